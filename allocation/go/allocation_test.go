@@ -0,0 +1,281 @@
+// A test harness that replays the traces of allocationTest.qnt against the
+// allocation of fees in x/distribution of SimApp, as shipped with
+// cosmos-sdk v0.46.4.
+//
+// SimApp is set up once for all traces, see simchain, and the fees of its
+// first block are moved to the community pool. For every trace, we create and
+// bond the validators of the spec with the stakes of their consensus powers
+// and their commission rates. Every state of a trace after
+// the first one is a block: the fee collector receives the fees of the
+// previous block, and the begin blocker of distribution allocates them to
+// the previous proposer, the validators, and the community pool, given the
+// votes of the validators on the previous block. Then we compare the
+// commission, the current rewards, and the outstanding rewards of every
+// validator, as well as the community pool, with the state of the spec,
+// digit by digit.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+	"github.com/cosmos/cosmos-sdk/x/distribution"
+	"github.com/cosmos/cosmos-sdk/x/staking/teststaking"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+const denom = sdk.DefaultBondDenom
+
+// VALIDATORS of allocationTest.qnt, in the order of the votes
+var validators = []string{"val1", "val2", "val3", "val4"}
+
+// a validator of the spec
+type TestValidator struct {
+	power          int64
+	commissionRate sdk.Dec
+}
+
+// the distribution records of a validator
+type TestRewards struct {
+	commission     sdk.Dec
+	currentRewards sdk.Dec
+	outstanding    sdk.Dec
+}
+
+// the distribution state of allocation.qnt
+type TestDistr struct {
+	validators    map[string]TestValidator
+	rewards       map[string]TestRewards
+	communityPool sdk.Dec
+}
+
+// a block, as recorded in lastAction
+type TestAction struct {
+	kind     string
+	fees     int64
+	proposer string
+	signed   map[string]bool
+}
+
+// a state of our testing state machine
+type TestState struct {
+	distr      TestDistr
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from allocationTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.distr.validators = make(map[string]TestValidator)
+		entries, err := itf.Map(jsonState.Get("distr.validators"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.distr.validators[e.Key.String()] = TestValidator{
+				power:          harness.Int64(t, e.Value.Get("power")),
+				commissionRate: sdk.NewDecFromBigIntWithPrec(harness.Dec(t, e.Value.Get("commissionRate")), sdk.Precision),
+			}
+		}
+		state.distr.rewards = make(map[string]TestRewards)
+		entries, err = itf.Map(jsonState.Get("distr.rewards"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.distr.rewards[e.Key.String()] = TestRewards{
+				commission:     sdk.NewDecFromBigIntWithPrec(harness.Dec(t, e.Value.Get("commission")), sdk.Precision),
+				currentRewards: sdk.NewDecFromBigIntWithPrec(harness.Dec(t, e.Value.Get("currentRewards")), sdk.Precision),
+				outstanding:    sdk.NewDecFromBigIntWithPrec(harness.Dec(t, e.Value.Get("outstanding")), sdk.Precision),
+			}
+		}
+		state.distr.communityPool = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("distr.communityPool")), sdk.Precision)
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.fees = harness.Int64(t, jsonState.Get("lastAction.fees"))
+		state.lastAction.proposer = jsonState.Get("lastAction.proposer").String()
+		state.lastAction.signed = make(map[string]bool)
+		signed, err := itf.Set(jsonState.Get("lastAction.signed"))
+		require.NoError(t, err)
+		for _, v := range signed {
+			state.lastAction.signed[v.String()] = true
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the consensus key of a validator, or of the unknown proposer
+func consPubKey(name string) cryptotypes.PubKey {
+	return ed25519.GenPrivKeyFromSecret([]byte(name)).PubKey()
+}
+
+// SimApp without the fees of its first block, which is set up once for all
+// traces, see simchain
+var sharedChain *simchain.Chain
+
+// the chain under test, together with the records that the spec starts from zero
+type testChain struct {
+	*simchain.Chain
+	// the community pool before the trace
+	initialPool sdk.Dec
+}
+
+// Reset the chain, whose fee collector is emptied on setup, and create the
+// validators of the spec.
+func setupChain(t *testing.T, init TestDistr) *testChain {
+	if sharedChain == nil {
+		sharedChain = simchain.New(t, simchain.Config{
+			Modules: []string{simchain.Distribution, simchain.Staking},
+			Header:  tmproto.Header{Height: 2},
+			Setup: func(c *simchain.Chain) {
+				// without votes, the begin blocker allocates all fees to the community pool
+				c.BeginBlock(c.Ctx.BlockHeight(), c.Ctx.BlockTime())
+			},
+		})
+	}
+	c := &testChain{Chain: sharedChain}
+	c.Reset()
+	tstaking := teststaking.NewHelper(t, c.Ctx, c.Keepers.Staking)
+	for _, name := range validators {
+		v := init.validators[name]
+		stake := c.Keepers.Staking.TokensFromConsensusPower(c.Ctx, v.power)
+		require.NoError(t, c.Fund(name, sdk.NewCoins(sdk.NewCoin(denom, stake))))
+		tstaking.Commission = stakingtypes.NewCommissionRates(v.commissionRate, v.commissionRate, sdk.ZeroDec())
+		tstaking.CreateValidator(simchain.ValAddr(name), consPubKey(name), stake, true)
+	}
+	c.EndBlock()
+	c.initialPool = c.Keepers.Distribution.GetFeePool(c.Ctx).CommunityPool.AmountOf(denom)
+	c.Ctx = c.Ctx.WithBlockHeight(c.Ctx.BlockHeight() + 1)
+	require.True(t, c.feeCollectorBalance().IsZero(), "fees left in the fee collector")
+	return c
+}
+
+// the tokens of the fee collector
+func (c *testChain) feeCollectorBalance() sdk.Int {
+	feeCollector := c.Keepers.Account.GetModuleAddress(authtypes.FeeCollectorName)
+	return c.Keepers.Bank.GetBalance(c.Ctx, feeCollector, denom).Amount
+}
+
+// the votes of all validators on the previous block, with their consensus powers
+func (c *testChain) votes(signed map[string]bool) []abci.VoteInfo {
+	reduction := c.Keepers.Staking.PowerReduction(c.Ctx)
+	votes := make([]abci.VoteInfo, 0, len(validators))
+	for _, name := range validators {
+		v := c.Keepers.Staking.Validator(c.Ctx, simchain.ValAddr(name))
+		votes = append(votes, abci.VoteInfo{
+			Validator:       abci.Validator{Address: consPubKey(name).Address(), Power: v.GetConsensusPower(reduction)},
+			SignedLastBlock: signed[name],
+		})
+	}
+	return votes
+}
+
+// collect the fees of the previous block, and run the begin blocker of
+// distribution in the next block
+func (c *testChain) executeAction(t *testing.T, a TestAction) {
+	require.Equal(t, "allocate", a.kind, "unknown action")
+	if a.fees > 0 {
+		fees := sdk.NewCoins(sdk.NewInt64Coin(denom, a.fees))
+		require.NoError(t, banktestutil.FundModuleAccount(c.Keepers.Bank, c.Ctx, authtypes.FeeCollectorName, fees))
+	}
+	c.Keepers.Distribution.SetPreviousProposerConsAddr(c.Ctx, sdk.ConsAddress(consPubKey(a.proposer).Address()))
+	req := abci.RequestBeginBlock{
+		Header:         tmproto.Header{Height: c.Ctx.BlockHeight()},
+		LastCommitInfo: abci.LastCommitInfo{Votes: c.votes(a.signed)},
+	}
+	distribution.BeginBlocker(c.Ctx, req, c.Keepers.Distribution)
+	assert.True(t, c.feeCollectorBalance().IsZero(), "fees left in the fee collector")
+	c.Ctx = c.Ctx.WithBlockHeight(c.Ctx.BlockHeight() + 1)
+}
+
+// compare a decimal of the keeper with the one of the spec
+func checkDec(t *testing.T, expected sdk.Dec, actual sdk.DecCoins, what string) {
+	assert.Equal(t, expected.String(), actual.AmountOf(denom).String(), what)
+}
+
+// compare the distribution records of the keeper with the state of the spec
+func (c *testChain) checkState(t *testing.T, s TestDistr) {
+	keeper, ctx := c.Keepers.Distribution, c.Ctx
+	reduction := c.Keepers.Staking.PowerReduction(ctx)
+	for _, name := range validators {
+		val := simchain.ValAddr(name)
+		v := c.Keepers.Staking.Validator(ctx, val)
+		assert.Equal(t, s.validators[name].power, v.GetConsensusPower(reduction), "power of %s", name)
+		assert.Equal(t, s.validators[name].commissionRate.String(), v.GetCommission().String(), "commission rate of %s", name)
+		r := s.rewards[name]
+		checkDec(t, r.commission, keeper.GetValidatorAccumulatedCommission(ctx, val).Commission, "commission of "+name)
+		checkDec(t, r.currentRewards, keeper.GetValidatorCurrentRewards(ctx, val).Rewards, "current rewards of "+name)
+		checkDec(t, r.outstanding, keeper.GetValidatorOutstandingRewards(ctx, val).Rewards, "outstanding rewards of "+name)
+	}
+	pool := keeper.GetFeePool(ctx).CommunityPool.AmountOf(denom).Sub(c.initialPool)
+	assert.Equal(t, s.communityPool.String(), pool.String(), "community pool")
+}
+
+// execute all blocks of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	chain := setupChain(t, states[0].distr)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%d_%s", i, s.lastAction.kind, s.lastAction.fees, s.lastAction.proposer)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				chain.executeAction(t, s.lastAction)
+			}
+			chain.checkState(t, s.distr)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+// Four validators with the same stake.
+func TestEvenStakes(t *testing.T) {
+	ExecFromItf(t, tracePath("evenStakes.itf.json"))
+}
+
+// A single whale among validators with dust stakes.
+func TestSingleWhale(t *testing.T) {
+	ExecFromItf(t, tracePath("singleWhale.itf.json"))
+}
+
+// Validators with dust stakes only, whose shares do not add up.
+func TestDustStakes(t *testing.T) {
+	ExecFromItf(t, tracePath("dustStakes.itf.json"))
+}
+
+// The community pool receives more than the community tax, see
+// noDustInCommunityPool in allocationTest.qnt.
+func TestDustInCommunityPool(t *testing.T) {
+	ExecFromItf(t, tracePath("dustInCommunityPool.itf.json"))
+}
@@ -0,0 +1,188 @@
+// A test harness that replays the traces of ammTest.qnt against the reference
+// pool in the package pool, which computes with the decimals of cosmos-sdk v0.46.4.
+//
+// The pool is created by alice with the reserves of the initial state. Every
+// state of a trace records the action that led to it in lastAction, and what
+// the action paid into the pool and out of it in outcome. We execute the
+// action with the pool and compare the amounts, digit by digit, as every
+// rounding step matters. Then we compare the reserves and the shares.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/informalsystems/quint-sandbox/amm/pool"
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the pool of amm.qnt
+type TestPool struct {
+	reserveX    *big.Int
+	reserveY    *big.Int
+	totalShares *big.Int
+	shares      map[string]*big.Int
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind     string
+	provider string
+	xForY    bool
+	amountIn *big.Int
+	maxX     *big.Int
+	maxY     *big.Int
+	burnt    *big.Int
+}
+
+// what an action paid into the pool and out of it, or its error
+type TestOutcome struct {
+	error       string
+	inX         *big.Int
+	inY         *big.Int
+	outX        *big.Int
+	outY        *big.Int
+	sharesDelta *big.Int
+}
+
+// a state of our testing state machine
+type TestState struct {
+	pool       TestPool
+	lastAction TestAction
+	outcome    TestOutcome
+}
+
+// parse the states in the ITF JSON format, as produced from ammTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.pool.reserveX = harness.BigInt(t, jsonState.Get("pool.reserveX"))
+		state.pool.reserveY = harness.BigInt(t, jsonState.Get("pool.reserveY"))
+		state.pool.totalShares = harness.BigInt(t, jsonState.Get("pool.totalShares"))
+		state.pool.shares, err = itf.StrBigIntMap(jsonState.Get("pool.shares"))
+		require.NoError(t, err)
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.provider = jsonState.Get("lastAction.provider").String()
+		state.lastAction.xForY = jsonState.Get("lastAction.xForY").Bool()
+		state.lastAction.amountIn = harness.BigInt(t, jsonState.Get("lastAction.amountIn"))
+		state.lastAction.maxX = harness.BigInt(t, jsonState.Get("lastAction.maxX"))
+		state.lastAction.maxY = harness.BigInt(t, jsonState.Get("lastAction.maxY"))
+		state.lastAction.burnt = harness.BigInt(t, jsonState.Get("lastAction.burnt"))
+		state.outcome.error = jsonState.Get("outcome.error").String()
+		state.outcome.inX = harness.BigInt(t, jsonState.Get("outcome.inX"))
+		state.outcome.inY = harness.BigInt(t, jsonState.Get("outcome.inY"))
+		state.outcome.outX = harness.BigInt(t, jsonState.Get("outcome.outX"))
+		state.outcome.outY = harness.BigInt(t, jsonState.Get("outcome.outY"))
+		state.outcome.sharesDelta = harness.BigInt(t, jsonState.Get("outcome.sharesDelta"))
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// execute an action with the pool and return its outcome
+func executeAction(p *pool.Pool, a TestAction) TestOutcome {
+	zero := sdk.ZeroInt()
+	inX, inY, outX, outY, sharesDelta := zero, zero, zero, zero, zero
+	var err error
+	switch a.kind {
+	case "swap":
+		amountIn := sdk.NewIntFromBigInt(a.amountIn)
+		if a.xForY {
+			inX = amountIn
+			outY, err = p.SwapXForY(amountIn)
+		} else {
+			inY = amountIn
+			outX, err = p.SwapYForX(amountIn)
+		}
+
+	case "addLiquidity":
+		sharesDelta, inX, inY, err = p.AddLiquidity(a.provider,
+			sdk.NewIntFromBigInt(a.maxX), sdk.NewIntFromBigInt(a.maxY))
+
+	case "removeLiquidity":
+		outX, outY, err = p.RemoveLiquidity(a.provider, sdk.NewIntFromBigInt(a.burnt))
+		sharesDelta = sdk.NewIntFromBigInt(a.burnt).Neg()
+
+	default:
+		return TestOutcome{error: "unknown action: " + a.kind}
+	}
+	if err != nil {
+		return TestOutcome{error: err.Error(), inX: big.NewInt(0), inY: big.NewInt(0),
+			outX: big.NewInt(0), outY: big.NewInt(0), sharesDelta: big.NewInt(0)}
+	}
+	return TestOutcome{inX: inX.BigInt(), inY: inY.BigInt(), outX: outX.BigInt(), outY: outY.BigInt(),
+		sharesDelta: sharesDelta.BigInt()}
+}
+
+// compare the outcome of an action with the spec
+func checkOutcome(t *testing.T, expected, actual TestOutcome) {
+	assert.Equal(t, expected.error, actual.error, "error")
+	assert.Equal(t, expected.inX.String(), actual.inX.String(), "tokens X paid in")
+	assert.Equal(t, expected.inY.String(), actual.inY.String(), "tokens Y paid in")
+	assert.Equal(t, expected.outX.String(), actual.outX.String(), "tokens X paid out")
+	assert.Equal(t, expected.outY.String(), actual.outY.String(), "tokens Y paid out")
+	assert.Equal(t, expected.sharesDelta.String(), actual.sharesDelta.String(), "shares minted or burnt")
+}
+
+// compare the reserves and the shares of the pool with the state of the spec
+func checkState(t *testing.T, p *pool.Pool, s TestState) {
+	assert.Equal(t, s.pool.reserveX.String(), p.ReserveX.String(), "reserve X")
+	assert.Equal(t, s.pool.reserveY.String(), p.ReserveY.String(), "reserve Y")
+	assert.Equal(t, s.pool.totalShares.String(), p.TotalShares.String(), "total shares")
+	for provider, expected := range s.pool.shares {
+		assert.Equal(t, expected.String(), p.SharesOf(provider).String(), "shares of %s", provider)
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	init := states[0]
+	p := pool.New(init.lastAction.provider,
+		sdk.NewIntFromBigInt(init.pool.reserveX), sdk.NewIntFromBigInt(init.pool.reserveY))
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, s.lastAction.provider)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				checkOutcome(t, s.outcome, executeAction(p, s.lastAction))
+			}
+			checkState(t, p, s)
+		})
+		if !ok {
+			// the states of the pool and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+func TestBoundaries(t *testing.T) {
+	ExecFromItf(t, tracePath("boundaries.itf.json"))
+}
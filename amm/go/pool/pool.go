@@ -0,0 +1,150 @@
+// Package pool is a reference implementation of a constant-product pool
+// (x * y = k) with two tokens, a swap fee, and liquidity shares. It computes
+// with the decimals of cosmos-sdk v0.46.4, as the pools of Cosmos chains do,
+// and rounds every step in favor of the pool:
+//
+//   - the output of a swap is truncated,
+//   - the shares that are minted for a deposit are truncated, whereas
+//     the deposited tokens are rounded up,
+//   - the tokens that are paid out for burnt shares are truncated.
+package pool
+
+import (
+	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// SwapFee is the fee of 0.3% that is charged on the input of a swap.
+var SwapFee = sdk.NewDecWithPrec(3, 3)
+
+// The errors of the pool.
+var (
+	ErrNonPositive        = errors.New("amount must be positive")
+	ErrZeroOutput         = errors.New("output is zero")
+	ErrZeroShares         = errors.New("shares are zero")
+	ErrInsufficientShares = errors.New("insufficient shares")
+	ErrDrain              = errors.New("cannot remove all liquidity")
+)
+
+// Pool holds the reserves of the tokens X and Y and the shares of the providers.
+type Pool struct {
+	ReserveX    sdk.Int
+	ReserveY    sdk.Int
+	TotalShares sdk.Int
+	shares      map[string]sdk.Int
+}
+
+// New returns a pool that is created by a provider with the initial reserves.
+// The provider receives as many shares as tokens X.
+func New(provider string, reserveX, reserveY sdk.Int) *Pool {
+	return &Pool{
+		ReserveX:    reserveX,
+		ReserveY:    reserveY,
+		TotalShares: reserveX,
+		shares:      map[string]sdk.Int{provider: reserveX},
+	}
+}
+
+// SharesOf returns the shares of a provider.
+func (p *Pool) SharesOf(provider string) sdk.Int {
+	if s, ok := p.shares[provider]; ok {
+		return s
+	}
+	return sdk.ZeroInt()
+}
+
+// SwapOutput returns the output of a swap with the given reserves, truncated:
+//
+//	out = reserveOut * in * (1 - fee) / (reserveIn + in * (1 - fee))
+func SwapOutput(reserveIn, reserveOut, amountIn sdk.Int) sdk.Int {
+	inAfterFee := sdk.NewDecFromInt(amountIn).Mul(sdk.OneDec().Sub(SwapFee))
+	return sdk.NewDecFromInt(reserveOut).Mul(inAfterFee).
+		QuoTruncate(sdk.NewDecFromInt(reserveIn).Add(inAfterFee)).TruncateInt()
+}
+
+// SwapXForY swaps the amount of token X for token Y and returns the output.
+func (p *Pool) SwapXForY(amountIn sdk.Int) (sdk.Int, error) {
+	out, err := p.swapOutput(p.ReserveX, p.ReserveY, amountIn)
+	if err != nil {
+		return sdk.ZeroInt(), err
+	}
+	p.ReserveX = p.ReserveX.Add(amountIn)
+	p.ReserveY = p.ReserveY.Sub(out)
+	return out, nil
+}
+
+// SwapYForX swaps the amount of token Y for token X and returns the output.
+func (p *Pool) SwapYForX(amountIn sdk.Int) (sdk.Int, error) {
+	out, err := p.swapOutput(p.ReserveY, p.ReserveX, amountIn)
+	if err != nil {
+		return sdk.ZeroInt(), err
+	}
+	p.ReserveY = p.ReserveY.Add(amountIn)
+	p.ReserveX = p.ReserveX.Sub(out)
+	return out, nil
+}
+
+func (p *Pool) swapOutput(reserveIn, reserveOut, amountIn sdk.Int) (sdk.Int, error) {
+	if !amountIn.IsPositive() {
+		return sdk.ZeroInt(), ErrNonPositive
+	}
+	out := SwapOutput(reserveIn, reserveOut, amountIn)
+	if !out.IsPositive() {
+		return sdk.ZeroInt(), ErrZeroOutput
+	}
+	return out, nil
+}
+
+// AddLiquidity deposits up to maxX and maxY. The shares are minted in
+// proportion to the smaller of the two ratios, and the deposit is computed
+// from the shares. It returns the minted shares and the deposited tokens.
+func (p *Pool) AddLiquidity(provider string, maxX, maxY sdk.Int) (minted, inX, inY sdk.Int, err error) {
+	if !maxX.IsPositive() || !maxY.IsPositive() {
+		return sdk.ZeroInt(), sdk.ZeroInt(), sdk.ZeroInt(), ErrNonPositive
+	}
+	ratio := sdk.MinDec(
+		sdk.NewDecFromInt(maxX).QuoTruncate(sdk.NewDecFromInt(p.ReserveX)),
+		sdk.NewDecFromInt(maxY).QuoTruncate(sdk.NewDecFromInt(p.ReserveY)))
+	minted = sdk.NewDecFromInt(p.TotalShares).MulTruncate(ratio).TruncateInt()
+	if !minted.IsPositive() {
+		return sdk.ZeroInt(), sdk.ZeroInt(), sdk.ZeroInt(), ErrZeroShares
+	}
+	// the tokens that back the minted shares, rounded up
+	deposit := func(reserve sdk.Int) sdk.Int {
+		return sdk.NewDecFromInt(reserve).Mul(sdk.NewDecFromInt(minted)).
+			QuoRoundUp(sdk.NewDecFromInt(p.TotalShares)).Ceil().TruncateInt()
+	}
+	inX, inY = deposit(p.ReserveX), deposit(p.ReserveY)
+	p.ReserveX = p.ReserveX.Add(inX)
+	p.ReserveY = p.ReserveY.Add(inY)
+	p.TotalShares = p.TotalShares.Add(minted)
+	p.shares[provider] = p.SharesOf(provider).Add(minted)
+	return minted, inX, inY, nil
+}
+
+// RemoveLiquidity burns the shares of a provider for its part of the
+// reserves, truncated. The last shares cannot be burnt, as the pool would be
+// empty. It returns the tokens that were paid out.
+func (p *Pool) RemoveLiquidity(provider string, burnt sdk.Int) (outX, outY sdk.Int, err error) {
+	if !burnt.IsPositive() {
+		return sdk.ZeroInt(), sdk.ZeroInt(), ErrNonPositive
+	}
+	if burnt.GT(p.SharesOf(provider)) {
+		return sdk.ZeroInt(), sdk.ZeroInt(), ErrInsufficientShares
+	}
+	if burnt.Equal(p.TotalShares) {
+		return sdk.ZeroInt(), sdk.ZeroInt(), ErrDrain
+	}
+	// the part of a reserve that backs the burnt shares, truncated
+	payout := func(reserve sdk.Int) sdk.Int {
+		return sdk.NewDecFromInt(reserve).Mul(sdk.NewDecFromInt(burnt)).
+			QuoTruncate(sdk.NewDecFromInt(p.TotalShares)).TruncateInt()
+	}
+	outX, outY = payout(p.ReserveX), payout(p.ReserveY)
+	p.ReserveX = p.ReserveX.Sub(outX)
+	p.ReserveY = p.ReserveY.Sub(outY)
+	p.TotalShares = p.TotalShares.Sub(burnt)
+	p.shares[provider] = p.SharesOf(provider).Sub(burnt)
+	return outX, outY, nil
+}
@@ -0,0 +1,193 @@
+// A test harness that replays the traces of bankTest.qnt against the bank
+// keeper of SimApp, as shipped with cosmos-sdk v0.46.4.
+//
+// Every state of a trace records the transaction that led to it in lastTx.
+// We execute this transaction with the keeper and compare the balances of all
+// accounts, as well as the total supply, with the state of the spec.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the module accounts of SimApp that may mint and burn coins
+const (
+	minterModule = minttypes.ModuleName
+	burnerModule = govtypes.ModuleName
+)
+
+// a transaction, as recorded in lastTx
+type TestTx struct {
+	kind     string
+	sender   string
+	receiver string
+	coins    map[string]*big.Int
+	// whether the transaction is expected to fail
+	error bool
+}
+
+// a state of our testing state machine
+type TestState struct {
+	balances map[string]map[string]*big.Int
+	supply   map[string]*big.Int
+	lastTx   TestTx
+}
+
+// parse the states in the ITF JSON format, as produced from bankTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.balances = make(map[string]map[string]*big.Int)
+		entries, err := itf.Map(jsonState.Get("balances"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.balances[e.Key.String()] = parseCoins(t, e.Value)
+		}
+		state.supply = parseCoins(t, jsonState.Get("supply"))
+		state.lastTx.kind = jsonState.Get("lastTx.kind").String()
+		state.lastTx.sender = jsonState.Get("lastTx.sender").String()
+		state.lastTx.receiver = jsonState.Get("lastTx.receiver").String()
+		state.lastTx.coins = parseCoins(t, jsonState.Get("lastTx.coins"))
+		state.lastTx.error = jsonState.Get("lastTx.error").Bool()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse coins, that is, a map from denominations to amounts
+func parseCoins(t *testing.T, obj gjson.Result) map[string]*big.Int {
+	coins, err := itf.StrBigIntMap(obj)
+	require.NoError(t, err)
+	return coins
+}
+
+// construct sdk.Coins out of a map, sorted by denominations
+func mapToCoins(m map[string]*big.Int) sdk.Coins {
+	coins := sdk.Coins{}
+	for denom, amount := range m {
+		coins = append(coins, sdk.NewCoin(denom, sdk.NewIntFromBigInt(amount)))
+	}
+	sort.Sort(coins)
+	return coins
+}
+
+// SimApp, which is set up once for all traces, see simchain
+var chain *simchain.Chain
+
+// reset the chain for a trace, and set it up on the first one
+func resetChain(t *testing.T) *simchain.Chain {
+	if chain == nil {
+		chain = simchain.New(t, simchain.Config{})
+	}
+	chain.Reset()
+	return chain
+}
+
+// Execute a transaction with the keeper. As in the execution of a real
+// transaction, the writes are discarded when the transaction fails.
+func executeTx(c *simchain.Chain, tx TestTx) error {
+	cacheCtx, write := c.Ctx.CacheContext()
+	coins := mapToCoins(tx.coins)
+	var err error
+	switch tx.kind {
+	case "send":
+		err = c.Keepers.Bank.SendCoins(cacheCtx, simchain.Addr(tx.sender), simchain.Addr(tx.receiver), coins)
+
+	case "mint":
+		err = c.Keepers.Bank.MintCoins(cacheCtx, minterModule, coins)
+		if err == nil {
+			err = c.Keepers.Bank.SendCoinsFromModuleToAccount(cacheCtx, minterModule, simchain.Addr(tx.receiver), coins)
+		}
+
+	case "burn":
+		err = c.Keepers.Bank.SendCoinsFromAccountToModule(cacheCtx, simchain.Addr(tx.sender), burnerModule, coins)
+		if err == nil {
+			err = c.Keepers.Bank.BurnCoins(cacheCtx, burnerModule, coins)
+		}
+
+	default:
+		return fmt.Errorf("unknown transaction: %s", tx.kind)
+	}
+	if err == nil {
+		write()
+	}
+	return err
+}
+
+// compare the balances and the supply of the keeper with the state of the spec
+func checkState(t *testing.T, c *simchain.Chain, s TestState) {
+	for name, expected := range s.balances {
+		actual := c.Keepers.Bank.GetAllBalances(c.Ctx, simchain.Addr(name))
+		assert.True(t, mapToCoins(expected).IsEqual(actual),
+			"balance of %s: expected %s, found %s", name, mapToCoins(expected), actual)
+	}
+	for denom, expected := range s.supply {
+		actual := c.Keepers.Bank.GetSupply(c.Ctx, denom)
+		assert.Equal(t, expected.String(), actual.Amount.String(), "supply of %s", denom)
+	}
+	// the denominations that are missing in the supply of the spec must be zero
+	for denom := range s.lastTx.coins {
+		if _, found := s.supply[denom]; !found {
+			assert.True(t, c.Keepers.Bank.GetSupply(c.Ctx, denom).IsZero(), "supply of %s", denom)
+		}
+	}
+}
+
+// execute all transactions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	c := resetChain(t)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s_%s", i, s.lastTx.kind, s.lastTx.sender, s.lastTx.receiver)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := executeTx(c, s.lastTx)
+				if s.lastTx.error {
+					require.Error(t, err, "the transaction should fail")
+				} else {
+					require.NoError(t, err, "the transaction should succeed")
+				}
+			}
+			checkState(t, c, s)
+		})
+		if !ok {
+			// the states of the keeper and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
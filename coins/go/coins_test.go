@@ -0,0 +1,208 @@
+// A test harness that replays the traces of coinsTest.qnt against sdk.Coins.
+//
+// In the spec, normalized coins are maps from denominations to amounts, which
+// are written as {"#map": [[denom, amount], ...]} in ITF. We decode them with
+// the itf package and convert them to sorted sdk.Coins. The results of the SDK
+// are checked both against the spec and against the invariants of sdk.Coins.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// With -itf-list, the harness lists the operations of every trace in the trace
+// directory, and whether they are expected to panic, instead of running them
+// against the SDK, e.g., to review a new corpus: go test -v -args -itf-list
+var listTraces = flag.Bool("itf-list", false, "list the operations of the traces instead of executing them")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *listTraces {
+		if err := listTraceDir(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// list the operations of the traces in the trace directory, see itf.List
+func listTraceDir() error {
+	files, err := filepath.Glob(tracePath("*.itf.json*"))
+	if err != nil {
+		return err
+	}
+	for _, filename := range files {
+		trace, err := itf.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		if err := itf.List(os.Stdout, filepath.Base(filename), trace, "opcode", "opResult.error"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// a coin in a raw slice, which may be invalid
+type TestCoin struct {
+	denom  string
+	amount *big.Int
+}
+
+// a state of our testing state machine, which is also an input to the Golang test
+type TestInput struct {
+	opcode string
+	// the raw slice that is passed to NewCoins
+	slice []TestCoin
+	arg1  map[string]*big.Int
+	arg2  map[string]*big.Int
+	// whether the operation is expected to panic
+	error bool
+	// the expected coins
+	result map[string]*big.Int
+	// the expected result of a predicate
+	holds bool
+}
+
+// parse the states in the ITF JSON format, as produced from coinsTest.qnt
+func parseItf(t *testing.T, filename string) []TestInput {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestInput, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestInput
+		state.opcode = jsonState.Get("opcode").String()
+		for _, jsonCoin := range jsonState.Get("opSlice").Array() {
+			amount, err := itf.BigInt(jsonCoin.Get("amount"))
+			require.NoError(t, err)
+			state.slice = append(state.slice, TestCoin{jsonCoin.Get("denom").String(), amount})
+		}
+		state.arg1 = parseCoins(t, jsonState.Get("opArg1"))
+		state.arg2 = parseCoins(t, jsonState.Get("opArg2"))
+		state.error = jsonState.Get("opResult.error").Bool()
+		state.result = parseCoins(t, jsonState.Get("opResult.coins"))
+		state.holds = jsonState.Get("opHolds").Bool()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse normalized coins, that is, a map from denominations to amounts
+func parseCoins(t *testing.T, obj gjson.Result) map[string]*big.Int {
+	coins, err := itf.StrBigIntMap(obj)
+	require.NoError(t, err)
+	return coins
+}
+
+// construct sdk.Coins out of a map, sorted by denominations, as NewCoins does
+func mapToCoins(m map[string]*big.Int) sdk.Coins {
+	coins := sdk.Coins{}
+	for denom, amount := range m {
+		coins = append(coins, sdk.Coin{Denom: denom, Amount: sdk.NewIntFromBigInt(amount)})
+	}
+	sort.Sort(coins)
+	return coins
+}
+
+// convert sdk.Coins to a map from denominations to amounts in decimal
+func coinsToMap(coins sdk.Coins) map[string]string {
+	m := make(map[string]string)
+	for _, c := range coins {
+		m[c.Denom] = c.Amount.String()
+	}
+	return m
+}
+
+// compare the coins returned by the SDK with the expected ones
+func checkCoins(t *testing.T, expected map[string]*big.Int, actual sdk.Coins) {
+	expectedStr := make(map[string]string)
+	for denom, amount := range expected {
+		expectedStr[denom] = amount.String()
+	}
+	assert.Equal(t, expectedStr, coinsToMap(actual), "the coins should be equal")
+	// the contract of NewCoins, Add, and Sub
+	assert.NoError(t, actual.Validate(), "the coins should be sorted, positive, and unique")
+}
+
+// run an operation that returns coins, or expect a panic
+func checkResult(t *testing.T, s TestInput, op func() sdk.Coins) {
+	if s.error {
+		require.Panics(t, func() { op() })
+	} else {
+		checkCoins(t, s.result, op())
+	}
+}
+
+// connect the test inputs to the actual code
+func executeTest(t *testing.T, s TestInput) {
+	arg1 := mapToCoins(s.arg1)
+	arg2 := mapToCoins(s.arg2)
+	switch s.opcode {
+	case "newCoins":
+		// construct the coins literally, as NewCoin panics on negative amounts
+		slice := make([]sdk.Coin, 0, len(s.slice))
+		for _, c := range s.slice {
+			slice = append(slice, sdk.Coin{Denom: c.denom, Amount: sdk.NewIntFromBigInt(c.amount)})
+		}
+		checkResult(t, s, func() sdk.Coins { return sdk.NewCoins(slice...) })
+
+	case "add":
+		checkResult(t, s, func() sdk.Coins { return arg1.Add(arg2...) })
+
+	case "sub":
+		checkResult(t, s, func() sdk.Coins { return arg1.Sub(arg2...) })
+
+	case "isAllGTE":
+		assert.Equal(t, s.holds, arg1.IsAllGTE(arg2), "%s.IsAllGTE(%s)", arg1, arg2)
+
+	default:
+		require.Fail(t, "unknown opcode: "+s.opcode)
+	}
+}
+
+// execute all steps of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s", i, s.opcode)
+		t.Run(description, func(t *testing.T) {
+			executeTest(t, s)
+		})
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+func TestNormalization(t *testing.T) {
+	ExecFromItf(t, tracePath("normalization.itf.json"))
+}
@@ -0,0 +1,147 @@
+// Package crdt is a small library of two state-based CRDTs: a grow-only
+// counter (GCounter) and an observed-remove set (ORSet). Every replica
+// updates its own copy, and merges the copies of the other replicas into it.
+// Merging is commutative, associative, and idempotent, so the replicas
+// converge once they have observed the same updates, no matter in which
+// order they merged them.
+package crdt
+
+import "sort"
+
+// GCounter is a counter that only grows. Every replica counts its own increments.
+type GCounter struct {
+	ID     string
+	counts map[string]uint64
+}
+
+// NewGCounter returns a counter of zero at the replica id.
+func NewGCounter(id string) *GCounter {
+	return &GCounter{ID: id, counts: make(map[string]uint64)}
+}
+
+// Increment increments the counter by n.
+func (c *GCounter) Increment(n uint64) {
+	c.counts[c.ID] += n
+}
+
+// Value returns the sum of the increments of all replicas.
+func (c *GCounter) Value() uint64 {
+	var sum uint64
+	for _, n := range c.counts {
+		sum += n
+	}
+	return sum
+}
+
+// Count returns the increments of a replica that this replica observed.
+func (c *GCounter) Count(id string) uint64 {
+	return c.counts[id]
+}
+
+// Merge merges another counter into this counter.
+func (c *GCounter) Merge(other *GCounter) {
+	for id, n := range other.counts {
+		if n > c.counts[id] {
+			c.counts[id] = n
+		}
+	}
+}
+
+// Tag tells apart the additions of the same element.
+type Tag struct {
+	// the replica that added the element
+	Replica string
+	// the number of additions of the replica before
+	Seq uint64
+}
+
+// Tagged is an element with the tag of its addition.
+type Tagged struct {
+	Elem string
+	Tag  Tag
+}
+
+// ORSet is a set, in which an addition wins over a concurrent removal.
+type ORSet struct {
+	ID      string
+	seq     uint64
+	adds    map[Tagged]bool
+	removes map[Tagged]bool
+}
+
+// NewORSet returns an empty set at the replica id.
+func NewORSet(id string) *ORSet {
+	return &ORSet{ID: id, adds: make(map[Tagged]bool), removes: make(map[Tagged]bool)}
+}
+
+// Add adds an element with a new tag.
+func (s *ORSet) Add(elem string) {
+	s.adds[Tagged{Elem: elem, Tag: Tag{Replica: s.ID, Seq: s.seq}}] = true
+	s.seq++
+}
+
+// Remove removes the tags of an element that this replica observed, and
+// returns whether the element was in the set.
+func (s *ORSet) Remove(elem string) bool {
+	found := s.Contains(elem)
+	for t := range s.adds {
+		if t.Elem == elem {
+			s.removes[t] = true
+		}
+	}
+	return found
+}
+
+// Contains returns whether an element has a tag that was not removed.
+func (s *ORSet) Contains(elem string) bool {
+	for t := range s.adds {
+		if t.Elem == elem && !s.removes[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// Elements returns the elements of the set, in order.
+func (s *ORSet) Elements() []string {
+	set := make(map[string]bool)
+	for t := range s.adds {
+		if !s.removes[t] {
+			set[t.Elem] = true
+		}
+	}
+	elems := make([]string, 0, len(set))
+	for e := range set {
+		elems = append(elems, e)
+	}
+	sort.Strings(elems)
+	return elems
+}
+
+// Adds returns the tagged elements that were added, in no particular order.
+func (s *ORSet) Adds() []Tagged {
+	return keys(s.adds)
+}
+
+// Removes returns the tagged elements that were removed, in no particular order.
+func (s *ORSet) Removes() []Tagged {
+	return keys(s.removes)
+}
+
+func keys(set map[Tagged]bool) []Tagged {
+	ts := make([]Tagged, 0, len(set))
+	for t := range set {
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// Merge merges another set into this set.
+func (s *ORSet) Merge(other *ORSet) {
+	for t := range other.adds {
+		s.adds[t] = true
+	}
+	for t := range other.removes {
+		s.removes[t] = true
+	}
+}
@@ -0,0 +1,231 @@
+// A test harness that replays the traces of crdtTest.qnt against the CRDTs
+// of the package crdt.
+//
+// Every replica of the spec holds a G-counter and an OR-set of the package
+// crdt. Every state of a trace records the operation that led to it in
+// lastAction: an update of a replica, or a replica merging the state of
+// another replica. Thus, the trace defines the order of the merges. After
+// every step, we compare the counts and the tagged elements of every replica
+// with the spec, which we decode from ITF maps and sets of tuples. We also
+// check convergence: the replicas that observed the same updates agree on
+// the value of the counter and on the elements of the set.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/crdt/crdt"
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// a replica of crdt.qnt
+type TestReplica struct {
+	counts  map[string]uint64
+	adds    []crdt.Tagged
+	removes []crdt.Tagged
+}
+
+// an operation, as recorded in lastAction
+type TestAction struct {
+	kind    string
+	replica string
+	other   string
+	elem    string
+	amount  uint64
+	found   bool
+}
+
+// a state of our testing state machine
+type TestState struct {
+	replicas   map[string]TestReplica
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from crdtTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.replicas = make(map[string]TestReplica)
+		entries, err := itf.Map(jsonState.Get("replicas"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			r := TestReplica{counts: make(map[string]uint64)}
+			counts, err := itf.Map(e.Value.Get("counts"))
+			require.NoError(t, err)
+			for _, c := range counts {
+				r.counts[c.Key.String()] = harness.Uint64(t, c.Value)
+			}
+			r.adds = parseTagged(t, e.Value.Get("adds"))
+			r.removes = parseTagged(t, e.Value.Get("removes"))
+			state.replicas[e.Key.String()] = r
+		}
+		state.lastAction = TestAction{
+			kind:    jsonState.Get("lastAction.kind").String(),
+			replica: jsonState.Get("lastAction.replica").String(),
+			other:   jsonState.Get("lastAction.other").String(),
+			elem:    jsonState.Get("lastAction.elem").String(),
+			amount:  harness.Uint64(t, jsonState.Get("lastAction.amount")),
+			found:   jsonState.Get("lastAction.found").Bool(),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a set of tagged elements, that is, of tuples (elem, replica, seq)
+func parseTagged(t *testing.T, obj gjson.Result) []crdt.Tagged {
+	elems, err := itf.Set(obj)
+	require.NoError(t, err)
+	tagged := make([]crdt.Tagged, 0, len(elems))
+	for _, e := range elems {
+		tuple, err := itf.Tuple(e)
+		require.NoError(t, err)
+		require.Len(t, tuple, 3)
+		tagged = append(tagged, crdt.Tagged{
+			Elem: tuple[0].String(),
+			Tag:  crdt.Tag{Replica: tuple[1].String(), Seq: harness.Uint64(t, tuple[2])},
+		})
+	}
+	return tagged
+}
+
+// the CRDTs of a replica under test
+type testReplica struct {
+	counter *crdt.GCounter
+	set     *crdt.ORSet
+}
+
+// create the replicas of the initial state
+func setupReplicas(init TestState) map[string]*testReplica {
+	replicas := make(map[string]*testReplica)
+	for id := range init.replicas {
+		replicas[id] = &testReplica{counter: crdt.NewGCounter(id), set: crdt.NewORSet(id)}
+	}
+	return replicas
+}
+
+// apply an operation to the replicas
+func execute(t *testing.T, replicas map[string]*testReplica, a TestAction) {
+	r := replicas[a.replica]
+	switch a.kind {
+	case "increment":
+		r.counter.Increment(a.amount)
+
+	case "add":
+		r.set.Add(a.elem)
+
+	case "remove":
+		assert.Equal(t, a.found, r.set.Remove(a.elem), "%s was in the set of %s", a.elem, a.replica)
+
+	case "merge":
+		other := replicas[a.other]
+		r.counter.Merge(other.counter)
+		r.set.Merge(other.set)
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+	}
+}
+
+// whether two replicas of the spec observed the same updates
+func sameUpdates(r1, r2 TestReplica) bool {
+	if fmt.Sprint(r1.counts) != fmt.Sprint(r2.counts) {
+		return false
+	}
+	return fmt.Sprint(sorted(r1.adds), sorted(r1.removes)) == fmt.Sprint(sorted(r2.adds), sorted(r2.removes))
+}
+
+// the tagged elements in order, as strings
+func sorted(tagged []crdt.Tagged) []string {
+	strs := make([]string, 0, len(tagged))
+	for _, t := range tagged {
+		strs = append(strs, fmt.Sprint(t))
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+// compare the replicas with the state of the spec, and check convergence
+func checkState(t *testing.T, replicas map[string]*testReplica, expected TestState) {
+	for id, e := range expected.replicas {
+		r := replicas[id]
+		var value uint64
+		for other, count := range e.counts {
+			assert.Equal(t, count, r.counter.Count(other), "count of %s at %s", other, id)
+			value += count
+		}
+		assert.Equal(t, value, r.counter.Value(), "value of the counter at %s", id)
+		assert.ElementsMatch(t, e.adds, r.set.Adds(), "additions at %s", id)
+		assert.ElementsMatch(t, e.removes, r.set.Removes(), "removals at %s", id)
+	}
+	for id1, e1 := range expected.replicas {
+		for id2, e2 := range expected.replicas {
+			if id1 < id2 && sameUpdates(e1, e2) {
+				r1, r2 := replicas[id1], replicas[id2]
+				assert.Equal(t, r1.counter.Value(), r2.counter.Value(), "%s and %s converged on the counter", id1, id2)
+				assert.Equal(t, r1.set.Elements(), r2.set.Elements(), "%s and %s converged on the set", id1, id2)
+			}
+		}
+	}
+}
+
+// execute all operations of a trace, one by one, and return the replicas in the last state
+func ExecFromItf(t *testing.T, filename string) map[string]*testReplica {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	replicas := setupReplicas(states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s_%s", i, s.lastAction.kind, s.lastAction.replica, s.lastAction.other)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				execute(t, replicas, s.lastAction)
+			}
+			checkState(t, replicas, s)
+		})
+		if !ok {
+			// the states of the replicas and the spec diverged
+			break
+		}
+	}
+	return replicas
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// all replicas converge after increments, additions, and removals
+func TestConverged(t *testing.T) {
+	replicas := ExecFromItf(t, tracePath("converged.itf.json"))
+	r1 := replicas["r1"]
+	assert.NotZero(t, r1.counter.Value())
+	assert.NotEmpty(t, r1.set.Elements())
+	for id, r := range replicas {
+		assert.Equal(t, r1.counter.Value(), r.counter.Value(), "value of the counter at %s", id)
+		assert.Equal(t, r1.set.Elements(), r.set.Elements(), "elements of the set at %s", id)
+	}
+}
@@ -0,0 +1,29 @@
+#!/usr/bin/env bash
+#
+# Replay the same trace corpus against several releases of cosmos-sdk
+# and report the steps whose outcome depends on the release.
+#
+# Usage: ./crossversion.sh [trace-dir]
+
+# fail asap
+set -e
+
+TRACE_DIR=`realpath ${1:-test-inputs-v0.46.4}`
+# release:go.mod file:build tags
+RELEASES="v0.45.16:go.v0.45.mod: v0.46.4:go.mod: v0.47.17:go.v0.47.mod: v0.50.14:go.v0.50.mod:sdk050"
+
+mkdir -p reports
+cd go
+REPORTS=""
+for r in $RELEASES; do
+    IFS=: read release modfile tags <<< "$r"
+    echo "[$release] replaying $TRACE_DIR..."
+    go mod tidy -modfile=$modfile
+    # some of the steps are expected to fail, so we do not stop here
+    go test -json -modfile=$modfile -tags="$tags" \
+        -run 'TestOneRun|Test56ops|TestAddErrorOnBitlen|TestChain' \
+        -args -itf-dir=$TRACE_DIR > ../reports/$release.json || true
+    REPORTS="$REPORTS ../reports/$release.json"
+done
+
+go run ./cmd/divergence $REPORTS | tee ../reports/divergence.md
@@ -6,11 +6,13 @@
 set -e
 
 for i in `seq 1 1000`; do
-    echo "[$i] generating a long test..."
-    quint run --max-samples=100 --max-steps=10000 --out-itf=t.itf.json decimalTest.qnt
+    # the seed is passed to the harness, which prints it on failure
+    seed=$((RANDOM * 32768 + RANDOM))
+    echo "[$i] generating a long test with the seed $seed..."
+    quint run --seed=$seed --max-samples=100 --max-steps=10000 --out-itf=t.itf.json --main=sdk constants.qnt
     cp t.itf.json test-inputs-v0.46.4/oneRandom.itf.json
     echo "[$i] replaying the test..."
     cd go
-    go test -v -run TestOneRun
+    go test -v -run TestOneRun -args -quint-seed=$seed
     cd ..
 done
@@ -0,0 +1,106 @@
+// Allocation profiling of Dec on the traces. Every state of the traces is
+// executed with testing.AllocsPerRun, and the allocations are aggregated by
+// opcode. The profile is optional, as it executes every state many times:
+//
+//	go test -run TestAllocs -v -args -allocs
+//
+// To catch a regression across releases of cosmos-sdk, the profile of one
+// release is saved as a baseline, and the profile of another release is
+// compared against it. An opcode fails, when it allocates more than in the
+// baseline on any of its states:
+//
+//	go test -run TestAllocs -args -allocs -allocs-baseline=allocs.json -update-allocs
+//	go test -modfile=go.v0.47.mod -run TestAllocs -args -allocs -allocs-baseline=$PWD/allocs.json
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	profileAllocs  = flag.Bool("allocs", false, "count the allocations of every operation on the traces")
+	allocsBaseline = flag.String("allocs-baseline", "", "the JSON file of the allocations to compare against")
+	updateAllocs   = flag.Bool("update-allocs", false, "write the allocations to -allocs-baseline")
+)
+
+// the number of times that every state is executed by testing.AllocsPerRun
+const allocsRuns = 100
+
+// the allocations of an opcode on the states of the traces
+type allocProfile struct {
+	States int     `json:"states"`
+	Mean   float64 `json:"mean"`
+	Max    float64 `json:"max"`
+}
+
+// count the allocations of every opcode on the traces in the trace directory
+func profileAllocsFromItf(t *testing.T) map[string]allocProfile {
+	files, err := traceFiles()
+	require.NoError(t, err)
+	profiles := make(map[string]allocProfile)
+	for _, filename := range files {
+		for _, s := range parseItf(filename) {
+			call, ok := prepareCall(s)
+			if !ok {
+				continue
+			}
+			allocs := testing.AllocsPerRun(allocsRuns, call)
+			p := profiles[s.opcode]
+			p.Mean = (p.Mean*float64(p.States) + allocs) / float64(p.States+1)
+			p.States++
+			if allocs > p.Max {
+				p.Max = allocs
+			}
+			profiles[s.opcode] = p
+		}
+	}
+	return profiles
+}
+
+// Report the allocations of every opcode, and compare them to the baseline
+func TestAllocs(t *testing.T) {
+	if !*profileAllocs {
+		t.Skip("pass -allocs to count the allocations")
+	}
+	profiles := profileAllocsFromItf(t)
+	opcodes := make([]string, 0, len(profiles))
+	for opcode := range profiles {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Strings(opcodes)
+	for _, opcode := range opcodes {
+		p := profiles[opcode]
+		t.Logf("%-26s %5d states %8.2f allocs/op (max %.0f)", opcode, p.States, p.Mean, p.Max)
+	}
+
+	if *allocsBaseline == "" {
+		return
+	}
+	if *updateAllocs {
+		data, err := json.MarshalIndent(profiles, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(*allocsBaseline, append(data, '\n'), 0644))
+		return
+	}
+	data, err := os.ReadFile(*allocsBaseline)
+	require.NoError(t, err)
+	var baseline map[string]allocProfile
+	require.NoError(t, json.Unmarshal(data, &baseline))
+	for _, opcode := range opcodes {
+		old, found := baseline[opcode]
+		if !found {
+			continue
+		}
+		if p := profiles[opcode]; p.Max > old.Max {
+			t.Errorf("%s allocates up to %.0f times per operation, the baseline is %.0f (%.2f vs %.2f on average)",
+				opcode, p.Max, old.Max, p.Mean, old.Mean)
+		}
+	}
+}
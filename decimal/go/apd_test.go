@@ -0,0 +1,314 @@
+// An independent oracle of the operations, computed with cockroachdb/apd, a
+// well-tested implementation of the General Decimal Arithmetic. Unlike the
+// oracle of oracle_test.go, which tells how far a result may be from the
+// exact value, the apd oracle computes the exact result of every opcode with
+// the rounding of rounding.json, which is configured explicitly in the context
+// of apd, see apdRounders:
+//
+//   - the operation is computed with apdPrecision digits, which is exact for
+//     add, sub, and mul, and a quotient is truncated and marked by a sticky
+//     digit, if it is inexact, so it is rounded as if it were exact,
+//   - the result is quantized to Precision digits after '.', or to an integer
+//     for ceil and roundInt, with the rounding of the opcode,
+//   - a result of add, sub, mul, or quo beyond MAX_DEC_BIT_LEN, a division by
+//     zero, a precision out of [0, PRECISION], and an argument beyond int64 or
+//     sdk.Int fail, whereas the constructors and ceil never overflow.
+//
+// TestApdOracle replays the traces in the trace directory, compares the
+// results of the spec and of sdk.Dec with the oracle, and prints a table of
+// the divergences by opcode:
+//
+//	go test -v -run TestApdOracle
+//
+// As apd is independent of both, a divergence of the spec from the oracle
+// shows a mistake of the spec, or a trace of an earlier spec. With
+// -apd-strict, it fails the test, e.g., in CI, once the corpus is consistent,
+// except for quo: sdk.Dec truncates the quotient to 36 digits before it rounds
+// it to 18 digits, and so does the spec, whereas the oracle rounds the exact
+// quotient once. A divergence of quo is reported as a double rounding.
+// A divergence of sdk.Dec from the oracle is only reported, as the other tests
+// compare sdk.Dec with the spec.
+
+package main
+
+import (
+	"flag"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/cockroachdb/apd/v3"
+	"github.com/stretchr/testify/require"
+)
+
+var apdStrict = flag.Bool("apd-strict", false, "fail, if the spec diverges from the apd oracle")
+
+// the digits of the operations of the oracle, which are enough for the
+// exact product of two decimals of MAX_DEC_BIT_LEN bits
+const apdPrecision = 400
+
+// the rounding modes of rounding.json in apd
+var apdRounders = map[string]apd.Rounder{
+	"exact":    apd.RoundHalfEven,
+	"halfEven": apd.RoundHalfEven,
+	"truncate": apd.RoundDown,
+	"ceiling":  apd.RoundCeiling,
+}
+
+// the operators whose results are bounded by MAX_DEC_BIT_LEN
+var bitLenBounded = map[string]bool{"add": true, "sub": true, "mul": true, "quo": true}
+
+// the opcodes whose results the spec rounds twice, as sdk.Dec does
+var doubleRounded = map[string]bool{"quo": true}
+
+// a context of apd with the rounding of rounding.json
+func apdContext(rounding string) *apd.Context {
+	rounder, found := apdRounders[rounding]
+	if !found {
+		panic("no rounding mode of apd for " + rounding)
+	}
+	return &apd.Context{
+		Precision:   apdPrecision,
+		MaxExponent: apd.MaxExponent,
+		MinExponent: apd.MinExponent,
+		Traps:       apd.DefaultTraps,
+		Rounding:    rounder,
+	}
+}
+
+// the outcome of an operation: a failure, or the value as the traces write it
+type apdOutcome struct {
+	failed bool
+	value  *big.Int
+}
+
+func (o apdOutcome) String() string {
+	if o.failed {
+		return "error"
+	}
+	return o.value.String()
+}
+
+func (o apdOutcome) equal(other apdOutcome) bool {
+	if o.failed || other.failed {
+		return o.failed == other.failed
+	}
+	return o.value.Cmp(other.value) == 0
+}
+
+// the apd decimal of i * 10^exp
+func bigintToApd(i *big.Int, exp int32) *apd.Decimal {
+	return apd.NewWithBigInt(new(apd.BigInt).SetMathBigInt(i), exp)
+}
+
+// the integer d * 10^-exp of a decimal d, which has no digits below 10^exp
+func apdToBigint(d *apd.Decimal, exp int32) *big.Int {
+	i := d.Coeff.MathBigInt()
+	if d.Negative {
+		i.Neg(i)
+	}
+	shift := big.NewInt(int64(d.Exponent - exp))
+	return i.Mul(i, new(big.Int).Exp(big.NewInt(10), shift, nil))
+}
+
+// the exact quotient, or the quotient truncated to apdPrecision digits and a
+// sticky digit after it, which is rounded as the exact quotient would be
+func apdQuo(t *testing.T, x, y *apd.Decimal) *apd.Decimal {
+	var q apd.Decimal
+	cond, err := apdContext("truncate").Quo(&q, x, y)
+	require.NoError(t, err)
+	if !cond.Inexact() {
+		return &q
+	}
+	sticky := apd.New(1, q.Exponent-1)
+	sticky.Negative = q.Negative
+	wider := apdContext("truncate")
+	wider.Precision = apdPrecision + 2
+	_, err = wider.Add(&q, &q, sticky)
+	require.NoError(t, err)
+	return &q
+}
+
+// compute the outcome of a state with apd
+func apdOracle(t *testing.T, s TestInput) apdOutcome {
+	places := int32(constants.Precision)
+	failure := apdOutcome{failed: true}
+	a, b := &s.arg1.value, &s.arg2.value
+	x, y := bigintToApd(a, -places), bigintToApd(b, -places)
+	maxSdkInt := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	// the exact result, which is rounded below
+	var z *apd.Decimal
+	r := rounding{Rounding: "exact", Scale: "ulp"}
+	switch s.opcode {
+	case "newDec", "newDecWithPrec":
+		if !a.IsInt64() {
+			return failure
+		}
+	case "newDecFromInt", "newDecFromIntWithPrec":
+		if new(big.Int).Abs(a).Cmp(maxSdkInt) >= 0 {
+			return failure
+		}
+	}
+	switch s.opcode {
+	case "newDec", "newDecFromInt", "newDecFromBigInt":
+		z = bigintToApd(a, 0)
+	case "newDecWithPrec", "newDecFromIntWithPrec", "newDecFromBigIntWithPrec":
+		if !b.IsInt64() || b.Sign() < 0 || b.Int64() > int64(places) {
+			return failure
+		}
+		z = bigintToApd(a, -int32(b.Int64()))
+	default:
+		var found bool
+		if r, found = roundings[s.opcode]; !found {
+			t.Fatalf("no rounding of %s in rounding.json", s.opcode)
+		}
+		z = new(apd.Decimal)
+		exact := apdContext("exact")
+		var err error
+		switch r.Operator {
+		case "add":
+			_, err = exact.Add(z, x, y)
+		case "sub":
+			_, err = exact.Sub(z, x, y)
+		case "mul":
+			_, err = exact.Mul(z, x, y)
+		case "quo":
+			if y.IsZero() {
+				return failure
+			}
+			z = apdQuo(t, x, y)
+		case "ceil", "roundInt":
+			z.Set(x)
+		default:
+			t.Fatalf("no operator of apd for %s", r.Operator)
+		}
+		require.NoError(t, err)
+	}
+
+	// round the exact result to the last place, or to an integer
+	exp := -places
+	if r.Scale == "integer" {
+		exp = 0
+	}
+	var rounded apd.Decimal
+	_, err := apdContext(r.Rounding).Quantize(&rounded, z, exp)
+	require.NoError(t, err)
+	if s.opcode == "roundInt" {
+		return apdOutcome{value: apdToBigint(&rounded, 0)}
+	}
+	value := apdToBigint(&rounded, -places)
+	if bitLenBounded[r.Operator] && value.BitLen() > constants.MaxDecBitLen {
+		return failure
+	}
+	return apdOutcome{value: value}
+}
+
+// the divergences of the spec and of sdk.Dec from the apd oracle by opcode
+type apdDivergence struct {
+	States  int
+	Agree   int
+	Spec    int
+	Double  int
+	SdkDec  int
+	Skipped int
+}
+
+// Compare the spec and sdk.Dec with the apd oracle on the traces in the
+// trace directory
+func TestApdOracle(t *testing.T) {
+	files, err := traceFiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no traces in %s", *traceDir)
+	divergences := make(map[string]*apdDivergence)
+	for _, filename := range files {
+		for i, s := range parseItf(filename) {
+			if !knownOpcode(s.opcode) {
+				continue
+			}
+			if divergences[s.opcode] == nil {
+				divergences[s.opcode] = &apdDivergence{}
+			}
+			d := divergences[s.opcode]
+			d.States++
+			if s.arg1.failed() || s.arg2.failed() || classifyArgs(s) == argsOutOfDomain {
+				d.Skipped++
+				continue
+			}
+			name := stepName(filename, i, s)
+			expected := apdOracle(t, s)
+			spec := apdOutcome{failed: s.result.failed(), value: &s.result.value}
+			value, ok := executeDecValue(s)
+			sdkDec := apdOutcome{failed: !ok, value: value}
+			switch {
+			case !spec.equal(expected) && doubleRounded[s.opcode]:
+				d.Double++
+				t.Logf("%s: the spec rounds twice to %s, apd rounds once to %s", name, spec, expected)
+			case !spec.equal(expected):
+				d.Spec++
+				report := t.Logf
+				if *apdStrict {
+					report = t.Errorf
+				}
+				report("%s: the spec computes %s, apd computes %s", name, spec, expected)
+			case !sdkDec.equal(expected):
+				d.SdkDec++
+				t.Logf("%s: sdk.Dec computes %s, apd computes %s", name, sdkDec, expected)
+			default:
+				d.Agree++
+			}
+		}
+	}
+
+	var opcodes []string
+	for opcode := range divergences {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Strings(opcodes)
+	t.Logf("%-26s %7s %7s %10s %15s %10s %8s", "opcode", "states", "agree",
+		"spec!=apd", "doubleRounding", "sdk!=apd", "skipped")
+	for _, opcode := range opcodes {
+		d := divergences[opcode]
+		t.Logf("%-26s %7d %7d %10d %15d %10d %8d", opcode,
+			d.States, d.Agree, d.Spec, d.Double, d.SdkDec, d.Skipped)
+	}
+}
+
+// The rounding of the oracle on a few states with known results
+func TestApdOracleRounding(t *testing.T) {
+	one := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(constants.Precision)), nil)
+	state := func(opcode string, a, b int64) TestInput {
+		s := TestInput{opcode: opcode}
+		s.arg1.value.SetInt64(a)
+		s.arg2.value.SetInt64(b)
+		return s
+	}
+	dec := func(i int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(i), one)
+	}
+	quo := func(opcode string, a, b int64) apdOutcome {
+		s := TestInput{opcode: opcode}
+		s.arg1.value.Set(dec(a))
+		s.arg2.value.Set(dec(b))
+		return apdOracle(t, s)
+	}
+	twoThirds := new(big.Int).Quo(dec(2), big.NewInt(3))
+	require.Equal(t, twoThirds.String(), quo("quoTruncate", 2, 3).String())
+	require.Equal(t, new(big.Int).Add(twoThirds, big.NewInt(1)).String(), quo("quo", 2, 3).String())
+	require.Equal(t, new(big.Int).Add(twoThirds, big.NewInt(1)).String(), quo("quoRoundup", 2, 3).String())
+	require.Equal(t, new(big.Int).Neg(twoThirds).String(), quo("quoRoundup", -2, 3).String())
+	require.True(t, quo("quo", 1, 0).failed)
+
+	// half to even at the integer
+	halves := TestInput{opcode: "roundInt"}
+	halves.arg1.value.Quo(dec(5), big.NewInt(2))
+	require.Equal(t, "2", apdOracle(t, halves).String())
+	halves.arg1.value.Neg(&halves.arg1.value)
+	require.Equal(t, "-2", apdOracle(t, halves).String())
+	halves.opcode = "ceil"
+	require.Equal(t, dec(-2).String(), apdOracle(t, halves).String())
+
+	require.Equal(t, "150000000000000000", apdOracle(t, state("newDecWithPrec", 15, 2)).String())
+	require.True(t, apdOracle(t, state("newDecWithPrec", 1, int64(constants.Precision)+1)).failed)
+	require.True(t, apdOracle(t, state("newDecWithPrec", 1, -1)).failed)
+}
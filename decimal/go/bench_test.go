@@ -0,0 +1,109 @@
+// Benchmarks of Dec on the traces. The states of the traces are replayed
+// purely for timing, that is, the results are not compared against the spec.
+// Every opcode is a sub-benchmark of its own, which cycles through all states
+// of that opcode in the trace directory, so the output is read by benchstat:
+//
+//	go test -run '^$' -bench BenchmarkOps -count 10 | tee new.txt
+//	benchstat old.txt new.txt
+//
+// The arguments are converted to Dec before the timer starts, and the states,
+// in which Dec is expected to panic, are skipped, as a panic is not a workload.
+
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+// an operation of Dec with its arguments, ready to be timed
+type benchCall func()
+
+// prepare the operation of a state, or return false, if it panics or
+// its arguments do not fit into Dec
+func prepareCall(s TestInput) (call benchCall, ok bool) {
+	if s.result.failed() {
+		return nil, false
+	}
+	switch classifyArgs(s) {
+	case argsOutOfDomain:
+		return nil, false
+	case argsViaBigInt:
+		s.opcode = viaBigInt[s.opcode]
+	}
+	arg1, err1 := NewDecFromStr(bigintToDecString(&s.arg1.value))
+	arg2, err2 := NewDecFromStr(bigintToDecString(&s.arg2.value))
+	if err1 != nil || err2 != nil {
+		return nil, false
+	}
+	x, prec := s.arg1.value.Int64(), s.arg2.value.Int64()
+	switch s.opcode {
+	case "newDec":
+		return func() { NewDec(x) }, true
+	case "newDecWithPrec":
+		return func() { NewDecWithPrec(x, prec) }, true
+	case "newDecFromInt":
+		i := NewIntFromBigInt(&s.arg1.value)
+		return func() { NewDecFromInt(i) }, true
+	case "newDecFromIntWithPrec":
+		i := NewIntFromBigInt(&s.arg1.value)
+		return func() { NewDecFromIntWithPrec(i, prec) }, true
+	case "newDecFromBigInt":
+		return func() { NewDecFromBigInt(&s.arg1.value) }, true
+	case "newDecFromBigIntWithPrec":
+		return func() { NewDecFromBigIntWithPrec(&s.arg1.value, prec) }, true
+	case "ceil":
+		return func() { arg1.Ceil() }, true
+	case "roundInt":
+		return func() { arg1.RoundInt() }, true
+	default:
+		op, found := binaryOps[s.opcode]
+		if !found {
+			return nil, false
+		}
+		return func() { op(arg1, arg2) }, true
+	}
+}
+
+// collect the operations of all traces in the trace directory by their opcodes
+func loadBenchCalls(b *testing.B) map[string][]benchCall {
+	files, err := traceFiles()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(files) == 0 {
+		b.Skipf("no traces in %s", *traceDir)
+	}
+	calls := make(map[string][]benchCall)
+	for _, filename := range files {
+		for _, s := range parseItf(filename) {
+			if call, ok := prepareCall(s); ok {
+				calls[s.opcode] = append(calls[s.opcode], call)
+			}
+		}
+	}
+	return calls
+}
+
+// Time every opcode on the states of the traces, e.g.:
+//
+//	BenchmarkOps/add-8         	41235870	        28.91 ns/op
+//	BenchmarkOps/quo-8         	 3404016	       352.6 ns/op
+func BenchmarkOps(b *testing.B) {
+	calls := loadBenchCalls(b)
+	opcodes := make([]string, 0, len(calls))
+	for opcode := range calls {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Strings(opcodes)
+	for _, opcode := range opcodes {
+		ops := calls[opcode]
+		b.Run(opcode, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ops[i%len(ops)]()
+			}
+			// the number of states, through which the opcode cycles
+			b.ReportMetric(float64(len(ops)), "states")
+		})
+	}
+}
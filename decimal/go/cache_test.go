@@ -0,0 +1,127 @@
+// A cache of the parsed traces. Several tests replay the same file, e.g.,
+// Test56ops, TestOracle, TestAlgebraicProperties, and TestMathDecDivergence all
+// replay random56.itf.json, and the benchmarks replay the whole directory. The
+// cache parses every file once per `go test`, and it keeps the most recently
+// used traces, so a large corpus does not stay in memory as a whole. A trace is
+// keyed by its path and the time of its last modification, so a file that is
+// regenerated while the tests run, e.g., by fuzz.sh, is parsed again.
+//
+// The cached states are shared by the tests, which must not modify them.
+
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the number of traces in the cache
+const traceCacheSize = 16
+
+// a trace in the cache
+type cachedTrace struct {
+	filename string
+	modTime  int64
+	states   []TestInput
+}
+
+// traceCache is a least recently used cache of the parsed traces
+type traceCache struct {
+	mu       sync.Mutex
+	capacity int
+	// the traces, the most recently used first
+	order *list.List
+	// the elements of order by the absolute paths of their files
+	byPath map[string]*list.Element
+	// the number of files that were parsed, for the tests
+	misses int
+}
+
+func newTraceCache(capacity int) *traceCache {
+	return &traceCache{
+		capacity: capacity,
+		order:    list.New(),
+		byPath:   make(map[string]*list.Element),
+	}
+}
+
+var traces = newTraceCache(traceCacheSize)
+
+// parseItf returns the states of a trace, which are parsed by readItf, unless
+// they are in the cache
+func parseItf(filename string) []TestInput {
+	return traces.get(filename, readItf)
+}
+
+// get returns the states of a trace from the cache, or it parses them
+func (c *traceCache) get(filename string, parse func(string) []TestInput) []TestInput {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		panic(fmt.Errorf("error opening file: %v", err))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		panic(fmt.Errorf("error opening file: %v", err))
+	}
+	modTime := info.ModTime().UnixNano()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, found := c.byPath[path]; found {
+		if trace := e.Value.(*cachedTrace); trace.modTime == modTime {
+			c.order.MoveToFront(e)
+			return trace.states
+		}
+		// the file was modified since it was parsed
+		c.order.Remove(e)
+		delete(c.byPath, path)
+	}
+	c.misses++
+	states := parse(filename)
+	c.byPath[path] = c.order.PushFront(&cachedTrace{filename: path, modTime: modTime, states: states})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.byPath, oldest.Value.(*cachedTrace).filename)
+	}
+	return states
+}
+
+func TestTraceCache(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, opcode string) string {
+		filename := filepath.Join(dir, name)
+		trace := fmt.Sprintf(`{ "states": [ { "opcode": %q,
+			"opArg1": { "error": false, "value": { "#bigint": "1" } },
+			"opArg2": { "error": false, "value": { "#bigint": "2" } },
+			"opResult": { "error": false, "value": { "#bigint": "3" } } } ] }`, opcode)
+		require.NoError(t, os.WriteFile(filename, []byte(trace), 0o644))
+		return filename
+	}
+	first, second := write("first.itf.json", "add"), write("second.itf.json", "sub")
+
+	c := newTraceCache(1)
+	assert.Equal(t, "add", c.get(first, readItf)[0].opcode)
+	assert.Equal(t, "add", c.get(first, readItf)[0].opcode)
+	assert.Equal(t, 1, c.misses, "a trace is parsed once")
+
+	// the file is regenerated
+	write("first.itf.json", "mul")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(first, later, later))
+	assert.Equal(t, "mul", c.get(first, readItf)[0].opcode)
+	assert.Equal(t, 2, c.misses, "a modified trace is parsed again")
+
+	// the second trace evicts the first one
+	assert.Equal(t, "sub", c.get(second, readItf)[0].opcode)
+	c.get(first, readItf)
+	assert.Equal(t, 4, c.misses, "an evicted trace is parsed again")
+	assert.Equal(t, 1, c.order.Len())
+}
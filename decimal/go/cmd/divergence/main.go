@@ -0,0 +1,107 @@
+// A tool that compares the outcomes of the decimal harness across several
+// releases of cosmos-sdk. It reads the reports produced by `go test -json`,
+// one report per release, and prints a markdown table of the test steps
+// whose outcome depends on the release. See crossversion.sh.
+//
+// Usage:
+//
+//	go run ./cmd/divergence reports/v0.45.16.json reports/v0.46.4.json ...
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// a single event in the output of `go test -json`
+type testEvent struct {
+	Action string
+	Test   string
+}
+
+// read the outcome (pass, fail, skip) of every test and subtest in a report
+func readReport(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	outcomes := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	// the output of huge subtests names may exceed the default buffer size
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// `go test` prints build errors as plain text
+			continue
+		}
+		switch event.Action {
+		case "pass", "fail", "skip":
+			if event.Test != "" {
+				outcomes[event.Test] = event.Action
+			}
+		}
+	}
+	return outcomes, scanner.Err()
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s report1.json report2.json...\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	var versions []string
+	var reports []map[string]string
+	names := make(map[string]bool)
+	for _, filename := range os.Args[1:] {
+		outcomes, err := readReport(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+		version := strings.TrimSuffix(filepath.Base(filename), ".json")
+		if len(outcomes) == 0 {
+			fmt.Fprintf(os.Stderr, "warning: no tests in %s, did the build fail?\n", filename)
+		}
+		versions = append(versions, version)
+		reports = append(reports, outcomes)
+		for name := range outcomes {
+			names[name] = true
+		}
+	}
+
+	// collect the tests whose outcomes are not the same in all reports
+	var diverging []string
+	for name := range names {
+		for _, report := range reports[1:] {
+			if report[name] != reports[0][name] {
+				diverging = append(diverging, name)
+				break
+			}
+		}
+	}
+	sort.Strings(diverging)
+
+	fmt.Printf("| test | %s |\n", strings.Join(versions, " | "))
+	fmt.Printf("|---%s|\n", strings.Repeat("|---", len(versions)))
+	for _, name := range diverging {
+		row := make([]string, len(reports))
+		for i, report := range reports {
+			row[i] = report[name]
+			if row[i] == "" {
+				row[i] = "missing"
+			}
+		}
+		fmt.Printf("| %s | %s |\n", name, strings.Join(row, " | "))
+	}
+	fmt.Printf("\n%d of %d tests diverge across %d releases\n",
+		len(diverging), len(names), len(versions))
+}
@@ -0,0 +1,154 @@
+// The constants of the decimal types, e.g., the number of digits after '.',
+// are configured in ../constants.json, which is the single source of truth
+// for the spec and the harness. The spec declares them as constants, and
+// constants.qnt, which is generated from the JSON file, instantiates the spec
+// for every decimal type:
+//
+//	go test -run TestConstantsQntInSync -args -update-constants
+//
+// The harness interprets the integers of the traces with the precision of the
+// decimal type of -dec-type, by default sdk, and TestConstantsMatchDec checks
+// that the Dec under test is of that type, e.g., when the harness is run
+// against another release of the SDK, or against the decimals of another chain.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the shared configuration and the Quint modules generated from it
+const (
+	constantsFile    = "../constants.json"
+	constantsQntFile = "../constants.qnt"
+)
+
+var (
+	updateConstants = flag.Bool("update-constants", false, "regenerate constants.qnt from constants.json")
+	decType         = flag.String("dec-type", "sdk", "the decimal type of constants.json, with which the spec produced the traces")
+)
+
+// the constants of a decimal type, as configured in constants.json
+type decConstants struct {
+	// the number of decimal places to the right of '.'
+	Precision int `json:"precision"`
+	// the maximum number of bits to represent a decimal, see chopping
+	MaxDecBitLen int `json:"maxDecBitLen"`
+}
+
+// the decimal types of constants.json by their names
+type decTypes struct {
+	Types map[string]decConstants `json:"types"`
+}
+
+// the constants that the spec used to produce the traces, see TestMain
+var constants decConstants
+
+// pick the constants of -dec-type, once the flags are parsed, or list the
+// traces with -itf-list
+func TestMain(m *testing.M) {
+	flag.Parse()
+	c, found := loadConstants(constantsFile).Types[*decType]
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown decimal type %q in %s\n", *decType, constantsFile)
+		os.Exit(2)
+	}
+	constants = c
+	if *listTraces {
+		if err := listTraceDir(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// read the constants of the decimal types from a JSON file
+func loadConstants(filename string) decTypes {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		panic(fmt.Errorf("error opening file: %v", err))
+	}
+	var types decTypes
+	if err := json.Unmarshal(data, &types); err != nil {
+		panic(fmt.Errorf("error parsing %s: %v", filename, err))
+	}
+	return types
+}
+
+// render the Quint modules that instantiate the spec for every decimal type
+func renderConstantsQnt(types decTypes) string {
+	names := make([]string, 0, len(types.Types))
+	for name := range types.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(`// -*- mode: Bluespec; -*-
+// Generated from constants.json. DO NOT EDIT. To regenerate:
+//
+//   cd go && go test -run TestConstantsQntInSync -args -update-constants
+//
+// Every module is the spec of a decimal type, which is run with --main, e.g.:
+//
+//   quint run --main=sdk constants.qnt
+`)
+	for _, name := range names {
+		c := types.Types[name]
+		consts := fmt.Sprintf("PRECISION = %d, MAX_DEC_BIT_LEN = %d", c.Precision, c.MaxDecBitLen)
+		fmt.Fprintf(&b, `
+// %d digits to the right of '.', in at most %d bits
+module %s {
+    import decimal(%s).* from "./decimal"
+    import decimalTest(%s).* from "./decimalTest"
+}
+`, c.Precision, c.MaxDecBitLen, name, consts, consts)
+	}
+	return b.String()
+}
+
+// constants.qnt must be generated from the current constants.json
+func TestConstantsQntInSync(t *testing.T) {
+	expected := renderConstantsQnt(loadConstants(constantsFile))
+	if *updateConstants {
+		require.NoError(t, os.WriteFile(constantsQntFile, []byte(expected), 0o644))
+	}
+	actual, err := os.ReadFile(constantsQntFile)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(actual),
+		"constants.qnt is out of sync with constants.json, regenerate it with -update-constants")
+}
+
+// whether Dec adds zero to a decimal of the given number of bits without a panic
+func decFitsBits(bits int) (fits bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			fits = false
+		}
+	}()
+	i := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	i.Sub(i, big.NewInt(1))
+	NewDecFromBigIntWithPrec(i, Precision).Add(NewDec(0))
+	return true
+}
+
+// the Dec under test must have the constants, with which the spec was run
+func TestConstantsMatchDec(t *testing.T) {
+	assert.EqualValues(t, Precision, constants.Precision,
+		"the precision of Dec differs from the decimal type %s of constants.json", *decType)
+	assert.True(t, decFitsBits(constants.MaxDecBitLen),
+		"Dec rejects a decimal of %d bits, which the spec accepts", constants.MaxDecBitLen)
+	assert.False(t, decFitsBits(constants.MaxDecBitLen+1),
+		"Dec accepts a decimal of %d bits, which the spec rejects", constants.MaxDecBitLen+1)
+}
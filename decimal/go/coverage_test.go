@@ -0,0 +1,123 @@
+// The coverage of the corpus as a whole. Every trace covers only some of the
+// operations, and only some of their outcomes, e.g., random56.itf.json never
+// divides by zero. TestCorpusCoverage aggregates the states of all traces in
+// the trace directory by opcode and by branch, whether the operation succeeds
+// or fails, and reports the operations of the harness that no trace covers:
+//
+//	go test -v -run TestCorpusCoverage
+//	go test -run TestCorpusCoverage -args -coverage-report=coverage.json
+//
+// With -require-coverage, an operation that no trace covers fails the test,
+// e.g., in CI, once the corpus covers all of them. A branch that no trace
+// covers, e.g., an operation that never fails, never fails the test, as the
+// spec may rule it out.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	coverageReport  = flag.String("coverage-report", "", "write the coverage of the corpus by opcode to this JSON file")
+	requireCoverage = flag.Bool("require-coverage", false, "fail, if an operation of the harness is not covered by the traces")
+)
+
+// the states of an opcode in the corpus by their branch
+type opCoverage struct {
+	Succeeds int `json:"succeeds"`
+	Fails    int `json:"fails"`
+	// the number of traces with the opcode
+	Traces int `json:"traces"`
+}
+
+// the opcodes that the harness executes, see knownOpcode
+func implementedOpcodes() []string {
+	var opcodes []string
+	for opcode := range integerArgs {
+		opcodes = append(opcodes, opcode)
+	}
+	for opcode := range singleArgOps {
+		if !integerArgs[opcode] {
+			opcodes = append(opcodes, opcode)
+		}
+	}
+	for opcode := range binaryOps {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Strings(opcodes)
+	return opcodes
+}
+
+// aggregate the states of the traces by opcode and branch
+func corpusCoverage(files []string) map[string]opCoverage {
+	coverage := make(map[string]opCoverage)
+	for _, filename := range files {
+		inTrace := make(map[string]bool)
+		for _, s := range parseItf(filename) {
+			c := coverage[s.opcode]
+			if s.result.failed() {
+				c.Fails++
+			} else {
+				c.Succeeds++
+			}
+			if !inTrace[s.opcode] {
+				inTrace[s.opcode] = true
+				c.Traces++
+			}
+			coverage[s.opcode] = c
+		}
+	}
+	return coverage
+}
+
+// Report the coverage of the traces in the trace directory, and, with
+// -require-coverage, check that every operation of the harness is covered
+func TestCorpusCoverage(t *testing.T) {
+	files, err := traceFiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no traces in %s", *traceDir)
+	coverage := corpusCoverage(files)
+
+	// the opcodes of the traces that the harness does not know come last
+	var unknown []string
+	for opcode := range coverage {
+		if !knownOpcode(opcode) {
+			unknown = append(unknown, opcode)
+		}
+	}
+	sort.Strings(unknown)
+	opcodes := append(implementedOpcodes(), unknown...)
+	t.Logf("%d traces in %s", len(files), *traceDir)
+	t.Logf("%-26s %9s %9s %7s", "opcode", "succeeds", "fails", "traces")
+	for _, opcode := range opcodes {
+		c := coverage[opcode]
+		var note string
+		switch {
+		case !knownOpcode(opcode):
+			note = "<- unknown opcode"
+		case c.Succeeds+c.Fails == 0:
+			note = "<- not covered"
+			if *requireCoverage {
+				t.Errorf("no trace covers %s", opcode)
+			}
+		case c.Fails == 0:
+			note = "<- never fails"
+		case c.Succeeds == 0:
+			note = "<- never succeeds"
+		}
+		t.Logf("%-26s %9d %9d %7d  %s", opcode, c.Succeeds, c.Fails, c.Traces, note)
+	}
+
+	if *coverageReport != "" {
+		data, err := json.MarshalIndent(coverage, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(*coverageReport, append(data, '\n'), 0644))
+	}
+}
@@ -0,0 +1,209 @@
+// The traces mark an operation that fails with { error: true }, whereas the
+// implementations of decimals signal a failure in different ways: sdk.Dec and
+// math.LegacyDec panic, e.g., on overflow, and math.Dec returns an error.
+// Every binding of the harness declares its failure mode, see decFailure in
+// sdk_test.go, and the harness checks that an operation fails in that mode iff
+// the spec expects it to fail. Hence, one corpus of traces drives all
+// implementations, and a failure in the other mode, e.g., a panic of math.Dec,
+// is reported as a bug of the implementation.
+//
+// The spec also tells why an operation fails, e.g., { error: "divisionByZero" },
+// and the harness checks that the implementation fails for the same reason,
+// which it tells by the message of the panic or the error.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// how an implementation signals that an operation fails
+type failureMode int
+
+const (
+	failsByPanic failureMode = iota
+	failsByError
+)
+
+func (m failureMode) String() string {
+	if m == failsByPanic {
+		return "a panic"
+	}
+	return "an error"
+}
+
+// The cause of a failure, as decimal.qnt writes it into the field error of a
+// decimal, see ERR_* there.
+type failureCause string
+
+const (
+	noFailure         failureCause = ""
+	overflowBitLen    failureCause = "overflowBitLen"
+	negativePrecision failureCause = "negativePrecision"
+	tooMuchPrecision  failureCause = "tooMuchPrecision"
+	divisionByZero    failureCause = "divisionByZero"
+	parseError        failureCause = "parseError"
+	// The traces that predate the causes write { error: true }, which any
+	// failure matches. causeOf returns it for a message it does not know.
+	unknownCause failureCause = "unknown"
+)
+
+// The causes of the failures by the fragments of their messages, in lower
+// case, which are matched in order, e.g., "index out of range [-1]" of a
+// negative precision before "out of range" of an overflow.
+var causeMessages = []struct {
+	fragment string
+	cause    failureCause
+}{
+	{"division by zero", divisionByZero},
+	{"division-by-zero", divisionByZero},
+	{"division by 0", divisionByZero},
+	{"too much precision", tooMuchPrecision},
+	{"index out of range [-", negativePrecision},
+	{"overflow", overflowBitLen},
+	{"out of bound", overflowBitLen},
+	{"out of range", overflowBitLen},
+	{"invalid decimal", parseError},
+	{"decimal string", parseError},
+}
+
+// the cause of a failure, which is told by its message
+func causeOf(failure error) failureCause {
+	msg := strings.ToLower(failure.Error())
+	for _, m := range causeMessages {
+		if strings.Contains(msg, m.fragment) {
+			return m.cause
+		}
+	}
+	return unknownCause
+}
+
+// Call an operation of an implementation, and return the failure in the
+// failure mode of the implementation, if the operation failed: either the
+// error, or the reason of the panic as an error. A failure in the other mode
+// fails the test: an operation that panics must not return errors, and vice
+// versa.
+func outcomeOf[T any](t require.TestingT, mode failureMode, op func() (T, error)) (result T, failure error) {
+	var err error
+	panicked, reason := func() (panicked bool, reason any) {
+		defer func() {
+			if reason = recover(); reason != nil {
+				panicked = true
+			}
+		}()
+		result, err = op()
+		return false, nil
+	}()
+	switch mode {
+	case failsByPanic:
+		require.NoError(t, err, "the operation returns an error, instead of a panic")
+		if panicked {
+			return result, fmt.Errorf("panic: %v", reason)
+		}
+		return result, nil
+	default:
+		require.False(t, panicked, "the operation panics, instead of returning an error: %v", reason)
+		return result, err
+	}
+}
+
+// Call an operation, which the spec expects to fail for a cause, or not to
+// fail, if the cause is noFailure, and check that it fails in the failure mode
+// of the implementation for that cause. It returns the result of the
+// operation, if there is one to compare with the spec.
+func expectOutcome[T any](t *testing.T, mode failureMode, expected failureCause, op func() (T, error)) (T, bool) {
+	result, failure := outcomeOf(t, mode, op)
+	if expected == noFailure {
+		require.NoError(t, failure, "the operation fails with %s, which the spec does not expect", mode)
+		return result, true
+	}
+	require.Error(t, failure, "the spec expects the operation to fail with %s: %s", mode, expected)
+	if expected != unknownCause {
+		require.Equal(t, expected, causeOf(failure), "the operation fails for another cause: %v", failure)
+	}
+	return result, false
+}
+
+// check an operation of Dec, whose result is a decimal, against the spec
+func checkDec(t *testing.T, expected TestDec, op func() Dec) {
+	actual, ok := expectOutcome(t, decFailure, expected.error, func() (Dec, error) { return op(), nil })
+	if ok {
+		assert.Equal(t, bigintToDec(t, &expected.value), actual, "the results should be equal")
+	}
+}
+
+func TestCauseOf(t *testing.T) {
+	for _, c := range []struct {
+		msg   string
+		cause failureCause
+	}{
+		// the panics of sdk.Dec and sdk.Int in v0.46.4
+		{"Int overflow", overflowBitLen},
+		{"NewIntFromBigInt() out of bound", overflowBitLen},
+		{"too much precision, maximum 18, provided 19", tooMuchPrecision},
+		{"runtime error: index out of range [-1]", negativePrecision},
+		{"division by zero", divisionByZero},
+		{"Division by zero", divisionByZero},
+		// the errors of math.Dec
+		{"invalid decimal string: 1.2.3", parseError},
+		{"exponent out of range", overflowBitLen},
+		{"something else", unknownCause},
+	} {
+		assert.Equal(t, c.cause, causeOf(errors.New(c.msg)), c.msg)
+	}
+}
+
+// The failure modes are checked both ways, with an operation that fails in
+// either mode, or does not fail.
+func TestFailureModes(t *testing.T) {
+	panics := func() (int, error) { panic("overflow") }
+	errs := func() (int, error) { return 0, errors.New("overflow") }
+	succeeds := func() (int, error) { return 1, nil }
+	for _, c := range []struct {
+		mode       failureMode
+		op         func() (int, error)
+		fails, bug bool
+	}{
+		{failsByPanic, panics, true, false},
+		{failsByPanic, errs, false, true},
+		{failsByPanic, succeeds, false, false},
+		{failsByError, errs, true, false},
+		{failsByError, panics, false, true},
+		{failsByError, succeeds, false, false},
+	} {
+		// a bug of the implementation fails the test, which is recorded
+		inner := &recordingT{}
+		var failure error
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, failure = outcomeOf(inner, c.mode, c.op)
+		}()
+		<-done
+		assert.Equal(t, c.bug, inner.failed, "%s: a bug", c.mode)
+		if !c.bug {
+			assert.Equal(t, c.fails, failure != nil, "%s: the outcome", c.mode)
+		}
+	}
+}
+
+// a test, whose failures are recorded, instead of failing the outer test
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Errorf(string, ...any) {
+	r.failed = true
+}
+
+func (r *recordingT) FailNow() {
+	r.failed = true
+	runtime.Goexit()
+}
@@ -0,0 +1,18 @@
+// The harness module with the apd-based math.Dec of cosmossdk.io/math v1.5.2,
+// which cosmos-sdk v0.46.4 does not ship with, see mathdec_test.go.
+// The indirect dependencies are resolved by: go mod tidy -modfile=go.mathdec.mod
+module github.com/informalsystems/quint-sandbox/decimal
+
+go 1.23.0
+
+require (
+	cosmossdk.io/math v1.5.2
+	github.com/cockroachdb/apd/v3 v3.2.1
+	github.com/cosmos/cosmos-sdk v0.46.4
+	github.com/informalsystems/quint-sandbox/itf v0.0.0
+	github.com/json-iterator/go v1.1.12
+	github.com/shopspring/decimal v1.3.1
+	github.com/stretchr/testify v1.10.0
+)
+
+replace github.com/informalsystems/quint-sandbox/itf => ../../itf
@@ -0,0 +1,19 @@
+// The harness module pinned to cosmos-sdk v0.45.16, see ../crossversion.sh.
+// The indirect dependencies are resolved by: go mod tidy -modfile=go.v0.45.mod
+module github.com/informalsystems/quint-sandbox/decimal
+
+go 1.23.0
+
+require (
+	github.com/cockroachdb/apd/v3 v3.2.1
+	github.com/cosmos/cosmos-sdk v0.45.16
+	github.com/informalsystems/quint-sandbox/itf v0.0.0
+	github.com/json-iterator/go v1.1.12
+	github.com/shopspring/decimal v1.3.1
+	github.com/stretchr/testify v1.10.0
+)
+
+replace (
+	github.com/gogo/protobuf => github.com/regen-network/protobuf v1.3.3-alpha.regen.1
+	github.com/informalsystems/quint-sandbox/itf => ../../itf
+)
@@ -0,0 +1,19 @@
+// The harness module pinned to cosmos-sdk v0.47.17, see ../crossversion.sh.
+// The indirect dependencies are resolved by: go mod tidy -modfile=go.v0.47.mod
+module github.com/informalsystems/quint-sandbox/decimal
+
+go 1.23.0
+
+require (
+	github.com/cockroachdb/apd/v3 v3.2.1
+	github.com/cosmos/cosmos-sdk v0.47.17
+	github.com/informalsystems/quint-sandbox/itf v0.0.0
+	github.com/json-iterator/go v1.1.12
+	github.com/shopspring/decimal v1.3.1
+	github.com/stretchr/testify v1.10.0
+)
+
+replace (
+	github.com/syndtr/goleveldb => github.com/syndtr/goleveldb v1.0.1-0.20210819022825-2ae1ddf74ef7
+	github.com/informalsystems/quint-sandbox/itf => ../../itf
+)
@@ -0,0 +1,161 @@
+//go:build mathdec
+
+// A test harness that replays the decimal traces against the new math.Dec
+// of cosmossdk.io/math, which is based on apd (General Decimal Arithmetic).
+//
+// Unlike sdk.Dec, the new Dec does not panic, but returns errors. Moreover, it
+// operates on 34 significant digits instead of 18 digits after '.', and it does
+// not know about MAX_DEC_BIT_LEN. Hence, the spec of v0.46.4 is not expected
+// to describe the new Dec precisely. Instead of failing on the first mismatch,
+// this harness classifies every step and reports where the two semantics diverge.
+// The harness only fails when the new Dec panics, which it should never do.
+//
+// The apd-based Dec was only shipped in cosmossdk.io/math v1.5.0-v1.5.2,
+// which are retracted now. As cosmos-sdk v0.46.4 requires v1.0.0-beta.3, the
+// harness pins v1.5.2 in the modfile go.mathdec.mod and is only built with the
+// tag mathdec:
+//
+//	go mod tidy -modfile=go.mathdec.mod
+//	go test -modfile=go.mathdec.mod -tags=mathdec -v -run TestMathDecDivergence
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/stretchr/testify/require"
+)
+
+// how the outcome of math.Dec relates to the expectation of the spec
+type divergence int
+
+const (
+	// both the spec and math.Dec produce the same value, or both fail
+	agree divergence = iota
+	// both produce a value, but the values are different
+	valueDiffers
+	// the spec expects a failure, whereas math.Dec produces a value
+	specErrorOnly
+	// math.Dec returns an error, whereas the spec expects a value
+	decErrorOnly
+	// math.Dec has no counterpart of the operation
+	unsupported
+)
+
+func (d divergence) String() string {
+	switch d {
+	case agree:
+		return "agree"
+	case valueDiffers:
+		return "valueDiffers"
+	case specErrorOnly:
+		return "specErrorOnly"
+	case decErrorOnly:
+		return "decErrorOnly"
+	default:
+		return "unsupported"
+	}
+}
+
+// construct a math.Dec out of the pure integer representation of a decimal
+func bigintToMathDec(t *testing.T, i *big.Int) sdkmath.Dec {
+	d, err := sdkmath.NewDecFromString(bigintToDecString(i))
+	require.NoError(t, err)
+	return d
+}
+
+// construct a math.Dec out of i * 10^(-prec), which may fail
+func bigintWithPrecToMathDec(i *big.Int, prec *big.Int) (sdkmath.Dec, error) {
+	return sdkmath.NewDecFromString(fmt.Sprintf("%se%s", i.String(), new(big.Int).Neg(prec).String()))
+}
+
+// compare the outcome of a math.Dec operation with the expected result
+func classify(t *testing.T, expected TestDec, actual sdkmath.Dec, err error) divergence {
+	switch {
+	case expected.failed() && err != nil:
+		return agree
+	case expected.failed():
+		return specErrorOnly
+	case err != nil:
+		t.Logf("math.Dec error: %v", err)
+		return decErrorOnly
+	case actual.Cmp(bigintToMathDec(t, &expected.value)) == 0:
+		return agree
+	default:
+		t.Logf("expected %s, found %s", bigintToDecString(&expected.value), actual.String())
+		return valueDiffers
+	}
+}
+
+// connect the test inputs to math.Dec, reporting how the result diverges from the spec
+func executeMathDecTest(t *testing.T, s TestInput) divergence {
+	var actual sdkmath.Dec
+	var err error
+	// math.Dec promises to return errors, so any panic is a bug
+	run := func(f func() (sdkmath.Dec, error)) {
+		actual, err = outcomeOf(t, failsByError, f)
+	}
+
+	switch s.opcode {
+	case "newDec", "newDecFromInt", "newDecFromBigInt":
+		// there are no 64-bit or 256-bit bounds on the arguments any longer
+		run(func() (sdkmath.Dec, error) { return sdkmath.NewDecFromString(s.arg1.value.String()) })
+
+	case "newDecWithPrec", "newDecFromIntWithPrec", "newDecFromBigIntWithPrec":
+		run(func() (sdkmath.Dec, error) { return bigintWithPrecToMathDec(&s.arg1.value, &s.arg2.value) })
+
+	case "add", "sub", "mul", "quo":
+		arg1 := bigintToMathDec(t, &s.arg1.value)
+		arg2 := bigintToMathDec(t, &s.arg2.value)
+		ops := map[string]func(sdkmath.Dec, sdkmath.Dec) (sdkmath.Dec, error){
+			"add": sdkmath.Dec.Add,
+			"sub": sdkmath.Dec.Sub,
+			"mul": sdkmath.Dec.Mul,
+			"quo": sdkmath.Dec.Quo,
+		}
+		run(func() (sdkmath.Dec, error) { return ops[s.opcode](arg1, arg2) })
+
+	default:
+		// mulTruncate, quoTruncate, quoRoundup, ceil, and roundInt are gone
+		return unsupported
+	}
+
+	return classify(t, s.result, actual, err)
+}
+
+// replay a trace against math.Dec and report the divergences from the spec
+func ExecMathDecFromItf(t *testing.T, filename string) map[divergence]int {
+	var counts = make(map[divergence]int)
+	var states = parseItf(filename)
+	for i, s := range states {
+		t.Run(stepName(filename, i, s), func(t *testing.T) {
+			d := executeMathDecTest(t, s)
+			counts[d]++
+			if d != agree {
+				t.Logf("%s: %s", s.opcode, d)
+			}
+		})
+	}
+
+	return counts
+}
+
+// Replay all committed traces against math.Dec and print the summary, e.g.:
+//
+//	go test -modfile=go.mathdec.mod -tags=mathdec -v -run TestMathDecDivergence
+func TestMathDecDivergence(t *testing.T) {
+	for _, filename := range []string{
+		tracePath("oneRandom.itf.json"),
+		tracePath("random56.itf.json"),
+		tracePath("addErrorOnBitlen.itf.json"),
+		tracePath("mulErrorOnBitlen.itf.json"),
+	} {
+		counts := ExecMathDecFromItf(t, filename)
+		t.Logf("%s: %d agree, %d valueDiffers, %d specErrorOnly, %d decErrorOnly, %d unsupported",
+			filename, counts[agree], counts[valueDiffers], counts[specErrorOnly],
+			counts[decErrorOnly], counts[unsupported])
+	}
+}
@@ -0,0 +1,195 @@
+// An oracle that computes the decimal operations with big.Float
+// at 512 bits of precision, that is, over 150 decimal digits.
+//
+// In contrast to the trace replay, this oracle does not care about the spec.
+// It only uses the arguments from the traces, and checks that Dec deviates
+// from the (almost) exact result by no more than the rounding mode of the
+// operation permits, as documented in the code of cosmos-sdk.
+
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// the number of bits in the mantissa of the oracle, which is over 150 digits
+const oraclePrecision = 512
+
+// the precision of the spec as a big.Float, e.g., 10^-18 for 18 digits
+func unitOfLastPlace() *big.Float {
+	return new(big.Float).SetPrec(oraclePrecision).Quo(
+		big.NewFloat(1).SetPrec(oraclePrecision),
+		new(big.Float).SetPrec(oraclePrecision).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(constants.Precision)), nil)))
+}
+
+// The oracle itself is not exact, as 10^-18 has no finite binary representation.
+// Hence, we tolerate a distance that is negligible in units of the last place.
+const oracleSlack = 1e-50
+
+// The rounding model of Dec: how far the result of an operation may be from
+// the exact value, in units of the last place. The constructors are exact,
+// the bounds of the other operations follow from their rounding in rounding.json.
+var roundingModel = withRoundingBounds(map[string]float64{
+	"newDec":                   0,
+	"newDecWithPrec":           0,
+	"newDecFromInt":            0,
+	"newDecFromIntWithPrec":    0,
+	"newDecFromBigInt":         0,
+	"newDecFromBigIntWithPrec": 0,
+})
+
+// convert the pure integer representation of a decimal to a float
+func bigintToFloat(i *big.Int) *big.Float {
+	f := new(big.Float).SetPrec(oraclePrecision).SetInt(i)
+	return f.Mul(f, unitOfLastPlace())
+}
+
+// compute the (almost) exact result of an operation, or nil, if it is undefined
+func oracle(s TestInput) *big.Float {
+	x := bigintToFloat(&s.arg1.value)
+	y := bigintToFloat(&s.arg2.value)
+	z := new(big.Float).SetPrec(oraclePrecision)
+	switch s.opcode {
+	case "newDec", "newDecFromInt", "newDecFromBigInt":
+		return z.SetInt(&s.arg1.value)
+
+	case "newDecWithPrec", "newDecFromIntWithPrec", "newDecFromBigIntWithPrec":
+		if !s.arg2.value.IsInt64() || s.arg2.value.Int64() < 0 || s.arg2.value.Int64() > int64(constants.Precision) {
+			return nil
+		}
+		// x * 10^(18 - prec)
+		z.SetInt(&s.arg1.value)
+		for i := int64(0); i < s.arg2.value.Int64(); i++ {
+			z.Quo(z, big.NewFloat(10))
+		}
+		return z
+
+	case "add":
+		return z.Add(x, y)
+
+	case "sub":
+		return z.Sub(x, y)
+
+	case "mul", "mulTruncate":
+		return z.Mul(x, y)
+
+	case "quo", "quoTruncate", "quoRoundup":
+		if y.Sign() == 0 {
+			return nil
+		}
+		return z.Quo(x, y)
+
+	case "ceil", "roundInt":
+		return z.Set(x)
+
+	default:
+		return nil
+	}
+}
+
+// execute an operation of Dec, which may panic
+func executeDec(s TestInput) (result *big.Float, ok bool) {
+	value, ok := executeDecValue(s)
+	switch {
+	case !ok:
+		return nil, false
+	case s.opcode == "roundInt":
+		return new(big.Float).SetPrec(oraclePrecision).SetInt(value), true
+	default:
+		return bigintToFloat(value), true
+	}
+}
+
+// execute an operation of Dec, and return its value as the traces write it,
+// that is, 10^Precision times a decimal, or the integer of roundInt
+func executeDecValue(s TestInput) (value *big.Int, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	arg1, err1 := NewDecFromStr(bigintToDecString(&s.arg1.value))
+	arg2, err2 := NewDecFromStr(bigintToDecString(&s.arg2.value))
+	if err1 != nil || err2 != nil {
+		// the arguments do not fit into Dec
+		return nil, false
+	}
+	switch classifyArgs(s) {
+	case argsOutOfDomain:
+		return nil, false
+	case argsViaBigInt:
+		s.opcode = viaBigInt[s.opcode]
+	}
+	switch s.opcode {
+	case "newDec":
+		return NewDec(s.arg1.value.Int64()).BigInt(), true
+	case "newDecWithPrec":
+		return NewDecWithPrec(s.arg1.value.Int64(), s.arg2.value.Int64()).BigInt(), true
+	case "newDecFromInt":
+		return NewDecFromInt(NewIntFromBigInt(&s.arg1.value)).BigInt(), true
+	case "newDecFromIntWithPrec":
+		return NewDecFromIntWithPrec(NewIntFromBigInt(&s.arg1.value), s.arg2.value.Int64()).BigInt(), true
+	case "newDecFromBigInt":
+		return NewDecFromBigInt(&s.arg1.value).BigInt(), true
+	case "newDecFromBigIntWithPrec":
+		return NewDecFromBigIntWithPrec(&s.arg1.value, s.arg2.value.Int64()).BigInt(), true
+	case "ceil":
+		return arg1.Ceil().BigInt(), true
+	case "roundInt":
+		return arg1.RoundInt().BigInt(), true
+	default:
+		op, found := binaryOps[s.opcode]
+		if !found {
+			return nil, false
+		}
+		return op(arg1, arg2).BigInt(), true
+	}
+}
+
+// the distance of a result from the oracle in units of the last place, and
+// whether it is within the bound of the rounding model
+func withinBound(actual, expected *big.Float, bound float64) (*big.Float, bool) {
+	dist := new(big.Float).SetPrec(oraclePrecision).Sub(actual, expected)
+	dist.Abs(dist).Quo(dist, unitOfLastPlace())
+	limit := new(big.Float).SetPrec(oraclePrecision).SetFloat64(bound)
+	return dist, dist.Cmp(limit.Add(limit, big.NewFloat(oracleSlack))) <= 0
+}
+
+// Check every step of a trace against the oracle. Return the number of steps
+// that were compared, as Dec may panic or the result may be undefined.
+func CheckOracleFromItf(t *testing.T, filename string) int {
+	var compared = 0
+	var states = parseItf(filename)
+	for i, s := range states {
+		bound, known := roundingModel[s.opcode]
+		expected := oracle(s)
+		if !known || expected == nil {
+			continue
+		}
+		actual, ok := executeDec(s)
+		if !ok {
+			// Dec panics, e.g., on overflow, which is not the concern of the oracle
+			continue
+		}
+		compared++
+		if dist, ok := withinBound(actual, expected, bound); !ok {
+			t.Errorf("%s, state %d: %s(%s, %s) = %s is %s units away from %s",
+				filename, i, s.opcode, s.arg1.value.String(), s.arg2.value.String(),
+				actual.Text('f', Precision), dist.Text('g', 10), expected.Text('f', 2*Precision))
+		}
+	}
+	return compared
+}
+
+// Check all traces in the trace directory against the oracle
+func TestOracle(t *testing.T) {
+	files, err := traceFiles()
+	require.NoError(t, err)
+	for _, filename := range files {
+		compared := CheckOracleFromItf(t, filename)
+		t.Logf("%s: compared %d steps against the oracle", filename, compared)
+	}
+}
@@ -0,0 +1,161 @@
+// Fuzzing around the states of the traces. Quint finds interesting states,
+// e.g., an addition right below the maximal decimal, but a trace has only one
+// of them, whereas the bugs hide next to it. With -perturb, the harness
+// perturbs the arguments of every binary operation of the traces:
+//
+//   - it flips the sign of either argument,
+//   - it adds and subtracts one unit of the last place to either argument,
+//   - it moves an argument of MAX_DEC_BIT_LEN bits to the maximal decimal,
+//   - it swaps the arguments.
+//
+// The spec did not produce the perturbed states, so there is no expected
+// result in the traces. Instead, the oracle computes it, and it also tells
+// whether the operation must fail: on a division by zero, or if the result
+// does not fit into MAX_DEC_BIT_LEN bits. A result so close to the maximal
+// decimal that it fits or not by the rounding is not checked, and neither is
+// a perturbed argument that does not fit into a decimal:
+//
+//	go test -v -run TestPerturbedStates -args -perturb
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var perturb = flag.Bool("perturb", false, "check the perturbed states of the traces against the oracle")
+
+// a perturbed state, and how it was perturbed, e.g., -arg1
+type perturbation struct {
+	name  string
+	state TestInput
+}
+
+// the perturbations of the arguments of a binary operation
+func perturbations(s TestInput) []perturbation {
+	one := big.NewInt(1)
+	maxDec := new(big.Int).Lsh(one, uint(constants.MaxDecBitLen))
+	maxDec.Sub(maxDec, one)
+	args := func(name string, a, b *big.Int) perturbation {
+		p := perturbation{name: name, state: TestInput{opcode: s.opcode}}
+		p.state.arg1.value.Set(a)
+		p.state.arg2.value.Set(b)
+		return p
+	}
+	a, b := &s.arg1.value, &s.arg2.value
+	perturbed := []perturbation{
+		args("-arg1", new(big.Int).Neg(a), b),
+		args("-arg2", a, new(big.Int).Neg(b)),
+		args("arg1+1", new(big.Int).Add(a, one), b),
+		args("arg1-1", new(big.Int).Sub(a, one), b),
+		args("arg2+1", a, new(big.Int).Add(b, one)),
+		args("arg2-1", a, new(big.Int).Sub(b, one)),
+		args("swapped", b, a),
+	}
+	// the maximal decimal of the sign of an argument
+	toMax := func(x *big.Int) *big.Int {
+		if x.Sign() < 0 {
+			return new(big.Int).Neg(maxDec)
+		}
+		return maxDec
+	}
+	if a.BitLen() == constants.MaxDecBitLen {
+		perturbed = append(perturbed, args("arg1=max", toMax(a), b))
+	}
+	if b.BitLen() == constants.MaxDecBitLen {
+		perturbed = append(perturbed, args("arg2=max", a, toMax(b)))
+	}
+	return perturbed
+}
+
+// the expected outcome of a perturbed state
+type perturbedOutcome int
+
+const (
+	// the operation succeeds with the result of the oracle
+	expectResult perturbedOutcome = iota
+	// the operation fails
+	expectFailure
+	// an argument does not fit, or the result is at the edge of the maximal decimal
+	notChecked
+)
+
+// the outcome of a perturbed state, which the oracle expects
+func expectedOutcome(s TestInput, expected *big.Float, bound float64) perturbedOutcome {
+	if s.arg1.value.BitLen() > constants.MaxDecBitLen || s.arg2.value.BitLen() > constants.MaxDecBitLen {
+		return notChecked
+	}
+	if expected == nil {
+		return expectFailure
+	}
+	// the exact result and its bound in units of the last place
+	units := new(big.Float).Quo(expected, unitOfLastPlace())
+	units.Abs(units)
+	maxDec := new(big.Float).SetPrec(oraclePrecision).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(constants.MaxDecBitLen)))
+	slack := new(big.Float).SetPrec(oraclePrecision).SetFloat64(bound + 1)
+	below := new(big.Float).Sub(maxDec, slack)
+	above := new(big.Float).Add(maxDec, slack)
+	switch {
+	case units.Cmp(below) < 0:
+		return expectResult
+	case units.Cmp(above) >= 0:
+		return expectFailure
+	default:
+		return notChecked
+	}
+}
+
+// Check the perturbed states of a trace against the oracle, and return the
+// number of the perturbed states that were checked
+func CheckPerturbedFromItf(t *testing.T, filename string) int {
+	checked := 0
+	for i, s := range parseItf(filename) {
+		if _, isBinary := binaryOps[s.opcode]; !isBinary || s.arg1.failed() || s.arg2.failed() {
+			continue
+		}
+		for _, perturbed := range perturbations(s) {
+			p := perturbed.state
+			bound := roundingModel[p.opcode]
+			expected := oracle(p)
+			outcome := expectedOutcome(p, expected, bound)
+			if outcome == notChecked {
+				continue
+			}
+			checked++
+			actual, ok := executeDec(p)
+			where := fmt.Sprintf("%s, state %d, %s: %s(%s, %s)", filepath.Base(filename), i, perturbed.name,
+				p.opcode, bigintToDecString(&p.arg1.value), bigintToDecString(&p.arg2.value))
+			switch {
+			case outcome == expectFailure && ok:
+				t.Errorf("%s = %s, where the oracle expects a failure", where, actual.Text('f', Precision))
+			case outcome == expectResult && !ok:
+				t.Errorf("%s fails, where the oracle expects %s", where, expected.Text('f', Precision))
+			case outcome == expectResult:
+				if dist, ok := withinBound(actual, expected, bound); !ok {
+					t.Errorf("%s = %s is %s units away from %s", where,
+						actual.Text('f', Precision), dist.Text('g', 10), expected.Text('f', 2*Precision))
+				}
+			}
+		}
+	}
+	return checked
+}
+
+// Check the perturbed states of all traces in the trace directory
+func TestPerturbedStates(t *testing.T) {
+	if !*perturb {
+		t.Skip("pass -perturb to check the perturbed states")
+	}
+	files, err := traceFiles()
+	require.NoError(t, err)
+	for _, filename := range files {
+		checked := CheckPerturbedFromItf(t, filename)
+		t.Logf("%s: checked %d perturbed states against the oracle", filename, checked)
+	}
+}
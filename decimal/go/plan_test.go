@@ -0,0 +1,134 @@
+// A dry run of the harness. With -dry-run, the traces are parsed as usual, but
+// instead of executing the operations against Dec, the harness prints the plan
+// of every trace: the opcode, the arguments, and the expected outcome of every
+// state, in decimal notation. This is useful to review a freshly generated
+// corpus before committing it:
+//
+//	go test -v -run Test56ops -args -dry-run -itf-dir=/tmp/my-traces
+//
+// The opcodes that the harness does not know are marked, as they would fail
+// the replay.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+var dryRun = flag.Bool("dry-run", false, "print the operations of the traces instead of executing them")
+
+// the constructors, whose first argument is an integer, not a decimal
+var integerArgs = map[string]bool{
+	"newDec":                   true,
+	"newDecWithPrec":           true,
+	"newDecFromInt":            true,
+	"newDecFromIntWithPrec":    true,
+	"newDecFromBigInt":         true,
+	"newDecFromBigIntWithPrec": true,
+}
+
+// the constructors, whose second argument is a precision
+var precisionArgs = map[string]bool{
+	"newDecWithPrec":           true,
+	"newDecFromIntWithPrec":    true,
+	"newDecFromBigIntWithPrec": true,
+}
+
+// the operations that have no second argument
+var singleArgOps = map[string]bool{
+	"newDec":           true,
+	"newDecFromInt":    true,
+	"newDecFromBigInt": true,
+	"ceil":             true,
+	"roundInt":         true,
+}
+
+// whether the harness knows how to execute an opcode
+func knownOpcode(opcode string) bool {
+	_, binary := binaryOps[opcode]
+	return integerArgs[opcode] || singleArgOps[opcode] || binary
+}
+
+// print an argument of an operation in the notation of its type
+func showArg(d TestDec, integer bool) string {
+	if d.failed() {
+		return "malformed"
+	}
+	if integer {
+		return d.value.String()
+	}
+	return bigintToDecString(&d.value)
+}
+
+// the plan of a state, e.g., quo(1.500000000000000000, 0.000000000000000000) panics: divisionByZero
+func planOf(s TestInput) string {
+	args := []string{showArg(s.arg1, integerArgs[s.opcode])}
+	if !singleArgOps[s.opcode] {
+		args = append(args, showArg(s.arg2, precisionArgs[s.opcode]))
+	}
+	var outcome string
+	switch s.result.error {
+	case noFailure:
+		outcome = "= " + showArg(s.result, s.opcode == "roundInt")
+	case unknownCause:
+		outcome = "panics"
+	default:
+		outcome = "panics: " + string(s.result.error)
+	}
+	plan := fmt.Sprintf("%s(%s) %s", s.opcode, strings.Join(args, ", "), outcome)
+	if !knownOpcode(s.opcode) {
+		plan += "   <- unknown opcode"
+	}
+	return plan
+}
+
+// print the plan of a trace, and count the states by their expected outcome
+func logPlan(t *testing.T, filename string, states []TestInput) {
+	panics, unknown := 0, 0
+	for i, s := range states {
+		if !shard.HasState(i) || !inRange(i) {
+			continue
+		}
+		t.Logf("%5d  %s", i, planOf(s))
+		if s.result.failed() {
+			panics++
+		}
+		if !knownOpcode(s.opcode) {
+			unknown++
+		}
+	}
+	t.Logf("%s: %d operations, %d expected to panic, %d unknown opcodes",
+		filepath.Base(filename), len(states), panics, unknown)
+}
+
+// With -itf-list, the harness lists the opcodes of every trace in the trace
+// directory, and the causes of their expected failures, instead of running the
+// tests. This is terser than -dry-run, which shows the arguments and results:
+//
+//	go test -v -args -itf-list -itf-dir=/tmp/my-traces
+var listTraces = flag.Bool("itf-list", false, "list the opcodes of the traces instead of running the tests")
+
+// List the opcodes of the traces in the trace directory with itf.List
+func listTraceDir(w io.Writer) error {
+	files, err := traceFiles()
+	if err != nil {
+		return err
+	}
+	for _, filename := range files {
+		trace, err := itf.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if err := itf.List(w, filepath.Base(filename), trace, "opcode", "opResult.error"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Measuring how much precision is lost over multi-step traces.
+//
+// We replay the traces produced with --step=stepChain and compute the exact
+// rational result next to Dec. The report shows the maximal distance between
+// the two, in units of the last place (10^-18). The same trace is also replayed
+// with every division replaced by Quo, QuoTruncate, and QuoRoundUp, which
+// gives us evidence on how the choice of division affects the accumulated error:
+//
+//	go test -v -run TestPrecisionLoss
+
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+// 10^PRECISION of the spec as a rational, that is, the number of units in 1.0
+func unitsPerOne() *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(constants.Precision)), nil))
+}
+
+// the exact counterparts of the binary operators of Dec
+var exactOps = map[string]func(z, x, y *big.Rat) *big.Rat{
+	"add":         (*big.Rat).Add,
+	"sub":         (*big.Rat).Sub,
+	"mul":         (*big.Rat).Mul,
+	"mulTruncate": (*big.Rat).Mul,
+	"quo":         (*big.Rat).Quo,
+	"quoTruncate": (*big.Rat).Quo,
+	"quoRoundup":  (*big.Rat).Quo,
+}
+
+// the opcodes of division, which can be replaced with one another
+var divisions = map[string]bool{"quo": true, "quoTruncate": true, "quoRoundup": true}
+
+// convert the pure integer representation of a decimal to a rational
+func bigintToRat(i *big.Int) *big.Rat {
+	r := new(big.Rat).SetInt(i)
+	return r.Quo(r, unitsPerOne())
+}
+
+// the distance between a decimal and a rational in units of the last place
+func distanceInUnits(d Dec, r *big.Rat) *big.Rat {
+	diff := new(big.Rat).Sub(bigintToRat(d.BigInt()), r)
+	diff.Abs(diff)
+	return diff.Mul(diff, unitsPerOne())
+}
+
+// the accumulated error of a replayed trace
+type precisionLoss struct {
+	// the maximal error in units of the last place
+	maxError *big.Rat
+	// the step where the maximal error was observed
+	maxStep int
+	// the number of steps replayed, until the end or an error
+	steps int
+}
+
+// Replay a chained trace against Dec and the exact rationals. When division
+// is not empty, replace all divisions in the trace with this opcode.
+func measurePrecisionLoss(t *testing.T, states []TestInput, division string) precisionLoss {
+	loss := precisionLoss{maxError: new(big.Rat)}
+	if len(states) == 0 || states[0].result.failed() {
+		return loss
+	}
+	acc := bigintToDec(t, &states[0].result.value)
+	exact := bigintToRat(&states[0].result.value)
+	for i, s := range states[1:] {
+		opcode := s.opcode
+		if division != "" && divisions[opcode] {
+			opcode = division
+		}
+		op, found := binaryOps[opcode]
+		if !found {
+			break
+		}
+		if s.arg2.value.Sign() == 0 && divisions[opcode] {
+			// division by zero, the trace ends here
+			break
+		}
+		arg2 := bigintToDec(t, &s.arg2.value)
+		next, ok := tryDec(func() Dec { return op(acc, arg2) })
+		if !ok {
+			// out of range, the trace ends here
+			break
+		}
+		acc = next
+		exact = exactOps[opcode](new(big.Rat), exact, bigintToRat(&s.arg2.value))
+		loss.steps++
+		if dist := distanceInUnits(acc, exact); dist.Cmp(loss.maxError) > 0 {
+			loss.maxError = dist
+			loss.maxStep = i + 1
+		}
+	}
+
+	return loss
+}
+
+// report the accumulated error of a chained trace for all kinds of division
+func ReportPrecisionLoss(t *testing.T, filename string) {
+	var states = parseItf(filename)
+	for _, division := range []string{"", "quo", "quoTruncate", "quoRoundup"} {
+		name := division
+		if name == "" {
+			name = "asInTrace"
+		}
+		loss := measurePrecisionLoss(t, states, division)
+		t.Logf("%s, divisions %s: max error %s units at step %d of %d",
+			filename, name, loss.maxError.FloatString(3), loss.maxStep, loss.steps)
+	}
+}
+
+func TestPrecisionLoss(t *testing.T) {
+	ReportPrecisionLoss(t, tracePath("chain.itf.json"))
+}
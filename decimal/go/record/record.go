@@ -0,0 +1,282 @@
+// Package record records the operations of sdk.Dec in a real application,
+// e.g., in the tests of a module, into a trace in the format of
+// decimalTest.qnt. The application calls the operations through a Recorder,
+// which calls sdk.Dec, and records the arguments and the result of every
+// call, or the cause of its panic:
+//
+//	r := record.New()
+//	price := r.Quo(amount, supply)
+//	...
+//	err := r.WriteFile("app.itf.json")
+//	err = r.WriteQuintFile("app.qnt", "recorded", "../../decimalTest", 18, 315)
+//
+// The trace is replayed by the harness against other releases of cosmos-sdk,
+// see -itf-dir, and checked by the coverage and the oracle tests. The Quint
+// module has a run that checks every recorded operation against the spec, with
+// replayed of decimalTest.qnt, as the application chose the arguments, not
+// the spec:
+//
+//	quint test --main=recorded app.qnt
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// a decimal of decimalTest.qnt: { error: str, value: int }
+type dec struct {
+	err   string
+	value *big.Int
+}
+
+// a recorded operation, which is a state of decimalTest.qnt
+type call struct {
+	opcode     string
+	arg1, arg2 dec
+	result     dec
+}
+
+// Recorder calls the operations of sdk.Dec, and records them. It is safe for
+// concurrent use, and the calls are recorded in the order they return.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []call
+}
+
+// New returns a recorder without calls.
+func New() *Recorder {
+	return &Recorder{}
+}
+
+// Len returns the number of the recorded calls.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+// The causes of the panics of sdk.Dec by the fragments of their messages, as
+// the harness tells them, see ERR_* of decimal.qnt.
+var causeMessages = []struct {
+	fragment string
+	cause    string
+}{
+	{"division by zero", "divisionByZero"},
+	{"too much precision", "tooMuchPrecision"},
+	{"index out of range [-", "negativePrecision"},
+	{"overflow", "overflowBitLen"},
+	{"out of bound", "overflowBitLen"},
+	{"out of range", "overflowBitLen"},
+}
+
+// the cause of a panic, or the message, if it has no cause of the spec
+func causeOf(reason any) string {
+	msg := strings.ToLower(fmt.Sprint(reason))
+	for _, m := range causeMessages {
+		if strings.Contains(msg, m.fragment) {
+			return m.cause
+		}
+	}
+	return msg
+}
+
+func integer(i int64) dec {
+	return dec{value: big.NewInt(i)}
+}
+
+func decimal(d sdk.Dec) dec {
+	return dec{value: new(big.Int).Set(d.BigInt())}
+}
+
+// Record a call, whose result is computed by op. A panic of op is recorded,
+// and raised again, so the application fails as it would without recording.
+func record[T any](r *Recorder, opcode string, arg1, arg2 dec, op func() T, result func(T) dec) T {
+	c := call{opcode: opcode, arg1: arg1, arg2: arg2, result: dec{value: new(big.Int)}}
+	defer func() {
+		if reason := recover(); reason != nil {
+			c.result.err = causeOf(reason)
+			r.append(c)
+			panic(reason)
+		}
+	}()
+	value := op()
+	c.result = result(value)
+	r.append(c)
+	return value
+}
+
+func (r *Recorder) append(c call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, c)
+}
+
+// the constructors, whose arguments are integers, as in mkWholeDec and mkFracDec
+
+func (r *Recorder) NewDec(i int64) sdk.Dec {
+	return record(r, "newDec", integer(i), integer(0), func() sdk.Dec { return sdk.NewDec(i) }, decimal)
+}
+
+func (r *Recorder) NewDecWithPrec(i, prec int64) sdk.Dec {
+	return record(r, "newDecWithPrec", integer(i), integer(prec),
+		func() sdk.Dec { return sdk.NewDecWithPrec(i, prec) }, decimal)
+}
+
+func (r *Recorder) NewDecFromInt(i sdk.Int) sdk.Dec {
+	return record(r, "newDecFromInt", dec{value: i.BigInt()}, integer(0),
+		func() sdk.Dec { return sdk.NewDecFromInt(i) }, decimal)
+}
+
+func (r *Recorder) NewDecFromIntWithPrec(i sdk.Int, prec int64) sdk.Dec {
+	return record(r, "newDecFromIntWithPrec", dec{value: i.BigInt()}, integer(prec),
+		func() sdk.Dec { return sdk.NewDecFromIntWithPrec(i, prec) }, decimal)
+}
+
+func (r *Recorder) NewDecFromBigInt(i *big.Int) sdk.Dec {
+	return record(r, "newDecFromBigInt", dec{value: new(big.Int).Set(i)}, integer(0),
+		func() sdk.Dec { return sdk.NewDecFromBigInt(i) }, decimal)
+}
+
+func (r *Recorder) NewDecFromBigIntWithPrec(i *big.Int, prec int64) sdk.Dec {
+	return record(r, "newDecFromBigIntWithPrec", dec{value: new(big.Int).Set(i)}, integer(prec),
+		func() sdk.Dec { return sdk.NewDecFromBigIntWithPrec(i, prec) }, decimal)
+}
+
+// the operations, whose arguments are decimals, by their opcodes of rounding.json
+
+func (r *Recorder) binary(opcode string, x, y sdk.Dec, op func(sdk.Dec, sdk.Dec) sdk.Dec) sdk.Dec {
+	return record(r, opcode, decimal(x), decimal(y), func() sdk.Dec { return op(x, y) }, decimal)
+}
+
+func (r *Recorder) Add(x, y sdk.Dec) sdk.Dec {
+	return r.binary("add", x, y, sdk.Dec.Add)
+}
+
+func (r *Recorder) Sub(x, y sdk.Dec) sdk.Dec {
+	return r.binary("sub", x, y, sdk.Dec.Sub)
+}
+
+func (r *Recorder) Mul(x, y sdk.Dec) sdk.Dec {
+	return r.binary("mul", x, y, sdk.Dec.Mul)
+}
+
+func (r *Recorder) MulTruncate(x, y sdk.Dec) sdk.Dec {
+	return r.binary("mulTruncate", x, y, sdk.Dec.MulTruncate)
+}
+
+func (r *Recorder) Quo(x, y sdk.Dec) sdk.Dec {
+	return r.binary("quo", x, y, sdk.Dec.Quo)
+}
+
+func (r *Recorder) QuoTruncate(x, y sdk.Dec) sdk.Dec {
+	return r.binary("quoTruncate", x, y, sdk.Dec.QuoTruncate)
+}
+
+func (r *Recorder) QuoRoundUp(x, y sdk.Dec) sdk.Dec {
+	return r.binary("quoRoundup", x, y, sdk.Dec.QuoRoundUp)
+}
+
+func (r *Recorder) Ceil(x sdk.Dec) sdk.Dec {
+	return record(r, "ceil", decimal(x), integer(0), func() sdk.Dec { return x.Ceil() }, decimal)
+}
+
+// RoundInt records the integer of the result, as decimalTest.qnt does.
+func (r *Recorder) RoundInt(x sdk.Dec) sdk.Int {
+	return record(r, "roundInt", decimal(x), integer(0), func() sdk.Int { return x.RoundInt() },
+		func(i sdk.Int) dec { return dec{value: i.BigInt()} })
+}
+
+// the ITF JSON of a decimal
+func (d dec) itf() map[string]any {
+	return map[string]any{"error": d.err, "value": map[string]string{"#bigint": d.value.String()}}
+}
+
+// WriteITF writes the recorded calls as a trace in ITF JSON, whose states
+// are the states of decimalTest.qnt.
+func (r *Recorder) WriteITF(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	states := make([]map[string]any, len(r.calls))
+	for i, c := range r.calls {
+		states[i] = map[string]any{
+			"#meta":    map[string]int{"index": i},
+			"opcode":   c.opcode,
+			"opArg1":   c.arg1.itf(),
+			"opArg2":   c.arg2.itf(),
+			"opResult": c.result.itf(),
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(map[string]any{
+		"#meta":  map[string]string{"format": "ITF", "source": "recorded by go/record"},
+		"vars":   []string{"opcode", "opArg1", "opArg2", "opResult"},
+		"states": states,
+	})
+}
+
+// WriteFile writes the recorded calls to a file in ITF JSON, see WriteITF.
+func (r *Recorder) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteITF(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// the Quint syntax of a decimal, e.g., { error: "", value: 1500000000000000000 }
+func (d dec) quint() string {
+	return fmt.Sprintf("{ error: %q, value: %s }", d.err, d.value.String())
+}
+
+// WriteQuint writes a Quint module with a run that checks that the recorded
+// calls agree with decimalTest.qnt, which is imported from spec, e.g.,
+// ./decimalTest, with the constants of a decimal type, e.g., 18 and 315 of
+// sdk.Dec.
+func (r *Recorder) WriteQuint(w io.Writer, module, spec string, precision, maxDecBitLen int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.calls) == 0 {
+		return fmt.Errorf("no calls are recorded")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "// -*- mode: Bluespec; -*-\n")
+	fmt.Fprintf(&b, "// Recorded by go/record. Check it with:\n//\n//   quint test --main=%s <this file>\n", module)
+	fmt.Fprintf(&b, "module %s {\n", module)
+	fmt.Fprintf(&b, "    import decimalTest(PRECISION = %d, MAX_DEC_BIT_LEN = %d).* from %q\n\n",
+		precision, maxDecBitLen, spec)
+	fmt.Fprintf(&b, "    // the %d recorded calls, in order\n", len(r.calls))
+	fmt.Fprintf(&b, "    run replayRecordedTest = all {\n")
+	for _, c := range r.calls {
+		fmt.Fprintf(&b, "        assert(agrees(%s, replayed(%q, %s, %s))),\n",
+			c.result.quint(), c.opcode, c.arg1.quint(), c.arg2.quint())
+	}
+	fmt.Fprintf(&b, "    }\n}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteQuintFile writes the Quint module to a file, see WriteQuint.
+func (r *Recorder) WriteQuintFile(filename, module, spec string, precision, maxDecBitLen int) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteQuint(f, module, spec, precision, maxDecBitLen); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,73 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+// Record a few calls, as an application would make them
+func recordCalls(t *testing.T) *Recorder {
+	r := New()
+	x := r.NewDecWithPrec(15, 1)
+	y := r.NewDec(2)
+	r.Add(x, y)
+	r.QuoTruncate(x, y)
+	r.RoundInt(x)
+	require.Panics(t, func() { r.Quo(x, sdk.ZeroDec()) })
+	require.Panics(t, func() { r.NewDecWithPrec(1, 19) })
+	require.Equal(t, 7, r.Len())
+	return r
+}
+
+func TestWriteITF(t *testing.T) {
+	r := recordCalls(t)
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteITF(&buf))
+
+	var trace struct {
+		Vars   []string `json:"vars"`
+		States []struct {
+			Meta   map[string]int `json:"#meta"`
+			Opcode string         `json:"opcode"`
+			Arg1   struct {
+				Value map[string]string `json:"value"`
+			} `json:"opArg1"`
+			Result struct {
+				Error string            `json:"error"`
+				Value map[string]string `json:"value"`
+			} `json:"opResult"`
+		} `json:"states"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &trace))
+	require.Equal(t, []string{"opcode", "opArg1", "opArg2", "opResult"}, trace.Vars)
+	require.Len(t, trace.States, 7)
+	for i, s := range trace.States {
+		require.Equal(t, i, s.Meta["index"])
+	}
+	add := trace.States[2]
+	require.Equal(t, "add", add.Opcode)
+	require.Equal(t, "1500000000000000000", add.Arg1.Value["#bigint"])
+	require.Equal(t, "", add.Result.Error)
+	require.Equal(t, "3500000000000000000", add.Result.Value["#bigint"])
+	require.Equal(t, "2", trace.States[4].Result.Value["#bigint"])
+	require.Equal(t, "divisionByZero", trace.States[5].Result.Error)
+	require.Equal(t, "tooMuchPrecision", trace.States[6].Result.Error)
+}
+
+func TestWriteQuint(t *testing.T) {
+	var buf bytes.Buffer
+	require.Error(t, New().WriteQuint(&buf, "recorded", "./decimalTest", 18, 315))
+
+	r := recordCalls(t)
+	require.NoError(t, r.WriteQuint(&buf, "recorded", "./decimalTest", 18, 315))
+	module := buf.String()
+	require.Contains(t, module, `import decimalTest(PRECISION = 18, MAX_DEC_BIT_LEN = 315).* from "./decimalTest"`)
+	require.Contains(t, module, `assert(agrees({ error: "", value: 3500000000000000000 }, `+
+		`replayed("add", { error: "", value: 1500000000000000000 }, { error: "", value: 2000000000000000000 }))),`)
+	require.Equal(t, 7, strings.Count(module, "assert("))
+}
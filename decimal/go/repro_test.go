@@ -0,0 +1,133 @@
+// When a state fails, the harness prints a command that replays exactly that
+// state, so that a failure found by fuzz.sh or in CI is rerun without digging
+// for the test, the trace, and the flags that found it, e.g.:
+//
+//	reproduce with:
+//	  cd /src/decimal/go && go test -v -run '^Test56ops$/^random56_17_add_1_2$' \
+//	    -args -itf-dir=/src/decimal/test-inputs-v0.46.4
+//	  the trace was generated by quint run --seed=0x2c1f
+//
+// The seed of the trace is known, if fuzz.sh passes it with -quint-seed, or if
+// quint wrote it into the #meta of the trace. A -modfile, e.g., of
+// crossversion.sh, is not known to the test, and must be added by hand.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var quintSeed = flag.String("quint-seed", "", "the seed of quint run that generated the traces, which is printed on failure")
+
+// the arguments of a command that replays a test, besides -run and -itf-dir
+type reproArgs []string
+
+// Print the command that replays the current subtest, if it fails. The filter
+// of -run matches the name of every subtest exactly.
+func logReproOnFailure(t *testing.T, filename string, extra reproArgs) {
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Log(reproCommand(t.Name(), filename, extra))
+		}
+	})
+}
+
+// the command that replays the test of a name on a trace
+func reproCommand(name, filename string, extra reproArgs) string {
+	var run []string
+	for _, elem := range strings.Split(name, "/") {
+		run = append(run, "^"+regexp.QuoteMeta(elem)+"$")
+	}
+	cmd := "go test -v"
+	if buildTags != "" {
+		cmd += " -tags " + buildTags
+	}
+	cmd += fmt.Sprintf(" -run '%s'", strings.Join(run, "/"))
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		dir = filepath.Dir(filename)
+	}
+	args := append(reproArgs{"-itf-dir=" + dir}, extra...)
+	if *decType != "sdk" {
+		args = append(args, "-dec-type="+*decType)
+	}
+	seed := *quintSeed
+	if seed == "" {
+		seed = traceSeed(filename)
+	}
+	if seed != "" {
+		args = append(args, "-quint-seed="+seed)
+	}
+	cmd += " -args " + strings.Join(args, " ")
+	if wd, err := os.Getwd(); err == nil {
+		cmd = fmt.Sprintf("cd %s && %s", wd, cmd)
+	}
+	out := "reproduce with:\n  " + cmd
+	if seed != "" {
+		out += "\n  the trace was generated by quint run --seed=" + seed
+	}
+	return out
+}
+
+// The seed in the #meta of a trace, or "", if there is none. The states are
+// skipped, so reading the seed of a failing trace is cheap.
+func traceSeed(filename string) string {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	iter := jsoniter.ParseBytes(jsoniter.ConfigDefault, data)
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		if field != "#meta" {
+			iter.Skip()
+			continue
+		}
+		for key := iter.ReadObject(); key != ""; key = iter.ReadObject() {
+			if key != "seed" {
+				iter.Skip()
+				continue
+			}
+			// quint writes the seed either as a number or as a hex string
+			if iter.WhatIsNext() == jsoniter.StringValue {
+				return iter.ReadString()
+			}
+			return iter.ReadNumber().String()
+		}
+		return ""
+	}
+	return ""
+}
+
+func TestReproCommand(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "random56.itf.json")
+	writeTrace := func(meta string) {
+		content := `{"#meta":` + meta + `,"vars":["opcode"],"states":[{"opcode":"add"}]}`
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+	}
+
+	writeTrace(`{"format":"ITF"}`)
+	cmd := reproCommand("Test56ops/random56_17_add_1.5_2", filename, nil)
+	assert.Contains(t, cmd, `-run '^Test56ops$/^random56_17_add_1\.5_2$'`, "the filter of -run is exact")
+	assert.Contains(t, cmd, "-itf-dir="+dir)
+	assert.NotContains(t, cmd, "seed")
+
+	writeTrace(`{"format":"ITF","seed":"0x2c1f"}`)
+	cmd = reproCommand("TestChain", filename, reproArgs{"-from=3", "-to=3"})
+	assert.Contains(t, cmd, "-from=3 -to=3 -quint-seed=0x2c1f")
+	assert.Contains(t, cmd, "quint run --seed=0x2c1f")
+
+	writeTrace(`{"seed":11780}`)
+	assert.Equal(t, "11780", traceSeed(filename))
+}
@@ -0,0 +1,167 @@
+// The rounding of every operation is configured in ../rounding.json, which is
+// the single source of truth for the spec and the harness. The spec imports
+// rounding.qnt, which is generated from the JSON file:
+//
+//	go test -run TestRoundingQntInSync -args -update-rounding
+//
+// The harness picks the Golang implementation of every opcode by its operator
+// and rounding. Hence, when the JSON file changes, both sides change together,
+// and TestRoundingQntInSync fails when rounding.qnt has not been regenerated.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the shared configuration and the Quint module generated from it
+const (
+	roundingFile    = "../rounding.json"
+	roundingQntFile = "../rounding.qnt"
+)
+
+var updateRounding = flag.Bool("update-rounding", false, "regenerate rounding.qnt from rounding.json")
+
+// the rounding of an operation, as configured in rounding.json
+type rounding struct {
+	// the mathematical operator, e.g., quo
+	Operator string `json:"operator"`
+	// exact, halfEven, truncate, or ceiling
+	Rounding string `json:"rounding"`
+	// where the rounding happens: ulp (the last digit) or integer
+	Scale string `json:"scale"`
+}
+
+// the rounding of every opcode, as found in rounding.json
+var roundings = loadRoundings(roundingFile)
+
+// read the rounding of every opcode from a JSON file
+func loadRoundings(filename string) map[string]rounding {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		panic(fmt.Errorf("error opening file: %v", err))
+	}
+	var config struct {
+		Operations map[string]rounding `json:"operations"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		panic(fmt.Errorf("error parsing %s: %v", filename, err))
+	}
+	return config.Operations
+}
+
+// the implementations of the binary operators of Dec by their rounding
+var roundedBinaryOps = map[rounding]func(Dec, Dec) Dec{
+	{"add", "exact", "ulp"}:    Dec.Add,
+	{"sub", "exact", "ulp"}:    Dec.Sub,
+	{"mul", "halfEven", "ulp"}: Dec.Mul,
+	{"mul", "truncate", "ulp"}: Dec.MulTruncate,
+	{"quo", "halfEven", "ulp"}: Dec.Quo,
+	{"quo", "truncate", "ulp"}: Dec.QuoTruncate,
+	{"quo", "ceiling", "ulp"}:  Dec.QuoRoundUp,
+}
+
+// the unary operators of Dec, which are called by executeTest directly
+var roundedUnaryOps = map[rounding]string{
+	{"ceil", "ceiling", "integer"}:      "ceil",
+	{"roundInt", "halfEven", "integer"}: "roundInt",
+}
+
+// map the opcodes of the binary operators to their implementations in Dec
+func resolveBinaryOps(roundings map[string]rounding) map[string]func(Dec, Dec) Dec {
+	ops := make(map[string]func(Dec, Dec) Dec)
+	for opcode, r := range roundings {
+		if op, found := roundedBinaryOps[r]; found {
+			ops[opcode] = op
+		}
+	}
+	return ops
+}
+
+// How far the result of an operation may be from the exact value,
+// in units of the last place. Truncation and ceiling lose less than one unit.
+func (r rounding) maxErrorInUnits() float64 {
+	var bound float64
+	switch r.Rounding {
+	case "halfEven":
+		bound = 0.5
+	case "truncate", "ceiling":
+		bound = 1
+	}
+	if r.Scale == "integer" {
+		bound *= math.Pow10(Precision)
+	}
+	return bound
+}
+
+// add the bounds of the configured operations to the bounds of the constructors
+func withRoundingBounds(bounds map[string]float64) map[string]float64 {
+	for opcode, r := range roundings {
+		bounds[opcode] = r.maxErrorInUnits()
+	}
+	return bounds
+}
+
+// render the Quint module that is imported by decimalTest.qnt
+func renderRoundingQnt(roundings map[string]rounding) string {
+	opcodes := make([]string, 0, len(roundings))
+	for opcode := range roundings {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Strings(opcodes)
+
+	var b strings.Builder
+	b.WriteString("// -*- mode: Bluespec; -*-\n")
+	b.WriteString("// Generated from rounding.json. DO NOT EDIT. To regenerate:\n")
+	b.WriteString("//\n")
+	b.WriteString("//   cd go && go test -run TestRoundingQntInSync -args -update-rounding\n")
+	b.WriteString("module rounding {\n")
+	b.WriteString("    type Rounding = { operator: str, rounding: str, scale: str }\n\n")
+	b.WriteString("    pure val ROUNDING: str -> Rounding = Map(\n")
+	for i, opcode := range opcodes {
+		r := roundings[opcode]
+		fmt.Fprintf(&b, "        %q -> { operator: %q, rounding: %q, scale: %q }",
+			opcode, r.Operator, r.Rounding, r.Scale)
+		if i < len(opcodes)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("    )\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// rounding.qnt must be generated from the current rounding.json
+func TestRoundingQntInSync(t *testing.T) {
+	expected := renderRoundingQnt(roundings)
+	if *updateRounding {
+		require.NoError(t, os.WriteFile(roundingQntFile, []byte(expected), 0o644))
+	}
+	actual, err := os.ReadFile(roundingQntFile)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(actual),
+		"rounding.qnt is out of sync with rounding.json, regenerate it with -update-rounding")
+}
+
+// every configured rounding must be implemented by the harness
+func TestRoundingImplemented(t *testing.T) {
+	for opcode, r := range roundings {
+		_, isBinary := roundedBinaryOps[r]
+		_, isUnary := roundedUnaryOps[r]
+		assert.True(t, isBinary || isUnary, "%s: no implementation of %+v", opcode, r)
+		if isUnary {
+			assert.Equal(t, roundedUnaryOps[r], opcode, "%s: the unary operators are called by name", opcode)
+		}
+	}
+}
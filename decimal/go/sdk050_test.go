@@ -0,0 +1,33 @@
+//go:build sdk050
+
+// Bindings of the harness to math.LegacyDec, which replaced sdk.Dec
+// in cosmos-sdk v0.50.x. Run with: go test -tags sdk050
+
+package main
+
+import (
+	sdkmath "cosmossdk.io/math"
+)
+
+type Dec = sdkmath.LegacyDec
+
+type Int = sdkmath.Int
+
+const Precision = sdkmath.LegacyPrecision
+
+// the operations fail by a panic, e.g., on overflow, see failure_test.go
+const decFailure = failsByPanic
+
+// the build tags of the bindings, which a command that reproduces a failure needs
+const buildTags = "sdk050"
+
+var (
+	NewDec                   = sdkmath.LegacyNewDec
+	NewDecWithPrec           = sdkmath.LegacyNewDecWithPrec
+	NewDecFromInt            = sdkmath.LegacyNewDecFromInt
+	NewDecFromIntWithPrec    = sdkmath.LegacyNewDecFromIntWithPrec
+	NewDecFromBigInt         = sdkmath.LegacyNewDecFromBigInt
+	NewDecFromBigIntWithPrec = sdkmath.LegacyNewDecFromBigIntWithPrec
+	NewDecFromStr            = sdkmath.LegacyNewDecFromStr
+	NewIntFromBigInt         = sdkmath.NewIntFromBigInt
+)
@@ -0,0 +1,33 @@
+//go:build !sdk050
+
+// Bindings of the harness to sdk.Dec, as found in cosmos-sdk v0.45.x-v0.47.x.
+// See sdk050_test.go for v0.50.x, where sdk.Dec is gone.
+
+package main
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+type Dec = sdk.Dec
+
+type Int = sdk.Int
+
+const Precision = sdk.Precision
+
+// the operations fail by a panic, e.g., on overflow, see failure_test.go
+const decFailure = failsByPanic
+
+// the build tags of the bindings, which a command that reproduces a failure needs
+const buildTags = ""
+
+var (
+	NewDec                   = sdk.NewDec
+	NewDecWithPrec           = sdk.NewDecWithPrec
+	NewDecFromInt            = sdk.NewDecFromInt
+	NewDecFromIntWithPrec    = sdk.NewDecFromIntWithPrec
+	NewDecFromBigInt         = sdk.NewDecFromBigInt
+	NewDecFromBigIntWithPrec = sdk.NewDecFromBigIntWithPrec
+	NewDecFromStr            = sdk.NewDecFromStr
+	NewIntFromBigInt         = sdk.NewIntFromBigInt
+)
@@ -0,0 +1,231 @@
+// Differential testing of sdk.Dec against shopspring/decimal, which many Go
+// services use for money, and which the users of both libraries expect to
+// compute the same. The adapter below implements the opcodes with
+// shopspring/decimal at 18 digits after '.', the way a user would port the
+// code: it rounds with Round, that is, half away from zero, as shopspring
+// does by default. TestShopspringDivergence replays the traces against both
+// libraries, and reports where they disagree:
+//
+//	go test -v -run TestShopspringDivergence
+//
+// The differences that the users stumble over are:
+//
+//   - sdk.Dec rounds mul, quo, and roundInt half to even, shopspring rounds
+//     half away from zero, e.g., roundInt(2.5) is 2 and 3; RoundBank of
+//     shopspring rounds half to even,
+//   - sdk.Dec quo rounds twice, first it truncates to 36 digits, then it
+//     rounds to 18 digits, whereas DivRound rounds the exact quotient once,
+//   - Div of shopspring rounds to DivisionPrecision, which is 16 digits, not
+//     18 digits, so it is not used here at all,
+//   - sdk.Dec QuoRoundUp rounds to the ceiling, as RoundCeil of shopspring
+//     does, whereas RoundUp of shopspring rounds away from zero,
+//   - shopspring has no bound on the bit length, nor on the precision of the
+//     constructors, so it succeeds where sdk.Dec panics on an overflow, e.g.,
+//     on the traces of addErrorOnBitlen and mulErrorOnBitlen,
+//   - both panic on a division by zero, shopspring with "division by 0".
+//
+// The harness never fails on a disagreement, as the libraries differ by
+// design, but only when the adapter panics on anything but a division by zero.
+
+package main
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// how the outcome of shopspring/decimal relates to the outcome of sdk.Dec
+type disagreement int
+
+const (
+	// both produce the same value, or both fail
+	bothAgree disagreement = iota
+	// both produce a value, but the values are different
+	valuesDiffer
+	// sdk.Dec fails, whereas shopspring/decimal produces a value
+	onlySdkFails
+	// shopspring/decimal fails, whereas sdk.Dec produces a value
+	onlyShopspringFails
+)
+
+func (d disagreement) String() string {
+	switch d {
+	case bothAgree:
+		return "agree"
+	case valuesDiffer:
+		return "valuesDiffer"
+	case onlySdkFails:
+		return "onlySdkFails"
+	default:
+		return "onlyShopspringFails"
+	}
+}
+
+// the digits after '.', as sdk.Dec has them
+func shopspringPlaces() int32 {
+	return int32(constants.Precision)
+}
+
+// the decimal of the integer of a trace, which is 10^Precision times the decimal
+func bigintToShopspring(i *big.Int) decimal.Decimal {
+	return decimal.NewFromBigInt(i, -shopspringPlaces())
+}
+
+// round a quotient up to the ceiling, as quoRoundup does; QuoRem truncates
+// toward zero, so only a positive quotient is rounded
+func quoRoundUp(x, y decimal.Decimal) decimal.Decimal {
+	q, r := x.QuoRem(y, shopspringPlaces())
+	if r.IsZero() || x.Sign()*y.Sign() < 0 {
+		return q
+	}
+	return q.Add(decimal.New(1, -shopspringPlaces()))
+}
+
+// the opcodes of the harness on shopspring/decimal
+var shopspringOps = map[string]func(x, y decimal.Decimal) decimal.Decimal{
+	"add": decimal.Decimal.Add,
+	"sub": decimal.Decimal.Sub,
+	"mul": func(x, y decimal.Decimal) decimal.Decimal {
+		return x.Mul(y).Round(shopspringPlaces())
+	},
+	"mulTruncate": func(x, y decimal.Decimal) decimal.Decimal {
+		return x.Mul(y).Truncate(shopspringPlaces())
+	},
+	"quo": func(x, y decimal.Decimal) decimal.Decimal {
+		return x.DivRound(y, shopspringPlaces())
+	},
+	"quoTruncate": func(x, y decimal.Decimal) decimal.Decimal {
+		q, _ := x.QuoRem(y, shopspringPlaces())
+		return q
+	},
+	"quoRoundup": quoRoundUp,
+	"ceil": func(x, _ decimal.Decimal) decimal.Decimal {
+		return x.Ceil()
+	},
+	"roundInt": func(x, _ decimal.Decimal) decimal.Decimal {
+		return x.Round(0)
+	},
+}
+
+// execute an operation on shopspring/decimal, and return the decimal, or
+// !ok, if it panics, which it should only do on a division by zero
+func executeShopspring(t *testing.T, s TestInput) (result decimal.Decimal, ok bool) {
+	if precisionArgs[s.opcode] {
+		// shopspring/decimal takes any exponent of int32
+		prec := &s.arg2.value
+		if !prec.IsInt64() || prec.Int64() < math.MinInt32+1 || prec.Int64() > math.MaxInt32 {
+			return decimal.Decimal{}, false
+		}
+		return decimal.NewFromBigInt(&s.arg1.value, -int32(prec.Int64())), true
+	}
+	if integerArgs[s.opcode] {
+		return decimal.NewFromBigInt(&s.arg1.value, 0), true
+	}
+	op, found := shopspringOps[s.opcode]
+	require.True(t, found, "no counterpart of %s in shopspring/decimal", s.opcode)
+	arg1, arg2 := bigintToShopspring(&s.arg1.value), bigintToShopspring(&s.arg2.value)
+	result, failure := outcomeOf(t, failsByPanic, func() (decimal.Decimal, error) { return op(arg1, arg2), nil })
+	if failure != nil {
+		require.Equal(t, divisionByZero, causeOf(failure), "shopspring/decimal fails: %v", failure)
+		return result, false
+	}
+	return result, true
+}
+
+// compare the outcomes of sdk.Dec and shopspring/decimal on a state
+func compareShopspring(t *testing.T, s TestInput) disagreement {
+	expected, sdkOk := executeDecValue(s)
+	actual, ok := executeShopspring(t, s)
+	switch {
+	case !sdkOk && !ok:
+		return bothAgree
+	case !sdkOk:
+		return onlySdkFails
+	case !ok:
+		return onlyShopspringFails
+	}
+	exp := -shopspringPlaces()
+	if s.opcode == "roundInt" {
+		exp = 0
+	}
+	if actual.Equal(decimal.NewFromBigInt(expected, exp)) {
+		return bothAgree
+	}
+	t.Logf("%s(%s, %s): sdk.Dec = %s, shopspring/decimal = %s", s.opcode,
+		bigintToDecString(&s.arg1.value), bigintToDecString(&s.arg2.value),
+		decimal.NewFromBigInt(expected, exp).String(), actual.String())
+	return valuesDiffer
+}
+
+// Replay the traces in the trace directory against shopspring/decimal, and
+// report the disagreements with sdk.Dec by opcode
+func TestShopspringDivergence(t *testing.T) {
+	files, err := traceFiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no traces in %s", *traceDir)
+	counts := make(map[string]map[disagreement]int)
+	for _, filename := range files {
+		for i, s := range parseItf(filename) {
+			if !knownOpcode(s.opcode) {
+				continue
+			}
+			t.Run(stepName(filename, i, s), func(t *testing.T) {
+				d := compareShopspring(t, s)
+				if counts[s.opcode] == nil {
+					counts[s.opcode] = make(map[disagreement]int)
+				}
+				counts[s.opcode][d]++
+			})
+		}
+	}
+
+	var opcodes []string
+	for opcode := range counts {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Strings(opcodes)
+	t.Logf("%-26s %7s %13s %13s %20s", "opcode", bothAgree, valuesDiffer, onlySdkFails, onlyShopspringFails)
+	for _, opcode := range opcodes {
+		c := counts[opcode]
+		t.Logf("%-26s %7d %13d %13d %20d", opcode,
+			c[bothAgree], c[valuesDiffer], c[onlySdkFails], c[onlyShopspringFails])
+	}
+}
+
+// The differences of the documentation above, on states that the traces
+// may miss
+func TestShopspringDifferences(t *testing.T) {
+	dec := func(s string) decimal.Decimal {
+		return decimal.RequireFromString(s)
+	}
+	// half away from zero vs. half to even
+	require.Equal(t, "3", shopspringOps["roundInt"](dec("2.5"), decimal.Zero).String())
+	require.Equal(t, "2", dec("2.5").RoundBank(0).String())
+	require.Equal(t, "0.000000000000000001",
+		shopspringOps["mul"](dec("0.000000000000000001"), dec("0.5")).String())
+	// Div rounds to 16 digits
+	require.Equal(t, "0.6666666666666667", dec("2").Div(dec("3")).String())
+	require.Equal(t, "0.666666666666666667", shopspringOps["quo"](dec("2"), dec("3")).String())
+	// rounding up is to the ceiling, as in sdk.Dec, not away from zero
+	require.Equal(t, "0.666666666666666667", quoRoundUp(dec("2"), dec("3")).String())
+	require.Equal(t, "-0.666666666666666666", quoRoundUp(dec("-2"), dec("3")).String())
+	require.Equal(t, "-0.666666666666666667", dec("-2").DivRound(dec("3"), 19).RoundUp(18).String())
+	require.Equal(t, "0.666666666666666666", shopspringOps["quoTruncate"](dec("2"), dec("3")).String())
+	// no bound on the precision of the constructors
+	s := TestInput{opcode: "newDecWithPrec"}
+	s.arg1.value.SetInt64(1)
+	s.arg2.value.SetInt64(19)
+	actual, ok := executeShopspring(t, s)
+	require.True(t, ok)
+	require.Equal(t, "0.0000000000000000001", actual.String())
+	// a division by zero
+	s = TestInput{opcode: "quo"}
+	s.arg1.value.SetInt64(1)
+	_, ok = executeShopspring(t, s)
+	require.False(t, ok)
+}
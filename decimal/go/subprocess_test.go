@@ -0,0 +1,155 @@
+// Cross-language conformance: an external process, e.g., the decimal module
+// of Python in ../oracle.py, or a Rust implementation, computes the states of
+// the traces, and the harness compares its results with the spec. The process
+// talks the protocol itf-oracle/1, see Oracle in ../../itf/oracle.go: JSON,
+// one value per line, on its stdin and stdout.
+//
+// A step is the opcode, the arguments, as the traces write them, and the
+// constants of the decimal type:
+//
+//	{"opcode": "add", "arg1": "1500000000000000000", "arg2": "2000000000000000000",
+//	 "precision": 18, "maxDecBitLen": 315}
+//
+// The result is a decimal of the spec: {"error": "", "value": "3500000000000000000"},
+// where error is a cause of ERR_* of decimal.qnt, if the operation fails:
+//
+//	go test -v -run TestSubprocessOracle -args -oracle-cmd="python3 ../oracle.py"
+//
+// A result that differs from the spec fails the test, except for quo, which
+// the spec rounds twice, see apd_test.go. A step that the process does not
+// implement is skipped.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+var oracleCmd = flag.String("oracle-cmd", "", "the command of an external oracle, e.g., python3 ../oracle.py")
+
+// a step of a trace, as it is sent to the external oracle
+type oracleStep struct {
+	Opcode       string `json:"opcode"`
+	Arg1         string `json:"arg1"`
+	Arg2         string `json:"arg2"`
+	Precision    int    `json:"precision"`
+	MaxDecBitLen int    `json:"maxDecBitLen"`
+}
+
+// the result of a step, as the external oracle computes it
+type oracleResult struct {
+	Error string `json:"error"`
+	Value string `json:"value"`
+}
+
+// start the process of an oracle, which is stopped after the test
+func startSubprocessOracle(t *testing.T, command string) *itf.Oracle {
+	args := strings.Fields(command)
+	require.NotEmpty(t, args, "no command of the oracle")
+	o, err := itf.StartOracle(args[0], args[1:]...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := o.Close(); err != nil {
+			t.Errorf("oracle %s: %v", o.Name(), err)
+		}
+	})
+	return o
+}
+
+// send a step to the oracle, and return its result, or nil, if the oracle
+// does not implement the step
+func askOracle(o *itf.Oracle, step oracleStep) (*oracleResult, error) {
+	reply, err := o.Ask(step)
+	if err != nil || reply.Unsupported {
+		return nil, err
+	}
+	var result oracleResult
+	if err := json.Unmarshal(reply.Result, &result); err != nil {
+		return nil, fmt.Errorf("oracle %s: %v", o.Name(), err)
+	}
+	return &result, nil
+}
+
+// whether the result of the oracle agrees with the spec: both fail, for the
+// same cause, if the spec tells it, or both succeed with the same value
+func agreesWithSpec(t *testing.T, expected TestDec, actual *oracleResult) bool {
+	if expected.failed() || actual.Error != "" {
+		if !expected.failed() || actual.Error == "" {
+			return false
+		}
+		cause := expected.error
+		return cause == unknownCause || string(cause) == actual.Error
+	}
+	value, ok := new(big.Int).SetString(actual.Value, 10)
+	require.True(t, ok, "the oracle returns a value that is not an integer: %q", actual.Value)
+	return value.Cmp(&expected.value) == 0
+}
+
+// Check the traces in the trace directory against an external oracle
+func TestSubprocessOracle(t *testing.T) {
+	if *oracleCmd == "" {
+		t.Skip("pass -oracle-cmd to check the traces against an external oracle")
+	}
+	oracle := startSubprocessOracle(t, *oracleCmd)
+	files, err := traceFiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no traces in %s", *traceDir)
+
+	type counts struct{ agree, differ, unsupported int }
+	byOpcode := make(map[string]*counts)
+	for _, filename := range files {
+		for i, s := range parseItf(filename) {
+			if s.arg1.failed() || s.arg2.failed() {
+				continue
+			}
+			if byOpcode[s.opcode] == nil {
+				byOpcode[s.opcode] = &counts{}
+			}
+			c := byOpcode[s.opcode]
+			actual, err := askOracle(oracle, oracleStep{
+				Opcode:       s.opcode,
+				Arg1:         s.arg1.value.String(),
+				Arg2:         s.arg2.value.String(),
+				Precision:    constants.Precision,
+				MaxDecBitLen: constants.MaxDecBitLen,
+			})
+			require.NoError(t, err)
+			name := stepName(filename, i, s)
+			switch {
+			case actual == nil:
+				c.unsupported++
+			case agreesWithSpec(t, s.result, actual):
+				c.agree++
+			default:
+				c.differ++
+				report := t.Errorf
+				if doubleRounded[s.opcode] {
+					report = t.Logf
+				}
+				report("%s: the spec computes {error: %q, value: %s}, %s computes {error: %q, value: %s}",
+					name, s.result.error, s.result.value.String(), oracle.Name(), actual.Error, actual.Value)
+			}
+		}
+	}
+
+	var opcodes []string
+	for opcode := range byOpcode {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Strings(opcodes)
+	t.Logf("%s: %-26s %7s %7s %12s", oracle.Name(), "opcode", "agree", "differ", "unsupported")
+	for _, opcode := range opcodes {
+		c := byOpcode[opcode]
+		t.Logf("%s: %-26s %7d %7d %12d", oracle.Name(), opcode, c.agree, c.differ, c.unsupported)
+	}
+}
@@ -0,0 +1,66 @@
+#!/usr/bin/env bash
+#
+# Mutation testing of the harness: apply a typical modeling mistake to the spec,
+# generate traces from the mutant, and check that the Golang harness fails on
+# them. A mutant that survives shows a blind spot of the harness.
+#
+# Usage: ./mutate.sh [number-of-runs-per-mutant]
+
+RUNS=${1:-10}
+
+# step|file|sed expression that introduces the mistake
+MUTANTS=(
+    # off-by-one on precision
+    "step|constants.qnt|s/PRECISION = 18, MAX_DEC_BIT_LEN = 315/PRECISION = 17, MAX_DEC_BIT_LEN = 315/g"
+    "step|constants.qnt|s/PRECISION = 18, MAX_DEC_BIT_LEN = 315/PRECISION = 18, MAX_DEC_BIT_LEN = 316/g"
+    # round half to odd instead of half to even
+    "stepMul|decimal.qnt|s/remX == HALF and quoX % 2 == 0/remX == HALF and quoX % 2 == 1/"
+    # round half up instead of half to even
+    "stepMul|decimal.qnt|s/if (remX < HALF or (remX == HALF and quoX % 2 == 0))/if (remX < HALF)/"
+    # round down instead of truncating
+    "stepQuoTruncate|decimal.qnt|s/pure val chopped: int = quoX \/ ONE/pure val chopped: int = chopPrecisionAndRound(quoX)/"
+    "stepMulTruncate|decimal.qnt|s/pure val chopped: int = mathProd \/ ONE/pure val chopped: int = chopPrecisionAndRoundUp(mathProd)/"
+    # round negative numbers up to the larger absolute value
+    "stepQuoRoundup|decimal.qnt|s/-(absX \/ ONE)/-(absX \/ ONE) - 1/"
+    # flip the direction of ceil
+    "stepCeil|decimal.qnt|s/x.value % ONE == 0 or x.value < 0/x.value % ONE == 0 or x.value > 0/"
+    # report an overflow for another cause
+    "stepMul|decimal.qnt|s/errorIf(not(isBitLenOk(chopped)), ERR_OVERFLOW_BIT_LEN)/errorIf(not(isBitLenOk(chopped)), ERR_DIVISION_BY_ZERO)/"
+)
+
+TMP=`mktemp -d`
+trap "rm -rf $TMP" EXIT
+
+KILLED=0
+SURVIVED=0
+for m in "${MUTANTS[@]}"; do
+    IFS="|" read -r step file expr <<< "$m"
+    rm -rf $TMP/spec $TMP/traces
+    mkdir -p $TMP/spec $TMP/traces
+    cp *.qnt $TMP/spec
+    sed -i "$expr" $TMP/spec/$file
+    if cmp -s $file $TMP/spec/$file; then
+        echo "[error] the mutation does not apply to $file: $expr"
+        exit 1
+    fi
+
+    status="survived"
+    for i in `seq 1 $RUNS`; do
+        quint run --max-samples=100 --max-steps=100 --step=$step \
+            --out-itf=$TMP/traces/oneRandom.itf.json --main=sdk $TMP/spec/constants.qnt >/dev/null
+        if ! (cd go && go test -run TestOneRun -args -itf-dir=$TMP/traces >/dev/null); then
+            status="killed"
+            break
+        fi
+    done
+
+    if [ "$status" == "killed" ]; then
+        KILLED=$((KILLED + 1))
+    else
+        SURVIVED=$((SURVIVED + 1))
+    fi
+    echo "[$status] $file: $expr"
+done
+
+echo "$KILLED killed, $SURVIVED survived"
+[ "$SURVIVED" == 0 ]
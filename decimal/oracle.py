@@ -0,0 +1,134 @@
+#!/usr/bin/env python3
+#
+# An oracle of the decimal operations on the decimal module of Python, which
+# talks the protocol itf-oracle/1 of ../itf/oracle.go on stdin and stdout.
+# The harness sends the states of the traces to it, see subprocess_test.go:
+#
+#   cd go && go test -v -run TestSubprocessOracle -args -oracle-cmd="python3 ../oracle.py"
+#
+# A step is { "opcode": "add", "arg1": "1500000000000000000", "arg2": "2",
+# "precision": 18, "maxDecBitLen": 315 }, whose arguments are the integers of
+# the traces, that is, 10^precision times a decimal, or the integers of the
+# constructors. The result is { "error": "", "value": "3500000000000000000" },
+# with a cause of ERR_* of decimal.qnt in "error", if the operation fails.
+# The rounding of every opcode is read from rounding.json next to this script.
+
+import json
+import os
+import sys
+from decimal import (Context, Decimal, Inexact, ROUND_CEILING, ROUND_DOWN,
+                     ROUND_HALF_EVEN)
+
+ROUNDERS = {
+    "exact": ROUND_HALF_EVEN,
+    "halfEven": ROUND_HALF_EVEN,
+    "truncate": ROUND_DOWN,
+    "ceiling": ROUND_CEILING,
+}
+
+# digits of the exact operations, enough for the product of two decimals of
+# 2^368 bits, as the widest decimal type of constants.json
+DIGITS = 400
+
+
+def load_roundings():
+    path = os.path.join(os.path.dirname(os.path.abspath(__file__)), "rounding.json")
+    with open(path) as f:
+        return json.load(f)["operations"]
+
+
+def failure(cause):
+    return {"error": cause, "value": "0"}
+
+
+def precision_error(prec, precision):
+    if prec < 0:
+        return "negativePrecision"
+    if prec > precision:
+        return "tooMuchPrecision"
+    return ""
+
+
+def quotient(x, y):
+    """The exact quotient, or the truncated quotient with a sticky digit,
+    which is rounded as the exact quotient would be."""
+    ctx = Context(prec=DIGITS, rounding=ROUND_DOWN)
+    q = ctx.divide(x, y)
+    if ctx.flags[Inexact]:
+        sticky = Decimal((0, (1,), q.adjusted() - DIGITS))
+        q = Context(prec=DIGITS + 2).add(q, sticky.copy_sign(q))
+    return q
+
+
+def execute(step, roundings):
+    opcode = step["opcode"]
+    a, b = int(step["arg1"]), int(step["arg2"])
+    precision, max_bits = step["precision"], step["maxDecBitLen"]
+    one = 10 ** precision
+
+    def dec(value):
+        return {"error": "", "value": str(value)}
+
+    # the constructors are exact, and they never overflow
+    if opcode in ("newDec", "newDecWithPrec") and not -2**63 <= a < 2**63:
+        return failure("overflowBitLen")
+    if opcode in ("newDecFromInt", "newDecFromIntWithPrec") and abs(a) >= 2**256:
+        return failure("overflowBitLen")
+    if opcode in ("newDec", "newDecFromInt", "newDecFromBigInt"):
+        return dec(a * one)
+    if opcode in ("newDecWithPrec", "newDecFromIntWithPrec", "newDecFromBigIntWithPrec"):
+        cause = precision_error(b, precision)
+        return failure(cause) if cause else dec(a * 10 ** (precision - b))
+
+    r = roundings.get(opcode)
+    if r is None:
+        return None
+    exact = Context(prec=DIGITS)
+    x, y = Decimal(a).scaleb(-precision, exact), Decimal(b).scaleb(-precision, exact)
+    if r["operator"] == "add":
+        z = exact.add(x, y)
+    elif r["operator"] == "sub":
+        z = exact.subtract(x, y)
+    elif r["operator"] == "mul":
+        z = exact.multiply(x, y)
+    elif r["operator"] == "quo":
+        if b == 0:
+            return failure("divisionByZero")
+        z = quotient(x, y)
+    elif r["operator"] in ("ceil", "roundInt"):
+        z = x
+    else:
+        return None
+
+    places = 0 if r["scale"] == "integer" else precision
+    rounded = z.quantize(Decimal(1).scaleb(-places), rounding=ROUNDERS[r["rounding"]],
+                         context=exact)
+    if opcode == "roundInt":
+        return dec(int(rounded))
+    value = int(rounded.scaleb(precision, exact))
+    # only the results of the arithmetic operators are bounded, not of ceil
+    if r["operator"] in ("add", "sub", "mul", "quo") and abs(value) >= 2 ** max_bits:
+        return {"error": "overflowBitLen", "value": str(value)}
+    return dec(value)
+
+
+def main():
+    roundings = load_roundings()
+    print(json.dumps({"protocol": "itf-oracle/1", "name": "python-decimal"}), flush=True)
+    for line in sys.stdin:
+        request = {}
+        try:
+            request = json.loads(line)
+            result = execute(request["step"], roundings)
+        except Exception as e:
+            print(json.dumps({"id": request.get("id", 0), "error": repr(e)}), flush=True)
+            continue
+        if result is None:
+            reply = {"id": request["id"], "unsupported": True}
+        else:
+            reply = {"id": request["id"], "result": result}
+        print(json.dumps(reply), flush=True)
+
+
+if __name__ == "__main__":
+    main()
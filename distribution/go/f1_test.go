@@ -0,0 +1,261 @@
+// A test harness that replays the traces of f1Test.qnt against x/distribution
+// of SimApp, as shipped with cosmos-sdk v0.46.4.
+//
+// The spec tracks a single validator with the commission rate of 10%. Every
+// state of a trace records the action that led to it in lastAction: allocating
+// rewards to the validator, delegating to it, or withdrawing the rewards of a
+// delegation. We execute the action with the keepers and compare the
+// distribution records of the validator with the state of the spec, digit by digit.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	"github.com/cosmos/cosmos-sdk/x/staking/teststaking"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the constants of f1Test.qnt
+const (
+	operator = "val"
+	denom    = sdk.DefaultBondDenom
+)
+
+// the delegators of f1Test.qnt, who are funded generously on genesis
+var delegators = []string{operator, "alice", "bob"}
+
+// COMMISSION_RATE of f1.qnt
+var commissionRate = sdk.NewDecWithPrec(1, 1)
+
+// when a delegation was last initialized, as in the starting info of the keeper
+type TestStartingInfo struct {
+	period uint64
+	stake  sdk.Dec
+}
+
+// the distribution records of the validator
+type TestDistr struct {
+	tokens         *big.Int
+	period         uint64
+	currentRewards sdk.Dec
+	historical     map[uint64]sdk.Dec
+	starting       map[string]TestStartingInfo
+	outstanding    sdk.Dec
+	commission     sdk.Dec
+	communityPool  sdk.Dec
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind      string
+	delegator string
+	amount    sdk.Dec
+	// the rewards that were paid out to the delegator
+	withdrawn *big.Int
+}
+
+// a state of our testing state machine
+type TestState struct {
+	distr      TestDistr
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from f1Test.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		distr := jsonState.Get("distr")
+		state.distr.tokens = harness.BigInt(t, distr.Get("tokens"))
+		state.distr.period = harness.BigInt(t, distr.Get("period")).Uint64()
+		state.distr.currentRewards = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, distr.Get("currentRewards")), sdk.Precision)
+		state.distr.historical = make(map[uint64]sdk.Dec)
+		entries, err := itf.Map(distr.Get("historical"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.distr.historical[harness.BigInt(t, e.Key).Uint64()] = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, e.Value), sdk.Precision)
+		}
+		state.distr.starting = make(map[string]TestStartingInfo)
+		entries, err = itf.Map(distr.Get("starting"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.distr.starting[e.Key.String()] = TestStartingInfo{
+				period: harness.BigInt(t, e.Value.Get("period")).Uint64(),
+				stake:  sdk.NewDecFromBigIntWithPrec(harness.Dec(t, e.Value.Get("stake")), sdk.Precision),
+			}
+		}
+		state.distr.outstanding = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, distr.Get("outstanding")), sdk.Precision)
+		state.distr.commission = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, distr.Get("commission")), sdk.Precision)
+		state.distr.communityPool = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, distr.Get("communityPool")), sdk.Precision)
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.delegator = jsonState.Get("lastAction.delegator").String()
+		state.lastAction.amount = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("lastAction.amount")), sdk.Precision)
+		state.lastAction.withdrawn = harness.BigInt(t, jsonState.Get("lastAction.withdrawn"))
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// SimApp with the funded delegators, which is set up once for all traces, see simchain
+var sharedChain *simchain.Chain
+
+// the chain under test, together with the records that the spec starts from zero
+type testChain struct {
+	*simchain.Chain
+	tstaking *teststaking.Helper
+	// the community pool of the genesis
+	initialPool sdk.Dec
+}
+
+// Reset the chain, whose delegators are funded on setup, and create the
+// validator, as in the initial state of the spec.
+func setupChain(t *testing.T, init TestState) *testChain {
+	if sharedChain == nil {
+		funds := sdk.NewCoins(sdk.NewCoin(denom, sdk.NewInt(1_000_000_000)))
+		balances := make(map[string]sdk.Coins)
+		for _, name := range delegators {
+			balances[name] = funds
+		}
+		sharedChain = simchain.New(t, simchain.Config{
+			Modules:  []string{simchain.Staking, simchain.Distribution},
+			Balances: balances,
+		})
+	}
+	sharedChain.Reset()
+	chain := &testChain{
+		Chain:       sharedChain,
+		tstaking:    teststaking.NewHelper(t, sharedChain.Ctx, sharedChain.Keepers.Staking),
+		initialPool: sharedChain.Keepers.Distribution.GetFeePool(sharedChain.Ctx).CommunityPool.AmountOf(denom),
+	}
+	chain.tstaking.Commission = stakingtypes.NewCommissionRates(commissionRate, commissionRate, sdk.ZeroDec())
+	selfBond := init.lastAction.amount.TruncateInt()
+	chain.tstaking.CreateValidator(simchain.ValAddr(operator), ed25519.GenPrivKey().PubKey(), selfBond, true)
+	return chain
+}
+
+// Execute an action with the keepers and return the balance change of the
+// delegator. Every action happens in a new block, since the rewards of a
+// delegation are zero in the block it was initialized.
+func (c *testChain) executeAction(t *testing.T, height int64, a TestAction) *big.Int {
+	c.Ctx = c.Ctx.WithBlockHeight(height)
+	c.tstaking.Ctx = c.Ctx
+	var before sdk.Int
+	if a.delegator != "" {
+		before = c.Keepers.Bank.GetBalance(c.Ctx, simchain.Addr(a.delegator), denom).Amount
+	}
+	switch a.kind {
+	case "allocate":
+		// the distribution module has to hold the coins it pays out
+		funds := sdk.NewCoins(sdk.NewCoin(denom, a.amount.Ceil().TruncateInt()))
+		require.NoError(t, banktestutil.FundModuleAccount(c.Keepers.Bank, c.Ctx, distrtypes.ModuleName, funds))
+		val := c.Keepers.Staking.Validator(c.Ctx, simchain.ValAddr(operator))
+		c.Keepers.Distribution.AllocateTokensToValidator(c.Ctx, val, sdk.NewDecCoins(sdk.NewDecCoinFromDec(denom, a.amount)))
+		return big.NewInt(0)
+
+	case "delegate":
+		c.tstaking.Delegate(simchain.Addr(a.delegator), simchain.ValAddr(operator), a.amount.TruncateInt())
+
+	case "withdraw":
+		_, err := c.Keepers.Distribution.WithdrawDelegationRewards(c.Ctx, simchain.Addr(a.delegator), simchain.ValAddr(operator))
+		require.NoError(t, err)
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+	}
+	after := c.Keepers.Bank.GetBalance(c.Ctx, simchain.Addr(a.delegator), denom).Amount
+	return after.Sub(before).BigInt()
+}
+
+// compare a decimal of the keeper with the one of the spec
+func checkDec(t *testing.T, expected sdk.Dec, actual sdk.DecCoins, what string) {
+	assert.Equal(t, expected.String(), actual.AmountOf(denom).String(), what)
+}
+
+// compare the distribution records of the keeper with the state of the spec
+func (c *testChain) checkState(t *testing.T, s TestState) {
+	keeper, ctx, val := c.Keepers.Distribution, c.Ctx, simchain.ValAddr(operator)
+	tokens := c.Keepers.Staking.Validator(ctx, val).GetTokens()
+	assert.Equal(t, s.distr.tokens.String(), tokens.String(), "tokens")
+
+	current := keeper.GetValidatorCurrentRewards(ctx, val)
+	assert.Equal(t, s.distr.period, current.Period, "period")
+	checkDec(t, s.distr.currentRewards, current.Rewards, "current rewards")
+
+	// the keeper prunes the periods that are not referenced anymore,
+	// so we only compare the last period and the starting periods
+	periods := []uint64{s.distr.period - 1}
+	for name, expected := range s.distr.starting {
+		info := keeper.GetDelegatorStartingInfo(ctx, val, simchain.Addr(name))
+		assert.Equal(t, expected.period, info.PreviousPeriod, "starting period of %s", name)
+		assert.Equal(t, expected.stake.String(), info.Stake.String(), "starting stake of %s", name)
+		periods = append(periods, expected.period)
+	}
+	for _, period := range periods {
+		historical := keeper.GetValidatorHistoricalRewards(ctx, val, period)
+		checkDec(t, s.distr.historical[period], historical.CumulativeRewardRatio,
+			fmt.Sprintf("cumulative reward ratio of period %d", period))
+	}
+
+	checkDec(t, s.distr.outstanding, keeper.GetValidatorOutstandingRewards(ctx, val).Rewards, "outstanding rewards")
+	checkDec(t, s.distr.commission, keeper.GetValidatorAccumulatedCommission(ctx, val).Commission, "commission")
+	pool := keeper.GetFeePool(ctx).CommunityPool.AmountOf(denom).Sub(c.initialPool)
+	assert.Equal(t, s.distr.communityPool.String(), pool.String(), "community pool")
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	chain := setupChain(t, states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, s.lastAction.delegator)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				change := chain.executeAction(t, int64(i), s.lastAction)
+				// a delegation pays for its tokens, but it receives the rewards first
+				expected := new(big.Int).Set(s.lastAction.withdrawn)
+				if s.lastAction.kind == "delegate" {
+					expected.Sub(expected, s.lastAction.amount.TruncateInt().BigInt())
+				}
+				assert.Equal(t, expected.String(), change.String(), "balance change of %s", s.lastAction.delegator)
+			}
+			chain.checkState(t, s)
+		})
+		if !ok {
+			// the states of the keeper and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,182 @@
+// A test harness that replays the traces of erc20Test.qnt against the Go port
+// of the ERC20 contract of OpenZeppelin Contracts v4.9.3, see the package token.
+//
+// The token starts with the balances of the initial state of a trace. Every
+// state records the call that led to it in lastTx. We execute the call with
+// the token, compare its revert reason with the spec, and compare the balances,
+// the allowances, and the total supply with the state of the spec.
+
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/erc20/token"
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v4.9.3"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// ZERO_ADDRESS of erc20.qnt
+const zeroAddress = "0x0"
+
+// the storage of the token, as in erc20.qnt
+type TestToken struct {
+	balances map[string]*big.Int
+	// owner -> spender -> amount
+	allowances  map[string]map[string]*big.Int
+	totalSupply *big.Int
+}
+
+// a call, as recorded in lastTx
+type TestTx struct {
+	kind      string
+	sender    string
+	owner     string
+	spender   string
+	recipient string
+	amount    *big.Int
+	// the revert reason, or "" when the call succeeded
+	error string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	token  TestToken
+	lastTx TestTx
+}
+
+// parse the states in the ITF JSON format, as produced from erc20Test.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.token.balances, err = itf.StrBigIntMap(jsonState.Get("token.balances"))
+		require.NoError(t, err)
+		state.token.allowances = make(map[string]map[string]*big.Int)
+		entries, err := itf.Map(jsonState.Get("token.allowances"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.token.allowances[e.Key.String()], err = itf.StrBigIntMap(e.Value)
+			require.NoError(t, err)
+		}
+		state.token.totalSupply = harness.BigInt(t, jsonState.Get("token.totalSupply"))
+		state.lastTx.kind = jsonState.Get("lastTx.kind").String()
+		state.lastTx.sender = jsonState.Get("lastTx.sender").String()
+		state.lastTx.owner = jsonState.Get("lastTx.owner").String()
+		state.lastTx.spender = jsonState.Get("lastTx.spender").String()
+		state.lastTx.recipient = jsonState.Get("lastTx.recipient").String()
+		state.lastTx.amount = harness.BigInt(t, jsonState.Get("lastTx.amount"))
+		state.lastTx.error = jsonState.Get("lastTx.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// convert an amount of the spec to uint256
+func toUint256(t *testing.T, i *big.Int) *uint256.Int {
+	u, overflow := uint256.FromBig(i)
+	require.False(t, overflow, "expected a uint256, found %s", i)
+	return u
+}
+
+// the address of an account that is called by its name in the spec
+func addr(name string) token.Address {
+	var a token.Address
+	if name != zeroAddress {
+		hash := sha256.Sum256([]byte(name))
+		copy(a[:], hash[:])
+	}
+	return a
+}
+
+// mint the balances of the initial state, as the constructor of the token does
+func setupToken(t *testing.T, init TestState) *token.Token {
+	tok := token.New()
+	for name, balance := range init.token.balances {
+		require.NoError(t, tok.Mint(addr(name), toUint256(t, balance)))
+	}
+	return tok
+}
+
+// execute a call with the token and return its revert reason, or "" on success
+func executeTx(t *testing.T, tok *token.Token, tx TestTx) string {
+	amount := toUint256(t, tx.amount)
+	var err error
+	switch tx.kind {
+	case "transfer":
+		err = tok.Transfer(addr(tx.sender), addr(tx.recipient), amount)
+
+	case "approve":
+		err = tok.Approve(addr(tx.sender), addr(tx.spender), amount)
+
+	case "transferFrom":
+		err = tok.TransferFrom(addr(tx.sender), addr(tx.owner), addr(tx.recipient), amount)
+
+	default:
+		require.Fail(t, "unknown call: "+tx.kind)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// compare the storage of the token with the state of the spec
+func checkState(t *testing.T, tok *token.Token, s TestState) {
+	for name, expected := range s.token.balances {
+		assert.Equal(t, expected.String(), tok.BalanceOf(addr(name)).Dec(), "balance of %s", name)
+	}
+	for owner, spenders := range s.token.allowances {
+		for spender, expected := range spenders {
+			assert.Equal(t, expected.String(), tok.Allowance(addr(owner), addr(spender)).Dec(),
+				"allowance of %s for %s", spender, owner)
+		}
+	}
+	assert.Equal(t, s.token.totalSupply.String(), tok.TotalSupply().Dec(), "total supply")
+}
+
+// execute all calls of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	tok := setupToken(t, states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastTx.kind, s.lastTx.sender)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				reason := executeTx(t, tok, s.lastTx)
+				assert.Equal(t, s.lastTx.error, reason, "revert reason")
+			}
+			checkState(t, tok, s)
+		})
+		if !ok {
+			// the states of the token and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,21 @@
+module github.com/informalsystems/quint-sandbox/erc20
+
+go 1.20
+
+require (
+	github.com/holiman/uint256 v1.2.3
+	github.com/informalsystems/quint-sandbox/itf v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.8.4
+	github.com/tidwall/gjson v1.16.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/klauspost/compress v1.16.7 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/tidwall/match v1.1.1 // indirect
+	github.com/tidwall/pretty v1.2.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/informalsystems/quint-sandbox/itf => ../../itf
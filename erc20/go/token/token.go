@@ -0,0 +1,160 @@
+// Package token is a port of the contract ERC20 of OpenZeppelin Contracts
+// v4.9.3 to Go. It keeps the order of the checks of the contract and its
+// revert reasons, so that it fails exactly when the contract reverts:
+//
+//	https://github.com/OpenZeppelin/openzeppelin-contracts/blob/v4.9.3/contracts/token/ERC20/ERC20.sol
+//
+// As in Solidity 0.8, the arithmetic on uint256 is checked. Since the
+// contract reverts as a whole, a failed call leaves the token as it is.
+package token
+
+import (
+	"errors"
+
+	"github.com/holiman/uint256"
+)
+
+// Address is an account address of 20 bytes, as in the EVM.
+type Address [20]byte
+
+// ZeroAddress is address(0), which nobody can sign for.
+var ZeroAddress Address
+
+// The revert reasons of ERC20.sol.
+var (
+	ErrTransferFromZero      = errors.New("ERC20: transfer from the zero address")
+	ErrTransferToZero        = errors.New("ERC20: transfer to the zero address")
+	ErrExceedsBalance        = errors.New("ERC20: transfer amount exceeds balance")
+	ErrApproveFromZero       = errors.New("ERC20: approve from the zero address")
+	ErrApproveToZero         = errors.New("ERC20: approve to the zero address")
+	ErrInsufficientAllowance = errors.New("ERC20: insufficient allowance")
+	ErrMintToZero            = errors.New("ERC20: mint to the zero address")
+	// the panic of Solidity 0.8 on an arithmetic overflow
+	ErrOverflow = errors.New("arithmetic overflow")
+)
+
+// maxUint256 is type(uint256).max, which stands for an infinite allowance.
+var maxUint256 = new(uint256.Int).SetAllOne()
+
+// Token is the storage of an ERC20 contract.
+type Token struct {
+	balances    map[Address]*uint256.Int
+	allowances  map[Address]map[Address]*uint256.Int
+	totalSupply *uint256.Int
+}
+
+// New returns a token with no supply.
+func New() *Token {
+	return &Token{
+		balances:    make(map[Address]*uint256.Int),
+		allowances:  make(map[Address]map[Address]*uint256.Int),
+		totalSupply: new(uint256.Int),
+	}
+}
+
+// TotalSupply returns the amount of tokens in existence.
+func (t *Token) TotalSupply() *uint256.Int {
+	return t.totalSupply.Clone()
+}
+
+// BalanceOf returns the amount of tokens owned by an account.
+func (t *Token) BalanceOf(account Address) *uint256.Int {
+	if b, ok := t.balances[account]; ok {
+		return b.Clone()
+	}
+	return new(uint256.Int)
+}
+
+// Allowance returns the amount that the spender may transfer on behalf of the owner.
+func (t *Token) Allowance(owner, spender Address) *uint256.Int {
+	if a, ok := t.allowances[owner][spender]; ok {
+		return a.Clone()
+	}
+	return new(uint256.Int)
+}
+
+// Transfer moves the amount from the caller to the recipient.
+func (t *Token) Transfer(caller, to Address, amount *uint256.Int) error {
+	return t.transfer(caller, to, amount)
+}
+
+// Approve sets the allowance of the spender over the tokens of the caller.
+func (t *Token) Approve(caller, spender Address, amount *uint256.Int) error {
+	return t.approve(caller, spender, amount)
+}
+
+// TransferFrom moves the amount from an owner to the recipient, which is
+// deducted from the allowance of the caller, unless the allowance is infinite.
+func (t *Token) TransferFrom(caller, from, to Address, amount *uint256.Int) error {
+	// the checks come before any effect, except for the allowance,
+	// which is restored when the transfer reverts
+	previous := t.Allowance(from, caller)
+	if err := t.spendAllowance(from, caller, amount); err != nil {
+		return err
+	}
+	if err := t.transfer(from, to, amount); err != nil {
+		t.allowances[from][caller] = previous
+		return err
+	}
+	return nil
+}
+
+// Mint creates the amount of tokens and assigns them to the account,
+// as the constructor of a token usually does with _mint.
+func (t *Token) Mint(account Address, amount *uint256.Int) error {
+	if account == ZeroAddress {
+		return ErrMintToZero
+	}
+	supply, overflow := new(uint256.Int).AddOverflow(t.totalSupply, amount)
+	if overflow {
+		return ErrOverflow
+	}
+	t.totalSupply = supply
+	// the balance cannot overflow, since it is bounded by the total supply
+	t.balances[account] = new(uint256.Int).Add(t.BalanceOf(account), amount)
+	return nil
+}
+
+// ERC20._transfer
+func (t *Token) transfer(from, to Address, amount *uint256.Int) error {
+	if from == ZeroAddress {
+		return ErrTransferFromZero
+	}
+	if to == ZeroAddress {
+		return ErrTransferToZero
+	}
+	fromBalance := t.BalanceOf(from)
+	if fromBalance.Lt(amount) {
+		return ErrExceedsBalance
+	}
+	t.balances[from] = new(uint256.Int).Sub(fromBalance, amount)
+	t.balances[to] = new(uint256.Int).Add(t.BalanceOf(to), amount)
+	return nil
+}
+
+// ERC20._approve
+func (t *Token) approve(owner, spender Address, amount *uint256.Int) error {
+	if owner == ZeroAddress {
+		return ErrApproveFromZero
+	}
+	if spender == ZeroAddress {
+		return ErrApproveToZero
+	}
+	if _, ok := t.allowances[owner]; !ok {
+		t.allowances[owner] = make(map[Address]*uint256.Int)
+	}
+	t.allowances[owner][spender] = amount.Clone()
+	return nil
+}
+
+// ERC20._spendAllowance
+func (t *Token) spendAllowance(owner, spender Address, amount *uint256.Int) error {
+	current := t.Allowance(owner, spender)
+	if current.Eq(maxUint256) {
+		return nil
+	}
+	if current.Lt(amount) {
+		return ErrInsufficientAllowance
+	}
+	return t.approve(owner, spender, new(uint256.Int).Sub(current, amount))
+}
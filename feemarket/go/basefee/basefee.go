@@ -0,0 +1,153 @@
+// Package basefee is a reference implementation of a fee market in the style
+// of EIP-1559, after the x/feemarket module of Skip: the base gas price of the
+// next block moves with the gas that the current block used, relative to the
+// target, by a learning rate, which adjusts itself with AIMD over a window of
+// blocks. It computes with the decimals of cosmos-sdk v0.46.4, step by step,
+// as the module does with the legacy decimals of cosmossdk.io/math.
+//
+// With a window of one block, Alpha = 0, Delta = 0, and the learning rate
+// fixed at 1/8, this is the plain rule of EIP-1559.
+package basefee
+
+import (
+	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrMaxBlockUtilization is returned when a block would use more gas than the maximum.
+var ErrMaxBlockUtilization = errors.New("block utilization exceeds the maximum")
+
+// Params are the parameters of the fee market.
+type Params struct {
+	// the increase of the learning rate, when the window is close to empty
+	// or close to full
+	Alpha sdk.Dec
+	// the factor of the decrease of the learning rate otherwise
+	Beta sdk.Dec
+	// how close to empty or to full the window is, relative to the maximum
+	Gamma sdk.Dec
+	// the factor of the net utilization of the window in the base gas price
+	Delta sdk.Dec
+
+	MinBaseGasPrice sdk.Dec
+	MinLearningRate sdk.Dec
+	MaxLearningRate sdk.Dec
+
+	// the gas that a block may use
+	MaxBlockUtilization uint64
+	// the number of blocks of the window
+	Window uint64
+}
+
+// TargetBlockUtilization returns the gas that a block should use, half of the maximum.
+func (p Params) TargetBlockUtilization() uint64 {
+	return p.MaxBlockUtilization / 2
+}
+
+// State is the state of the fee market.
+type State struct {
+	// the base gas price of the next block
+	BaseGasPrice sdk.Dec
+	// the learning rate of the next update
+	LearningRate sdk.Dec
+	// the gas that the blocks of the window used
+	Window []uint64
+	// the index of the current block in the window
+	Index uint64
+}
+
+// NewState returns the state at genesis, with the minimal base gas price and
+// learning rate, and a window of empty blocks.
+func NewState(params Params) *State {
+	return &State{
+		BaseGasPrice: params.MinBaseGasPrice,
+		LearningRate: params.MinLearningRate,
+		Window:       make([]uint64, params.Window),
+	}
+}
+
+// Update consumes gas in the current block.
+func (s *State) Update(gas uint64, params Params) error {
+	used := s.Window[s.Index] + gas
+	if used > params.MaxBlockUtilization {
+		return ErrMaxBlockUtilization
+	}
+	s.Window[s.Index] = used
+	return nil
+}
+
+// AverageUtilization returns the gas that the blocks of the window used,
+// relative to the maximum.
+func (s *State) AverageUtilization(params Params) sdk.Dec {
+	var total uint64
+	for _, used := range s.Window {
+		total += used
+	}
+	max := sdk.NewDecFromInt(sdk.NewIntFromUint64(params.MaxBlockUtilization))
+	window := sdk.NewDecFromInt(sdk.NewIntFromUint64(uint64(len(s.Window))))
+	return sdk.NewDecFromInt(sdk.NewIntFromUint64(total)).Quo(max.Mul(window))
+}
+
+// NetUtilization returns the gas that the blocks of the window used over the
+// target, which is negative if they used less.
+func (s *State) NetUtilization(params Params) sdk.Int {
+	net := sdk.ZeroInt()
+	target := sdk.NewIntFromUint64(params.TargetBlockUtilization())
+	for _, used := range s.Window {
+		net = net.Add(sdk.NewIntFromUint64(used).Sub(target))
+	}
+	return net
+}
+
+// UpdateLearningRate increases the learning rate by Alpha, when the window is
+// close to empty or close to full, and decreases it by the factor Beta
+// otherwise, within its bounds.
+func (s *State) UpdateLearningRate(params Params) sdk.Dec {
+	utilization := s.AverageUtilization(params)
+	var lr sdk.Dec
+	if utilization.LTE(params.Gamma) || utilization.GTE(sdk.OneDec().Sub(params.Gamma)) {
+		lr = params.Alpha.Add(s.LearningRate)
+		if lr.GT(params.MaxLearningRate) {
+			lr = params.MaxLearningRate
+		}
+	} else {
+		lr = s.LearningRate.Mul(params.Beta)
+		if lr.LT(params.MinLearningRate) {
+			lr = params.MinLearningRate
+		}
+	}
+	s.LearningRate = lr
+	return lr
+}
+
+// UpdateBaseGasPrice moves the base gas price by the learning rate times the
+// distance of the current block from the target, relative to the target, and
+// by Delta times the net utilization of the window, but not below its minimum.
+func (s *State) UpdateBaseGasPrice(params Params) sdk.Dec {
+	current := sdk.NewDecFromInt(sdk.NewIntFromUint64(s.Window[s.Index]))
+	target := sdk.NewDecFromInt(sdk.NewIntFromUint64(params.TargetBlockUtilization()))
+	utilization := current.Sub(target).Quo(target)
+	adjustment := sdk.OneDec().Add(s.LearningRate.Mul(utilization))
+	net := sdk.NewDecFromInt(s.NetUtilization(params)).Mul(params.Delta)
+	price := s.BaseGasPrice.Mul(adjustment).Add(net)
+	if price.LT(params.MinBaseGasPrice) {
+		price = params.MinBaseGasPrice
+	}
+	s.BaseGasPrice = price
+	return price
+}
+
+// IncrementHeight moves on to the next block of the window, which has used no gas yet.
+func (s *State) IncrementHeight() {
+	s.Index = (s.Index + 1) % uint64(len(s.Window))
+	s.Window[s.Index] = 0
+}
+
+// EndBlock updates the learning rate, then the base gas price with the new
+// learning rate, and moves on to the next block, as the end blocker does.
+func (s *State) EndBlock(params Params) {
+	s.UpdateLearningRate(params)
+	s.UpdateBaseGasPrice(params)
+	s.IncrementHeight()
+}
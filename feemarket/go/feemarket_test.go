@@ -0,0 +1,149 @@
+// A test harness that replays the traces of feemarketTest.qnt against the
+// reference fee market in the package basefee, which computes with the
+// decimals of cosmos-sdk v0.46.4.
+//
+// Every state of a trace records the action that led to it in lastAction: a
+// transaction that consumes gas in the current block, or the end of the block,
+// which updates the learning rate and the base gas price. We execute the
+// action with the fee market and compare the base gas price and the learning
+// rate, digit by digit, as every rounding step of the decimals matters. Then
+// we compare the window of the blocks.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/informalsystems/quint-sandbox/feemarket/basefee"
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the parameters of feemarket.qnt
+var params = basefee.Params{
+	Alpha:               sdk.NewDecWithPrec(25, 3), // ALPHA
+	Beta:                sdk.NewDecWithPrec(95, 2), // BETA
+	Gamma:               sdk.NewDecWithPrec(25, 2), // GAMMA
+	Delta:               sdk.NewDecWithPrec(1, 3),  // DELTA
+	MinBaseGasPrice:     sdk.NewDec(1),             // MIN_BASE_GAS_PRICE
+	MinLearningRate:     sdk.NewDecWithPrec(1, 2),  // MIN_LEARNING_RATE
+	MaxLearningRate:     sdk.NewDecWithPrec(5, 1),  // MAX_LEARNING_RATE
+	MaxBlockUtilization: 100,                       // MAX_BLOCK_UTILIZATION
+	Window:              4,                         // WINDOW
+}
+
+// the state of the fee market in feemarket.qnt
+type TestFeeMarket struct {
+	baseGasPrice sdk.Dec
+	learningRate sdk.Dec
+	window       []*big.Int
+	index        int64
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind  string
+	gas   *big.Int
+	error string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	state      TestFeeMarket
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from feemarketTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.state.baseGasPrice = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("state.baseGasPrice")), sdk.Precision)
+		state.state.learningRate = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("state.learningRate")), sdk.Precision)
+		for _, used := range jsonState.Get("state.window").Array() {
+			state.state.window = append(state.state.window, harness.BigInt(t, used))
+		}
+		index := harness.BigInt(t, jsonState.Get("state.index"))
+		require.True(t, index.IsInt64(), "expected an int64, found %s", index)
+		state.state.index = index.Int64()
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.gas = harness.BigInt(t, jsonState.Get("lastAction.gas"))
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// execute an action with the fee market and return its error, if any
+func executeAction(t *testing.T, s *basefee.State, a TestAction) string {
+	switch a.kind {
+	case "consume":
+		require.True(t, a.gas.IsUint64(), "expected a uint64, found %s", a.gas)
+		if err := s.Update(a.gas.Uint64(), params); err != nil {
+			return err.Error()
+		}
+		return ""
+
+	case "endBlock":
+		s.EndBlock(params)
+		return ""
+
+	default:
+		return "unknown action: " + a.kind
+	}
+}
+
+// compare the fee market with the state of the spec
+func checkState(t *testing.T, s *basefee.State, expected TestFeeMarket) {
+	assert.Equal(t, expected.baseGasPrice.String(), s.BaseGasPrice.String(), "base gas price")
+	assert.Equal(t, expected.learningRate.String(), s.LearningRate.String(), "learning rate")
+	assert.Equal(t, expected.index, int64(s.Index), "index")
+	require.Len(t, s.Window, len(expected.window), "window")
+	for i, used := range expected.window {
+		assert.Equal(t, used.String(), fmt.Sprint(s.Window[i]), "gas of the block %d of the window", i)
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	s := basefee.NewState(params)
+	for i, state := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, state.lastAction.kind, state.lastAction.gas)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				assert.Equal(t, state.lastAction.error, executeAction(t, s, state.lastAction), "error")
+			}
+			checkState(t, s, state.state)
+		})
+		if !ok {
+			// the states of the fee market and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,343 @@
+// A test harness that replays the traces of govTest.qnt against x/gov of
+// SimApp, as shipped with cosmos-sdk v0.46.4.
+//
+// The validators of the spec are created with their self-delegations and
+// bonded on setup. Every state of a trace records the action that led to it
+// in lastAction: a delegation, a slash, a deposit, a vote, the tally at the
+// end of the voting period, or the submission of the next proposal. We execute
+// the action with the keepers and compare the validators, the delegations,
+// and the proposal with the state of the spec. After a tally, we compare the
+// tally results and the verdict, digit by digit.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	v1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
+	"github.com/cosmos/cosmos-sdk/x/staking/teststaking"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+const denom = sdk.DefaultBondDenom
+
+// the voters of govTest.qnt, who are funded generously on genesis
+var voters = []string{"v1", "v2", "alice", "bob"}
+
+// the errors of the keeper, as they are called in the spec
+var errorsOfSpec = map[string]error{
+	"inactive proposal": govtypes.ErrInactiveProposal,
+}
+
+// a bonded validator of gov.qnt
+type TestValidator struct {
+	tokens *big.Int
+	shares sdk.Dec
+}
+
+// an option of a vote with its weight
+type TestOption struct {
+	option string
+	weight sdk.Dec
+}
+
+// the proposal of the spec
+type TestProposal struct {
+	id      uint64
+	status  string
+	deposit *big.Int
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind      string
+	actor     string
+	validator string
+	amount    *big.Int
+	options   []TestOption
+	error     string
+}
+
+// the result of the last tally
+type TestResult struct {
+	passes       bool
+	burnDeposits bool
+	yes          *big.Int
+	abstain      *big.Int
+	no           *big.Int
+	noWithVeto   *big.Int
+}
+
+// a state of our testing state machine
+type TestState struct {
+	validators  map[string]TestValidator
+	delegations map[string]map[string]sdk.Dec
+	otherBonded *big.Int
+	proposal    TestProposal
+	lastAction  TestAction
+	result      TestResult
+}
+
+// parse the states in the ITF JSON format, as produced from govTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.validators = make(map[string]TestValidator)
+		entries, err := itf.Map(jsonState.Get("staking.validators"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.validators[e.Key.String()] = TestValidator{
+				tokens: harness.BigInt(t, e.Value.Get("tokens")),
+				shares: sdk.NewDecFromBigIntWithPrec(harness.Dec(t, e.Value.Get("shares")), sdk.Precision),
+			}
+		}
+		state.delegations = make(map[string]map[string]sdk.Dec)
+		entries, err = itf.Map(jsonState.Get("staking.delegations"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			inner, err := itf.Map(e.Value)
+			require.NoError(t, err)
+			state.delegations[e.Key.String()] = make(map[string]sdk.Dec)
+			for _, d := range inner {
+				state.delegations[e.Key.String()][d.Key.String()] = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, d.Value), sdk.Precision)
+			}
+		}
+		state.otherBonded = harness.BigInt(t, jsonState.Get("staking.otherBonded"))
+		state.proposal.id = harness.BigInt(t, jsonState.Get("proposal.id")).Uint64()
+		state.proposal.status = jsonState.Get("proposal.status").String()
+		state.proposal.deposit = harness.BigInt(t, jsonState.Get("proposal.deposit"))
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.actor = jsonState.Get("lastAction.actor").String()
+		state.lastAction.validator = jsonState.Get("lastAction.validator").String()
+		state.lastAction.amount = harness.BigInt(t, jsonState.Get("lastAction.amount"))
+		for _, o := range jsonState.Get("lastAction.options").Array() {
+			state.lastAction.options = append(state.lastAction.options, TestOption{
+				option: o.Get("option").String(),
+				weight: sdk.NewDecFromBigIntWithPrec(harness.Dec(t, o.Get("weight")), sdk.Precision),
+			})
+		}
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		state.result.passes = jsonState.Get("result.passes").Bool()
+		state.result.burnDeposits = jsonState.Get("result.burnDeposits").Bool()
+		state.result.yes = harness.BigInt(t, jsonState.Get("result.yes"))
+		state.result.abstain = harness.BigInt(t, jsonState.Get("result.abstain"))
+		state.result.no = harness.BigInt(t, jsonState.Get("result.no"))
+		state.result.noWithVeto = harness.BigInt(t, jsonState.Get("result.noWithVeto"))
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// SimApp with the funded voters, which is set up once for all traces, see simchain
+var sharedChain *simchain.Chain
+
+// the chain under test, together with the current proposal
+type testChain struct {
+	*simchain.Chain
+	proposalID uint64
+}
+
+// Reset the chain, whose voters are funded on setup, create the validators
+// with their self-delegations and bond them, as in the initial state of the
+// spec. Then submit the first proposal.
+func setupChain(t *testing.T, init TestState) *testChain {
+	if sharedChain == nil {
+		funds := sdk.NewCoins(sdk.NewCoin(denom, sdk.NewInt(1_000_000_000)))
+		balances := make(map[string]sdk.Coins)
+		for _, name := range voters {
+			balances[name] = funds
+		}
+		sharedChain = simchain.New(t, simchain.Config{
+			Modules:  []string{simchain.Staking, simchain.Gov},
+			Balances: balances,
+		})
+	}
+	sharedChain.Reset()
+	tstaking := teststaking.NewHelper(t, sharedChain.Ctx, sharedChain.Keepers.Staking)
+	for name, val := range init.validators {
+		tstaking.CreateValidator(simchain.ValAddr(name), ed25519.GenPrivKey().PubKey(), sdk.NewIntFromBigInt(val.tokens), true)
+	}
+	sharedChain.EndBlock()
+	chain := &testChain{Chain: sharedChain}
+	chain.submit(t)
+	return chain
+}
+
+// submit a proposal without messages
+func (c *testChain) submit(t *testing.T) {
+	proposal, err := c.Keepers.Gov.SubmitProposal(c.Ctx, []sdk.Msg{}, "")
+	require.NoError(t, err)
+	c.proposalID = proposal.Id
+}
+
+// burn tokens of a validator, as the staking keeper does on a slash
+func (c *testChain) slash(t *testing.T, name string, amount sdk.Int) {
+	validator, found := c.Keepers.Staking.GetValidator(c.Ctx, simchain.ValAddr(name))
+	require.True(t, found, "validator %s", name)
+	c.Keepers.Staking.RemoveValidatorTokens(c.Ctx, validator, amount)
+	coins := sdk.NewCoins(sdk.NewCoin(denom, amount))
+	require.NoError(t, c.Keepers.Bank.BurnCoins(c.Ctx, stakingtypes.BondedPoolName, coins))
+}
+
+// Tally the votes, and end the voting period with the verdict.
+// The keeper deletes the votes of the proposal.
+func (c *testChain) tally(t *testing.T) TestResult {
+	proposal, found := c.Keepers.Gov.GetProposal(c.Ctx, c.proposalID)
+	require.True(t, found, "proposal %d", c.proposalID)
+	passes, burnDeposits, results := c.Keepers.Gov.Tally(c.Ctx, proposal)
+	proposal.Status = v1.StatusRejected
+	if passes {
+		proposal.Status = v1.StatusPassed
+	}
+	c.Keepers.Gov.SetProposal(c.Ctx, proposal)
+	count := func(s string) *big.Int {
+		i, ok := new(big.Int).SetString(s, 10)
+		require.True(t, ok, "a tally count: %s", s)
+		return i
+	}
+	return TestResult{
+		passes:       passes,
+		burnDeposits: burnDeposits,
+		yes:          count(results.YesCount),
+		abstain:      count(results.AbstainCount),
+		no:           count(results.NoCount),
+		noWithVeto:   count(results.NoWithVetoCount),
+	}
+}
+
+// execute an action with the keepers and return the error of the keeper, if any
+func (c *testChain) executeAction(t *testing.T, a TestAction) error {
+	amount := sdk.NewIntFromBigInt(a.amount)
+	switch a.kind {
+	case "delegate":
+		validator, found := c.Keepers.Staking.GetValidator(c.Ctx, simchain.ValAddr(a.validator))
+		require.True(t, found, "validator %s", a.validator)
+		_, err := c.Keepers.Staking.Delegate(c.Ctx, simchain.Addr(a.actor), amount, stakingtypes.Unbonded, validator, true)
+		return err
+
+	case "slash":
+		c.slash(t, a.validator, amount)
+		return nil
+
+	case "deposit":
+		_, err := c.Keepers.Gov.AddDeposit(c.Ctx, c.proposalID, simchain.Addr(a.actor), sdk.NewCoins(sdk.NewCoin(denom, amount)))
+		return err
+
+	case "vote":
+		options := v1.WeightedVoteOptions{}
+		for _, o := range a.options {
+			options = append(options, &v1.WeightedVoteOption{
+				Option: v1.VoteOption(v1.VoteOption_value[o.option]),
+				Weight: o.weight.String(),
+			})
+		}
+		return c.Keepers.Gov.AddVote(c.Ctx, c.proposalID, simchain.Addr(a.actor), options, "")
+
+	case "submit":
+		c.submit(t)
+		return nil
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+		return nil
+	}
+}
+
+// compare the validators, the delegations, and the proposal with the state of the spec
+func (c *testChain) checkState(t *testing.T, s TestState) {
+	bonded := new(big.Int).Set(s.otherBonded)
+	for name, expected := range s.validators {
+		validator, found := c.Keepers.Staking.GetValidator(c.Ctx, simchain.ValAddr(name))
+		require.True(t, found, "validator %s", name)
+		assert.Equal(t, expected.tokens.String(), validator.Tokens.String(), "tokens of %s", name)
+		assert.Equal(t, expected.shares.String(), validator.DelegatorShares.String(), "shares of %s", name)
+		bonded.Add(bonded, expected.tokens)
+	}
+	assert.Equal(t, bonded.String(), c.Keepers.Staking.TotalBondedTokens(c.Ctx).String(), "bonded tokens")
+	for delegator, byValidator := range s.delegations {
+		for name, expected := range byValidator {
+			delegation, found := c.Keepers.Staking.GetDelegation(c.Ctx, simchain.Addr(delegator), simchain.ValAddr(name))
+			require.True(t, found, "delegation of %s to %s", delegator, name)
+			assert.Equal(t, expected.String(), delegation.Shares.String(), "shares of %s in %s", delegator, name)
+		}
+	}
+
+	assert.Equal(t, s.proposal.id, c.proposalID, "proposal id")
+	proposal, found := c.Keepers.Gov.GetProposal(c.Ctx, c.proposalID)
+	require.True(t, found, "proposal %d", c.proposalID)
+	assert.Equal(t, s.proposal.status, proposal.Status.String(), "proposal status")
+	deposit := sdk.NewCoins(proposal.TotalDeposit...).AmountOf(denom)
+	assert.Equal(t, s.proposal.deposit.String(), deposit.String(), "total deposit")
+}
+
+// compare the result of a tally with the spec
+func checkResult(t *testing.T, expected, actual TestResult) {
+	assert.Equal(t, expected.passes, actual.passes, "passes")
+	assert.Equal(t, expected.burnDeposits, actual.burnDeposits, "burn deposits")
+	assert.Equal(t, expected.yes.String(), actual.yes.String(), "yes")
+	assert.Equal(t, expected.abstain.String(), actual.abstain.String(), "abstain")
+	assert.Equal(t, expected.no.String(), actual.no.String(), "no")
+	assert.Equal(t, expected.noWithVeto.String(), actual.noWithVeto.String(), "no with veto")
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	chain := setupChain(t, states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s_%s", i, s.lastAction.kind, s.lastAction.actor, s.lastAction.validator)
+		ok := t.Run(description, func(t *testing.T) {
+			switch {
+			case i == 0:
+			case s.lastAction.kind == "tally":
+				checkResult(t, s.result, chain.tally(t))
+			default:
+				err := chain.executeAction(t, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err, "the action should succeed")
+				} else {
+					require.True(t, errors.Is(err, errorsOfSpec[s.lastAction.error]),
+						"expected the error %q, found %v", s.lastAction.error, err)
+				}
+			}
+			chain.checkState(t, s)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
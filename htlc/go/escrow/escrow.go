@@ -0,0 +1,175 @@
+// Package escrow is a small implementation of an escrow with
+// hash-timelocks, as used for atomic swaps. A sender locks tokens for a
+// receiver under the SHA-256 hash of a secret and a timelock. Before the
+// timelock, anybody who knows the secret may claim the tokens for the
+// receiver. From the timelock on, anybody may refund the tokens to the
+// sender. A lock is settled at most once, by whichever comes first.
+//
+// The escrow reads the time from its Clock.
+package escrow
+
+import (
+	"crypto/sha256"
+	"errors"
+	"time"
+)
+
+// The errors of the operations, which change nothing when they fail.
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidTimelock   = errors.New("timelock in the past")
+	ErrAlreadyClaimed    = errors.New("already claimed")
+	ErrAlreadyRefunded   = errors.New("already refunded")
+	ErrExpired           = errors.New("lock expired")
+	ErrNotExpired        = errors.New("lock not expired")
+	ErrWrongSecret       = errors.New("wrong secret")
+	ErrUnknownLock       = errors.New("unknown lock")
+)
+
+// Clock tells the time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Status is the state of a lock.
+type Status int
+
+const (
+	Open Status = iota
+	Claimed
+	Refunded
+)
+
+// Hash is the hash of a secret.
+type Hash [sha256.Size]byte
+
+// HashOf returns the hash of a secret.
+func HashOf(secret []byte) Hash {
+	return sha256.Sum256(secret)
+}
+
+// Lock is a lock on the tokens of a sender.
+type Lock struct {
+	Sender   string
+	Receiver string
+	Amount   uint64
+	Hashlock Hash
+	Timelock time.Time
+	Status   Status
+}
+
+// Escrow keeps the accounts and the locks.
+type Escrow struct {
+	clock    Clock
+	balances map[string]uint64
+	locks    map[uint64]*Lock
+	nextID   uint64
+	revealed map[Hash][]byte
+}
+
+// New returns an escrow without locks, in which the accounts hold their balances.
+func New(clock Clock, balances map[string]uint64) *Escrow {
+	e := &Escrow{
+		clock:    clock,
+		balances: make(map[string]uint64, len(balances)),
+		locks:    make(map[uint64]*Lock),
+		nextID:   1,
+		revealed: make(map[Hash][]byte),
+	}
+	for owner, amount := range balances {
+		e.balances[owner] = amount
+	}
+	return e
+}
+
+// Balance returns the tokens of an owner.
+func (e *Escrow) Balance(owner string) uint64 {
+	return e.balances[owner]
+}
+
+// Locks returns all locks, settled or not, by their ID.
+func (e *Escrow) Locks() map[uint64]Lock {
+	locks := make(map[uint64]Lock, len(e.locks))
+	for id, l := range e.locks {
+		locks[id] = *l
+	}
+	return locks
+}
+
+// Revealed returns the secret of a hash, if a claim revealed it.
+func (e *Escrow) Revealed(h Hash) ([]byte, bool) {
+	secret, found := e.revealed[h]
+	return secret, found
+}
+
+// Lock locks tokens of a sender for a receiver, and returns the ID of the lock.
+func (e *Escrow) Lock(sender, receiver string, amount uint64, hashlock Hash, timelock time.Time) (uint64, error) {
+	if e.balances[sender] < amount {
+		return 0, ErrInsufficientFunds
+	}
+	if !timelock.After(e.clock.Now()) {
+		return 0, ErrInvalidTimelock
+	}
+	e.balances[sender] -= amount
+	id := e.nextID
+	e.locks[id] = &Lock{
+		Sender:   sender,
+		Receiver: receiver,
+		Amount:   amount,
+		Hashlock: hashlock,
+		Timelock: timelock,
+		Status:   Open,
+	}
+	e.nextID++
+	return id, nil
+}
+
+// the lock of an ID, if it is open
+func (e *Escrow) open(id uint64) (*Lock, error) {
+	l, found := e.locks[id]
+	if !found {
+		return nil, ErrUnknownLock
+	}
+	switch l.Status {
+	case Claimed:
+		return nil, ErrAlreadyClaimed
+	case Refunded:
+		return nil, ErrAlreadyRefunded
+	}
+	return l, nil
+}
+
+// Claim pays the tokens of an open lock to its receiver, if the secret
+// matches the hashlock and the timelock is not reached, and reveals the secret.
+func (e *Escrow) Claim(id uint64, secret []byte) error {
+	l, err := e.open(id)
+	if err != nil {
+		return err
+	}
+	if !e.clock.Now().Before(l.Timelock) {
+		return ErrExpired
+	}
+	h := HashOf(secret)
+	if h != l.Hashlock {
+		return ErrWrongSecret
+	}
+	l.Status = Claimed
+	e.balances[l.Receiver] += l.Amount
+	e.revealed[h] = append([]byte(nil), secret...)
+	return nil
+}
+
+// Refund pays the tokens of an open lock back to its sender, once the
+// timelock is reached.
+func (e *Escrow) Refund(id uint64) error {
+	l, err := e.open(id)
+	if err != nil {
+		return err
+	}
+	if e.clock.Now().Before(l.Timelock) {
+		return ErrNotExpired
+	}
+	l.Status = Refunded
+	e.balances[l.Sender] += l.Amount
+	return nil
+}
@@ -0,0 +1,241 @@
+// A test harness that replays the traces of htlcTest.qnt against the escrow
+// with hash-timelocks in the package escrow.
+//
+// The harness owns the clock of the escrow, which shows the time of the
+// spec, so the traces schedule when the timelocks are reached. The secrets of
+// the spec are strings, and their hashes are the SHA-256 hashes of the
+// strings. Every state of a trace records the action that led to it in
+// lastAction: a lock, a claim with a secret, a refund, or time advancing. We
+// apply the action and check its error, then we compare the balances, the
+// locks, and the revealed secrets with the spec.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/htlc/escrow"
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the time 0 of the spec
+var epoch = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// the time of the spec as a time.Time
+func at(t int64) time.Time {
+	return epoch.Add(time.Duration(t) * time.Second)
+}
+
+// the secrets of htlc.qnt by their hashes, see HASH
+var secretOfHash = map[string]string{
+	"h1": "s1",
+	"h2": "s2",
+	"h3": "s3",
+}
+
+// the hash of a hash of the spec
+func hashOf(hashlock string) escrow.Hash {
+	return escrow.HashOf([]byte(secretOfHash[hashlock]))
+}
+
+// the states of the locks, as they are written in the spec
+var statusOfSpec = map[string]escrow.Status{
+	"OPEN":     escrow.Open,
+	"CLAIMED":  escrow.Claimed,
+	"REFUNDED": escrow.Refunded,
+}
+
+// the errors of the actions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"insufficient funds":   escrow.ErrInsufficientFunds,
+	"timelock in the past": escrow.ErrInvalidTimelock,
+	"already claimed":      escrow.ErrAlreadyClaimed,
+	"already refunded":     escrow.ErrAlreadyRefunded,
+	"lock expired":         escrow.ErrExpired,
+	"lock not expired":     escrow.ErrNotExpired,
+	"wrong secret":         escrow.ErrWrongSecret,
+}
+
+// the clock of the escrow, which shows the time of the spec
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind     string
+	sender   string
+	receiver string
+	amount   uint64
+	hashlock string
+	timelock int64
+	id       uint64
+	secret   string
+	error    string
+}
+
+// the escrow of htlc.qnt
+type TestEscrow struct {
+	balances map[string]uint64
+	locks    map[uint64]escrow.Lock
+	time     int64
+	revealed map[string]bool
+}
+
+// a state of our testing state machine
+type TestState struct {
+	escrow     TestEscrow
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from htlcTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.escrow.balances = make(map[string]uint64)
+		entries, err := itf.Map(jsonState.Get("escrow.balances"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.escrow.balances[e.Key.String()] = harness.Uint64(t, e.Value)
+		}
+		state.escrow.locks = make(map[uint64]escrow.Lock)
+		entries, err = itf.Map(jsonState.Get("escrow.locks"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			status, found := statusOfSpec[e.Value.Get("status").String()]
+			require.True(t, found, "unknown status: %s", e.Value.Get("status"))
+			state.escrow.locks[harness.Uint64(t, e.Key)] = escrow.Lock{
+				Sender:   e.Value.Get("sender").String(),
+				Receiver: e.Value.Get("receiver").String(),
+				Amount:   harness.Uint64(t, e.Value.Get("amount")),
+				Hashlock: hashOf(e.Value.Get("hashlock").String()),
+				Timelock: at(harness.Int64(t, e.Value.Get("timelock"))),
+				Status:   status,
+			}
+		}
+		state.escrow.time = harness.Int64(t, jsonState.Get("escrow.time"))
+		state.escrow.revealed = make(map[string]bool)
+		elems, err := itf.Set(jsonState.Get("escrow.revealed"))
+		require.NoError(t, err)
+		for _, s := range elems {
+			state.escrow.revealed[s.String()] = true
+		}
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.sender = jsonState.Get("lastAction.sender").String()
+		state.lastAction.receiver = jsonState.Get("lastAction.receiver").String()
+		state.lastAction.amount = harness.Uint64(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.hashlock = jsonState.Get("lastAction.hashlock").String()
+		state.lastAction.timelock = harness.Int64(t, jsonState.Get("lastAction.timelock"))
+		state.lastAction.id = harness.Uint64(t, jsonState.Get("lastAction.id"))
+		state.lastAction.secret = jsonState.Get("lastAction.secret").String()
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// apply an action to the escrow and return its error
+func executeAction(e *escrow.Escrow, clock *testClock, a TestAction) error {
+	switch a.kind {
+	case "lock":
+		_, err := e.Lock(a.sender, a.receiver, a.amount, hashOf(a.hashlock), at(a.timelock))
+		return err
+
+	case "claim":
+		return e.Claim(a.id, []byte(a.secret))
+
+	case "refund":
+		return e.Refund(a.id)
+
+	case "elapse":
+		clock.now = clock.now.Add(time.Duration(a.amount) * time.Second)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown action: %s", a.kind)
+	}
+}
+
+// compare the escrow with the state of the spec
+func checkState(t *testing.T, e *escrow.Escrow, clock *testClock, expected TestEscrow) {
+	for owner, amount := range expected.balances {
+		assert.Equal(t, amount, e.Balance(owner), "balance of %s", owner)
+	}
+	locks := e.Locks()
+	require.Len(t, locks, len(expected.locks), "locks")
+	for id, l := range expected.locks {
+		assert.Equal(t, l, locks[id], "lock %d", id)
+	}
+	assert.Equal(t, at(expected.time), clock.now, "time")
+	for _, secret := range secretOfHash {
+		revealed, found := e.Revealed(escrow.HashOf([]byte(secret)))
+		assert.Equal(t, expected.revealed[secret], found, "whether %s is revealed", secret)
+		if found {
+			assert.Equal(t, secret, string(revealed), "the revealed secret")
+		}
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	clock := &testClock{now: at(states[0].escrow.time)}
+	e := escrow.New(clock, states[0].escrow.balances)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s%d", i, s.lastAction.kind, s.lastAction.id)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := executeAction(e, clock, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err)
+				} else {
+					expected, found := errorsOfSpec[s.lastAction.error]
+					require.True(t, found, "unknown error: %s", s.lastAction.error)
+					require.True(t, errors.Is(err, expected), "expected %v, found %v", expected, err)
+				}
+			}
+			checkState(t, e, clock, s.escrow)
+		})
+		if !ok {
+			// the states of the escrow and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// A claim with the right secret loses the race to a refund, see
+// noClaimAfterRefund in htlcTest.qnt.
+func TestClaimAfterRefund(t *testing.T) {
+	ExecFromItf(t, tracePath("claimAfterRefund.itf.json"))
+}
@@ -0,0 +1,255 @@
+// A test harness that replays the traces of iavlTest.qnt against a mutable
+// tree of IAVL v0.19.4 over an in-memory database.
+//
+// Every state of a trace records the operation that led to it in lastAction.
+// We execute the operation on the tree and compare the value that it returns,
+// or its error, with the spec. Then we compare the working tree, the available
+// versions, and the key-value pairs of every version with the state of the
+// spec, by iterating over the trees.
+//
+// The spec does not compute root hashes, but assigns hash identifiers to the
+// versions. We record the root hash of every identifier when it is first seen,
+// and check that the versions with the same identifier have the same root hash,
+// and that versions with different key-value pairs have different root hashes.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/cosmos/iavl"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.19.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the number of nodes that the tree caches, which is small to exercise the database
+const cacheSize = 4
+
+// a saved version of iavl.qnt
+type TestVersion struct {
+	kvs  map[string]string
+	hash int64
+}
+
+// an operation, as recorded in lastAction
+type TestAction struct {
+	kind    string
+	key     string
+	value   string
+	version int64
+	result  string
+	error   string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	working    map[string]string
+	versions   map[int64]TestVersion
+	latest     int64
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from iavlTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.working = parseKVs(t, jsonState.Get("tree.working"))
+		state.versions = make(map[int64]TestVersion)
+		entries, err := itf.Map(jsonState.Get("tree.versions"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.versions[harness.Int64(t, e.Key)] = TestVersion{
+				kvs:  parseKVs(t, e.Value.Get("kvs")),
+				hash: harness.Int64(t, e.Value.Get("hash")),
+			}
+		}
+		state.latest = harness.Int64(t, jsonState.Get("tree.latest"))
+		state.lastAction = TestAction{
+			kind:    jsonState.Get("lastAction.kind").String(),
+			key:     jsonState.Get("lastAction.key").String(),
+			value:   jsonState.Get("lastAction.value").String(),
+			version: harness.Int64(t, jsonState.Get("lastAction.version")),
+			result:  jsonState.Get("lastAction.result").String(),
+			error:   jsonState.Get("lastAction.error").String(),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a map of key-value pairs
+func parseKVs(t *testing.T, obj gjson.Result) map[string]string {
+	entries, err := itf.Map(obj)
+	require.NoError(t, err)
+	kvs := make(map[string]string)
+	for _, e := range entries {
+		kvs[e.Key.String()] = e.Value.String()
+	}
+	return kvs
+}
+
+// the tree under test, its database, and the root hashes of the hash identifiers
+type testTree struct {
+	db     dbm.DB
+	tree   *iavl.MutableTree
+	hashes map[int64][]byte
+}
+
+// create an empty tree over an in-memory database
+func setupTree(t *testing.T) *testTree {
+	db := dbm.NewMemDB()
+	tree, err := iavl.NewMutableTree(db, cacheSize, false)
+	require.NoError(t, err)
+	return &testTree{db: db, tree: tree, hashes: make(map[int64][]byte)}
+}
+
+// reopen the tree from its database, which loads the latest version
+func (tt *testTree) reload(t *testing.T) {
+	tree, err := iavl.NewMutableTree(tt.db, cacheSize, false)
+	require.NoError(t, err)
+	_, err = tree.Load()
+	require.NoError(t, err)
+	tt.tree = tree
+}
+
+// execute an operation on the tree and compare its result with the spec
+func (tt *testTree) execute(t *testing.T, a TestAction) {
+	switch a.kind {
+	case "set":
+		updated, err := tt.tree.Set([]byte(a.key), []byte(a.value))
+		require.NoError(t, err)
+		assert.Equal(t, a.result != "", updated, "whether %s existed", a.key)
+
+	case "remove":
+		value, removed, err := tt.tree.Remove([]byte(a.key))
+		require.NoError(t, err)
+		assert.Equal(t, a.result != "", removed, "whether %s existed", a.key)
+		assert.Equal(t, a.result, string(value), "removed value of %s", a.key)
+
+	case "get":
+		value, err := tt.tree.Get([]byte(a.key))
+		require.NoError(t, err)
+		assert.Equal(t, a.result, string(value), "value of %s", a.key)
+
+	case "getVersioned":
+		value, err := tt.tree.GetVersioned([]byte(a.key), a.version)
+		require.NoError(t, err)
+		assert.Equal(t, a.result, string(value), "value of %s at version %d", a.key, a.version)
+
+	case "saveVersion":
+		_, version, err := tt.tree.SaveVersion()
+		require.NoError(t, err)
+		assert.Equal(t, a.version, version, "saved version")
+
+	case "deleteVersion":
+		err := tt.tree.DeleteVersion(a.version)
+		if a.error == "" {
+			require.NoError(t, err, "deleting version %d should succeed", a.version)
+		} else {
+			require.ErrorContains(t, err, a.error, "deleting version %d", a.version)
+		}
+
+	case "rollback":
+		tt.tree.Rollback()
+
+	case "reload":
+		tt.reload(t)
+
+	default:
+		require.Fail(t, "unknown operation: "+a.kind)
+	}
+}
+
+// collect the key-value pairs of a tree
+func collect(t *testing.T, iterate func(func(key, value []byte) bool) (bool, error)) map[string]string {
+	kvs := make(map[string]string)
+	_, err := iterate(func(key, value []byte) bool {
+		kvs[string(key)] = string(value)
+		return false
+	})
+	require.NoError(t, err)
+	return kvs
+}
+
+// compare the tree with the state of the spec
+func (tt *testTree) checkState(t *testing.T, s TestState) {
+	assert.Equal(t, s.latest, tt.tree.Version(), "latest version")
+	assert.Equal(t, s.working, collect(t, tt.tree.Iterate), "working tree")
+
+	expectedVersions := make([]int, 0, len(s.versions))
+	for v := range s.versions {
+		expectedVersions = append(expectedVersions, int(v))
+	}
+	sort.Ints(expectedVersions)
+	assert.Equal(t, expectedVersions, tt.tree.AvailableVersions(), "available versions")
+
+	hashes := make(map[int64][]byte)
+	for v, expected := range s.versions {
+		immutable, err := tt.tree.GetImmutable(v)
+		require.NoError(t, err, "version %d", v)
+		assert.Equal(t, expected.kvs, collect(t, immutable.Iterate), "key-value pairs of version %d", v)
+		hash, err := immutable.Hash()
+		require.NoError(t, err)
+		if recorded, found := tt.hashes[expected.hash]; found {
+			assert.Equal(t, recorded, hash, "root hash of version %d", v)
+		} else {
+			tt.hashes[expected.hash] = hash
+		}
+		hashes[v] = hash
+	}
+	for v, left := range s.versions {
+		for w, right := range s.versions {
+			if !assert.ObjectsAreEqual(left.kvs, right.kvs) {
+				assert.NotEqual(t, hashes[v], hashes[w], "root hashes of versions %d and %d", v, w)
+			}
+		}
+	}
+}
+
+// execute all operations of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	tt := setupTree(t)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s_%d", i, s.lastAction.kind, s.lastAction.key, s.lastAction.version)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				tt.execute(t, s.lastAction)
+			}
+			tt.checkState(t, s)
+		})
+		if !ok {
+			// the states of the tree and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
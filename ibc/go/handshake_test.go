@@ -0,0 +1,227 @@
+// A test harness that replays the traces of channelTest.qnt against the
+// channel keeper of ibc-go v6.0.0.
+//
+// The chains "A" and "B" of the spec are two test chains of ibctesting, which
+// are connected before the trace starts. Every state of a trace records the
+// handshake step that led to it in lastStep. We call the corresponding
+// function of the channel keeper directly, since the message server of
+// ibctesting requires every message to succeed, whereas the traces contain
+// failing steps as well. Then we compare the channel ends with the spec.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	clienttypes "github.com/cosmos/ibc-go/v6/modules/core/02-client/types"
+	channeltypes "github.com/cosmos/ibc-go/v6/modules/core/04-channel/types"
+	host "github.com/cosmos/ibc-go/v6/modules/core/24-host"
+	ibctesting "github.com/cosmos/ibc-go/v6/testing"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v6.0.0"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// a handshake step, as recorded in lastStep
+type TestStep struct {
+	kind  string
+	chain string
+	// whether the step is expected to fail
+	error bool
+}
+
+// a state of our testing state machine
+type TestState struct {
+	// the state of the channel end on every chain
+	ends     map[string]string
+	lastStep TestStep
+}
+
+// parse the states in the ITF JSON format, as produced from channelTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.ends = make(map[string]string)
+		entries, err := itf.Map(jsonState.Get("ends"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.ends[e.Key.String()] = e.Value.String()
+		}
+		state.lastStep.kind = jsonState.Get("lastStep.kind").String()
+		state.lastStep.chain = jsonState.Get("lastStep.chain").String()
+		state.lastStep.error = jsonState.Get("lastStep.error").Bool()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the states of channel.qnt
+var channelStates = map[string]channeltypes.State{
+	"UNINITIALIZED": channeltypes.UNINITIALIZED,
+	"INIT":          channeltypes.INIT,
+	"TRYOPEN":       channeltypes.TRYOPEN,
+	"OPEN":          channeltypes.OPEN,
+}
+
+// two chains with an open connection, and no channel yet
+func setupPath(t *testing.T) map[string]*ibctesting.Endpoint {
+	coordinator := ibctesting.NewCoordinator(t, 2)
+	chainA := coordinator.GetChain(ibctesting.GetChainID(1))
+	chainB := coordinator.GetChain(ibctesting.GetChainID(2))
+	path := ibctesting.NewPath(chainA, chainB)
+	coordinator.SetupConnections(path)
+	return map[string]*ibctesting.Endpoint{"A": path.EndpointA, "B": path.EndpointB}
+}
+
+// the proof of the counterparty channel end, after updating the client of the endpoint
+func proveCounterparty(t *testing.T, endpoint *ibctesting.Endpoint) ([]byte, clienttypes.Height) {
+	require.NoError(t, endpoint.UpdateClient())
+	counterparty := endpoint.Counterparty
+	channelKey := host.ChannelKey(counterparty.ChannelConfig.PortID, counterparty.ChannelID)
+	return counterparty.Chain.QueryProof(channelKey)
+}
+
+// Execute a handshake step with the channel keeper. As in the message server,
+// the channel is written only when the step succeeds.
+func executeStep(t *testing.T, endpoint *ibctesting.Endpoint, step TestStep) error {
+	chain := endpoint.Chain
+	keeper := chain.App.GetIBCKeeper().ChannelKeeper
+	config, counterparty := endpoint.ChannelConfig, endpoint.Counterparty
+	hops := []string{endpoint.ConnectionID}
+	var err error
+	switch step.kind {
+	case "chanOpenInit":
+		cacheCtx, write := chain.GetContext().CacheContext()
+		portCap := chain.GetPortCapability(config.PortID)
+		remote := channeltypes.NewCounterparty(counterparty.ChannelConfig.PortID, "")
+		var channelID string
+		channelID, _, err = keeper.ChanOpenInit(cacheCtx, config.Order, hops, config.PortID, portCap, remote, config.Version)
+		if err == nil {
+			keeper.WriteOpenInitChannel(cacheCtx, config.PortID, channelID, config.Order, hops, remote, config.Version)
+			write()
+			endpoint.ChannelID = channelID
+		}
+
+	case "chanOpenTry":
+		proof, height := proveCounterparty(t, endpoint)
+		cacheCtx, write := chain.GetContext().CacheContext()
+		portCap := chain.GetPortCapability(config.PortID)
+		remote := channeltypes.NewCounterparty(counterparty.ChannelConfig.PortID, counterparty.ChannelID)
+		var channelID string
+		channelID, _, err = keeper.ChanOpenTry(cacheCtx, config.Order, hops, config.PortID, portCap, remote,
+			counterparty.ChannelConfig.Version, proof, height)
+		if err == nil {
+			keeper.WriteOpenTryChannel(cacheCtx, config.PortID, channelID, config.Order, hops, remote, config.Version)
+			write()
+			endpoint.ChannelID = channelID
+		}
+
+	case "chanOpenAck":
+		proof, height := proveCounterparty(t, endpoint)
+		cacheCtx, write := chain.GetContext().CacheContext()
+		// the capability is missing when the chain has no channel end, which the keeper reports
+		chanCap, _ := chain.App.GetScopedIBCKeeper().GetCapability(cacheCtx,
+			host.ChannelCapabilityPath(config.PortID, endpoint.ChannelID))
+		err = keeper.ChanOpenAck(cacheCtx, config.PortID, endpoint.ChannelID, chanCap,
+			counterparty.ChannelConfig.Version, counterparty.ChannelID, proof, height)
+		if err == nil {
+			keeper.WriteOpenAckChannel(cacheCtx, config.PortID, endpoint.ChannelID,
+				counterparty.ChannelConfig.Version, counterparty.ChannelID)
+			write()
+		}
+
+	case "chanOpenConfirm":
+		proof, height := proveCounterparty(t, endpoint)
+		cacheCtx, write := chain.GetContext().CacheContext()
+		chanCap, _ := chain.App.GetScopedIBCKeeper().GetCapability(cacheCtx,
+			host.ChannelCapabilityPath(config.PortID, endpoint.ChannelID))
+		err = keeper.ChanOpenConfirm(cacheCtx, config.PortID, endpoint.ChannelID, chanCap, proof, height)
+		if err == nil {
+			keeper.WriteOpenConfirmChannel(cacheCtx, config.PortID, endpoint.ChannelID)
+			write()
+		}
+
+	default:
+		return fmt.Errorf("unknown step: %s", step.kind)
+	}
+	// commit the block, so the counterparty can prove the new state
+	chain.Coordinator.CommitBlock(chain)
+	return err
+}
+
+// compare the channel ends of the keepers with the ends of the spec
+func checkState(t *testing.T, endpoints map[string]*ibctesting.Endpoint, s TestState) {
+	for name, state := range s.ends {
+		endpoint := endpoints[name]
+		expected, found := channelStates[state]
+		require.True(t, found, "unknown state: %s", state)
+		if expected == channeltypes.UNINITIALIZED {
+			assert.Empty(t, endpoint.ChannelID, "chain %s should have no channel end", name)
+			continue
+		}
+		require.NotEmpty(t, endpoint.ChannelID, "chain %s should have a channel end", name)
+		channel := endpoint.GetChannel()
+		assert.Equal(t, expected, channel.State, "state of the channel end on %s", name)
+		// an end learns the channel ID of its counterparty in ChanOpenTry or ChanOpenAck
+		if expected != channeltypes.INIT {
+			assert.Equal(t, endpoint.Counterparty.ChannelID, channel.Counterparty.ChannelId,
+				"counterparty of the channel end on %s", name)
+		}
+	}
+}
+
+// execute all steps of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	endpoints := setupPath(t)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastStep.kind, s.lastStep.chain)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				endpoint, found := endpoints[s.lastStep.chain]
+				require.True(t, found, "unknown chain: %s", s.lastStep.chain)
+				err := executeStep(t, endpoint, s.lastStep)
+				if s.lastStep.error {
+					require.Error(t, err, "the step should fail")
+				} else {
+					require.NoError(t, err, "the step should succeed")
+				}
+			}
+			checkState(t, endpoints, s)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOpenedByA(t *testing.T) {
+	ExecFromItf(t, tracePath("openedByA.itf.json"))
+}
+
+func TestOpenedByB(t *testing.T) {
+	ExecFromItf(t, tracePath("openedByB.itf.json"))
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,471 @@
+// A test harness that replays the traces of ibcDenomTest.qnt against the
+// transfer module of ibc-go v6.0.0.
+//
+// The chains A, B, and C are three test chains of ibctesting, connected
+// pairwise by transfer channels, which we open in the order of CHANNELS in
+// the spec, so that ibc-go gives them the same identifiers. The users hold
+// the native tokens of the spec. A transfer goes through the message server
+// of the transfer module in a cached context, as it may fail. We call the
+// message server directly, as a module would, because the native tokens
+// with slashes in their names do not pass MsgTransfer.ValidateBasic in v6.
+// The relayer delivers a packet and its acknowledgement through the
+// endpoints of ibctesting.
+//
+// The spec keeps the full path of a voucher instead of its hash, so we hash
+// the path here, independently of ibc-go, to find the balances of the
+// vouchers: ibc/ and the SHA-256 of the path in upper-case hex. Then we
+// compare all balances, including the escrow accounts of the channels, and
+// the denomination traces that every chain stores, with the spec.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	tmcrypto "github.com/tendermint/tendermint/crypto"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+
+	transfertypes "github.com/cosmos/ibc-go/v6/modules/apps/transfer/types"
+	clienttypes "github.com/cosmos/ibc-go/v6/modules/core/02-client/types"
+	channeltypes "github.com/cosmos/ibc-go/v6/modules/core/04-channel/types"
+	ibctesting "github.com/cosmos/ibc-go/v6/testing"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v6.0.0"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// The timeout of every packet, relative to the block time of the sender. It
+// must be long enough for the blocks of ibctesting, which add 10 seconds
+// each, not to time out a packet over a trace, as the spec has no timeouts.
+const relativeTimeout = 24 * time.Hour
+
+// the chains of the spec, and the test chains of ibctesting that they are
+var chainIDs = map[string]string{
+	"A": ibctesting.GetChainID(1),
+	"B": ibctesting.GetChainID(2),
+	"C": ibctesting.GetChainID(3),
+}
+
+// the channels of ibcDenomTest.qnt, in the order in which we open them,
+// with the identifiers of the channel ends, see CHANNELS
+var channels = []struct {
+	chainA, channelA string
+	chainB, channelB string
+}{
+	{"A", "channel-0", "B", "channel-0"},
+	{"B", "channel-1", "C", "channel-0"},
+	{"A", "channel-1", "C", "channel-1"},
+}
+
+// the errors of the transfers, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"insufficient funds":           sdkerrors.ErrInsufficientFunds,
+	"denomination trace not found": transfertypes.ErrTraceNotFound,
+}
+
+// the address of a name, as in simchain.Addr
+func addr(name string) sdk.AccAddress {
+	return sdk.AccAddress(tmcrypto.AddressHash([]byte(name)))
+}
+
+// a denomination of ibcDenom.qnt: a voucher, or a native token
+type TestDenom struct {
+	ibc  bool
+	path []string
+}
+
+// the full path of the denomination
+func (d TestDenom) FullPath() string {
+	return strings.Join(d.path, "/")
+}
+
+// the denomination in the bank: ibc/{hash} of the full path for a voucher,
+// and the full path itself otherwise
+func (d TestDenom) String() string {
+	if !d.ibc {
+		return d.FullPath()
+	}
+	hash := sha256.Sum256([]byte(d.FullPath()))
+	return transfertypes.DenomPrefix + "/" + strings.ToUpper(hex.EncodeToString(hash[:]))
+}
+
+// a packet in flight
+type TestPacket struct {
+	srcChain   string
+	srcChannel string
+	dstChain   string
+	dstChannel string
+	denom      []string
+	amount     int64
+	sender     string
+	receiver   string
+}
+
+// a chain of ibcDenom.qnt, with the balances that are not zero
+type TestChain struct {
+	balances map[string]sdk.Coins
+	escrow   map[string]sdk.Coins
+	traces   []transfertypes.DenomTrace
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind     string
+	chain    string
+	to       string
+	sender   string
+	receiver string
+	denom    TestDenom
+	amount   int64
+	packet   uint64
+	ack      bool
+	error    string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	chains     map[string]TestChain
+	packets    map[uint64]TestPacket
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from ibcDenomTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.chains = make(map[string]TestChain)
+		entries, err := itf.Map(jsonState.Get("chains"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.chains[e.Key.String()] = parseChain(t, e.Value)
+		}
+		state.packets = make(map[uint64]TestPacket)
+		entries, err = itf.Map(jsonState.Get("packets"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.packets[harness.Uint64(t, e.Key)] = parsePacket(t, e.Value)
+		}
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.chain = jsonState.Get("lastAction.chain").String()
+		state.lastAction.to = jsonState.Get("lastAction.to").String()
+		state.lastAction.sender = jsonState.Get("lastAction.sender").String()
+		state.lastAction.receiver = jsonState.Get("lastAction.receiver").String()
+		state.lastAction.denom = parseDenom(jsonState.Get("lastAction.denom"))
+		state.lastAction.amount = harness.Int64(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.packet = harness.Uint64(t, jsonState.Get("lastAction.packet"))
+		state.lastAction.ack = jsonState.Get("lastAction.ack").Bool()
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a list of strings
+func parseStrings(obj gjson.Result) []string {
+	var strs []string
+	for _, s := range obj.Array() {
+		strs = append(strs, s.String())
+	}
+	return strs
+}
+
+// parse a denomination of ibcDenom.qnt
+func parseDenom(obj gjson.Result) TestDenom {
+	return TestDenom{ibc: obj.Get("ibc").Bool(), path: parseStrings(obj.Get("path"))}
+}
+
+// parse the balances of an account by denomination, leaving out the zeros
+func parseCoins(t *testing.T, obj gjson.Result) sdk.Coins {
+	entries, err := itf.Map(obj)
+	require.NoError(t, err)
+	coins := sdk.NewCoins()
+	for _, e := range entries {
+		coins = coins.Add(sdk.NewInt64Coin(parseDenom(e.Key).String(), harness.Int64(t, e.Value)))
+	}
+	return coins
+}
+
+// parse a chain of ibcDenom.qnt
+func parseChain(t *testing.T, obj gjson.Result) TestChain {
+	c := TestChain{balances: make(map[string]sdk.Coins), escrow: make(map[string]sdk.Coins)}
+	entries, err := itf.Map(obj.Get("balances"))
+	require.NoError(t, err)
+	for _, e := range entries {
+		c.balances[e.Key.String()] = parseCoins(t, e.Value)
+	}
+	entries, err = itf.Map(obj.Get("escrow"))
+	require.NoError(t, err)
+	for _, e := range entries {
+		c.escrow[e.Key.String()] = parseCoins(t, e.Value)
+	}
+	traces, err := itf.Set(obj.Get("traces"))
+	require.NoError(t, err)
+	for _, trace := range traces {
+		c.traces = append(c.traces, transfertypes.DenomTrace{
+			Path:      strings.Join(parseStrings(trace.Get("path")), "/"),
+			BaseDenom: strings.Join(parseStrings(trace.Get("baseDenom")), "/"),
+		})
+	}
+	return c
+}
+
+// parse a packet of ibcDenom.qnt
+func parsePacket(t *testing.T, obj gjson.Result) TestPacket {
+	return TestPacket{
+		srcChain:   obj.Get("srcChain").String(),
+		srcChannel: obj.Get("srcChannel").String(),
+		dstChain:   obj.Get("dstChain").String(),
+		dstChannel: obj.Get("dstChannel").String(),
+		denom:      parseStrings(obj.Get("denom")),
+		amount:     harness.Int64(t, obj.Get("amount")),
+		sender:     obj.Get("sender").String(),
+		receiver:   obj.Get("receiver").String(),
+	}
+}
+
+// the chains, their channel ends, and the packets in flight by their number in the spec
+type system struct {
+	coordinator *ibctesting.Coordinator
+	chains      map[string]*ibctesting.TestChain
+	// the end of the channel of a chain to another chain
+	ends    map[string]map[string]*ibctesting.Endpoint
+	packets map[uint64]channeltypes.Packet
+}
+
+// Three chains, connected pairwise by a transfer channel, and the users with
+// their native tokens, as in the initial state of the spec
+func setup(t *testing.T, initial TestState) *system {
+	coordinator := ibctesting.NewCoordinator(t, len(chainIDs))
+	s := &system{
+		coordinator: coordinator,
+		chains:      make(map[string]*ibctesting.TestChain),
+		ends:        make(map[string]map[string]*ibctesting.Endpoint),
+		packets:     make(map[uint64]channeltypes.Packet),
+	}
+	for name, id := range chainIDs {
+		s.chains[name] = coordinator.GetChain(id)
+		s.ends[name] = make(map[string]*ibctesting.Endpoint)
+	}
+	for _, c := range channels {
+		path := ibctesting.NewPath(s.chains[c.chainA], s.chains[c.chainB])
+		for _, endpoint := range []*ibctesting.Endpoint{path.EndpointA, path.EndpointB} {
+			endpoint.ChannelConfig.PortID = ibctesting.TransferPort
+			endpoint.ChannelConfig.Version = transfertypes.Version
+		}
+		coordinator.Setup(path)
+		require.Equal(t, c.channelA, path.EndpointA.ChannelID, "channel of %s to %s", c.chainA, c.chainB)
+		require.Equal(t, c.channelB, path.EndpointB.ChannelID, "channel of %s to %s", c.chainB, c.chainA)
+		s.ends[c.chainA][c.chainB] = path.EndpointA
+		s.ends[c.chainB][c.chainA] = path.EndpointB
+	}
+
+	for name, c := range initial.chains {
+		chain := s.chains[name]
+		for user, coins := range c.balances {
+			if coins.IsZero() {
+				continue
+			}
+			app := chain.GetSimApp()
+			require.NoError(t, banktestutil.FundAccount(app.BankKeeper, chain.GetContext(), addr(user), coins))
+		}
+		coordinator.CommitBlock(chain)
+	}
+	return s
+}
+
+// Transfer, which sends the packet of the spec from the end of the channel
+// of the chain to chain "to", and escrows or burns the tokens
+func (s *system) transfer(t *testing.T, a TestAction, expected TestPacket) error {
+	end := s.ends[a.chain][a.to]
+	chain := end.Chain
+	ctx := chain.GetContext()
+	sequence, found := chain.App.GetIBCKeeper().ChannelKeeper.GetNextSequenceSend(ctx,
+		end.ChannelConfig.PortID, end.ChannelID)
+	require.True(t, found, "no next sequence of %s", end.ChannelID)
+
+	timeout := uint64(ctx.BlockTime().Add(relativeTimeout).UnixNano())
+	token := sdk.NewInt64Coin(a.denom.String(), a.amount)
+	sender, receiver := addr(a.sender).String(), addr(a.receiver).String()
+	msg := transfertypes.NewMsgTransfer(end.ChannelConfig.PortID, end.ChannelID, token,
+		sender, receiver, clienttypes.ZeroHeight(), timeout, "")
+	cacheCtx, write := ctx.CacheContext()
+	if _, err := chain.GetSimApp().TransferKeeper.Transfer(sdk.WrapSDKContext(cacheCtx), msg); err != nil {
+		return err
+	}
+	write()
+
+	// the packet that the chain committed to, with the full path of the spec
+	data := transfertypes.NewFungibleTokenPacketData(strings.Join(expected.denom, "/"),
+		token.Amount.String(), sender, receiver, "")
+	s.packets[a.packet] = channeltypes.NewPacket(data.GetBytes(), sequence,
+		end.ChannelConfig.PortID, end.ChannelID,
+		end.Counterparty.ChannelConfig.PortID, end.Counterparty.ChannelID,
+		clienttypes.ZeroHeight(), timeout)
+	s.coordinator.CommitBlock(chain)
+	return nil
+}
+
+// the relayer delivers a packet to the receiver, and the acknowledgement
+// back to the sender, and returns whether the receiver acknowledged it with success
+func (s *system) relay(t *testing.T, id uint64, expected TestPacket) bool {
+	packet, found := s.packets[id]
+	require.True(t, found, "unknown packet: %d", id)
+	src := s.ends[expected.srcChain][expected.dstChain]
+	dst := src.Counterparty
+	require.NoError(t, dst.UpdateClient())
+	res, err := dst.RecvPacketWithResult(packet)
+	require.NoError(t, err)
+	bz, err := ibctesting.ParseAckFromEvents(res.GetEvents())
+	require.NoError(t, err)
+	require.NoError(t, src.UpdateClient())
+	require.NoError(t, src.AcknowledgePacket(packet, bz))
+	delete(s.packets, id)
+
+	var ack channeltypes.Acknowledgement
+	require.NoError(t, channeltypes.SubModuleCdc.UnmarshalJSON(bz, &ack))
+	return ack.Success()
+}
+
+// execute an action, with the packets of the spec before and after it, and
+// return whether a relayed packet was acknowledged with success, and the
+// error of a transfer
+func (s *system) executeAction(t *testing.T, a TestAction, before, after map[uint64]TestPacket) (bool, error) {
+	switch a.kind {
+	case "transfer":
+		return false, s.transfer(t, a, after[a.packet])
+
+	case "relay":
+		return s.relay(t, a.packet, before[a.packet]), nil
+
+	default:
+		require.FailNow(t, "unknown action: "+a.kind)
+	}
+	return false, nil
+}
+
+// sort the traces by their full paths
+func sortTraces(traces []transfertypes.DenomTrace) []transfertypes.DenomTrace {
+	sorted := append([]transfertypes.DenomTrace{}, traces...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetFullDenomPath() < sorted[j].GetFullDenomPath()
+	})
+	return sorted
+}
+
+// compare a chain with the state of the spec
+func (s *system) checkChain(t *testing.T, name string, expected TestChain) {
+	chain := s.chains[name]
+	ctx := chain.GetContext()
+	app := chain.GetSimApp()
+	for user, coins := range expected.balances {
+		balances := app.BankKeeper.GetAllBalances(ctx, addr(user))
+		assert.Equal(t, coins.String(), balances.String(), "balances of %s on %s", user, name)
+	}
+	for channel, coins := range expected.escrow {
+		escrow := transfertypes.GetEscrowAddress(ibctesting.TransferPort, channel)
+		balances := app.BankKeeper.GetAllBalances(ctx, escrow)
+		assert.Equal(t, coins.String(), balances.String(), "escrow of %s on %s", channel, name)
+	}
+
+	traces := app.TransferKeeper.GetAllDenomTraces(ctx)
+	assert.Equal(t, sortTraces(expected.traces), sortTraces(traces), "denomination traces on %s", name)
+	for _, trace := range traces {
+		// a trace is parsed from its full path, and hashed to the denomination of the spec
+		fullPath := trace.GetFullDenomPath()
+		assert.Equal(t, trace, transfertypes.ParseDenomTrace(fullPath), "parsed trace of %s on %s", fullPath, name)
+		voucher := TestDenom{ibc: true, path: strings.Split(fullPath, "/")}
+		assert.Equal(t, voucher.String(), trace.IBCDenom(), "denomination of %s on %s", fullPath, name)
+	}
+}
+
+// compare the chains and the packets in flight with the state of the spec
+func (s *system) checkState(t *testing.T, expected TestState) {
+	for name, c := range expected.chains {
+		s.checkChain(t, name, c)
+	}
+	require.Len(t, s.packets, len(expected.packets), "packets in flight")
+	for id, p := range expected.packets {
+		packet, found := s.packets[id]
+		require.True(t, found, "packet %d should be in flight", id)
+		end := s.ends[p.srcChain][p.dstChain]
+		assert.Equal(t, p.srcChannel, packet.SourceChannel, "source channel of packet %d", id)
+		assert.Equal(t, p.dstChannel, packet.DestinationChannel, "destination channel of packet %d", id)
+		assert.True(t, end.Chain.App.GetIBCKeeper().ChannelKeeper.HasPacketCommitment(end.Chain.GetContext(),
+			packet.SourcePort, packet.SourceChannel, packet.Sequence), "commitment of packet %d", id)
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	s := setup(t, states[0])
+	for i, state := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, state.lastAction.kind, state.lastAction.chain)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				ack, err := s.executeAction(t, state.lastAction, states[i-1].packets, state.packets)
+				if state.lastAction.error == "" {
+					require.NoError(t, err, "the action should succeed")
+				} else {
+					expected, found := errorsOfSpec[state.lastAction.error]
+					require.True(t, found, "unknown error: %s", state.lastAction.error)
+					require.True(t, errors.Is(err, expected), "expected %v, found %v", expected, err)
+				}
+				assert.Equal(t, state.lastAction.ack, ack, "acknowledgement")
+			}
+			s.checkState(t, state)
+		})
+		if !ok {
+			// the states of the chains and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// The tokens travel over several hops and come back, and every account holds
+// the denominations that it started with, see noRoundTrip in ibcDenomTest.qnt.
+func TestRoundTrip(t *testing.T) {
+	ExecFromItf(t, tracePath("roundTrip.itf.json"))
+}
+
+// C burns its native token, which is named like the vouchers of atom from A,
+// and A releases atom from its escrow for it, see noCounterfeitRedeemed in
+// ibcDenomTest.qnt.
+func TestCounterfeitRedeemed(t *testing.T) {
+	ExecFromItf(t, tracePath("counterfeitRedeemed.itf.json"))
+}
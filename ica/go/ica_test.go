@@ -0,0 +1,459 @@
+// A test harness that replays the traces of icaTest.qnt against the
+// interchain accounts modules of ibc-go v6.0.0.
+//
+// The controller and the host chain are two test chains of ibctesting, which
+// are connected before the trace starts. Every owner of the spec registers
+// its account over a channel of its own, which we drive through the
+// controller's message server: RegisterInterchainAccount and SendTx run in a
+// cached context, as they may fail, and the relayer steps go through the
+// endpoints of ibctesting. Time is counted in timeouts in the spec, and every
+// packet times out after relativeTimeout, so elapse moves the clocks of both
+// chains past it.
+//
+// Every state of a trace records the action that led to it in lastAction,
+// with the acknowledgement of a delivered packet. Then we compare the channel
+// ends, the accounts and their balances, and the sequences with the spec.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gogo/protobuf/proto"
+
+	tmcrypto "github.com/tendermint/tendermint/crypto"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+
+	icacontrollerkeeper "github.com/cosmos/ibc-go/v6/modules/apps/27-interchain-accounts/controller/keeper"
+	icacontrollertypes "github.com/cosmos/ibc-go/v6/modules/apps/27-interchain-accounts/controller/types"
+	icahosttypes "github.com/cosmos/ibc-go/v6/modules/apps/27-interchain-accounts/host/types"
+	icatypes "github.com/cosmos/ibc-go/v6/modules/apps/27-interchain-accounts/types"
+	clienttypes "github.com/cosmos/ibc-go/v6/modules/core/02-client/types"
+	channeltypes "github.com/cosmos/ibc-go/v6/modules/core/04-channel/types"
+	ibctesting "github.com/cosmos/ibc-go/v6/testing"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v6.0.0"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// The timeout of every packet, relative to the block time of the controller.
+// It must be long enough for the blocks of ibctesting, which add 10 seconds
+// each, not to time out a packet before the next elapse, and short enough for
+// the clients not to expire over a trace.
+const relativeTimeout = 6 * time.Hour
+
+// the denomination of the tokens of the accounts
+var denom = sdk.DefaultBondDenom
+
+// the account on the host that the interchain accounts send their tokens to
+var recipient = addr("recipient")
+
+// the address of a name, as in simchain.Addr
+func addr(name string) sdk.AccAddress {
+	return sdk.AccAddress(tmcrypto.AddressHash([]byte(name)))
+}
+
+// a packet that is sent, but neither acknowledged nor timed out
+type TestPacket struct {
+	sequence int64
+	amount   int64
+}
+
+// the account of an owner in ica.qnt
+type TestAccount struct {
+	controllerEnd string
+	hostEnd       string
+	registered    bool
+	balance       int64
+	nextSequence  int64
+	packets       []TestPacket
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind   string
+	owner  string
+	amount int64
+	ack    bool
+	error  string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	accounts   map[string]TestAccount
+	recipient  int64
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from icaTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.accounts = make(map[string]TestAccount)
+		entries, err := itf.Map(jsonState.Get("state.accounts"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			a := TestAccount{
+				controllerEnd: e.Value.Get("controllerEnd").String(),
+				hostEnd:       e.Value.Get("hostEnd").String(),
+				registered:    e.Value.Get("registered").Bool(),
+				balance:       harness.Int64(t, e.Value.Get("balance")),
+				nextSequence:  harness.Int64(t, e.Value.Get("nextSequence")),
+			}
+			for _, p := range e.Value.Get("packets").Array() {
+				a.packets = append(a.packets, TestPacket{
+					sequence: harness.Int64(t, p.Get("sequence")),
+					amount:   harness.Int64(t, p.Get("amount")),
+				})
+			}
+			state.accounts[e.Key.String()] = a
+		}
+		state.recipient = harness.Int64(t, jsonState.Get("state.recipient"))
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.owner = jsonState.Get("lastAction.owner").String()
+		state.lastAction.amount = harness.Int64(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.ack = jsonState.Get("lastAction.ack").Bool()
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the states of a channel end in ica.qnt, except for NONE, which has no end
+var channelStates = map[string]channeltypes.State{
+	"INIT":   channeltypes.INIT,
+	"OPEN":   channeltypes.OPEN,
+	"CLOSED": channeltypes.CLOSED,
+}
+
+// the interchain account of an owner, and its current channel
+type account struct {
+	owner string
+	path  *ibctesting.Path
+	// the address on the host, once the host has created the account
+	address string
+	// the packets in flight, in the order of their sequences
+	packets []channeltypes.Packet
+}
+
+// the chains, and the accounts of the owners of the spec
+type system struct {
+	coordinator *ibctesting.Coordinator
+	controller  *ibctesting.TestChain
+	host        *ibctesting.TestChain
+	accounts    map[string]*account
+}
+
+// the version of every channel, which the host completes with the address of the account
+func channelVersion(connection *ibctesting.Path) string {
+	return string(icatypes.ModuleCdc.MustMarshalJSON(&icatypes.Metadata{
+		Version:                icatypes.Version,
+		ControllerConnectionId: connection.EndpointA.ConnectionID,
+		HostConnectionId:       connection.EndpointB.ConnectionID,
+		Encoding:               icatypes.EncodingProtobuf,
+		TxType:                 icatypes.TxTypeSDKMultiMsg,
+	}))
+}
+
+// Two chains with an open connection, and no channels yet. The host allows
+// the interchain accounts to send tokens. Every owner gets a path over the
+// connection, whose ends are on the controller port of the owner and on the
+// host port.
+func setup(t *testing.T, owners map[string]TestAccount) *system {
+	coordinator := ibctesting.NewCoordinator(t, 2)
+	controller := coordinator.GetChain(ibctesting.GetChainID(1))
+	host := coordinator.GetChain(ibctesting.GetChainID(2))
+	connection := ibctesting.NewPath(controller, host)
+	coordinator.SetupConnections(connection)
+	host.GetSimApp().ICAHostKeeper.SetParams(host.GetContext(),
+		icahosttypes.NewParams(true, []string{sdk.MsgTypeURL(&banktypes.MsgSend{})}))
+	coordinator.CommitBlock(host)
+
+	s := &system{coordinator: coordinator, controller: controller, host: host, accounts: make(map[string]*account)}
+	version := channelVersion(connection)
+	for name := range owners {
+		owner := addr(name).String()
+		portID, err := icatypes.NewControllerPortID(owner)
+		require.NoError(t, err)
+		path := ibctesting.NewPath(controller, host)
+		path.EndpointA.ClientID = connection.EndpointA.ClientID
+		path.EndpointB.ClientID = connection.EndpointB.ClientID
+		path.EndpointA.ConnectionID = connection.EndpointA.ConnectionID
+		path.EndpointB.ConnectionID = connection.EndpointB.ConnectionID
+		path.EndpointA.ChannelConfig.PortID = portID
+		path.EndpointB.ChannelConfig.PortID = icatypes.HostPortID
+		for _, endpoint := range []*ibctesting.Endpoint{path.EndpointA, path.EndpointB} {
+			endpoint.ChannelConfig.Order = channeltypes.ORDERED
+			endpoint.ChannelConfig.Version = version
+		}
+		s.accounts[name] = &account{owner: owner, path: path}
+	}
+	return s
+}
+
+// the message server of the controller, which registers accounts and sends transactions
+func (s *system) msgServer() icacontrollertypes.MsgServer {
+	return icacontrollerkeeper.NewMsgServerImpl(&s.controller.GetSimApp().ICAControllerKeeper)
+}
+
+// RegisterInterchainAccount, which sends ChanOpenInit on the controller
+func (s *system) register(a *account) error {
+	cacheCtx, write := s.controller.GetContext().CacheContext()
+	endpoint := a.path.EndpointA
+	msg := icacontrollertypes.NewMsgRegisterInterchainAccount(endpoint.ConnectionID, a.owner, endpoint.ChannelConfig.Version)
+	res, err := s.msgServer().RegisterInterchainAccount(sdk.WrapSDKContext(cacheCtx), msg)
+	if err != nil {
+		return err
+	}
+	write()
+	endpoint.ChannelID = res.ChannelId
+	s.coordinator.CommitBlock(s.controller)
+	return nil
+}
+
+// the relayer completes the handshake of a new channel, as the host end is new too
+func (s *system) openChannel(t *testing.T, a *account) {
+	controllerEnd, hostEnd := a.path.EndpointA, a.path.EndpointB
+	hostEnd.ChannelID = ""
+	hostEnd.ChannelConfig.Version = controllerEnd.ChannelConfig.Version
+	require.NoError(t, hostEnd.ChanOpenTry())
+	// the host proposes the version with the address of the account
+	hostEnd.ChannelConfig.Version = hostEnd.GetChannel().Version
+	require.NoError(t, controllerEnd.ChanOpenAck())
+	require.NoError(t, hostEnd.ChanOpenConfirm())
+	a.packets = nil
+}
+
+// fund the account on the host, from nowhere
+func (s *system) fund(t *testing.T, a *account, amount int64) {
+	address, err := sdk.AccAddressFromBech32(a.address)
+	require.NoError(t, err)
+	coins := sdk.NewCoins(sdk.NewInt64Coin(denom, amount))
+	require.NoError(t, banktestutil.FundAccount(s.host.GetSimApp().BankKeeper, s.host.GetContext(), address, coins))
+	s.coordinator.CommitBlock(s.host)
+}
+
+// SendTx, which sends a packet with a bank send from the account to the recipient
+func (s *system) sendTx(t *testing.T, a *account, amount int64) error {
+	send := &banktypes.MsgSend{
+		FromAddress: a.address,
+		ToAddress:   recipient.String(),
+		Amount:      sdk.NewCoins(sdk.NewInt64Coin(denom, amount)),
+	}
+	data, err := icatypes.SerializeCosmosTx(s.controller.GetSimApp().AppCodec(), []proto.Message{send})
+	require.NoError(t, err)
+	packetData := icatypes.InterchainAccountPacketData{Type: icatypes.EXECUTE_TX, Data: data}
+
+	ctx := s.controller.GetContext()
+	cacheCtx, write := ctx.CacheContext()
+	endpoint := a.path.EndpointA
+	msg := icacontrollertypes.NewMsgSendTx(a.owner, endpoint.ConnectionID, uint64(relativeTimeout.Nanoseconds()), packetData)
+	res, err := s.msgServer().SendTx(sdk.WrapSDKContext(cacheCtx), msg)
+	if err != nil {
+		return err
+	}
+	write()
+	// the packet that the controller committed to
+	timeout := uint64(ctx.BlockTime().UnixNano()) + uint64(relativeTimeout.Nanoseconds())
+	packet := channeltypes.NewPacket(packetData.GetBytes(), res.Sequence,
+		endpoint.ChannelConfig.PortID, endpoint.ChannelID,
+		endpoint.Counterparty.ChannelConfig.PortID, endpoint.Counterparty.ChannelID,
+		clienttypes.ZeroHeight(), timeout)
+	a.packets = append(a.packets, packet)
+	s.coordinator.CommitBlock(s.controller)
+	return nil
+}
+
+// the relayer delivers the first packet to the host, and the acknowledgement
+// back to the controller, and returns whether the host executed the transaction
+func (s *system) relay(t *testing.T, a *account) bool {
+	require.NotEmpty(t, a.packets, "no packet to relay")
+	packet := a.packets[0]
+	require.NoError(t, a.path.EndpointB.UpdateClient())
+	res, err := a.path.EndpointB.RecvPacketWithResult(packet)
+	require.NoError(t, err)
+	bz, err := ibctesting.ParseAckFromEvents(res.GetEvents())
+	require.NoError(t, err)
+	require.NoError(t, a.path.EndpointA.UpdateClient())
+	require.NoError(t, a.path.EndpointA.AcknowledgePacket(packet, bz))
+	a.packets = a.packets[1:]
+
+	var ack channeltypes.Acknowledgement
+	require.NoError(t, channeltypes.SubModuleCdc.UnmarshalJSON(bz, &ack))
+	return ack.Success()
+}
+
+// the relayer proves that the first packet timed out, which closes the ordered channel
+func (s *system) timeout(t *testing.T, a *account) {
+	require.NotEmpty(t, a.packets, "no packet to time out")
+	require.NoError(t, a.path.EndpointA.UpdateClient())
+	require.NoError(t, a.path.EndpointA.TimeoutPacket(a.packets[0]))
+	a.packets = nil
+}
+
+// the relayer closes the host end, after the controller end is closed
+func (s *system) closeConfirm(t *testing.T, a *account) {
+	require.NoError(t, a.path.EndpointB.UpdateClient())
+	require.NoError(t, a.path.EndpointB.ChanCloseConfirm())
+}
+
+// move the clocks of both chains past the timeout of every packet in flight
+func (s *system) elapse() {
+	s.coordinator.IncrementTimeBy(relativeTimeout + time.Minute)
+	s.coordinator.CommitBlock(s.controller, s.host)
+}
+
+// execute an action, and return whether a delivered packet was acknowledged
+// with success, and the error of the controller
+func (s *system) executeAction(t *testing.T, a TestAction) (bool, error) {
+	if a.kind == "elapse" {
+		s.elapse()
+		return false, nil
+	}
+	acc, found := s.accounts[a.owner]
+	require.True(t, found, "unknown owner: %s", a.owner)
+	switch a.kind {
+	case "register":
+		return false, s.register(acc)
+
+	case "openChannel":
+		s.openChannel(t, acc)
+
+	case "fund":
+		s.fund(t, acc, a.amount)
+
+	case "sendTx":
+		return false, s.sendTx(t, acc, a.amount)
+
+	case "relay":
+		return s.relay(t, acc), nil
+
+	case "timeout":
+		s.timeout(t, acc)
+
+	case "closeConfirm":
+		s.closeConfirm(t, acc)
+
+	default:
+		require.FailNow(t, "unknown action: "+a.kind)
+	}
+	return false, nil
+}
+
+// compare the end of a channel with the state of the spec
+func checkEnd(t *testing.T, endpoint *ibctesting.Endpoint, state string, name string) {
+	if state == "NONE" {
+		assert.Empty(t, endpoint.ChannelID, "%s should have no channel end", name)
+		return
+	}
+	expected, found := channelStates[state]
+	require.True(t, found, "unknown state: %s", state)
+	require.NotEmpty(t, endpoint.ChannelID, "%s should have a channel end", name)
+	assert.Equal(t, expected, endpoint.GetChannel().State, "state of %s", name)
+}
+
+// compare the account of an owner with the state of the spec
+func (s *system) checkAccount(t *testing.T, name string, expected TestAccount) {
+	a := s.accounts[name]
+	controllerEnd, hostEnd := a.path.EndpointA, a.path.EndpointB
+	checkEnd(t, controllerEnd, expected.controllerEnd, "the controller end of "+name)
+	checkEnd(t, hostEnd, expected.hostEnd, "the host end of "+name)
+
+	hostCtx := s.host.GetContext()
+	address, found := s.host.GetSimApp().ICAHostKeeper.GetInterchainAccountAddress(hostCtx,
+		hostEnd.ConnectionID, controllerEnd.ChannelConfig.PortID)
+	require.Equal(t, expected.registered, found, "whether the host has the account of %s", name)
+	if !found {
+		return
+	}
+	if a.address != "" {
+		// the account stays the same over every channel
+		assert.Equal(t, a.address, address, "address of the account of %s", name)
+	}
+	a.address = address
+	balance := s.host.GetSimApp().BankKeeper.GetBalance(hostCtx, sdk.MustAccAddressFromBech32(address), denom)
+	assert.Equal(t, expected.balance, balance.Amount.Int64(), "balance of the account of %s", name)
+
+	if expected.controllerEnd == "OPEN" {
+		controllerCtx := s.controller.GetContext()
+		known, found := s.controller.GetSimApp().ICAControllerKeeper.GetInterchainAccountAddress(controllerCtx,
+			controllerEnd.ConnectionID, controllerEnd.ChannelConfig.PortID)
+		assert.True(t, found, "the controller should know the account of %s", name)
+		assert.Equal(t, address, known, "address of the account of %s on the controller", name)
+		next, _ := s.controller.App.GetIBCKeeper().ChannelKeeper.GetNextSequenceSend(controllerCtx,
+			controllerEnd.ChannelConfig.PortID, controllerEnd.ChannelID)
+		assert.Equal(t, uint64(expected.nextSequence), next, "next sequence of %s", name)
+	}
+	require.Len(t, a.packets, len(expected.packets), "packets of %s in flight", name)
+	for i, p := range expected.packets {
+		assert.Equal(t, uint64(p.sequence), a.packets[i].Sequence, "sequence of the packet %d of %s", i, name)
+	}
+}
+
+// compare the chains with the state of the spec
+func (s *system) checkState(t *testing.T, expected TestState) {
+	for name, a := range expected.accounts {
+		s.checkAccount(t, name, a)
+	}
+	balance := s.host.GetSimApp().BankKeeper.GetBalance(s.host.GetContext(), recipient, denom)
+	assert.Equal(t, expected.recipient, balance.Amount.Int64(), "balance of the recipient")
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	s := setup(t, states[0].accounts)
+	for i, state := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, state.lastAction.kind, state.lastAction.owner)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				ack, err := s.executeAction(t, state.lastAction)
+				if state.lastAction.error != "" {
+					require.Error(t, err, "the action should fail with: %s", state.lastAction.error)
+				} else {
+					require.NoError(t, err, "the action should succeed")
+				}
+				assert.Equal(t, state.lastAction.ack, ack, "acknowledgement")
+			}
+			s.checkState(t, state)
+		})
+		if !ok {
+			// the states of the chains and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// A timeout closes the channel of an owner, who registers again, and gets
+// the same account over a new channel, see neverReopened in icaTest.qnt.
+func TestReopened(t *testing.T) {
+	ExecFromItf(t, tracePath("reopened.itf.json"))
+}
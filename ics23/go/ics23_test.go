@@ -0,0 +1,273 @@
+// A test harness that replays the traces of ics23Test.qnt against the proof
+// verification of confio/ics23/go v0.9.0, with the proof spec of Tendermint.
+//
+// The hashes of the spec are terms: [LEAF, key, value] for a leaf, and
+// [OPEN] ++ left ++ right ++ [CLOSE] for an inner node. We turn them into
+// SHA-256 hashes with the leaf and inner operations of TendermintSpec, so the
+// proofs of the spec become proofs of ICS-23, including the mutated ones.
+// A key or a value k of the spec is the single byte k. In every state, we
+// check that the root of the spec is the root of the Merkle tree of CometBFT
+// over the store. When the state is a query, we verify its proofs and compare
+// the error with the error of the spec.
+
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	ics23 "github.com/confio/ics23/go"
+
+	"github.com/cometbft/cometbft/crypto/merkle"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.9.0"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the markers of the terms in ics23.qnt
+const (
+	markLeaf  = -1
+	markOpen  = -2
+	markClose = -3
+)
+
+// a step of a path: side is 0 when the child is the left one
+type TestInnerOp struct {
+	side    int64
+	sibling []int64
+}
+
+// an existence proof of the spec
+type TestProof struct {
+	key   int64
+	value int64
+	path  []TestInnerOp
+}
+
+// a query of the spec, or an update of the store
+type TestQuery struct {
+	kind     string
+	key      int64
+	value    int64
+	proof    TestProof
+	left     TestProof
+	hasLeft  bool
+	right    TestProof
+	hasRight bool
+	error    string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	store     map[int64]int64
+	root      []int64
+	lastQuery TestQuery
+}
+
+// parse the states in the ITF JSON format, as produced from ics23Test.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		entries, err := itf.Map(jsonState.Get("store"))
+		require.NoError(t, err)
+		state.store = make(map[int64]int64, len(entries))
+		for _, e := range entries {
+			state.store[harness.Int64(t, e.Key)] = harness.Int64(t, e.Value)
+		}
+		state.root = parseTerm(t, jsonState.Get("root"))
+		q := jsonState.Get("lastQuery")
+		state.lastQuery = TestQuery{
+			kind:     q.Get("kind").String(),
+			key:      harness.Int64(t, q.Get("key")),
+			value:    harness.Int64(t, q.Get("value")),
+			proof:    parseProof(t, q.Get("proof")),
+			left:     parseProof(t, q.Get("left")),
+			hasLeft:  q.Get("hasLeft").Bool(),
+			right:    parseProof(t, q.Get("right")),
+			hasRight: q.Get("hasRight").Bool(),
+			error:    q.Get("error").String(),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse an existence proof of the spec
+func parseProof(t *testing.T, obj gjson.Result) TestProof {
+	proof := TestProof{
+		key:   harness.Int64(t, obj.Get("key")),
+		value: harness.Int64(t, obj.Get("value")),
+	}
+	for _, op := range obj.Get("path").Array() {
+		proof.path = append(proof.path, TestInnerOp{
+			side:    harness.Int64(t, op.Get("side")),
+			sibling: parseTerm(t, op.Get("sibling")),
+		})
+	}
+	return proof
+}
+
+// parse a hash of the spec, which is a list of integers
+func parseTerm(t *testing.T, obj gjson.Result) []int64 {
+	var term []int64
+	for _, e := range obj.Array() {
+		term = append(term, harness.Int64(t, e))
+	}
+	return term
+}
+
+// the bytes of a key or a value of the spec
+func toBytes(k int64) []byte {
+	return []byte{byte(k)}
+}
+
+// the leaf operation of Tendermint, which hashes the key and the value
+func leafHash(t *testing.T, key, value int64) []byte {
+	h, err := ics23.TendermintSpec.LeafSpec.Apply(toBytes(key), toBytes(value))
+	require.NoError(t, err)
+	return h
+}
+
+// the inner operation of Tendermint that hashes the child on the given side
+func innerOp(side int64, sibling []byte) *ics23.InnerOp {
+	op := &ics23.InnerOp{Hash: ics23.HashOp_SHA256, Prefix: []byte{1}}
+	if side == 0 {
+		op.Suffix = sibling
+	} else {
+		op.Prefix = append(op.Prefix, sibling...)
+	}
+	return op
+}
+
+// Turn a term of the spec into the hash it stands for. The empty term is the
+// root of the empty store, which is never hashed by the verification.
+func hashOf(t *testing.T, term []int64) []byte {
+	if len(term) == 0 {
+		return nil
+	}
+	h, rest := hashPrefix(t, term)
+	require.Empty(t, rest, "trailing elements in a hash: %v", term)
+	return h
+}
+
+// hash the term that starts the list, and return the remaining elements
+func hashPrefix(t *testing.T, term []int64) ([]byte, []int64) {
+	require.NotEmpty(t, term, "unexpected end of a hash")
+	switch term[0] {
+	case markLeaf:
+		require.GreaterOrEqual(t, len(term), 3, "a leaf is too short: %v", term)
+		return leafHash(t, term[1], term[2]), term[3:]
+
+	case markOpen:
+		left, rest := hashPrefix(t, term[1:])
+		right, rest := hashPrefix(t, rest)
+		require.NotEmpty(t, rest, "an inner node is not closed")
+		require.Equal(t, int64(markClose), rest[0], "an inner node is not closed")
+		h, err := innerOp(0, right).Apply(left)
+		require.NoError(t, err)
+		return h, rest[1:]
+
+	default:
+		require.FailNow(t, "unexpected marker", "found %d", term[0])
+		return nil, nil
+	}
+}
+
+// the existence proof of ICS-23 that corresponds to a proof of the spec
+func existenceProof(t *testing.T, p TestProof) *ics23.ExistenceProof {
+	proof := &ics23.ExistenceProof{
+		Key:   toBytes(p.key),
+		Value: toBytes(p.value),
+		Leaf:  ics23.TendermintSpec.LeafSpec,
+	}
+	for _, op := range p.path {
+		proof.Path = append(proof.Path, innerOp(op.side, hashOf(t, op.sibling)))
+	}
+	return proof
+}
+
+// The root of the Merkle tree of CometBFT over the store. The items are the
+// leaves of TendermintSpec without their prefix, which CometBFT adds.
+func cometRoot(store map[int64]int64) []byte {
+	var items [][]byte
+	for k := int64(0); k < 256; k++ {
+		if v, ok := store[k]; ok {
+			valueHash := sha256.Sum256(toBytes(v))
+			item := append([]byte{1, byte(k), byte(len(valueHash))}, valueHash[:]...)
+			items = append(items, item)
+		}
+	}
+	return merkle.HashFromByteSlices(items)
+}
+
+// the error as it is written in the spec, where ICS-23 capitalizes some errors
+func errorOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	return strings.ToLower(err.Error())
+}
+
+// connect the test inputs to the actual code
+func executeTest(t *testing.T, s TestState) {
+	root := hashOf(t, s.root)
+	if len(s.store) > 0 {
+		// the spec builds the same tree as CometBFT does
+		assert.Equal(t, cometRoot(s.store), root, "the roots should be equal")
+	}
+
+	q := s.lastQuery
+	switch q.kind {
+	case "verifyMembership":
+		err := existenceProof(t, q.proof).Verify(ics23.TendermintSpec, root, toBytes(q.key), toBytes(q.value))
+		assert.Equal(t, q.error, errorOf(err), "the errors of existence should be equal")
+
+	case "verifyNonMembership":
+		proof := &ics23.NonExistenceProof{Key: toBytes(q.key)}
+		if q.hasLeft {
+			proof.Left = existenceProof(t, q.left)
+		}
+		if q.hasRight {
+			proof.Right = existenceProof(t, q.right)
+		}
+		err := proof.Verify(ics23.TendermintSpec, root, toBytes(q.key))
+		assert.Equal(t, q.error, errorOf(err), "the errors of non-existence should be equal")
+	}
+}
+
+// execute all queries of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%d", i, s.lastQuery.kind, s.lastQuery.key)
+		t.Run(description, func(t *testing.T) {
+			executeTest(t, s)
+		})
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
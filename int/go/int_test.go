@@ -0,0 +1,240 @@
+// A test harness that parses a test in the ITF format, as produced from
+// intTest.qnt, and executes it against sdk.Int.
+//
+// The harness follows the one of decimal, since Int and Dec bugs tend to
+// interact: Dec is constructed from Int, and Dec.RoundInt returns an Int.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see defaultTraceDir
+var traceDir = flag.String("itf-dir", defaultTraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the directory of ITF_TRACE_DIR, if it is set, or else def, as itf.TraceDir,
+// which this harness does not depend on
+func defaultTraceDir(def string) string {
+	if dir := os.Getenv("ITF_TRACE_DIR"); dir != "" {
+		return dir
+	}
+	return def
+}
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// With -itf-list, the harness lists the opcodes of every trace in the trace
+// directory, and whether they are expected to panic, instead of running them
+// against the SDK, e.g., to review a new corpus: go test -v -args -itf-list
+var listTraces = flag.Bool("itf-list", false, "list the opcodes of the traces instead of executing them")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *listTraces {
+		if err := listTraceDir(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// List the opcodes of the traces of intTest.qnt and uintTest.qnt, in the
+// format of itf.List, which this harness does not depend on.
+func listTraceDir(w io.Writer) error {
+	files, err := filepath.Glob(tracePath("*.itf.json"))
+	if err != nil {
+		return err
+	}
+	for _, filename := range files {
+		states := parseItf(filename)
+		failures := 0
+		for _, s := range states {
+			if s.result.error {
+				failures++
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s: %d states, %d errors\n", filepath.Base(filename), len(states), failures); err != nil {
+			return err
+		}
+		for i, s := range states {
+			line := fmt.Sprintf("%5d  %s", i, s.opcode)
+			if s.result.error {
+				line += "  error: true"
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// a representation of an integer in the test
+type TestInt struct {
+	// whether this integer is malformed (a panic expected)
+	error bool
+	// the actual value
+	value big.Int
+}
+
+// a state of our testing state machine, which is also an input to the Golang test
+type TestInput struct {
+	opcode string
+	arg1   TestInt
+	arg2   TestInt
+	result TestInt
+}
+
+// parse a big integer from ITF JSON
+func parseBigInt(obj gjson.Result, target *big.Int) {
+	var bigintStr = obj.Get("\\#bigint").String()
+	if obj.Type == gjson.Number {
+		// older versions of Quint write small integers as JSON numbers
+		bigintStr = obj.Raw
+	}
+	_, ok := target.SetString(bigintStr, 10)
+	if !ok {
+		panic(fmt.Errorf("expected a big.Int, found: %s", obj.Raw))
+	}
+}
+
+// parse the states in the ITF JSON format, as produced from intTest.qnt
+func parseItf(filename string) []TestInput {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		panic(fmt.Errorf("error opening file: %v", err))
+	}
+	jsonStates := gjson.GetBytes(data, "states").Array()
+	// iterate over all states of the test run
+	var states = make([]TestInput, 0)
+	for _, jsonState := range jsonStates {
+		var state TestInput
+		state.opcode = jsonState.Get("opcode").String()
+		state.arg1.error = jsonState.Get("opArg1.error").Bool()
+		state.arg2.error = jsonState.Get("opArg2.error").Bool()
+		state.result.error = jsonState.Get("opResult.error").Bool()
+		parseBigInt(jsonState.Get("opArg1.value"), &state.arg1.value)
+		parseBigInt(jsonState.Get("opArg2.value"), &state.arg2.value)
+		parseBigInt(jsonState.Get("opResult.value"), &state.result.value)
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the binary operators of Int, by their opcodes in intTest.qnt
+var binaryOps = map[string]func(sdk.Int, sdk.Int) sdk.Int{
+	"add": sdk.Int.Add,
+	"sub": sdk.Int.Sub,
+	"mul": sdk.Int.Mul,
+	"quo": sdk.Int.Quo,
+	"mod": sdk.Int.Mod,
+}
+
+// the unary operators of Int, by their opcodes in intTest.qnt
+var unaryOps = map[string]func(sdk.Int) sdk.Int{
+	"neg": sdk.Int.Neg,
+	"abs": sdk.Int.Abs,
+}
+
+// compare the result of an operation with the expected one, or expect a panic
+func checkResult(t *testing.T, s TestInput, op func() sdk.Int) {
+	if s.result.error {
+		require.Panics(t, func() { op() })
+	} else {
+		actual := op()
+		expected := sdk.NewIntFromBigInt(&s.result.value)
+		assert.True(t, expected.Equal(actual),
+			"the results should be equal, expected: %s, actual: %s", expected, actual)
+	}
+}
+
+// connect the test inputs to the actual code
+func executeTest(t *testing.T, s TestInput) {
+	switch s.opcode {
+	case "newInt":
+		checkResult(t, s, func() sdk.Int { return sdk.NewInt(s.arg1.value.Int64()) })
+
+	case "newIntFromBigInt":
+		checkResult(t, s, func() sdk.Int { return sdk.NewIntFromBigInt(&s.arg1.value) })
+
+	case "newIntWithDecimal":
+		checkResult(t, s, func() sdk.Int {
+			// not re-exported by the types package of cosmos-sdk v0.46.4
+			return sdkmath.NewIntWithDecimal(s.arg1.value.Int64(), int(s.arg2.value.Int64()))
+		})
+
+	default:
+		if op, found := unaryOps[s.opcode]; found {
+			arg1 := sdk.NewIntFromBigInt(&s.arg1.value)
+			checkResult(t, s, func() sdk.Int { return op(arg1) })
+		} else if op, found := binaryOps[s.opcode]; found {
+			arg1 := sdk.NewIntFromBigInt(&s.arg1.value)
+			arg2 := sdk.NewIntFromBigInt(&s.arg2.value)
+			checkResult(t, s, func() sdk.Int { return op(arg1, arg2) })
+		} else {
+			require.Fail(t, "unknown opcode: "+s.opcode)
+		}
+	}
+}
+
+// execute all steps of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(filename)
+	for i, s := range states {
+		t.Run(stepName(filename, i, s), func(t *testing.T) {
+			executeTest(t, s)
+		})
+	}
+}
+
+// The name of the subtest of a state, which is unique across traces and stable
+// across runs, so that go test -run selects exactly one step, e.g., the state
+// 17 of overflow.itf.json with go test -run 'TestOverflow/^overflow_17_'.
+// The digits of a huge argument beyond the first 12 are replaced by their
+// number, e.g., 123456789012e28 for an argument of 40 digits.
+func stepName(filename string, i int, s TestInput) string {
+	short := func(x *big.Int) string {
+		str := x.String()
+		digits := strings.TrimPrefix(str, "-")
+		if len(digits) <= 12 {
+			return str
+		}
+		return fmt.Sprintf("%s%se%d", str[:len(str)-len(digits)], digits[:12], len(digits)-12)
+	}
+	name := filepath.Base(filename)
+	if dot := strings.Index(name, "."); dot > 0 {
+		name = name[:dot]
+	}
+	return fmt.Sprintf("%s_%d_%s_%s_%s", name, i, s.opcode, short(&s.arg1.value), short(&s.arg2.value))
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+func TestOverflow(t *testing.T) {
+	ExecFromItf(t, tracePath("overflow.itf.json"))
+}
@@ -0,0 +1,88 @@
+// Replaying the traces of uintTest.qnt against sdk.Uint.
+//
+// The traces have the same shape as the ones of intTest.qnt, so we reuse
+// parseItf. What differs are the panic conditions: Uint panics on negative
+// results, e.g., in Sub and Decr, whereas Int only panics on overflow.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// the binary operators of Uint, by their opcodes in uintTest.qnt
+var uintBinaryOps = map[string]func(sdk.Uint, sdk.Uint) sdk.Uint{
+	"add": sdk.Uint.Add,
+	"sub": sdk.Uint.Sub,
+	"mul": sdk.Uint.Mul,
+	"quo": sdk.Uint.Quo,
+	"mod": sdk.Uint.Mod,
+}
+
+// the unary operators of Uint, by their opcodes in uintTest.qnt
+var uintUnaryOps = map[string]func(sdk.Uint) sdk.Uint{
+	"incr": sdk.Uint.Incr,
+	"decr": sdk.Uint.Decr,
+}
+
+// NewUintFromBigInt is not re-exported by the types package of cosmos-sdk v0.46.4
+var newUintFromBigInt = sdkmath.NewUintFromBigInt
+
+// compare the result of an operation with the expected one, or expect a panic
+func checkUintResult(t *testing.T, s TestInput, op func() sdk.Uint) {
+	if s.result.error {
+		require.Panics(t, func() { op() })
+	} else {
+		actual := op()
+		expected := newUintFromBigInt(&s.result.value)
+		assert.True(t, expected.Equal(actual),
+			"the results should be equal, expected: %s, actual: %s", expected, actual)
+	}
+}
+
+// connect the test inputs to the actual code
+func executeUintTest(t *testing.T, s TestInput) {
+	switch s.opcode {
+	case "newUint":
+		checkUintResult(t, s, func() sdk.Uint { return sdk.NewUint(s.arg1.value.Uint64()) })
+
+	case "newUintFromBigInt":
+		checkUintResult(t, s, func() sdk.Uint { return newUintFromBigInt(&s.arg1.value) })
+
+	default:
+		if op, found := uintUnaryOps[s.opcode]; found {
+			arg1 := newUintFromBigInt(&s.arg1.value)
+			checkUintResult(t, s, func() sdk.Uint { return op(arg1) })
+		} else if op, found := uintBinaryOps[s.opcode]; found {
+			arg1 := newUintFromBigInt(&s.arg1.value)
+			arg2 := newUintFromBigInt(&s.arg2.value)
+			checkUintResult(t, s, func() sdk.Uint { return op(arg1, arg2) })
+		} else {
+			require.Fail(t, "unknown opcode: "+s.opcode)
+		}
+	}
+}
+
+// execute all steps of a trace of uintTest.qnt, one by one
+func ExecUintFromItf(t *testing.T, filename string) {
+	var states = parseItf(filename)
+	for i, s := range states {
+		t.Run(stepName(filename, i, s), func(t *testing.T) {
+			executeUintTest(t, s)
+		})
+	}
+}
+
+func TestUintOneRun(t *testing.T) {
+	ExecUintFromItf(t, tracePath("uintRandom.itf.json"))
+}
+
+func TestUintUnderflow(t *testing.T) {
+	ExecUintFromItf(t, tracePath("uintUnderflow.itf.json"))
+}
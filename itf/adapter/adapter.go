@@ -0,0 +1,304 @@
+// Package adapter connects ITF traces to systems under test outside of
+// `go test`. An adapter knows how to bring a system under test into the
+// initial state of a trace, how to execute the action that led to every
+// following state, and how to compare the system with that state.
+//
+// Adapters register themselves by name, usually in an init function, so the
+// command itfrun can pick them by name, either from the adapters that are
+// compiled into it, or from a Go plugin that registers its adapters when it is
+// loaded.
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// Adapter drives a system under test through the states of a trace.
+type Adapter interface {
+	// Reset brings the system under test into the initial state of a trace.
+	Reset(init gjson.Result) error
+	// Step executes the action that led to a state, and compares the system
+	// under test with the state. If they diverged, it returns a *Mismatch, or
+	// an error that is marked with Diverged. Any other error, e.g., of a lost
+	// connection to the system under test, stops the replay as a failure.
+	Step(state gjson.Result) error
+}
+
+// Factory creates an adapter, e.g., from the address of a long-running service.
+// The argument is passed as is from the command line, and it may be empty.
+type Factory func(arg string) (Adapter, error)
+
+var (
+	mu        sync.Mutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes an adapter available by name. It panics if the name is taken,
+// as registering two adapters under the same name is a programming error.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, found := factories[name]; found {
+		panic(fmt.Sprintf("adapter %q is registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// New creates the adapter of a name.
+func New(name string, arg string) (Adapter, error) {
+	mu.Lock()
+	factory, found := factories[name]
+	mu.Unlock()
+	if !found {
+		return nil, fmt.Errorf("unknown adapter %q, known adapters: %v", name, Names())
+	}
+	return factory(arg)
+}
+
+// Names returns the names of the registered adapters, in order.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Options are the key-value pairs of an adapter argument, which is written as
+// key1=value1,key2=value2,... A key may be repeated, e.g., to give several
+// state variables to compare: compare=p.sender,compare=p.receiver.
+type Options map[string][]string
+
+// ParseOptions parses an adapter argument into options.
+func ParseOptions(arg string) (Options, error) {
+	opts := make(Options)
+	if arg == "" {
+		return opts, nil
+	}
+	for _, kv := range strings.Split(arg, ",") {
+		key, value, found := strings.Cut(kv, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("expected key=value, found %q", kv)
+		}
+		opts[key] = append(opts[key], value)
+	}
+	return opts, nil
+}
+
+// Get returns the last value of a key, or def if the key is missing.
+func (o Options) Get(key, def string) string {
+	if values := o[key]; len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return def
+}
+
+// Divergence is the error of a replay, in which the system under test
+// diverged from a state of the trace.
+type Divergence struct {
+	// the index of the state in the trace
+	State int
+	Err   error
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("state %d: %v", d.State, d.Err)
+}
+
+func (d *Divergence) Unwrap() error {
+	return d.Err
+}
+
+// Diverged marks an error of an adapter as a divergence of the system under
+// test from a state, which is not a *Mismatch, e.g., an operation that failed,
+// while the trace expected it to succeed:
+//
+//	return adapter.Diverged(fmt.Errorf("%s: expected the error %q, found %q", op, expected, actual))
+//
+// The message of the error is kept as it is.
+func Diverged(err error) error {
+	return &diverged{err}
+}
+
+// an error that is marked with Diverged
+type diverged struct {
+	err error
+}
+
+func (d *diverged) Error() string {
+	return d.err.Error()
+}
+
+func (d *diverged) Unwrap() error {
+	return d.err
+}
+
+// IsDivergence tells whether an error of an adapter is a divergence of the
+// system under test, i.e., a *Mismatch, or an error marked with Diverged.
+func IsDivergence(err error) bool {
+	var mismatch *Mismatch
+	var marked *diverged
+	return errors.As(err, &mismatch) || errors.As(err, &marked)
+}
+
+// Mismatch is the error of an adapter, when a value of the system under test,
+// e.g., a state variable or the result of an operation, differs from the
+// value in the trace. The values are written in ITF JSON.
+type Mismatch struct {
+	// the path of the value, e.g., p.sender
+	Path     string
+	Expected string
+	Actual   string
+	// the parts of the values, in which they differ, if they are not the
+	// values themselves, see itf.Diff
+	Diffs []itf.Difference
+}
+
+func (m *Mismatch) Error() string {
+	msg := fmt.Sprintf("%s: expected %s, found %s", m.Path, m.Expected, m.Actual)
+	for _, d := range m.Diffs {
+		msg += fmt.Sprintf("\n  %s%v", m.Path, d)
+	}
+	return msg
+}
+
+// Compare compares a value of the system under test with the value of a
+// trace at a path, and returns a *Mismatch, if they differ. The values are
+// compared by their fingerprints, see itf.FingerprintOf, and only if they
+// differ, their canonical forms and the differences are computed for the
+// error.
+func Compare(path string, expected, actual gjson.Result) error {
+	e, err := itf.FingerprintOf(expected)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	a, err := itf.FingerprintOf(actual)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if e == a {
+		return nil
+	}
+	return NewMismatch(path, expected, actual)
+}
+
+// NewMismatch returns the mismatch of two values, which are known to differ,
+// e.g., by their fingerprints, with the parts of the values that differ.
+func NewMismatch(path string, expected, actual gjson.Result) error {
+	e, err := itf.Canonical(expected)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	a, err := itf.Canonical(actual)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	diffs, err := itf.Diff(expected, actual)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if len(diffs) == 1 && diffs[0].Path == "" && !expected.Get(`\#set`).Exists() {
+		// the values themselves differ, e.g., two integers
+		diffs = nil
+	}
+	return &Mismatch{Path: path, Expected: e, Actual: a, Diffs: diffs}
+}
+
+// Applier is an adapter that also executes the action that led to a state
+// without comparing the system under test with the state. It is used to
+// fast-forward the system under test to a state deep in a trace.
+type Applier interface {
+	Adapter
+	// Apply executes the action that led to a state. It returns an error if
+	// the action failed, e.g., with an error that the spec did not expect.
+	Apply(state gjson.Result) error
+}
+
+// Range restricts a replay to the states From to To of a trace, both
+// inclusive, e.g., to debug a single state deep in a long trace. A negative To
+// is the last state of the trace.
+//
+// By default, the system under test is reset to the state From, which works
+// for the adapters that bring a system under test into any state, and for the
+// traces of independent operations. With FastForward, the system under test
+// is reset to the initial state, and the states before From are executed as a
+// setup: with Apply, if the adapter is an Applier, or with Step otherwise.
+type Range struct {
+	From, To    int
+	FastForward bool
+}
+
+// All is the range of all states of a trace.
+var All = Range{From: 0, To: -1}
+
+// Run replays a trace against an adapter. It stops at the first state, from
+// which the system under test diverged, and returns a *Divergence then, see
+// IsDivergence. It also stops at any other error of the adapter, which it
+// returns with the index of the state, but not as a *Divergence.
+// If onState is not nil, it is called after every state that was replayed.
+func Run(a Adapter, trace itf.Trace, onState func(i int, state gjson.Result)) error {
+	return RunRange(a, trace, All, onState)
+}
+
+// RunRange replays a range of the states of a trace against an adapter, as
+// Run does. The states of a fast-forward are not passed to onState, but a
+// divergence in them is returned as usual. If the adapter is Resolving, the
+// choices of the states before From are only resolved with FastForward.
+func RunRange(a Adapter, trace itf.Trace, r Range, onState func(i int, state gjson.Result)) error {
+	if len(trace.States) == 0 {
+		return nil
+	}
+	to := r.To
+	if to < 0 || to >= len(trace.States) {
+		to = len(trace.States) - 1
+	}
+	if r.From < 0 || r.From > to {
+		return fmt.Errorf("expected a range of states in 0..%d, found %d..%d", len(trace.States)-1, r.From, r.To)
+	}
+	start := r.From
+	if r.FastForward {
+		start = 0
+	}
+	applier, canApply := a.(Applier)
+	var resolved *resolutions
+	if resolving, ok := a.(Resolving); ok {
+		resolved = newResolutions(resolving.Resolvers())
+	}
+	for i := start; i <= to; i++ {
+		state := trace.States[i]
+		var err error
+		if resolved != nil {
+			state, err = resolved.resolve(state)
+		}
+		switch {
+		case err != nil:
+		case i == start:
+			err = a.Reset(state)
+		case i < r.From && canApply:
+			err = applier.Apply(state)
+		default:
+			err = a.Step(state)
+		}
+		if err != nil {
+			if IsDivergence(err) {
+				return &Divergence{State: i, Err: err}
+			}
+			return fmt.Errorf("state %d: %w", i, err)
+		}
+		if onState != nil && i >= r.From {
+			onState(i, state)
+		}
+	}
+	return nil
+}
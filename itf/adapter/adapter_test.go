@@ -0,0 +1,187 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions("addr=localhost:50051,compare=p.sender,compare=p.receiver")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:50051", opts.Get("addr", ""))
+	assert.Equal(t, []string{"p.sender", "p.receiver"}, opts["compare"])
+	assert.Equal(t, "lastAction.kind", opts.Get("op", "lastAction.kind"))
+
+	_, err = ParseOptions("addr")
+	assert.Error(t, err)
+}
+
+// a registry of accounts, whose ids are picked by the spec, and generated by
+// the system under test
+const accountsTrace = `{
+  "vars": [ "accounts", "mbt::nondetPicks" ],
+  "states": [
+    { "accounts": { "#set": [] },
+      "mbt::nondetPicks": { "id": { "tag": "None", "value": { "#tup": [] } } } },
+    { "accounts": { "#set": [ "acc7" ] },
+      "mbt::nondetPicks": { "id": { "tag": "Some", "value": "acc7" } } },
+    { "accounts": { "#set": [ "acc3", "acc7" ] },
+      "mbt::nondetPicks": { "id": { "tag": "Some", "value": "acc3" } } },
+    { "accounts": { "#set": [ "acc7" ] },
+      "mbt::nondetPicks": { "id": { "tag": "None", "value": { "#tup": [] } } } }
+  ]
+}`
+
+type registry struct {
+	next     int
+	accounts map[string]bool
+}
+
+func (r *registry) Reset(gjson.Result) error {
+	r.next, r.accounts = 100, make(map[string]bool)
+	return nil
+}
+
+// open an account with the id of the state, or close the account that the
+// spec does not have anymore
+func (r *registry) Step(state gjson.Result) error {
+	if id := state.Get("mbt::nondetPicks.id"); id.Get("tag").String() == "Some" {
+		r.accounts[id.Get("value").String()] = true
+	}
+	expected := make(map[string]bool)
+	for _, id := range state.Get("accounts.\\#set").Array() {
+		expected[id.String()] = true
+	}
+	for id := range r.accounts {
+		if !expected[id] {
+			delete(r.accounts, id)
+		}
+	}
+	if len(expected) != len(r.accounts) {
+		return Diverged(fmt.Errorf("expected %v, found %v", expected, r.accounts))
+	}
+	return nil
+}
+
+func (r *registry) Resolvers() map[string]Resolver {
+	return map[string]Resolver{
+		"id": func(gjson.Result) (gjson.Result, error) {
+			r.next++
+			return gjson.Parse(fmt.Sprintf(`"acc%d"`, r.next)), nil
+		},
+	}
+}
+
+func TestRunResolves(t *testing.T) {
+	trace, err := itf.Parse([]byte(accountsTrace))
+	require.NoError(t, err)
+	var accounts []string
+	r := &registry{}
+	err = Run(r, trace, func(_ int, state gjson.Result) {
+		accounts = append(accounts, itf.Show(state.Get("accounts")))
+	})
+	require.NoError(t, err)
+	// the ids of the spec are replaced with the ids of the system under test,
+	// also in the states after the ones that picked them
+	assert.Equal(t, []string{`Set()`, `Set("acc101")`, `Set("acc102", "acc101")`, `Set("acc101")`}, accounts)
+	assert.Equal(t, map[string]bool{"acc101": true}, r.accounts)
+
+	err = Run(&exhaustedRegistry{}, trace, nil)
+	assert.EqualError(t, err, "state 1: resolving id: out of ids")
+}
+
+// a registry that cannot generate ids
+type exhaustedRegistry struct {
+	registry
+}
+
+func (r *exhaustedRegistry) Resolvers() map[string]Resolver {
+	return map[string]Resolver{
+		"id": func(gjson.Result) (gjson.Result, error) {
+			return gjson.Result{}, errors.New("out of ids")
+		},
+	}
+}
+
+const bankState = `{
+  "balances": { "#map": [ [ "alice", { "#bigint": "1500000000000000000" } ], [ "bob", { "#bigint": "0" } ] ] },
+  "denoms": { "#set": [ "uatom", "uosmo" ] },
+  "supply": { "#bigint": "1500000000000000000" },
+  "height": 7
+}`
+
+func TestMapping(t *testing.T) {
+	m := NewMapping().
+		Scaled("balances", "bank.balances", -18).
+		Var("denoms", "bank.denoms").
+		Scaled("supply", "bank.supply", -18).
+		Var("height", "")
+	state := gjson.Parse(bankState)
+	// the bank writes its balances in whole tokens, as decimals or integers,
+	// its maps as objects, and its sets in any order
+	snapshot := gjson.Parse(`{
+	  "bank": {
+	    "balances": { "bob": 0, "alice": "1.500000000000000000" },
+	    "denoms": { "#set": [ "uosmo", "uatom" ] },
+	    "supply": "1.5"
+	  },
+	  "height": { "#bigint": "7" }
+	}`)
+	require.NoError(t, m.Compare(state, snapshot))
+
+	diverged := gjson.Parse(`{
+	  "bank": { "balances": { "alice": "1.5", "bob": "0.000000000000000001" }, "denoms": [], "supply": "1.5" },
+	  "height": 7
+	}`)
+	var mismatch *Mismatch
+	require.ErrorAs(t, m.Compare(state, diverged), &mismatch)
+	assert.Equal(t, "balances", mismatch.Path)
+
+	_, err := normalize(gjson.Parse(`"atom"`), gjson.Parse("1"), 0)
+	assert.ErrorContains(t, err, `expected a number, found: "atom"`)
+	assert.ErrorContains(t, NewMapping().Var("supply", "total").Compare(state, snapshot), "total: missing in the snapshot")
+	assert.ErrorContains(t, NewMapping().Var("missing", "").Compare(state, snapshot), "missing: missing in the state")
+}
+
+func TestReadMapping(t *testing.T) {
+	dir := t.TempDir()
+	filename := dir + "/mapping.json"
+	require.NoError(t, os.WriteFile(filename, []byte(`{
+	  "vars": [ { "spec": "supply", "sut": "bank.supply", "scale": -18 }, { "spec": "height" } ]
+	}`), 0o644))
+	m, err := ReadMapping(filename)
+	require.NoError(t, err)
+	assert.Equal(t, NewMapping().Scaled("supply", "bank.supply", -18).Var("height", ""), m)
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{ "vars": [ { "sut": "bank.supply" } ] }`), 0o644))
+	_, err = ReadMapping(filename)
+	assert.ErrorContains(t, err, "variable 0: missing the path of the spec")
+}
+
+func TestCompare(t *testing.T) {
+	require.NoError(t, Compare("x", gjson.Parse(`{ "#set": [ 1, 2 ] }`), gjson.Parse(`{ "#set": [ 2, { "#bigint": "1" } ] }`)))
+
+	err := Compare("p", gjson.Parse(`{ "sender": { "seq": 1, "window": { "#set": [ 1, 2 ] } }, "receiver": 0 }`),
+		gjson.Parse(`{ "sender": { "seq": 2, "window": { "#set": [ 2 ] } }, "receiver": 0 }`))
+	var mismatch *Mismatch
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, []itf.Difference{
+		{Path: ".sender.seq", Expected: "1", Actual: "2"},
+		{Path: ".sender.window", Expected: "Set(1)", Actual: "Set()"},
+	}, mismatch.Diffs)
+	assert.Contains(t, err.Error(), "\n  p.sender.seq: expected 1, found 2\n  p.sender.window: expected Set(1), found Set()")
+
+	// the differences of two integers are the integers themselves
+	err = Compare("counter", gjson.Parse("1"), gjson.Parse("2"))
+	assert.EqualError(t, err, `counter: expected {"#bigint":"1"}, found {"#bigint":"2"}`)
+
+	assert.ErrorContains(t, Compare("x", gjson.Parse(`{ "#bigint": "ten" }`), gjson.Parse("1")), "x: ")
+}
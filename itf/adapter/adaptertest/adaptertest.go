@@ -0,0 +1,71 @@
+// Package adaptertest provides the traces and the adapters that the tests of
+// the adapters share, e.g., the tests of itf/adapter and of itfrun.
+package adaptertest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// CounterTrace is a trace of a counter, which is incremented twice, and then
+// doubled. Every state records its action in lastAction.kind. As Counter only
+// increments, it diverges at the state 3.
+const CounterTrace = `{
+  "vars": [ "counter", "lastAction" ],
+  "states": [
+    { "counter": { "#bigint": "0" }, "lastAction": { "kind": "init" } },
+    { "counter": { "#bigint": "1" }, "lastAction": { "kind": "inc" } },
+    { "counter": { "#bigint": "2" }, "lastAction": { "kind": "inc" } },
+    { "counter": { "#bigint": "4" }, "lastAction": { "kind": "double" } }
+  ]
+}`
+
+// AmountTrace is a trace of a counter, which is incremented and decremented
+// by the amounts of the actions in lastAction. A decrement below zero fails
+// with the error "underflow", and leaves the counter as it is. The systems
+// under test of the remote adapters, e.g., grpc and wasm, replay it.
+const AmountTrace = `{
+  "vars": [ "counter", "lastAction" ],
+  "states": [
+    { "#meta": { "index": 0 }, "counter": { "#bigint": "0" },
+      "lastAction": { "kind": "init", "amount": { "#bigint": "0" }, "error": "" } },
+    { "counter": { "#bigint": "3" },
+      "lastAction": { "kind": "inc", "amount": { "#bigint": "3" }, "error": "" } },
+    { "counter": { "#bigint": "3" },
+      "lastAction": { "kind": "dec", "amount": { "#bigint": "4" }, "error": "underflow" } },
+    { "counter": { "#bigint": "1" },
+      "lastAction": { "kind": "dec", "amount": { "#bigint": "2" }, "error": "" } }
+  ]
+}`
+
+// Parse parses a trace, e.g., CounterTrace, and fails the test if it cannot.
+func Parse(t testing.TB, trace string) itf.Trace {
+	parsed, err := itf.Parse([]byte(trace))
+	require.NoError(t, err)
+	return parsed
+}
+
+// Counter is an adapter of CounterTrace, which increments the counter by one
+// on every step.
+type Counter struct {
+	Value int64
+}
+
+func (c *Counter) Reset(init gjson.Result) error {
+	c.Value = init.Get("counter.\\#bigint").Int()
+	return nil
+}
+
+func (c *Counter) Step(state gjson.Result) error {
+	c.Value++
+	if expected := state.Get("counter.\\#bigint").Int(); expected != c.Value {
+		return adapter.Diverged(fmt.Errorf("counter: expected %d, found %d", expected, c.Value))
+	}
+	return nil
+}
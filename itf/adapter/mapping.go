@@ -0,0 +1,245 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Mapping declares how the state variables of a spec correspond to the state
+// of a system under test, so an adapter compares a snapshot of the system with
+// a state of a trace without code for every variable. A mapping is either
+// built in Go:
+//
+//	m := adapter.NewMapping().
+//		Var("balances", "bank.balances").
+//		Scaled("supply", "bank.totalSupply", -18)
+//
+// or read from a JSON file with ReadMapping:
+//
+//	{
+//	  "vars": [
+//	    { "spec": "balances", "sut": "bank.balances" },
+//	    { "spec": "supply", "sut": "bank.totalSupply", "scale": -18 }
+//	  ]
+//	}
+type Mapping struct {
+	Vars []VarMapping `json:"vars"`
+}
+
+// VarMapping maps a part of a state of the spec to a part of a snapshot.
+type VarMapping struct {
+	// the gjson path in the state of the spec, e.g., balances
+	Spec string `json:"spec"`
+	// the gjson path in the snapshot of the system under test, or the path of
+	// the spec, if it is empty
+	SUT string `json:"sut"`
+	// The power of ten, by which the integers of the spec are multiplied to be
+	// the numbers of the system under test, e.g., -18, if the spec counts in
+	// 10^-18 units, and the system writes decimals, such as "1.5", or 18, if it
+	// is the other way around. The integers in the values of the variable are
+	// scaled, and so are the numbers in the snapshot that are written as
+	// strings. Zero, if the units are the same.
+	Scale int `json:"scale"`
+}
+
+// NewMapping returns an empty mapping.
+func NewMapping() *Mapping {
+	return &Mapping{}
+}
+
+// Var maps a part of a state of the spec to a part of a snapshot with the
+// same units.
+func (m *Mapping) Var(spec, sut string) *Mapping {
+	return m.Scaled(spec, sut, 0)
+}
+
+// Scaled maps a part of a state of the spec to a part of a snapshot, whose
+// numbers are the integers of the spec multiplied by 10^scale.
+func (m *Mapping) Scaled(spec, sut string, scale int) *Mapping {
+	m.Vars = append(m.Vars, VarMapping{Spec: spec, SUT: sut, Scale: scale})
+	return m
+}
+
+// ReadMapping reads a mapping from a JSON file.
+func ReadMapping(filename string) (*Mapping, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m Mapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for i, v := range m.Vars {
+		if v.Spec == "" {
+			return nil, fmt.Errorf("%s: variable %d: missing the path of the spec", filename, i)
+		}
+	}
+	return &m, nil
+}
+
+// Compare compares a snapshot of the system under test, in JSON, with a state
+// of the spec, and returns a *Mismatch at the path of the spec, if they
+// differ. Both are compared as ITF values, so integers may be written as JSON
+// numbers, as strings, or as {"#bigint": "..."} in the snapshot, and a map
+// with string keys may be written as a JSON object.
+func (m *Mapping) Compare(state, snapshot gjson.Result) error {
+	for _, v := range m.Vars {
+		sut := v.SUT
+		if sut == "" {
+			sut = v.Spec
+		}
+		expected := state.Get(v.Spec)
+		if !expected.Exists() {
+			return fmt.Errorf("%s: missing in the state", v.Spec)
+		}
+		actual := snapshot.Get(sut)
+		if !actual.Exists() {
+			return fmt.Errorf("%s: missing in the snapshot", sut)
+		}
+		e, err := normalize(expected, expected, v.Scale)
+		if err != nil {
+			return fmt.Errorf("%s: %v", v.Spec, err)
+		}
+		a, err := normalize(actual, expected, 0)
+		if err != nil {
+			return fmt.Errorf("%s: %v", sut, err)
+		}
+		if err := Compare(v.Spec, gjson.Parse(e), gjson.Parse(a)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rewrite a value into ITF JSON, in which the integers are multiplied by
+// 10^scale and written as {"#num": "p/q"} in lowest terms, so the numbers of
+// the spec and the snapshot are compared regardless of how they are written.
+// The shape of the value of the spec tells which strings of the snapshot are
+// numbers, and which JSON objects are maps.
+func normalize(v, shape gjson.Result, scale int) (string, error) {
+	if isNumber(shape) {
+		r, ok := rat(v)
+		if !ok {
+			return "", fmt.Errorf("expected a number, found: %s", v.Raw)
+		}
+		r.Mul(r, pow10(scale))
+		return fmt.Sprintf(`{"#num":%q}`, r.RatString()), nil
+	}
+	if shape.Get(`\#map`).Exists() && v.IsObject() && !v.Get(`\#map`).Exists() {
+		// a map with string keys, which the system under test writes as an object
+		var pairs []string
+		var err error
+		v.ForEach(func(key, value gjson.Result) bool {
+			var s string
+			s, err = normalize(value, mapValueShape(shape), scale)
+			k, _ := json.Marshal(key.String())
+			pairs = append(pairs, "["+string(k)+","+s+"]")
+			return err == nil
+		})
+		return `{"#map":[` + strings.Join(pairs, ",") + "]}", err
+	}
+	switch {
+	case v.IsArray():
+		var elems []string
+		var err error
+		i := 0
+		v.ForEach(func(_, e gjson.Result) bool {
+			var s string
+			s, err = normalize(e, elemShape(shape, i), scale)
+			elems = append(elems, s)
+			i++
+			return err == nil
+		})
+		return "[" + strings.Join(elems, ",") + "]", err
+	case v.IsObject():
+		var fields []string
+		var err error
+		v.ForEach(func(name, e gjson.Result) bool {
+			var s string
+			s, err = normalize(e, fieldShape(shape, name.String()), scale)
+			fields = append(fields, name.Raw+":"+s)
+			return err == nil
+		})
+		return "{" + strings.Join(fields, ",") + "}", err
+	}
+	return v.Raw, nil
+}
+
+// whether a value of the spec is an integer
+func isNumber(v gjson.Result) bool {
+	return v.Type == gjson.Number || v.Get(`\#bigint`).Exists()
+}
+
+// The shapes of the parts of a value of the spec. The elements of a set or a
+// list are assumed to have the same shape, so the first one stands for all.
+func elemShape(shape gjson.Result, i int) gjson.Result {
+	if shape.IsArray() {
+		if e := shape.Get(fmt.Sprint(i)); e.Exists() {
+			return e
+		}
+		return shape.Get("0")
+	}
+	return gjson.Result{}
+}
+
+func fieldShape(shape gjson.Result, name string) gjson.Result {
+	if shape.IsObject() {
+		return shape.Get(gjsonKey(name))
+	}
+	return gjson.Result{}
+}
+
+func mapValueShape(shape gjson.Result) gjson.Result {
+	return shape.Get(`\#map.0.1`)
+}
+
+// a key of an object as a gjson path
+func gjsonKey(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		switch c {
+		case '.', '*', '?', '#', '|', '@', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// a number, which is written as a JSON number, as a string of a decimal,
+// e.g., "1.5", or as {"#bigint": "..."}
+func rat(v gjson.Result) (*big.Rat, bool) {
+	var digits string
+	switch {
+	case v.Type == gjson.Number:
+		digits = v.Raw
+	case v.Type == gjson.String:
+		digits = v.Str
+	case v.Get(`\#bigint`).Exists():
+		digits = v.Get(`\#bigint`).String()
+	default:
+		return nil, false
+	}
+	return new(big.Rat).SetString(digits)
+}
+
+func pow10(scale int) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(scale))), nil)
+	if scale < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+	return new(big.Rat).SetInt(p)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,143 @@
+package adapter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// Resolver decides a nondeterministic choice of a spec that belongs to the
+// system under test, e.g., a generated id or a timestamp. It is called with the
+// value that the spec picked, before the state of the choice is replayed, and
+// it returns the value that the system under test picks instead, in ITF JSON.
+type Resolver func(picked gjson.Result) (gjson.Result, error)
+
+// Resolving is an adapter that decides some of the nondeterministic choices of
+// a spec. The replay substitutes the values of the system under test for the
+// values that the spec picked, in the state of every choice and in all the
+// states that follow it, so Reset, Step, and Apply see the values of the system
+// under test, e.g., in the state variables and in mbt::nondetPicks.
+//
+// Every occurrence of a picked value is substituted, so the resolvers are meant
+// for the values that cannot be confused with others, e.g., ids and
+// timestamps, and not for small integers. A value that is picked again keeps
+// the value of the system under test that it was resolved to first.
+type Resolving interface {
+	Adapter
+	// Resolvers returns the resolvers by the names of the nondeterministic
+	// values, as quint run --mbt writes them in mbt::nondetPicks.
+	Resolvers() map[string]Resolver
+}
+
+// the variable, in which quint run --mbt writes the nondeterministic choices
+// of every step
+const nondetPicks = "mbt::nondetPicks"
+
+// the values of the system under test that were resolved during a replay
+type resolutions struct {
+	resolvers map[string]Resolver
+	// the names of the resolvers, in order
+	names []string
+	// the values of the system under test by the canonical forms of the
+	// values of the spec
+	subst map[string]string
+}
+
+func newResolutions(resolvers map[string]Resolver) *resolutions {
+	names := make([]string, 0, len(resolvers))
+	for name := range resolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &resolutions{resolvers: resolvers, names: names, subst: make(map[string]string)}
+}
+
+// resolve the choices of a state, and substitute all resolved values in it
+func (r *resolutions) resolve(state gjson.Result) (gjson.Result, error) {
+	picks := state.Get(nondetPicks)
+	for _, name := range r.names {
+		picked, found := pickedValue(picks.Get(name))
+		if !found {
+			continue
+		}
+		key, err := itf.Canonical(picked)
+		if err != nil {
+			return state, fmt.Errorf("%s: %v", name, err)
+		}
+		if _, resolved := r.subst[key]; resolved {
+			continue
+		}
+		actual, err := r.resolvers[name](picked)
+		if err != nil {
+			return state, fmt.Errorf("resolving %s: %v", name, err)
+		}
+		if !gjson.Valid(actual.Raw) {
+			return state, fmt.Errorf("resolving %s: expected a value in ITF JSON, found: %s", name, actual.Raw)
+		}
+		r.subst[key] = actual.Raw
+	}
+	if len(r.subst) == 0 {
+		return state, nil
+	}
+	raw, err := substitute(state, r.subst)
+	if err != nil {
+		return state, err
+	}
+	return gjson.Parse(raw), nil
+}
+
+// The value of a choice, which Quint writes as an Option, e.g.,
+// {"tag": "Some", "value": 3}, or {"tag": "None", ...} if nothing was picked
+// in a step. Older versions of Quint write the value itself.
+func pickedValue(v gjson.Result) (gjson.Result, bool) {
+	if !v.Exists() {
+		return v, false
+	}
+	switch v.Get("tag").String() {
+	case "Some":
+		return v.Get("value"), true
+	case "None":
+		return v, false
+	}
+	return v, true
+}
+
+// rewrite a value, in which every value in subst is substituted
+func substitute(v gjson.Result, subst map[string]string) (string, error) {
+	key, err := itf.Canonical(v)
+	if err != nil {
+		return "", err
+	}
+	if actual, found := subst[key]; found {
+		return actual, nil
+	}
+	if v.Get(`\#bigint`).Exists() {
+		// the digits of an integer are not a string to substitute
+		return v.Raw, nil
+	}
+	switch {
+	case v.IsArray():
+		var elems []string
+		v.ForEach(func(_, e gjson.Result) bool {
+			var s string
+			s, err = substitute(e, subst)
+			elems = append(elems, s)
+			return err == nil
+		})
+		return "[" + strings.Join(elems, ",") + "]", err
+	case v.IsObject():
+		var fields []string
+		v.ForEach(func(name, e gjson.Result) bool {
+			var s string
+			s, err = substitute(e, subst)
+			fields = append(fields, name.Raw+":"+s)
+			return err == nil
+		})
+		return "{" + strings.Join(fields, ",") + "}", err
+	}
+	return v.Raw, nil
+}
@@ -0,0 +1,105 @@
+package adapter_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+	"github.com/informalsystems/quint-sandbox/itf/adapter/adaptertest"
+)
+
+// The tests of Run replay the traces of adaptertest, which imports this
+// package, so they are in a package of their own.
+
+func TestRunStopsAtDivergence(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.CounterTrace)
+	var replayed []int
+	err := adapter.Run(&adaptertest.Counter{}, trace, func(i int, _ gjson.Result) { replayed = append(replayed, i) })
+	var divergence *adapter.Divergence
+	require.True(t, errors.As(err, &divergence), "expected a divergence, found %v", err)
+	assert.Equal(t, 3, divergence.State)
+	assert.EqualError(t, err, "state 3: counter: expected 4, found 3")
+	assert.Equal(t, []int{0, 1, 2}, replayed)
+}
+
+// a counter that cannot be reset, e.g., as the service is unreachable
+type unreachable struct {
+	adaptertest.Counter
+}
+
+func (unreachable) Reset(gjson.Result) error {
+	return errors.New("connection refused")
+}
+
+func TestRunReturnsOtherErrors(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.CounterTrace)
+	err := adapter.Run(&unreachable{}, trace, nil)
+	assert.EqualError(t, err, "state 0: connection refused")
+	var divergence *adapter.Divergence
+	assert.False(t, errors.As(err, &divergence))
+
+	assert.True(t, adapter.IsDivergence(fmt.Errorf("step: %w", &adapter.Mismatch{Path: "counter"})))
+	assert.True(t, adapter.IsDivergence(fmt.Errorf("step: %w", adapter.Diverged(errors.New("unexpected error")))))
+	assert.False(t, adapter.IsDivergence(errors.New("connection refused")))
+}
+
+// a counter that also counts the states that were applied without a comparison
+type applyingCounter struct {
+	adaptertest.Counter
+	applied int
+}
+
+func (c *applyingCounter) Apply(gjson.Result) error {
+	c.Value++
+	c.applied++
+	return nil
+}
+
+func TestRunRange(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.CounterTrace)
+	replay := func(a adapter.Adapter, r adapter.Range) ([]int, error) {
+		var replayed []int
+		err := adapter.RunRange(a, trace, r, func(i int, _ gjson.Result) { replayed = append(replayed, i) })
+		return replayed, err
+	}
+
+	// reset to the state 1, and stop before the divergence
+	replayed, err := replay(&adaptertest.Counter{}, adapter.Range{From: 1, To: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, replayed)
+
+	// the states 1 and 2 are a setup
+	c := &applyingCounter{}
+	replayed, err = replay(c, adapter.Range{From: 3, To: -1, FastForward: true})
+	assert.EqualError(t, err, "state 3: counter: expected 4, found 3")
+	assert.Empty(t, replayed)
+	assert.Equal(t, 2, c.applied)
+
+	// without Apply, the setup is stepped through
+	replayed, err = replay(&adaptertest.Counter{}, adapter.Range{From: 2, To: 2, FastForward: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, replayed)
+
+	_, err = replay(&adaptertest.Counter{}, adapter.Range{From: 3, To: 1})
+	assert.ErrorContains(t, err, "expected a range of states in 0..3, found 3..1")
+}
+
+func TestRegistry(t *testing.T) {
+	adapter.Register("counter", func(string) (adapter.Adapter, error) { return &adaptertest.Counter{}, nil })
+	assert.Contains(t, adapter.Names(), "counter")
+	assert.Panics(t, func() {
+		adapter.Register("counter", func(string) (adapter.Adapter, error) { return &adaptertest.Counter{}, nil })
+	})
+
+	a, err := adapter.New("counter", "")
+	require.NoError(t, err)
+	assert.IsType(t, &adaptertest.Counter{}, a)
+
+	_, err = adapter.New("nonexistent", "")
+	assert.ErrorContains(t, err, `unknown adapter "nonexistent"`)
+}
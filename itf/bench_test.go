@@ -0,0 +1,160 @@
+package itf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// The fixtures of the benchmarks are generated, so they have the shapes that
+// make parsers slow, and the repository does not carry megabytes of traces.
+
+// a trace of n states, whose state variables are written by state
+func fixture(n int, vars []string, state func(i int) string) []byte {
+	var b strings.Builder
+	b.WriteString(`{"#meta":{"format":"ITF","source":"bench.qnt"},"vars":[`)
+	for i, v := range vars {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%q", v)
+	}
+	b.WriteString(`],"states":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"#meta":{"index":%d},%s}`, i, state(i))
+	}
+	b.WriteString("]}")
+	return []byte(b.String())
+}
+
+// a record nested depth times, e.g., {"next":{"next":{"value":...}}}
+func deepRecord(depth int, i int) string {
+	return strings.Repeat(`{"id":"node","next":`, depth) +
+		fmt.Sprintf(`{"value":{"#bigint":"%d"}}`, i) +
+		strings.Repeat("}", depth)
+}
+
+// a set of n tuples of an address and a big integer
+func bigSet(n int, i int) string {
+	elems := make([]string, n)
+	for j := range elems {
+		elems[j] = fmt.Sprintf(`{"#tup":["addr%d",{"#bigint":"%d000000000000000000%d"}]}`, j, i+j, j)
+	}
+	return `{"#set":[` + strings.Join(elems, ",") + "]}"
+}
+
+// a map of n balances
+func balances(n int, i int) string {
+	entries := make([]string, n)
+	for j := range entries {
+		entries[j] = fmt.Sprintf(`["addr%d",{"#bigint":"%d"}]`, j, (i+1)*(j+1))
+	}
+	return `{"#map":[` + strings.Join(entries, ",") + "]}"
+}
+
+// the fixtures by their names
+var fixtures = map[string]func() []byte{
+	// a few states, each a record 64 levels deep
+	"deep-records": func() []byte {
+		return fixture(100, []string{"tree"}, func(i int) string {
+			return `"tree":` + deepRecord(64, i)
+		})
+	},
+	// a few states, each a set of 10,000 elements
+	"big-sets": func() []byte {
+		return fixture(10, []string{"delegations"}, func(i int) string {
+			return `"delegations":` + bigSet(10000, i)
+		})
+	},
+	// many small states, as a long random simulation writes them
+	"long-run": func() []byte {
+		return fixture(100000, []string{"balances", "lastAction", "step"}, func(i int) string {
+			return fmt.Sprintf(`"balances":%s,"lastAction":{"kind":"transfer","amount":{"#bigint":"%d"}},"step":{"#bigint":"%d"}`,
+				balances(3, i), i%17, i)
+		})
+	},
+}
+
+// the time to parse a trace of every fixture
+func BenchmarkParseItf(b *testing.B) {
+	for _, name := range []string{"deep-records", "big-sets", "long-run"} {
+		data := fixtures[name]()
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// the time to decode the values of the fixtures, once a trace is parsed
+func BenchmarkDecode(b *testing.B) {
+	deep := gjson.Parse(deepRecord(64, 1))
+	set := gjson.Parse(bigSet(10000, 1))
+	m := gjson.Parse(balances(1000, 1))
+	bigint := gjson.Parse(`{"#bigint":"-115792089237316195423570985008687907853269984665640564039457584007913129639935"}`)
+	longRunData := fixtures["long-run"]()
+	longRun, err := Parse(longRunData)
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := strings.TrimPrefix(strings.Repeat(".next", 64), ".") + ".value"
+
+	for _, bench := range []struct {
+		name   string
+		decode func() error
+	}{
+		{"BigInt", func() error {
+			_, err := BigInt(bigint)
+			return err
+		}},
+		{"Set", func() error {
+			_, err := Set(set)
+			return err
+		}},
+		{"StrBigIntMap", func() error {
+			_, err := StrBigIntMap(m)
+			return err
+		}},
+		{"DeepGet", func() error {
+			if !deep.Get(path).Exists() {
+				return fmt.Errorf("no value at %s", path)
+			}
+			return nil
+		}},
+		{"Canonical", func() error {
+			_, err := Canonical(deep)
+			return err
+		}},
+		{"Fingerprint", func() error {
+			_, err := FingerprintOf(deep)
+			return err
+		}},
+		{"SelectVars", func() error {
+			SelectVars(longRun, []string{"step"})
+			return nil
+		}},
+		{"ParseVars", func() error {
+			_, err := ParseVars(longRunData, []string{"step"})
+			return err
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := bench.decode(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
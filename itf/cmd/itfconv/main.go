@@ -0,0 +1,94 @@
+// A command that converts traces between the formats of the package
+// formats: ITF JSON, protobuf, CBOR, and MessagePack. The formats are given
+// by the extensions of the files, e.g.:
+//
+//	go run ./cmd/itfconv trace.itf.json trace.itf.pb
+//	go run ./cmd/itfconv trace.itf.json trace.itf.cbor
+//	go run ./cmd/itfconv trace.itf.msgpack trace.itf.json
+//
+// The format of the input is detected by its first bytes, if its extension
+// is unknown. The input may be compressed with gzip or zstd.
+//
+// With -vars, the output keeps only some of the state variables, see
+// itf.Trace.Project, e.g., to share a trace without the internal variables of
+// a spec:
+//
+//	go run ./cmd/itfconv -vars opcode,opResult trace.itf.json public.itf.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/formats"
+)
+
+func main() {
+	varList := flag.String("vars", "", "keep only these state variables, e.g., opcode,opResult")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-vars x,y] input.itf.{json,pb,cbor,msgpack} output.itf.{json,pb,cbor,msgpack}\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input, output := flag.Arg(0), flag.Arg(1)
+	data, err := os.ReadFile(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %s: %v\n", input, err)
+		os.Exit(1)
+	}
+	data, err = itf.Decompress(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %s: %v\n", input, err)
+		os.Exit(1)
+	}
+	to, err := formats.ParseFormat(filepath.Ext(output))
+	if err == nil {
+		data, err = formats.ToJSON(data, formats.Detect(input, data))
+	}
+	if err == nil && *varList != "" {
+		data, err = project(data, strings.Split(*varList, ","))
+	}
+	if err == nil {
+		data, err = formats.FromJSON(data, to)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error converting %s: %v\n", input, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing %s: %v\n", output, err)
+		os.Exit(1)
+	}
+}
+
+// project a trace in ITF JSON onto the state variables vars
+func project(data []byte, vars []string) ([]byte, error) {
+	trace, err := itf.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vars {
+		if !contains(trace.Vars, v) {
+			return nil, fmt.Errorf("unknown state variable %q, the trace has %v", v, trace.Vars)
+		}
+	}
+	return json.Marshal(trace.Project(vars...))
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
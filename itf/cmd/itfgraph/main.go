@@ -0,0 +1,72 @@
+// A command that writes the transition graph of traces in DOT or in Mermaid,
+// see the package graph. The states are abstracted to the values of the -var
+// flags, and the edges are labeled with the value of -action, e.g.:
+//
+//	go run ./cmd/itfgraph -var sys.tm.state -var 'sys.rms.\#map.#.1.state' \
+//	  ../twophase/test-inputs/*.itf.json > twophase.dot
+//	dot -Tsvg twophase.dot > twophase.svg
+//
+// The format is given by -format, which is dot or mermaid.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/informalsystems/quint-sandbox/itf/formats"
+	"github.com/informalsystems/quint-sandbox/itf/graph"
+)
+
+// the values of a repeated flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func main() {
+	var vars stringList
+	flag.Var(&vars, "var", "a gjson path of a value of the abstract states (repeatable), all state variables by default")
+	action := flag.String("action", "lastAction.kind", "the gjson path of the label of the action that led to a state")
+	format := flag.String("format", "dot", "the format of the graph, dot or mermaid")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] trace.itf.json...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || (*format != "dot" && *format != "mermaid") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	g := graph.New(graph.Abstraction{Vars: vars, Action: *action})
+	for _, filename := range flag.Args() {
+		trace, err := formats.ReadFile(filename)
+		if err == nil {
+			err = g.Add(trace)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+	}
+	var err error
+	if *format == "mermaid" {
+		err = g.WriteMermaid(os.Stdout)
+	} else {
+		err = g.WriteDOT(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing the graph: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%d traces: %d abstract states, %d edges, actions: %s\n",
+		flag.NArg(), len(g.Nodes), len(g.Edges), strings.Join(g.Actions(), ", "))
+}
@@ -0,0 +1,105 @@
+// A command that checks traces in ITF JSON before they are committed, see
+// itf.Lint, e.g.:
+//
+//	go run ./cmd/itflint ../decimal/test-inputs-v0.46.4 ../coins/test-inputs-v0.46.4
+//
+// A directory is searched recursively for *.itf.json, which may be compressed
+// with gzip or zstd, e.g., trace.itf.json.gz. Every problem is printed with the
+// file and its path in the trace, and the command fails, if there is one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// whether a file in a directory is a trace in ITF JSON
+func isTrace(name string) bool {
+	for _, ext := range []string{".itf.json", ".itf.json.gz", ".itf.json.zst"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// the traces of the arguments, which are files or directories
+func traces(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && isTrace(d.Name()) {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// lint a trace, and print its problems
+func lint(filename string) (int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	data, err = itf.Decompress(data)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", filename, err)
+	}
+	errs := itf.Lint(data)
+	for _, e := range errs {
+		fmt.Printf("%s: %v\n", filename, e)
+	}
+	return len(errs), nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s trace.itf.json|dir...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	files, err := traces(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	problems, failed := 0, 0
+	for _, filename := range files {
+		n, err := lint(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %v\n", err)
+			os.Exit(1)
+		}
+		problems += n
+		if n > 0 {
+			failed++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d traces: %d problems in %d traces\n", len(files), problems, failed)
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,91 @@
+// A command that selects a small subset of a corpus of traces, which covers
+// every operation of the corpus with the same outcomes, succeeding or failing,
+// see itf.Reduce. The operation of a state is found at the gjson path -op, and
+// its error at the path -error, as in itf.List, e.g.:
+//
+//	go run ./cmd/itfreduce -op opcode -error opResult.error \
+//	  -out ../decimal/test-inputs-v0.46.4 /tmp/fuzz/*.itf.json
+//
+// The selected traces are printed, with the branches that they add, and, with
+// -out, copied to a directory. The input may be compressed with gzip or zstd,
+// and it is copied as it is.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// copy a file into a directory
+func copyFile(filename, dir string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, filepath.Base(filename)), data, 0o644)
+}
+
+func main() {
+	op := flag.String("op", "opcode", "the gjson path of the operation of a state, e.g., lastAction.kind")
+	errPath := flag.String("error", "opResult.error", "the gjson path of the error of a state, a flag or a cause")
+	out := flag.String("out", "", "copy the selected traces to this directory")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] trace.itf.json...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var corpus []itf.CoveredTrace
+	all := make(map[itf.Branch]bool)
+	for _, filename := range flag.Args() {
+		trace, err := itf.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+		branches := itf.Branches(trace, *op, *errPath)
+		for b := range branches {
+			all[b] = true
+		}
+		corpus = append(corpus, itf.CoveredTrace{Name: filename, Size: len(trace.States), Branches: branches})
+	}
+
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	covered := make(map[itf.Branch]bool)
+	subset := itf.Reduce(corpus)
+	states := 0
+	for _, trace := range subset {
+		var added []string
+		for b := range trace.Branches {
+			if !covered[b] {
+				covered[b] = true
+				added = append(added, b.String())
+			}
+		}
+		sort.Strings(added)
+		fmt.Printf("%s: %d states, adds %v\n", trace.Name, trace.Size, added)
+		states += trace.Size
+		if *out != "" {
+			if err := copyFile(trace.Name, *out); err != nil {
+				fmt.Fprintf(os.Stderr, "error copying %s: %v\n", trace.Name, err)
+				os.Exit(1)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d traces, %d states, cover %d branches\n",
+		len(subset), len(corpus), states, len(all))
+}
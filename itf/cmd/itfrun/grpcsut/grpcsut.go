@@ -0,0 +1,268 @@
+// Package grpcsut drives a remote system under test over gRPC, see
+// sut.proto, so that implementations in other languages are checked against
+// the same traces as the Go code.
+//
+// The adapter "grpc" sends the initial state of a trace to Reset. For every
+// following state, it sends the operation and its arguments to Step, and
+// compares the error and, optionally, the result with the state. It then
+// compares the state variables of Snapshot with the state of the trace. Which
+// parts of a state are the operation, its arguments, and so on, is given by
+// the options of the adapter, see New.
+//
+// A system under test in Go is served by Register.
+package grpcsut
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// the name of the service in sut.proto
+const serviceName = "itf.sut.v1.SystemUnderTest"
+
+func init() {
+	adapter.Register("grpc", New)
+}
+
+// Client is an adapter that drives a system under test over gRPC.
+type Client struct {
+	conn *grpc.ClientConn
+	// the paths of the operation, its arguments, its error, and its result in a state
+	op, args, error, result string
+	// the paths of the state variables to compare with the snapshots
+	compare []string
+	// the mapping of the state variables to the snapshots, or nil
+	mapping *adapter.Mapping
+	timeout time.Duration
+}
+
+// New connects to a system under test. The options of the argument are:
+//
+//   - addr: the address of the service, e.g., localhost:50051,
+//   - op: the path of the operation in a state, by default lastAction.kind,
+//   - args: the path of the arguments, by default lastAction,
+//   - error: the path of the expected error, by default lastAction.error,
+//     or the empty string, if the errors are not compared,
+//   - result: the path of the expected result, by default none,
+//   - compare: the path of a state variable to compare with the snapshots,
+//     repeated for every variable, by default none,
+//   - mapping: a JSON file of an adapter.Mapping, which maps the state
+//     variables to the fields of the snapshots, e.g., with other names or
+//     units, instead of compare,
+//   - timeout: the timeout of every call, by default 10s.
+func New(arg string) (adapter.Adapter, error) {
+	opts, err := adapter.ParseOptions(arg)
+	if err != nil {
+		return nil, err
+	}
+	addr := opts.Get("addr", "")
+	if addr == "" {
+		return nil, fmt.Errorf("missing the option addr")
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, opts)
+}
+
+// NewClient returns an adapter over an existing connection, see New for the options.
+func NewClient(conn *grpc.ClientConn, opts adapter.Options) (*Client, error) {
+	timeout, err := time.ParseDuration(opts.Get("timeout", "10s"))
+	if err != nil {
+		return nil, err
+	}
+	var mapping *adapter.Mapping
+	if filename := opts.Get("mapping", ""); filename != "" {
+		if len(opts["compare"]) > 0 {
+			return nil, fmt.Errorf("the options compare and mapping exclude each other")
+		}
+		if mapping, err = adapter.ReadMapping(filename); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{
+		conn:    conn,
+		op:      opts.Get("op", "lastAction.kind"),
+		args:    opts.Get("args", "lastAction"),
+		error:   opts.Get("error", "lastAction.error"),
+		result:  opts.Get("result", ""),
+		compare: opts["compare"],
+		mapping: mapping,
+		timeout: timeout,
+	}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) invoke(method string, in, out any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.conn.Invoke(ctx, "/"+serviceName+"/"+method, in, out)
+}
+
+func (c *Client) Reset(init gjson.Result) error {
+	var state structpb.Struct
+	if err := protojson.Unmarshal([]byte(init.Raw), &state); err != nil {
+		return err
+	}
+	// the metadata of a state is not a state variable
+	delete(state.Fields, "#meta")
+	if err := c.invoke("Reset", &state, &emptypb.Empty{}); err != nil {
+		return err
+	}
+	return c.checkSnapshot(init)
+}
+
+func (c *Client) Step(state gjson.Result) error {
+	op := state.Get(c.op).String()
+	var args structpb.Value
+	if raw := state.Get(c.args).Raw; raw != "" {
+		if err := protojson.Unmarshal([]byte(raw), &args); err != nil {
+			return err
+		}
+	}
+	request, err := structpb.NewStruct(map[string]any{"op": op})
+	if err != nil {
+		return err
+	}
+	request.Fields["args"] = &args
+	var response structpb.Struct
+	if err := c.invoke("Step", request, &response); err != nil {
+		return err
+	}
+	actualError := response.Fields["error"].GetStringValue()
+	if c.error != "" && actualError != state.Get(c.error).String() {
+		return adapter.Diverged(fmt.Errorf("%s: expected the error %q, found %q", op, state.Get(c.error).String(), actualError))
+	}
+	if c.result != "" {
+		result, err := protojson.Marshal(response.Fields["result"])
+		if err != nil {
+			return err
+		}
+		if err := adapter.Compare(c.result, state.Get(c.result), gjson.ParseBytes(result)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return c.checkSnapshot(state)
+}
+
+// compare the state variables of a snapshot with a state of the trace
+func (c *Client) checkSnapshot(state gjson.Result) error {
+	if len(c.compare) == 0 && c.mapping == nil {
+		return nil
+	}
+	var snapshot structpb.Struct
+	if err := c.invoke("Snapshot", &emptypb.Empty{}, &snapshot); err != nil {
+		return err
+	}
+	if c.mapping != nil {
+		data, err := protojson.Marshal(&snapshot)
+		if err != nil {
+			return err
+		}
+		return c.mapping.Compare(state, gjson.ParseBytes(data))
+	}
+	for _, path := range c.compare {
+		actual, found := snapshot.Fields[path]
+		if !found {
+			return fmt.Errorf("%s: missing in the snapshot", path)
+		}
+		data, err := protojson.Marshal(actual)
+		if err != nil {
+			return err
+		}
+		if err := adapter.Compare(path, state.Get(path), gjson.ParseBytes(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// System is a system under test in Go, whose values are in the ITF format.
+type System interface {
+	// Reset brings the system into the initial state of a trace.
+	Reset(init *structpb.Struct) error
+	// Step executes an operation, and returns its result. An error of the
+	// operation is sent to the client, which compares it with the trace.
+	Step(op string, args *structpb.Value) (*structpb.Value, error)
+	// Snapshot returns the state variables of the system.
+	Snapshot() (*structpb.Struct, error)
+}
+
+// Register serves a system under test on a gRPC server.
+func Register(s *grpc.Server, system System) {
+	s.RegisterService(&serviceDesc, system)
+}
+
+// the service of sut.proto, which would be generated by protoc-gen-go-grpc
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*System)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Reset", Handler: unaryHandler("Reset", func(system System, in *structpb.Struct) (any, error) {
+			if err := system.Reset(in); err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			return &emptypb.Empty{}, nil
+		})},
+		{MethodName: "Step", Handler: unaryHandler("Step", func(system System, in *structpb.Struct) (any, error) {
+			result, err := system.Step(in.Fields["op"].GetStringValue(), in.Fields["args"])
+			response := &structpb.Struct{Fields: map[string]*structpb.Value{
+				"result": structpb.NewNullValue(),
+				"error":  structpb.NewStringValue(""),
+			}}
+			if result != nil {
+				response.Fields["result"] = result
+			}
+			if err != nil {
+				response.Fields["error"] = structpb.NewStringValue(err.Error())
+			}
+			return response, nil
+		})},
+		{MethodName: "Snapshot", Handler: unaryHandler("Snapshot", func(system System, _ *structpb.Struct) (any, error) {
+			snapshot, err := system.Snapshot()
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			return snapshot, nil
+		})},
+	},
+	Metadata: "sut.proto",
+}
+
+// a handler of a unary method, whose request is a Struct, or Empty for Snapshot
+func unaryHandler(method string, handle func(System, *structpb.Struct) (any, error)) grpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := new(structpb.Struct)
+		var decoded any = in
+		if method == "Snapshot" {
+			decoded = new(emptypb.Empty)
+		}
+		if err := dec(decoded); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return handle(srv.(System), in)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + method}
+		return interceptor(ctx, decoded, info, func(ctx context.Context, _ any) (any, error) {
+			return handle(srv.(System), in)
+		})
+	}
+}
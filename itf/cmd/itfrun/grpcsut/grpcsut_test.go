@@ -0,0 +1,135 @@
+package grpcsut
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+	"github.com/informalsystems/quint-sandbox/itf/adapter/adaptertest"
+)
+
+// the counter under test, whose decrement may be off by one
+type counter struct {
+	value    int64
+	offByOne bool
+}
+
+// decode an ITF value that was sent over gRPC
+func decode(v *structpb.Value) gjson.Result {
+	data, _ := protojson.Marshal(v)
+	return gjson.ParseBytes(data)
+}
+
+func (c *counter) Reset(init *structpb.Struct) error {
+	i, err := itf.BigInt(decode(init.Fields["counter"]))
+	if err != nil {
+		return err
+	}
+	c.value = i.Int64()
+	return nil
+}
+
+func (c *counter) Step(op string, args *structpb.Value) (*structpb.Value, error) {
+	amount, err := itf.BigInt(decode(args).Get("amount"))
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "inc":
+		c.value += amount.Int64()
+	case "dec":
+		if c.value < amount.Int64() {
+			return nil, errors.New("underflow")
+		}
+		c.value -= amount.Int64()
+		if c.offByOne {
+			c.value--
+		}
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", op)
+	}
+	return nil, nil
+}
+
+func (c *counter) Snapshot() (*structpb.Struct, error) {
+	return structpb.NewStruct(map[string]any{
+		"counter": map[string]any{"#bigint": fmt.Sprint(c.value)},
+	})
+}
+
+// serve a system under test in memory, and connect an adapter to it
+func connect(t *testing.T, system System, arg string) *Client {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	Register(server, system)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	opts, err := adapter.ParseOptions(arg)
+	require.NoError(t, err)
+	client, err := NewClient(conn, opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestReplayOverGrpc(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.AmountTrace)
+	client := connect(t, &counter{}, "compare=counter")
+	assert.NoError(t, adapter.Run(client, trace, nil))
+}
+
+func TestDivergenceOverGrpc(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.AmountTrace)
+	client := connect(t, &counter{offByOne: true}, "compare=counter")
+	err := adapter.Run(client, trace, nil)
+	var divergence *adapter.Divergence
+	require.True(t, errors.As(err, &divergence), "expected a divergence, found %v", err)
+	assert.Equal(t, 3, divergence.State)
+	assert.ErrorContains(t, err, `counter: expected {"#bigint":"1"}, found {"#bigint":"0"}`)
+	var mismatch *adapter.Mismatch
+	require.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, "counter", mismatch.Path)
+}
+
+func TestMappingOverGrpc(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.AmountTrace)
+	mapping := filepath.Join(t.TempDir(), "mapping.json")
+	require.NoError(t, os.WriteFile(mapping, []byte(`{ "vars": [ { "spec": "counter" } ] }`), 0o644))
+	client := connect(t, &counter{}, "mapping="+mapping)
+	assert.NoError(t, adapter.Run(client, trace, nil))
+
+	client = connect(t, &counter{offByOne: true}, "mapping="+mapping)
+	var mismatch *adapter.Mismatch
+	require.ErrorAs(t, adapter.Run(client, trace, nil), &mismatch)
+	assert.Equal(t, "counter", mismatch.Path)
+
+	_, err := NewClient(nil, adapter.Options{"mapping": {mapping}, "compare": {"counter"}})
+	assert.ErrorContains(t, err, "exclude each other")
+}
+
+func TestMissingAddress(t *testing.T) {
+	_, err := adapter.New("grpc", "compare=counter")
+	assert.ErrorContains(t, err, "missing the option addr")
+}
@@ -0,0 +1,352 @@
+// Package httpsut drives a web service through the states of a trace, by
+// mapping every operation of a trace to an HTTP request, so a service is
+// checked against the traces without writing Go code for every endpoint.
+//
+// The requests are configured in a JSON file, see Config. The paths and the
+// bodies of the requests are templates of text/template, which read the state
+// of the trace, e.g.:
+//
+//	{
+//	  "base": "http://localhost:8080",
+//	  "reset": { "method": "POST", "path": "/reset" },
+//	  "operations": {
+//	    "deposit": {
+//	      "method": "POST",
+//	      "path": "/accounts/{{.Query \"lastAction.account\"}}/deposit",
+//	      "body": "{\"amount\": {{.Get \"lastAction.amount\"}}}",
+//	      "error": "message",
+//	      "checks": [ { "response": "balance", "state": "lastAction.balance" } ]
+//	    }
+//	  },
+//	  "snapshot": {
+//	    "path": "/accounts",
+//	    "checks": [ { "response": "@this", "state": "balances" } ]
+//	  }
+//	}
+//
+// A response with a status other than 2xx is a failed operation, whose error
+// message is extracted from the response, and compared with the state. A
+// status of 5xx is a failure of the service, which is an error of the replay,
+// not a divergence.
+package httpsut
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+func init() {
+	adapter.Register("http", New)
+}
+
+// Config maps the operations of a trace to HTTP requests.
+type Config struct {
+	// the URL that the paths of the requests are relative to
+	Base string `json:"base"`
+	// the path of the operation in a state, by default lastAction.kind
+	Op string `json:"op"`
+	// the path of the expected error in a state, by default lastAction.error,
+	// or "-", if the errors are not compared
+	ExpectedError string `json:"expectedError"`
+	// the request that brings the service into the initial state, if any
+	Reset *Request `json:"reset"`
+	// the request of every operation
+	Operations map[string]Request `json:"operations"`
+	// the request whose response is compared with every state, if any
+	Snapshot *Request `json:"snapshot"`
+}
+
+// Request is a template of an HTTP request.
+type Request struct {
+	// the method, by default GET
+	Method string `json:"method"`
+	// the template of the path, relative to the base URL
+	Path string `json:"path"`
+	// the template of the body, which is sent as JSON, if it is not empty
+	Body string `json:"body"`
+	// the path of the error message in the response of a failed request,
+	// or the empty string, if the whole response is the message
+	Error string `json:"error"`
+	// the fields of the response of a successful request, which are compared with the state
+	Checks []Check `json:"checks"`
+}
+
+// Check compares a field of a response with a part of a state. Both are
+// compared as ITF values, so integers may be written as JSON numbers or as
+// {"#bigint": "..."} in the response, and a map with string keys may be
+// written as a JSON object.
+type Check struct {
+	// the gjson path in the response
+	Response string `json:"response"`
+	// the gjson path in the state
+	State string `json:"state"`
+}
+
+// the templates of a request
+type request struct {
+	Request
+	path *template.Template
+	body *template.Template
+}
+
+// Client is an adapter that drives a web service.
+type Client struct {
+	config     Config
+	http       *http.Client
+	reset      *request
+	snapshot   *request
+	operations map[string]*request
+}
+
+// New reads the configuration of a file. The options of the argument are:
+//
+//   - config: the configuration file,
+//   - base: the base URL, which overrides the one of the configuration,
+//   - timeout: the timeout of every request, by default 10s.
+func New(arg string) (adapter.Adapter, error) {
+	opts, err := adapter.ParseOptions(arg)
+	if err != nil {
+		return nil, err
+	}
+	filename := opts.Get("config", "")
+	if filename == "" {
+		return nil, fmt.Errorf("missing the option config")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	config.Base = opts.Get("base", config.Base)
+	timeout, err := time.ParseDuration(opts.Get("timeout", "10s"))
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(config, &http.Client{Timeout: timeout})
+}
+
+// NewClient returns an adapter of a configuration, which sends the requests with a client.
+func NewClient(config Config, client *http.Client) (*Client, error) {
+	if config.Op == "" {
+		config.Op = "lastAction.kind"
+	}
+	if config.ExpectedError == "" {
+		config.ExpectedError = "lastAction.error"
+	}
+	c := &Client{config: config, http: client, operations: make(map[string]*request)}
+	var err error
+	if config.Reset != nil {
+		if c.reset, err = parse("reset", *config.Reset); err != nil {
+			return nil, err
+		}
+	}
+	if config.Snapshot != nil {
+		if c.snapshot, err = parse("snapshot", *config.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+	for op, r := range config.Operations {
+		if c.operations[op], err = parse(op, r); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// parse the templates of a request
+func parse(name string, r Request) (*request, error) {
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+	path, err := template.New(name + ".path").Option("missingkey=error").Parse(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	body, err := template.New(name + ".body").Option("missingkey=error").Parse(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &request{Request: r, path: path, body: body}, nil
+}
+
+func (c *Client) Reset(init gjson.Result) error {
+	if c.reset != nil {
+		status, response, err := c.send(c.reset, init)
+		if err != nil {
+			return err
+		}
+		if !successful(status) {
+			return fmt.Errorf("reset: status %d: %s", status, response)
+		}
+	}
+	return c.checkSnapshot(init)
+}
+
+func (c *Client) Step(state gjson.Result) error {
+	op := state.Get(c.config.Op).String()
+	r, found := c.operations[op]
+	if !found {
+		return fmt.Errorf("no request for the operation %q", op)
+	}
+	status, response, err := c.send(r, state)
+	if err != nil {
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	if status >= 500 {
+		// the service failed, rather than rejected the operation, which no
+		// error of the spec stands for, whether the errors are compared or not
+		return fmt.Errorf("%s: status %d: %s", op, status, strings.TrimSpace(string(response)))
+	}
+	var actualError string
+	if !successful(status) {
+		actualError = strings.TrimSpace(string(response))
+		if r.Error != "" {
+			actualError = gjson.GetBytes(response, r.Error).String()
+		}
+	}
+	if c.config.ExpectedError != "-" {
+		if expected := state.Get(c.config.ExpectedError).String(); expected != actualError {
+			return adapter.Diverged(fmt.Errorf("%s: expected the error %q, found %q (status %d)", op, expected, actualError, status))
+		}
+	} else if !successful(status) {
+		return adapter.Diverged(fmt.Errorf("%s: status %d: %s", op, status, actualError))
+	}
+	if successful(status) {
+		if err := check(r.Checks, response, state); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return c.checkSnapshot(state)
+}
+
+// compare the response of the snapshot request with a state
+func (c *Client) checkSnapshot(state gjson.Result) error {
+	if c.snapshot == nil {
+		return nil
+	}
+	status, response, err := c.send(c.snapshot, state)
+	if err != nil {
+		return fmt.Errorf("snapshot: %v", err)
+	}
+	if !successful(status) {
+		return fmt.Errorf("snapshot: status %d: %s", status, response)
+	}
+	if err := check(c.snapshot.Checks, response, state); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
+
+// send a request, whose templates are filled with a state
+func (c *Client) send(r *request, state gjson.Result) (int, []byte, error) {
+	var path, body bytes.Buffer
+	if err := r.path.Execute(&path, view{state}); err != nil {
+		return 0, nil, err
+	}
+	if err := r.body.Execute(&body, view{state}); err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest(r.Method, strings.TrimSuffix(c.config.Base, "/")+path.String(), &body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body.Len() > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+func successful(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// compare the fields of a response with a state
+func check(checks []Check, response []byte, state gjson.Result) error {
+	for _, c := range checks {
+		actual := gjson.GetBytes(response, c.Response)
+		if !actual.Exists() {
+			return fmt.Errorf("%s: missing in the response %s", c.Response, response)
+		}
+		expected := state.Get(c.State)
+		if expected.Get("\\#map").Exists() && actual.IsObject() && !actual.Get("\\#map").Exists() {
+			actual = objectToMap(actual)
+		}
+		if err := adapter.Compare(c.Response, expected, actual); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convert a JSON object into an ITF map with string keys
+func objectToMap(obj gjson.Result) gjson.Result {
+	var pairs []string
+	obj.ForEach(func(key, value gjson.Result) bool {
+		k, _ := json.Marshal(key.String())
+		pairs = append(pairs, "["+string(k)+","+value.Raw+"]")
+		return true
+	})
+	return gjson.Parse(`{"#map":[` + strings.Join(pairs, ",") + "]}")
+}
+
+// the data of the templates, which reads a state
+type view struct {
+	state gjson.Result
+}
+
+// Get returns a part of the state as plain text: a string without quotes,
+// an integer in decimal digits, and any other value in ITF JSON.
+func (v view) Get(path string) (string, error) {
+	value := v.state.Get(path)
+	switch {
+	case !value.Exists():
+		return "", fmt.Errorf("%s: missing in the state", path)
+	case value.Type == gjson.String:
+		return value.String(), nil
+	case value.Type == gjson.Number || value.Get("\\#bigint").Exists():
+		i, err := itf.BigInt(value)
+		if err != nil {
+			return "", err
+		}
+		return i.String(), nil
+	default:
+		return value.Raw, nil
+	}
+}
+
+// Query returns a part of the state as Get does, escaped for a URL path.
+func (v view) Query(path string) (string, error) {
+	s, err := v.Get(path)
+	return url.PathEscape(s), err
+}
+
+// Raw returns a part of the state in ITF JSON, e.g., to send a set as is.
+func (v view) Raw(path string) (string, error) {
+	value := v.state.Get(path)
+	if !value.Exists() {
+		return "", fmt.Errorf("%s: missing in the state", path)
+	}
+	return value.Raw, nil
+}
@@ -0,0 +1,170 @@
+package httpsut
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// a trace of a bank, in which the accounts deposit and withdraw
+const bankTrace = `{
+  "vars": [ "balances", "lastAction" ],
+  "states": [
+    { "balances": { "#map": [ [ "alice", { "#bigint": "0" } ], [ "bob", { "#bigint": "2" } ] ] },
+      "lastAction": { "kind": "init", "account": "", "amount": { "#bigint": "0" }, "balance": { "#bigint": "0" }, "error": "" } },
+    { "balances": { "#map": [ [ "alice", { "#bigint": "5" } ], [ "bob", { "#bigint": "2" } ] ] },
+      "lastAction": { "kind": "deposit", "account": "alice", "amount": { "#bigint": "5" }, "balance": { "#bigint": "5" }, "error": "" } },
+    { "balances": { "#map": [ [ "alice", { "#bigint": "5" } ], [ "bob", { "#bigint": "2" } ] ] },
+      "lastAction": { "kind": "withdraw", "account": "bob", "amount": { "#bigint": "3" }, "balance": { "#bigint": "2" }, "error": "insufficient funds" } },
+    { "balances": { "#map": [ [ "alice", { "#bigint": "1" } ], [ "bob", { "#bigint": "2" } ] ] },
+      "lastAction": { "kind": "withdraw", "account": "alice", "amount": { "#bigint": "4" }, "balance": { "#bigint": "1" }, "error": "" } }
+  ]
+}`
+
+// the configuration of the bank, as it would be written in a file
+const bankConfig = `{
+  "reset": { "method": "POST", "path": "/reset", "body": "{{.Raw \"balances\"}}" },
+  "operations": {
+    "deposit": {
+      "method": "POST",
+      "path": "/accounts/{{.Query \"lastAction.account\"}}/deposit",
+      "body": "{\"amount\": {{.Get \"lastAction.amount\"}}}",
+      "checks": [ { "response": "balance", "state": "lastAction.balance" } ]
+    },
+    "withdraw": {
+      "method": "POST",
+      "path": "/accounts/{{.Query \"lastAction.account\"}}/withdraw",
+      "body": "{\"amount\": {{.Get \"lastAction.amount\"}}}",
+      "error": "message",
+      "checks": [ { "response": "balance", "state": "lastAction.balance" } ]
+    }
+  },
+  "snapshot": {
+    "path": "/accounts",
+    "checks": [ { "response": "@this", "state": "balances" } ]
+  }
+}`
+
+// a web service of a bank, whose withdrawals may ignore the balance, or fail
+type bank struct {
+	mu        sync.Mutex
+	balances  map[string]int64
+	overdraft bool
+	broken    bool
+}
+
+func (b *bank) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var body struct{ Amount int64 }
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/reset":
+		// the initial balances are sent as an ITF map
+		var raw json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&raw)
+		entries, err := itf.Map(gjson.ParseBytes(raw))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b.balances = make(map[string]int64)
+		for _, e := range entries {
+			i, _ := itf.BigInt(e.Value)
+			b.balances[e.Key.String()] = i.Int64()
+		}
+
+	case r.URL.Path == "/accounts":
+		_ = json.NewEncoder(w).Encode(b.balances)
+
+	case len(parts) == 3 && parts[0] == "accounts":
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		account := parts[1]
+		if parts[2] == "withdraw" {
+			if b.broken {
+				http.Error(w, "database unavailable", http.StatusInternalServerError)
+				return
+			}
+			if !b.overdraft && b.balances[account] < body.Amount {
+				w.WriteHeader(http.StatusConflict)
+				_ = json.NewEncoder(w).Encode(map[string]string{"message": "insufficient funds"})
+				return
+			}
+			body.Amount = -body.Amount
+		}
+		b.balances[account] += body.Amount
+		_ = json.NewEncoder(w).Encode(map[string]int64{"balance": b.balances[account]})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serve a bank, and connect an adapter to it via a configuration file
+func connect(t *testing.T, b *bank) adapter.Adapter {
+	server := httptest.NewServer(b)
+	t.Cleanup(server.Close)
+	config := filepath.Join(t.TempDir(), "bank.json")
+	require.NoError(t, os.WriteFile(config, []byte(bankConfig), 0o644))
+	a, err := adapter.New("http", "config="+config+",base="+server.URL)
+	require.NoError(t, err)
+	return a
+}
+
+func TestReplayOverHttp(t *testing.T) {
+	trace, err := itf.Parse([]byte(bankTrace))
+	require.NoError(t, err)
+	assert.NoError(t, adapter.Run(connect(t, &bank{}), trace, nil))
+}
+
+func TestDivergenceOverHttp(t *testing.T) {
+	trace, err := itf.Parse([]byte(bankTrace))
+	require.NoError(t, err)
+	err = adapter.Run(connect(t, &bank{overdraft: true}), trace, nil)
+	var divergence *adapter.Divergence
+	require.True(t, errors.As(err, &divergence), "expected a divergence, found %v", err)
+	assert.Equal(t, 2, divergence.State)
+	assert.ErrorContains(t, err, `withdraw: expected the error "insufficient funds", found "" (status 200)`)
+}
+
+func TestServerErrorOverHttp(t *testing.T) {
+	trace, err := itf.Parse([]byte(bankTrace))
+	require.NoError(t, err)
+	// a failure of the service is an error, not a divergence, even though the
+	// spec expects an error of the withdrawal
+	err = adapter.Run(connect(t, &bank{broken: true}), trace, nil)
+	var divergence *adapter.Divergence
+	assert.False(t, errors.As(err, &divergence), "expected an error, found a divergence: %v", err)
+	assert.ErrorContains(t, err, "withdraw: status 500: database unavailable")
+}
+
+func TestTemplates(t *testing.T) {
+	v := view{gjson.Parse(`{ "a": { "#bigint": "-7" }, "b": "x y", "c": { "#set": [] } }`)}
+	s, err := v.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "-7", s)
+	s, err = v.Query("b")
+	require.NoError(t, err)
+	assert.Equal(t, "x%20y", s)
+	s, err = v.Get("c")
+	require.NoError(t, err)
+	assert.Equal(t, `{ "#set": [] }`, s)
+	_, err = v.Raw("d")
+	assert.Error(t, err)
+
+	_, err = NewClient(Config{Operations: map[string]Request{"bad": {Path: "{{.Get"}}}, http.DefaultClient)
+	assert.Error(t, err, "a template that does not parse")
+}
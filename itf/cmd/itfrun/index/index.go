@@ -0,0 +1,179 @@
+// Package index records the results of replaying traces in a SQLite database,
+// which accumulates over many runs of itfrun, so the history of a system under
+// test is queried later, e.g., whether quoRoundUp ever diverged on a negative
+// argument in any run:
+//
+//	SELECT run, started, file, state, error FROM history
+//	WHERE op = 'quoRoundUp' AND verdict = 'diverged'
+//	  AND json_extract(input, '$.opArg1.value."#bigint"') LIKE '-%';
+//
+// The database has the tables:
+//
+//	runs     id, started, adapter, arg              an invocation of itfrun
+//	traces   id, run, file, result, error           a trace that was replayed
+//	steps    trace, state, op, input, verdict,      a state that was executed
+//	         error, expected, actual
+//
+// and the view history, which joins the steps with their traces and runs.
+// The input of a step is the state in ITF JSON, which is queried with the JSON
+// functions of SQLite. The verdict is passed, when the system under test
+// produced the state, or diverged. The outputs of the system under test are
+// only known from an adapter.Mismatch: its expected and actual values. The
+// states after a divergence were not executed, and they are not recorded.
+package index
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	// the driver of SQLite, which needs cgo
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id      INTEGER PRIMARY KEY,
+	started TEXT NOT NULL,
+	adapter TEXT NOT NULL,
+	arg     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS traces (
+	id     INTEGER PRIMARY KEY,
+	run    INTEGER NOT NULL REFERENCES runs (id),
+	file   TEXT NOT NULL,
+	result TEXT NOT NULL,
+	error  TEXT
+);
+CREATE TABLE IF NOT EXISTS steps (
+	trace    INTEGER NOT NULL REFERENCES traces (id),
+	state    INTEGER NOT NULL,
+	op       TEXT NOT NULL,
+	input    TEXT NOT NULL,
+	verdict  TEXT NOT NULL,
+	error    TEXT,
+	expected TEXT,
+	actual   TEXT,
+	PRIMARY KEY (trace, state)
+);
+CREATE INDEX IF NOT EXISTS steps_by_op ON steps (op, verdict);
+CREATE VIEW IF NOT EXISTS history AS
+	SELECT runs.id AS run, runs.started, runs.adapter, traces.file, traces.result,
+		steps.state, steps.op, steps.input, steps.verdict, steps.error, steps.expected, steps.actual
+	FROM steps JOIN traces ON steps.trace = traces.id JOIN runs ON traces.run = runs.id;
+`
+
+// Index is a database of the results of replaying traces.
+type Index struct {
+	db *sql.DB
+	// the path of the operation of a state
+	op string
+}
+
+// Open opens the database in a file, which is created if it does not exist.
+// The operations of the states are read from the path op, e.g., lastAction.kind.
+func Open(filename, op string) (*Index, error) {
+	db, err := sql.Open("sqlite3", filename+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return &Index{db: db, op: op}, nil
+}
+
+// Close closes the database.
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// DB returns the database, e.g., to query it.
+func (ix *Index) DB() *sql.DB {
+	return ix.db
+}
+
+// Run is an invocation of itfrun, which replays traces against an adapter.
+type Run struct {
+	index *Index
+	ID    int64
+}
+
+// Start records a run against an adapter with its argument.
+func (ix *Index) Start(name, arg string) (*Run, error) {
+	res, err := ix.db.Exec(`INSERT INTO runs (started, adapter, arg) VALUES (?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), name, arg)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &Run{index: ix, ID: id}, nil
+}
+
+// Add records the result of replaying a trace, as returned by adapter.Run,
+// together with the states that were executed.
+func (r *Run) Add(filename string, trace itf.Trace, err error) error {
+	result, message := "passed", sql.NullString{}
+	var divergence *adapter.Divergence
+	switch {
+	case err == nil:
+	case errors.As(err, &divergence):
+		result, message = "diverged", sql.NullString{String: err.Error(), Valid: true}
+	default:
+		result, message = "failed", sql.NullString{String: err.Error(), Valid: true}
+	}
+	tx, err := r.index.db.Begin()
+	if err != nil {
+		return err
+	}
+	// a no-op after the commit
+	defer tx.Rollback()
+	res, err := tx.Exec(`INSERT INTO traces (run, file, result, error) VALUES (?, ?, ?, ?)`,
+		r.ID, filename, result, message)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	insert, err := tx.Prepare(`INSERT INTO steps (trace, state, op, input, verdict, error, expected, actual)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for i, state := range trace.States {
+		if result == "failed" || (divergence != nil && i > divergence.State) {
+			// the state was not executed
+			break
+		}
+		op := "reset"
+		if i > 0 {
+			op = state.Get(r.index.op).String()
+		}
+		verdict := "passed"
+		var stepError, expected, actual sql.NullString
+		if divergence != nil && i == divergence.State {
+			verdict = "diverged"
+			stepError = sql.NullString{String: divergence.Err.Error(), Valid: true}
+			var mismatch *adapter.Mismatch
+			if errors.As(divergence.Err, &mismatch) {
+				expected = sql.NullString{String: mismatch.Expected, Valid: true}
+				actual = sql.NullString{String: mismatch.Actual, Valid: true}
+			}
+		}
+		if _, err := insert.Exec(id, i, op, state.Raw, verdict, stepError, expected, actual); err != nil {
+			return fmt.Errorf("state %d: %v", i, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,80 @@
+package index
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+const decimalTrace = `{
+  "vars": [ "opcode", "opArg1", "opArg2" ],
+  "states": [
+    { "opcode": "none", "opArg1": { "#bigint": "0" }, "opArg2": { "#bigint": "0" } },
+    { "opcode": "add", "opArg1": { "#bigint": "-5" }, "opArg2": { "#bigint": "3" } },
+    { "opcode": "quoRoundUp", "opArg1": { "#bigint": "-7" }, "opArg2": { "#bigint": "2" } },
+    { "opcode": "quoRoundUp", "opArg1": { "#bigint": "7" }, "opArg2": { "#bigint": "2" } }
+  ]
+}`
+
+func TestIndex(t *testing.T) {
+	trace, err := itf.Parse([]byte(decimalTrace))
+	require.NoError(t, err)
+	filename := filepath.Join(t.TempDir(), "runs.db")
+
+	ix, err := Open(filename, "opcode")
+	require.NoError(t, err)
+	run, err := ix.Start("decimal", "")
+	require.NoError(t, err)
+	require.NoError(t, run.Add("passed.itf.json", trace, nil))
+	require.NoError(t, run.Add("failed.itf.json", itf.Trace{}, errors.New("connection refused")))
+	require.NoError(t, ix.Close())
+
+	// the second run is appended to the same database
+	ix, err = Open(filename, "opcode")
+	require.NoError(t, err)
+	defer ix.Close()
+	run, err = ix.Start("decimal", "")
+	require.NoError(t, err)
+	require.NoError(t, run.Add("diverged.itf.json", trace, &adapter.Divergence{State: 2, Err: &adapter.Mismatch{
+		Path:     "opResult",
+		Expected: `{"#bigint":"-3"}`,
+		Actual:   `{"#bigint":"-4"}`,
+	}}))
+
+	var results []string
+	rows, err := ix.DB().Query(`SELECT result FROM traces ORDER BY id`)
+	require.NoError(t, err)
+	for rows.Next() {
+		var result string
+		require.NoError(t, rows.Scan(&result))
+		results = append(results, result)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"passed", "failed", "diverged"}, results)
+
+	var steps int
+	require.NoError(t, ix.DB().QueryRow(`SELECT count(*) FROM steps`).Scan(&steps))
+	// all states of the passed trace, and the states up to the divergence
+	assert.Equal(t, 4+3, steps)
+
+	// has quoRoundUp ever diverged on a negative argument?
+	var runID int64
+	var file, op, actual string
+	require.NoError(t, ix.DB().QueryRow(`
+		SELECT run, file, op, actual FROM history
+		WHERE op = 'quoRoundUp' AND verdict = 'diverged'
+		  AND json_extract(input, '$.opArg1."#bigint"') LIKE '-%'`).Scan(&runID, &file, &op, &actual))
+	assert.Equal(t, run.ID, runID)
+	assert.Equal(t, "diverged.itf.json", file)
+	assert.Equal(t, `{"#bigint":"-4"}`, actual)
+
+	var resets int
+	require.NoError(t, ix.DB().QueryRow(`SELECT count(*) FROM steps WHERE state = 0 AND op = 'reset'`).Scan(&resets))
+	assert.Equal(t, 2, resets)
+}
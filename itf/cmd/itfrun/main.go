@@ -0,0 +1,405 @@
+// A command that replays ITF traces against a registered adapter outside of
+// `go test`, e.g., against a long-running service, or in a debugging session.
+//
+// The adapters are either compiled into the command, see print.go and the
+// packages grpcsut, httpsut, and wasmsut, or loaded from Go plugins, which
+// register their adapters with adapter.Register in an init function. A plugin
+// has to be built with the same version of Go and of the package itf as the
+// command:
+//
+//	(cd ../../../slidingwindow/go && go build -buildmode=plugin -o /tmp/sw.so ./plugin)
+//	go run . -plugin /tmp/sw.so -adapter slidingwindow trace.itf.json
+//
+// The command is a module of its own, so the dependencies of its adapters,
+// e.g., gRPC, do not end up in the modules that use the package itf.
+//
+// With -tui, the command steps through a single trace interactively, see the
+// package tui, which is useful to debug a trace, from which a system under
+// test diverged:
+//
+//	go run . -plugin /tmp/sw.so -adapter slidingwindow -tui trace.itf.json
+//
+// With -report, the command also writes the results of the traces to a single
+// HTML page, see the package report, which is attached to bug reports:
+//
+//	go run . -adapter grpc -arg addr=localhost:50051 -report report.html traces/*.itf.json
+//
+// With -soak, the command replays the traces in rounds until a duration has
+// passed, and with -metrics, it serves Prometheus metrics of the replays, see
+// the package metrics, so a long conformance campaign is monitored:
+//
+//	go run . -adapter grpc -arg addr=localhost:50051 -soak 24h -metrics :9090 traces/*.itf.json
+//
+// With -otel, the command exports OpenTelemetry spans of the traces and of
+// their states, see the package tracing, either to the standard error, or over
+// OTLP, which is configured with OTEL_EXPORTER_OTLP_ENDPOINT and the like:
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT=http://localhost:4317 go run . -adapter grpc -otel otlp ...
+//
+// With -index, the command records every state that it executed, with its
+// verdict, in a SQLite database, which accumulates over many runs and is
+// queried later, see the package index:
+//
+//	go run . -adapter grpc -arg addr=localhost:50051 -index runs.db traces/*.itf.json
+//	sqlite3 runs.db "SELECT file, state FROM history WHERE verdict = 'diverged'"
+//
+// With -from and -to, the command replays a range of the states of every
+// trace, e.g., to debug a divergence deep in a long trace. With -fast-forward,
+// the states before -from are executed as a setup, see adapter.RunRange:
+//
+//	go run . -plugin /tmp/sw.so -adapter slidingwindow -from 412 -fast-forward trace.itf.json
+//
+// With -shard, the command replays the i-th of n disjoint shards of the
+// traces, so a large corpus is split across processes or machines, which are
+// given the same arguments, see itf.Shard. A shard without traces is an error:
+//
+//	go run . -adapter grpc -arg addr=localhost:50051 -shard 2/4 traces/*.itf.json
+//
+// The traces are read and decoded in parallel, ahead of their replay, see
+// -read-ahead, so reading a large corpus overlaps with replaying it.
+//
+// The traces are read in any of the formats of the package formats, e.g.,
+// trace.itf.json or trace.itf.cbor.
+//
+// With -monitor, the command checks the invariants of a spec on a running
+// system, instead of replaying traces: it reads the events of the system as
+// JSON lines from files, or from the standard input, and passes them to a
+// registered monitor, see the package monitor. It reports every event, after
+// which the system violates an invariant, and it keeps reading:
+//
+//	tail -f node.log | go run . -plugin /tmp/bank.so -monitor bank
+//
+// The command exits with 1 if the system under test diverged from a trace,
+// or violated an invariant, and with 2 on other errors. With -report or -soak, it replays the remaining
+// traces after an error, before it exits with 2.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"plugin"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tidwall/gjson"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+	"github.com/informalsystems/quint-sandbox/itf/cmd/itfrun/index"
+	"github.com/informalsystems/quint-sandbox/itf/cmd/itfrun/metrics"
+	"github.com/informalsystems/quint-sandbox/itf/cmd/itfrun/tracing"
+	"github.com/informalsystems/quint-sandbox/itf/cmd/itfrun/tui"
+	"github.com/informalsystems/quint-sandbox/itf/formats"
+	"github.com/informalsystems/quint-sandbox/itf/monitor"
+	"github.com/informalsystems/quint-sandbox/itf/report"
+	// the adapters of remote systems under test, and of modules of WebAssembly
+	_ "github.com/informalsystems/quint-sandbox/itf/cmd/itfrun/grpcsut"
+	_ "github.com/informalsystems/quint-sandbox/itf/cmd/itfrun/httpsut"
+	_ "github.com/informalsystems/quint-sandbox/itf/cmd/itfrun/wasmsut"
+)
+
+// the plugins to load, as given by repeated -plugin flags
+type pluginList []string
+
+func (l *pluginList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *pluginList) Set(path string) error {
+	*l = append(*l, path)
+	return nil
+}
+
+func main() {
+	var plugins pluginList
+	var shard itf.Shard
+	flag.Var(&plugins, "plugin", "a Go plugin that registers adapters or monitors (repeatable)")
+	name := flag.String("adapter", "", "the name of the adapter to replay the traces against")
+	monitorName := flag.String("monitor", "", "the name of the monitor to check the events of a system with, instead of replaying traces")
+	arg := flag.String("arg", "", "the argument of the adapter or of the monitor, e.g., the address of a service")
+	list := flag.Bool("list", false, "list the registered adapters and monitors and exit")
+	verbose := flag.Bool("v", false, "print every state that was replayed")
+	interactive := flag.Bool("tui", false, "step through a single trace interactively")
+	reportFile := flag.String("report", "", "write an HTML report of the traces to a file")
+	soak := flag.Duration("soak", 0, "replay the traces in rounds until the duration has passed, e.g., 24h")
+	metricsAddr := flag.String("metrics", "", "serve Prometheus metrics on /metrics at an address, e.g., :9090")
+	op := flag.String("op", "lastAction.kind", "the path of the operation of a state, which labels the metrics and the spans")
+	ahead := flag.Int("read-ahead", runtime.GOMAXPROCS(0), "the number of traces to read in parallel, ahead of their replay")
+	indexFile := flag.String("index", "", "record the states and their verdicts in a SQLite database")
+	from := flag.Int("from", 0, "replay the states from this state on")
+	to := flag.Int("to", -1, "replay the states up to this state, or up to the last state, if negative")
+	fastForward := flag.Bool("fast-forward", false, "execute the states before -from as a setup, instead of resetting to -from")
+	flag.Var(&shard, "shard", "replay the i-th of n shards of the traces, e.g., 2/4")
+	varList := flag.String("vars", "", "decode only these state variables, which the adapter consumes, e.g., x,lastAction")
+	otelExporter := flag.String("otel", "", "export OpenTelemetry spans of the traces and of the states: stdout or otlp")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] -adapter name trace.itf.json...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [flags] -monitor name [events.jsonl...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	for _, path := range plugins {
+		// the plugin registers its adapters in an init function
+		if _, err := plugin.Open(path); err != nil {
+			fmt.Fprintf(os.Stderr, "error loading plugin %s: %v\n", path, err)
+			os.Exit(2)
+		}
+	}
+	if *list {
+		for _, n := range adapter.Names() {
+			fmt.Println(n)
+		}
+		for _, n := range monitor.Names() {
+			fmt.Printf("%s (monitor)\n", n)
+		}
+		return
+	}
+	if *monitorName != "" {
+		os.Exit(watch(*monitorName, *arg, flag.Args(), *verbose))
+	}
+	if *name == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// the state variables to decode, or nil for all of them
+	var vars []string
+	if *varList != "" {
+		vars = strings.Split(*varList, ",")
+	}
+
+	if *interactive {
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err := step(*name, *arg, flag.Arg(0), vars)
+		var divergence *adapter.Divergence
+		switch {
+		case errors.As(err, &divergence):
+			fmt.Printf("FAIL %s: %v\n", flag.Arg(0), err)
+			os.Exit(1)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "error stepping through %s: %v\n", flag.Arg(0), err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	states := adapter.Range{From: *from, To: *to, FastForward: *fastForward}
+	if states != adapter.All && (*reportFile != "" || *indexFile != "") {
+		// the reports and the index have the results of all states
+		fmt.Fprintln(os.Stderr, "-from and -to do not go with -report and -index")
+		os.Exit(2)
+	}
+
+	var files []string
+	for _, filename := range flag.Args() {
+		if shard.Has(filename) {
+			files = append(files, filename)
+		}
+	}
+	if len(files) == 0 {
+		// a shard without traces would pass, or spin in -soak, without a replay
+		fmt.Fprintf(os.Stderr, "no traces in shard %s\n", shard.String())
+		os.Exit(2)
+	}
+
+	r := runner{name: *name, arg: *arg, verbose: *verbose, op: *op, states: states}
+	// exit after the last spans were exported
+	exit := os.Exit
+	if *otelExporter != "" {
+		provider, err := tracing.Setup(context.Background(), *otelExporter, os.Stderr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error setting up OpenTelemetry: %v\n", err)
+			os.Exit(2)
+		}
+		r.tracer = tracing.New(provider, *op)
+		exit = func(code int) {
+			if err := provider.Shutdown(context.Background()); err != nil {
+				fmt.Fprintf(os.Stderr, "error exporting the spans: %v\n", err)
+			}
+			os.Exit(code)
+		}
+	}
+	// the run in the index, or nil
+	var run *index.Run
+	if *indexFile != "" {
+		ix, err := index.Open(*indexFile, *op)
+		if err == nil {
+			run, err = ix.Start(*name, *arg)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening the index: %v\n", err)
+			exit(2)
+		}
+		exitAfterSpans := exit
+		exit = func(code int) {
+			ix.Close()
+			exitAfterSpans(code)
+		}
+	}
+	// the error of serving the metrics, which the replays check, so that they
+	// exit through exit
+	served := make(chan error, 1)
+	if *metricsAddr != "" {
+		l, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error serving the metrics: %v\n", err)
+			exit(2)
+		}
+		registry := prometheus.NewRegistry()
+		r.metrics = metrics.New(registry)
+		go func() {
+			served <- metrics.Serve(l, registry)
+		}()
+	}
+	// exit with 2, if the metrics are no longer served
+	checkMetrics := func() {
+		select {
+		case err := <-served:
+			fmt.Fprintf(os.Stderr, "error serving the metrics: %v\n", err)
+			exit(2)
+		default:
+		}
+	}
+	deadline := time.Now().Add(*soak)
+	diverged, failed := false, false
+	for round := 1; ; round++ {
+		// the report has the results of the last round
+		var results *report.Report
+		if *reportFile != "" {
+			results = report.New("itfrun -adapter " + *name)
+		}
+		passed := 0
+		read, stop := readAhead(files, vars, *ahead)
+		for i, filename := range files {
+			checkMetrics()
+			trace, err := r.replay(filename, func() (itf.Trace, error) { return read(i) })
+			if results != nil {
+				results.Add(filename, trace, err)
+			}
+			if run != nil {
+				if err := run.Add(filename, trace, err); err != nil {
+					fmt.Fprintf(os.Stderr, "error recording %s in the index: %v\n", filename, err)
+					exit(2)
+				}
+			}
+			var divergence *adapter.Divergence
+			switch {
+			case err == nil:
+				passed++
+				if *soak == 0 {
+					fmt.Printf("ok   %s\n", filename)
+				}
+			case errors.As(err, &divergence):
+				fmt.Printf("FAIL %s: %v\n", filename, err)
+				diverged = true
+			default:
+				fmt.Fprintf(os.Stderr, "error replaying %s: %v\n", filename, err)
+				if results == nil && *soak == 0 {
+					exit(2)
+				}
+				// the error is recorded in the report, or in the next rounds
+				failed = true
+			}
+		}
+		stop()
+		if results != nil {
+			if err := results.WriteFile(*reportFile); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing the report: %v\n", err)
+				exit(2)
+			}
+		}
+		if *soak == 0 {
+			break
+		}
+		fmt.Printf("round %d: %d of %d traces passed\n", round, passed, len(files))
+		if time.Now().After(deadline) {
+			break
+		}
+	}
+	checkMetrics()
+	switch {
+	case failed:
+		exit(2)
+	case diverged:
+		exit(1)
+	}
+	exit(0)
+}
+
+// the options of replaying traces
+type runner struct {
+	name, arg string
+	verbose   bool
+	// the metrics and the spans of the replays, or nil
+	metrics *metrics.Metrics
+	tracer  *tracing.Tracer
+	// the path of the operations of the states
+	op string
+	// the states to replay
+	states adapter.Range
+}
+
+// replay a trace, which is returned by read, against a fresh adapter
+func (r runner) replay(filename string, read func() (itf.Trace, error)) (trace itf.Trace, err error) {
+	ctx := context.Background()
+	if r.tracer != nil {
+		var span oteltrace.Span
+		ctx, span = r.tracer.Start(ctx, filename, r.name)
+		defer func() { r.tracer.End(span, len(trace.States), err) }()
+	}
+	if r.metrics != nil {
+		defer func() { r.metrics.Done(r.name, err) }()
+	}
+	trace, err = read()
+	if err != nil {
+		return itf.Trace{}, err
+	}
+	a, err := adapter.New(r.name, r.arg)
+	if err != nil {
+		return trace, err
+	}
+	if r.metrics != nil {
+		a = r.metrics.Instrument(r.name, a, r.op)
+	}
+	if r.tracer != nil {
+		a = r.tracer.Instrument(ctx, a)
+	}
+	var onState func(int, gjson.Result)
+	if r.verbose {
+		onState = func(i int, _ gjson.Result) {
+			fmt.Printf("     %s: state %d\n", filename, i)
+		}
+	}
+	return trace, adapter.RunRange(a, trace, r.states, onState)
+}
+
+// step through a trace in the terminal, and return the divergence, if any
+func step(name, arg, filename string, vars []string) error {
+	trace, err := formats.ReadFileVars(filename, vars)
+	if err != nil {
+		return err
+	}
+	stepper := tui.New(filename, trace, func() (adapter.Adapter, error) {
+		return adapter.New(name, arg)
+	})
+	final, err := tea.NewProgram(stepper, tea.WithAltScreen()).Run()
+	if err != nil {
+		return err
+	}
+	if d := final.(tui.Model).Divergence(); d != nil {
+		return d
+	}
+	return final.(tui.Model).Err()
+}
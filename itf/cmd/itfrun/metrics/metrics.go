@@ -0,0 +1,114 @@
+// Package metrics exposes the progress of itfrun as Prometheus metrics, so a
+// long conformance campaign, e.g., with -soak, is monitored while it runs:
+//
+//	itf_steps_total{adapter, op}                the states that were executed
+//	itf_step_failures_total{adapter, op}        the states that diverged
+//	itf_step_duration_seconds{adapter, op}      the latency of the states
+//	itf_traces_total{adapter, result}           the traces that were completed,
+//	                                            by result: passed, diverged, failed
+//
+// The operation of a state is read from the state, e.g., from lastAction.kind.
+// The initial state of a trace is executed with the operation reset.
+package metrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// Metrics are the metrics of replaying traces.
+type Metrics struct {
+	steps    *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	traces   *prometheus.CounterVec
+}
+
+// New registers the metrics with a registry.
+func New(registry prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		steps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "itf_steps_total",
+			Help: "The states of the traces that were executed against the system under test.",
+		}, []string{"adapter", "op"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "itf_step_failures_total",
+			Help: "The states, from which the system under test diverged.",
+		}, []string{"adapter", "op"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "itf_step_duration_seconds",
+			Help:    "The time of executing a state against the system under test.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"adapter", "op"}),
+		traces: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "itf_traces_total",
+			Help: "The traces that were completed, by result: passed, diverged, or failed.",
+		}, []string{"adapter", "result"}),
+	}
+	registry.MustRegister(m.steps, m.failures, m.duration, m.traces)
+	return m
+}
+
+// Instrument returns an adapter that records the metrics of the states that
+// it executes, and then passes them to a. The operation of a state is read from
+// the path op, e.g., lastAction.kind.
+func (m *Metrics) Instrument(name string, a adapter.Adapter, op string) adapter.Adapter {
+	return &instrumented{metrics: m, name: name, adapter: a, op: op}
+}
+
+// Done records a trace that was completed with the result of adapter.Run.
+func (m *Metrics) Done(name string, err error) {
+	var divergence *adapter.Divergence
+	result := "passed"
+	switch {
+	case errors.As(err, &divergence):
+		result = "diverged"
+	case err != nil:
+		result = "failed"
+	}
+	m.traces.WithLabelValues(name, result).Inc()
+}
+
+// Serve serves the metrics of a registry on /metrics with a listener, e.g., of
+// net.Listen("tcp", ":9090"), so that the caller sees an address in use before
+// it serves. It blocks, as http.Serve does.
+func Serve(l net.Listener, registry *prometheus.Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry}))
+	return http.Serve(l, mux)
+}
+
+type instrumented struct {
+	metrics *Metrics
+	name    string
+	adapter adapter.Adapter
+	op      string
+}
+
+func (a *instrumented) Reset(init gjson.Result) error {
+	return a.observe("reset", func() error { return a.adapter.Reset(init) })
+}
+
+func (a *instrumented) Step(state gjson.Result) error {
+	op := state.Get(a.op).String()
+	return a.observe(op, func() error { return a.adapter.Step(state) })
+}
+
+func (a *instrumented) observe(op string, execute func() error) error {
+	start := time.Now()
+	err := execute()
+	a.metrics.duration.WithLabelValues(a.name, op).Observe(time.Since(start).Seconds())
+	a.metrics.steps.WithLabelValues(a.name, op).Inc()
+	if err != nil {
+		a.metrics.failures.WithLabelValues(a.name, op).Inc()
+	}
+	return err
+}
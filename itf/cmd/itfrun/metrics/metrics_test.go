@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+	"github.com/informalsystems/quint-sandbox/itf/adapter/adaptertest"
+)
+
+func TestMetrics(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.CounterTrace)
+	registry := prometheus.NewRegistry()
+	m := New(registry)
+	for i := 0; i < 2; i++ {
+		err := adapter.Run(m.Instrument("counter", &adaptertest.Counter{}, "lastAction.kind"), trace, nil)
+		m.Done("counter", err)
+	}
+	m.Done("counter", fmt.Errorf("connection refused"))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.steps.WithLabelValues("counter", "reset")))
+	assert.Equal(t, 4.0, testutil.ToFloat64(m.steps.WithLabelValues("counter", "inc")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.failures.WithLabelValues("counter", "double")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.failures.WithLabelValues("counter", "inc")))
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP itf_traces_total The traces that were completed, by result: passed, diverged, or failed.
+# TYPE itf_traces_total counter
+itf_traces_total{adapter="counter",result="diverged"} 2
+itf_traces_total{adapter="counter",result="failed"} 1
+`), "itf_traces_total"))
+	// one histogram per operation
+	assert.Equal(t, 3, testutil.CollectAndCount(m.duration))
+}
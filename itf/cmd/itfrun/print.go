@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// an adapter without a system under test, which prints every state,
+// e.g., to look at a trace before writing an adapter for it
+type printer struct {
+	// the state variables to print, or all of them
+	vars []string
+}
+
+func init() {
+	adapter.Register("print", func(arg string) (adapter.Adapter, error) {
+		var p printer
+		if arg != "" {
+			p.vars = strings.Split(arg, ",")
+		}
+		return &p, nil
+	})
+}
+
+func (p *printer) Reset(init gjson.Result) error {
+	p.print(init)
+	return nil
+}
+
+func (p *printer) Step(state gjson.Result) error {
+	p.print(state)
+	return nil
+}
+
+func (p *printer) print(state gjson.Result) {
+	if len(p.vars) == 0 {
+		fmt.Println(state.Raw)
+		return
+	}
+	for _, v := range p.vars {
+		fmt.Printf("%s = %s\n", v, state.Get(v).Raw)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/formats"
+)
+
+// a trace that was read ahead of its replay
+type loaded struct {
+	trace itf.Trace
+	err   error
+}
+
+// readAhead reads and decodes the traces of files in parallel, while the
+// traces that were read before are replayed, so the reading of large files
+// overlaps with the replay. At most n traces are read, or held in memory,
+// before they are replayed. The returned function returns the i-th trace,
+// waiting for it to be read, and it has to be called for every file in order.
+// Only the state variables vars are decoded, or all of them, if vars is nil.
+// The returned function stop stops the reading ahead, once the caller no
+// longer calls read, e.g., after an error, and it may be called more than once.
+func readAhead(files, vars []string, n int) (read func(i int) (itf.Trace, error), stop func()) {
+	if n < 1 {
+		n = 1
+	}
+	results := make([]chan loaded, len(files))
+	for i := range results {
+		results[i] = make(chan loaded, 1)
+	}
+	// a token for every trace that is read, but not replayed yet
+	tokens := make(chan struct{}, n)
+	done := make(chan struct{})
+	go func() {
+		for i, filename := range files {
+			select {
+			case tokens <- struct{}{}:
+			case <-done:
+				return
+			}
+			go func(i int, filename string) {
+				trace, err := formats.ReadFileVars(filename, vars)
+				results[i] <- loaded{trace, err}
+			}(i, filename)
+		}
+	}()
+	read = func(i int) (itf.Trace, error) {
+		l := <-results[i]
+		<-tokens
+		return l.trace, l.err
+	}
+	var once sync.Once
+	stop = func() {
+		once.Do(func() { close(done) })
+	}
+	return read, stop
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAhead(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 10; i++ {
+		filename := filepath.Join(dir, fmt.Sprintf("trace%d.itf.json", i))
+		data := fmt.Sprintf(`{ "vars": [ "x" ], "states": [ { "x": %d } ] }`, i)
+		if i == 4 {
+			data = "not a trace"
+		}
+		require.NoError(t, os.WriteFile(filename, []byte(data), 0o644))
+		files = append(files, filename)
+	}
+	for _, n := range []int{0, 1, 3, 20} {
+		read, stop := readAhead(files, nil, n)
+		for i := range files {
+			trace, err := read(i)
+			if i == 4 {
+				assert.Error(t, err)
+				continue
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(i), trace.States[0].Get("x").Int(), "read-ahead %d", n)
+		}
+		stop()
+	}
+}
+
+func TestStopReadAhead(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 10; i++ {
+		filename := filepath.Join(dir, fmt.Sprintf("trace%d.itf.json", i))
+		require.NoError(t, os.WriteFile(filename, []byte(`{ "vars": [], "states": [ {} ] }`), 0o644))
+		files = append(files, filename)
+	}
+	before := runtime.NumGoroutine()
+	// the caller stops after the first trace, so the reading ahead is blocked
+	// on the second one, until it is stopped
+	read, stop := readAhead(files, nil, 1)
+	_, err := read(0)
+	require.NoError(t, err)
+	stop()
+	stop()
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; {
+		require.True(t, time.Now().Before(deadline), "the reading ahead is still running")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
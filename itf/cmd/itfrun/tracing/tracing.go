@@ -0,0 +1,149 @@
+// Package tracing instruments itfrun with OpenTelemetry, so the slow
+// operations of a system under test are found in a large campaign. Every
+// trace is replayed in a span of its own, and every state of the trace is
+// executed in a child span, which is named after the operation of the state:
+//
+//	itfrun.trace    itf.file, itf.adapter, itf.states, itf.result
+//	<op>            itf.state, itf.op, itf.result
+//
+// The operation of a state is read from the state, e.g., from lastAction.kind.
+// The initial state of a trace is executed with the operation reset. The
+// results are passed, diverged, or failed, and the errors are recorded in the
+// spans, whose status is then Error.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// Setup returns a tracer provider that exports the spans, either as JSON to w
+// with the exporter stdout, or over OTLP/gRPC with the exporter otlp, which is
+// configured with the usual variables, e.g., OTEL_EXPORTER_OTLP_ENDPOINT.
+// The provider has to be shut down to export the last spans.
+func Setup(ctx context.Context, exporter string, w io.Writer) (*sdktrace.TracerProvider, error) {
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case "otlp":
+		exp, err = otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown exporter %q, expected stdout or otlp", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName("itfrun")))
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res)), nil
+}
+
+// Tracer creates the spans of the traces and of their states.
+type Tracer struct {
+	tracer trace.Tracer
+	// the path of the operation of a state
+	op string
+}
+
+// New returns a tracer of a provider, which reads the operations of the
+// states from the path op, e.g., lastAction.kind.
+func New(provider trace.TracerProvider, op string) *Tracer {
+	return &Tracer{tracer: provider.Tracer("github.com/informalsystems/quint-sandbox/itf/cmd/itfrun"), op: op}
+}
+
+// Start starts the span of a trace, which is replayed against an adapter.
+func (t *Tracer) Start(ctx context.Context, filename, name string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "itfrun.trace", trace.WithAttributes(
+		attribute.String("itf.file", filename),
+		attribute.String("itf.adapter", name),
+	))
+}
+
+// End ends the span of a trace with the result of adapter.Run.
+func (t *Tracer) End(span trace.Span, states int, err error) {
+	span.SetAttributes(attribute.Int("itf.states", states), attribute.String("itf.result", result(err)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Instrument returns an adapter that executes every state in a child span of
+// the span in ctx, and then passes the state to a.
+func (t *Tracer) Instrument(ctx context.Context, a adapter.Adapter) adapter.Adapter {
+	return &instrumented{tracer: t, ctx: ctx, adapter: a}
+}
+
+type instrumented struct {
+	tracer  *Tracer
+	ctx     context.Context
+	adapter adapter.Adapter
+	// the index of the next state
+	next int
+}
+
+func (a *instrumented) Reset(init gjson.Result) error {
+	a.next = 0
+	return a.span("reset", func() error { return a.adapter.Reset(init) })
+}
+
+func (a *instrumented) Step(state gjson.Result) error {
+	return a.span(state.Get(a.tracer.op).String(), func() error { return a.adapter.Step(state) })
+}
+
+func (a *instrumented) span(op string, execute func() error) error {
+	_, span := a.tracer.tracer.Start(a.ctx, op, trace.WithAttributes(
+		attribute.Int("itf.state", a.next),
+		attribute.String("itf.op", op),
+	))
+	a.next++
+	err := execute()
+	if err != nil {
+		// the system under test diverged from the state, or it failed
+		result := "failed"
+		if adapter.IsDivergence(err) {
+			result = "diverged"
+		}
+		span.SetAttributes(attribute.String("itf.result", result))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.String("itf.result", "passed"))
+	}
+	span.End()
+	return err
+}
+
+// the result of a replay, as in the metrics
+func result(err error) string {
+	var divergence *adapter.Divergence
+	switch {
+	case err == nil:
+		return "passed"
+	case errors.As(err, &divergence):
+		return "diverged"
+	default:
+		return "failed"
+	}
+}
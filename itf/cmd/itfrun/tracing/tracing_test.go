@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+	"github.com/informalsystems/quint-sandbox/itf/adapter/adaptertest"
+)
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := New(provider, "lastAction.kind")
+	trace := adaptertest.Parse(t, adaptertest.CounterTrace)
+
+	ctx, span := tracer.Start(context.Background(), "counter.itf.json", "counter")
+	err := adapter.Run(tracer.Instrument(ctx, &adaptertest.Counter{}), trace, nil)
+	tracer.End(span, len(trace.States), err)
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 5)
+	root := spans[4]
+	assert.Equal(t, "itfrun.trace", root.Name())
+	assert.Equal(t, "diverged", attributes(root)["itf.result"].AsString())
+	assert.Equal(t, int64(4), attributes(root)["itf.states"].AsInt64())
+	assert.Equal(t, codes.Error, root.Status().Code)
+
+	var names []string
+	for i, s := range spans[:4] {
+		names = append(names, s.Name())
+		assert.Equal(t, root.SpanContext().SpanID(), s.Parent().SpanID())
+		assert.Equal(t, int64(i), attributes(s)["itf.state"].AsInt64())
+	}
+	assert.Equal(t, []string{"reset", "inc", "inc", "double"}, names)
+	assert.Equal(t, "passed", attributes(spans[2])["itf.result"].AsString())
+	assert.Equal(t, "diverged", attributes(spans[3])["itf.result"].AsString())
+	assert.Equal(t, "counter: expected 4, found 3", spans[3].Status().Description)
+	require.Len(t, spans[3].Events(), 1, "the error is recorded")
+}
+
+func TestUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), "zipkin", nil)
+	assert.ErrorContains(t, err, `unknown exporter "zipkin"`)
+}
@@ -0,0 +1,308 @@
+// Package tui is an interactive stepper of itfrun: a debugger for the traces,
+// from which a system under test diverged. It shows a state of a trace, and it
+// executes the states one by one against an adapter, when asked to:
+//
+//	n, enter, space  execute the next state
+//	c                continue until the end, or until the system diverges
+//	←, →             browse the states without executing them
+//	↑, ↓             scroll the state
+//	r                restart with a fresh adapter
+//	q                quit
+//
+// The variables that changed from the previous state are highlighted, and so
+// is the variable, whose path starts the error of a divergence, e.g., the
+// variable p of "p.sender: expected ..., found ...".
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	okStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	failStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+	changedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	pendingStyle  = lipgloss.NewStyle().Faint(true)
+	helpStyle     = lipgloss.NewStyle().Faint(true)
+	ruleCharacter = "─"
+)
+
+// Model is the state of the stepper, as a Bubble Tea model.
+type Model struct {
+	filename   string
+	trace      itf.Trace
+	newAdapter func() (adapter.Adapter, error)
+	adapter    adapter.Adapter
+
+	// the number of states that were executed
+	executed int
+	// the divergence of the system under test, if any
+	divergence *adapter.Divergence
+	// whether a state is being executed, and whether to go on after it
+	running, continuing bool
+	// an error that is not a divergence, e.g., of creating the adapter
+	err error
+
+	// the state that is shown, and the first line of it on the screen
+	cursor, scroll int
+	width, height  int
+}
+
+// the result of executing a state
+type executedMsg struct {
+	state int
+	err   error
+}
+
+// New returns a stepper of a trace. The adapter is created by newAdapter, when
+// the first state is executed, and again on every restart.
+func New(filename string, trace itf.Trace, newAdapter func() (adapter.Adapter, error)) Model {
+	return Model{filename: filename, trace: trace, newAdapter: newAdapter, width: 80, height: 24}
+}
+
+// Divergence returns the divergence of the system under test, or nil.
+func (m Model) Divergence() *adapter.Divergence {
+	return m.divergence
+}
+
+// Executed returns the number of states that were executed without a divergence.
+func (m Model) Executed() int {
+	return m.executed
+}
+
+// Err returns the error of creating the adapter, or nil.
+func (m Model) Err() error {
+	return m.err
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case executedMsg:
+		return m.finish(msg)
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "n", "enter", " ":
+			m.continuing = false
+			return m.execute()
+		case "c":
+			m.continuing = true
+			return m.execute()
+		case "left", "h":
+			m.show(m.cursor - 1)
+		case "right", "l":
+			m.show(m.cursor + 1)
+		case "home", "g":
+			m.show(0)
+		case "end", "G":
+			m.show(len(m.trace.States) - 1)
+		case "up", "k":
+			if m.scroll > 0 {
+				m.scroll--
+			}
+		case "down", "j":
+			m.scroll++
+		case "r":
+			if !m.running {
+				m.adapter, m.executed, m.divergence, m.err = nil, 0, nil, nil
+				m.show(0)
+			}
+		}
+	}
+	return m, nil
+}
+
+// show a state, if it is in the trace
+func (m *Model) show(i int) {
+	if i >= 0 && i < len(m.trace.States) && i != m.cursor {
+		m.cursor, m.scroll = i, 0
+	}
+}
+
+// execute the next state of the trace in the background
+func (m Model) execute() (tea.Model, tea.Cmd) {
+	if m.running || m.divergence != nil || m.err != nil || m.executed == len(m.trace.States) {
+		m.continuing = false
+		return m, nil
+	}
+	if m.adapter == nil {
+		a, err := m.newAdapter()
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.adapter = a
+	}
+	m.running = true
+	i, a, state := m.executed, m.adapter, m.trace.States[m.executed]
+	return m, func() tea.Msg {
+		if i == 0 {
+			return executedMsg{state: i, err: a.Reset(state)}
+		}
+		return executedMsg{state: i, err: a.Step(state)}
+	}
+}
+
+// record the result of executing a state, and go on if continuing
+func (m Model) finish(msg executedMsg) (tea.Model, tea.Cmd) {
+	m.running = false
+	m.show(msg.state)
+	if msg.err != nil {
+		if adapter.IsDivergence(msg.err) {
+			m.divergence = &adapter.Divergence{State: msg.state, Err: msg.err}
+		} else {
+			m.err = fmt.Errorf("state %d: %w", msg.state, msg.err)
+		}
+		m.continuing = false
+		return m, nil
+	}
+	m.executed = msg.state + 1
+	if m.continuing {
+		return m.execute()
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	var b strings.Builder
+	rule := strings.Repeat(ruleCharacter, max(m.width, 1))
+	fmt.Fprintf(&b, "%s  state %d/%d\n", titleStyle.Render(m.filename), m.cursor, len(m.trace.States)-1)
+	b.WriteString(m.status() + "\n")
+	b.WriteString(rule + "\n")
+
+	lines := m.stateLines()
+	// the header, the rules, the error, and the help take 6 lines
+	room := max(m.height-6, 1)
+	scroll := min(m.scroll, max(len(lines)-room, 0))
+	for _, line := range lines[scroll:min(scroll+room, len(lines))] {
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString(rule + "\n")
+	if m.divergence != nil && m.divergence.State == m.cursor {
+		b.WriteString(failStyle.Render(m.divergence.Err.Error()) + "\n")
+	} else if m.err != nil {
+		b.WriteString(failStyle.Render(m.err.Error()) + "\n")
+	} else {
+		b.WriteString("\n")
+	}
+	b.WriteString(helpStyle.Render("n: next  c: continue  ←/→: browse  ↑/↓: scroll  r: restart  q: quit"))
+	return b.String()
+}
+
+// the status of the execution
+func (m Model) status() string {
+	switch {
+	case m.err != nil:
+		return failStyle.Render("error")
+	case m.divergence != nil:
+		return failStyle.Render(fmt.Sprintf("✘ diverged at state %d", m.divergence.State))
+	case m.running:
+		return fmt.Sprintf("executing state %d…", m.executed)
+	case m.executed == len(m.trace.States):
+		return okStyle.Render(fmt.Sprintf("✔ all %d states executed", m.executed))
+	case m.executed == 0:
+		return pendingStyle.Render("not started")
+	default:
+		return okStyle.Render(fmt.Sprintf("✔ states 0-%d executed", m.executed-1))
+	}
+}
+
+// the lines of the state that is shown, one per variable
+func (m Model) stateLines() []string {
+	state := fields(m.trace.States[m.cursor])
+	var previous map[string]gjson.Result
+	if m.cursor > 0 {
+		previous = fields(m.trace.States[m.cursor-1])
+	}
+	failed := m.divergence != nil && m.divergence.State == m.cursor
+	diverged := ""
+	if failed {
+		diverged = culprit(m.divergence.Err.Error())
+	}
+	var lines []string
+	for _, name := range m.vars(m.trace.States[m.cursor]) {
+		value := state[name]
+		line := truncate(fmt.Sprintf("  %s = %s", name, itf.Show(value)), m.width)
+		switch {
+		case name == diverged:
+			line = failStyle.Render(line)
+		case m.cursor >= m.executed && !failed:
+			line = pendingStyle.Render(line)
+		case m.cursor > 0 && !sameValue(value, previous[name]):
+			line = changedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// the names of the variables of a state, in the order of the trace
+func (m Model) vars(state gjson.Result) []string {
+	names := append([]string(nil), m.trace.Vars...)
+	if len(names) == 0 {
+		state.ForEach(func(key, _ gjson.Result) bool {
+			names = append(names, key.String())
+			return true
+		})
+		sort.Strings(names)
+	}
+	var vars []string
+	for _, name := range names {
+		if name != "#meta" {
+			vars = append(vars, name)
+		}
+	}
+	return vars
+}
+
+// the fields of an object by their names, which may contain dots
+func fields(obj gjson.Result) map[string]gjson.Result {
+	m := make(map[string]gjson.Result)
+	obj.ForEach(func(key, value gjson.Result) bool {
+		m[key.String()] = value
+		return true
+	})
+	return m
+}
+
+// the variable at the start of an error, e.g., p of "p.sender: expected ..."
+func culprit(err string) string {
+	path, _, found := strings.Cut(err, ": ")
+	if !found {
+		return ""
+	}
+	name, _, _ := strings.Cut(path, ".")
+	return name
+}
+
+func sameValue(a, b gjson.Result) bool {
+	ca, errA := itf.Canonical(a)
+	cb, errB := itf.Canonical(b)
+	return errA == nil && errB == nil && ca == cb
+}
+
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if width <= 1 || len(runes) <= width {
+		return line
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,125 @@
+package tui
+
+import (
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+	"github.com/informalsystems/quint-sandbox/itf/adapter/adaptertest"
+)
+
+func newStepper(t *testing.T) (Model, *int) {
+	trace := adaptertest.Parse(t, adaptertest.CounterTrace)
+	created := 0
+	return New("counter.itf.json", trace, func() (adapter.Adapter, error) {
+		created++
+		return &adaptertest.Counter{}, nil
+	}), &created
+}
+
+// press a key, and run the commands that it leads to, as Bubble Tea would
+func press(m Model, key string) Model {
+	var msg tea.Msg
+	switch key {
+	case "left":
+		msg = tea.KeyMsg{Type: tea.KeyLeft}
+	case "right":
+		msg = tea.KeyMsg{Type: tea.KeyRight}
+	default:
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	}
+	for msg != nil {
+		model, cmd := m.Update(msg)
+		m, msg = model.(Model), nil
+		if cmd != nil {
+			msg = cmd()
+		}
+	}
+	return m
+}
+
+func TestStepping(t *testing.T) {
+	m, created := newStepper(t)
+	assert.Contains(t, m.View(), "not started")
+
+	m = press(m, "n")
+	m = press(m, "n")
+	assert.Equal(t, 2, m.Executed())
+	assert.Equal(t, 1, *created)
+	assert.Contains(t, m.View(), "state 1/3")
+	assert.Contains(t, m.View(), "states 0-1 executed")
+	assert.Contains(t, m.View(), "counter = 1")
+	assert.Contains(t, m.View(), `lastAction = { kind: "inc" }`)
+
+	// browsing does not execute the states
+	m = press(m, "right")
+	m = press(m, "right")
+	assert.Contains(t, m.View(), "state 3/3")
+	assert.Equal(t, 2, m.Executed())
+	m = press(m, "left")
+	assert.Contains(t, m.View(), "state 2/3")
+}
+
+func TestContinueUntilDivergence(t *testing.T) {
+	m, created := newStepper(t)
+	m = press(m, "c")
+	require.NotNil(t, m.Divergence())
+	assert.Equal(t, 3, m.Divergence().State)
+	assert.Equal(t, 3, m.Executed())
+	view := m.View()
+	assert.Contains(t, view, "diverged at state 3")
+	assert.Contains(t, view, "counter: expected 4, found 3")
+	assert.Contains(t, view, "counter = 4")
+	assert.Equal(t, "counter", culprit(m.Divergence().Err.Error()))
+
+	// no more states are executed after a divergence, until a restart
+	m = press(m, "n")
+	assert.Equal(t, 3, m.Executed())
+	m = press(m, "r")
+	assert.Nil(t, m.Divergence())
+	assert.Contains(t, m.View(), "state 0/3")
+	m = press(m, "n")
+	assert.Equal(t, 1, m.Executed())
+	assert.Equal(t, 2, *created)
+}
+
+func TestAdapterError(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.CounterTrace)
+	m := New("counter.itf.json", trace, func() (adapter.Adapter, error) {
+		return nil, fmt.Errorf("connection refused")
+	})
+	m = press(m, "n")
+	assert.EqualError(t, m.Err(), "connection refused")
+	assert.Contains(t, m.View(), "connection refused")
+}
+
+// a counter that loses its connection after the initial state
+type disconnected struct {
+	adaptertest.Counter
+}
+
+func (disconnected) Step(gjson.Result) error {
+	return fmt.Errorf("connection reset")
+}
+
+func TestStepError(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.CounterTrace)
+	m := New("counter.itf.json", trace, func() (adapter.Adapter, error) {
+		return &disconnected{}, nil
+	})
+	m = press(m, "n")
+	m = press(m, "n")
+	assert.Nil(t, m.Divergence())
+	assert.EqualError(t, m.Err(), "state 1: connection reset")
+}
+
+func TestCulprit(t *testing.T) {
+	assert.Equal(t, "p", culprit("p.sender: expected 1, found 2"))
+	assert.Equal(t, "balances", culprit("balances: missing in the snapshot"))
+	assert.Equal(t, "", culprit("connection refused"))
+}
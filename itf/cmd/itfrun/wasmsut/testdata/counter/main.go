@@ -0,0 +1,123 @@
+// A counter that is compiled to WebAssembly, and driven by the adapter wasm
+// in the tests of wasmsut, see the ABI in ../../wasmsut.go:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o counter.wasm .
+//
+// With OFF_BY_ONE=1 in the environment, its decrement is off by one.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// the buffers of the requests and of the replies, which are kept alive until
+// the adapter frees them
+var buffers = make(map[uint32][]byte)
+
+var (
+	value    int64
+	offByOne = os.Getenv("OFF_BY_ONE") == "1"
+)
+
+// an integer in ITF JSON
+type bigint struct {
+	Value string `json:"#bigint"`
+}
+
+func (i bigint) int64() (int64, error) {
+	var v int64
+	_, err := fmt.Sscan(i.Value, &v)
+	return v, err
+}
+
+//go:wasmexport itf_alloc
+func alloc(size uint32) uint32 {
+	buf := make([]byte, size+1)
+	ptr := uint32(uintptr(unsafe.Pointer(&buf[0])))
+	buffers[ptr] = buf[:size]
+	return ptr
+}
+
+//go:wasmexport itf_free
+func free(ptr, size uint32) {
+	delete(buffers, ptr)
+}
+
+// the request in a buffer
+func request(ptr, size uint32) []byte {
+	return buffers[ptr][:size]
+}
+
+// copy a reply into a new buffer, and pack its address and its size
+func reply(v any) uint64 {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	ptr := alloc(uint32(len(data)))
+	copy(buffers[ptr], data)
+	return uint64(ptr)<<32 | uint64(len(data))
+}
+
+// an error in the replies of reset and step
+func message(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+//go:wasmexport itf_reset
+func reset(ptr, size uint32) uint64 {
+	var init struct {
+		Counter bigint `json:"counter"`
+	}
+	err := json.Unmarshal(request(ptr, size), &init)
+	if err == nil {
+		value, err = init.Counter.int64()
+	}
+	return reply(map[string]string{"error": message(err)})
+}
+
+//go:wasmexport itf_step
+func step(ptr, size uint32) uint64 {
+	var req struct {
+		Op   string `json:"op"`
+		Args struct {
+			Amount bigint `json:"amount"`
+		} `json:"args"`
+	}
+	if err := json.Unmarshal(request(ptr, size), &req); err != nil {
+		return reply(map[string]any{"result": nil, "error": err.Error()})
+	}
+	amount, err := req.Args.Amount.int64()
+	if err == nil {
+		switch req.Op {
+		case "inc":
+			value += amount
+		case "dec":
+			if value < amount {
+				err = errors.New("underflow")
+				break
+			}
+			value -= amount
+			if offByOne {
+				value--
+			}
+		default:
+			err = fmt.Errorf("unknown operation: %s", req.Op)
+		}
+	}
+	return reply(map[string]any{"result": nil, "error": message(err)})
+}
+
+//go:wasmexport itf_snapshot
+func snapshot() uint64 {
+	return reply(map[string]any{"counter": bigint{fmt.Sprint(value)}})
+}
+
+func main() {}
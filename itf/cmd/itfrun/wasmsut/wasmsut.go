@@ -0,0 +1,297 @@
+// Package wasmsut drives a system under test that is compiled to WebAssembly,
+// e.g., a Rust port of the decimal type, in the runtime wazero, so that it is
+// checked against the same traces as the Go code, without FFI and without
+// serving it over the network.
+//
+// The module exports its memory and the functions below, whose requests and
+// replies are JSON in the linear memory of the module, with the values in the
+// ITF format, as in sut.proto of the package grpcsut:
+//
+//   - itf_alloc(size i32) -> i32 allocates a buffer of size bytes, into which
+//     the adapter writes a request,
+//   - itf_free(ptr i32, size i32) releases the buffer of a request or of a
+//     reply, if the module exports it,
+//   - itf_reset(ptr i32, size i32) -> i64 brings the system into the initial
+//     state of a trace, which is an object of the state variables, and replies
+//     {"error": string},
+//   - itf_step(ptr i32, size i32) -> i64 executes a request {"op": string,
+//     "args": value}, and replies {"result": value, "error": string}, where
+//     "error" is empty if the operation succeeded,
+//   - itf_snapshot() -> i64 replies with the state variables of the system,
+//     which is only needed if they are compared with the states.
+//
+// A reply is a buffer of the module, whose address and size are packed into
+// the result of the function as ptr<<32 | size.
+//
+// Every trace is replayed in a fresh instance of the module, so a trace does
+// not inherit the memory of the previous one. The modules for WASI, e.g., of
+// the target wasm32-wasip1 of Rust, or of GOOS=wasip1 of Go, run with WASI,
+// whose standard output and error are passed to the standard error.
+package wasmsut
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+func init() {
+	adapter.Register("wasm", New)
+}
+
+// Module is an adapter that drives a module of WebAssembly.
+type Module struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	config   wazero.ModuleConfig
+	// the instance of the current trace, or nil before the first one
+	instance api.Module
+	// the paths of the operation, its arguments, its error, and its result in a state
+	op, args, error, result string
+	// the paths of the state variables to compare with the snapshots
+	compare []string
+	// the mapping of the state variables to the snapshots, or nil
+	mapping *adapter.Mapping
+}
+
+// New compiles a module of a file. The options of the argument are:
+//
+//   - module: the file of the module, e.g., counter.wasm,
+//   - env: a variable of the environment of the module, written as KEY=VALUE,
+//     repeated for every variable, by default none,
+//   - op, args, error, result, compare, and mapping, as for the adapter grpc:
+//     the paths of the operation, of its arguments, of its expected error and
+//     result in a state, and the state variables to compare with the
+//     snapshots, see grpcsut.New.
+func New(arg string) (adapter.Adapter, error) {
+	opts, err := adapter.ParseOptions(arg)
+	if err != nil {
+		return nil, err
+	}
+	filename := opts.Get("module", "")
+	if filename == "" {
+		return nil, fmt.Errorf("missing the option module")
+	}
+	binary, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewModule(binary, opts)
+}
+
+// NewModule compiles the binary of a module, see New for the options.
+func NewModule(binary []byte, opts adapter.Options) (*Module, error) {
+	var mapping *adapter.Mapping
+	if filename := opts.Get("mapping", ""); filename != "" {
+		if len(opts["compare"]) > 0 {
+			return nil, fmt.Errorf("the options compare and mapping exclude each other")
+		}
+		var err error
+		if mapping, err = adapter.ReadMapping(filename); err != nil {
+			return nil, err
+		}
+	}
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStdout(os.Stderr).
+		WithStderr(os.Stderr).
+		// a reactor of WASI initializes itself in _initialize, instead of _start
+		WithStartFunctions("_initialize")
+	for _, kv := range opts["env"] {
+		key, value, found := strings.Cut(kv, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("expected env=KEY=VALUE, found env=%s", kv)
+		}
+		config = config.WithEnv(key, value)
+	}
+	m := &Module{
+		config:  config,
+		op:      opts.Get("op", "lastAction.kind"),
+		args:    opts.Get("args", "lastAction"),
+		error:   opts.Get("error", "lastAction.error"),
+		result:  opts.Get("result", ""),
+		compare: opts["compare"],
+		mapping: mapping,
+	}
+
+	ctx := context.Background()
+	m.runtime = wazero.NewRuntime(ctx)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, m.runtime); err != nil {
+		m.Close()
+		return nil, err
+	}
+	var err error
+	if m.compiled, err = m.runtime.CompileModule(ctx, binary); err != nil {
+		m.Close()
+		return nil, err
+	}
+	if len(m.compiled.ExportedMemories()) == 0 {
+		m.Close()
+		return nil, fmt.Errorf("the module does not export its memory")
+	}
+	required := []string{"itf_alloc", "itf_reset", "itf_step"}
+	if len(m.compare) > 0 || m.mapping != nil {
+		required = append(required, "itf_snapshot")
+	}
+	exported := m.compiled.ExportedFunctions()
+	for _, name := range required {
+		if _, found := exported[name]; !found {
+			m.Close()
+			return nil, fmt.Errorf("the module does not export the function %s", name)
+		}
+	}
+	return m, nil
+}
+
+// Close releases the instance and the runtime of the module.
+func (m *Module) Close() error {
+	return m.runtime.Close(context.Background())
+}
+
+// call a function of the module with a request, and decode its reply into v
+func (m *Module) call(name string, request []byte, v any) error {
+	ctx := context.Background()
+	var params []uint64
+	if request != nil {
+		ptr, err := m.write(ctx, request)
+		if err != nil {
+			return err
+		}
+		defer m.free(ctx, ptr, uint32(len(request)))
+		params = []uint64{uint64(ptr), uint64(len(request))}
+	}
+	results, err := m.instance.ExportedFunction(name).Call(ctx, params...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	ptr, size := uint32(results[0]>>32), uint32(results[0])
+	data, ok := m.instance.Memory().Read(ptr, size)
+	if !ok {
+		return fmt.Errorf("%s: the reply of %d bytes at %d is out of the memory", name, size, ptr)
+	}
+	// the data is a view of the memory, which the next call may overwrite
+	err = json.Unmarshal(data, v)
+	m.free(ctx, ptr, size)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, data)
+	}
+	return nil
+}
+
+// copy a request into a buffer of the module
+func (m *Module) write(ctx context.Context, request []byte) (uint32, error) {
+	results, err := m.instance.ExportedFunction("itf_alloc").Call(ctx, uint64(len(request)))
+	if err != nil {
+		return 0, fmt.Errorf("itf_alloc: %w", err)
+	}
+	ptr := uint32(results[0])
+	if !m.instance.Memory().Write(ptr, request) {
+		return 0, fmt.Errorf("itf_alloc: the buffer of %d bytes at %d is out of the memory", len(request), ptr)
+	}
+	return ptr, nil
+}
+
+// release a buffer of the module, if it exports itf_free
+func (m *Module) free(ctx context.Context, ptr, size uint32) {
+	if free := m.instance.ExportedFunction("itf_free"); free != nil {
+		_, _ = free.Call(ctx, uint64(ptr), uint64(size))
+	}
+}
+
+func (m *Module) Reset(init gjson.Result) error {
+	ctx := context.Background()
+	if m.instance != nil {
+		_ = m.instance.Close(ctx)
+	}
+	var err error
+	if m.instance, err = m.runtime.InstantiateModule(ctx, m.compiled, m.config); err != nil {
+		return err
+	}
+	// the metadata of a state is not a state variable
+	state := make(map[string]json.RawMessage)
+	init.ForEach(func(key, value gjson.Result) bool {
+		if key.String() != "#meta" {
+			state[key.String()] = json.RawMessage(value.Raw)
+		}
+		return true
+	})
+	request, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	var reply struct {
+		Error string `json:"error"`
+	}
+	if err := m.call("itf_reset", request, &reply); err != nil {
+		return err
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("reset: %s", reply.Error)
+	}
+	return m.checkSnapshot(init)
+}
+
+func (m *Module) Step(state gjson.Result) error {
+	op := state.Get(m.op).String()
+	args := json.RawMessage("null")
+	if raw := state.Get(m.args).Raw; raw != "" {
+		args = json.RawMessage(raw)
+	}
+	request, err := json.Marshal(map[string]any{"op": op, "args": args})
+	if err != nil {
+		return err
+	}
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	if err := m.call("itf_step", request, &reply); err != nil {
+		return err
+	}
+	if m.error != "" && reply.Error != state.Get(m.error).String() {
+		return adapter.Diverged(fmt.Errorf("%s: expected the error %q, found %q", op, state.Get(m.error).String(), reply.Error))
+	}
+	if m.result != "" {
+		if err := adapter.Compare(m.result, state.Get(m.result), gjson.ParseBytes(reply.Result)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return m.checkSnapshot(state)
+}
+
+// compare the state variables of a snapshot with a state of the trace
+func (m *Module) checkSnapshot(state gjson.Result) error {
+	if len(m.compare) == 0 && m.mapping == nil {
+		return nil
+	}
+	var snapshot map[string]json.RawMessage
+	if err := m.call("itf_snapshot", nil, &snapshot); err != nil {
+		return err
+	}
+	if m.mapping != nil {
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+		return m.mapping.Compare(state, gjson.ParseBytes(data))
+	}
+	for _, path := range m.compare {
+		actual, found := snapshot[path]
+		if !found {
+			return fmt.Errorf("%s: missing in the snapshot", path)
+		}
+		if err := adapter.Compare(path, state.Get(path), gjson.ParseBytes(actual)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package wasmsut
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+	"github.com/informalsystems/quint-sandbox/itf/adapter/adaptertest"
+)
+
+var (
+	buildOnce   sync.Once
+	counterWasm string
+	buildErr    error
+)
+
+// build the counter of testdata/counter to WebAssembly, once for all tests
+func buildCounter(t *testing.T) string {
+	buildOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "wasmsut")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		counterWasm = filepath.Join(dir, "counter.wasm")
+		cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", counterWasm, ".")
+		cmd.Dir = filepath.Join("testdata", "counter")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			buildErr = errors.New(string(out))
+		}
+	})
+	require.NoError(t, buildErr, "cannot build the counter with GOOS=wasip1")
+	return counterWasm
+}
+
+// load the counter with the options of an adapter
+func load(t *testing.T, arg string) adapter.Adapter {
+	a, err := adapter.New("wasm", "module="+buildCounter(t)+","+arg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = a.(*Module).Close() })
+	return a
+}
+
+func TestReplayInWasm(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.AmountTrace)
+	m := load(t, "compare=counter")
+	assert.NoError(t, adapter.Run(m, trace, nil))
+	// the next trace starts from a fresh instance
+	assert.NoError(t, adapter.Run(m, trace, nil))
+}
+
+func TestDivergenceInWasm(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.AmountTrace)
+	err := adapter.Run(load(t, "compare=counter,env=OFF_BY_ONE=1"), trace, nil)
+	var divergence *adapter.Divergence
+	require.True(t, errors.As(err, &divergence), "expected a divergence, found %v", err)
+	assert.Equal(t, 3, divergence.State)
+	assert.ErrorContains(t, err, `counter: expected {"#bigint":"1"}, found {"#bigint":"0"}`)
+}
+
+func TestMappingInWasm(t *testing.T) {
+	trace := adaptertest.Parse(t, adaptertest.AmountTrace)
+	mapping := filepath.Join(t.TempDir(), "mapping.json")
+	require.NoError(t, os.WriteFile(mapping, []byte(`{ "vars": [ { "spec": "counter" } ] }`), 0o644))
+	assert.NoError(t, adapter.Run(load(t, "mapping="+mapping), trace, nil))
+}
+
+func TestMissingExports(t *testing.T) {
+	// the empty module, which exports nothing
+	empty := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	_, err := NewModule(empty, adapter.Options{})
+	assert.ErrorContains(t, err, "the module does not export its memory")
+	_, err = adapter.New("wasm", "")
+	assert.ErrorContains(t, err, "missing the option module")
+	_, err = adapter.New("wasm", "module="+buildCounter(t)+",env=OFF_BY_ONE")
+	assert.ErrorContains(t, err, "expected env=KEY=VALUE")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf/monitor"
+)
+
+// check the events of a system, which are read from files, or from the
+// standard input, if there are none or a file is "-", and return the exit code
+func watch(name, arg string, files []string, verbose bool) int {
+	m, err := monitor.New(name, arg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating the monitor: %v\n", err)
+		return 2
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	violated := false
+	for _, filename := range files {
+		var onEvent func(int, gjson.Result)
+		if verbose {
+			onEvent = func(i int, _ gjson.Result) {
+				fmt.Printf("ok   %s: event %d\n", filename, i)
+			}
+		}
+		onViolation := func(v *monitor.Violation) {
+			fmt.Printf("FAIL %s: %v\n", filename, v)
+			violated = true
+		}
+		// the state of the monitor carries over from one file to the next, as
+		// the files are the consecutive logs of the same system
+		var r io.Reader = os.Stdin
+		if filename != "-" {
+			f, err := os.Open(filename)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error reading the events: %v\n", err)
+				return 2
+			}
+			defer f.Close()
+			r = f
+		}
+		n, err := monitor.Run(r, m, onEvent, onViolation)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading the events of %s: %v\n", filename, err)
+			return 2
+		}
+		fmt.Printf("%d events of %s checked\n", n, filename)
+	}
+	if violated {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package coinstest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+func TestDecodeTrace(t *testing.T) {
+	trace, err := itf.ReadFile("../../../../../coins/test-inputs-v0.46.4/oneRandom.itf.json")
+	require.NoError(t, err)
+	states, err := DecodeTrace(trace)
+	require.NoError(t, err)
+	require.Len(t, states, len(trace.States))
+
+	s := states[1]
+	assert.Equal(t, "add", s.Opcode)
+	assert.False(t, s.OpResult.Error)
+	assert.Equal(t, big.NewInt(1), s.OpArg1["atom"])
+	assert.Equal(t, big.NewInt(2), s.OpResult.Coins["osmo"])
+	assert.Empty(t, s.OpSlice)
+}
+
+func TestCheckInvariants(t *testing.T) {
+	trace, err := itf.ReadFile("../../../../../coins/test-inputs-v0.46.4/oneRandom.itf.json")
+	require.NoError(t, err)
+	states, err := DecodeTrace(trace)
+	require.NoError(t, err)
+	for i, s := range states {
+		assert.NoError(t, CheckInvariants(s), "state %d", i)
+	}
+
+	s := states[1]
+	s.OpResult.Coins = Coins{"atom": big.NewInt(0), "x": big.NewInt(1)}
+	assert.EqualError(t, CheckInvariants(s), "violates noZeroCoinsWhenNoError, validDenomsWhenNoError")
+	s.OpResult.Error = true
+	assert.NoError(t, CheckInvariants(s))
+}
@@ -0,0 +1,50 @@
+package shapes
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+const state = `{
+  "owners": { "#map": [ [ { "#bigint": "7" }, "alice" ] ] },
+  "balances": { "#map": [ [ "alice", { "#bigint": "100" } ] ] },
+  "msgs": { "#set": [
+    { "tag": "Transfer", "value": { "from": "alice", "to": "bob", "amount": { "#bigint": "3" } } },
+    { "tag": "Burn", "value": { "#bigint": "5" } },
+    { "tag": "Halt", "value": { "#tup": [] } }
+  ] },
+  "pending": { "tag": "None", "value": { "#tup": [] } },
+  "pair": { "#tup": [ "x", { "#set": [ { "#bigint": "1" }, { "#bigint": "2" } ] } ] },
+  "log": [ { "step": { "#bigint": "0" }, "ok": true } ],
+  "token": "TOKEN_1",
+  "mbt::actionTaken": "send"
+}`
+
+func TestDecodeState(t *testing.T) {
+	s, err := DecodeState(gjson.Parse(state))
+	require.NoError(t, err)
+	assert.Equal(t, []OwnersEntry{{Key: big.NewInt(7), Value: "alice"}}, s.Owners)
+	assert.Equal(t, big.NewInt(100), s.Balances["alice"])
+	require.Len(t, s.Msgs, 3)
+	assert.Equal(t, &MsgTransfer{From: "alice", To: "bob", Amount: big.NewInt(3)}, s.Msgs[0].Transfer)
+	assert.Equal(t, big.NewInt(5), s.Msgs[1].Burn)
+	assert.Equal(t, Msg{Tag: "Halt"}, s.Msgs[2])
+	assert.Equal(t, OptionOfBigInt{Tag: "None"}, s.Pending)
+	assert.Equal(t, Pair{F0: "x", F1: []*big.Int{big.NewInt(1), big.NewInt(2)}}, s.Pair)
+	assert.Equal(t, []LogElem{{Step: big.NewInt(0), Ok: true}}, s.Log)
+	assert.Equal(t, "TOKEN_1", s.Token.String())
+	assert.Equal(t, "send", s.MbtActionTaken)
+
+	params, err := DecodeSendParams(gjson.Parse(`{ "msg": { "tag": "Burn", "value": 5 }, "nonce": { "#bigint": "1" } }`))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(5), params.Msg.Burn)
+
+	_, err = DecodeState(gjson.Parse(`{ "owners": { "#map": [] }, "balances": { "#map": [ [ 1, 2 ] ] } }`))
+	assert.EqualError(t, err, "balances: key: expected a string, found: 1")
+	_, err = DecodeSendParams(gjson.Parse(`{ "msg": { "tag": "Mint", "value": 1 } }`))
+	assert.ErrorContains(t, err, "msg: unexpected variant")
+}
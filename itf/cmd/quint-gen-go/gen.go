@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// generator emits the Go bindings of a module
+type generator struct {
+	// the typedefs of all modules by their names
+	typedefs map[string]*quintType
+	// the types of the expressions by their ids
+	types quintTypes
+	// the declarations of the Go types and of their decoders by their names
+	typeDecls map[string]string
+	funcDecls map[string]string
+	// the Go names of the struct types by the JSON of their Quint types
+	structs map[string]string
+	// the names of the types that are written by generate
+	reserved map[string]bool
+	// the Go types of the aliases by their names
+	aliases map[string]string
+	// whether the code refers to math/big and to the package itf
+	usesBig, usesItf bool
+}
+
+// a field of a generated struct
+type goField struct {
+	// the name in the spec, and the name in Go
+	name, goName string
+	goType       string
+	decoder      string
+	// whether a variant of a sum type is stored behind a pointer
+	pointer bool
+}
+
+// generate the bindings of the module main, or of the last module if main is
+// empty, in the package pkg, with the predicates of the invariants, which
+// are vals of the spec
+func generate(out quintOutput, main, pkg, source string, invariants []string) ([]byte, error) {
+	g := &generator{
+		typedefs:  make(map[string]*quintType),
+		types:     out.Types,
+		typeDecls: make(map[string]string),
+		funcDecls: make(map[string]string),
+		structs:   make(map[string]string),
+		reserved:  map[string]bool{"State": true},
+		aliases:   make(map[string]string),
+	}
+	defs := make(map[string]*quintDecl)
+	var module *quintModule
+	for i := range out.Modules {
+		m := &out.Modules[i]
+		for j, d := range m.Declarations {
+			switch d.Kind {
+			case "typedef":
+				g.typedefs[d.Name] = d.Type
+			case "def":
+				defs[d.Name] = &m.Declarations[j]
+			}
+		}
+		if m.Name == main || (main == "" && i == len(out.Modules)-1) {
+			module = m
+		}
+	}
+	if module == nil {
+		return nil, fmt.Errorf("no module %s", main)
+	}
+	if pkg == "" {
+		pkg = strings.ToLower(goName(module.Name))
+	}
+
+	var actions []quintDecl
+	for _, d := range module.Declarations {
+		if d.Kind == "def" && d.Qualifier == "action" && d.Expr != nil &&
+			d.Expr.Kind == "lambda" && len(d.Expr.Params) > 0 {
+			actions = append(actions, d)
+			g.reserved[goName(d.Name)+"Params"] = true
+		}
+	}
+	if len(invariants) > 0 {
+		for _, name := range append([]string{"Invariant", "Invariants", "CheckInvariants"}, invariants...) {
+			if g.reserved[goName(name)] {
+				return nil, fmt.Errorf("the name %s of invariant %s is taken", goName(name), name)
+			}
+			g.reserved[goName(name)] = true
+		}
+	}
+	var vars []goField
+	varTypes := make(map[string]*quintType)
+	for _, d := range module.Declarations {
+		if d.Kind == "var" {
+			goType, decoder := g.typeOf(d.TypeAnnotation, goName(d.Name))
+			vars = append(vars, goField{name: d.Name, goName: goName(d.Name), goType: goType, decoder: decoder})
+			varTypes[d.Name] = d.TypeAnnotation
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by quint-gen-go from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&b, "// Package %s decodes the states of the ITF traces of the Quint module %s.\n", pkg, module.Name)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	imports := []string{`"fmt"`}
+	var body bytes.Buffer
+	g.writeStruct(&body, "State", fmt.Sprintf("State is a state of the module %s.", module.Name), vars)
+	g.writeRecordDecoder(&body, "DecodeState", "State",
+		"DecodeState decodes a state of an ITF trace, e.g., trace.States[i].", vars)
+	g.usesItf = true
+	body.WriteString(`// DecodeTrace decodes all states of an ITF trace.
+func DecodeTrace(trace itf.Trace) ([]State, error) {
+	states := make([]State, len(trace.States))
+	for i, v := range trace.States {
+		s, err := DecodeState(v)
+		if err != nil {
+			return nil, fmt.Errorf("state %d: %v", i, err)
+		}
+		states[i] = s
+	}
+	return states, nil
+}
+
+`)
+	for _, d := range actions {
+		g.writeParams(&body, d)
+	}
+	if len(invariants) > 0 {
+		c := &compiler{g: g, vars: varTypes, defs: defs, inlining: make(map[string]bool)}
+		if err := c.writeInvariants(&body, invariants); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sortedKeys(g.typeDecls) {
+		body.WriteString(g.typeDecls[name])
+	}
+	for _, name := range sortedKeys(g.funcDecls) {
+		body.WriteString(g.funcDecls[name])
+	}
+	if g.usesBig {
+		imports = append(imports, `"math/big"`)
+	}
+	if len(invariants) > 0 {
+		imports = append(imports, `"strings"`)
+	}
+	imports = append(imports, "", `"github.com/tidwall/gjson"`)
+	if g.usesItf {
+		imports = append(imports, "", `"github.com/informalsystems/quint-sandbox/itf"`)
+	}
+	fmt.Fprintf(&b, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	b.Write(body.Bytes())
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return b.Bytes(), fmt.Errorf("the generated code does not compile: %v", err)
+	}
+	return src, nil
+}
+
+// write the parameters of an action, and their decoder
+func (g *generator) writeParams(b *bytes.Buffer, d quintDecl) {
+	var signature []*quintType
+	if d.TypeAnnotation != nil && d.TypeAnnotation.Kind == "oper" {
+		signature = d.TypeAnnotation.Args
+	}
+	name := goName(d.Name) + "Params"
+	var fields []goField
+	for i, p := range d.Expr.Params {
+		t := p.TypeAnnotation
+		if t == nil && i < len(signature) {
+			t = signature[i]
+		}
+		if t == nil {
+			t = g.types[p.ID]
+		}
+		goType, decoder := g.typeOf(t, name+goName(p.Name))
+		fields = append(fields, goField{name: p.Name, goName: goName(p.Name), goType: goType, decoder: decoder})
+	}
+	g.writeStruct(b, name, fmt.Sprintf("%s are the parameters of the action %s.", name, d.Name), fields)
+	g.writeRecordDecoder(b, "Decode"+name, name,
+		fmt.Sprintf("Decode%s decodes the parameters of the action %s from a record\n// of them, e.g., the last action that a state records.", name, d.Name),
+		fields)
+}
+
+// the Go type of a Quint type, and the name of its decoder, which is a
+// function from gjson.Result to the Go type and an error. The anonymous
+// records, tuples, and sum types are named after hint.
+func (g *generator) typeOf(t *quintType, hint string) (goType, decoder string) {
+	if t == nil {
+		return g.raw()
+	}
+	switch t.Kind {
+	case "bool":
+		g.helper("decodeBool", `func decodeBool(v gjson.Result) (bool, error) {
+	if !v.IsBool() {
+		return false, fmt.Errorf("expected a bool, found: %s", v.Raw)
+	}
+	return v.Bool(), nil
+}
+`)
+		return "bool", "decodeBool"
+	case "int":
+		g.usesBig, g.usesItf = true, true
+		return "*big.Int", "itf.BigInt"
+	case "str":
+		g.helper("decodeStr", `func decodeStr(v gjson.Result) (string, error) {
+	if v.Type != gjson.String {
+		return "", fmt.Errorf("expected a string, found: %s", v.Raw)
+	}
+	return v.Str, nil
+}
+`)
+		return "string", "decodeStr"
+	case "const":
+		return g.named(t.Name, g.typedefs[t.Name], nil)
+	case "app":
+		if t.Ctor == nil || t.Ctor.Kind != "const" {
+			return g.raw()
+		}
+		return g.named(t.Ctor.Name, g.typedefs[t.Ctor.Name], t.Args)
+	case "set", "list":
+		elem, elemDecoder := g.typeOf(t.Elem, hint+"Elem")
+		name := "decode" + goName(t.Kind) + "Of" + mnemonic(elemDecoder)
+		elems := "v.Array()"
+		if t.Kind == "set" {
+			g.usesItf = true
+			elems = "itf.Set(v)"
+		}
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "func %s(v gjson.Result) ([]%s, error) {\n", name, elem)
+		if t.Kind == "set" {
+			fmt.Fprintf(&b, "\telems, err := %s\n\tif err != nil {\n\t\treturn nil, err\n\t}\n", elems)
+		} else {
+			fmt.Fprintf(&b, "\tif !v.IsArray() {\n\t\treturn nil, fmt.Errorf(\"expected a list, found: %%s\", v.Raw)\n\t}\n\telems := %s\n\tvar err error\n", elems)
+		}
+		fmt.Fprintf(&b, `	%s := make([]%s, len(elems))
+	for i, e := range elems {
+		if %s[i], err = %s(e); err != nil {
+			return nil, fmt.Errorf("element %%d: %%v", i, err)
+		}
+	}
+	return %s, nil
+}
+`, t.Kind, elem, t.Kind, elemDecoder, t.Kind)
+		g.helper(name, b.String())
+		return "[]" + elem, name
+	case "fun":
+		return g.mapOf(t, hint)
+	case "tup", "rec", "sum":
+		if t.Kind == "tup" && len(t.Fields.all()) == 0 {
+			g.helper("decodeUnit", `func decodeUnit(v gjson.Result) (struct{}, error) {
+	if _, err := itf.Tuple(v); err != nil {
+		return struct{}{}, err
+	}
+	return struct{}{}, nil
+}
+`)
+			g.usesItf = true
+			return "struct{}", "decodeUnit"
+		}
+		return g.structOf(t, hint, "")
+	default:
+		// type variables and operators, which are not written to ITF
+		return g.raw()
+	}
+}
+
+// a value that is kept as JSON
+func (g *generator) raw() (string, string) {
+	g.helper("decodeRaw", `func decodeRaw(v gjson.Result) (gjson.Result, error) {
+	return v, nil
+}
+`)
+	return "gjson.Result", "decodeRaw"
+}
+
+// the Go type of a typedef, which is applied to args, if it has parameters
+func (g *generator) named(name string, def *quintType, args []*quintType) (string, string) {
+	if def == nil {
+		// an uninterpreted type, or a type of another spec
+		return g.raw()
+	}
+	goType := goName(name)
+	if def.Kind == "abs" {
+		if len(def.Vars) != len(args) || def.Body == nil {
+			return g.raw()
+		}
+		subst := make(map[string]*quintType)
+		for i, v := range def.Vars {
+			subst[v.Name] = args[i]
+			_, decoder := g.typeOf(args[i], goType+"Arg")
+			if i == 0 {
+				goType += "Of"
+			} else {
+				goType += "And"
+			}
+			goType += mnemonic(decoder)
+		}
+		def = substitute(def.Body, subst)
+	}
+	switch def.Kind {
+	case "rec", "tup", "sum":
+		if def.Kind != "tup" || len(def.Fields.all()) > 0 {
+			return g.structOf(def, goType, fmt.Sprintf("%s is the type %s of the spec.", goType, typeName(name, args)))
+		}
+	}
+	// an alias, e.g., type Coins = str -> int
+	alias, decoder := g.typeOf(def, goType)
+	if _, found := g.typeDecls[goType]; !found && alias != goType {
+		g.aliases[goType] = alias
+		g.typeDecls[goType] = fmt.Sprintf("// %s is the type %s of the spec.\ntype %s = %s\n\n", goType, typeName(name, args), goType, alias)
+	}
+	return goType, decoder
+}
+
+// a map, or a list of entries, if the keys cannot be keys of a Go map
+func (g *generator) mapOf(t *quintType, hint string) (string, string) {
+	key, keyDecoder := g.typeOf(t.Arg, hint+"Key")
+	value, valueDecoder := g.typeOf(t.Res, hint+"Value")
+	g.usesItf = true
+	name := "decodeMapOf" + mnemonic(keyDecoder) + "To" + mnemonic(valueDecoder)
+	if k := g.resolve(t.Arg); k != nil && (k.Kind == "str" || k.Kind == "bool") {
+		g.helper(name, fmt.Sprintf(`func %s(v gjson.Result) (map[%s]%s, error) {
+	entries, err := itf.Map(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[%s]%s, len(entries))
+	for _, e := range entries {
+		k, err := %s(e.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key: %%v", err)
+		}
+		if m[k], err = %s(e.Value); err != nil {
+			return nil, fmt.Errorf("%%v: %%v", k, err)
+		}
+	}
+	return m, nil
+}
+`, name, key, value, key, value, keyDecoder, valueDecoder))
+		return fmt.Sprintf("map[%s]%s", key, value), name
+	}
+	// e.g., int -> str, whose keys are *big.Int
+	entry := g.unique(hint+"Entry", "entry:"+key+":"+value)
+	if _, found := g.typeDecls[entry]; !found {
+		g.typeDecls[entry] = fmt.Sprintf("// %s is an entry of a map, whose keys are not comparable in Go.\ntype %s struct {\n\tKey   %s\n\tValue %s\n}\n\n",
+			entry, entry, key, value)
+	}
+	g.helper(name, fmt.Sprintf(`func %s(v gjson.Result) ([]%s, error) {
+	entries, err := itf.Map(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make([]%s, len(entries))
+	for i, e := range entries {
+		if m[i].Key, err = %s(e.Key); err != nil {
+			return nil, fmt.Errorf("key %%d: %%v", i, err)
+		}
+		if m[i].Value, err = %s(e.Value); err != nil {
+			return nil, fmt.Errorf("value %%d: %%v", i, err)
+		}
+	}
+	return m, nil
+}
+`, name, entry, entry, keyDecoder, valueDecoder))
+	return "[]" + entry, name
+}
+
+// a struct of a record, a tuple, or a sum type, which is named after a
+// typedef, if it has the doc comment of one, or after hint
+func (g *generator) structOf(t *quintType, hint, doc string) (string, string) {
+	key := "typedef " + hint
+	if doc == "" {
+		anonymous, _ := json.Marshal(t)
+		key = string(anonymous)
+	}
+	name := g.unique(hint, key)
+	decoder := "decode" + name
+	if _, found := g.typeDecls[name]; found {
+		return name, decoder
+	}
+	// reserve the name before the fields, which may refer to other structs
+	g.typeDecls[name] = ""
+	var fields []goField
+	for i, f := range t.Fields.all() {
+		fieldName := goName(f.FieldName)
+		if t.Kind == "tup" {
+			fieldName = fmt.Sprintf("F%d", i)
+		}
+		if t.Kind == "sum" && isUnit(f.FieldType) {
+			fields = append(fields, goField{name: f.FieldName, goName: fieldName})
+			continue
+		}
+		goType, fieldDecoder := g.typeOf(f.FieldType, name+fieldName)
+		fields = append(fields, goField{
+			name: f.FieldName, goName: fieldName, goType: goType, decoder: fieldDecoder,
+			pointer: !g.nilable(goType),
+		})
+	}
+	var b bytes.Buffer
+	switch t.Kind {
+	case "rec":
+		if doc == "" {
+			doc = fmt.Sprintf("%s is a record of the spec.", name)
+		}
+		g.writeStruct(&b, name, doc, fields)
+		g.typeDecls[name] = b.String()
+		b.Reset()
+		g.writeRecordDecoder(&b, decoder, name, "", fields)
+	case "tup":
+		if doc == "" {
+			doc = fmt.Sprintf("%s is a tuple of the spec.", name)
+		}
+		g.writeStruct(&b, name, doc, fields)
+		g.typeDecls[name] = b.String()
+		b.Reset()
+		g.writeTupleDecoder(&b, decoder, name, fields)
+	case "sum":
+		if doc == "" {
+			doc = fmt.Sprintf("%s is a sum type of the spec.", name)
+		}
+		g.writeSum(&b, name, doc, fields)
+		g.typeDecls[name] = b.String()
+		b.Reset()
+		g.writeSumDecoder(&b, decoder, name, fields)
+	}
+	g.funcDecls[decoder] = b.String()
+	return name, decoder
+}
+
+func (g *generator) writeStruct(b *bytes.Buffer, name, doc string, fields []goField) {
+	fmt.Fprintf(b, "// %s\ntype %s struct {\n", doc, name)
+	for _, f := range fields {
+		fmt.Fprintf(b, "\t%s %s\n", f.goName, f.goType)
+	}
+	b.WriteString("}\n\n")
+}
+
+func (g *generator) writeRecordDecoder(b *bytes.Buffer, decoder, name, doc string, fields []goField) {
+	if doc != "" {
+		fmt.Fprintf(b, "// %s\n", doc)
+	}
+	fmt.Fprintf(b, "func %s(v gjson.Result) (%s, error) {\n\tvar r %s\n\tvar err error\n", decoder, name, name)
+	for _, f := range fields {
+		fmt.Fprintf(b, "\tif r.%s, err = %s(v.Get(%q)); err != nil {\n\t\treturn r, fmt.Errorf(\"%s: %%v\", err)\n\t}\n",
+			f.goName, f.decoder, f.name, f.name)
+	}
+	b.WriteString("\treturn r, nil\n}\n\n")
+}
+
+func (g *generator) writeTupleDecoder(b *bytes.Buffer, decoder, name string, fields []goField) {
+	g.usesItf = true
+	fmt.Fprintf(b, `func %s(v gjson.Result) (%s, error) {
+	var t %s
+	elems, err := itf.Tuple(v)
+	if err != nil {
+		return t, err
+	}
+	if len(elems) != %d {
+		return t, fmt.Errorf("expected a tuple of %d elements, found: %%s", v.Raw)
+	}
+`, decoder, name, name, len(fields), len(fields))
+	for i, f := range fields {
+		fmt.Fprintf(b, "\tif t.%s, err = %s(elems[%d]); err != nil {\n\t\treturn t, fmt.Errorf(\"element %d: %%v\", err)\n\t}\n",
+			f.goName, f.decoder, i, i)
+	}
+	b.WriteString("\treturn t, nil\n}\n\n")
+}
+
+// a sum type is a struct of its tag, and a pointer to the value of every
+// variant that carries a value
+func (g *generator) writeSum(b *bytes.Buffer, name, doc string, variants []goField) {
+	fmt.Fprintf(b, "// %s\n// Its tag is the name of the variant, and the value is set, if the variant\n// carries one.\ntype %s struct {\n\tTag string\n", doc, name)
+	for _, v := range variants {
+		switch {
+		case v.pointer:
+			fmt.Fprintf(b, "\t%s *%s\n", v.goName, v.goType)
+		case v.decoder != "":
+			fmt.Fprintf(b, "\t%s %s\n", v.goName, v.goType)
+		}
+	}
+	b.WriteString("}\n\n")
+}
+
+func (g *generator) writeSumDecoder(b *bytes.Buffer, decoder, name string, variants []goField) {
+	fmt.Fprintf(b, "func %s(v gjson.Result) (%s, error) {\n\ts := %s{Tag: v.Get(\"tag\").String()}\n\tswitch s.Tag {\n", decoder, name, name)
+	for _, v := range variants {
+		fmt.Fprintf(b, "\tcase %q:\n", v.name)
+		if v.decoder != "" {
+			ref := ""
+			if v.pointer {
+				ref = "&"
+			}
+			fmt.Fprintf(b, "\t\tvalue, err := %s(v.Get(\"value\"))\n\t\tif err != nil {\n\t\t\treturn s, fmt.Errorf(\"%s: %%v\", err)\n\t\t}\n\t\ts.%s = %svalue\n",
+				v.decoder, v.name, v.goName, ref)
+		}
+	}
+	b.WriteString("\tdefault:\n\t\treturn s, fmt.Errorf(\"unexpected variant, found: %s\", v.Raw)\n\t}\n\treturn s, nil\n}\n\n")
+}
+
+// add a helper function, unless it was added before
+func (g *generator) helper(name, code string) {
+	if _, found := g.funcDecls[name]; !found {
+		g.funcDecls[name] = code + "\n"
+	}
+}
+
+// a name for the Go type of a Quint type, which is not taken by another type
+func (g *generator) unique(name, key string) string {
+	if taken, found := g.structs[key]; found {
+		return taken
+	}
+	unique := name
+	for i := 2; g.taken(unique); i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	g.structs[key] = unique
+	return unique
+}
+
+// whether a name is taken by a Go type
+func (g *generator) taken(name string) bool {
+	_, found := g.typeDecls[name]
+	return found || g.reserved[name]
+}
+
+// the type that an alias stands for
+func (g *generator) resolve(t *quintType) *quintType {
+	for i := 0; t != nil && t.Kind == "const" && i < 100; i++ {
+		t = g.typedefs[t.Name]
+	}
+	return t
+}
+
+// replace the type variables in a type
+func substitute(t *quintType, subst map[string]*quintType) *quintType {
+	if t == nil {
+		return nil
+	}
+	if t.Kind == "var" {
+		if s, found := subst[t.Name]; found {
+			return s
+		}
+		return t
+	}
+	c := *t
+	c.Elem = substitute(t.Elem, subst)
+	c.Arg = substitute(t.Arg, subst)
+	c.Res = substitute(t.Res, subst)
+	c.Args = make([]*quintType, len(t.Args))
+	for i, a := range t.Args {
+		c.Args[i] = substitute(a, subst)
+	}
+	if t.Fields != nil {
+		row := &quintRow{Kind: "row"}
+		for _, f := range t.Fields.all() {
+			row.Fields = append(row.Fields, quintField{f.FieldName, substitute(f.FieldType, subst)})
+		}
+		row.Other = &quintRow{Kind: "empty"}
+		c.Fields = row
+	}
+	return &c
+}
+
+// whether a Go type has nil, so it does not need a pointer to be optional
+func (g *generator) nilable(goType string) bool {
+	for i := 0; i < 100; i++ {
+		alias, found := g.aliases[goType]
+		if !found {
+			break
+		}
+		goType = alias
+	}
+	return goType == "gjson.Result" || strings.HasPrefix(goType, "*") ||
+		strings.HasPrefix(goType, "[]") || strings.HasPrefix(goType, "map[")
+}
+
+// whether a type is the unit type, which the variants without a value carry
+func isUnit(t *quintType) bool {
+	return t != nil && t.Kind == "tup" && len(t.Fields.all()) == 0
+}
+
+// the name of a type in the spec, e.g., Option[int]
+func typeName(name string, args []*quintType) string {
+	if len(args) == 0 {
+		return name
+	}
+	var names []string
+	for _, a := range args {
+		if a.Kind == "const" {
+			names = append(names, a.Name)
+		} else {
+			names = append(names, a.Kind)
+		}
+	}
+	return name + "[" + strings.Join(names, ", ") + "]"
+}
+
+// an exported Go name of a Quint name, e.g., LastAction for lastAction, or
+// MbtActionTaken for mbt::actionTaken
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			upper = true
+		case upper:
+			if b.Len() == 0 && unicode.IsDigit(r) {
+				b.WriteString("X")
+			}
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// the part of a decoder name that names its type, e.g., BigInt for itf.BigInt
+func mnemonic(decoder string) string {
+	decoder = strings.TrimPrefix(decoder, "itf.")
+	return strings.TrimPrefix(decoder, "decode")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
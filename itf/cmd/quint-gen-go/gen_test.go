@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:generate go run . -invariants noZeroCoinsWhenNoError,validDenomsWhenNoError -o example/coinstest/coinstest.go testdata/coinsTest.json
+//go:generate go run . -o example/shapes/shapes.go testdata/shapes.json
+
+// the examples are compiled and tested as packages of their own, and they
+// have to be in sync with the generator
+func TestGenerate(t *testing.T) {
+	for _, example := range []struct {
+		input, output string
+		invariants    []string
+	}{
+		{"testdata/coinsTest.json", "example/coinstest/coinstest.go", []string{"noZeroCoinsWhenNoError", "validDenomsWhenNoError"}},
+		{"testdata/shapes.json", "example/shapes/shapes.go", nil},
+	} {
+		out, err := readQuint(example.input)
+		require.NoError(t, err)
+		src, err := generate(out, "", "", example.input[len("testdata/"):], example.invariants)
+		require.NoError(t, err, example.input)
+		expected, err := os.ReadFile(example.output)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(src), "%s is out of date, run go generate", example.output)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	out, err := readQuint("testdata/shapes.json")
+	require.NoError(t, err)
+	_, err = generate(out, "missing", "", "shapes.json", nil)
+	assert.ErrorContains(t, err, "no module missing")
+
+	_, err = readQuint("testdata/missing.json")
+	assert.ErrorContains(t, err, "error opening file")
+}
+
+func TestGoName(t *testing.T) {
+	assert.Equal(t, "LastAction", goName("lastAction"))
+	assert.Equal(t, "MbtActionTaken", goName("mbt::actionTaken"))
+	assert.Equal(t, "MAXBITLEN", goName("MAX_BIT_LEN"))
+	assert.Equal(t, "X0", goName("0"))
+}
+
+func TestCompileInvariants(t *testing.T) {
+	out, err := readQuint("testdata/coinsTest.json")
+	require.NoError(t, err)
+	_, err = generate(out, "", "", "coinsTest.json", []string{"opcode"})
+	assert.EqualError(t, err, "invariant opcode: expected a val of the spec")
+	_, err = generate(out, "", "", "coinsTest.json", []string{"isValidDenom"})
+	assert.EqualError(t, err, "invariant isValidDenom: expected a val of the spec")
+	_, err = generate(out, "", "", "coinsTest.json", []string{"MAX_BIT_LEN"})
+	assert.EqualError(t, err, "invariant MAX_BIT_LEN: expected a bool, found int")
+
+	// the operators that are not supported are named
+	expr := func(src string) *quintExpr {
+		var e quintExpr
+		require.NoError(t, json.Unmarshal([]byte(src), &e))
+		return &e
+	}
+	out.Modules[1].Declarations = append(out.Modules[1].Declarations,
+		quintDecl{Kind: "def", Qualifier: "val", Name: "folded", Expr: expr(`{"kind": "app", "opcode": "fold",
+			"args": [{"kind": "name", "name": "opSlice"}, {"kind": "int", "value": 0}]}`)},
+		quintDecl{Kind: "def", Qualifier: "val", Name: "mixed", Expr: expr(`{"kind": "app", "opcode": "eq",
+			"args": [{"kind": "name", "name": "opcode"}, {"kind": "int", "value": 1}]}`)},
+		quintDecl{Kind: "def", Qualifier: "val", Name: "loop", Expr: expr(`{"kind": "name", "name": "loop"}`)})
+	_, err = generate(out, "", "", "coinsTest.json", []string{"folded"})
+	assert.EqualError(t, err, "invariant folded: the operator fold is not supported")
+	_, err = generate(out, "", "", "coinsTest.json", []string{"mixed"})
+	assert.EqualError(t, err, "invariant mixed: eq: expected the same types, found str and int")
+	_, err = generate(out, "", "", "coinsTest.json", []string{"loop"})
+	assert.EqualError(t, err, "invariant loop: the recursive definition loop is not supported")
+}
@@ -0,0 +1,87 @@
+// A command that generates typed Go bindings of the states of a Quint spec, so
+// a harness decodes the states of its ITF traces without writing the access to
+// every field by hand. It reads the output of quint parse, or of quint
+// typecheck, and writes a struct of the state variables of a module, a struct
+// of the parameters of every action, and their decoders from ITF JSON, e.g.:
+//
+//	quint typecheck --out=/tmp/coinsTest.json coinsTest.qnt
+//	go run ./cmd/quint-gen-go -o coinstest/coinstest.go /tmp/coinsTest.json
+//
+// and then, in the harness:
+//
+//	trace, err := itf.ReadFile("oneRandom.itf.json")
+//	states, err := coinstest.DecodeTrace(trace)
+//	fmt.Println(states[1].OpResult.Coins["atom"])
+//
+// The types of Quint are mapped to Go as follows:
+//
+//	bool, int, str         bool, *big.Int, string
+//	Set[a], List[a]        []a
+//	a -> b                 map[a]b, if a is str or bool, or a slice of entries
+//	records, tuples        structs, with the fields F0, F1, ... for tuples
+//	sum types              a struct of the tag and of a pointer to every value
+//	type T = ...           a named struct, or an alias, e.g., type Coins = map[string]*big.Int
+//	operators, type vars   gjson.Result
+//
+// The types of the parameters of the actions are read from their signatures,
+// or, if they are not written, from the types that quint typecheck inferred.
+//
+// With -invariants, the vals of the spec that are invariants are compiled into
+// predicates over State, which a harness evaluates after every step, e.g.:
+//
+//	go run ./cmd/quint-gen-go -invariants noZeroCoinsWhenNoError -o coinstest/coinstest.go /tmp/coinsTest.json
+//
+// and then, for every state s of the system under test:
+//
+//	if err := coinstest.CheckInvariants(s); err != nil { ... }
+//
+// Only simple invariants are compiled, see monitor.go, and the others are
+// rejected with the operator that is not supported.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	module := flag.String("module", "", "the module to generate the bindings of, by default the last one")
+	pkg := flag.String("package", "", "the name of the Go package, by default the name of the module in lower case")
+	output := flag.String("o", "", "write the bindings to a file instead of the standard output")
+	invariants := flag.String("invariants", "", "the comma-separated vals of the spec to compile into predicates over the states")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] spec.json\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := flag.Arg(0)
+	out, err := readQuint(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %s: %v\n", input, err)
+		os.Exit(1)
+	}
+	var invs []string
+	if *invariants != "" {
+		invs = strings.Split(*invariants, ",")
+	}
+	src, err := generate(out, *module, *pkg, filepath.Base(input), invs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error generating the bindings of %s: %v\n", input, err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+}
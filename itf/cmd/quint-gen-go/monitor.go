@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// The invariants of a spec are compiled into predicates over State, which a
+// harness evaluates after every step, so it checks the invariants on the
+// states of the system under test, and not only on the traces that quint
+// verified. Only simple invariants are compiled: the boolean and integer
+// operators, the comparisons, the fields of records and tuples, the keys of
+// maps, contains, size, forall, and exists over sets, lists, and keys, and the
+// vals and the operators of the spec, which are inlined. The other invariants
+// are rejected with the operator that is not supported.
+
+// a compiled expression: its Go code and its type in Quint
+type compiled struct {
+	code string
+	t    *quintType
+	// the type of the map in code, if the expression is the set of its keys
+	keysOf *quintType
+}
+
+// compiler compiles the expressions of a module into Go expressions over
+// the variable s of the type State
+type compiler struct {
+	g *generator
+	// the types of the state variables by their names
+	vars map[string]*quintType
+	// the definitions of all modules by their names
+	defs map[string]*quintDecl
+	// the bindings of the parameters of lambdas and inlined operators,
+	// the innermost last
+	scopes []map[string]compiled
+	// the definitions that are being inlined, to reject recursion
+	inlining map[string]bool
+}
+
+var (
+	boolType = &quintType{Kind: "bool"}
+	intType  = &quintType{Kind: "int"}
+	strType  = &quintType{Kind: "str"}
+)
+
+// write the predicates of the invariants, and Invariants and CheckInvariants
+func (c *compiler) writeInvariants(b *bytes.Buffer, names []string) error {
+	var checks []string
+	for _, name := range names {
+		d, found := c.defs[name]
+		if !found || d.Kind != "def" || d.Expr == nil || d.Expr.Kind == "lambda" {
+			return fmt.Errorf("invariant %s: expected a val of the spec", name)
+		}
+		e, err := c.compile(d.Expr)
+		if err != nil {
+			return fmt.Errorf("invariant %s: %v", name, err)
+		}
+		if c.kind(e.t) != "bool" {
+			return fmt.Errorf("invariant %s: expected a bool, found %s", name, c.kind(e.t))
+		}
+		fmt.Fprintf(b, "// %s is the invariant %s of the spec.\nfunc %s(s State) bool {\n\treturn %s\n}\n\n",
+			goName(name), name, goName(name), e.code)
+		checks = append(checks, fmt.Sprintf("\t{%q, %s},\n", name, goName(name)))
+	}
+	fmt.Fprintf(b, `// Invariant is an invariant of the spec, which is compiled into a predicate
+// over the states.
+type Invariant struct {
+	Name  string
+	Check func(State) bool
+}
+
+// Invariants are the invariants of the spec, which a harness checks after
+// every step, see CheckInvariants.
+var Invariants = []Invariant{
+%s}
+
+// CheckInvariants returns an error that names the invariants, which a state
+// violates, or nil, if it satisfies all of them.
+func CheckInvariants(s State) error {
+	var violated []string
+	for _, inv := range Invariants {
+		if !inv.Check(s) {
+			violated = append(violated, inv.Name)
+		}
+	}
+	if len(violated) > 0 {
+		return fmt.Errorf("violates %%s", strings.Join(violated, ", "))
+	}
+	return nil
+}
+
+`, strings.Join(checks, ""))
+	return nil
+}
+
+// the kind of a type, after its aliases are resolved
+func (c *compiler) kind(t *quintType) string {
+	if t = c.g.resolve(t); t == nil {
+		return "unknown"
+	}
+	return t.Kind
+}
+
+func (c *compiler) compile(e *quintExpr) (compiled, error) {
+	switch e.Kind {
+	case "bool":
+		return compiled{code: string(e.Value), t: boolType}, nil
+	case "int":
+		digits := strings.Trim(string(e.Value), `"`)
+		i, ok := new(big.Int).SetString(digits, 10)
+		if !ok {
+			return compiled{}, fmt.Errorf("expected an integer, found %s", e.Value)
+		}
+		c.g.usesBig = true
+		if i.IsInt64() {
+			return compiled{code: fmt.Sprintf("big.NewInt(%s)", i), t: intType}, nil
+		}
+		c.g.helper("bigIntOf", `func bigIntOf(digits string) *big.Int {
+	i, _ := new(big.Int).SetString(digits, 10)
+	return i
+}
+`)
+		return compiled{code: fmt.Sprintf("bigIntOf(%q)", i), t: intType}, nil
+	case "str":
+		var s string
+		if err := json.Unmarshal(e.Value, &s); err != nil {
+			return compiled{}, fmt.Errorf("expected a string, found %s", e.Value)
+		}
+		return compiled{code: strconv.Quote(s), t: strType}, nil
+	case "name":
+		return c.name(e.Name)
+	case "app":
+		return c.app(e)
+	}
+	return compiled{}, fmt.Errorf("the expressions of the kind %s are not supported", e.Kind)
+}
+
+// a parameter, a state variable, or a val of the spec, which is inlined
+func (c *compiler) name(name string) (compiled, error) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if b, found := c.scopes[i][name]; found {
+			return b, nil
+		}
+	}
+	if t, found := c.vars[name]; found {
+		return compiled{code: "s." + goName(name), t: t}, nil
+	}
+	if d, found := c.defs[name]; found && d.Kind == "def" && d.Expr != nil && d.Expr.Kind != "lambda" {
+		return c.inline(d, nil)
+	}
+	return compiled{}, fmt.Errorf("the name %s is not supported", name)
+}
+
+// the body of a definition, whose parameters are bound to args
+func (c *compiler) inline(d *quintDecl, args []compiled) (compiled, error) {
+	if c.inlining[d.Name] {
+		return compiled{}, fmt.Errorf("the recursive definition %s is not supported", d.Name)
+	}
+	c.inlining[d.Name] = true
+	defer delete(c.inlining, d.Name)
+	body := d.Expr
+	scope := make(map[string]compiled)
+	if body.Kind == "lambda" {
+		if len(body.Params) != len(args) {
+			return compiled{}, fmt.Errorf("%s: expected %d arguments, found %d", d.Name, len(body.Params), len(args))
+		}
+		for i, p := range body.Params {
+			scope[p.Name] = args[i]
+		}
+		body = body.Expr
+	}
+	// the definition does not see the parameters of its callers
+	saved := c.scopes
+	c.scopes = []map[string]compiled{scope}
+	defer func() { c.scopes = saved }()
+	return c.compile(body)
+}
+
+func (c *compiler) app(e *quintExpr) (compiled, error) {
+	args := make([]compiled, 0, len(e.Args))
+	var lambda *quintExpr
+	for _, a := range e.Args {
+		if a.Kind == "lambda" {
+			lambda = a
+			continue
+		}
+		arg, err := c.compile(a)
+		if err != nil {
+			return compiled{}, err
+		}
+		args = append(args, arg)
+	}
+	switch e.Opcode {
+	case "forall", "exists":
+		if lambda == nil || len(args) != 1 || len(lambda.Params) != 1 {
+			return compiled{}, fmt.Errorf("%s: expected a set and a lambda of one parameter", e.Opcode)
+		}
+		return c.quantifier(e.Opcode, args[0], lambda)
+	}
+	if lambda != nil {
+		return compiled{}, fmt.Errorf("the operator %s over a lambda is not supported", e.Opcode)
+	}
+	if err := c.expectArgs(e.Opcode, args); err != nil {
+		return compiled{}, err
+	}
+	switch e.Opcode {
+	case "and", "or":
+		if len(args) == 0 {
+			return compiled{code: strconv.FormatBool(e.Opcode == "and"), t: boolType}, nil
+		}
+		sep := " && "
+		if e.Opcode == "or" {
+			sep = " || "
+		}
+		codes := make([]string, len(args))
+		for i, a := range args {
+			codes[i] = a.code
+		}
+		return compiled{code: "(" + strings.Join(codes, sep) + ")", t: boolType}, nil
+	case "not":
+		return compiled{code: not(args[0].code), t: boolType}, nil
+	case "implies":
+		return compiled{code: fmt.Sprintf("(%s || %s)", not(args[0].code), args[1].code), t: boolType}, nil
+	case "iff":
+		return compiled{code: fmt.Sprintf("(%s == %s)", args[0].code, args[1].code), t: boolType}, nil
+	case "eq", "neq":
+		eq, err := c.equal(args[0], args[1])
+		if err != nil {
+			return compiled{}, err
+		}
+		if e.Opcode == "neq" {
+			eq = not(eq)
+		}
+		return compiled{code: eq, t: boolType}, nil
+	case "ilt", "ilte", "igt", "igte":
+		op := map[string]string{"ilt": "<", "ilte": "<=", "igt": ">", "igte": ">="}[e.Opcode]
+		return compiled{code: fmt.Sprintf("(%s.Cmp(%s) %s 0)", args[0].code, args[1].code, op), t: boolType}, nil
+	case "iadd", "isub", "imul", "idiv", "imod", "ipow":
+		// Quint divides as JavaScript does, truncating towards zero
+		method := map[string]string{"iadd": "Add", "isub": "Sub", "imul": "Mul", "idiv": "Quo", "imod": "Rem", "ipow": "Exp"}[e.Opcode]
+		extra := ""
+		if e.Opcode == "ipow" {
+			extra = ", nil"
+		}
+		return compiled{code: fmt.Sprintf("new(big.Int).%s(%s, %s%s)", method, args[0].code, args[1].code, extra), t: intType}, nil
+	case "iuminus":
+		return compiled{code: fmt.Sprintf("new(big.Int).Neg(%s)", args[0].code), t: intType}, nil
+	case "field":
+		return c.field(args[0], e.Args[1])
+	case "item":
+		return c.item(args[0], e.Args[1])
+	case "size", "length":
+		return compiled{code: fmt.Sprintf("big.NewInt(int64(len(%s)))", args[0].code), t: intType}, nil
+	case "keys":
+		t := c.g.resolve(args[0].t)
+		return compiled{code: args[0].code, t: &quintType{Kind: "set", Elem: t.Arg}, keysOf: args[0].t}, nil
+	case "get":
+		if !c.isGoMap(args[0].t) {
+			return compiled{}, fmt.Errorf("get: the maps, whose keys are not strings or bools, are not supported")
+		}
+		return compiled{code: fmt.Sprintf("%s[%s]", args[0].code, args[1].code), t: c.g.resolve(args[0].t).Res}, nil
+	case "contains", "in":
+		set, elem := args[0], args[1]
+		if e.Opcode == "in" {
+			set, elem = args[1], args[0]
+		}
+		return c.contains(set, elem)
+	case "Set", "List":
+		return c.literal(e.Opcode, args)
+	}
+	if d, found := c.defs[e.Opcode]; found && d.Kind == "def" && d.Expr != nil && d.Expr.Kind == "lambda" {
+		return c.inline(d, args)
+	}
+	return compiled{}, fmt.Errorf("the operator %s is not supported", e.Opcode)
+}
+
+// the kinds of the arguments of the built-in operators
+var argKinds = map[string][]string{
+	"not": {"bool"}, "implies": {"bool", "bool"}, "iff": {"bool", "bool"},
+	"ilt": {"int", "int"}, "ilte": {"int", "int"}, "igt": {"int", "int"}, "igte": {"int", "int"},
+	"iadd": {"int", "int"}, "isub": {"int", "int"}, "imul": {"int", "int"}, "idiv": {"int", "int"},
+	"imod": {"int", "int"}, "ipow": {"int", "int"}, "iuminus": {"int"},
+	"eq": {"", ""}, "neq": {"", ""}, "field": {"rec", "str"}, "item": {"tup", "int"},
+	"size": {"set"}, "length": {"list"}, "keys": {"fun"}, "get": {"fun", ""},
+	"contains": {"set", ""}, "in": {"", "set"},
+}
+
+// check the number and the kinds of the arguments of a built-in operator
+func (c *compiler) expectArgs(opcode string, args []compiled) error {
+	if opcode == "and" || opcode == "or" {
+		for _, a := range args {
+			if k := c.kind(a.t); k != "bool" {
+				return fmt.Errorf("%s: expected bool arguments, found %s", opcode, k)
+			}
+		}
+		return nil
+	}
+	kinds, found := argKinds[opcode]
+	if !found {
+		return nil
+	}
+	if len(args) != len(kinds) {
+		return fmt.Errorf("%s: expected %d arguments, found %d", opcode, len(kinds), len(args))
+	}
+	for i, k := range kinds {
+		if k != "" && c.kind(args[i].t) != k {
+			return fmt.Errorf("%s: expected %s as argument %d, found %s", opcode, k, i+1, c.kind(args[i].t))
+		}
+	}
+	return nil
+}
+
+// the comparison of two values of bool, int, or str
+func (c *compiler) equal(x, y compiled) (string, error) {
+	kx, ky := c.kind(x.t), c.kind(y.t)
+	switch {
+	case kx != ky:
+		return "", fmt.Errorf("eq: expected the same types, found %s and %s", kx, ky)
+	case kx == "int":
+		return fmt.Sprintf("(%s.Cmp(%s) == 0)", x.code, y.code), nil
+	case kx == "bool" || kx == "str":
+		return fmt.Sprintf("(%s == %s)", x.code, y.code), nil
+	}
+	return "", fmt.Errorf("eq: the equality of %s is not supported", kx)
+}
+
+// the field of a record, which is a field of the struct that typeOf generates
+func (c *compiler) field(rec compiled, name *quintExpr) (compiled, error) {
+	var fieldName string
+	if name.Kind != "str" || json.Unmarshal(name.Value, &fieldName) != nil {
+		return compiled{}, fmt.Errorf("field: expected the name of a field")
+	}
+	for _, f := range c.g.resolve(rec.t).Fields.all() {
+		if f.FieldName == fieldName {
+			return compiled{code: rec.code + "." + goName(fieldName), t: f.FieldType}, nil
+		}
+	}
+	return compiled{}, fmt.Errorf("field: no field %s", fieldName)
+}
+
+// the element of a tuple, which Quint counts from 1, e.g., t._1
+func (c *compiler) item(tup compiled, index *quintExpr) (compiled, error) {
+	fields := c.g.resolve(tup.t).Fields.all()
+	i, err := strconv.Atoi(string(index.Value))
+	if index.Kind != "int" || err != nil || i < 1 || i > len(fields) {
+		return compiled{}, fmt.Errorf("item: expected an index in 1..%d", len(fields))
+	}
+	return compiled{code: fmt.Sprintf("%s.F%d", tup.code, i-1), t: fields[i-1].FieldType}, nil
+}
+
+// whether a map is a Go map, and not a slice of entries, see mapOf
+func (c *compiler) isGoMap(t *quintType) bool {
+	k := c.kind(c.g.resolve(t).Arg)
+	return k == "str" || k == "bool"
+}
+
+// a loop over the elements of a set, a list, or the keys of a map, in which
+// the element is bound to v, and the body is run
+func (c *compiler) rangeOver(set compiled, v, body string) string {
+	switch {
+	case set.keysOf != nil && c.isGoMap(set.keysOf):
+		return fmt.Sprintf("for %s := range %s {\n%s}\n", v, set.code, body)
+	case set.keysOf != nil:
+		return fmt.Sprintf("for _, entry := range %s {\n%s := entry.Key\n%s}\n", set.code, v, body)
+	}
+	return fmt.Sprintf("for _, %s := range %s {\n%s}\n", v, set.code, body)
+}
+
+// forall and exists, which are compiled into loops
+func (c *compiler) quantifier(opcode string, set compiled, lambda *quintExpr) (compiled, error) {
+	if k := c.kind(set.t); k != "set" && k != "list" {
+		return compiled{}, fmt.Errorf("%s: expected a set, found %s", opcode, k)
+	}
+	param := lambda.Params[0].Name
+	v := local(param)
+	c.scopes = append(c.scopes, map[string]compiled{param: {code: v, t: c.g.resolve(set.t).Elem}})
+	body, err := c.compile(lambda.Expr)
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	if err != nil {
+		return compiled{}, err
+	}
+	if k := c.kind(body.t); k != "bool" {
+		return compiled{}, fmt.Errorf("%s: expected a bool, found %s", opcode, k)
+	}
+	// forall returns false on the first element that does not satisfy the
+	// body, and exists returns true on the first one that does
+	cond, found := not(body.code), "false"
+	if opcode == "exists" {
+		cond, found = body.code, "true"
+	}
+	loop := c.rangeOver(set, v, fmt.Sprintf("if %s {\nreturn %s\n}\n", cond, found))
+	return compiled{
+		code: fmt.Sprintf("func() bool {\n%sreturn %s\n}()", loop, strconv.FormatBool(found != "true")),
+		t:    boolType,
+	}, nil
+}
+
+// whether a set, a list, or the keys of a map contain an element
+func (c *compiler) contains(set compiled, elem compiled) (compiled, error) {
+	if set.keysOf != nil && c.isGoMap(set.keysOf) {
+		return compiled{code: fmt.Sprintf("func() bool {\n_, found := %s[%s]\nreturn found\n}()", set.code, elem.code), t: boolType}, nil
+	}
+	eq, err := c.equal(compiled{code: "e", t: c.g.resolve(set.t).Elem}, elem)
+	if err != nil {
+		return compiled{}, fmt.Errorf("contains: %v", err)
+	}
+	loop := c.rangeOver(set, "e", fmt.Sprintf("if %s {\nreturn true\n}\n", eq))
+	return compiled{code: fmt.Sprintf("func() bool {\n%sreturn false\n}()", loop), t: boolType}, nil
+}
+
+// a set or a list of bools, ints, or strings, e.g., Set("atom", "osmo")
+func (c *compiler) literal(opcode string, elems []compiled) (compiled, error) {
+	if len(elems) == 0 {
+		return compiled{}, fmt.Errorf("%s: the empty literals are not supported", opcode)
+	}
+	t := elems[0].t
+	goType := map[string]string{"bool": "bool", "int": "*big.Int", "str": "string"}[c.kind(t)]
+	codes := make([]string, len(elems))
+	for i, e := range elems {
+		if c.kind(e.t) != c.kind(t) || goType == "" {
+			return compiled{}, fmt.Errorf("%s: expected the elements of bool, int, or str of the same type", opcode)
+		}
+		codes[i] = e.code
+	}
+	kind := "set"
+	if opcode == "List" {
+		kind = "list"
+	}
+	return compiled{code: fmt.Sprintf("[]%s{%s}", goType, strings.Join(codes, ", ")), t: &quintType{Kind: kind, Elem: t}}, nil
+}
+
+// the Go name of a parameter, which does not hide the state s or the
+// identifiers of the generated code
+func local(name string) string {
+	switch name {
+	case "s", "e", "entry", "big", "new", "len", "int64", "true", "false", "nil":
+		return name + "_"
+	}
+	if !token.IsIdentifier(name) {
+		return "x" + goName(name)
+	}
+	return name
+}
+
+// the negation of a boolean expression, e.g., x for !x
+func not(code string) string {
+	if strings.HasPrefix(code, "!") && !strings.HasPrefix(code, "!(") {
+		return code[1:]
+	}
+	return "!" + code
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The intermediate representation of Quint, as written by
+//
+//	quint parse --out=spec.json spec.qnt
+//	quint typecheck --out=spec.json spec.qnt
+//
+// Only the parts that describe the types of the state variables and of the
+// parameters of the actions are decoded.
+
+// the output of quint parse or quint typecheck
+type quintOutput struct {
+	Modules []quintModule `json:"modules"`
+	// the types of the expressions by their ids, only written by quint typecheck
+	Types quintTypes `json:"types"`
+}
+
+type quintModule struct {
+	Name         string      `json:"name"`
+	Declarations []quintDecl `json:"declarations"`
+}
+
+// a declaration of a module: var, const, typedef, def, import, ...
+type quintDecl struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Qualifier string `json:"qualifier"`
+	// the type of var and const, and the signature of def, if it was written
+	TypeAnnotation *quintType `json:"typeAnnotation"`
+	// the type of typedef, or nil for an uninterpreted type
+	Type *quintType `json:"type"`
+	Expr *quintExpr `json:"expr"`
+}
+
+// an expression: name, bool, int, str, app, lambda, or let. The parameters of
+// the actions are read from their lambdas, and the invariants are compiled
+// from their expressions, see monitor.go.
+type quintExpr struct {
+	ID   quintID `json:"id"`
+	Kind string  `json:"kind"`
+	// the name of name, and the operator of app, e.g., iadd or forall
+	Name   string `json:"name"`
+	Opcode string `json:"opcode"`
+	// the literal of bool, int, and str, where int may exceed int64
+	Value json.RawMessage `json:"value"`
+	Args  []*quintExpr    `json:"args"`
+	// the parameters and the body of lambda
+	Params []quintParam `json:"params"`
+	Expr   *quintExpr   `json:"expr"`
+}
+
+type quintParam struct {
+	ID             quintID    `json:"id"`
+	Name           string     `json:"name"`
+	TypeAnnotation *quintType `json:"typeAnnotation"`
+}
+
+// a type: bool, int, str, const, var, set, list, fun, oper, tup, rec, sum,
+// app, or abs
+type quintType struct {
+	Kind string `json:"kind"`
+	// the name of const and var
+	Name string     `json:"name,omitempty"`
+	Elem *quintType `json:"elem,omitempty"`
+	// the argument and the result of fun, and the result of oper
+	Arg *quintType `json:"arg,omitempty"`
+	Res *quintType `json:"res,omitempty"`
+	// the arguments of oper and app
+	Args []*quintType `json:"args,omitempty"`
+	// the fields of tup and rec, and the variants of sum
+	Fields *quintRow `json:"fields,omitempty"`
+	// the constructor of app, e.g., Option in Option[int]
+	Ctor *quintType `json:"ctor,omitempty"`
+	// the parameters and the body of a parameterized type, e.g.,
+	// type Option[a] = Some(a) | None
+	Vars []*quintType `json:"vars,omitempty"`
+	Body *quintType   `json:"body,omitempty"`
+}
+
+// a row of fields, which may be continued by another row
+type quintRow struct {
+	Kind   string       `json:"kind"`
+	Fields []quintField `json:"fields,omitempty"`
+	Other  *quintRow    `json:"other,omitempty"`
+}
+
+type quintField struct {
+	FieldName string     `json:"fieldName"`
+	FieldType *quintType `json:"fieldType"`
+}
+
+// the fields of a row, including the fields of the rows that continue it
+func (r *quintRow) all() []quintField {
+	var fields []quintField
+	for ; r != nil && r.Kind == "row"; r = r.Other {
+		fields = append(fields, r.Fields...)
+	}
+	return fields
+}
+
+// the id of an expression, which Quint writes as a number or as a string
+type quintID string
+
+func (id *quintID) UnmarshalJSON(data []byte) error {
+	*id = quintID(strings.Trim(string(data), `"`))
+	return nil
+}
+
+// the types of the expressions, which are written as an object from the ids
+// to the type schemes, or as an array of [id, scheme] pairs
+type quintTypes map[quintID]*quintType
+
+func (types *quintTypes) UnmarshalJSON(data []byte) error {
+	type scheme struct {
+		Type *quintType `json:"type"`
+	}
+	*types = make(quintTypes)
+	var byID map[quintID]scheme
+	if err := json.Unmarshal(data, &byID); err == nil {
+		for id, s := range byID {
+			(*types)[id] = s.Type
+		}
+		return nil
+	}
+	var pairs [][2]json.RawMessage
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return fmt.Errorf("expected the types by their ids: %v", err)
+	}
+	for _, p := range pairs {
+		var id quintID
+		var s scheme
+		if err := id.UnmarshalJSON(p[0]); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(p[1], &s); err != nil {
+			return err
+		}
+		(*types)[id] = s.Type
+	}
+	return nil
+}
+
+// read the output of quint parse or quint typecheck
+func readQuint(filename string) (quintOutput, error) {
+	var out quintOutput
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return out, fmt.Errorf("error opening file: %v", err)
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(out.Modules) == 0 {
+		return out, fmt.Errorf("%s: expected the output of quint parse or quint typecheck", filename)
+	}
+	return out, nil
+}
@@ -0,0 +1,63 @@
+package itf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// the first bytes of the compressed files
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// the extensions of the compressed files, e.g., trace.itf.json.gz
+var compressedExtensions = []string{".gz", ".zst"}
+
+// Decompress decompresses a trace that is compressed with gzip or zstd,
+// which it detects by the first bytes of the data. Any other data is
+// returned as is.
+func Decompress(data []byte) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	case bytes.HasPrefix(data, zstdMagic):
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return data, nil
+	}
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing: %v", err)
+	}
+	return decompressed, nil
+}
+
+// Uncompressed returns the name of a file without the extension of its
+// compression, e.g., trace.itf.json for trace.itf.json.gz.
+func Uncompressed(filename string) string {
+	ext := filepath.Ext(filename)
+	for _, c := range compressedExtensions {
+		if ext == c {
+			return strings.TrimSuffix(filename, ext)
+		}
+	}
+	return filename
+}
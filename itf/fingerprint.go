@@ -0,0 +1,268 @@
+package itf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Fingerprint is a hash of an ITF value, which is equal for two values iff
+// their canonical forms are equal, up to collisions of SHA-256. A harness
+// compares a state of a spec with a state of a system under test by their
+// fingerprints, which takes constant time and memory once they are computed,
+// e.g., when the fingerprints of the states of a trace are computed ahead of
+// the replay, or when a system under test reports the fingerprint of its
+// state instead of the state. Only when the fingerprints differ, Diff tells
+// where the values differ.
+type Fingerprint [sha256.Size]byte
+
+func (f Fingerprint) String() string {
+	return hex.EncodeToString(f[:])
+}
+
+// FingerprintOf returns the fingerprint of a value. The fingerprint is a
+// Merkle hash over the structure of the value: the elements of sets and the
+// entries of maps are hashed one by one, and their hashes are sorted, instead
+// of their canonical forms.
+func FingerprintOf(v gjson.Result) (Fingerprint, error) {
+	var f Fingerprint
+	h := sha256.New()
+	switch {
+	case v.Type == gjson.Number:
+		return fingerprintBigInt(v)
+	case v.Type == gjson.String:
+		h.Write([]byte("s"))
+		h.Write([]byte(v.Str))
+	case v.IsArray():
+		h.Write([]byte("l"))
+		if err := fingerprintElems(h.Write, v.Array(), false); err != nil {
+			return f, err
+		}
+	case !v.IsObject():
+		// true, false, and null
+		h.Write([]byte("v"))
+		h.Write([]byte(v.Raw))
+	default:
+		obj := v.Map()
+		if _, found := obj["#bigint"]; found {
+			return fingerprintBigInt(v)
+		}
+		var err error
+		if elems, found := obj["#set"]; found {
+			h.Write([]byte("S"))
+			err = fingerprintElems(h.Write, elems.Array(), true)
+		} else if pairs, found := obj["#map"]; found {
+			h.Write([]byte("M"))
+			err = fingerprintElems(h.Write, pairs.Array(), true)
+		} else if elems, found := obj["#tup"]; found {
+			h.Write([]byte("t"))
+			err = fingerprintElems(h.Write, elems.Array(), false)
+		} else {
+			h.Write([]byte("r"))
+			err = fingerprintRecord(h.Write, obj)
+		}
+		if err != nil {
+			return f, err
+		}
+	}
+	copy(f[:], h.Sum(nil))
+	return f, nil
+}
+
+func fingerprintBigInt(v gjson.Result) (Fingerprint, error) {
+	i, err := BigInt(v)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	return sha256.Sum256([]byte("i" + i.String())), nil
+}
+
+// write the fingerprints of elements, sorted, if their order does not matter
+func fingerprintElems(write func([]byte) (int, error), elems []gjson.Result, sorted bool) error {
+	fingerprints := make([]Fingerprint, len(elems))
+	for i, e := range elems {
+		var err error
+		if fingerprints[i], err = FingerprintOf(e); err != nil {
+			return err
+		}
+	}
+	if sorted {
+		sort.Slice(fingerprints, func(i, j int) bool {
+			return bytes.Compare(fingerprints[i][:], fingerprints[j][:]) < 0
+		})
+	}
+	for _, f := range fingerprints {
+		write(f[:])
+	}
+	return nil
+}
+
+// write the names of the fields of a record, in order, with the fingerprints of their values
+func fingerprintRecord(write func([]byte) (int, error), obj map[string]gjson.Result) error {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, err := FingerprintOf(obj[name])
+		if err != nil {
+			return err
+		}
+		// the name is hashed with its length, so it cannot run into the next one
+		write([]byte(fmt.Sprintf("%d:%s", len(name), name)))
+		write(f[:])
+	}
+	return nil
+}
+
+// Difference is a part of two values, in which they differ. The values are
+// written in the syntax of Quint, see Show.
+type Difference struct {
+	// the path of the part, e.g., .balances["alice"] or [1], which is empty
+	// for the values themselves
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: expected %s, found %s", d.Path, d.Expected, d.Actual)
+}
+
+// Diff compares two values structurally, and returns the innermost parts, in
+// which they differ: the fields of records, the elements of lists and tuples
+// of the same length, and the entries of maps, by their keys. Two sets differ
+// in the sets of the elements that only one of them has, e.g., expected
+// Set(1), found Set(3) for Set(1, 2) and Set(2, 3). Diff returns nil, if the
+// values are equal.
+func Diff(expected, actual gjson.Result) ([]Difference, error) {
+	e, err := Canonical(expected)
+	if err != nil {
+		return nil, err
+	}
+	a, err := Canonical(actual)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []Difference
+	diff("", gjson.Parse(e), gjson.Parse(a), &diffs)
+	return diffs, nil
+}
+
+// the kind of a value in canonical form
+func kind(v gjson.Result) string {
+	switch {
+	case v.IsArray():
+		return "list"
+	case !v.IsObject():
+		return "scalar"
+	}
+	for _, tag := range []string{"#bigint", "#set", "#map", "#tup"} {
+		if v.Get("\\" + tag).Exists() {
+			return tag
+		}
+	}
+	return "record"
+}
+
+// compare two values in canonical form
+func diff(path string, e, a gjson.Result, diffs *[]Difference) {
+	if e.Raw == a.Raw {
+		return
+	}
+	k := kind(e)
+	differ := func() {
+		*diffs = append(*diffs, Difference{Path: path, Expected: Show(e), Actual: Show(a)})
+	}
+	if k != kind(a) {
+		differ()
+		return
+	}
+	switch k {
+	case "list", "#tup":
+		elems, others := e.Array(), a.Array()
+		if k == "#tup" {
+			elems, others = e.Get(`\#tup`).Array(), a.Get(`\#tup`).Array()
+		}
+		if len(elems) != len(others) {
+			differ()
+			return
+		}
+		for i := range elems {
+			diff(fmt.Sprintf("%s[%d]", path, i), elems[i], others[i], diffs)
+		}
+	case "record":
+		fields, others := e.Map(), a.Map()
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		for name := range others {
+			if _, found := fields[name]; !found {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			diff(path+"."+name, fields[name], others[name], diffs)
+		}
+	case "#map":
+		entries, others := entriesByKey(e), entriesByKey(a)
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		for key := range others {
+			if _, found := entries[key]; !found {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			diff(path+"["+Show(gjson.Parse(key))+"]", entries[key], others[key], diffs)
+		}
+	case "#set":
+		elems, others := elemsByRaw(e.Get(`\#set`)), elemsByRaw(a.Get(`\#set`))
+		var missing, unexpected []string
+		for _, x := range e.Get(`\#set`).Array() {
+			if !others[x.Raw] {
+				missing = append(missing, Show(x))
+			}
+		}
+		for _, x := range a.Get(`\#set`).Array() {
+			if !elems[x.Raw] {
+				unexpected = append(unexpected, Show(x))
+			}
+		}
+		*diffs = append(*diffs, Difference{
+			Path:     path,
+			Expected: "Set(" + strings.Join(missing, ", ") + ")",
+			Actual:   "Set(" + strings.Join(unexpected, ", ") + ")",
+		})
+	default:
+		differ()
+	}
+}
+
+// the values of a map in canonical form, by the canonical forms of their keys
+func entriesByKey(m gjson.Result) map[string]gjson.Result {
+	entries := make(map[string]gjson.Result)
+	for _, pair := range m.Get(`\#map`).Array() {
+		entries[pair.Get("0").Raw] = pair.Get("1")
+	}
+	return entries
+}
+
+func elemsByRaw(set gjson.Result) map[string]bool {
+	elems := make(map[string]bool)
+	for _, x := range set.Array() {
+		elems[x.Raw] = true
+	}
+	return elems
+}
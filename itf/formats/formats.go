@@ -0,0 +1,286 @@
+// Package formats reads and writes traces in the formats of this repository:
+// ITF JSON, the protobuf representation of the package itfpb, CBOR, and
+// MessagePack. The format of a file is detected by its extension, or, if the
+// extension is unknown, by its first bytes.
+//
+// CBOR and MessagePack keep the data model of ITF JSON, that is, objects,
+// arrays, strings, and booleans, but write every integer that fits into 64
+// bits as a native integer instead of {"#bigint": "..."}, which makes them
+// smaller than JSON. The fields of an object are written in order of their
+// names, so the same trace is always encoded in the same bytes.
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/tidwall/gjson"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/itfpb"
+)
+
+// Format is the format of a trace file.
+type Format string
+
+// The formats of trace files.
+const (
+	JSON     Format = "json"
+	Protobuf Format = "pb"
+	CBOR     Format = "cbor"
+	MsgPack  Format = "msgpack"
+)
+
+// the extensions of the formats, e.g., trace.itf.cbor
+var extensions = map[string]Format{
+	".json":    JSON,
+	".pb":      Protobuf,
+	".cbor":    CBOR,
+	".msgpack": MsgPack,
+	".mpk":     MsgPack,
+}
+
+// the self-described CBOR tag 55799, which CBOR files start with
+var cborMagic = []byte{0xd9, 0xd9, 0xf7}
+
+// Detect returns the format of a file by its extension, or by its first bytes.
+// The data has to be decompressed, but the filename may have the extension of
+// its compression, e.g., trace.itf.cbor.gz.
+func Detect(filename string, data []byte) Format {
+	if format, found := extensions[filepath.Ext(itf.Uncompressed(filename))]; found {
+		return format
+	}
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(data, cborMagic):
+		return CBOR
+	case len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && gjson.ValidBytes(data):
+		return JSON
+	case len(data) > 0 && (data[0]&0xf0 == 0x80 || data[0] == 0xde || data[0] == 0xdf):
+		// a trace in MessagePack is a map, whereas a Trace message starts
+		// with one of its fields 1-3, that is, with 0x0a, 0x12, or 0x1a
+		return MsgPack
+	default:
+		return Protobuf
+	}
+}
+
+// ParseFormat returns the format of a name, e.g., of a command-line flag.
+func ParseFormat(name string) (Format, error) {
+	if format, found := extensions["."+strings.TrimPrefix(name, ".")]; found {
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected json, pb, cbor, or msgpack", name)
+}
+
+// ReadFile reads a trace from a file in any of the formats, which may be
+// compressed with gzip or zstd.
+func ReadFile(filename string) (itf.Trace, error) {
+	return ReadFileVars(filename, nil)
+}
+
+// ReadFileVars reads a trace like ReadFile, but it keeps only the state
+// variables vars, see itf.ParseVars. If vars is nil, all state variables are
+// kept.
+func ReadFileVars(filename string, vars []string) (itf.Trace, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return itf.Trace{}, fmt.Errorf("error opening file: %v", err)
+	}
+	data, err = itf.Decompress(data)
+	if err != nil {
+		return itf.Trace{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	format := Detect(filename, data)
+	if format == Protobuf {
+		// the protobuf decoder skips the values of the other variables
+		trace, err := itfpb.ParseVars(data, vars)
+		if err != nil {
+			return itf.Trace{}, fmt.Errorf("%s: %v", filename, err)
+		}
+		return trace, nil
+	}
+	js, err := ToJSON(data, format)
+	if err != nil {
+		return itf.Trace{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return itf.ParseVars(js, vars)
+}
+
+// ToJSON converts a trace from a format to ITF JSON.
+func ToJSON(data []byte, format Format) ([]byte, error) {
+	var tree any
+	switch format {
+	case JSON:
+		return data, nil
+	case Protobuf:
+		return itfpb.ToJSON(data)
+	case CBOR:
+		decoder, err := cbor.DecOptions{DefaultMapType: mapType}.DecMode()
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	case MsgPack:
+		decoder := msgpack.NewDecoder(bytes.NewReader(data))
+		decoder.SetMapDecoder(func(d *msgpack.Decoder) (any, error) {
+			return d.DecodeUntypedMap()
+		})
+		if err := decoder.Decode(&tree); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	tree, err := toITF(tree)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+// FromJSON converts a trace in ITF JSON to a format.
+func FromJSON(data []byte, format Format) ([]byte, error) {
+	if !gjson.ValidBytes(data) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+	switch format {
+	case JSON:
+		return data, nil
+	case Protobuf:
+		return itfpb.FromJSON(data)
+	}
+	tree, err := fromITF(gjson.ParseBytes(data))
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case CBOR:
+		encoder, err := cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := encoder.Marshal(cbor.Tag{Number: 55799, Content: tree})
+		return encoded, err
+	case MsgPack:
+		var w bytes.Buffer
+		encoder := msgpack.NewEncoder(&w)
+		encoder.SetSortMapKeys(true)
+		err := encoder.Encode(tree)
+		return w.Bytes(), err
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// Convert converts a trace from one format to another.
+func Convert(data []byte, from, to Format) ([]byte, error) {
+	js, err := ToJSON(data, from)
+	if err != nil {
+		return nil, err
+	}
+	return FromJSON(js, to)
+}
+
+// CBOR decodes the objects as maps with string keys, as JSON does
+var mapType = reflect.TypeOf(map[string]any(nil))
+
+// convert ITF JSON into a tree of Go values, in which the integers that fit into 64 bits are native
+func fromITF(v gjson.Result) (any, error) {
+	switch {
+	case v.Type == gjson.String:
+		return v.String(), nil
+	case v.Type == gjson.True || v.Type == gjson.False:
+		return v.Bool(), nil
+	case v.Type == gjson.Null:
+		return nil, nil
+	case v.Type == gjson.Number || (v.IsObject() && v.Get("\\#bigint").Exists()):
+		i, err := itf.BigInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if i.IsInt64() {
+			return i.Int64(), nil
+		}
+		return map[string]any{"#bigint": i.String()}, nil
+	case v.IsArray():
+		elems := make([]any, 0)
+		for _, e := range v.Array() {
+			t, err := fromITF(e)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, t)
+		}
+		return elems, nil
+	}
+	obj := make(map[string]any)
+	var err error
+	v.ForEach(func(key, value gjson.Result) bool {
+		obj[key.String()], err = fromITF(value)
+		return err == nil
+	})
+	return obj, err
+}
+
+// convert a tree of decoded Go values back into ITF JSON, as a tree of Go values
+func toITF(tree any) (any, error) {
+	switch v := tree.(type) {
+	case nil, string, bool:
+		return v, nil
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, int, uint:
+		return map[string]any{"#bigint": fmt.Sprint(v)}, nil
+	case *big.Int:
+		return map[string]any{"#bigint": v.String()}, nil
+	case big.Int:
+		return map[string]any{"#bigint": v.String()}, nil
+	case []any:
+		elems := make([]any, 0, len(v))
+		for _, e := range v {
+			t, err := toITF(e)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, t)
+		}
+		return elems, nil
+	case map[string]any:
+		obj := make(map[string]any, len(v))
+		for key, value := range v {
+			t, err := toITF(value)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = t
+		}
+		return obj, nil
+	case map[any]any:
+		// CBOR decodes the content of a tag without DefaultMapType
+		obj := make(map[string]any, len(v))
+		for key, value := range v {
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string key, found %v", key)
+			}
+			obj[name] = value
+		}
+		return toITF(obj)
+	case cbor.Tag:
+		if v.Number == 55799 {
+			return toITF(v.Content)
+		}
+		return nil, fmt.Errorf("unexpected CBOR tag %d", v.Number)
+	default:
+		return nil, fmt.Errorf("unexpected value of type %T: %v", tree, tree)
+	}
+}
@@ -0,0 +1,136 @@
+package formats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+const quintTrace = `{
+  "#meta": { "format": "ITF", "source": "coinsTest.qnt" },
+  "vars": [ "balances", "denoms", "pair", "log" ],
+  "states": [
+    {
+      "#meta": { "index": 0 },
+      "balances": { "#map": [ [ "atom", { "#bigint": "10" } ], [ "osmo", 3 ] ] },
+      "denoms": { "#set": [ "atom", "osmo" ] },
+      "pair": { "#tup": [ "atom", { "#bigint": "-115792089237316195423570985008687907853269984665640564039457584007913129639935" } ] },
+      "log": [ { "kind": "mint", "ok": true, "amount": { "#bigint": "18446744073709551615" } } ]
+    }
+  ]
+}`
+
+// compare two traces state by state, in their canonical forms
+func assertSameTrace(t *testing.T, expected, actual itf.Trace) {
+	assert.Equal(t, expected.Vars, actual.Vars)
+	require.Equal(t, len(expected.States), len(actual.States))
+	for i := range expected.States {
+		e, err := itf.Canonical(expected.States[i])
+		require.NoError(t, err)
+		a, err := itf.Canonical(actual.States[i])
+		require.NoError(t, err)
+		require.Equal(t, e, a, "state %d", i)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	expected, err := itf.Parse([]byte(quintTrace))
+	require.NoError(t, err)
+	for _, format := range []Format{JSON, Protobuf, CBOR, MsgPack} {
+		data, err := FromJSON([]byte(quintTrace), format)
+		require.NoError(t, err, format)
+		// the format is detected by the first bytes, when the extension is unknown
+		assert.Equal(t, format, Detect("trace", data))
+		filename := filepath.Join(t.TempDir(), "trace.itf."+string(format))
+		require.NoError(t, os.WriteFile(filename, data, 0o644))
+		actual, err := ReadFile(filename)
+		require.NoError(t, err, format)
+		assertSameTrace(t, expected, actual)
+
+		// a compressed file is detected by the extension in front of .gz
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.NoError(t, os.WriteFile(filename+".gz", gz.Bytes(), 0o644))
+		actual, err = ReadFile(filename + ".gz")
+		require.NoError(t, err, format)
+		assertSameTrace(t, expected, actual)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	for _, format := range []Format{CBOR, MsgPack} {
+		first, err := FromJSON([]byte(quintTrace), format)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			data, err := FromJSON([]byte(quintTrace), format)
+			require.NoError(t, err)
+			require.Equal(t, first, data, format)
+		}
+	}
+}
+
+// all traces of the repository survive the conversions, and they get smaller
+func TestRoundTripOfRepository(t *testing.T) {
+	files, err := filepath.Glob("../../*/test-inputs*/*.itf.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	sizes := make(map[Format]int)
+	for _, filename := range files {
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		expected, err := itf.Parse(data)
+		require.NoError(t, err, filename)
+		sizes[JSON] += len(data)
+		for _, format := range []Format{CBOR, MsgPack} {
+			encoded, err := FromJSON(data, format)
+			require.NoError(t, err, filename)
+			sizes[format] += len(encoded)
+			js, err := ToJSON(encoded, format)
+			require.NoError(t, err, filename)
+			actual, err := itf.Parse(js)
+			require.NoError(t, err, filename)
+			assertSameTrace(t, expected, actual)
+		}
+	}
+	t.Logf("%d traces: %v bytes", len(files), sizes)
+	assert.Less(t, sizes[CBOR], sizes[JSON])
+	assert.Less(t, sizes[MsgPack], sizes[JSON])
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("cbor")
+	require.NoError(t, err)
+	assert.Equal(t, CBOR, format)
+	_, err = ParseFormat("yaml")
+	assert.Error(t, err)
+}
+
+// the time to read the largest trace of the repository in every format
+func BenchmarkReadFile(b *testing.B) {
+	data, err := os.ReadFile("../../crdt/test-inputs/oneRandom.itf.json")
+	require.NoError(b, err)
+	for _, format := range []Format{JSON, Protobuf, CBOR, MsgPack} {
+		encoded, err := FromJSON(data, format)
+		require.NoError(b, err)
+		filename := filepath.Join(b.TempDir(), "trace.itf."+string(format))
+		require.NoError(b, os.WriteFile(filename, encoded, 0o644))
+		b.Run(string(format), func(b *testing.B) {
+			b.SetBytes(int64(len(encoded)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadFile(filename); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/informalsystems/quint-sandbox/itf
+
+go 1.20
+
+require (
+	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/klauspost/compress v1.16.7
+	github.com/stretchr/testify v1.8.4
+	github.com/tidwall/gjson v1.16.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	google.golang.org/protobuf v1.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/tidwall/match v1.1.1 // indirect
+	github.com/tidwall/pretty v1.2.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+)
@@ -0,0 +1,220 @@
+// Package graph builds a transition graph from traces, which shows the
+// portion of the state space of a specification that a corpus of traces
+// covers. The states are abstracted to a few values, e.g., to the states of the
+// transaction manager and of the resource managers in two-phase commit:
+//
+//	sys.tm.state
+//	sys.rms.\#map.#.1.state
+//
+// Every abstract state is a node, and every action that leads from an abstract
+// state to another one is an edge, which is labeled with the kind of the
+// action, e.g., lastAction.kind. The nodes and the edges count how many times
+// the traces visited them. The graph is written in DOT for GraphViz, or as a
+// Mermaid flowchart, e.g., for a Markdown file on GitHub.
+package graph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// Abstraction tells how to abstract the states of a trace.
+type Abstraction struct {
+	// the gjson paths of the values that make an abstract state, or all state
+	// variables but the root of Action, if empty
+	Vars []string
+	// the gjson path of the label of the action that led to a state,
+	// e.g., lastAction.kind
+	Action string
+}
+
+// Node is an abstract state.
+type Node struct {
+	ID int
+	// the values of the abstraction, in the syntax of Quint
+	Values []string
+	// whether a trace starts in this state
+	Initial bool
+	// the number of states of the traces that are abstracted to this node
+	Visits int
+}
+
+// Edge is an action from an abstract state to another one.
+type Edge struct {
+	From, To int
+	Action   string
+	// the number of steps of the traces that took this edge
+	Count int
+}
+
+// Graph is the transition graph of a set of traces.
+type Graph struct {
+	Abstraction Abstraction
+	Nodes       []Node
+	Edges       []Edge
+	// the abstract states, and the edges, by their keys
+	nodes map[string]int
+	edges map[edgeKey]int
+}
+
+type edgeKey struct {
+	from, to int
+	action   string
+}
+
+// New returns an empty graph.
+func New(abstraction Abstraction) *Graph {
+	return &Graph{Abstraction: abstraction, nodes: make(map[string]int), edges: make(map[edgeKey]int)}
+}
+
+// Add adds the states and the steps of a trace to the graph.
+func (g *Graph) Add(trace itf.Trace) error {
+	paths := g.Abstraction.Vars
+	if len(paths) == 0 {
+		root, _, _ := strings.Cut(g.Abstraction.Action, ".")
+		for _, v := range trace.Vars {
+			if v != root {
+				paths = append(paths, v)
+			}
+		}
+	}
+	from := -1
+	for i, state := range trace.States {
+		to, err := g.node(paths, state)
+		if err != nil {
+			return fmt.Errorf("state %d: %v", i, err)
+		}
+		g.Nodes[to].Visits++
+		if from < 0 {
+			g.Nodes[to].Initial = true
+		} else {
+			action := ""
+			if g.Abstraction.Action != "" {
+				action = state.Get(g.Abstraction.Action).String()
+			}
+			key := edgeKey{from, to, action}
+			e, found := g.edges[key]
+			if !found {
+				e = len(g.Edges)
+				g.edges[key] = e
+				g.Edges = append(g.Edges, Edge{From: from, To: to, Action: action})
+			}
+			g.Edges[e].Count++
+		}
+		from = to
+	}
+	return nil
+}
+
+// the node of the abstraction of a state, which is added if it is new
+func (g *Graph) node(paths []string, state gjson.Result) (int, error) {
+	keys := make([]string, len(paths))
+	values := make([]string, len(paths))
+	for i, path := range paths {
+		v := state.Get(path)
+		key, err := itf.Canonical(v)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", path, err)
+		}
+		keys[i], values[i] = key, path+" = "+itf.Show(v)
+	}
+	key := strings.Join(keys, "\x00")
+	if n, found := g.nodes[key]; found {
+		return n, nil
+	}
+	n := len(g.Nodes)
+	g.nodes[key] = n
+	g.Nodes = append(g.Nodes, Node{ID: n, Values: values})
+	return n, nil
+}
+
+// Actions returns the labels of the edges, in order.
+func (g *Graph) Actions() []string {
+	seen := make(map[string]bool)
+	var actions []string
+	for _, e := range g.Edges {
+		if !seen[e.Action] {
+			seen[e.Action] = true
+			actions = append(actions, e.Action)
+		}
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// WriteDOT writes the graph in the DOT language of GraphViz.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph states {\n  node [shape=box, fontname=monospace];\n")
+	for _, n := range g.Nodes {
+		attrs := ""
+		if n.Initial {
+			attrs = ", peripheries=2"
+		}
+		fmt.Fprintf(&b, "  s%d [label=\"%s\\l%s\\l\"%s];\n",
+			n.ID, dotEscape(strings.Join(n.Values, "\n")), visits(n), attrs)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  s%d -> s%d [label=\"%s\"];\n", e.From, e.To, dotEscape(edgeLabel(e)))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for _, n := range g.Nodes {
+		open, close := "[", "]"
+		if n.Initial {
+			open, close = "([", "])"
+		}
+		fmt.Fprintf(&b, "  s%d%s\"%s<br/>%s\"%s\n",
+			n.ID, open, mermaidEscape(strings.Join(n.Values, "\n")), visits(n), close)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  s%d -->|\"%s\"| s%d\n", e.From, mermaidEscape(edgeLabel(e)), e.To)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// the number of visits of a node, e.g., (3 visits)
+func visits(n Node) string {
+	if n.Visits == 1 {
+		return "(1 visit)"
+	}
+	return fmt.Sprintf("(%d visits)", n.Visits)
+}
+
+// the label of an edge, with the number of steps that took it, e.g., commit ×3
+func edgeLabel(e Edge) string {
+	if e.Count == 1 {
+		return e.Action
+	}
+	return fmt.Sprintf("%s ×%d", e.Action, e.Count)
+}
+
+// escape a label in DOT, where \l ends a left-justified line
+func dotEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\l`)
+}
+
+// escape a label in Mermaid, which takes HTML entities and line breaks
+func mermaidEscape(s string) string {
+	s = strings.ReplaceAll(s, "&", "#amp;")
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "<", "#lt;")
+	s = strings.ReplaceAll(s, ">", "#gt;")
+	return strings.ReplaceAll(s, "\n", "<br/>")
+}
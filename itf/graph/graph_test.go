@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// a door that is opened and closed, and whose lock is only abstracted away
+const doorTrace = `{
+  "vars": [ "door", "lock", "lastAction" ],
+  "states": [
+    { "door": "closed", "lock": { "#bigint": "1" }, "lastAction": { "kind": "init" } },
+    { "door": "open", "lock": { "#bigint": "2" }, "lastAction": { "kind": "open" } },
+    { "door": "closed", "lock": { "#bigint": "3" }, "lastAction": { "kind": "close" } },
+    { "door": "open", "lock": { "#bigint": "4" }, "lastAction": { "kind": "open" } },
+    { "door": "open", "lock": 5, "lastAction": { "kind": "say \"hi\"" } }
+  ]
+}`
+
+func TestGraph(t *testing.T) {
+	trace, err := itf.Parse([]byte(doorTrace))
+	require.NoError(t, err)
+	g := New(Abstraction{Vars: []string{"door"}, Action: "lastAction.kind"})
+	require.NoError(t, g.Add(trace))
+	require.NoError(t, g.Add(trace))
+
+	assert.Equal(t, []Node{
+		{ID: 0, Values: []string{`door = "closed"`}, Initial: true, Visits: 4},
+		{ID: 1, Values: []string{`door = "open"`}, Visits: 6},
+	}, g.Nodes)
+	assert.Equal(t, []Edge{
+		{From: 0, To: 1, Action: "open", Count: 4},
+		{From: 1, To: 0, Action: "close", Count: 2},
+		{From: 1, To: 1, Action: `say "hi"`, Count: 2},
+	}, g.Edges)
+	assert.Equal(t, []string{"close", "open", `say "hi"`}, g.Actions())
+
+	var dot strings.Builder
+	require.NoError(t, g.WriteDOT(&dot))
+	assert.Contains(t, dot.String(), `s0 [label="door = \"closed\"\l(4 visits)\l", peripheries=2];`)
+	assert.Contains(t, dot.String(), `s1 -> s1 [label="say \"hi\" ×2"];`)
+
+	var mermaid strings.Builder
+	require.NoError(t, g.WriteMermaid(&mermaid))
+	assert.Contains(t, mermaid.String(), `s0(["door = #quot;closed#quot;<br/>(4 visits)"])`)
+	assert.Contains(t, mermaid.String(), `s0 -->|"open ×4"| s1`)
+}
+
+// without an abstraction, the states are abstracted to all variables but the action,
+// and the integers are compared by value
+func TestAllVars(t *testing.T) {
+	trace, err := itf.Parse([]byte(`{
+	  "vars": [ "x", "lastAction" ],
+	  "states": [
+	    { "x": { "#bigint": "1" }, "lastAction": { "kind": "init" } },
+	    { "x": 1, "lastAction": { "kind": "skip" } }
+	  ]
+	}`))
+	require.NoError(t, err)
+	g := New(Abstraction{Action: "lastAction.kind"})
+	require.NoError(t, g.Add(trace))
+	assert.Equal(t, []Node{{ID: 0, Values: []string{"x = 1"}, Initial: true, Visits: 2}}, g.Nodes)
+	assert.Equal(t, []Edge{{From: 0, To: 0, Action: "skip", Count: 1}}, g.Edges)
+}
@@ -0,0 +1,57 @@
+package harness
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// BigInt decodes an integer of a state with itf.BigInt, and fails the test if
+// it is not one, so that a harness decodes its states without checking an
+// error after every field:
+//
+//	state.balance = harness.Uint64(t, jsonState.Get("wallet.balance"))
+func BigInt(t testing.TB, v gjson.Result) *big.Int {
+	t.Helper()
+	i, err := itf.BigInt(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// Int64 decodes an integer that fits into int64 with itf.Int64, or fails the
+// test.
+func Int64(t testing.TB, v gjson.Result) int64 {
+	t.Helper()
+	i, err := itf.Int64(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// Uint64 decodes an integer that fits into uint64 with itf.Uint64, or fails
+// the test.
+func Uint64(t testing.TB, v gjson.Result) uint64 {
+	t.Helper()
+	i, err := itf.Uint64(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// Dec decodes a decimal of decimal.qnt with itf.Dec, or fails the test, e.g.,
+// if the spec produced an erroneous decimal.
+func Dec(t testing.TB, v gjson.Result) *big.Int {
+	t.Helper()
+	i, err := itf.Dec(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
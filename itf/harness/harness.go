@@ -0,0 +1,228 @@
+// Package harness runs the traces of an example against its implementation,
+// as configured in a file harness.yaml next to the spec, instead of in the Go
+// code of the harness. The file declares which traces are replayed, which of
+// their operations are enabled, the implementation to replay them against,
+// how many traces are replayed in parallel, and the tolerances of the
+// comparisons:
+//
+//	traces:
+//	  - test-inputs-v0.3.0/*.itf.json
+//	op: lastAction.kind
+//	opcodes: [tick, allow, reserve]
+//	target: rate
+//	parallel: 4
+//	tolerances:
+//	  tokens: 0.001
+//
+// A harness registers its implementations as targets, and runs the traces
+// with Run in a test of its own:
+//
+//	func TestHarness(t *testing.T) {
+//		c, err := harness.Load("../harness.yaml")
+//		require.NoError(t, err)
+//		harness.Run(t, c, map[string]harness.Target{"rate": execTrace})
+//	}
+//
+// An example opts in to the package with a harness.yaml of its own; the other
+// examples name their traces in their tests.
+//
+// A harness decodes the integers and the decimals of its states with BigInt,
+// Int64, Uint64, and Dec, which fail the test instead of returning an error.
+package harness
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// Config is the configuration of the runs of a harness.
+type Config struct {
+	// the globs of the traces, relative to the directory of the file
+	Traces []string `yaml:"traces"`
+	// the gjson path of the operation of a state, e.g., lastAction.kind
+	Op string `yaml:"op"`
+	// the operations that are enabled, or all operations, if empty
+	Opcodes []string `yaml:"opcodes"`
+	// the name of the implementation to replay the traces against, which may
+	// be omitted, if the harness has only one
+	Target string `yaml:"target"`
+	// the number of traces that are replayed in parallel, one by default
+	Parallel int `yaml:"parallel"`
+	// the absolute tolerances of the comparisons by their names, which are 0,
+	// that is, exact, if they are missing
+	Tolerances map[string]float64 `yaml:"tolerances"`
+
+	// the directory of the file, which the globs are relative to
+	dir string
+}
+
+// Load reads a configuration from a YAML file. Unknown keys are errors, so a
+// typo does not silently fall back to a default.
+func Load(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	c.dir = filepath.Dir(filename)
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Traces) == 0 {
+		return fmt.Errorf("no traces")
+	}
+	if len(c.Opcodes) > 0 && c.Op == "" {
+		return fmt.Errorf("opcodes without the path of the operation in op")
+	}
+	if c.Parallel < 0 {
+		return fmt.Errorf("expected a non-negative parallel, found %d", c.Parallel)
+	}
+	for name, tolerance := range c.Tolerances {
+		if tolerance < 0 {
+			return fmt.Errorf("tolerance %s: expected a non-negative number, found %v", name, tolerance)
+		}
+	}
+	return nil
+}
+
+// Files returns the traces that match the globs, in order, without
+// duplicates.
+func (c *Config) Files() ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, glob := range c.Traces {
+		matches, err := filepath.Glob(filepath.Join(c.dir, glob))
+		if err != nil {
+			return nil, fmt.Errorf("traces %s: %v", glob, err)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Enabled tells whether an operation is enabled.
+func (c *Config) Enabled(opcode string) bool {
+	if len(c.Opcodes) == 0 {
+		return true
+	}
+	for _, op := range c.Opcodes {
+		if op == opcode {
+			return true
+		}
+	}
+	return false
+}
+
+// Tolerance returns the tolerance of a comparison by its name.
+func (c *Config) Tolerance(name string) float64 {
+	return c.Tolerances[name]
+}
+
+// the first operation of a trace that is not enabled, or "", if there is none;
+// the initial state is not the result of an operation
+func (c *Config) disabledOp(trace itf.Trace) string {
+	if c.Op == "" {
+		return ""
+	}
+	for i := 1; i < len(trace.States); i++ {
+		if op := trace.States[i].Get(c.Op).String(); !c.Enabled(op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// Target replays a trace against an implementation.
+type Target func(t *testing.T, trace itf.Trace)
+
+// Run replays the traces of a configuration against its target, every trace in
+// a subtest, which is named by the path of the trace relative to the file. As
+// the states of a trace usually depend on the states before, a trace that has
+// a disabled operation is skipped as a whole. A harness of independent
+// operations may rather check Enabled for every state.
+//
+// If more than one trace is replayed in parallel, the subtests are parallel,
+// so they run after the test that called Run returns, at most Parallel at a
+// time, and within the bounds of go test -parallel.
+func Run(t *testing.T, c *Config, targets map[string]Target) {
+	t.Helper()
+	target, err := c.target(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no traces match %v in %s", c.Traces, c.dir)
+	}
+	parallel := c.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	slots := make(chan struct{}, parallel)
+	for _, filename := range files {
+		filename := filename
+		name, err := filepath.Rel(c.dir, filename)
+		if err != nil {
+			name = filename
+		}
+		t.Run(name, func(t *testing.T) {
+			if c.Parallel > 1 {
+				t.Parallel()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			trace, err := itf.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if op := c.disabledOp(trace); op != "" {
+				t.Skipf("the operation %s is not enabled", op)
+			}
+			target(t, trace)
+		})
+	}
+}
+
+// the target of a configuration, or the only target, if it names none
+func (c *Config) target(targets map[string]Target) (Target, error) {
+	if c.Target == "" && len(targets) == 1 {
+		for _, target := range targets {
+			return target, nil
+		}
+	}
+	if target, found := targets[c.Target]; found {
+		return target, nil
+	}
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown target %q, known targets: %v", c.Target, names)
+}
@@ -0,0 +1,163 @@
+package harness
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// write the files of an example into a temporary directory
+func example(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+// a trace of the operations of a counter
+func counterTrace(ops ...string) string {
+	trace := `{"vars":["lastAction"],"states":[{"lastAction":{"kind":"init"}}`
+	for _, op := range ops {
+		trace += `,{"lastAction":{"kind":"` + op + `"}}`
+	}
+	return trace + "]}"
+}
+
+func TestLoad(t *testing.T) {
+	dir := example(t, map[string]string{
+		"harness.yaml": `
+traces:
+  - traces/*.itf.json
+  - traces/a.itf.json
+op: lastAction.kind
+opcodes: [inc, dec]
+target: counter
+parallel: 2
+tolerances:
+  value: 0.5
+`,
+		"traces/a.itf.json": counterTrace("inc"),
+		"traces/b.itf.json": counterTrace("dec"),
+		"traces/c.json":     counterTrace("inc"),
+	})
+	c, err := Load(filepath.Join(dir, "harness.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "counter", c.Target)
+	assert.Equal(t, 2, c.Parallel)
+	assert.True(t, c.Enabled("inc"))
+	assert.False(t, c.Enabled("reset"))
+	assert.Equal(t, 0.5, c.Tolerance("value"))
+	assert.Equal(t, 0.0, c.Tolerance("other"))
+
+	files, err := c.Files()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "traces/a.itf.json"),
+		filepath.Join(dir, "traces/b.itf.json"),
+	}, files)
+}
+
+func TestLoadErrors(t *testing.T) {
+	for config, msg := range map[string]string{
+		"op: lastAction.kind":                           "no traces",
+		"traces: [a.itf.json]\nopcodes: [inc]":          "opcodes without",
+		"traces: [a.itf.json]\nparallel: -1":            "non-negative parallel",
+		"traces: [a.itf.json]\ntolerances: {value: -1}": "tolerance value",
+		"traces: [a.itf.json]\ntarget: a\ntargets: [b]": "field targets not found",
+	} {
+		dir := example(t, map[string]string{"harness.yaml": config})
+		_, err := Load(filepath.Join(dir, "harness.yaml"))
+		require.Error(t, err, config)
+		assert.Contains(t, err.Error(), msg, config)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	one := map[string]Target{"a": func(*testing.T, itf.Trace) {}}
+	two := map[string]Target{"a": one["a"], "b": one["a"]}
+
+	_, err := (&Config{}).target(one)
+	assert.NoError(t, err, "the only target")
+	_, err = (&Config{}).target(two)
+	assert.EqualError(t, err, `unknown target "", known targets: [a b]`)
+	_, err = (&Config{Target: "b"}).target(two)
+	assert.NoError(t, err)
+}
+
+func TestRun(t *testing.T) {
+	dir := example(t, map[string]string{
+		"harness.yaml": `
+traces: ["*.itf.json"]
+op: lastAction.kind
+opcodes: [inc]
+parallel: 2
+`,
+		"a.itf.json": counterTrace("inc", "inc"),
+		"b.itf.json": counterTrace("inc", "reset"),
+		"c.itf.json": counterTrace("inc"),
+	})
+	c, err := Load(filepath.Join(dir, "harness.yaml"))
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	replayed := make(map[int]bool)
+	t.Run("traces", func(t *testing.T) {
+		Run(t, c, map[string]Target{"counter": func(t *testing.T, trace itf.Trace) {
+			mu.Lock()
+			defer mu.Unlock()
+			replayed[len(trace.States)] = true
+		}})
+	})
+	// the trace b has the disabled operation reset
+	assert.Equal(t, map[int]bool{3: true, 2: true}, replayed)
+}
+
+// a test that records that it failed, instead of failing
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatal(args ...any) {
+	r.failed = true
+	runtime.Goexit()
+}
+
+// whether decoding fails the test, which stops the goroutine of the test
+func fails(decode func(t testing.TB)) bool {
+	r := &recorder{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		decode(r)
+	}()
+	<-done
+	return r.failed
+}
+
+func TestDecode(t *testing.T) {
+	assert.Equal(t, big.NewInt(-3), BigInt(t, gjson.Parse(`{ "#bigint": "-3" }`)))
+	assert.Equal(t, int64(-3), Int64(t, gjson.Parse(`-3`)))
+	assert.Equal(t, uint64(3), Uint64(t, gjson.Parse(`{ "#bigint": "3" }`)))
+	assert.Equal(t, big.NewInt(5e17), Dec(t, gjson.Parse(`{ "error": "", "value": { "#bigint": "500000000000000000" } }`)))
+
+	assert.True(t, fails(func(t testing.TB) { BigInt(t, gjson.Parse(`"3"`)) }))
+	assert.True(t, fails(func(t testing.TB) { Int64(t, gjson.Parse(`{ "#bigint": "9223372036854775808" }`)) }))
+	assert.True(t, fails(func(t testing.TB) { Uint64(t, gjson.Parse(`-3`)) }))
+	assert.True(t, fails(func(t testing.TB) { Dec(t, gjson.Parse(`{ "error": "overflow", "value": 0 }`)) }))
+	assert.False(t, fails(func(t testing.TB) { Uint64(t, gjson.Parse(`3`)) }))
+}
@@ -0,0 +1,325 @@
+// Package itf decodes traces in the Informal Trace Format (ITF), as produced
+// by `quint run --out-itf` and by Apalache:
+//
+//	https://apalache.informal.systems/docs/adr/015adr-trace.html
+//
+// The harnesses in this repository query the states with gjson. This package
+// adds the decoding of the ITF encodings that gjson does not know about:
+// big integers, maps, sets, and tuples.
+package itf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Trace is a decoded ITF trace. Every state is kept as a JSON object,
+// whose fields are the state variables.
+type Trace struct {
+	// the names of the state variables
+	Vars []string
+	// the states of the trace, in the order of execution
+	States []gjson.Result
+}
+
+// ReadFile reads a trace from a file in the ITF format. The file may be
+// compressed with gzip or zstd, e.g., trace.itf.json.gz or trace.itf.json.zst.
+func ReadFile(filename string) (Trace, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Trace{}, fmt.Errorf("error opening file: %v", err)
+	}
+	data, err = Decompress(data)
+	if err != nil {
+		return Trace{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a trace from its ITF JSON representation.
+func Parse(data []byte) (Trace, error) {
+	return parse(string(data))
+}
+
+// parse decodes a trace, whose states refer to the memory of text
+func parse(text string) (Trace, error) {
+	if !gjson.Valid(text) {
+		return Trace{}, fmt.Errorf("invalid JSON")
+	}
+	root := gjson.Parse(text)
+	if root.IsArray() {
+		// Apalache writes a trace wrapped in an array
+		root = root.Get("0")
+	}
+	if !root.Get("states").IsArray() {
+		return Trace{}, fmt.Errorf("expected an ITF trace with states")
+	}
+	var trace Trace
+	for _, v := range root.Get("vars").Array() {
+		trace.Vars = append(trace.Vars, v.String())
+	}
+	trace.States = root.Get("states").Array()
+	return trace, nil
+}
+
+// BigInt decodes an integer, which is written as {"#bigint": "..."}.
+// Older versions of Quint write small integers as JSON numbers.
+func BigInt(v gjson.Result) (*big.Int, error) {
+	var s = v.Get("\\#bigint").String()
+	if v.Type == gjson.Number {
+		s = v.Raw
+	}
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("expected a big integer, found: %s", v.Raw)
+	}
+	return i, nil
+}
+
+// Int64 decodes an integer, as BigInt does, which has to fit into int64,
+// e.g., a height or a time.
+func Int64(v gjson.Result) (int64, error) {
+	i, err := BigInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsInt64() {
+		return 0, fmt.Errorf("the integer %s does not fit into int64", i)
+	}
+	return i.Int64(), nil
+}
+
+// Uint64 decodes an integer, as BigInt does, which has to fit into uint64,
+// e.g., an amount, a sequence number, or an id.
+func Uint64(v gjson.Result) (uint64, error) {
+	i, err := BigInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsUint64() {
+		return 0, fmt.Errorf("the integer %s does not fit into uint64", i)
+	}
+	return i.Uint64(), nil
+}
+
+// Dec decodes a decimal of decimal.qnt, which is written as
+// {"error": ..., "value": ...}, into its value, that is, the decimal
+// multiplied by 10^18, which is how sdk.Dec stores it:
+//
+//	i, err := itf.Dec(state.Get("price"))
+//	price := sdk.NewDecFromBigIntWithPrec(i, sdk.Precision)
+//
+// A decimal whose error is set is an error. The error of a decimal is the
+// cause, e.g., "overflow", or a bool in the traces that predate the causes.
+func Dec(v gjson.Result) (*big.Int, error) {
+	if !v.IsObject() || !v.Get("value").Exists() {
+		return nil, fmt.Errorf("expected a decimal, found: %s", v.Raw)
+	}
+	if e := v.Get("error"); e.Str != "" || e.Type == gjson.True {
+		return nil, fmt.Errorf("the decimal is an error: %s", e.Raw)
+	}
+	return BigInt(v.Get("value"))
+}
+
+// Set decodes a set, which is written as {"#set": [...]}.
+func Set(v gjson.Result) ([]gjson.Result, error) {
+	return tagged(v, "#set")
+}
+
+// Tuple decodes a tuple, which is written as {"#tup": [...]}.
+func Tuple(v gjson.Result) ([]gjson.Result, error) {
+	return tagged(v, "#tup")
+}
+
+// Entry is a key-value pair of a map.
+type Entry struct {
+	Key   gjson.Result
+	Value gjson.Result
+}
+
+// Map decodes a map, which is written as {"#map": [[key, value], ...]}.
+func Map(v gjson.Result) ([]Entry, error) {
+	pairs, err := tagged(v, "#map")
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(pairs))
+	for _, p := range pairs {
+		kv := p.Array()
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("expected a key-value pair, found: %s", p.Raw)
+		}
+		entries = append(entries, Entry{Key: kv[0], Value: kv[1]})
+	}
+	return entries, nil
+}
+
+// StrBigIntMap decodes a map from strings to integers, e.g., str -> int in Quint,
+// which is the usual shape of balances.
+func StrBigIntMap(v gjson.Result) (map[string]*big.Int, error) {
+	entries, err := Map(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]*big.Int, len(entries))
+	for _, e := range entries {
+		if e.Key.Type != gjson.String {
+			return nil, fmt.Errorf("expected a string key, found: %s", e.Key.Raw)
+		}
+		i, err := BigInt(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		m[e.Key.String()] = i
+	}
+	return m, nil
+}
+
+// Show writes an ITF value in the syntax of Quint, e.g., Set(1, 2) for
+// {"#set": [1, 2]}, which is easier to read than ITF JSON.
+func Show(v gjson.Result) string {
+	switch {
+	case !v.Exists():
+		return "<missing>"
+	case v.Type == gjson.Number:
+		return v.Raw
+	case v.Type == gjson.String:
+		return v.Raw
+	case v.Type != gjson.JSON:
+		return v.String()
+	case v.IsArray():
+		return "[" + showElems(v.Array()) + "]"
+	}
+	if i := v.Get("\\#bigint"); i.Exists() {
+		return i.String()
+	}
+	if elems := v.Get("\\#set"); elems.Exists() {
+		return "Set(" + showElems(elems.Array()) + ")"
+	}
+	if elems := v.Get("\\#tup"); elems.Exists() {
+		return "(" + showElems(elems.Array()) + ")"
+	}
+	if entries := v.Get("\\#map"); entries.Exists() {
+		var pairs []string
+		for _, e := range entries.Array() {
+			pairs = append(pairs, Show(e.Get("0"))+" -> "+Show(e.Get("1")))
+		}
+		return "Map(" + strings.Join(pairs, ", ") + ")"
+	}
+	if u := v.Get("\\#unserializable"); u.Exists() {
+		return "<" + u.String() + ">"
+	}
+	var fields []string
+	v.ForEach(func(key, value gjson.Result) bool {
+		fields = append(fields, key.String()+": "+Show(value))
+		return true
+	})
+	if len(fields) == 0 {
+		return "{}"
+	}
+	return "{ " + strings.Join(fields, ", ") + " }"
+}
+
+func showElems(elems []gjson.Result) string {
+	shown := make([]string, len(elems))
+	for i, e := range elems {
+		shown[i] = Show(e)
+	}
+	return strings.Join(shown, ", ")
+}
+
+// Canonical returns the JSON of a value, in which the elements of sets, the
+// entries of maps, and the fields of records are sorted, and all integers are
+// written as {"#bigint": "..."}. Two values are equal iff their canonical
+// forms are equal, no matter in which order a system under test wrote them.
+func Canonical(v gjson.Result) (string, error) {
+	switch {
+	case v.Type == gjson.Number:
+		return canonicalBigInt(v)
+	case v.IsArray():
+		elems, err := canonicalElems(v.Array())
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(elems, ",") + "]", nil
+	case !v.IsObject():
+		return v.Raw, nil
+	}
+	obj := v.Map()
+	if _, found := obj["#bigint"]; found {
+		return canonicalBigInt(v)
+	}
+	if elems, found := obj["#set"]; found {
+		return canonicalTagged("#set", elems.Array())
+	}
+	if pairs, found := obj["#map"]; found {
+		return canonicalTagged("#map", pairs.Array())
+	}
+	if elems, found := obj["#tup"]; found {
+		tuple, err := canonicalElems(elems.Array())
+		if err != nil {
+			return "", err
+		}
+		return `{"#tup":[` + strings.Join(tuple, ",") + "]}", nil
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		field, err := Canonical(obj[name])
+		if err != nil {
+			return "", err
+		}
+		key, _ := json.Marshal(name)
+		fields = append(fields, string(key)+":"+field)
+	}
+	return "{" + strings.Join(fields, ",") + "}", nil
+}
+
+func canonicalBigInt(v gjson.Result) (string, error) {
+	i, err := BigInt(v)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`{"#bigint":"%s"}`, i), nil
+}
+
+// the canonical forms of the elements of a set or of a map, in order
+func canonicalTagged(tag string, elems []gjson.Result) (string, error) {
+	canonical, err := canonicalElems(elems)
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(canonical)
+	return fmt.Sprintf(`{"%s":[%s]}`, tag, strings.Join(canonical, ",")), nil
+}
+
+func canonicalElems(elems []gjson.Result) ([]string, error) {
+	canonical := make([]string, 0, len(elems))
+	for _, e := range elems {
+		c, err := Canonical(e)
+		if err != nil {
+			return nil, err
+		}
+		canonical = append(canonical, c)
+	}
+	return canonical, nil
+}
+
+// the elements of an object that is tagged with a single field, e.g., #set
+func tagged(v gjson.Result, tag string) ([]gjson.Result, error) {
+	elems := v.Get("\\" + tag)
+	if !v.IsObject() || !elems.IsArray() {
+		return nil, fmt.Errorf("expected %s, found: %s", tag, v.Raw)
+	}
+	return elems.Array(), nil
+}
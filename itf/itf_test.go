@@ -0,0 +1,637 @@
+package itf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+const quintTrace = `{
+  "#meta": { "format": "ITF", "source": "coinsTest.qnt" },
+  "vars": [ "balances", "denoms", "pair" ],
+  "states": [
+    {
+      "#meta": { "index": 0 },
+      "balances": { "#map": [ [ "atom", { "#bigint": "10" } ], [ "osmo", 3 ] ] },
+      "denoms": { "#set": [ "atom", "osmo" ] },
+      "pair": { "#tup": [ "atom", { "#bigint": "-115792089237316195423570985008687907853269984665640564039457584007913129639935" } ] }
+    }
+  ]
+}`
+
+func TestParseQuintTrace(t *testing.T) {
+	trace, err := Parse([]byte(quintTrace))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"balances", "denoms", "pair"}, trace.Vars)
+	require.Len(t, trace.States, 1)
+
+	balances, err := StrBigIntMap(trace.States[0].Get("balances"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*big.Int{"atom": big.NewInt(10), "osmo": big.NewInt(3)}, balances)
+
+	denoms, err := Set(trace.States[0].Get("denoms"))
+	require.NoError(t, err)
+	require.Len(t, denoms, 2)
+	assert.Equal(t, "osmo", denoms[1].String())
+
+	pair, err := Tuple(trace.States[0].Get("pair"))
+	require.NoError(t, err)
+	require.Len(t, pair, 2)
+	i, err := BigInt(pair[1])
+	require.NoError(t, err)
+	expected := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	assert.Equal(t, expected.Neg(expected), i)
+}
+
+func TestDecodeNumbers(t *testing.T) {
+	i, err := Int64(gjson.Parse(`{ "#bigint": "-7" }`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-7), i)
+
+	u, err := Uint64(gjson.Parse(`{ "#bigint": "18446744073709551615" }`))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u)
+
+	// 0.25, and 1.5 in a trace that predates the causes of errors
+	d, err := Dec(gjson.Parse(`{ "error": "", "value": { "#bigint": "250000000000000000" } }`))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(250000000000000000), d)
+	d, err = Dec(gjson.Parse(`{ "error": false, "value": { "#bigint": "1500000000000000000" } }`))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(1500000000000000000), d)
+}
+
+func TestParseApalacheTrace(t *testing.T) {
+	trace, err := Parse([]byte("[" + quintTrace + "]"))
+	require.NoError(t, err)
+	assert.Len(t, trace.States, 1)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := Parse([]byte(`{ "vars": [] }`))
+	assert.Error(t, err, "a trace without states")
+
+	_, err = BigInt(gjson.Parse(`"10"`))
+	assert.Error(t, err, "a string is not an integer")
+
+	_, err = Int64(gjson.Parse(`{ "#bigint": "9223372036854775808" }`))
+	assert.EqualError(t, err, "the integer 9223372036854775808 does not fit into int64")
+
+	_, err = Uint64(gjson.Parse(`-1`))
+	assert.EqualError(t, err, "the integer -1 does not fit into uint64")
+
+	_, err = Dec(gjson.Parse(`{ "error": "overflow", "value": 0 }`))
+	assert.EqualError(t, err, `the decimal is an error: "overflow"`)
+
+	_, err = Dec(gjson.Parse(`{ "error": true, "value": 0 }`))
+	assert.Error(t, err, "an erroneous decimal of the traces that predate the causes")
+
+	_, err = Dec(gjson.Parse(`{ "#bigint": "1" }`))
+	assert.Error(t, err, "an integer is not a decimal")
+
+	_, err = Set(gjson.Parse(`{ "#map": [] }`))
+	assert.Error(t, err, "a map is not a set")
+
+	_, err = Map(gjson.Parse(`{ "#map": [ [ "atom" ] ] }`))
+	assert.Error(t, err, "a key without a value")
+
+	_, err = StrBigIntMap(gjson.Parse(`{ "#map": [ [ 1, 2 ] ] }`))
+	assert.Error(t, err, "a non-string key")
+}
+
+func TestCanonical(t *testing.T) {
+	c1, err := Canonical(gjson.Parse(`{
+	  "b": { "#set": [ 2, { "#bigint": "1" } ] },
+	  "a": { "#map": [ [ "osmo", 3 ], [ "atom", { "#bigint": "010" } ] ] }
+	}`))
+	require.NoError(t, err)
+	c2, err := Canonical(gjson.Parse(`{
+	  "a": { "#map": [ [ "atom", { "#bigint": "10" } ], [ "osmo", { "#bigint": "3" } ] ] },
+	  "b": { "#set": [ { "#bigint": "1" }, { "#bigint": "2" } ] }
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, c1, c2)
+	assert.Equal(t, `{"a":{"#map":[["atom",{"#bigint":"10"}],["osmo",{"#bigint":"3"}]]},`+
+		`"b":{"#set":[{"#bigint":"1"},{"#bigint":"2"}]}}`, c2)
+
+	c3, err := Canonical(gjson.Parse(`{ "#tup": [ 2, 1 ] }`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"#tup":[{"#bigint":"2"},{"#bigint":"1"}]}`, c3)
+
+	_, err = Canonical(gjson.Parse(`{ "#bigint": "ten" }`))
+	assert.Error(t, err)
+}
+
+func TestFingerprint(t *testing.T) {
+	values := []string{
+		`{ "b": { "#set": [ 2, { "#bigint": "1" } ] }, "a": { "#map": [ [ "osmo", 3 ], [ "atom", { "#bigint": "010" } ] ] } }`,
+		`{ "a": { "#map": [ [ "atom", { "#bigint": "10" } ], [ "osmo", { "#bigint": "3" } ] ] }, "b": { "#set": [ 1, 2 ] } }`,
+		`{ "a": { "#map": [ [ "atom", 10 ], [ "osmo", 3 ] ] }, "b": { "#set": [ 1, 3 ] } }`,
+		`{ "a": { "#map": [ [ "atom", 10 ], [ "osmo", 3 ] ] }, "b": [ 1, 2 ] }`,
+		`{ "a": { "#map": [ [ "atom", 10 ], [ "osmo", 3 ] ] }, "b": { "#tup": [ 1, 2 ] } }`,
+		`{ "ab": "c" }`,
+		`{ "a": "bc" }`,
+		`"1"`,
+		`1`,
+	}
+	// two values have the same fingerprint iff they have the same canonical form
+	for i, x := range values {
+		for j, y := range values {
+			fx, err := FingerprintOf(gjson.Parse(x))
+			require.NoError(t, err)
+			fy, err := FingerprintOf(gjson.Parse(y))
+			require.NoError(t, err)
+			cx, _ := Canonical(gjson.Parse(x))
+			cy, _ := Canonical(gjson.Parse(y))
+			assert.Equal(t, cx == cy, fx == fy, "values %d and %d", i, j)
+		}
+	}
+	f, err := FingerprintOf(gjson.Parse(`[]`))
+	require.NoError(t, err)
+	assert.Len(t, f.String(), 64)
+
+	_, err = FingerprintOf(gjson.Parse(`{ "x": { "#set": [ { "#bigint": "ten" } ] } }`))
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	diffs, err := Diff(gjson.Parse(`{
+	  "balances": { "#map": [ [ "alice", 10 ], [ "bob", 3 ] ] },
+	  "denoms": { "#set": [ "atom", "osmo" ] },
+	  "pair": { "#tup": [ "atom", 1 ] },
+	  "height": 7
+	}`), gjson.Parse(`{
+	  "balances": { "#map": [ [ "bob", 3 ], [ "alice", 11 ], [ "carol", 1 ] ] },
+	  "denoms": { "#set": [ "osmo", "juno" ] },
+	  "pair": { "#tup": [ "atom", 1, 2 ] },
+	  "height": { "#bigint": "7" }
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Difference{
+		{Path: `.balances["alice"]`, Expected: "10", Actual: "11"},
+		{Path: `.balances["carol"]`, Expected: "<missing>", Actual: "1"},
+		{Path: ".denoms", Expected: `Set("atom")`, Actual: `Set("juno")`},
+		{Path: ".pair", Expected: `("atom", 1)`, Actual: `("atom", 1, 2)`},
+	}, diffs)
+
+	diffs, err = Diff(gjson.Parse(`[ 1, 2 ]`), gjson.Parse(`[ 1, { "#bigint": "2" } ]`))
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	diffs, err = Diff(gjson.Parse(`[ 1, 2 ]`), gjson.Parse(`{ "#set": [ 1, 2 ] }`))
+	require.NoError(t, err)
+	assert.Equal(t, []Difference{{Expected: "[1, 2]", Actual: "Set(1, 2)"}}, diffs)
+}
+
+func TestReadCompressedFile(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write([]byte(quintTrace))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	zw, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	zst := zw.EncodeAll([]byte(quintTrace), nil)
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"trace.itf.json":     []byte(quintTrace),
+		"trace.itf.json.gz":  gz.Bytes(),
+		"trace.itf.json.zst": zst,
+	} {
+		filename := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(filename, data, 0o644))
+		trace, err := ReadFile(filename)
+		require.NoError(t, err, name)
+		assert.Equal(t, []string{"balances", "denoms", "pair"}, trace.Vars, name)
+		assert.Equal(t, "osmo", trace.States[0].Get("denoms.\\#set.1").String(), name)
+	}
+	assert.Equal(t, "trace.itf.json", Uncompressed("trace.itf.json.zst"))
+	assert.Equal(t, "trace.itf.json", Uncompressed("trace.itf.json"))
+
+	// a truncated archive
+	filename := filepath.Join(dir, "truncated.itf.json.gz")
+	require.NoError(t, os.WriteFile(filename, gz.Bytes()[:gz.Len()/2], 0o644))
+	_, err = ReadFile(filename)
+	assert.ErrorContains(t, err, "error decompressing")
+}
+
+func TestMapFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "trace.itf.json")
+	require.NoError(t, os.WriteFile(filename, []byte(quintTrace), 0o644))
+	trace, release, err := MapFile(filename)
+	require.NoError(t, err)
+	expected, err := ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, expected, trace)
+	release()
+
+	// the compressed traces are read, and they have nothing to release
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err = w.Write([]byte(quintTrace))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	compressed := filepath.Join(dir, "trace.itf.json.gz")
+	require.NoError(t, os.WriteFile(compressed, gz.Bytes(), 0o644))
+	trace, release, err = MapFile(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, expected, trace)
+	release()
+
+	// an empty file is not mapped, but it is not a trace either
+	empty := filepath.Join(dir, "empty.itf.json")
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+	_, _, err = MapFile(empty)
+	assert.ErrorContains(t, err, "invalid JSON")
+
+	_, _, err = MapFile(filepath.Join(dir, "missing.itf.json"))
+	assert.ErrorContains(t, err, "error opening file")
+}
+
+func TestMapData(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write([]byte(quintTrace))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	for name, content := range map[string][]byte{
+		"trace.itf.json":    []byte(quintTrace),
+		"trace.itf.json.gz": gz.Bytes(),
+		"empty.itf.json":    nil,
+	} {
+		filename := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(filename, content, 0o644))
+		data, release, err := MapData(filename)
+		require.NoError(t, err, name)
+		if content == nil {
+			assert.Empty(t, data, name)
+		} else {
+			assert.Equal(t, quintTrace, string(data), name)
+		}
+		release()
+	}
+
+	_, _, err = MapData(filepath.Join(dir, "missing.itf.json"))
+	assert.ErrorContains(t, err, "error opening file")
+}
+
+func TestParseVars(t *testing.T) {
+	trace, err := ParseVars([]byte(quintTrace), []string{"pair", "denoms"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"denoms", "pair"}, trace.Vars)
+	require.Len(t, trace.States, 1)
+	assert.Equal(t, `{"#meta":{ "index": 0 },"denoms":{ "#set": [ "atom", "osmo" ] },`+
+		`"pair":{ "#tup": [ "atom", { "#bigint": "-115792089237316195423570985008687907853269984665640564039457584007913129639935" } ] }}`,
+		trace.States[0].Raw)
+	assert.False(t, trace.States[0].Get("balances").Exists())
+
+	all, err := ParseVars([]byte(quintTrace), nil)
+	require.NoError(t, err)
+	expected, err := Parse([]byte(quintTrace))
+	require.NoError(t, err)
+	assert.Equal(t, expected, all)
+
+	// the states do not refer to the data, which the caller may reuse
+	data := []byte(quintTrace)
+	trace, err = ParseVars(data, []string{"denoms"})
+	require.NoError(t, err)
+	for i := range data {
+		data[i] = ' '
+	}
+	assert.Equal(t, []string{"denoms"}, trace.Vars)
+	assert.Equal(t, "osmo", trace.States[0].Get("denoms.\\#set.1").String())
+
+	_, err = ParseVars([]byte("{"), []string{"pair"})
+	assert.ErrorContains(t, err, "invalid JSON")
+}
+
+func TestProject(t *testing.T) {
+	trace, err := Parse([]byte(quintTrace))
+	require.NoError(t, err)
+	projected := trace.Project("pair", "missing")
+	assert.Equal(t, []string{"pair"}, projected.Vars)
+	assert.Equal(t, SelectVars(trace, []string{"pair"}), projected)
+
+	data, err := json.Marshal(projected)
+	require.NoError(t, err)
+	assert.Equal(t, `{"vars":["pair"],"states":[{"#meta":{"index":0},`+
+		`"pair":{"#tup":["atom",{"#bigint":"-115792089237316195423570985008687907853269984665640564039457584007913129639935"}]}}]}`,
+		string(data))
+	// the projection is a trace of its own
+	reparsed, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, projected.Vars, reparsed.Vars)
+	assert.Equal(t, "atom", reparsed.States[0].Get("pair.#tup.0").String())
+
+	data, err = json.Marshal(trace.Project())
+	require.NoError(t, err)
+	assert.Equal(t, `{"vars":[],"states":[{"#meta":{"index":0}}]}`, string(data))
+
+	_, err = json.Marshal(Trace{States: []gjson.Result{gjson.Parse("1")}})
+	assert.ErrorContains(t, err, "state 0: expected an object")
+}
+
+func TestUnmarshal(t *testing.T) {
+	type pair struct {
+		Denom  string
+		Amount string `itf:",bigint"`
+	}
+	type state struct {
+		Balances map[string]*big.Int `itf:"balances"`
+		Denoms   []string            `itf:"denoms,set"`
+		DenomSet map[string]bool     `itf:"denoms,set"`
+		Pair     pair                `itf:"pair,tup"`
+		Index    int                 `itf:"#meta"`
+		Missing  string              `itf:"missing,optional"`
+		Skipped  func()              `itf:"-"`
+		Raw      gjson.Result        `itf:"pair"`
+	}
+	trace, err := Parse([]byte(quintTrace))
+	require.NoError(t, err)
+	var s state
+	err = Unmarshal(trace.States[0], &s)
+	assert.EqualError(t, err, "#meta: expected a big integer, found: { \"index\": 0 }")
+
+	type indexed struct {
+		Meta struct {
+			Index int
+		} `itf:"#meta"`
+	}
+	var meta indexed
+	require.NoError(t, Unmarshal(trace.States[0], &meta))
+	assert.Equal(t, 0, meta.Meta.Index)
+
+	s = state{}
+	state0 := gjson.Parse(strings.Replace(trace.States[0].Raw, `"#meta": { "index": 0 }`, `"#meta": 3`, 1))
+	require.NoError(t, Unmarshal(state0, &s))
+	assert.Equal(t, map[string]*big.Int{"atom": big.NewInt(10), "osmo": big.NewInt(3)}, s.Balances)
+	assert.Equal(t, []string{"atom", "osmo"}, s.Denoms)
+	assert.Equal(t, map[string]bool{"atom": true, "osmo": true}, s.DenomSet)
+	assert.Equal(t, "atom", s.Pair.Denom)
+	assert.Equal(t, "-115792089237316195423570985008687907853269984665640564039457584007913129639935", s.Pair.Amount)
+	assert.Equal(t, 3, s.Index)
+	assert.True(t, s.Raw.Get("\\#tup").IsArray())
+
+	// the integers that do not fit are errors, not truncated
+	var small struct {
+		Pair struct {
+			Denom  string
+			Amount int64
+		} `itf:",tup"`
+	}
+	err = Unmarshal(trace.States[0], &small)
+	assert.ErrorContains(t, err, "pair: 1: the integer -115792089237316195423570985008687907853269984665640564039457584007913129639935 does not fit into int64")
+
+	var missing struct{ Supply *big.Int }
+	assert.EqualError(t, Unmarshal(trace.States[0], &missing), `missing field "Supply"`)
+	assert.Error(t, Unmarshal(trace.States[0], missing))
+
+	// an exact name comes first, and several names that only differ in case
+	// are ambiguous, no matter in which order the record lists them
+	var cased struct{ Amount, AMOUNT int }
+	record := gjson.Parse(`{ "AMOUNT": 2, "Amount": 1 }`)
+	require.NoError(t, Unmarshal(record, &cased))
+	assert.Equal(t, 1, cased.Amount)
+	assert.Equal(t, 2, cased.AMOUNT)
+	var lower struct {
+		Amount int `itf:"amount"`
+	}
+	for i := 0; i < 10; i++ {
+		err = Unmarshal(record, &lower)
+		assert.EqualError(t, err, `ambiguous field "amount": AMOUNT, Amount`)
+	}
+
+	// the options of a tag are checked
+	var unknown struct {
+		Amount string `itf:"amount,bigInt"`
+	}
+	err = Unmarshal(gjson.Parse(`{ "amount": 1 }`), &unknown)
+	assert.EqualError(t, err, `Amount: unknown option "bigInt" in the tag "amount,bigInt"`)
+}
+
+func TestShow(t *testing.T) {
+	for raw, expected := range map[string]string{
+		`{ "#bigint": "-12" }`:                            "-12",
+		`{ "#set": [ 1, { "#bigint": "2" } ] }`:           "Set(1, 2)",
+		`{ "#tup": [ "a", true ] }`:                       `("a", true)`,
+		`{ "#map": [ [ "alice", { "#bigint": "3" } ] ] }`: `Map("alice" -> 3)`,
+		`{ "kind": "send", "seq": { "#bigint": "1" } }`:   `{ kind: "send", seq: 1 }`,
+		`[ { "#set": [] }, {} ]`:                          "[Set(), {}]",
+		`{ "#unserializable": "λx. x" }`:                  "<λx. x>",
+	} {
+		assert.Equal(t, expected, Show(gjson.Parse(raw)), raw)
+	}
+}
+
+func TestShard(t *testing.T) {
+	var files []string
+	for i := 0; i < 100; i++ {
+		files = append(files, fmt.Sprintf("traces/run%d.itf.json", i))
+	}
+	shards := make([]Shard, 3)
+	for i := range shards {
+		require.NoError(t, shards[i].Set(fmt.Sprintf("%d/3", i+1)))
+	}
+	// every trace and every state is in exactly one shard
+	for i, filename := range files {
+		traces, states := 0, 0
+		for _, s := range shards {
+			if s.Has(filename) {
+				traces++
+				// the directory does not matter
+				assert.True(t, s.Has(filepath.Join("/tmp", filepath.Base(filename))))
+			}
+			if s.HasState(i) {
+				states++
+			}
+		}
+		assert.Equal(t, 1, traces, filename)
+		assert.Equal(t, 1, states, i)
+	}
+	var all Shard
+	assert.True(t, all.Has(files[0]) && all.HasState(1))
+	assert.Equal(t, "2/3", shards[1].String())
+
+	for _, s := range []string{"", "3", "0/3", "4/3", "1/0", "a/b", "1/3x"} {
+		_, err := ParseShard(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestTraceDir(t *testing.T) {
+	t.Setenv(TraceDirEnv, "")
+	assert.Equal(t, "../test-inputs", TraceDir("../test-inputs"), "unset")
+	t.Setenv(TraceDirEnv, "/tmp/traces")
+	assert.Equal(t, "/tmp/traces", TraceDir("../test-inputs"), "set")
+}
+
+func TestList(t *testing.T) {
+	trace, err := Parse([]byte(`{"vars":["opcode","opResult"],"states":[
+		{"opcode":"newDec","opResult":{"error":false}},
+		{"opcode":"quo","opResult":{"error":"divisionByZero"}},
+		{"opcode":"add","opResult":{"error":""}},
+		{"opcode":"mul","opResult":{"error":true}}]}`))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, List(&out, "t.itf.json", trace, "opcode", "opResult.error"))
+	assert.Equal(t, `t.itf.json: 4 states, 2 errors
+    0  newDec
+    1  quo  error: divisionByZero
+    2  add
+    3  mul  error: true
+`, out.String())
+}
+
+func TestLint(t *testing.T) {
+	assert.Empty(t, Lint([]byte(`{"#meta":{"format":"ITF"},"vars":["x","m"],"states":[
+		{"#meta":{"index":0},"x":{"#bigint":"-12"},"m":{"#map":[[1,{"#set":[]}]]}},
+		{"#meta":{"index":1},"x":3,"m":{"#map":[]}}]}`)))
+	assert.Empty(t, Lint([]byte(`[{"vars":[],"states":[{}]}]`)), "a trace of Apalache")
+
+	for trace, expected := range map[string][]string{
+		`{"vars":["x"],"states":[{"x":1}`: {"invalid JSON"},
+		`{"states":[]}`:                   {"vars: expected an array, found: nothing"},
+		`{"vars":["x","x"],"states":{}}`: {
+			"vars[1]: duplicate variable x",
+			"states: expected an array, found: an object",
+		},
+		`{"vars":["x","y"],"states":[{"x":1,"z":2}]}`: {
+			"states[0]: variable z is not in vars",
+			"states[0]: missing variable y",
+		},
+		`{"vars":["x"],"states":[{"x":{"#bigint":"12a"}},{"x":{"#bigint":12}}]}`: {
+			`states[0].x: expected the digits of a #bigint, found: "12a"`,
+			"states[1].x: expected the digits of a #bigint, found: 12",
+		},
+		`{"vars":["x"],"states":[{"x":{"#map":[[1]]}},{"x":{"#set":1}},{"x":{"#sets":[]}}]}`: {
+			"states[0].x[0]: expected a key-value pair, found: [1]",
+			"states[1].x: expected the elements of a #set, found: a number",
+			"states[2].x: unknown tag #sets",
+		},
+		`{"vars":["x"],"states":[{"#meta":{"index":0},"x":1},{"#meta":{"index":2},"x":1}]}`: {
+			"states[1].#meta.index: expected the index 1, found: 2",
+		},
+	} {
+		var actual []string
+		for _, e := range Lint([]byte(trace)) {
+			actual = append(actual, e.Error())
+		}
+		assert.Equal(t, expected, actual, trace)
+	}
+}
+
+// the traces of the examples are linted, before they confuse a harness
+func TestLintCorpus(t *testing.T) {
+	files, err := filepath.Glob("../*/test-inputs*/*.itf.json")
+	require.NoError(t, err)
+	for _, filename := range files {
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		for _, e := range Lint(data) {
+			t.Errorf("%s: %v", filename, e)
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	branches := func(bs ...Branch) map[Branch]bool {
+		m := make(map[Branch]bool)
+		for _, b := range bs {
+			m[b] = true
+		}
+		return m
+	}
+	add, addErr, quo := Branch{"add", false}, Branch{"add", true}, Branch{"quo", false}
+	subset := Reduce([]CoveredTrace{
+		{Name: "a", Size: 10, Branches: branches(add)},
+		{Name: "b", Size: 50, Branches: branches(add, quo)},
+		{Name: "c", Size: 20, Branches: branches(add, quo)},
+		{Name: "d", Size: 5, Branches: branches(addErr)},
+		{Name: "e", Size: 5, Branches: branches(addErr)},
+	})
+	var names []string
+	for _, trace := range subset {
+		names = append(names, trace.Name)
+	}
+	// c is smaller than b, and d comes before e
+	assert.Equal(t, []string{"c", "d"}, names)
+	assert.Empty(t, Reduce(nil))
+
+	trace, err := Parse([]byte(`{"vars":["opcode","opResult"],"states":[
+		{"opcode":"add","opResult":{"error":""}},
+		{"opcode":"add","opResult":{"error":"overflowBitLen"}},
+		{"opcode":"add","opResult":{"error":""}}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, branches(add, addErr), Branches(trace, "opcode", "opResult.error"))
+	assert.Equal(t, "add: error", addErr.String())
+}
+
+// An oracle in the test binary itself, which adds the numbers x and y of a
+// step, see TestOracle. It only runs as a subprocess of TestOracle.
+func TestOracleHelperProcess(t *testing.T) {
+	if os.Getenv("ITF_ORACLE_HELPER") != "1" {
+		t.Skip("runs as the oracle of TestOracle")
+	}
+	fmt.Printf(`{"protocol": %q, "name": "adder"}`+"\n", OracleProtocol)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var request struct {
+			ID   int64 `json:"id"`
+			Step struct {
+				Op   string `json:"op"`
+				X, Y int
+			} `json:"step"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			fmt.Printf(`{"id": 0, "error": %q}`+"\n", err.Error())
+			continue
+		}
+		switch request.Step.Op {
+		case "add":
+			fmt.Printf(`{"id": %d, "result": {"sum": %d}}`+"\n", request.ID, request.Step.X+request.Step.Y)
+		case "skip":
+			fmt.Printf(`{"id": %d, "result": {"sum": 0}}`+"\n", request.ID+1)
+		default:
+			fmt.Printf(`{"id": %d, "unsupported": true}`+"\n", request.ID)
+		}
+	}
+	os.Exit(0)
+}
+
+func TestOracle(t *testing.T) {
+	t.Setenv("ITF_ORACLE_HELPER", "1")
+	oracle, err := StartOracle(os.Args[0], "-test.run=^TestOracleHelperProcess$")
+	require.NoError(t, err)
+	assert.Equal(t, "adder", oracle.Name())
+
+	reply, err := oracle.Ask(map[string]any{"op": "add", "x": 2, "y": 3})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sum": 5}`, string(reply.Result))
+	reply, err = oracle.Ask(map[string]any{"op": "mul", "x": 2, "y": 3})
+	require.NoError(t, err)
+	assert.True(t, reply.Unsupported)
+	_, err = oracle.Ask(map[string]any{"op": "skip"})
+	assert.ErrorContains(t, err, "answer 4 to request 3")
+	require.NoError(t, oracle.Close())
+
+	// a process that does not talk the protocol
+	_, err = StartOracle("sh", "-c", "echo hello")
+	assert.Error(t, err)
+}
@@ -0,0 +1,537 @@
+// Package itfpb encodes ITF traces in the protobuf representation of
+// itf.proto, and decodes them back into ITF JSON, so the harnesses read them
+// in the same way as the traces in JSON.
+//
+// The messages are encoded with protowire by hand, so the package does not
+// depend on generated code. Since the messages follow itf.proto, the traces
+// are read in other languages with the code generated from itf.proto.
+package itfpb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/tidwall/gjson"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// the field numbers of itf.proto
+const (
+	traceMeta   = 1
+	traceVars   = 2
+	traceStates = 3
+
+	stateMeta = 1
+	stateVars = 2
+
+	fieldName  = 1
+	fieldValue = 2
+
+	valueBool           = 1
+	valueStr            = 2
+	valueInt            = 3
+	valueBigInt         = 4
+	valueList           = 5
+	valueRecord         = 6
+	valueTuple          = 7
+	valueSet            = 8
+	valueMap            = 9
+	valueUnserializable = 10
+
+	bigIntNegative  = 1
+	bigIntMagnitude = 2
+
+	elemsElems   = 1
+	recordFields = 1
+	mapEntries   = 1
+	entryKey     = 1
+	entryValue   = 2
+)
+
+// ReadFile reads a trace from a file in the protobuf representation.
+func ReadFile(filename string) (itf.Trace, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return itf.Trace{}, fmt.Errorf("error opening file: %v", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a trace from its protobuf representation.
+func Parse(data []byte) (itf.Trace, error) {
+	return ParseVars(data, nil)
+}
+
+// ParseVars decodes a trace like Parse, but it keeps only the state variables
+// vars, see itf.ParseVars. The values of the other variables are skipped
+// without being decoded. If vars is nil, all state variables are kept.
+func ParseVars(data []byte, vars []string) (itf.Trace, error) {
+	var keep map[string]bool
+	if vars != nil {
+		keep = make(map[string]bool)
+		for _, v := range vars {
+			keep[v] = true
+		}
+	}
+	js, err := toJSON(data, keep)
+	if err != nil {
+		return itf.Trace{}, err
+	}
+	return itf.Parse(js)
+}
+
+// FromJSON encodes a trace in ITF JSON as a Trace message.
+func FromJSON(data []byte) ([]byte, error) {
+	if !gjson.ValidBytes(data) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+	root := gjson.ParseBytes(data)
+	if root.IsArray() {
+		// Apalache writes a trace wrapped in an array
+		root = root.Get("0")
+	}
+	if !root.Get("states").IsArray() {
+		return nil, fmt.Errorf("expected an ITF trace with states")
+	}
+	var b []byte
+	if meta := root.Get("\\#meta"); meta.Exists() {
+		b = protowire.AppendTag(b, traceMeta, protowire.BytesType)
+		b = protowire.AppendString(b, meta.Raw)
+	}
+	for _, v := range root.Get("vars").Array() {
+		b = protowire.AppendTag(b, traceVars, protowire.BytesType)
+		b = protowire.AppendString(b, v.String())
+	}
+	for _, s := range root.Get("states").Array() {
+		state, err := MarshalState(s)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, traceStates, protowire.BytesType)
+		b = protowire.AppendBytes(b, state)
+	}
+	return b, nil
+}
+
+// ToJSON decodes a Trace message into ITF JSON.
+func ToJSON(data []byte) ([]byte, error) {
+	return toJSON(data, nil)
+}
+
+// decode a Trace message into ITF JSON with the state variables in keep,
+// or with all state variables, if keep is nil
+func toJSON(data []byte, keep map[string]bool) ([]byte, error) {
+	var meta string
+	var vars []string
+	var states [][]byte
+	err := fields(data, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		switch num {
+		case traceMeta:
+			meta = string(v)
+		case traceVars:
+			if keep == nil || keep[string(v)] {
+				vars = append(vars, string(v))
+			}
+		case traceStates:
+			state, err := unmarshalState(v, keep)
+			if err != nil {
+				return err
+			}
+			states = append(states, []byte(state.Raw))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var w bytes.Buffer
+	w.WriteString("{")
+	if meta != "" {
+		w.WriteString(`"#meta":` + meta + ",")
+	}
+	if vars == nil {
+		vars = []string{}
+	}
+	v, _ := json.Marshal(vars)
+	w.WriteString(`"vars":` + string(v) + `,"states":[`)
+	w.Write(bytes.Join(states, []byte(",")))
+	w.WriteString("]}")
+	return w.Bytes(), nil
+}
+
+// MarshalState encodes a state of a trace as a State message,
+// e.g., to send the states of a trace one by one.
+func MarshalState(state gjson.Result) ([]byte, error) {
+	if !state.IsObject() {
+		return nil, fmt.Errorf("expected a state, found: %s", state.Raw)
+	}
+	var b []byte
+	var err error
+	state.ForEach(func(name, value gjson.Result) bool {
+		if name.String() == "#meta" {
+			b = protowire.AppendTag(b, stateMeta, protowire.BytesType)
+			b = protowire.AppendString(b, value.Raw)
+			return true
+		}
+		var field []byte
+		if field, err = marshalField(name.String(), value); err != nil {
+			return false
+		}
+		b = protowire.AppendTag(b, stateVars, protowire.BytesType)
+		b = protowire.AppendBytes(b, field)
+		return true
+	})
+	return b, err
+}
+
+// UnmarshalState decodes a State message into ITF JSON.
+func UnmarshalState(data []byte) (gjson.Result, error) {
+	return unmarshalState(data, nil)
+}
+
+// decode a State message with the state variables in keep, or with all state
+// variables, if keep is nil
+func unmarshalState(data []byte, keep map[string]bool) (gjson.Result, error) {
+	var w bytes.Buffer
+	w.WriteString("{")
+	first := true
+	err := fields(data, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		if num != stateMeta && num != stateVars {
+			return nil
+		}
+		if num == stateVars && keep != nil {
+			name, _, err := splitField(v)
+			if err != nil {
+				return err
+			}
+			if !keep[string(name)] {
+				return nil
+			}
+		}
+		if !first {
+			w.WriteString(",")
+		}
+		first = false
+		if num == stateMeta {
+			w.WriteString(`"#meta":`)
+			w.Write(v)
+			return nil
+		}
+		return writeField(&w, v)
+	})
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	w.WriteString("}")
+	return gjson.ParseBytes(w.Bytes()), nil
+}
+
+func marshalField(name string, value gjson.Result) ([]byte, error) {
+	v, err := marshalValue(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	b := protowire.AppendTag(nil, fieldName, protowire.BytesType)
+	b = protowire.AppendString(b, name)
+	b = protowire.AppendTag(b, fieldValue, protowire.BytesType)
+	return protowire.AppendBytes(b, v), nil
+}
+
+// encode an ITF value as a Value message
+func marshalValue(v gjson.Result) ([]byte, error) {
+	switch {
+	case v.Type == gjson.True || v.Type == gjson.False:
+		b := protowire.AppendTag(nil, valueBool, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v.Bool())), nil
+	case v.Type == gjson.String:
+		b := protowire.AppendTag(nil, valueStr, protowire.BytesType)
+		return protowire.AppendString(b, v.String()), nil
+	case v.Type == gjson.Number:
+		// older versions of Quint write small integers as JSON numbers
+		return marshalBigInt(v)
+	case v.IsArray():
+		return marshalElems(valueList, v.Array())
+	case !v.IsObject():
+		return nil, fmt.Errorf("unexpected value: %s", v.Raw)
+	}
+	obj := v.Map()
+	if len(obj) == 1 {
+		for tag, inner := range obj {
+			switch tag {
+			case "#bigint":
+				return marshalBigInt(v)
+			case "#tup":
+				return marshalElems(valueTuple, inner.Array())
+			case "#set":
+				return marshalElems(valueSet, inner.Array())
+			case "#map":
+				return marshalMap(v)
+			case "#unserializable":
+				b := protowire.AppendTag(nil, valueUnserializable, protowire.BytesType)
+				return protowire.AppendString(b, inner.String()), nil
+			}
+		}
+	}
+	var record []byte
+	var err error
+	v.ForEach(func(name, value gjson.Result) bool {
+		var field []byte
+		if field, err = marshalField(name.String(), value); err != nil {
+			return false
+		}
+		record = protowire.AppendTag(record, recordFields, protowire.BytesType)
+		record = protowire.AppendBytes(record, field)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	b := protowire.AppendTag(nil, valueRecord, protowire.BytesType)
+	return protowire.AppendBytes(b, record), nil
+}
+
+func marshalBigInt(v gjson.Result) ([]byte, error) {
+	i, err := itf.BigInt(v)
+	if err != nil {
+		return nil, err
+	}
+	if i.IsInt64() {
+		b := protowire.AppendTag(nil, valueInt, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeZigZag(i.Int64())), nil
+	}
+	var bigInt []byte
+	if i.Sign() < 0 {
+		bigInt = protowire.AppendTag(bigInt, bigIntNegative, protowire.VarintType)
+		bigInt = protowire.AppendVarint(bigInt, 1)
+	}
+	bigInt = protowire.AppendTag(bigInt, bigIntMagnitude, protowire.BytesType)
+	bigInt = protowire.AppendBytes(bigInt, i.Bytes())
+	b := protowire.AppendTag(nil, valueBigInt, protowire.BytesType)
+	return protowire.AppendBytes(b, bigInt), nil
+}
+
+func marshalElems(num protowire.Number, elems []gjson.Result) ([]byte, error) {
+	var inner []byte
+	for _, e := range elems {
+		v, err := marshalValue(e)
+		if err != nil {
+			return nil, err
+		}
+		inner = protowire.AppendTag(inner, elemsElems, protowire.BytesType)
+		inner = protowire.AppendBytes(inner, v)
+	}
+	b := protowire.AppendTag(nil, num, protowire.BytesType)
+	return protowire.AppendBytes(b, inner), nil
+}
+
+func marshalMap(v gjson.Result) ([]byte, error) {
+	entries, err := itf.Map(v)
+	if err != nil {
+		return nil, err
+	}
+	var inner []byte
+	for _, e := range entries {
+		key, err := marshalValue(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalValue(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		entry := protowire.AppendTag(nil, entryKey, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, key)
+		entry = protowire.AppendTag(entry, entryValue, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, value)
+		inner = protowire.AppendTag(inner, mapEntries, protowire.BytesType)
+		inner = protowire.AppendBytes(inner, entry)
+	}
+	b := protowire.AppendTag(nil, valueMap, protowire.BytesType)
+	return protowire.AppendBytes(b, inner), nil
+}
+
+// write a Field message as "name":value
+func writeField(w *bytes.Buffer, data []byte) error {
+	name, value, err := splitField(data)
+	if err != nil {
+		return err
+	}
+	n, _ := json.Marshal(string(name))
+	w.Write(n)
+	w.WriteString(":")
+	return writeValue(w, value)
+}
+
+// the name and the encoded value of a Field message
+func splitField(data []byte) (name, value []byte, err error) {
+	err = fields(data, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		switch num {
+		case fieldName:
+			name = v
+		case fieldValue:
+			value = v
+		}
+		return nil
+	})
+	return name, value, err
+}
+
+// write a Value message in ITF JSON
+func writeValue(w *bytes.Buffer, data []byte) error {
+	found := false
+	err := fields(data, func(num protowire.Number, _ protowire.Type, v []byte, x uint64) error {
+		found = true
+		switch num {
+		case valueBool:
+			fmt.Fprint(w, protowire.DecodeBool(x))
+		case valueStr:
+			s, _ := json.Marshal(string(v))
+			w.Write(s)
+		case valueInt:
+			fmt.Fprintf(w, `{"#bigint":"%d"}`, protowire.DecodeZigZag(x))
+		case valueBigInt:
+			return writeBigInt(w, v)
+		case valueList:
+			return writeElems(w, "[", v, "]")
+		case valueTuple:
+			return writeElems(w, `{"#tup":[`, v, "]}")
+		case valueSet:
+			return writeElems(w, `{"#set":[`, v, "]}")
+		case valueRecord:
+			w.WriteString("{")
+			first := true
+			err := fields(v, func(_ protowire.Number, _ protowire.Type, f []byte, _ uint64) error {
+				if !first {
+					w.WriteString(",")
+				}
+				first = false
+				return writeField(w, f)
+			})
+			w.WriteString("}")
+			return err
+		case valueMap:
+			return writeMap(w, v)
+		case valueUnserializable:
+			s, _ := json.Marshal(string(v))
+			w.WriteString(`{"#unserializable":`)
+			w.Write(s)
+			w.WriteString("}")
+		default:
+			return fmt.Errorf("unknown kind of value: %d", num)
+		}
+		return nil
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("a value without a kind")
+	}
+	return err
+}
+
+func writeBigInt(w *bytes.Buffer, data []byte) error {
+	var negative bool
+	i := new(big.Int)
+	err := fields(data, func(num protowire.Number, _ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case bigIntNegative:
+			negative = protowire.DecodeBool(x)
+		case bigIntMagnitude:
+			i.SetBytes(v)
+		}
+		return nil
+	})
+	if negative {
+		i.Neg(i)
+	}
+	fmt.Fprintf(w, `{"#bigint":"%s"}`, i)
+	return err
+}
+
+func writeElems(w *bytes.Buffer, open string, data []byte, close string) error {
+	w.WriteString(open)
+	first := true
+	err := fields(data, func(_ protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		if !first {
+			w.WriteString(",")
+		}
+		first = false
+		return writeValue(w, v)
+	})
+	w.WriteString(close)
+	return err
+}
+
+func writeMap(w *bytes.Buffer, data []byte) error {
+	w.WriteString(`{"#map":[`)
+	first := true
+	err := fields(data, func(_ protowire.Number, _ protowire.Type, entry []byte, _ uint64) error {
+		if !first {
+			w.WriteString(",")
+		}
+		first = false
+		var key, value []byte
+		err := fields(entry, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+			switch num {
+			case entryKey:
+				key = v
+			case entryValue:
+				value = v
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		w.WriteString("[")
+		if err := writeValue(w, key); err != nil {
+			return err
+		}
+		w.WriteString(",")
+		if err := writeValue(w, value); err != nil {
+			return err
+		}
+		w.WriteString("]")
+		return nil
+	})
+	w.WriteString("]}")
+	return err
+}
+
+// iterate over the fields of a message, passing the bytes of the fields of
+// the type BytesType, and the numbers of the fields of the type VarintType
+func fields(data []byte, f func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		var v []byte
+		var x uint64
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(data)
+		default:
+			// a field of a newer version of itf.proto
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n >= 0 {
+				data = data[n:]
+				continue
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := f(num, typ, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package itfpb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+const quintTrace = `{
+  "#meta": { "format": "ITF", "source": "coinsTest.qnt" },
+  "vars": [ "balances", "denoms", "pair", "log", "ok", "action" ],
+  "states": [
+    {
+      "#meta": { "index": 0 },
+      "balances": { "#map": [ [ "atom", { "#bigint": "10" } ], [ "osmo", 3 ] ] },
+      "denoms": { "#set": [ "atom", "osmo" ] },
+      "pair": { "#tup": [ "atom", { "#bigint": "-115792089237316195423570985008687907853269984665640564039457584007913129639935" } ] },
+      "log": [ { "kind": "mint", "amount": { "#bigint": "-9223372036854775808" } } ],
+      "ok": false,
+      "action": { "#unserializable": "λx. x + 1" }
+    },
+    { "balances": { "#map": [] }, "denoms": { "#set": [] }, "pair": { "#tup": [] },
+      "log": [], "ok": true, "action": "" }
+  ]
+}`
+
+// compare two traces state by state, in their canonical forms
+func assertSameTrace(t *testing.T, expected, actual itf.Trace) {
+	assert.Equal(t, expected.Vars, actual.Vars)
+	require.Equal(t, len(expected.States), len(actual.States))
+	for i := range expected.States {
+		e, err := itf.Canonical(expected.States[i])
+		require.NoError(t, err)
+		a, err := itf.Canonical(actual.States[i])
+		require.NoError(t, err)
+		require.Equal(t, e, a, "state %d", i)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, err := FromJSON([]byte(quintTrace))
+	require.NoError(t, err)
+	trace, err := Parse(data)
+	require.NoError(t, err)
+	expected, err := itf.Parse([]byte(quintTrace))
+	require.NoError(t, err)
+	assertSameTrace(t, expected, trace)
+
+	// the order of the fields is kept
+	assert.Equal(t, `{ "index": 0 }`, trace.States[0].Get("\\#meta").Raw)
+	assert.Equal(t, []string{"#meta", "balances", "denoms", "pair", "log", "ok", "action"},
+		keys(trace.States[0]))
+
+	js, err := ToJSON(data)
+	require.NoError(t, err)
+	assert.Equal(t, "coinsTest.qnt", gjson.GetBytes(js, "\\#meta.source").String())
+}
+
+func TestParseVars(t *testing.T) {
+	data, err := FromJSON([]byte(quintTrace))
+	require.NoError(t, err)
+	vars := []string{"ok", "denoms", "missing"}
+	trace, err := ParseVars(data, vars)
+	require.NoError(t, err)
+	expected, err := itf.ParseVars([]byte(quintTrace), vars)
+	require.NoError(t, err)
+	assertSameTrace(t, expected, trace)
+	assert.Equal(t, []string{"denoms", "ok"}, trace.Vars)
+	assert.Equal(t, []string{"#meta", "denoms", "ok"}, keys(trace.States[0]))
+	assert.Equal(t, []string{"denoms", "ok"}, keys(trace.States[1]))
+}
+
+func keys(obj gjson.Result) []string {
+	var names []string
+	obj.ForEach(func(key, _ gjson.Result) bool {
+		names = append(names, key.String())
+		return true
+	})
+	return names
+}
+
+// all traces of the repository survive the round trip, and they get smaller
+func TestRoundTripOfRepository(t *testing.T) {
+	files, err := filepath.Glob("../../*/test-inputs*/*.itf.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	var jsonSize, pbSize int
+	for _, filename := range files {
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		expected, err := itf.Parse(data)
+		require.NoError(t, err, filename)
+		pb, err := FromJSON(data)
+		require.NoError(t, err, filename)
+		actual, err := Parse(pb)
+		require.NoError(t, err, filename)
+		assertSameTrace(t, expected, actual)
+		jsonSize += len(data)
+		pbSize += len(pb)
+	}
+	t.Logf("%d traces: %d bytes of JSON, %d bytes of protobuf", len(files), jsonSize, pbSize)
+	assert.Less(t, pbSize, jsonSize)
+}
+
+func TestStreamingStates(t *testing.T) {
+	trace, err := itf.Parse([]byte(quintTrace))
+	require.NoError(t, err)
+	for _, s := range trace.States {
+		data, err := MarshalState(s)
+		require.NoError(t, err)
+		decoded, err := UnmarshalState(data)
+		require.NoError(t, err)
+		e, _ := itf.Canonical(s)
+		a, _ := itf.Canonical(decoded)
+		assert.Equal(t, e, a)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := FromJSON([]byte(`{ "vars": [] }`))
+	assert.Error(t, err, "a trace without states")
+
+	_, err = FromJSON([]byte(`{ "states": [ { "x": { "#bigint": "ten" } } ] }`))
+	assert.ErrorContains(t, err, "x: expected a big integer")
+
+	_, err = Parse([]byte{0x1a, 0x05, 0x01})
+	assert.Error(t, err, "a truncated message")
+}
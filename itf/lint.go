@@ -0,0 +1,227 @@
+package itf
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// LintError is a problem of a trace at a path of its JSON, e.g.,
+// states[3].balances, which the parser may accept, but which points to a
+// corrupted or a hand-edited trace.
+type LintError struct {
+	Path string
+	Msg  string
+}
+
+func (e LintError) Error() string {
+	if e.Path == "" {
+		return e.Msg
+	}
+	return e.Path + ": " + e.Msg
+}
+
+// Lint checks a trace in ITF JSON before it is committed:
+//
+//   - the trace is an object with an array vars of names and an array states
+//     of objects, or such an object in an array, as Apalache writes it,
+//   - every state has exactly the variables of vars, besides its #meta,
+//   - every #bigint is a string of decimal digits, and every #set, #tup, and
+//     #map is an array, the latter of key-value pairs,
+//   - the #meta.index of the states, if there is one, counts up from 0.
+//
+// It returns all problems it finds, in the order of the trace, or none.
+func Lint(data []byte) []LintError {
+	text := string(data)
+	if !gjson.Valid(text) {
+		return []LintError{{Msg: "invalid JSON"}}
+	}
+	root := gjson.Parse(text)
+	if root.IsArray() {
+		root = root.Get("0")
+	}
+	if !root.IsObject() {
+		return []LintError{{Msg: "expected an ITF trace, found: " + kindOf(root)}}
+	}
+	var l linter
+	if meta := root.Get("\\#meta"); meta.Exists() && !meta.IsObject() {
+		l.errorf("#meta", "expected an object, found: %s", kindOf(meta))
+	}
+	vars := l.vars(root.Get("vars"))
+	states := root.Get("states")
+	if !states.IsArray() {
+		l.errorf("states", "expected an array, found: %s", kindOf(states))
+		return l.errs
+	}
+	for i, state := range states.Array() {
+		l.state(fmt.Sprintf("states[%d]", i), i, state, vars)
+	}
+	return l.errs
+}
+
+// the problems of a trace, as they are found
+type linter struct {
+	errs []LintError
+}
+
+func (l *linter) errorf(path, format string, args ...any) {
+	l.errs = append(l.errs, LintError{Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+// the names of the state variables, which are unique strings
+func (l *linter) vars(v gjson.Result) map[string]bool {
+	vars := make(map[string]bool)
+	if !v.IsArray() {
+		l.errorf("vars", "expected an array, found: %s", kindOf(v))
+		return vars
+	}
+	for i, name := range v.Array() {
+		path := fmt.Sprintf("vars[%d]", i)
+		switch {
+		case name.Type != gjson.String:
+			l.errorf(path, "expected the name of a variable, found: %s", name.Raw)
+		case vars[name.String()]:
+			l.errorf(path, "duplicate variable %s", name.String())
+		default:
+			vars[name.String()] = true
+		}
+	}
+	return vars
+}
+
+// check a state, which is the i-th one of the trace
+func (l *linter) state(path string, i int, state gjson.Result, vars map[string]bool) {
+	if !state.IsObject() {
+		l.errorf(path, "expected a state, found: %s", kindOf(state))
+		return
+	}
+	seen := make(map[string]bool)
+	state.ForEach(func(key, value gjson.Result) bool {
+		name := key.String()
+		if name == "#meta" {
+			l.meta(path+".#meta", i, value)
+			return true
+		}
+		seen[name] = true
+		if !vars[name] {
+			l.errorf(path, "variable %s is not in vars", name)
+		}
+		l.value(path+"."+name, value)
+		return true
+	})
+	var missing []string
+	for name := range vars {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		l.errorf(path, "missing variable %s", name)
+	}
+}
+
+// check the #meta of the i-th state
+func (l *linter) meta(path string, i int, meta gjson.Result) {
+	if !meta.IsObject() {
+		l.errorf(path, "expected an object, found: %s", kindOf(meta))
+		return
+	}
+	index := meta.Get("index")
+	if !index.Exists() {
+		return
+	}
+	if index.Type != gjson.Number || index.Raw != fmt.Sprint(i) {
+		l.errorf(path+".index", "expected the index %d, found: %s", i, index.Raw)
+	}
+}
+
+// check the encodings of the integers, sets, tuples, and maps in a value
+func (l *linter) value(path string, v gjson.Result) {
+	switch {
+	case v.IsArray():
+		for i, elem := range v.Array() {
+			l.value(fmt.Sprintf("%s[%d]", path, i), elem)
+		}
+	case v.IsObject():
+		if tag, elems, ok := taggedValue(v); ok {
+			l.tagged(path, tag, elems)
+			return
+		}
+		v.ForEach(func(key, value gjson.Result) bool {
+			l.value(path+"."+key.String(), value)
+			return true
+		})
+	}
+}
+
+// the tag of an encoded value, e.g., #bigint, and its contents, if it has one
+func taggedValue(v gjson.Result) (string, gjson.Result, bool) {
+	var tag string
+	var contents gjson.Result
+	fields := 0
+	v.ForEach(func(key, value gjson.Result) bool {
+		fields++
+		tag, contents = key.String(), value
+		return true
+	})
+	if fields != 1 || !strings.HasPrefix(tag, "#") {
+		return "", gjson.Result{}, false
+	}
+	return tag, contents, true
+}
+
+func (l *linter) tagged(path, tag string, contents gjson.Result) {
+	switch tag {
+	case "#bigint":
+		if _, ok := new(big.Int).SetString(contents.Str, 10); contents.Type != gjson.String || !ok {
+			l.errorf(path, "expected the digits of a #bigint, found: %s", contents.Raw)
+		}
+	case "#set", "#tup":
+		if !contents.IsArray() {
+			l.errorf(path, "expected the elements of a %s, found: %s", tag, kindOf(contents))
+			return
+		}
+		l.value(path, contents)
+	case "#map":
+		if !contents.IsArray() {
+			l.errorf(path, "expected the entries of a #map, found: %s", kindOf(contents))
+			return
+		}
+		for i, entry := range contents.Array() {
+			entryPath := fmt.Sprintf("%s[%d]", path, i)
+			if !entry.IsArray() || len(entry.Array()) != 2 {
+				l.errorf(entryPath, "expected a key-value pair, found: %s", entry.Raw)
+				continue
+			}
+			l.value(entryPath, entry)
+		}
+	case "#unserializable":
+		// Quint writes the values that ITF cannot represent in this way
+	default:
+		l.errorf(path, "unknown tag %s", tag)
+	}
+}
+
+// the kind of a JSON value in a message, e.g., an array
+func kindOf(v gjson.Result) string {
+	switch {
+	case !v.Exists():
+		return "nothing"
+	case v.IsArray():
+		return "an array"
+	case v.IsObject():
+		return "an object"
+	case v.Type == gjson.String:
+		return "a string"
+	case v.Type == gjson.Number:
+		return "a number"
+	case v.Type == gjson.Null:
+		return "null"
+	default:
+		return "a boolean"
+	}
+}
@@ -0,0 +1,59 @@
+package itf
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tidwall/gjson"
+)
+
+// List writes the operations of the states of a trace, one per line, without
+// executing them, so that a reviewer skims a freshly generated corpus before
+// it is committed, e.g.:
+//
+//	random56.itf.json: 57 states, 2 errors
+//	    0  newDec
+//	    1  add
+//	    2  quo  error: divisionByZero
+//
+// The operation of a state is found at the gjson path op, e.g., opcode or
+// lastAction.kind, and its error at the path errPath, e.g., opResult.error,
+// which is either a flag or a cause. A missing error, false, and "" are no
+// errors. The initial state is listed as well, as the traces of the SDK
+// harnesses also have an operation in it.
+func List(w io.Writer, name string, trace Trace, op, errPath string) error {
+	errs := make([]string, len(trace.States))
+	count := 0
+	for i, state := range trace.States {
+		errs[i] = errorOf(state, errPath)
+		if errs[i] != "" {
+			count++
+		}
+	}
+	if _, err := fmt.Fprintf(w, "%s: %d states, %d errors\n", name, len(trace.States), count); err != nil {
+		return err
+	}
+	for i, state := range trace.States {
+		line := fmt.Sprintf("%5d  %s", i, state.Get(op).String())
+		if errs[i] != "" {
+			line += "  error: " + errs[i]
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the error of a state at a path: "" for no error, the cause for a string, and
+// "true" for a flag that is set
+func errorOf(state gjson.Result, errPath string) string {
+	switch value := state.Get(errPath); value.Type {
+	case gjson.True:
+		return "true"
+	case gjson.False:
+		return ""
+	default:
+		return value.String()
+	}
+}
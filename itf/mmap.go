@@ -0,0 +1,71 @@
+package itf
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// MapFile reads a trace from a file in the ITF format like ReadFile, but it
+// maps the file into memory instead of reading it, which is meant for traces
+// of several gigabytes. The operating system pages the file in on demand, and
+// it drops the pages again under memory pressure, while the states of the
+// trace refer to the mapped memory instead of a copy of it. Hence, the mapping
+// is released by the returned function, after which the trace must not be
+// used anymore.
+//
+// The compressed files, and the files on the platforms without mmap, are read
+// with ReadFile.
+func MapFile(filename string) (Trace, func(), error) {
+	if Uncompressed(filename) != filename {
+		return readFile(filename)
+	}
+	data, err := mapFile(filename)
+	if err != nil {
+		return Trace{}, nil, err
+	}
+	if data == nil {
+		return readFile(filename)
+	}
+	trace, err := parse(unsafe.String(unsafe.SliceData(data), len(data)))
+	if err != nil {
+		unmapFile(data)
+		return Trace{}, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return trace, func() { unmapFile(data) }, nil
+}
+
+// readFile reads a trace like ReadFile, which needs no release
+func readFile(filename string) (Trace, func(), error) {
+	trace, err := ReadFile(filename)
+	if err != nil {
+		return Trace{}, nil, err
+	}
+	return trace, func() {}, nil
+}
+
+// MapData returns the contents of a trace file for the harnesses that decode
+// ITF JSON on their own. Like MapFile, it maps an uncompressed file into
+// memory, but the mapping is released by the returned function, after which
+// the data must not be used anymore. The compressed files, and the files that
+// cannot be mapped, are read and decompressed like in ReadFile.
+func MapData(filename string) ([]byte, func(), error) {
+	if Uncompressed(filename) == filename {
+		data, err := mapFile(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		if data != nil {
+			return data, func() { unmapFile(data) }, nil
+		}
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening file: %v", err)
+	}
+	data, err = Decompress(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return data, func() {}, nil
+}
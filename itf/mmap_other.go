@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package itf
+
+// mapFile does not map files on the platforms without mmap
+func mapFile(filename string) ([]byte, error) {
+	return nil, nil
+}
+
+// unmapFile is never called on the platforms without mmap
+func unmapFile(data []byte) {}
@@ -0,0 +1,38 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package itf
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapFile maps a file into memory, read-only, and returns its contents
+func mapFile(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	// the mapping outlives the file descriptor
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	size := info.Size()
+	if size == 0 || int64(int(size)) != size {
+		// an empty file cannot be mapped, and a huge one not on 32 bits
+		return nil, nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("error mapping %s: %v", filename, err)
+	}
+	return data, nil
+}
+
+// unmapFile releases the memory of mapFile
+func unmapFile(data []byte) {
+	syscall.Munmap(data)
+}
@@ -0,0 +1,129 @@
+// Package monitor checks the invariants of a spec on a running system, instead
+// of on the traces that the spec generated. The system writes its events as a
+// stream of JSON lines, e.g., to a log, and a monitor maps every event into
+// the state of the spec, and checks the invariants of the spec on the state,
+// e.g., with the predicates that quint-gen-go compiles with -invariants.
+//
+// Monitors register themselves by name, as adapters do, so the command itfrun
+// picks them by name with -monitor, either from the monitors that are compiled
+// into it, or from a Go plugin.
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// Monitor maps the events of a system into the states of a spec, and checks
+// the invariants of the spec on them.
+type Monitor interface {
+	// Observe maps an event into the state of the spec, e.g., it applies a
+	// transfer to the balances of the state. It returns an error if the event
+	// cannot be mapped, e.g., if it is not an event of the spec.
+	Observe(event gjson.Result) error
+	// Check checks the invariants of the spec on the current state. It returns
+	// an error that names the invariants that the state violates.
+	Check() error
+}
+
+// Factory creates a monitor. The argument is passed as is from the command
+// line, and it may be empty.
+type Factory func(arg string) (Monitor, error)
+
+var (
+	mu        sync.Mutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a monitor available by name. It panics if the name is taken,
+// as registering two monitors under the same name is a programming error.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, found := factories[name]; found {
+		panic(fmt.Sprintf("monitor %q is registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// New creates the monitor of a name.
+func New(name string, arg string) (Monitor, error) {
+	mu.Lock()
+	factory, found := factories[name]
+	mu.Unlock()
+	if !found {
+		return nil, fmt.Errorf("unknown monitor %q, known monitors: %v", name, Names())
+	}
+	return factory(arg)
+}
+
+// Names returns the names of the registered monitors, in order.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Violation is an event, after which the system is not in a state of the spec:
+// either the event could not be mapped, or the state violates an invariant.
+type Violation struct {
+	// the index of the event in the stream, and its line
+	Event, Line int
+	Err         error
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("event %d (line %d): %v", v.Event, v.Line, v.Err)
+}
+
+func (v *Violation) Unwrap() error {
+	return v.Err
+}
+
+// the longest line of an event
+const maxEventSize = 64 << 20
+
+// Run reads the events of a stream, one JSON value per line, until the stream
+// ends, and passes them to a monitor. It checks the invariants after every
+// event, and it calls onViolation for every violation, but it does not stop,
+// as a system keeps running after it violated an invariant. The empty lines
+// are skipped. Run returns the number of events, and an error if the stream
+// cannot be read. If onEvent is not nil, it is called after every event that
+// was checked without a violation.
+func Run(r io.Reader, m Monitor, onEvent func(i int, event gjson.Result), onViolation func(*Violation)) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxEventSize)
+	events := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if len(text) == 0 {
+			continue
+		}
+		i := events
+		events++
+		event := gjson.Parse(text)
+		var err error
+		if !gjson.Valid(text) {
+			err = fmt.Errorf("expected an event in JSON, found: %s", text)
+		} else if err = m.Observe(event); err == nil {
+			err = m.Check()
+		}
+		switch {
+		case err != nil && onViolation != nil:
+			onViolation(&Violation{Event: i, Line: line, Err: err})
+		case err == nil && onEvent != nil:
+			onEvent(i, event)
+		}
+	}
+	return events, scanner.Err()
+}
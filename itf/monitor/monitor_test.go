@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+// a bank, whose state in the spec is the balances of the accounts, and whose
+// invariants are that no balance is negative, and that transfers keep the
+// total supply
+type bank struct {
+	balances map[string]int64
+	supply   int64
+}
+
+func (b *bank) Observe(event gjson.Result) error {
+	amount := event.Get("amount").Int()
+	switch event.Get("kind").String() {
+	case "mint":
+		b.balances[event.Get("to").String()] += amount
+		b.supply += amount
+	case "transfer":
+		b.balances[event.Get("from").String()] -= amount
+		b.balances[event.Get("to").String()] += amount
+	default:
+		return fmt.Errorf("unexpected event: %s", event.Raw)
+	}
+	return nil
+}
+
+func (b *bank) Check() error {
+	var total int64
+	for addr, balance := range b.balances {
+		if balance < 0 {
+			return fmt.Errorf("violates noNegativeBalances: %s has %d", addr, balance)
+		}
+		total += balance
+	}
+	if total != b.supply {
+		return fmt.Errorf("violates totalSupply: %d != %d", total, b.supply)
+	}
+	return nil
+}
+
+const events = `{"kind": "mint", "to": "alice", "amount": 10}
+{"kind": "transfer", "from": "alice", "to": "bob", "amount": 4}
+
+{"kind": "transfer", "from": "bob", "to": "carol", "amount": 5}
+{"kind": "burn", "from": "alice", "amount": 1}
+not json
+{"kind": "transfer", "from": "carol", "to": "bob", "amount": 5}
+`
+
+func TestRun(t *testing.T) {
+	var checked []int
+	var violations []string
+	n, err := Run(strings.NewReader(events), &bank{balances: make(map[string]int64)},
+		func(i int, _ gjson.Result) { checked = append(checked, i) },
+		func(v *Violation) { violations = append(violations, v.Error()) })
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	// the monitor keeps going after a violation, and the last transfer
+	// brings the bank back into a state of the spec
+	assert.Equal(t, []int{0, 1, 5}, checked)
+	assert.Equal(t, []string{
+		"event 2 (line 4): violates noNegativeBalances: bob has -1",
+		`event 3 (line 5): unexpected event: {"kind": "burn", "from": "alice", "amount": 1}`,
+		"event 4 (line 6): expected an event in JSON, found: not json",
+	}, violations)
+}
+
+func TestRegistry(t *testing.T) {
+	Register("bank", func(string) (Monitor, error) { return &bank{balances: make(map[string]int64)}, nil })
+	assert.Contains(t, Names(), "bank")
+	assert.Panics(t, func() {
+		Register("bank", func(string) (Monitor, error) { return nil, nil })
+	})
+	m, err := New("bank", "")
+	require.NoError(t, err)
+	assert.IsType(t, &bank{}, m)
+
+	_, err = New("nonexistent", "")
+	assert.ErrorContains(t, err, `unknown monitor "nonexistent"`)
+
+	v := &Violation{Event: 1, Line: 2, Err: errors.New("violates inv")}
+	assert.Equal(t, "violates inv", errors.Unwrap(v).Error())
+}
@@ -0,0 +1,144 @@
+package itf
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// OracleProtocol is the version of the protocol of Oracle, which the
+// process announces in its first line.
+const OracleProtocol = "itf-oracle/1"
+
+// Oracle is an external process that computes the expected outcome of the
+// steps of a trace, e.g., a script on the decimal module of Python, or a
+// binary of a Rust implementation, so that the harness in Go checks another
+// implementation against the same traces. The process talks JSON, one value
+// per line, on its standard input and output, and its standard error is
+// passed through:
+//
+//   - it first writes a hello: {"protocol": "itf-oracle/1", "name": "python-decimal"},
+//   - it then reads one request per line: {"id": 1, "step": {...}}, where the
+//     step is a JSON object, whose fields are up to the harness,
+//   - it answers every request in order with one line of the same id: the
+//     outcome {"id": 1, "result": {...}}, {"id": 1, "unsupported": true}, if it
+//     does not implement the step, or {"id": 1, "error": "message"}, if it
+//     cannot process the request at all,
+//   - it exits, when its standard input is closed.
+//
+// The outcome of a step that fails, e.g., a division by zero, is a result,
+// as the harness defines it, and not an error of the protocol.
+type Oracle struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	// serializes the requests, as the answers come in order
+	mu     sync.Mutex
+	nextID int64
+}
+
+// OracleReply is the answer of an oracle to a request.
+type OracleReply struct {
+	ID          int64           `json:"id"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Unsupported bool            `json:"unsupported,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// StartOracle starts the process of an oracle, and reads its hello.
+func StartOracle(name string, args ...string) (*Oracle, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("oracle %s: %w", name, err)
+	}
+	o := &Oracle{name: name, cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}
+	var hello struct {
+		Protocol string `json:"protocol"`
+		Name     string `json:"name"`
+	}
+	if err := o.readLine(&hello); err != nil {
+		o.Close()
+		return nil, fmt.Errorf("oracle %s: no hello: %w", name, err)
+	}
+	if hello.Protocol != OracleProtocol {
+		o.Close()
+		return nil, fmt.Errorf("oracle %s: protocol %q, expected %q", name, hello.Protocol, OracleProtocol)
+	}
+	if hello.Name != "" {
+		o.name = hello.Name
+	}
+	return o, nil
+}
+
+// Name returns the name of the oracle, as it introduced itself.
+func (o *Oracle) Name() string {
+	return o.name
+}
+
+// read a line of JSON into v
+func (o *Oracle) readLine(v any) error {
+	line, err := o.stdout.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF && len(line) == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		if err != io.EOF {
+			return err
+		}
+	}
+	return json.Unmarshal(line, v)
+}
+
+// Ask sends a step to the oracle, and returns its answer. An error of the
+// protocol, e.g., a process that exited, or an answer of another id, is
+// returned as an error, as is the error of a reply.
+func (o *Oracle) Ask(step any) (OracleReply, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.nextID++
+	request, err := json.Marshal(struct {
+		ID   int64 `json:"id"`
+		Step any   `json:"step"`
+	}{o.nextID, step})
+	if err != nil {
+		return OracleReply{}, err
+	}
+	if _, err := o.stdin.Write(append(request, '\n')); err != nil {
+		return OracleReply{}, fmt.Errorf("oracle %s: %w", o.name, err)
+	}
+	var reply OracleReply
+	if err := o.readLine(&reply); err != nil {
+		return OracleReply{}, fmt.Errorf("oracle %s: %w", o.name, err)
+	}
+	switch {
+	case reply.ID != o.nextID:
+		return reply, fmt.Errorf("oracle %s: answer %d to request %d", o.name, reply.ID, o.nextID)
+	case reply.Error != "":
+		return reply, fmt.Errorf("oracle %s: %s", o.name, reply.Error)
+	case !reply.Unsupported && len(reply.Result) == 0:
+		return reply, fmt.Errorf("oracle %s: no result for request %d", o.name, reply.ID)
+	}
+	return reply, nil
+}
+
+// Close closes the standard input of the oracle, and waits for it to exit.
+func (o *Oracle) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stdin.Close()
+	return o.cmd.Wait()
+}
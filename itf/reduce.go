@@ -0,0 +1,76 @@
+package itf
+
+import (
+	"sort"
+)
+
+// Branch is an outcome of an operation that a trace covers, e.g., add that
+// fails. The operation is found at a gjson path of the states, as in List.
+type Branch struct {
+	Op     string
+	Failed bool
+}
+
+func (b Branch) String() string {
+	if b.Failed {
+		return b.Op + ": error"
+	}
+	return b.Op + ": ok"
+}
+
+// Branches returns the branches of the operations that a trace covers, see
+// List for the paths op and errPath.
+func Branches(trace Trace, op, errPath string) map[Branch]bool {
+	branches := make(map[Branch]bool)
+	for _, state := range trace.States {
+		branches[Branch{Op: state.Get(op).String(), Failed: errorOf(state, errPath) != ""}] = true
+	}
+	return branches
+}
+
+// CoveredTrace is a trace of a corpus with the branches that it covers, and
+// with its size, e.g., the number of its states, by which Reduce prefers
+// smaller traces.
+type CoveredTrace struct {
+	Name     string
+	Size     int
+	Branches map[Branch]bool
+}
+
+// Reduce selects a subset of a corpus that covers the same branches as the
+// whole corpus, e.g., to commit only a few of the traces that fuzz.sh
+// generates. The subset is chosen greedily: the next trace is the one that
+// covers the most branches that are not covered yet, and, of those, the
+// smallest one, then the first one by name. Finding a smallest subset is NP
+// hard, but the greedy subset is at most ln(n)+1 times as large for n
+// branches, and it is the same on every run.
+func Reduce(corpus []CoveredTrace) []CoveredTrace {
+	remaining := append([]CoveredTrace(nil), corpus...)
+	sort.Slice(remaining, func(i, j int) bool {
+		return remaining[i].Name < remaining[j].Name
+	})
+	covered := make(map[Branch]bool)
+	var subset []CoveredTrace
+	for {
+		best, bestGain := -1, 0
+		for i, trace := range remaining {
+			gain := 0
+			for b := range trace.Branches {
+				if !covered[b] {
+					gain++
+				}
+			}
+			if gain > bestGain || (gain == bestGain && gain > 0 && trace.Size < remaining[best].Size) {
+				best, bestGain = i, gain
+			}
+		}
+		if best < 0 {
+			return subset
+		}
+		for b := range remaining[best].Branches {
+			covered[b] = true
+		}
+		subset = append(subset, remaining[best])
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+}
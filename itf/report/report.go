@@ -0,0 +1,250 @@
+// Package report writes the results of replaying traces as a single HTML page,
+// which is attached to a bug report against a system under test. The page
+// lists every trace, and every state of a trace is expanded to its state
+// variables. The state, from which the system under test diverged, is
+// highlighted, and so are the expected and the actual values of an
+// adapter.Mismatch, as a diff.
+//
+// The page has no dependencies, so it is opened from a file in any browser.
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// the statuses of traces and of states
+const (
+	Passed   = "passed"
+	Diverged = "diverged"
+	Failed   = "failed"
+	Skipped  = "skipped"
+)
+
+// Report is the result of replaying a set of traces.
+type Report struct {
+	Title   string
+	Created time.Time
+	Traces  []Trace
+}
+
+// Trace is the result of replaying a trace.
+type Trace struct {
+	Filename string
+	// Passed, Diverged, or Failed, e.g., when the trace could not be read
+	Status string
+	// the error of a divergence or of a failure
+	Error  string
+	States []State
+}
+
+// State is the result of replaying a state of a trace.
+type State struct {
+	Index int
+	// Passed, Diverged, or Skipped, if a previous state diverged
+	Status string
+	Vars   []Var
+	Error  string
+	// the diff of the expected and the actual value of a mismatch
+	Diff []Line
+}
+
+// Var is a state variable, written in the syntax of Quint.
+type Var struct {
+	Name  string
+	Value string
+	// whether the value differs from the previous state
+	Changed bool
+	// whether the mismatch of a divergence is in this variable
+	Mismatch bool
+}
+
+// Line is a line of a diff. Its kind is ' ' for a common line, '-' for a line
+// of the expected value, and '+' for a line of the actual value.
+type Line struct {
+	Kind byte
+	Text string
+}
+
+// New returns an empty report.
+func New(title string) *Report {
+	return &Report{Title: title, Created: time.Now()}
+}
+
+// Add adds the result of replaying a trace, as returned by adapter.Run.
+func (r *Report) Add(filename string, trace itf.Trace, err error) {
+	t := Trace{Filename: filename, Status: Passed}
+	var divergence *adapter.Divergence
+	switch {
+	case err == nil:
+	case errors.As(err, &divergence):
+		t.Status, t.Error = Diverged, err.Error()
+	default:
+		t.Status, t.Error = Failed, err.Error()
+	}
+	var previous map[string]gjson.Result
+	for i, s := range trace.States {
+		state := State{Index: i, Status: Passed}
+		if divergence != nil && i > divergence.State {
+			state.Status = Skipped
+		}
+		var mismatch *adapter.Mismatch
+		if divergence != nil && i == divergence.State {
+			state.Status, state.Error = Diverged, divergence.Err.Error()
+			if errors.As(divergence.Err, &mismatch) {
+				state.Diff = Diff(indent(mismatch.Expected), indent(mismatch.Actual))
+			}
+		}
+		values := fields(s)
+		for _, name := range names(trace, s) {
+			v := Var{Name: name, Value: itf.Show(values[name])}
+			if previous != nil {
+				v.Changed = !sameValue(values[name], previous[name])
+			}
+			if mismatch != nil {
+				root, _, _ := strings.Cut(mismatch.Path, ".")
+				v.Mismatch = root == name
+			}
+			state.Vars = append(state.Vars, v)
+		}
+		t.States = append(t.States, state)
+		previous = values
+	}
+	r.Traces = append(r.Traces, t)
+}
+
+// Count returns the number of traces of a status.
+func (r *Report) Count(status string) int {
+	n := 0
+	for _, t := range r.Traces {
+		if t.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes the report as HTML.
+func (r *Report) Write(w io.Writer) error {
+	return page.Execute(w, r)
+}
+
+// WriteFile writes the report as HTML to a file.
+func (r *Report) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := r.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return f.Close()
+}
+
+// the names of the state variables, in the order of the trace
+func names(trace itf.Trace, state gjson.Result) []string {
+	if len(trace.Vars) > 0 {
+		return trace.Vars
+	}
+	var vars []string
+	state.ForEach(func(key, _ gjson.Result) bool {
+		if key.String() != "#meta" {
+			vars = append(vars, key.String())
+		}
+		return true
+	})
+	return vars
+}
+
+// the fields of an object by their names, which may contain dots
+func fields(obj gjson.Result) map[string]gjson.Result {
+	m := make(map[string]gjson.Result)
+	obj.ForEach(func(key, value gjson.Result) bool {
+		m[key.String()] = value
+		return true
+	})
+	return m
+}
+
+func sameValue(a, b gjson.Result) bool {
+	ca, errA := itf.Canonical(a)
+	cb, errB := itf.Canonical(b)
+	return errA == nil && errB == nil && ca == cb
+}
+
+// the lines of a value in ITF JSON, indented
+func indent(value string) []string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(value), "", "  "); err != nil {
+		return strings.Split(value, "\n")
+	}
+	return strings.Split(b.String(), "\n")
+}
+
+// Diff returns the diff of two lists of lines, which keeps their longest
+// common subsequence.
+func Diff(expected, actual []string) []Line {
+	// the lengths of the longest common subsequences of the suffixes
+	lcs := make([][]int, len(expected)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			switch {
+			case expected[i] == actual[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []Line
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			lines = append(lines, Line{' ', expected[i]})
+			i, j = i+1, j+1
+		case j == len(actual) || (i < len(expected) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, Line{'-', expected[i]})
+			i++
+		default:
+			lines = append(lines, Line{'+', actual[j]})
+			j++
+		}
+	}
+	return lines
+}
+
+//go:embed report.html
+var pageTemplate string
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"kind": func(l Line) string {
+		switch l.Kind {
+		case '-':
+			return "expected"
+		case '+':
+			return "actual"
+		}
+		return "common"
+	},
+	"sign": func(l Line) string { return string(l.Kind) },
+}).Parse(pageTemplate))
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+const counterTrace = `{
+  "vars": [ "counter", "log" ],
+  "states": [
+    { "counter": { "#bigint": "0" }, "log": { "#set": [] } },
+    { "counter": { "#bigint": "1" }, "log": { "#set": [ "inc" ] } },
+    { "counter": { "#bigint": "2" }, "log": { "#set": [ "inc" ] } }
+  ]
+}`
+
+func TestReport(t *testing.T) {
+	trace, err := itf.Parse([]byte(counterTrace))
+	require.NoError(t, err)
+	r := New("counter <conformance>")
+	r.Add("passed.itf.json", trace, nil)
+	r.Add("diverged.itf.json", trace, &adapter.Divergence{State: 1, Err: &adapter.Mismatch{
+		Path:     "counter",
+		Expected: `{"#bigint":"1"}`,
+		Actual:   `{"#bigint":"2"}`,
+	}})
+	r.Add("failed.itf.json", itf.Trace{}, errors.New("connection refused"))
+	assert.Equal(t, 1, r.Count(Passed))
+	assert.Equal(t, 1, r.Count(Diverged))
+	assert.Equal(t, 1, r.Count(Failed))
+
+	diverged := r.Traces[1]
+	assert.Equal(t, []string{Passed, Diverged, Skipped},
+		[]string{diverged.States[0].Status, diverged.States[1].Status, diverged.States[2].Status})
+	state := diverged.States[1]
+	assert.Equal(t, Var{Name: "counter", Value: "1", Changed: true, Mismatch: true}, state.Vars[0])
+	assert.Equal(t, Var{Name: "log", Value: `Set("inc")`, Changed: true}, state.Vars[1])
+	assert.False(t, diverged.States[2].Vars[1].Changed)
+	assert.Equal(t, []Line{{' ', "{"}, {'-', `  "#bigint": "1"`}, {'+', `  "#bigint": "2"`}, {' ', "}"}},
+		state.Diff)
+
+	var html bytes.Buffer
+	require.NoError(t, r.Write(&html))
+	page := html.String()
+	assert.Contains(t, page, "<title>counter &lt;conformance&gt;</title>")
+	assert.Contains(t, page, "3 traces, 1 passed, 1 diverged, 1 failed")
+	assert.Contains(t, page, `<details class="trace diverged" open>`)
+	assert.Contains(t, page, `<span class="expected">-   &#34;#bigint&#34;: &#34;1&#34;</span>`)
+	assert.Contains(t, page, `<span class="actual">&#43;   &#34;#bigint&#34;: &#34;2&#34;</span>`)
+	assert.Contains(t, page, "connection refused")
+}
+
+func TestDiff(t *testing.T) {
+	lines := Diff([]string{"a", "b", "c", "d"}, []string{"a", "c", "x", "d"})
+	assert.Equal(t, []Line{{' ', "a"}, {'-', "b"}, {' ', "c"}, {'+', "x"}, {' ', "d"}}, lines)
+	assert.Empty(t, Diff(nil, nil))
+}
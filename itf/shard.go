@@ -0,0 +1,73 @@
+package itf
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Shard is a part of a corpus of traces, which is split deterministically
+// across several processes or machines, e.g., in a CI matrix. It is written as
+// i/n, where 1 <= i <= n, and every trace is in exactly one of the n shards.
+// The zero Shard is the whole corpus.
+//
+// Shard is a flag.Value:
+//
+//	var shard itf.Shard
+//	flag.Var(&shard, "shard", "replay the i-th of n shards of the traces, e.g., 2/4")
+type Shard struct {
+	Index, Count int
+}
+
+// ParseShard parses a shard, e.g., 2/4.
+func ParseShard(s string) (Shard, error) {
+	i, n, found := strings.Cut(s, "/")
+	index, err1 := strconv.Atoi(i)
+	count, err2 := strconv.Atoi(n)
+	if !found || err1 != nil || err2 != nil {
+		return Shard{}, fmt.Errorf("expected a shard i/n, found %q", s)
+	}
+	if count < 1 || index < 1 || index > count {
+		return Shard{}, fmt.Errorf("expected a shard i/n with 1 <= i <= n, found %q", s)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+func (s *Shard) String() string {
+	if s.Count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+func (s *Shard) Set(value string) error {
+	shard, err := ParseShard(value)
+	if err != nil {
+		return err
+	}
+	*s = shard
+	return nil
+}
+
+// Has tells whether a trace is in the shard. The trace is assigned by a hash
+// of the base name of its file, so a shard has the same traces on every
+// machine, no matter in which directory the corpus is, and the traces that are
+// added to the corpus do not move the others.
+func (s Shard) Has(filename string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(filepath.Base(filename)))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index-1
+}
+
+// HasState tells whether the i-th state of a trace is in the shard, which
+// splits the states of a single trace round-robin. This only makes sense,
+// when the states are independent of each other, e.g., when every state is a
+// test case of its own.
+func (s Shard) HasState(i int) bool {
+	return s.Count <= 1 || i%s.Count == s.Index-1
+}
@@ -0,0 +1,21 @@
+package itf
+
+import "os"
+
+// TraceDirEnv is the environment variable that overrides the directory of the
+// traces of the harnesses, e.g., to replay a freshly generated corpus, or the
+// corpus of another version of a system under test, with the same test binary.
+const TraceDirEnv = "ITF_TRACE_DIR"
+
+// TraceDir returns the directory of ITF_TRACE_DIR, if it is set, or else def.
+// The harnesses use it as the default of their flag -itf-dir, so the flag
+// still takes precedence. As the variable is read before the test starts, go
+// test does not cache the results by it, so run go test -count=1 with it:
+//
+//	var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+func TraceDir(def string) string {
+	if dir := os.Getenv(TraceDirEnv); dir != "" {
+		return dir
+	}
+	return def
+}
@@ -0,0 +1,318 @@
+package itf
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Unmarshal decodes an ITF value, e.g., a state of a trace, into the value
+// that target points to, by the types of its Go fields, in the same way as
+// encoding/json decodes JSON:
+//
+//	type State struct {
+//		Balances map[string]*big.Int `itf:"balances"`
+//		Denoms   []string            `itf:"denoms,set"`
+//		Step     int64               `itf:"step"`
+//		Action   string              `itf:"mbt::actionTaken"`
+//	}
+//
+//	var s State
+//	err := itf.Unmarshal(trace.States[i], &s)
+//
+// A field of a struct is decoded from the field of a record, which is named by
+// the tag itf of the Go field, or by the name of the Go field, where the case
+// of the letters does not matter, as in encoding/json. A field whose name
+// matches exactly comes first, and a record with several fields that only
+// differ in case from the name is an error. The options of the tag follow the
+// name, separated by commas, and an unknown option is an error:
+//
+//	bigint     the field is an integer, which is a string of its decimal digits
+//	           in Go, e.g., `itf:"amount,bigint"`
+//	set        the field is a set, which is a slice, or a map to bool or to
+//	           struct{} in Go, and not a list
+//	tup        the field is a tuple, which is a slice, or a struct whose fields
+//	           are the elements in order
+//	optional   the field may be missing, and it is left as is
+//
+// The tag "-" skips a field. The types of Go are decoded as follows:
+//
+//	bool, string       bool and str
+//	int, int64, ...    int, which is an error if it does not fit
+//	*big.Int, big.Int  int
+//	slices             lists and sets
+//	maps               maps, whose keys are strings, bools, or integers
+//	structs            records
+//	gjson.Result       any value, as is
+//	pointers           the values they point to
+func Unmarshal(v gjson.Result, target any) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("expected a non-nil pointer, found: %T", target)
+	}
+	return unmarshal(v, ptr.Elem(), fieldOptions{})
+}
+
+// the options of the tag of a field
+type fieldOptions struct {
+	bigint, set, tup, optional bool
+}
+
+// parse the tag of a field, e.g., amount,bigint
+func parseTag(f reflect.StructField) (name string, opts fieldOptions, err error) {
+	tag, found := f.Tag.Lookup("itf")
+	if !found {
+		return f.Name, opts, nil
+	}
+	name, rest, hasOpts := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	if !hasOpts {
+		return name, opts, nil
+	}
+	for _, opt := range strings.Split(rest, ",") {
+		switch opt {
+		case "bigint":
+			opts.bigint = true
+		case "set":
+			opts.set = true
+		case "tup":
+			opts.tup = true
+		case "optional":
+			opts.optional = true
+		default:
+			return "", opts, fmt.Errorf("%s: unknown option %q in the tag %q", f.Name, opt, tag)
+		}
+	}
+	return name, opts, nil
+}
+
+var (
+	bigIntType = reflect.TypeOf(big.Int{})
+	resultType = reflect.TypeOf(gjson.Result{})
+)
+
+func unmarshal(v gjson.Result, target reflect.Value, opts fieldOptions) error {
+	t := target.Type()
+	switch {
+	case t == resultType:
+		target.Set(reflect.ValueOf(v))
+		return nil
+	case t == bigIntType:
+		i, err := BigInt(v)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(*i))
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		if target.IsNil() {
+			target.Set(reflect.New(t.Elem()))
+		}
+		return unmarshal(v, target.Elem(), opts)
+	case reflect.Bool:
+		if !v.IsBool() {
+			return fmt.Errorf("expected a bool, found: %s", v.Raw)
+		}
+		target.SetBool(v.Bool())
+	case reflect.String:
+		if opts.bigint {
+			i, err := BigInt(v)
+			if err != nil {
+				return err
+			}
+			target.SetString(i.String())
+			return nil
+		}
+		if v.Type != gjson.String {
+			return fmt.Errorf("expected a string, found: %s", v.Raw)
+		}
+		target.SetString(v.Str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := BigInt(v)
+		if err != nil {
+			return err
+		}
+		if !i.IsInt64() || target.OverflowInt(i.Int64()) {
+			return fmt.Errorf("the integer %s does not fit into %s", i, t)
+		}
+		target.SetInt(i.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := BigInt(v)
+		if err != nil {
+			return err
+		}
+		if !i.IsUint64() || target.OverflowUint(i.Uint64()) {
+			return fmt.Errorf("the integer %s does not fit into %s", i, t)
+		}
+		target.SetUint(i.Uint64())
+	case reflect.Slice:
+		elems, err := elemsOf(v, opts)
+		if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(t, len(elems), len(elems))
+		for i, e := range elems {
+			if err := unmarshal(e, slice.Index(i), fieldOptions{}); err != nil {
+				return fmt.Errorf("%d: %v", i, err)
+			}
+		}
+		target.Set(slice)
+	case reflect.Map:
+		if opts.set {
+			return unmarshalSet(v, target)
+		}
+		return unmarshalMap(v, target)
+	case reflect.Struct:
+		if opts.tup {
+			return unmarshalTuple(v, target)
+		}
+		return unmarshalRecord(v, target)
+	default:
+		return fmt.Errorf("cannot decode ITF into %s", t)
+	}
+	return nil
+}
+
+// the elements of a list, a set, or a tuple
+func elemsOf(v gjson.Result, opts fieldOptions) ([]gjson.Result, error) {
+	switch {
+	case opts.set:
+		return Set(v)
+	case opts.tup:
+		return Tuple(v)
+	case v.IsArray():
+		return v.Array(), nil
+	case v.Get(`\#set`).Exists():
+		return Set(v)
+	}
+	return nil, fmt.Errorf("expected a list or a set, found: %s", v.Raw)
+}
+
+// decode a set into a map to bool or to struct{}
+func unmarshalSet(v gjson.Result, target reflect.Value) error {
+	t := target.Type()
+	if t.Elem().Kind() != reflect.Bool && t.Elem() != reflect.TypeOf(struct{}{}) {
+		return fmt.Errorf("cannot decode a set into %s", t)
+	}
+	elems, err := Set(v)
+	if err != nil {
+		return err
+	}
+	m := reflect.MakeMapWithSize(t, len(elems))
+	member := reflect.New(t.Elem()).Elem()
+	if member.Kind() == reflect.Bool {
+		member.SetBool(true)
+	}
+	for i, e := range elems {
+		key := reflect.New(t.Key()).Elem()
+		if err := unmarshal(e, key, fieldOptions{}); err != nil {
+			return fmt.Errorf("%d: %v", i, err)
+		}
+		m.SetMapIndex(key, member)
+	}
+	target.Set(m)
+	return nil
+}
+
+func unmarshalMap(v gjson.Result, target reflect.Value) error {
+	t := target.Type()
+	entries, err := Map(v)
+	if err != nil {
+		return err
+	}
+	m := reflect.MakeMapWithSize(t, len(entries))
+	for _, e := range entries {
+		key := reflect.New(t.Key()).Elem()
+		if err := unmarshal(e.Key, key, fieldOptions{}); err != nil {
+			return fmt.Errorf("key %s: %v", e.Key.Raw, err)
+		}
+		value := reflect.New(t.Elem()).Elem()
+		if err := unmarshal(e.Value, value, fieldOptions{}); err != nil {
+			return fmt.Errorf("%s: %v", Show(e.Key), err)
+		}
+		m.SetMapIndex(key, value)
+	}
+	target.Set(m)
+	return nil
+}
+
+// decode a tuple into the exported fields of a struct, in order
+func unmarshalTuple(v gjson.Result, target reflect.Value) error {
+	elems, err := Tuple(v)
+	if err != nil {
+		return err
+	}
+	var fields []int
+	for i := 0; i < target.NumField(); i++ {
+		if f := target.Type().Field(i); f.IsExported() && f.Tag.Get("itf") != "-" {
+			fields = append(fields, i)
+		}
+	}
+	if len(elems) != len(fields) {
+		return fmt.Errorf("expected a tuple of %d elements, found: %s", len(fields), v.Raw)
+	}
+	for i, e := range elems {
+		_, opts, err := parseTag(target.Type().Field(fields[i]))
+		if err != nil {
+			return err
+		}
+		if err := unmarshal(e, target.Field(fields[i]), opts); err != nil {
+			return fmt.Errorf("%d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// decode a record into the exported fields of a struct
+func unmarshalRecord(v gjson.Result, target reflect.Value) error {
+	if !v.IsObject() {
+		return fmt.Errorf("expected a record, found: %s", v.Raw)
+	}
+	fields := v.Map()
+	for i := 0; i < target.NumField(); i++ {
+		f := target.Type().Field(i)
+		if !f.IsExported() || f.Tag.Get("itf") == "-" {
+			continue
+		}
+		name, opts, err := parseTag(f)
+		if err != nil {
+			return err
+		}
+		value, found := fields[name]
+		if !found {
+			// the names match case-insensitively, as in encoding/json, but
+			// only if a single field of the record matches
+			var matches []string
+			for n := range fields {
+				if strings.EqualFold(n, name) {
+					matches = append(matches, n)
+				}
+			}
+			sort.Strings(matches)
+			if len(matches) > 1 {
+				return fmt.Errorf("ambiguous field %q: %s", name, strings.Join(matches, ", "))
+			}
+			if len(matches) == 1 {
+				name, value, found = matches[0], fields[matches[0]], true
+			}
+		}
+		if !found {
+			if opts.optional {
+				continue
+			}
+			return fmt.Errorf("missing field %q", name)
+		}
+		if err := unmarshal(value, target.Field(i), opts); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
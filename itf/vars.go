@@ -0,0 +1,157 @@
+package itf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// ParseVars decodes a trace like Parse, but it keeps only the state variables
+// vars in the states, and #meta, so a harness declares the variables that it
+// consumes, and the decoder skips the others. This matters when a spec carries
+// large auxiliary state, e.g., the history of a protocol for its invariants,
+// which the system under test does not have. The states are scanned once, and
+// only the kept variables are copied out of data, so the states of the trace
+// do not refer to the data of the skipped variables, and their queries do not
+// scan them. If vars is nil, all state variables are kept, as in Parse.
+func ParseVars(data []byte, vars []string) (Trace, error) {
+	if vars == nil {
+		return Parse(data)
+	}
+	// nothing of the trace refers to data, so it is not copied into a string
+	text := unsafe.String(unsafe.SliceData(data), len(data))
+	if !gjson.Valid(text) {
+		return Trace{}, fmt.Errorf("invalid JSON")
+	}
+	root := gjson.Parse(text)
+	if root.IsArray() {
+		// Apalache writes a trace wrapped in an array
+		root = root.Get("0")
+	}
+	states := root.Get("states")
+	if !states.IsArray() {
+		return Trace{}, fmt.Errorf("expected an ITF trace with states")
+	}
+	keep := keptVars(vars)
+	var trace Trace
+	for _, v := range root.Get("vars").Array() {
+		if keep[v.String()] {
+			trace.Vars = append(trace.Vars, strings.Clone(v.String()))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("[")
+	states.ForEach(func(_, state gjson.Result) bool {
+		if b.Len() > 1 {
+			b.WriteString(",")
+		}
+		writeSelected(&b, state, keep)
+		return true
+	})
+	b.WriteString("]")
+	trace.States = gjson.Parse(b.String()).Array()
+	return trace, nil
+}
+
+// ReadFileVars reads a trace from a file like ReadFile, but it keeps only the
+// state variables vars, see ParseVars.
+func ReadFileVars(filename string, vars []string) (Trace, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Trace{}, fmt.Errorf("error opening file: %v", err)
+	}
+	data, err = Decompress(data)
+	if err != nil {
+		return Trace{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return ParseVars(data, vars)
+}
+
+// SelectVars returns a trace with the state variables vars of a trace, and
+// #meta, e.g., for a trace that was decoded from another format. The variables
+// that the trace does not have are ignored.
+func SelectVars(trace Trace, vars []string) Trace {
+	keep := keptVars(vars)
+	selected := Trace{States: make([]gjson.Result, len(trace.States))}
+	for _, v := range trace.Vars {
+		if keep[v] {
+			selected.Vars = append(selected.Vars, v)
+		}
+	}
+	var b strings.Builder
+	for i, state := range trace.States {
+		b.Reset()
+		writeSelected(&b, state, keep)
+		selected.States[i] = gjson.Parse(b.String())
+	}
+	return selected
+}
+
+// the state variables to keep, and #meta
+func keptVars(vars []string) map[string]bool {
+	keep := map[string]bool{"#meta": true}
+	for _, v := range vars {
+		keep[v] = true
+	}
+	return keep
+}
+
+// write a state with only the kept variables as a JSON object
+func writeSelected(b *strings.Builder, state gjson.Result, keep map[string]bool) {
+	b.WriteString("{")
+	first := true
+	state.ForEach(func(key, value gjson.Result) bool {
+		if keep[key.Str] {
+			if !first {
+				b.WriteString(",")
+			}
+			first = false
+			b.WriteString(key.Raw)
+			b.WriteString(":")
+			b.WriteString(value.Raw)
+		}
+		return true
+	})
+	b.WriteString("}")
+}
+
+// Project returns the trace with only the state variables vars, and #meta,
+// e.g., when a harness implements only a part of a composed spec, or to share
+// a trace without the variables that are internal to a team. It is SelectVars
+// as a method, so projections read as trace.Project("opcode", "opResult").
+func (t Trace) Project(vars ...string) Trace {
+	return SelectVars(t, vars)
+}
+
+// MarshalJSON encodes a trace in ITF JSON, e.g., to write a projected trace to
+// a file. The states are written as they were decoded. The #meta of the trace,
+// e.g., the source of the spec, is not kept in a Trace, so it is not written.
+func (t Trace) MarshalJSON() ([]byte, error) {
+	vars := t.Vars
+	if vars == nil {
+		vars = []string{}
+	}
+	v, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString(`{"vars":`)
+	b.Write(v)
+	b.WriteString(`,"states":[`)
+	for i, state := range t.States {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		if !state.IsObject() {
+			return nil, fmt.Errorf("state %d: expected an object, found: %s", i, state.Raw)
+		}
+		b.WriteString(state.Raw)
+	}
+	b.WriteString("]}")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,266 @@
+// A test harness that replays the traces of leaseTest.qnt against the
+// implementation of the lock service in the package lock.
+//
+// The harness owns the clocks: the clock of the server shows the global time
+// of the spec, and the clock of every client is skewed from it by the offset
+// of the client in the spec. A unit of time in the spec is a second. Every
+// state of a trace records the action that led to it in lastAction: the time
+// advancing, the clock of a client jumping, an operation of a client, or the
+// server reaping an expired lease. We apply the action, check its error, and
+// compare the leases of the server and of the clients with the spec. We also
+// compare which clients believe that they hold the lock, which shows the
+// clock-skew scenarios, in which two clients believe so at the same time.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/lease/lock"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the constants of leaseTest.qnt
+const (
+	leaseDuration = 10 * time.Second
+	guard         = 2 * time.Second
+)
+
+// the global time 0 of the spec
+var epoch = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// the time of the spec as a time.Time
+func at(t int64) time.Time {
+	return epoch.Add(time.Duration(t) * time.Second)
+}
+
+// the errors of the actions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"lock held":     lock.ErrLockHeld,
+	"not holder":    lock.ErrNotHolder,
+	"lease expired": lock.ErrLeaseExpired,
+	"no holder":     lock.ErrNoHolder,
+	"not expired":   lock.ErrNotExpired,
+}
+
+// a client of lease.qnt
+type TestClient struct {
+	skew   int64
+	token  uint64
+	expiry int64
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind   string
+	client string
+	amount int64
+	error  string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	now        int64
+	holder     string
+	token      uint64
+	expiry     int64
+	clients    map[string]TestClient
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from leaseTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.now = harness.Int64(t, jsonState.Get("svc.now"))
+		state.holder = jsonState.Get("svc.server.holder").String()
+		state.token = uint64(harness.Int64(t, jsonState.Get("svc.server.token")))
+		state.expiry = harness.Int64(t, jsonState.Get("svc.server.expiry"))
+		state.clients = make(map[string]TestClient)
+		entries, err := itf.Map(jsonState.Get("svc.clients"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.clients[e.Key.String()] = TestClient{
+				skew:   harness.Int64(t, e.Value.Get("skew")),
+				token:  uint64(harness.Int64(t, e.Value.Get("token"))),
+				expiry: harness.Int64(t, e.Value.Get("expiry")),
+			}
+		}
+		state.lastAction = TestAction{
+			kind:   jsonState.Get("lastAction.kind").String(),
+			client: jsonState.Get("lastAction.client").String(),
+			amount: harness.Int64(t, jsonState.Get("lastAction.amount")),
+			error:  jsonState.Get("lastAction.error").String(),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the lease with a token and an expiry of the spec, where token 0 is no lease
+func expectedLease(token uint64, expiry int64) lock.Lease {
+	if token == 0 {
+		return lock.Lease{}
+	}
+	return lock.Lease{Token: token, Expiry: at(expiry)}
+}
+
+// the clock of the server, which shows the global time
+type globalClock struct {
+	now time.Time
+}
+
+func (c *globalClock) Now() time.Time {
+	return c.now
+}
+
+// the clock of a client, which is skewed from the global time
+type skewedClock struct {
+	global *globalClock
+	skew   time.Duration
+}
+
+func (c *skewedClock) Now() time.Time {
+	return c.global.now.Add(c.skew)
+}
+
+// the server and the clients under test, together with their clocks
+type testService struct {
+	clock   *globalClock
+	server  *lock.Server
+	clocks  map[string]*skewedClock
+	clients map[string]*lock.Client
+}
+
+// create the server and the clients of the initial state
+func setupService(init TestState) *testService {
+	clock := &globalClock{now: at(init.now)}
+	s := &testService{
+		clock:   clock,
+		server:  lock.NewServer(clock, leaseDuration),
+		clocks:  make(map[string]*skewedClock),
+		clients: make(map[string]*lock.Client),
+	}
+	for name, c := range init.clients {
+		s.clocks[name] = &skewedClock{global: clock, skew: time.Duration(c.skew) * time.Second}
+		s.clients[name] = lock.NewClient(name, s.clocks[name], guard, s.server)
+	}
+	return s
+}
+
+// apply an action to the service
+func (s *testService) execute(t *testing.T, a TestAction) error {
+	switch a.kind {
+	case "tick":
+		s.clock.now = s.clock.now.Add(time.Duration(a.amount) * time.Second)
+		return nil
+	case "adjust":
+		s.clocks[a.client].skew = time.Duration(a.amount) * time.Second
+		return nil
+	case "acquire":
+		return s.clients[a.client].Acquire()
+	case "renew":
+		return s.clients[a.client].Renew()
+	case "release":
+		return s.clients[a.client].Release()
+	case "expire":
+		return s.server.Expire()
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+		return nil
+	}
+}
+
+// compare the server and the clients with the state of the spec
+func (s *testService) checkState(t *testing.T, expected TestState) {
+	assert.Equal(t, at(expected.now), s.clock.Now(), "global time")
+	holder, lease := s.server.Holder()
+	assert.Equal(t, expected.holder, holder, "holder of the lock")
+	assert.Equal(t, expectedLease(expected.token, expected.expiry), lease, "lease of the server")
+	var expectedHolders []string
+	for name, c := range expected.clients {
+		client := s.clients[name]
+		assert.Equal(t, expectedLease(c.token, c.expiry), client.Lease(), "lease of %s", name)
+		// as holds in lease.qnt
+		if c.token > 0 && expected.now+c.skew < c.expiry-int64(guard/time.Second) {
+			expectedHolders = append(expectedHolders, name)
+		}
+	}
+	sort.Strings(expectedHolders)
+	assert.Equal(t, expectedHolders, s.holders(), "clients that believe that they hold the lock")
+}
+
+// the clients that believe that they hold the lock, in order
+func (s *testService) holders() []string {
+	var holders []string
+	for name, c := range s.clients {
+		if c.Holds() {
+			holders = append(holders, name)
+		}
+	}
+	sort.Strings(holders)
+	return holders
+}
+
+// execute all actions of a trace, one by one, and return the service in the last state
+func ExecFromItf(t *testing.T, filename string) *testService {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	service := setupService(states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, s.lastAction.client)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := service.execute(t, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err, "the action should succeed")
+				} else {
+					require.True(t, errors.Is(err, errorsOfSpec[s.lastAction.error]),
+						"expected the error %q, found %v", s.lastAction.error, err)
+				}
+			}
+			service.checkState(t, s)
+		})
+		if !ok {
+			// the states of the implementation and the spec diverged
+			break
+		}
+	}
+	return service
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// a clock of a client falls behind the clock of the server by more than the
+// guard, so two clients believe that they hold the lock, with different tokens
+func TestSkewViolation(t *testing.T) {
+	service := ExecFromItf(t, tracePath("skewViolation.itf.json"))
+	holders := service.holders()
+	require.Len(t, holders, 2)
+	assert.NotEqual(t, service.clients[holders[0]].Lease().Token, service.clients[holders[1]].Lease().Token)
+}
@@ -0,0 +1,162 @@
+// Package lock is a small implementation of a lock service with leases. The
+// server grants the lock for a lease of a fixed duration, and tells the
+// client when the lease expires on the clock of the server, together with a
+// fencing token, which grows with every acquisition. Once a lease expired,
+// another client may acquire the lock, even when the server has not reaped
+// the lease yet.
+//
+// The server and every client read the time from their own Clock. A client
+// believes that it holds the lock while its own clock is below the expiry
+// minus a guard. This is only safe as long as the clock of the client is not
+// behind the clock of the server by more than the guard, so the resources
+// that are protected by the lock should check the fencing tokens.
+package lock
+
+import (
+	"errors"
+	"time"
+)
+
+// The errors of the operations, which change nothing on the server when they fail.
+var (
+	ErrLockHeld     = errors.New("lock held")
+	ErrNotHolder    = errors.New("not holder")
+	ErrLeaseExpired = errors.New("lease expired")
+	ErrNoHolder     = errors.New("no holder")
+	ErrNotExpired   = errors.New("not expired")
+)
+
+// Clock tells the time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Lease is a lease on the lock. The zero value is no lease.
+type Lease struct {
+	Token  uint64
+	Expiry time.Time
+}
+
+// Server grants leases on a single lock.
+type Server struct {
+	clock    Clock
+	duration time.Duration
+	holder   string
+	lease    Lease
+}
+
+// NewServer returns a server that grants leases of the given duration.
+func NewServer(clock Clock, duration time.Duration) *Server {
+	return &Server{clock: clock, duration: duration}
+}
+
+// Holder returns the holder of the lock, or "" if the lock is free, and the
+// last lease that the server granted.
+func (s *Server) Holder() (string, Lease) {
+	return s.holder, s.lease
+}
+
+func (s *Server) expired() bool {
+	return !s.clock.Now().Before(s.lease.Expiry)
+}
+
+func (s *Server) checkHolder(client string, token uint64) error {
+	if s.holder != client || s.lease.Token != token {
+		return ErrNotHolder
+	}
+	if s.expired() {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// Acquire grants the lock to a client, unless another lease is still valid.
+func (s *Server) Acquire(client string) (Lease, error) {
+	if s.holder != "" && !s.expired() {
+		return Lease{}, ErrLockHeld
+	}
+	s.holder = client
+	s.lease = Lease{Token: s.lease.Token + 1, Expiry: s.clock.Now().Add(s.duration)}
+	return s.lease, nil
+}
+
+// Renew extends the lease with the given token, which must still be valid.
+func (s *Server) Renew(client string, token uint64) (Lease, error) {
+	if err := s.checkHolder(client, token); err != nil {
+		return Lease{}, err
+	}
+	s.lease.Expiry = s.clock.Now().Add(s.duration)
+	return s.lease, nil
+}
+
+// Release frees the lock, which must still be held with the given token.
+func (s *Server) Release(client string, token uint64) error {
+	if err := s.checkHolder(client, token); err != nil {
+		return err
+	}
+	s.holder = ""
+	return nil
+}
+
+// Expire reaps an expired lease.
+func (s *Server) Expire() error {
+	if s.holder == "" {
+		return ErrNoHolder
+	}
+	if !s.expired() {
+		return ErrNotExpired
+	}
+	s.holder = ""
+	return nil
+}
+
+// Client uses the lock of a server.
+type Client struct {
+	Name   string
+	clock  Clock
+	guard  time.Duration
+	server *Server
+	lease  Lease
+}
+
+// NewClient returns a client without a lease, which stops using its lease
+// the guard before it expires on its clock.
+func NewClient(name string, clock Clock, guard time.Duration, server *Server) *Client {
+	return &Client{Name: name, clock: clock, guard: guard, server: server}
+}
+
+// Lease returns the lease of the client.
+func (c *Client) Lease() Lease {
+	return c.lease
+}
+
+// Acquire acquires the lock.
+func (c *Client) Acquire() error {
+	lease, err := c.server.Acquire(c.Name)
+	if err != nil {
+		return err
+	}
+	c.lease = lease
+	return nil
+}
+
+// Renew renews the lease. When renewing fails, the client forgets its lease.
+func (c *Client) Renew() error {
+	lease, err := c.server.Renew(c.Name, c.lease.Token)
+	c.lease = lease
+	return err
+}
+
+// Release releases the lock and forgets the lease.
+func (c *Client) Release() error {
+	if err := c.server.Release(c.Name, c.lease.Token); err != nil {
+		return err
+	}
+	c.lease = Lease{}
+	return nil
+}
+
+// Holds returns whether the client believes that it holds the lock.
+func (c *Client) Holds() bool {
+	return c.lease.Token > 0 && c.clock.Now().Before(c.lease.Expiry.Add(-c.guard))
+}
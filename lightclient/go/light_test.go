@@ -0,0 +1,229 @@
+// A test harness that replays the traces of lightTest.qnt against the
+// verifier of the light client of CometBFT v0.37.2.
+//
+// Every state of a trace is a single verification: a trusted block, an
+// untrusted block, the current time, and the verdict of the spec. The blocks
+// of the spec are abstract, so we construct signed headers out of them: the
+// validators of the spec get deterministic keys, and the signers of a block
+// sign its commit. Then we call light.Verify and compare its error with the verdict.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/cometbft/cometbft/crypto"
+	"github.com/cometbft/cometbft/crypto/ed25519"
+	"github.com/cometbft/cometbft/crypto/tmhash"
+	"github.com/cometbft/cometbft/light"
+	cmtversion "github.com/cometbft/cometbft/proto/tendermint/version"
+	"github.com/cometbft/cometbft/types"
+	"github.com/cometbft/cometbft/version"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.37.2"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the constants of light.qnt
+var (
+	powers = map[string]int64{"v1": 4, "v2": 3, "v3": 2, "v4": 1}
+	// TRUSTING_PERIOD and MAX_CLOCK_DRIFT, in seconds
+	trustingPeriod = 100 * time.Second
+	maxClockDrift  = 10 * time.Second
+)
+
+// the chain of the headers and the time that the times of the spec are relative to
+const chainID = "quint-light"
+
+var genesisTime = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// a block of the spec, with the times in seconds since genesis
+type TestBlock struct {
+	height   int64
+	time     int64
+	vals     []string
+	nextVals []string
+	signers  []string
+}
+
+// a state of our testing state machine, which is also an input to the Golang test
+type TestInput struct {
+	trusted   TestBlock
+	untrusted TestBlock
+	now       int64
+	verdict   string
+}
+
+// parse the states in the ITF JSON format, as produced from lightTest.qnt
+func parseItf(t *testing.T, filename string) []TestInput {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestInput, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestInput
+		state.trusted = parseBlock(t, jsonState.Get("trusted"))
+		state.untrusted = parseBlock(t, jsonState.Get("untrusted"))
+		state.now = harness.Int64(t, jsonState.Get("now"))
+		state.verdict = jsonState.Get("verdict").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a block of light.qnt
+func parseBlock(t *testing.T, obj gjson.Result) TestBlock {
+	return TestBlock{
+		height:   harness.Int64(t, obj.Get("height")),
+		time:     harness.Int64(t, obj.Get("time")),
+		vals:     parseNames(t, obj.Get("vals")),
+		nextVals: parseNames(t, obj.Get("nextVals")),
+		signers:  parseNames(t, obj.Get("signers")),
+	}
+}
+
+// parse a set of validator names
+func parseNames(t *testing.T, obj gjson.Result) []string {
+	elems, err := itf.Set(obj)
+	require.NoError(t, err)
+	names := make([]string, 0, len(elems))
+	for _, e := range elems {
+		names = append(names, e.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the deterministic key of a validator of the spec
+func privKey(name string) crypto.PrivKey {
+	return ed25519.GenPrivKeyFromSecret([]byte(name))
+}
+
+// the validator set of the given validators of the spec
+func validatorSet(names []string) *types.ValidatorSet {
+	vals := make([]*types.Validator, 0, len(names))
+	for _, name := range names {
+		vals = append(vals, types.NewValidator(privKey(name).PubKey(), powers[name]))
+	}
+	return types.NewValidatorSet(vals)
+}
+
+// the time that is given in seconds since genesis
+func specTime(seconds int64) time.Time {
+	return genesisTime.Add(time.Duration(seconds) * time.Second)
+}
+
+// Construct a signed header out of a block of the spec. The commit has a
+// signature for every validator, in the order of the validator set,
+// which is absent when the validator is not among the signers.
+func signedHeader(t *testing.T, b TestBlock) (*types.SignedHeader, *types.ValidatorSet) {
+	vals := validatorSet(b.vals)
+	header := &types.Header{
+		Version:            cmtversion.Consensus{Block: version.BlockProtocol},
+		ChainID:            chainID,
+		Height:             b.height,
+		Time:               specTime(b.time),
+		ValidatorsHash:     vals.Hash(),
+		NextValidatorsHash: validatorSet(b.nextVals).Hash(),
+		ConsensusHash:      tmhash.Sum([]byte("consensus")),
+		AppHash:            tmhash.Sum([]byte("app")),
+		LastResultsHash:    tmhash.Sum([]byte("results")),
+		ProposerAddress:    vals.Validators[0].Address,
+	}
+	blockID := types.BlockID{
+		Hash:          header.Hash(),
+		PartSetHeader: types.PartSetHeader{Total: 1, Hash: tmhash.Sum([]byte("parts"))},
+	}
+
+	signers := make(map[string]bool)
+	for _, name := range b.signers {
+		signers[name] = true
+	}
+	names := make(map[string]string)
+	for _, name := range b.vals {
+		names[string(privKey(name).PubKey().Address())] = name
+	}
+	commit := &types.Commit{Height: b.height, BlockID: blockID, Signatures: make([]types.CommitSig, vals.Size())}
+	for idx, val := range vals.Validators {
+		commit.Signatures[idx] = types.NewCommitSigAbsent()
+		name := names[string(val.Address)]
+		if !signers[name] {
+			continue
+		}
+		commit.Signatures[idx] = types.CommitSig{
+			BlockIDFlag:      types.BlockIDFlagCommit,
+			ValidatorAddress: val.Address,
+			Timestamp:        header.Time,
+		}
+		signature, err := privKey(name).Sign(commit.VoteSignBytes(chainID, int32(idx)))
+		require.NoError(t, err)
+		commit.Signatures[idx].Signature = signature
+	}
+	return &types.SignedHeader{Header: header, Commit: commit}, vals
+}
+
+// the verdict of the spec that corresponds to an error of light.Verify
+func verdictOf(err error) string {
+	var expired light.ErrOldHeaderExpired
+	var notTrusted light.ErrNewValSetCantBeTrusted
+	switch {
+	case err == nil:
+		return "SUCCESS"
+	case errors.As(err, &expired):
+		return "EXPIRED"
+	case errors.As(err, &notTrusted):
+		return "NOT_ENOUGH_TRUST"
+	default:
+		return "INVALID"
+	}
+}
+
+// connect the test inputs to the actual code
+func executeTest(t *testing.T, s TestInput) {
+	trusted, _ := signedHeader(t, s.trusted)
+	untrusted, untrustedVals := signedHeader(t, s.untrusted)
+	// the light client verifies non-adjacent headers against the next validators of the trusted header
+	trustedNextVals := validatorSet(s.trusted.nextVals)
+	err := light.Verify(trusted, trustedNextVals, untrusted, untrustedVals,
+		trustingPeriod, specTime(s.now), maxClockDrift, light.DefaultTrustLevel)
+	assert.Equal(t, s.verdict, verdictOf(err), "the verdicts should be equal, error: %v", err)
+}
+
+// execute all verifications of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%d_%d_%s", i, s.trusted.height, s.untrusted.height, s.verdict)
+		t.Run(description, func(t *testing.T) {
+			executeTest(t, s)
+		})
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+func TestBoundaries(t *testing.T) {
+	ExecFromItf(t, tracePath("boundaries.itf.json"))
+}
@@ -0,0 +1,168 @@
+// A test harness that replays the traces of liquidstakingTest.qnt against the
+// reference pool in the package ststake, which computes with the decimals of
+// cosmos-sdk v0.46.4.
+//
+// Every state of a trace records the action that led to it in lastAction,
+// together with what the pool paid out: the minted st tokens, the redeemed
+// native tokens, the fee of a reward, or the slashed tokens. We execute the
+// action with the pool and compare what it paid out, as well as the rate,
+// digit by digit, at every step, as a drift of 10^-18 in the rate adds up
+// over the supply. Then we compare the balances and the tokens of the pool.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/liquidstaking/ststake"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the pool of liquidstaking.qnt
+type TestPool struct {
+	balances   map[string]*big.Int
+	stBalances map[string]*big.Int
+	staked     *big.Int
+	stSupply   *big.Int
+	fees       *big.Int
+	rate       sdk.Dec
+	halted     bool
+}
+
+// an action, as recorded in lastAction, with what the pool paid out, or its error
+type TestAction struct {
+	kind     string
+	user     string
+	amount   *big.Int
+	fraction sdk.Dec
+	out      *big.Int
+	error    string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	pool       TestPool
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from liquidstakingTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.pool.balances, err = itf.StrBigIntMap(jsonState.Get("pool.balances"))
+		require.NoError(t, err)
+		state.pool.stBalances, err = itf.StrBigIntMap(jsonState.Get("pool.stBalances"))
+		require.NoError(t, err)
+		state.pool.staked = harness.BigInt(t, jsonState.Get("pool.staked"))
+		state.pool.stSupply = harness.BigInt(t, jsonState.Get("pool.stSupply"))
+		state.pool.fees = harness.BigInt(t, jsonState.Get("pool.fees"))
+		state.pool.rate = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("pool.rate")), sdk.Precision)
+		state.pool.halted = jsonState.Get("pool.halted").Bool()
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.user = jsonState.Get("lastAction.user").String()
+		state.lastAction.amount = harness.BigInt(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.fraction = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("lastAction.fraction")), sdk.Precision)
+		state.lastAction.out = harness.BigInt(t, jsonState.Get("lastAction.out"))
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// execute an action with the pool, and return what the pool paid out and the error
+func executeAction(p *ststake.Pool, a TestAction) (sdk.Int, string) {
+	amount := sdk.NewIntFromBigInt(a.amount)
+	var out sdk.Int
+	var err error
+	switch a.kind {
+	case "liquidStake":
+		out, err = p.LiquidStake(a.user, amount)
+
+	case "redeem":
+		out, err = p.Redeem(a.user, amount)
+
+	case "reward":
+		out = p.Reward(amount)
+
+	case "slash":
+		out = p.Slash(a.fraction)
+
+	case "endEpoch":
+		out, err = sdk.ZeroInt(), p.EndEpoch()
+
+	default:
+		return sdk.ZeroInt(), "unknown action: " + a.kind
+	}
+	if err != nil {
+		return out, err.Error()
+	}
+	return out, ""
+}
+
+// compare the tokens of the pool with the state of the spec
+func checkState(t *testing.T, p *ststake.Pool, s TestPool) {
+	assert.Equal(t, s.rate.String(), p.Rate.String(), "rate")
+	assert.Equal(t, s.staked.String(), p.Staked.String(), "staked")
+	assert.Equal(t, s.stSupply.String(), p.StSupply.String(), "supply of st tokens")
+	assert.Equal(t, s.fees.String(), p.Fees.String(), "fees")
+	assert.Equal(t, s.halted, p.Halted, "halted")
+	for user, expected := range s.balances {
+		assert.Equal(t, expected.String(), p.BalanceOf(user).String(), "balance of %s", user)
+	}
+	for user, expected := range s.stBalances {
+		assert.Equal(t, expected.String(), p.StBalanceOf(user).String(), "st tokens of %s", user)
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	balances := make(map[string]sdk.Int)
+	for user, b := range states[0].pool.balances {
+		balances[user] = sdk.NewIntFromBigInt(b)
+	}
+	p := ststake.New(balances)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, s.lastAction.user)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				out, err := executeAction(p, s.lastAction)
+				assert.Equal(t, s.lastAction.error, err, "error")
+				assert.Equal(t, s.lastAction.out.String(), out.String(), "paid out")
+			}
+			checkState(t, p, s.pool)
+		})
+		if !ok {
+			// the states of the pool and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,157 @@
+// Package ststake is a reference implementation of a liquid staking pool in
+// the style of Stride: users stake native tokens for st tokens at the
+// redemption rate, and burn st tokens for native tokens at the same rate,
+// which follows the rewards and the slashes of the pool at the end of an
+// epoch. It computes with the decimals of cosmos-sdk v0.46.4, and truncates
+// every amount, as well as the rate, in favor of the pool:
+//
+//   - the minted st tokens are amount / rate, truncated,
+//   - the redeemed native tokens are stAmount * rate, truncated,
+//   - the rate is staked / stSupply, truncated with QuoTruncate, as a rate
+//     that is rounded up would pay out more than the pool holds.
+package ststake
+
+import (
+	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// The parameters of the pool.
+var (
+	// the share of the rewards that is kept as a protocol fee
+	Fee = sdk.NewDecWithPrec(1, 1)
+	// the bounds of the rate, out of which the pool halts
+	MinRate = sdk.NewDecWithPrec(9, 1)
+	MaxRate = sdk.NewDecWithPrec(15, 1)
+)
+
+// The errors of the pool.
+var (
+	ErrHalted            = errors.New("halted")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrZeroAmount        = errors.New("zero amount")
+	ErrInsufficientStake = errors.New("insufficient stake")
+	ErrRateOutOfBounds   = errors.New("rate out of bounds")
+)
+
+// Pool holds the tokens of the users, and the tokens that it has staked.
+type Pool struct {
+	balances   map[string]sdk.Int
+	stBalances map[string]sdk.Int
+	// the native tokens that the pool has staked
+	Staked   sdk.Int
+	StSupply sdk.Int
+	// the protocol fees of the rewards, in native tokens
+	Fees sdk.Int
+	// the native tokens per st token
+	Rate   sdk.Dec
+	Halted bool
+}
+
+// New returns a pool without stakes, whose rate is 1, for users with their
+// native tokens.
+func New(balances map[string]sdk.Int) *Pool {
+	p := &Pool{
+		balances:   make(map[string]sdk.Int),
+		stBalances: make(map[string]sdk.Int),
+		Staked:     sdk.ZeroInt(),
+		StSupply:   sdk.ZeroInt(),
+		Fees:       sdk.ZeroInt(),
+		Rate:       sdk.OneDec(),
+	}
+	for user, balance := range balances {
+		p.balances[user] = balance
+		p.stBalances[user] = sdk.ZeroInt()
+	}
+	return p
+}
+
+// BalanceOf returns the native tokens of a user.
+func (p *Pool) BalanceOf(user string) sdk.Int {
+	if b, found := p.balances[user]; found {
+		return b
+	}
+	return sdk.ZeroInt()
+}
+
+// StBalanceOf returns the st tokens of a user.
+func (p *Pool) StBalanceOf(user string) sdk.Int {
+	if b, found := p.stBalances[user]; found {
+		return b
+	}
+	return sdk.ZeroInt()
+}
+
+// LiquidStake stakes native tokens of a user, and returns the minted st tokens.
+func (p *Pool) LiquidStake(user string, amount sdk.Int) (sdk.Int, error) {
+	if p.Halted {
+		return sdk.ZeroInt(), ErrHalted
+	}
+	if amount.GT(p.BalanceOf(user)) {
+		return sdk.ZeroInt(), ErrInsufficientFunds
+	}
+	minted := sdk.NewDecFromInt(amount).Quo(p.Rate).TruncateInt()
+	if minted.IsZero() {
+		return sdk.ZeroInt(), ErrZeroAmount
+	}
+	p.balances[user] = p.BalanceOf(user).Sub(amount)
+	p.stBalances[user] = p.StBalanceOf(user).Add(minted)
+	p.Staked = p.Staked.Add(amount)
+	p.StSupply = p.StSupply.Add(minted)
+	return minted, nil
+}
+
+// Redeem burns st tokens of a user, and returns the native tokens that the
+// user receives. With a rate that is out of date, the pool may not hold
+// enough tokens.
+func (p *Pool) Redeem(user string, stAmount sdk.Int) (sdk.Int, error) {
+	if p.Halted {
+		return sdk.ZeroInt(), ErrHalted
+	}
+	if stAmount.GT(p.StBalanceOf(user)) {
+		return sdk.ZeroInt(), ErrInsufficientFunds
+	}
+	native := sdk.NewDecFromInt(stAmount).Mul(p.Rate).TruncateInt()
+	if native.IsZero() {
+		return sdk.ZeroInt(), ErrZeroAmount
+	}
+	if native.GT(p.Staked) {
+		return sdk.ZeroInt(), ErrInsufficientStake
+	}
+	p.balances[user] = p.BalanceOf(user).Add(native)
+	p.stBalances[user] = p.StBalanceOf(user).Sub(stAmount)
+	p.Staked = p.Staked.Sub(native)
+	p.StSupply = p.StSupply.Sub(stAmount)
+	return native, nil
+}
+
+// Reward restakes the rewards of the staked tokens, minus the fee, and returns the fee.
+func (p *Pool) Reward(amount sdk.Int) sdk.Int {
+	fee := sdk.NewDecFromInt(amount).Mul(Fee).TruncateInt()
+	p.Staked = p.Staked.Add(amount).Sub(fee)
+	p.Fees = p.Fees.Add(fee)
+	return fee
+}
+
+// Slash burns a fraction of the staked tokens, and returns the slashed tokens.
+func (p *Pool) Slash(fraction sdk.Dec) sdk.Int {
+	slashed := sdk.NewDecFromInt(p.Staked).Mul(fraction).TruncateInt()
+	p.Staked = p.Staked.Sub(slashed)
+	return slashed
+}
+
+// EndEpoch updates the rate to the staked tokens per st token, unless there
+// are no st tokens. A rate out of its bounds halts the pool, and is not applied.
+func (p *Pool) EndEpoch() error {
+	if p.StSupply.IsZero() {
+		return nil
+	}
+	rate := sdk.NewDecFromInt(p.Staked).QuoTruncate(sdk.NewDecFromInt(p.StSupply))
+	if rate.LT(MinRate) || rate.GT(MaxRate) {
+		p.Halted = true
+		return ErrRateOutOfBounds
+	}
+	p.Rate = rate
+	return nil
+}
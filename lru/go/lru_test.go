@@ -0,0 +1,184 @@
+// A test harness that replays the traces of lruTest.qnt against lru.Cache of
+// github.com/hashicorp/golang-lru v2.0.2.
+//
+// Every state of a trace records the operation that led to it in lastAction,
+// together with its result: whether the key was found, its value, and the
+// keys that were evicted. We apply the operation to the cache, which collects
+// the evicted keys with its eviction callback, and check the result. Then we
+// compare the contents of the cache with the spec, that is, the entries, and
+// the order of the keys from the least to the most recently used, which we
+// sort by the times of their last use in the spec.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v2.0.2"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// an operation, as recorded in lastAction
+type TestAction struct {
+	kind    string
+	key     string
+	amount  int
+	found   bool
+	value   int
+	evicted []string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	size    int
+	entries map[string]int
+	// the keys from the least to the most recently used
+	keys       []string
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from lruTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.size = int(harness.Int64(t, jsonState.Get("cache.size")))
+		state.entries = make(map[string]int)
+		entries, err := itf.Map(jsonState.Get("cache.entries"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.entries[e.Key.String()] = int(harness.Int64(t, e.Value))
+		}
+		used := make(map[string]int)
+		state.keys = make([]string, 0)
+		entries, err = itf.Map(jsonState.Get("cache.used"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			used[e.Key.String()] = int(harness.Int64(t, e.Value))
+			state.keys = append(state.keys, e.Key.String())
+		}
+		sort.Slice(state.keys, func(i, j int) bool { return used[state.keys[i]] < used[state.keys[j]] })
+		evicted, err := itf.Set(jsonState.Get("lastAction.evicted"))
+		require.NoError(t, err)
+		state.lastAction = TestAction{
+			kind:   jsonState.Get("lastAction.kind").String(),
+			key:    jsonState.Get("lastAction.key").String(),
+			amount: int(harness.Int64(t, jsonState.Get("lastAction.amount"))),
+			found:  jsonState.Get("lastAction.found").Bool(),
+			value:  int(harness.Int64(t, jsonState.Get("lastAction.value"))),
+		}
+		for _, k := range evicted {
+			state.lastAction.evicted = append(state.lastAction.evicted, k.String())
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the cache under test and the keys that its eviction callback collected
+type testCache struct {
+	cache   *lru.Cache[string, int]
+	evicted []string
+}
+
+// create an empty cache of the given size
+func setupCache(t *testing.T, size int) *testCache {
+	c := &testCache{}
+	cache, err := lru.NewWithEvict[string, int](size, func(key string, _ int) {
+		c.evicted = append(c.evicted, key)
+	})
+	require.NoError(t, err)
+	c.cache = cache
+	return c
+}
+
+// apply an operation to the cache, and check its result
+func (c *testCache) execute(t *testing.T, a TestAction) {
+	c.evicted = nil
+	switch a.kind {
+	case "add":
+		evicted := c.cache.Add(a.key, a.amount)
+		assert.Equal(t, len(a.evicted) > 0, evicted, "evicted on adding %s", a.key)
+
+	case "get":
+		value, found := c.cache.Get(a.key)
+		assert.Equal(t, a.found, found, "found %s", a.key)
+		assert.Equal(t, a.value, value, "value of %s", a.key)
+
+	case "peek":
+		value, found := c.cache.Peek(a.key)
+		assert.Equal(t, a.found, found, "found %s", a.key)
+		assert.Equal(t, a.value, value, "value of %s", a.key)
+
+	case "remove":
+		found := c.cache.Remove(a.key)
+		assert.Equal(t, a.found, found, "found %s", a.key)
+
+	case "resize":
+		evicted := c.cache.Resize(a.amount)
+		assert.Equal(t, len(a.evicted), evicted, "evicted on resizing to %d", a.amount)
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+	}
+	assert.ElementsMatch(t, a.evicted, c.evicted, "evicted keys")
+}
+
+// compare the contents of the cache with the spec
+func (c *testCache) checkState(t *testing.T, expected TestState) {
+	entries := make(map[string]int)
+	for _, key := range c.cache.Keys() {
+		value, found := c.cache.Peek(key)
+		require.True(t, found, "key %s should be in the cache", key)
+		entries[key] = value
+	}
+	assert.Equal(t, expected.entries, entries, "entries")
+	// Keys returns the keys from the oldest to the newest
+	assert.Equal(t, expected.keys, c.cache.Keys(), "keys by their last use")
+	assert.Equal(t, len(expected.entries), c.cache.Len(), "length")
+}
+
+// execute all operations of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	cache := setupCache(t, states[0].size)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, s.lastAction.key)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				cache.execute(t, s.lastAction)
+			}
+			cache.checkState(t, s)
+		})
+		if !ok {
+			// the states of the cache and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
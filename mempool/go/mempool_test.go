@@ -0,0 +1,246 @@
+// A test harness that replays the traces of mempoolTest.qnt against the
+// mempool of CometBFT v0.37.2, that is, CListMempool.
+//
+// The mempool is connected to a toy application over a local ABCI client.
+// Every state of a trace records the action that led to it in lastAction:
+// either a transaction that arrives at CheckTx, or a block that is committed.
+// We execute the action with the mempool, compare its outcome with the spec,
+// and compare the transactions in the mempool with the spec, in their order.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	abcicli "github.com/cometbft/cometbft/abci/client"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/config"
+	"github.com/cometbft/cometbft/mempool"
+	mempoolv0 "github.com/cometbft/cometbft/mempool/v0"
+	"github.com/cometbft/cometbft/proxy"
+	"github.com/cometbft/cometbft/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.37.2"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// MEMPOOL_SIZE and CACHE_SIZE of mempool.qnt
+const (
+	mempoolSize = 3
+	cacheSize   = 4
+)
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind      string
+	tx        string
+	block     []string
+	outcome   string
+	delivered []bool
+}
+
+// a state of our testing state machine
+type TestState struct {
+	txs        []string
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from mempoolTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.txs = parseStrings(jsonState.Get("mempool.txs"))
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.tx = jsonState.Get("lastAction.tx").String()
+		state.lastAction.block = parseStrings(jsonState.Get("lastAction.block"))
+		state.lastAction.outcome = jsonState.Get("lastAction.outcome").String()
+		state.lastAction.delivered = make([]bool, 0)
+		for _, d := range jsonState.Get("lastAction.delivered").Array() {
+			state.lastAction.delivered = append(state.lastAction.delivered, d.Bool())
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a list of strings
+func parseStrings(obj gjson.Result) []string {
+	strs := make([]string, 0)
+	for _, s := range obj.Array() {
+		strs = append(strs, s.String())
+	}
+	return strs
+}
+
+// The application of mempool.qnt: a transaction such as "a2" consists of an
+// account and a nonce, and it is valid as long as no transaction of its
+// account with the same or a higher nonce has been committed.
+type nonceApp struct {
+	abci.BaseApplication
+	committed map[string]int
+}
+
+var _ abci.Application = (*nonceApp)(nil)
+
+// split a transaction into its account and its nonce
+func (app *nonceApp) parseTx(tx []byte) (string, int, error) {
+	if len(tx) < 2 {
+		return "", 0, fmt.Errorf("malformed transaction: %s", tx)
+	}
+	nonce, err := strconv.Atoi(string(tx[1:]))
+	return string(tx[:1]), nonce, err
+}
+
+func (app *nonceApp) isValid(tx []byte) bool {
+	account, nonce, err := app.parseTx(tx)
+	return err == nil && nonce > app.committed[account]
+}
+
+func (app *nonceApp) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
+	if !app.isValid(req.Tx) {
+		return abci.ResponseCheckTx{Code: 1, Log: "stale nonce"}
+	}
+	return abci.ResponseCheckTx{Code: abci.CodeTypeOK}
+}
+
+// Deliver a transaction. The consensus connection is not under test,
+// so the harness calls it directly.
+func (app *nonceApp) deliver(tx []byte) *abci.ResponseDeliverTx {
+	if !app.isValid(tx) {
+		return &abci.ResponseDeliverTx{Code: 1, Log: "stale nonce"}
+	}
+	account, nonce, _ := app.parseTx(tx)
+	app.committed[account] = nonce
+	return &abci.ResponseDeliverTx{Code: abci.CodeTypeOK}
+}
+
+// the mempool under test, together with its application
+type testNode struct {
+	app     *nonceApp
+	mempool *mempoolv0.CListMempool
+	height  int64
+}
+
+// an empty mempool that forgets the invalid transactions, as in mempool.qnt
+func setupNode(t *testing.T) *testNode {
+	app := &nonceApp{committed: make(map[string]int)}
+	client := abcicli.NewLocalClient(nil, app)
+	require.NoError(t, client.Start())
+	t.Cleanup(func() { _ = client.Stop() })
+
+	cfg := config.DefaultMempoolConfig()
+	cfg.Size = mempoolSize
+	cfg.CacheSize = cacheSize
+	cfg.Recheck = true
+	cfg.KeepInvalidTxsInCache = false
+	appConn := proxy.NewAppConnMempool(client, proxy.NopMetrics())
+	return &testNode{app: app, mempool: mempoolv0.NewCListMempool(cfg, appConn, 0)}
+}
+
+// submit a transaction to CheckTx and return its outcome, as in mempool.qnt
+func (n *testNode) checkTx(t *testing.T, tx string) string {
+	var code uint32
+	called := false
+	err := n.mempool.CheckTx(types.Tx(tx), func(res *abci.Response) {
+		called = true
+		code = res.GetCheckTx().Code
+	}, mempool.TxInfo{})
+	var full mempool.ErrMempoolIsFull
+	switch {
+	case errors.Is(err, mempool.ErrTxInCache):
+		return "inCache"
+	case errors.As(err, &full):
+		return "full"
+	}
+	require.NoError(t, err)
+	// the local client calls the callback before CheckTx returns
+	require.True(t, called, "the callback of CheckTx should be called")
+	if code == abci.CodeTypeOK {
+		return "ok"
+	}
+	return "rejected"
+}
+
+// deliver a block and update the mempool, which rechecks the remaining transactions
+func (n *testNode) commit(t *testing.T, block []string) []bool {
+	n.height++
+	txs := make(types.Txs, 0, len(block))
+	responses := make([]*abci.ResponseDeliverTx, 0, len(block))
+	delivered := make([]bool, 0, len(block))
+	for _, tx := range block {
+		res := n.app.deliver([]byte(tx))
+		txs = append(txs, types.Tx(tx))
+		responses = append(responses, res)
+		delivered = append(delivered, res.Code == abci.CodeTypeOK)
+	}
+	n.mempool.Lock()
+	err := n.mempool.Update(n.height, txs, responses, nil, nil)
+	n.mempool.Unlock()
+	require.NoError(t, err)
+	return delivered
+}
+
+// compare the transactions in the mempool with the spec, in their order
+func (n *testNode) checkState(t *testing.T, s TestState) {
+	txs := make([]string, 0)
+	for _, tx := range n.mempool.ReapMaxTxs(-1) {
+		txs = append(txs, string(tx))
+	}
+	assert.Equal(t, s.txs, txs, "transactions in the mempool")
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	node := setupNode(t)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s_%s", i, s.lastAction.kind, s.lastAction.tx, s.lastAction.outcome)
+		ok := t.Run(description, func(t *testing.T) {
+			switch s.lastAction.kind {
+			case "init":
+
+			case "checkTx":
+				outcome := node.checkTx(t, s.lastAction.tx)
+				assert.Equal(t, s.lastAction.outcome, outcome, "outcome of CheckTx")
+
+			case "commit":
+				delivered := node.commit(t, s.lastAction.block)
+				assert.Equal(t, s.lastAction.delivered, delivered, "delivered transactions")
+
+			default:
+				require.Fail(t, "unknown action: "+s.lastAction.kind)
+			}
+			node.checkState(t, s)
+		})
+		if !ok {
+			// the states of the mempool and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,285 @@
+// A test harness that replays the traces of mintTest.qnt against the minter
+// of x/mint of SimApp, as shipped with cosmos-sdk v0.46.4.
+//
+// SimApp starts with a bonded validator and a funded account, and mints for
+// its first block. It is set up once for all traces, with the parameters of
+// the spec, see simchain. For every trace, we set the minter of the spec, and
+// top up the staking tokens to the supply of the spec: a delegator receives
+// and delegates its tokens, and another account receives the rest of the
+// supply and delegates the rest of the bonded tokens. Every state of a trace records
+// the action that led to it in lastAction: the beginning of a block, or a
+// delegation or an undelegation of the delegator. At the beginning of a
+// block, we compare the bonded ratio, begin the next block, which runs the
+// begin blockers of mint and staking, and compare what mint sent to the fee
+// collector. Then we compare the inflation
+// and the annual provisions, digit by digit, as well as the supply and the
+// bonded tokens, with the state of the spec.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+const denom = sdk.DefaultBondDenom
+
+// the parameters of mint.qnt
+var params = minttypes.NewParams(
+	denom,
+	sdk.NewDecWithPrec(13, 2), // INFLATION_RATE_CHANGE
+	sdk.NewDecWithPrec(20, 2), // INFLATION_MAX
+	sdk.NewDecWithPrec(7, 2),  // INFLATION_MIN
+	sdk.NewDecWithPrec(67, 2), // GOAL_BONDED
+	12,                        // BLOCKS_PER_YEAR
+)
+
+// the delegator of the spec, and the account that holds the other tokens
+const (
+	delegator = "delegator"
+	others    = "others"
+)
+
+// the errors of the transactions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"insufficient funds":                    sdkerrors.ErrInsufficientFunds,
+	"delegator does not contain delegation": stakingtypes.ErrNoDelegatorForAddress,
+	"not enough delegation shares":          stakingtypes.ErrNotEnoughDelegationShares,
+}
+
+// the chain of mint.qnt
+type TestChain struct {
+	inflation        sdk.Dec
+	annualProvisions sdk.Dec
+	supply           int64
+	bonded           int64
+	liquid           int64
+	delegated        int64
+	minted           int64
+}
+
+// an action, as recorded in lastAction, with the bonded ratio that a block
+// started with, and the tokens that it minted
+type TestAction struct {
+	kind        string
+	amount      int64
+	bondedRatio sdk.Dec
+	provision   int64
+	error       string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	chain      TestChain
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from mintTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.chain.inflation = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("chain.minter.inflation")), sdk.Precision)
+		state.chain.annualProvisions = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("chain.minter.annualProvisions")), sdk.Precision)
+		state.chain.supply = harness.Int64(t, jsonState.Get("chain.supply"))
+		state.chain.bonded = harness.Int64(t, jsonState.Get("chain.bonded"))
+		state.chain.liquid = harness.Int64(t, jsonState.Get("chain.liquid"))
+		state.chain.delegated = harness.Int64(t, jsonState.Get("chain.delegated"))
+		state.chain.minted = harness.Int64(t, jsonState.Get("chain.minted"))
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.amount = harness.Int64(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.bondedRatio = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("lastAction.bondedRatio")), sdk.Precision)
+		state.lastAction.provision = harness.Int64(t, jsonState.Get("lastAction.provision"))
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// SimApp with the parameters of the spec, which is set up once for all traces, see simchain
+var sharedChain *simchain.Chain
+
+// the chain under test at the current block
+type testChain struct {
+	*simchain.Chain
+	// the validator of SimApp, which all delegations go to
+	validator sdk.ValAddress
+	// the tokens of the fee collector before the trace
+	collected sdk.Int
+}
+
+// Reset the chain, whose parameters are set on setup, set the minter of the
+// spec, and top up the supply and the bonded tokens of SimApp to those of the spec.
+func setupChain(t *testing.T, init TestChain) *testChain {
+	if sharedChain == nil {
+		sharedChain = simchain.New(t, simchain.Config{
+			Modules: []string{simchain.Mint, simchain.Staking},
+			Setup: func(c *simchain.Chain) {
+				c.Keepers.Mint.SetParams(c.Ctx, params)
+				// the undelegations of a trace never mature, so their entries are not limited
+				stakingParams := c.Keepers.Staking.GetParams(c.Ctx)
+				stakingParams.MaxEntries = 1000
+				c.Keepers.Staking.SetParams(c.Ctx, stakingParams)
+			},
+		})
+	}
+	c := &testChain{Chain: sharedChain}
+	c.Reset()
+	c.Ctx = c.Ctx.WithBlockHeight(c.App.LastBlockHeight() + 1)
+	c.Keepers.Mint.SetMinter(c.Ctx, minttypes.NewMinter(init.inflation, init.annualProvisions))
+
+	validators := c.Keepers.Staking.GetAllValidators(c.Ctx)
+	require.Len(t, validators, 1)
+	c.validator = validators[0].GetOperator()
+	c.collected = c.feesCollected()
+
+	supply := c.Keepers.Bank.GetSupply(c.Ctx, denom).Amount.Int64()
+	bonded := c.Keepers.Staking.TotalBondedTokens(c.Ctx).Int64()
+	othersDelegated := init.bonded - bonded - init.delegated
+	othersLiquid := init.supply - supply - init.liquid - init.delegated - othersDelegated
+	require.True(t, othersDelegated >= 0 && othersLiquid >= 0, "SimApp holds more tokens than the spec")
+	require.NoError(t, c.Fund(delegator, sdk.NewCoins(sdk.NewInt64Coin(denom, init.liquid+init.delegated))))
+	require.NoError(t, c.delegate(delegator, init.delegated))
+	require.NoError(t, c.Fund(others, sdk.NewCoins(sdk.NewInt64Coin(denom, othersLiquid+othersDelegated))))
+	require.NoError(t, c.delegate(others, othersDelegated))
+	return c
+}
+
+// the tokens of the fee collector
+func (c *testChain) feesCollected() sdk.Int {
+	feeCollector := c.Keepers.Account.GetModuleAddress(authtypes.FeeCollectorName)
+	return c.Keepers.Bank.GetBalance(c.Ctx, feeCollector, denom).Amount
+}
+
+// delegate liquid tokens of an account to the validator
+func (c *testChain) delegate(name string, amount int64) error {
+	if amount == 0 {
+		return nil
+	}
+	v, found := c.Keepers.Staking.GetValidator(c.Ctx, c.validator)
+	if !found {
+		return stakingtypes.ErrNoValidatorFound
+	}
+	_, err := c.Keepers.Staking.Delegate(c.Ctx, simchain.Addr(name), sdk.NewInt(amount), stakingtypes.Unbonded, v, true)
+	return err
+}
+
+// run a transaction in a cached context, which is written only on success
+func (c *testChain) deliver(tx func(c *testChain) error) error {
+	ctx := c.Ctx
+	cacheCtx, write := ctx.CacheContext()
+	c.Ctx = cacheCtx
+	err := tx(c)
+	c.Ctx = ctx
+	if err == nil {
+		write()
+	}
+	return err
+}
+
+// apply an action of the spec, and compare what the begin blocker minted
+func (c *testChain) executeAction(t *testing.T, a TestAction) error {
+	switch a.kind {
+	case "beginBlock":
+		assert.Equal(t, a.bondedRatio.String(), c.Keepers.Staking.BondedRatio(c.Ctx).String(), "bonded ratio")
+		before := c.feesCollected()
+		c.BeginBlock(c.Ctx.BlockHeight()+1, c.Ctx.BlockTime())
+		assert.Equal(t, sdk.NewInt(a.provision).String(), c.feesCollected().Sub(before).String(), "provision")
+		return nil
+
+	case "bond":
+		return c.deliver(func(c *testChain) error {
+			return c.delegate(delegator, a.amount)
+		})
+
+	case "unbond":
+		return c.deliver(func(c *testChain) error {
+			_, err := c.Keepers.Staking.Undelegate(c.Ctx, simchain.Addr(delegator), c.validator, sdk.NewDec(a.amount))
+			return err
+		})
+
+	default:
+		return fmt.Errorf("unknown action: %s", a.kind)
+	}
+}
+
+// compare the minter, the bank, and the staking keepers with the state of the spec
+func (c *testChain) checkState(t *testing.T, s TestChain) {
+	minter := c.Keepers.Mint.GetMinter(c.Ctx)
+	assert.Equal(t, s.inflation.String(), minter.Inflation.String(), "inflation")
+	assert.Equal(t, s.annualProvisions.String(), minter.AnnualProvisions.String(), "annual provisions")
+	assert.Equal(t, sdk.NewInt(s.supply).String(), c.Keepers.Bank.GetSupply(c.Ctx, denom).Amount.String(), "supply")
+	assert.Equal(t, sdk.NewInt(s.bonded).String(), c.Keepers.Staking.TotalBondedTokens(c.Ctx).String(), "bonded tokens")
+	assert.Equal(t, sdk.NewInt(s.minted).String(), c.feesCollected().Sub(c.collected).String(), "minted tokens")
+	assert.Equal(t, sdk.NewInt(s.liquid).String(), c.Keepers.Bank.GetBalance(c.Ctx, simchain.Addr(delegator), denom).Amount.String(), "liquid tokens")
+	delegation, found := c.Keepers.Staking.GetDelegation(c.Ctx, simchain.Addr(delegator), c.validator)
+	assert.Equal(t, s.delegated > 0, found, "delegation")
+	if found {
+		assert.Equal(t, sdk.NewDec(s.delegated).String(), delegation.Shares.String(), "shares")
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	chain := setupChain(t, states[0].chain)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s", i, s.lastAction.kind)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := chain.executeAction(t, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err)
+				} else {
+					expected, found := errorsOfSpec[s.lastAction.error]
+					require.True(t, found, "unknown error: %s", s.lastAction.error)
+					require.True(t, errors.Is(err, expected), "expected %v, found %v", expected, err)
+				}
+			}
+			chain.checkState(t, s.chain)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// The inflation grows to its maximum, as too few tokens are bonded, see
+// inflationBelowMax in mintTest.qnt.
+func TestInflationCapped(t *testing.T) {
+	ExecFromItf(t, tracePath("inflationCapped.itf.json"))
+}
@@ -0,0 +1,213 @@
+// A test harness that replays the traces of multisigTest.qnt against the
+// multisig wallet in the package wallet.
+//
+// The wallet starts with the owners, the threshold, and the balance of the
+// first state of a trace. Every state records the action that led to it in
+// lastAction: a deposit, or a proposal, an approval, a revocation, or an
+// execution. We apply the action and check its error. Then we compare the
+// verdict of the wallet on every proposal, whether it is executable now,
+// with the verdict of the spec, as well as the proposals, the balance, and
+// the payments.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/multisig/wallet"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the errors of the actions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"not an owner":         wallet.ErrNotOwner,
+	"unknown proposal":     wallet.ErrUnknownProposal,
+	"proposal executed":    wallet.ErrExecuted,
+	"already approved":     wallet.ErrAlreadyApproved,
+	"not approved":         wallet.ErrNotApproved,
+	"not enough approvals": wallet.ErrNoQuorum,
+	"insufficient funds":   wallet.ErrInsufficientFunds,
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind      string
+	owner     string
+	recipient string
+	amount    uint64
+	id        uint64
+	error     string
+}
+
+// the wallet of multisig.qnt
+type TestWallet struct {
+	owners    []string
+	threshold int
+	balance   uint64
+	paid      map[string]uint64
+	proposals map[uint64]wallet.Proposal
+}
+
+// a state of our testing state machine
+type TestState struct {
+	wallet     TestWallet
+	verdicts   map[uint64]bool
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from multisigTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.wallet.owners = parseStrings(t, jsonState.Get("wallet.owners"))
+		state.wallet.threshold = int(harness.Uint64(t, jsonState.Get("wallet.threshold")))
+		state.wallet.balance = harness.Uint64(t, jsonState.Get("wallet.balance"))
+		state.wallet.paid = make(map[string]uint64)
+		entries, err := itf.Map(jsonState.Get("wallet.paid"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.wallet.paid[e.Key.String()] = harness.Uint64(t, e.Value)
+		}
+		state.wallet.proposals = make(map[uint64]wallet.Proposal)
+		entries, err = itf.Map(jsonState.Get("wallet.proposals"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.wallet.proposals[harness.Uint64(t, e.Key)] = wallet.Proposal{
+				Proposer:  e.Value.Get("proposer").String(),
+				Recipient: e.Value.Get("recipient").String(),
+				Amount:    harness.Uint64(t, e.Value.Get("amount")),
+				Approvals: parseStrings(t, e.Value.Get("approvals")),
+				Executed:  e.Value.Get("executed").Bool(),
+			}
+		}
+		state.verdicts = make(map[uint64]bool)
+		entries, err = itf.Map(jsonState.Get("verdicts"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.verdicts[harness.Uint64(t, e.Key)] = e.Value.Bool()
+		}
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.owner = jsonState.Get("lastAction.owner").String()
+		state.lastAction.recipient = jsonState.Get("lastAction.recipient").String()
+		state.lastAction.amount = harness.Uint64(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.id = harness.Uint64(t, jsonState.Get("lastAction.id"))
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a set of strings, in order
+func parseStrings(t *testing.T, obj gjson.Result) []string {
+	elems, err := itf.Set(obj)
+	require.NoError(t, err)
+	strs := make([]string, 0, len(elems))
+	for _, s := range elems {
+		strs = append(strs, s.String())
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+// apply an action to the wallet and return its error
+func executeAction(w *wallet.Wallet, a TestAction) error {
+	switch a.kind {
+	case "deposit":
+		w.Deposit(a.amount)
+		return nil
+
+	case "propose":
+		_, err := w.Propose(a.owner, a.recipient, a.amount)
+		return err
+
+	case "approve":
+		return w.Approve(a.owner, a.id)
+
+	case "revoke":
+		return w.Revoke(a.owner, a.id)
+
+	case "execute":
+		return w.Execute(a.id)
+
+	default:
+		return fmt.Errorf("unknown action: %s", a.kind)
+	}
+}
+
+// compare the wallet with the state of the spec
+func checkState(t *testing.T, w *wallet.Wallet, expected TestState) {
+	for id, executable := range expected.verdicts {
+		assert.Equal(t, executable, w.Executable(id), "whether the proposal %d is executable", id)
+	}
+	proposals := w.Proposals()
+	require.Len(t, proposals, len(expected.wallet.proposals), "proposals")
+	for id, p := range expected.wallet.proposals {
+		assert.Equal(t, p, proposals[id], "the proposal %d", id)
+	}
+	assert.Equal(t, expected.wallet.balance, w.Balance(), "balance")
+	for recipient, amount := range expected.wallet.paid {
+		assert.Equal(t, amount, w.Paid(recipient), "paid to %s", recipient)
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	initial := states[0].wallet
+	w := wallet.New(initial.owners, initial.threshold, initial.balance)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s%d", i, s.lastAction.kind, s.lastAction.owner, s.lastAction.id)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := executeAction(w, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err)
+				} else {
+					expected, found := errorsOfSpec[s.lastAction.error]
+					require.True(t, found, "unknown error: %s", s.lastAction.error)
+					require.True(t, errors.Is(err, expected), "expected %v, found %v", expected, err)
+				}
+			}
+			checkState(t, w, s)
+		})
+		if !ok {
+			// the states of the wallet and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// A proposal with a quorum cannot be executed, as another proposal spent the
+// tokens, see noSpentQuorum in multisigTest.qnt.
+func TestSpentQuorum(t *testing.T) {
+	ExecFromItf(t, tracePath("spentQuorum.itf.json"))
+}
@@ -0,0 +1,187 @@
+// Package wallet is a small implementation of a k-of-n multisig wallet. An
+// owner proposes a payment, which counts as its approval. The owners approve
+// proposals, and may revoke their approvals, until a proposal is executed.
+// Anybody may execute a proposal once the threshold of owners approved it and
+// the wallet holds its amount. A proposal is executed at most once.
+package wallet
+
+import (
+	"errors"
+	"sort"
+)
+
+// The errors of the operations, which change nothing when they fail.
+var (
+	ErrNotOwner          = errors.New("not an owner")
+	ErrUnknownProposal   = errors.New("unknown proposal")
+	ErrExecuted          = errors.New("proposal executed")
+	ErrAlreadyApproved   = errors.New("already approved")
+	ErrNotApproved       = errors.New("not approved")
+	ErrNoQuorum          = errors.New("not enough approvals")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// Proposal is a proposal to pay an amount to a recipient.
+type Proposal struct {
+	Proposer  string
+	Recipient string
+	Amount    uint64
+	// the owners that approve the proposal, in order
+	Approvals []string
+	Executed  bool
+}
+
+type proposal struct {
+	Proposal
+	approvals map[string]bool
+}
+
+// Wallet is a multisig wallet.
+type Wallet struct {
+	owners    map[string]bool
+	threshold int
+	balance   uint64
+	paid      map[string]uint64
+	proposals map[uint64]*proposal
+	nextID    uint64
+}
+
+// New returns a wallet of the owners that holds a balance, in which threshold
+// owners must approve a proposal.
+func New(owners []string, threshold int, balance uint64) *Wallet {
+	w := &Wallet{
+		owners:    make(map[string]bool, len(owners)),
+		threshold: threshold,
+		balance:   balance,
+		paid:      make(map[string]uint64),
+		proposals: make(map[uint64]*proposal),
+		nextID:    1,
+	}
+	for _, o := range owners {
+		w.owners[o] = true
+	}
+	return w
+}
+
+// Balance returns the tokens in the wallet.
+func (w *Wallet) Balance() uint64 {
+	return w.balance
+}
+
+// Paid returns the tokens that the executed proposals paid to a recipient.
+func (w *Wallet) Paid(recipient string) uint64 {
+	return w.paid[recipient]
+}
+
+// Proposals returns all proposals, executed or not, by their ID.
+func (w *Wallet) Proposals() map[uint64]Proposal {
+	proposals := make(map[uint64]Proposal, len(w.proposals))
+	for id, p := range w.proposals {
+		q := p.Proposal
+		q.Approvals = make([]string, 0, len(p.approvals))
+		for o := range p.approvals {
+			q.Approvals = append(q.Approvals, o)
+		}
+		sort.Strings(q.Approvals)
+		proposals[id] = q
+	}
+	return proposals
+}
+
+// Executable returns whether a proposal may be executed now.
+func (w *Wallet) Executable(id uint64) bool {
+	p, found := w.proposals[id]
+	if !found {
+		return false
+	}
+	return w.check(p) == nil
+}
+
+// the error of executing a proposal
+func (w *Wallet) check(p *proposal) error {
+	switch {
+	case p.Executed:
+		return ErrExecuted
+	case len(p.approvals) < w.threshold:
+		return ErrNoQuorum
+	case w.balance < p.Amount:
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// Deposit adds tokens to the wallet.
+func (w *Wallet) Deposit(amount uint64) {
+	w.balance += amount
+}
+
+// Propose records the proposal of an owner to pay an amount to a recipient,
+// with the approval of the owner, and returns its ID.
+func (w *Wallet) Propose(owner, recipient string, amount uint64) (uint64, error) {
+	if !w.owners[owner] {
+		return 0, ErrNotOwner
+	}
+	id := w.nextID
+	w.proposals[id] = &proposal{
+		Proposal:  Proposal{Proposer: owner, Recipient: recipient, Amount: amount},
+		approvals: map[string]bool{owner: true},
+	}
+	w.nextID++
+	return id, nil
+}
+
+// the proposal of an ID, if an owner may still approve or revoke it
+func (w *Wallet) pending(owner string, id uint64) (*proposal, error) {
+	if !w.owners[owner] {
+		return nil, ErrNotOwner
+	}
+	p, found := w.proposals[id]
+	if !found {
+		return nil, ErrUnknownProposal
+	}
+	if p.Executed {
+		return nil, ErrExecuted
+	}
+	return p, nil
+}
+
+// Approve records the approval of a proposal by an owner.
+func (w *Wallet) Approve(owner string, id uint64) error {
+	p, err := w.pending(owner, id)
+	if err != nil {
+		return err
+	}
+	if p.approvals[owner] {
+		return ErrAlreadyApproved
+	}
+	p.approvals[owner] = true
+	return nil
+}
+
+// Revoke withdraws the approval of a proposal by an owner.
+func (w *Wallet) Revoke(owner string, id uint64) error {
+	p, err := w.pending(owner, id)
+	if err != nil {
+		return err
+	}
+	if !p.approvals[owner] {
+		return ErrNotApproved
+	}
+	delete(p.approvals, owner)
+	return nil
+}
+
+// Execute pays the amount of a proposal to its recipient.
+func (w *Wallet) Execute(id uint64) error {
+	p, found := w.proposals[id]
+	if !found {
+		return ErrUnknownProposal
+	}
+	if err := w.check(p); err != nil {
+		return err
+	}
+	p.Executed = true
+	w.balance -= p.Amount
+	w.paid[p.Recipient] += p.Amount
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package aggregate is a reference implementation of the aggregation of the
+// price votes of validators, as in the oracle module of Terra: the price of a
+// round is the median of the votes, weighted by the power of the voters, if
+// enough power voted, and the votes outside of the reward band around the
+// median are outliers. It computes with the decimals of cosmos-sdk v0.46.4.
+//
+// On top of the prices of the rounds, the oracle keeps the time-weighted
+// average price (TWAP) of the last prices, weighted by the time that they held.
+package aggregate
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrNoQuorum is returned when too little power voted in a round.
+var ErrNoQuorum = errors.New("no quorum")
+
+// Params are the parameters of the oracle.
+type Params struct {
+	// the fraction of the total power that must vote in a round
+	VoteThreshold sdk.Dec
+	// the width of the band around the median, in which the votes are no outliers
+	RewardBand sdk.Dec
+	// the number of the last prices in the TWAP
+	TwapWindow int
+}
+
+// Vote is the price vote of a validator.
+type Vote struct {
+	Voter string
+	Power int64
+	Price sdk.Dec
+}
+
+// Ballot is the votes of a round.
+type Ballot []Vote
+
+func (b Ballot) Len() int      { return len(b) }
+func (b Ballot) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+
+// Less orders the votes by their prices, and the votes of the same price by their voters.
+func (b Ballot) Less(i, j int) bool {
+	if b[i].Price.Equal(b[j].Price) {
+		return bytes.Compare([]byte(b[i].Voter), []byte(b[j].Voter)) < 0
+	}
+	return b[i].Price.LT(b[j].Price)
+}
+
+// Power returns the power of the voters.
+func (b Ballot) Power() int64 {
+	var power int64
+	for _, v := range b {
+		power += v.Power
+	}
+	return power
+}
+
+// WeightedMedian sorts the votes, and returns the price of the first vote, at
+// which the cumulative power reaches half of the power of the ballot, rounded
+// down, or zero, if the ballot is empty.
+func (b Ballot) WeightedMedian() sdk.Dec {
+	sort.Sort(b)
+	total := b.Power()
+	var pivot int64
+	for _, v := range b {
+		pivot += v.Power
+		if pivot >= total/2 {
+			return v.Price
+		}
+	}
+	return sdk.ZeroDec()
+}
+
+// Sample is a price that held for a duration.
+type Sample struct {
+	Price    sdk.Dec
+	Duration int64
+}
+
+// Oracle aggregates the votes of the validators into prices.
+type Oracle struct {
+	params Params
+	powers map[string]int64
+
+	// whether a round has set a price
+	Priced bool
+	Price  sdk.Dec
+	// the time of the round that set the price
+	LastUpdate int64
+	// the last prices before the current one, the newest last
+	Samples []Sample
+	TWAP    sdk.Dec
+	// the rounds that every validator abstained in, or voted an outlier
+	MissCounters map[string]int64
+}
+
+// New returns an oracle of validators with their power, which has no price yet.
+func New(params Params, powers map[string]int64) *Oracle {
+	o := &Oracle{
+		params:       params,
+		powers:       powers,
+		Price:        sdk.ZeroDec(),
+		TWAP:         sdk.ZeroDec(),
+		MissCounters: make(map[string]int64),
+	}
+	for v := range powers {
+		o.MissCounters[v] = 0
+	}
+	return o
+}
+
+// TotalPower returns the power of all validators.
+func (o *Oracle) TotalPower() int64 {
+	var power int64
+	for _, p := range o.powers {
+		power += p
+	}
+	return power
+}
+
+// Tally aggregates the votes of a round at a time, in seconds, and returns the
+// median. Without a quorum, the oracle does not change. Otherwise, the median
+// is the new price, the previous price becomes a sample of the TWAP, and the
+// validators that abstained or voted an outlier miss the round.
+func (o *Oracle) Tally(time int64, votes map[string]sdk.Dec) (sdk.Dec, error) {
+	ballot := make(Ballot, 0, len(votes))
+	for voter, price := range votes {
+		ballot = append(ballot, Vote{Voter: voter, Power: o.powers[voter], Price: price})
+	}
+	threshold := o.params.VoteThreshold.MulInt64(o.TotalPower()).RoundInt64()
+	if len(ballot) == 0 || ballot.Power() < threshold {
+		return sdk.ZeroDec(), ErrNoQuorum
+	}
+	median := ballot.WeightedMedian()
+
+	spread := median.Mul(o.params.RewardBand.QuoInt64(2))
+	for v := range o.powers {
+		price, voted := votes[v]
+		if !voted || price.LT(median.Sub(spread)) || price.GT(median.Add(spread)) {
+			o.MissCounters[v]++
+		}
+	}
+
+	if o.Priced {
+		o.Samples = append(o.Samples, Sample{Price: o.Price, Duration: time - o.LastUpdate})
+		if len(o.Samples) > o.params.TwapWindow {
+			o.Samples = o.Samples[len(o.Samples)-o.params.TwapWindow:]
+		}
+	}
+	o.Priced = true
+	o.Price = median
+	o.LastUpdate = time
+	o.TWAP = o.twap()
+	return median, nil
+}
+
+// the TWAP of the samples, or the price, if there are none
+func (o *Oracle) twap() sdk.Dec {
+	var duration int64
+	weighted := sdk.ZeroDec()
+	for _, s := range o.Samples {
+		duration += s.Duration
+		weighted = weighted.Add(s.Price.MulInt64(s.Duration))
+	}
+	if duration == 0 {
+		return o.Price
+	}
+	return weighted.QuoInt64(duration)
+}
@@ -0,0 +1,176 @@
+// A test harness that replays the traces of oracleTest.qnt against the
+// reference oracle in the package aggregate, which computes with the decimals
+// of cosmos-sdk v0.46.4.
+//
+// Every state of a trace records the round that led to it in lastAction: the
+// votes of the validators, some of which collude and vote far off, the
+// median, and whether the round failed. We tally the votes with the oracle
+// and compare the median, digit by digit. Then we compare the price, the
+// samples and the TWAP, and the miss counters with the state of the spec.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/oracle/aggregate"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the parameters of oracle.qnt
+var params = aggregate.Params{
+	VoteThreshold: sdk.NewDecWithPrec(5, 1), // VOTE_THRESHOLD
+	RewardBand:    sdk.NewDecWithPrec(2, 2), // REWARD_BAND
+	TwapWindow:    3,                        // TWAP_WINDOW
+}
+
+// the validators of oracle.qnt and their power, see POWERS
+var powers = map[string]int64{"v1": 1, "v2": 3, "v3": 2, "v4": 1, "v5": 2}
+
+// a price that held for a duration
+type TestSample struct {
+	price    sdk.Dec
+	duration int64
+}
+
+// the oracle of oracle.qnt
+type TestOracle struct {
+	priced       bool
+	price        sdk.Dec
+	lastUpdate   int64
+	samples      []TestSample
+	twap         sdk.Dec
+	missCounters map[string]*big.Int
+}
+
+// a round, as recorded in lastAction
+type TestAction struct {
+	kind   string
+	votes  map[string]sdk.Dec
+	median sdk.Dec
+	error  string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	oracle     TestOracle
+	time       int64
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from oracleTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.oracle.priced = jsonState.Get("oracle.priced").Bool()
+		state.oracle.price = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("oracle.price")), sdk.Precision)
+		state.oracle.lastUpdate = harness.Int64(t, jsonState.Get("oracle.lastUpdate"))
+		for _, s := range jsonState.Get("oracle.samples").Array() {
+			state.oracle.samples = append(state.oracle.samples, TestSample{
+				price:    sdk.NewDecFromBigIntWithPrec(harness.Dec(t, s.Get("price")), sdk.Precision),
+				duration: harness.Int64(t, s.Get("duration")),
+			})
+		}
+		state.oracle.twap = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("oracle.twap")), sdk.Precision)
+		state.oracle.missCounters, err = itf.StrBigIntMap(jsonState.Get("oracle.missCounters"))
+		require.NoError(t, err)
+		state.time = harness.Int64(t, jsonState.Get("time"))
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.votes = make(map[string]sdk.Dec)
+		votes, err := itf.Map(jsonState.Get("lastAction.votes"))
+		require.NoError(t, err)
+		for _, e := range votes {
+			state.lastAction.votes[e.Key.String()] = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, e.Value), sdk.Precision)
+		}
+		state.lastAction.median = sdk.NewDecFromBigIntWithPrec(harness.Dec(t, jsonState.Get("lastAction.median")), sdk.Precision)
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// compare the oracle with the state of the spec
+func checkState(t *testing.T, o *aggregate.Oracle, expected TestOracle) {
+	assert.Equal(t, expected.priced, o.Priced, "priced")
+	assert.Equal(t, expected.price.String(), o.Price.String(), "price")
+	assert.Equal(t, expected.lastUpdate, o.LastUpdate, "last update")
+	require.Len(t, o.Samples, len(expected.samples), "samples")
+	for i, s := range expected.samples {
+		assert.Equal(t, s.price.String(), o.Samples[i].Price.String(), "price of the sample %d", i)
+		assert.Equal(t, s.duration, o.Samples[i].Duration, "duration of the sample %d", i)
+	}
+	assert.Equal(t, expected.twap.String(), o.TWAP.String(), "TWAP")
+	for v, counter := range expected.missCounters {
+		assert.Equal(t, counter.Int64(), o.MissCounters[v], "miss counter of %s", v)
+	}
+}
+
+// a short description of the votes of a round, e.g., v1_v2_v4
+func describe(votes map[string]sdk.Dec) string {
+	voters := make([]string, 0, len(votes))
+	for v := range votes {
+		voters = append(voters, v)
+	}
+	sort.Strings(voters)
+	return strings.Join(voters, "_")
+}
+
+// tally all rounds of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	o := aggregate.New(params, powers)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, describe(s.lastAction.votes))
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				median, err := o.Tally(s.time, s.lastAction.votes)
+				if s.lastAction.error != "" {
+					require.ErrorIs(t, err, aggregate.ErrNoQuorum)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, s.lastAction.median.String(), median.String(), "median")
+				}
+			}
+			checkState(t, o, s.oracle)
+		})
+		if !ok {
+			// the states of the oracle and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// The byzantine voters v4 and v5 decide the median with 3 of the power of 7,
+// as the spec predicts, see honestMajorityDecides in oracleTest.qnt.
+func TestHonestMajorityDecides(t *testing.T) {
+	ExecFromItf(t, tracePath("honestMajorityDecides.itf.json"))
+}
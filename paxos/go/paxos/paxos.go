@@ -0,0 +1,198 @@
+// Package paxos is a small implementation of single-decree Paxos with
+// proposers, acceptors, and a learner. The processes do not talk to each
+// other directly, but send their messages over a Transport, which decides
+// when and whether to deliver them to the Handle method of their destination.
+//
+// Every proposer owns the ballots that are congruent to its offset modulo
+// the number of proposers. Acceptors ignore the messages that they must not
+// answer, instead of sending negative acknowledgements.
+package paxos
+
+// Kind is the kind of a message.
+type Kind string
+
+// The kinds of messages of the two phases.
+const (
+	Msg1a Kind = "1a"
+	Msg1b Kind = "1b"
+	Msg2a Kind = "2a"
+	Msg2b Kind = "2b"
+)
+
+// Message is a message from Src to Dst with a ballot. A promise (Msg1b)
+// carries the ballot and the value that the acceptor accepted last, in MBal
+// and Val, whereas Msg2a and Msg2b carry the proposed value in Val.
+type Message struct {
+	Kind Kind
+	Src  string
+	Dst  string
+	Bal  int
+	MBal int
+	Val  string
+}
+
+// Transport sends messages to their destinations.
+type Transport interface {
+	Send(m Message)
+}
+
+// Phase is the phase of a proposer.
+type Phase string
+
+// The phases of a proposer.
+const (
+	Idle   Phase = "idle"
+	Phase1 Phase = "phase1"
+	Phase2 Phase = "phase2"
+)
+
+// quorum returns whether n out of all acceptors form a majority.
+func quorum(n int, acceptors []string) bool {
+	return 2*n > len(acceptors)
+}
+
+// Proposer proposes its value, unless an acceptor reports another value
+// that may have been chosen.
+type Proposer struct {
+	Name       string
+	Value      string
+	Ballot     int
+	Phase      Phase
+	Promises   map[string]bool
+	HighestBal int
+	HighestVal string
+
+	offset    int
+	proposers int
+	acceptors []string
+	transport Transport
+}
+
+// NewProposer returns an idle proposer with the given offset among all proposers.
+func NewProposer(name, value string, offset, proposers int, acceptors []string, transport Transport) *Proposer {
+	return &Proposer{
+		Name:      name,
+		Value:     value,
+		Phase:     Idle,
+		Promises:  make(map[string]bool),
+		offset:    offset,
+		proposers: proposers,
+		acceptors: acceptors,
+		transport: transport,
+	}
+}
+
+// NextBallot returns the ballot that the proposer starts next.
+func (p *Proposer) NextBallot() int {
+	if p.Ballot == 0 {
+		return p.offset
+	}
+	return p.Ballot + p.proposers
+}
+
+// Propose starts the next ballot and asks all acceptors for promises.
+func (p *Proposer) Propose() {
+	p.Ballot = p.NextBallot()
+	p.Phase = Phase1
+	p.Promises = make(map[string]bool)
+	p.HighestBal, p.HighestVal = 0, ""
+	for _, a := range p.acceptors {
+		p.transport.Send(Message{Kind: Msg1a, Src: p.Name, Dst: a, Bal: p.Ballot})
+	}
+}
+
+// Handle collects the promises for the current ballot. With a quorum, it
+// proposes the value of the highest accepted ballot, or its own value.
+func (p *Proposer) Handle(m Message) {
+	if m.Kind != Msg1b || p.Phase != Phase1 || m.Bal != p.Ballot {
+		return
+	}
+	p.Promises[m.Src] = true
+	if m.MBal > p.HighestBal {
+		p.HighestBal, p.HighestVal = m.MBal, m.Val
+	}
+	if !quorum(len(p.Promises), p.acceptors) {
+		return
+	}
+	value := p.Value
+	if p.HighestBal > 0 {
+		value = p.HighestVal
+	}
+	p.Phase = Phase2
+	for _, a := range p.acceptors {
+		p.transport.Send(Message{Kind: Msg2a, Src: p.Name, Dst: a, Bal: p.Ballot, Val: value})
+	}
+}
+
+// Acceptor promises ballots and accepts proposals.
+type Acceptor struct {
+	Name    string
+	MaxBal  int
+	MaxVBal int
+	MaxVal  string
+
+	learner   string
+	transport Transport
+}
+
+// NewAcceptor returns an acceptor that tells the learner about the proposals it accepts.
+func NewAcceptor(name, learner string, transport Transport) *Acceptor {
+	return &Acceptor{Name: name, learner: learner, transport: transport}
+}
+
+// Handle promises a higher ballot (Msg1a), or accepts a proposal (Msg2a),
+// unless it promised a higher ballot.
+func (a *Acceptor) Handle(m Message) {
+	switch m.Kind {
+	case Msg1a:
+		if m.Bal > a.MaxBal {
+			a.MaxBal = m.Bal
+			a.transport.Send(Message{Kind: Msg1b, Src: a.Name, Dst: m.Src, Bal: m.Bal, MBal: a.MaxVBal, Val: a.MaxVal})
+		}
+
+	case Msg2a:
+		if m.Bal >= a.MaxBal {
+			a.MaxBal, a.MaxVBal, a.MaxVal = m.Bal, m.Bal, m.Val
+			a.transport.Send(Message{Kind: Msg2b, Src: a.Name, Dst: a.learner, Bal: m.Bal, Val: m.Val})
+		}
+	}
+}
+
+// Learner learns the chosen value from the acceptors.
+type Learner struct {
+	Name string
+	// the acceptors that accepted a ballot, and the value of the ballot
+	Accepted map[int]map[string]bool
+	Values   map[int]string
+	// the values that were chosen, which are at most one
+	Chosen map[string]bool
+
+	acceptors []string
+}
+
+// NewLearner returns a learner that has not learned anything.
+func NewLearner(name string, acceptors []string) *Learner {
+	return &Learner{
+		Name:      name,
+		Accepted:  make(map[int]map[string]bool),
+		Values:    make(map[int]string),
+		Chosen:    make(map[string]bool),
+		acceptors: acceptors,
+	}
+}
+
+// Handle records an accepted proposal (Msg2b). A value is chosen,
+// once a quorum accepted a ballot with this value.
+func (l *Learner) Handle(m Message) {
+	if m.Kind != Msg2b {
+		return
+	}
+	if l.Accepted[m.Bal] == nil {
+		l.Accepted[m.Bal] = make(map[string]bool)
+	}
+	l.Accepted[m.Bal][m.Src] = true
+	l.Values[m.Bal] = m.Val
+	if quorum(len(l.Accepted[m.Bal]), l.acceptors) {
+		l.Chosen[m.Val] = true
+	}
+}
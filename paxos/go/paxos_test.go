@@ -0,0 +1,279 @@
+// A test harness that replays the traces of paxosTest.qnt against the
+// implementation of Paxos in the package paxos.
+//
+// The processes send their messages to a scheduler of the package scheduler,
+// which keeps them in flight. Every state of a trace records the action that
+// led to it in lastAction: a proposer starting its next ballot, or a message
+// being delivered or dropped. We impose this order on the processes by
+// delivering or dropping exactly this message. Then we compare the states of
+// all processes and the messages in flight with the state of the spec.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/paxos/paxos"
+	"github.com/informalsystems/quint-sandbox/paxos/scheduler"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the name of the learner in the spec
+const learner = "learner"
+
+// a proposer of paxos.qnt
+type TestProposer struct {
+	offset     int
+	value      string
+	ballot     int
+	phase      string
+	promises   []string
+	highestBal int
+	highestVal string
+}
+
+// an acceptor of paxos.qnt
+type TestAcceptor struct {
+	maxBal  int
+	maxVBal int
+	maxVal  string
+}
+
+// the learner of paxos.qnt
+type TestLearner struct {
+	accepted map[int][]string
+	values   map[int]string
+	chosen   []string
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind string
+	node string
+	msg  paxos.Message
+}
+
+// a state of our testing state machine
+type TestState struct {
+	proposers  map[string]TestProposer
+	acceptors  map[string]TestAcceptor
+	learner    TestLearner
+	msgs       []paxos.Message
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from paxosTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.proposers = make(map[string]TestProposer)
+		entries, err := itf.Map(jsonState.Get("sys.proposers"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.proposers[e.Key.String()] = TestProposer{
+				offset:     int(harness.Int64(t, e.Value.Get("offset"))),
+				value:      e.Value.Get("value").String(),
+				ballot:     int(harness.Int64(t, e.Value.Get("ballot"))),
+				phase:      e.Value.Get("phase").String(),
+				promises:   parseStrings(t, e.Value.Get("promises")),
+				highestBal: int(harness.Int64(t, e.Value.Get("highestBal"))),
+				highestVal: e.Value.Get("highestVal").String(),
+			}
+		}
+		state.acceptors = make(map[string]TestAcceptor)
+		entries, err = itf.Map(jsonState.Get("sys.acceptors"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.acceptors[e.Key.String()] = TestAcceptor{
+				maxBal:  int(harness.Int64(t, e.Value.Get("maxBal"))),
+				maxVBal: int(harness.Int64(t, e.Value.Get("maxVBal"))),
+				maxVal:  e.Value.Get("maxVal").String(),
+			}
+		}
+		state.learner = TestLearner{
+			accepted: make(map[int][]string),
+			values:   make(map[int]string),
+			chosen:   parseStrings(t, jsonState.Get("sys.learner.chosen")),
+		}
+		entries, err = itf.Map(jsonState.Get("sys.learner.accepted"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.learner.accepted[int(harness.Int64(t, e.Key))] = parseStrings(t, e.Value)
+		}
+		entries, err = itf.Map(jsonState.Get("sys.learner.values"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.learner.values[int(harness.Int64(t, e.Key))] = e.Value.String()
+		}
+		msgs, err := itf.Set(jsonState.Get("sys.msgs"))
+		require.NoError(t, err)
+		for _, m := range msgs {
+			state.msgs = append(state.msgs, parseMessage(t, m))
+		}
+		state.lastAction = TestAction{
+			kind: jsonState.Get("lastAction.kind").String(),
+			node: jsonState.Get("lastAction.node").String(),
+			msg:  parseMessage(t, jsonState.Get("lastAction.msg")),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a message of paxos.qnt
+func parseMessage(t *testing.T, obj gjson.Result) paxos.Message {
+	return paxos.Message{
+		Kind: paxos.Kind(obj.Get("kind").String()),
+		Src:  obj.Get("src").String(),
+		Dst:  obj.Get("dst").String(),
+		Bal:  int(harness.Int64(t, obj.Get("bal"))),
+		MBal: int(harness.Int64(t, obj.Get("mbal"))),
+		Val:  obj.Get("val").String(),
+	}
+}
+
+// parse a set of strings, in order
+func parseStrings(t *testing.T, obj gjson.Result) []string {
+	elems, err := itf.Set(obj)
+	require.NoError(t, err)
+	strs := make([]string, 0, len(elems))
+	for _, e := range elems {
+		strs = append(strs, e.String())
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+// the keys of a set, in order
+func keys[K int | string](set map[K]bool) []K {
+	ks := make([]K, 0, len(set))
+	for k := range set {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool { return ks[i] < ks[j] })
+	return ks
+}
+
+// the processes under test and the scheduler of their messages
+type testSystem struct {
+	sched     *scheduler.Scheduler[paxos.Message]
+	proposers map[string]*paxos.Proposer
+	acceptors map[string]*paxos.Acceptor
+	learner   *paxos.Learner
+}
+
+// create the processes of the initial state and register them with the scheduler
+func setupSystem(init TestState) *testSystem {
+	sched := scheduler.New(func(m paxos.Message) string { return m.Dst })
+	names := make([]string, 0, len(init.acceptors))
+	for name := range init.acceptors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s := &testSystem{
+		sched:     sched,
+		proposers: make(map[string]*paxos.Proposer),
+		acceptors: make(map[string]*paxos.Acceptor),
+		learner:   paxos.NewLearner(learner, names),
+	}
+	sched.Register(learner, s.learner.Handle)
+	for _, name := range names {
+		s.acceptors[name] = paxos.NewAcceptor(name, learner, sched)
+		sched.Register(name, s.acceptors[name].Handle)
+	}
+	for name, p := range init.proposers {
+		s.proposers[name] = paxos.NewProposer(name, p.value, p.offset, len(init.proposers), names, sched)
+		sched.Register(name, s.proposers[name].Handle)
+	}
+	return s
+}
+
+// execute an action of the spec
+func (s *testSystem) execute(t *testing.T, a TestAction) {
+	switch a.kind {
+	case "propose":
+		s.proposers[a.node].Propose()
+
+	case "deliver":
+		require.NoError(t, s.sched.Deliver(a.msg))
+
+	case "drop":
+		require.NoError(t, s.sched.Drop(a.msg))
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+	}
+}
+
+// compare the processes and the messages in flight with the state of the spec
+func (s *testSystem) checkState(t *testing.T, expected TestState) {
+	for name, e := range expected.proposers {
+		p := s.proposers[name]
+		assert.Equal(t, e.ballot, p.Ballot, "ballot of %s", name)
+		assert.Equal(t, e.phase, string(p.Phase), "phase of %s", name)
+		assert.Equal(t, e.promises, keys(p.Promises), "promises of %s", name)
+		assert.Equal(t, e.highestBal, p.HighestBal, "highest accepted ballot of %s", name)
+		assert.Equal(t, e.highestVal, p.HighestVal, "highest accepted value of %s", name)
+	}
+	for name, e := range expected.acceptors {
+		a := s.acceptors[name]
+		assert.Equal(t, e.maxBal, a.MaxBal, "promised ballot of %s", name)
+		assert.Equal(t, e.maxVBal, a.MaxVBal, "accepted ballot of %s", name)
+		assert.Equal(t, e.maxVal, a.MaxVal, "accepted value of %s", name)
+	}
+	accepted := make(map[int][]string)
+	for bal, acceptors := range s.learner.Accepted {
+		accepted[bal] = keys(acceptors)
+	}
+	assert.Equal(t, expected.learner.accepted, accepted, "accepted ballots")
+	assert.Equal(t, expected.learner.values, s.learner.Values, "values of the ballots")
+	assert.Equal(t, expected.learner.chosen, keys(s.learner.Chosen), "chosen values")
+	assert.ElementsMatch(t, expected.msgs, s.sched.Pending(), "messages in flight")
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	system := setupSystem(states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s_%s", i, s.lastAction.kind, s.lastAction.node, s.lastAction.msg.Kind)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				system.execute(t, s.lastAction)
+			}
+			system.checkState(t, s)
+		})
+		if !ok {
+			// the states of the implementation and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
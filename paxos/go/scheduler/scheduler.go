@@ -0,0 +1,84 @@
+// Package scheduler imposes the message orderings of a trace on a system
+// under test. The processes of the system send their messages to the
+// scheduler instead of a network, and the scheduler keeps them until the
+// harness delivers or drops them, one by one, as the trace says. Hence, the
+// processes run deterministically, in the order of the trace.
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrNotPending is returned for a message that is not in flight.
+var ErrNotPending = errors.New("message is not in flight")
+
+// Scheduler keeps the messages in flight, which are values of type M.
+// Every message has a destination, which handles it on delivery.
+type Scheduler[M comparable] struct {
+	dst      func(M) string
+	handlers map[string]func(M)
+	pending  map[M]int
+}
+
+// New returns a scheduler without messages, which finds the destination
+// of a message with dst.
+func New[M comparable](dst func(M) string) *Scheduler[M] {
+	return &Scheduler[M]{dst: dst, handlers: make(map[string]func(M)), pending: make(map[M]int)}
+}
+
+// Register sets the handler of the messages to a node.
+func (s *Scheduler[M]) Register(node string, handler func(M)) {
+	s.handlers[node] = handler
+}
+
+// Send puts a message in flight. A message that is sent twice is in flight twice.
+func (s *Scheduler[M]) Send(m M) {
+	s.pending[m]++
+}
+
+// Deliver hands a message in flight to the handler of its destination.
+// The handler may send new messages.
+func (s *Scheduler[M]) Deliver(m M) error {
+	if err := s.remove(m); err != nil {
+		return err
+	}
+	handler, ok := s.handlers[s.dst(m)]
+	if !ok {
+		return fmt.Errorf("no handler for %s", s.dst(m))
+	}
+	handler(m)
+	return nil
+}
+
+// Drop removes a message in flight without delivering it.
+func (s *Scheduler[M]) Drop(m M) error {
+	return s.remove(m)
+}
+
+func (s *Scheduler[M]) remove(m M) error {
+	if s.pending[m] == 0 {
+		return fmt.Errorf("%w: %v", ErrNotPending, m)
+	}
+	s.pending[m]--
+	if s.pending[m] == 0 {
+		delete(s.pending, m)
+	}
+	return nil
+}
+
+// Pending returns the messages in flight, each as often as it is in flight,
+// ordered by their string representation.
+func (s *Scheduler[M]) Pending() []M {
+	msgs := make([]M, 0, len(s.pending))
+	for m, n := range s.pending {
+		for i := 0; i < n; i++ {
+			msgs = append(msgs, m)
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		return fmt.Sprint(msgs[i]) < fmt.Sprint(msgs[j])
+	})
+	return msgs
+}
@@ -0,0 +1,339 @@
+// Package forward is a small reference implementation of multi-hop transfers
+// on top of ICS 20, which follows the refund logic of the packet-forward
+// middleware of Strangelove for ibc-go v6, but is not that middleware. The
+// chains of a Network exchange tokens over channels, and the relayer delivers
+// their packets and acknowledgements, or times the packets out, one at a time.
+//
+// A transfer carries a route: the channels that the intermediate chains
+// forward the tokens to. An intermediate chain receives the tokens into the
+// Hold address, sends them on, and acknowledges the incoming packet only when
+// the outgoing packet is settled. If the outgoing packet fails, the chain
+// refunds it to the Hold address, undoes the receive of the incoming packet,
+// and acknowledges the incoming packet with the error, so that the refund
+// travels back to the sender.
+package forward
+
+import (
+	"errors"
+	"strings"
+)
+
+// The errors of the operations, which change nothing when they fail.
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrUnknownChannel    = errors.New("unknown channel")
+	ErrUnknownPacket     = errors.New("unknown packet")
+	ErrUnknownAck        = errors.New("unknown acknowledgement")
+)
+
+// The errors of the error acknowledgements.
+const (
+	AckInvalidReceiver = "invalid receiver"
+	AckInvalidChannel  = "invalid forward channel"
+	AckTimeout         = "timeout"
+)
+
+// Hold is the address that receives the tokens that a chain forwards.
+const Hold = "hold"
+
+// EscrowAddress returns the escrow account of a channel.
+func EscrowAddress(channel string) string {
+	return "escrow-" + channel
+}
+
+// Denom is a denomination: a base denomination, and the channels that the
+// tokens traveled over, the last one first. The tokens are native on a
+// chain, if the path is empty.
+type Denom struct {
+	Base string
+	Path []string
+}
+
+// String returns the full path of a denomination, e.g., transfer/toB/uatom.
+func (d Denom) String() string {
+	var b strings.Builder
+	for _, channel := range d.Path {
+		b.WriteString("transfer/" + channel + "/")
+	}
+	b.WriteString(d.Base)
+	return b.String()
+}
+
+// whether the tokens go back over the channel that they came from
+func (d Denom) movingBack(channel string) bool {
+	return len(d.Path) > 0 && d.Path[0] == channel
+}
+
+// End is the end of a channel on a chain.
+type End struct {
+	Chain   string
+	Channel string
+}
+
+// Account is the account of an owner on a chain, in a denomination.
+type Account struct {
+	Chain string
+	Owner string
+	Denom string
+}
+
+// Packet is a packet of a transfer.
+type Packet struct {
+	ID            uint64
+	Source        string
+	SourceChannel string
+	Dest          string
+	DestChannel   string
+	// the denomination on the source chain
+	Denom  Denom
+	Amount uint64
+	Sender string
+	// the final receiver, on the last chain of the route
+	Receiver string
+	// the channels that the next chains forward the tokens to
+	Forward []string
+}
+
+// the denomination of a packet on the destination chain: the chain strips
+// the channel of a token that comes back, and prefixes its channel otherwise
+func (p Packet) received() Denom {
+	if p.Denom.movingBack(p.SourceChannel) {
+		return Denom{Base: p.Denom.Base, Path: p.Denom.Path[1:]}
+	}
+	return Denom{Base: p.Denom.Base, Path: append([]string{p.DestChannel}, p.Denom.Path...)}
+}
+
+// Ack is the acknowledgement of a packet, with the error of a failed packet.
+type Ack struct {
+	Packet  Packet
+	Success bool
+	Error   string
+}
+
+// Network is a set of chains that are connected by channels.
+type Network struct {
+	counterparty map[End]End
+	balances     map[Account]uint64
+	packets      map[uint64]Packet
+	acks         map[uint64]Ack
+	// the incoming packets that a chain forwards, by their outgoing packet
+	forwarded map[uint64]Packet
+	nextID    uint64
+}
+
+// New returns a network without channels, in which the accounts hold their balances.
+func New(balances map[Account]uint64) *Network {
+	n := &Network{
+		counterparty: make(map[End]End),
+		balances:     make(map[Account]uint64),
+		packets:      make(map[uint64]Packet),
+		acks:         make(map[uint64]Ack),
+		forwarded:    make(map[uint64]Packet),
+		nextID:       1,
+	}
+	for a, amount := range balances {
+		n.balances[a] = amount
+	}
+	return n
+}
+
+// Connect opens a channel between two ends.
+func (n *Network) Connect(a, b End) {
+	n.counterparty[a] = b
+	n.counterparty[b] = a
+}
+
+// Balance returns the tokens of an owner on a chain in a denomination.
+func (n *Network) Balance(chain, owner string, denom Denom) uint64 {
+	return n.balances[Account{Chain: chain, Owner: owner, Denom: denom.String()}]
+}
+
+// Balances returns the accounts that hold tokens.
+func (n *Network) Balances() map[Account]uint64 {
+	balances := make(map[Account]uint64)
+	for a, amount := range n.balances {
+		if amount > 0 {
+			balances[a] = amount
+		}
+	}
+	return balances
+}
+
+// Packets returns the packets that are sent, but neither received nor timed out.
+func (n *Network) Packets() map[uint64]Packet {
+	packets := make(map[uint64]Packet, len(n.packets))
+	for id, p := range n.packets {
+		packets[id] = p
+	}
+	return packets
+}
+
+// Acks returns the acknowledgements that are written, but not relayed.
+func (n *Network) Acks() map[uint64]Ack {
+	acks := make(map[uint64]Ack, len(n.acks))
+	for id, a := range n.acks {
+		acks[id] = a
+	}
+	return acks
+}
+
+// Forwarded returns the incoming packets that the chains forward, by their outgoing packets.
+func (n *Network) Forwarded() map[uint64]Packet {
+	forwarded := make(map[uint64]Packet, len(n.forwarded))
+	for id, p := range n.forwarded {
+		forwarded[id] = p
+	}
+	return forwarded
+}
+
+func (n *Network) credit(chain, owner string, denom Denom, amount uint64) {
+	n.balances[Account{Chain: chain, Owner: owner, Denom: denom.String()}] += amount
+}
+
+func (n *Network) debit(chain, owner string, denom Denom, amount uint64) {
+	n.balances[Account{Chain: chain, Owner: owner, Denom: denom.String()}] -= amount
+}
+
+func (n *Network) move(chain, from, to string, denom Denom, amount uint64) {
+	n.debit(chain, from, denom, amount)
+	n.credit(chain, to, denom, amount)
+}
+
+// send a packet over a channel: ICS 20 burns the vouchers that go back over
+// the channel that they came from, and escrows the other tokens
+func (n *Network) sendPacket(chain, channel, sender string, denom Denom, amount uint64, receiver string, forward []string) uint64 {
+	cp := n.counterparty[End{Chain: chain, Channel: channel}]
+	if denom.movingBack(channel) {
+		n.debit(chain, sender, denom, amount)
+	} else {
+		n.move(chain, sender, EscrowAddress(channel), denom, amount)
+	}
+	p := Packet{
+		ID:            n.nextID,
+		Source:        chain,
+		SourceChannel: channel,
+		Dest:          cp.Chain,
+		DestChannel:   cp.Channel,
+		Denom:         denom,
+		Amount:        amount,
+		Sender:        sender,
+		Receiver:      receiver,
+		Forward:       forward,
+	}
+	n.packets[p.ID] = p
+	n.nextID++
+	return p.ID
+}
+
+// Transfer sends tokens of a sender over the first channel of a route, and
+// returns the ID of the packet. The intermediate chains forward the tokens
+// over the other channels of the route.
+func (n *Network) Transfer(chain, sender string, denom Denom, amount uint64, receiver string, route []string) (uint64, error) {
+	if len(route) == 0 {
+		return 0, ErrUnknownChannel
+	}
+	if _, found := n.counterparty[End{Chain: chain, Channel: route[0]}]; !found {
+		return 0, ErrUnknownChannel
+	}
+	if n.Balance(chain, sender, denom) < amount {
+		return 0, ErrInsufficientFunds
+	}
+	return n.sendPacket(chain, route[0], sender, denom, amount, receiver, route[1:]), nil
+}
+
+// ICS 20 receives tokens: it unescrows the tokens that come back, and mints
+// vouchers for the other tokens
+func (n *Network) receive(p Packet, receiver string) {
+	if p.Denom.movingBack(p.SourceChannel) {
+		n.move(p.Dest, EscrowAddress(p.DestChannel), receiver, p.received(), p.Amount)
+	} else {
+		n.credit(p.Dest, receiver, p.received(), p.Amount)
+	}
+}
+
+// undo the receive of a packet into the Hold address
+func (n *Network) unreceive(p Packet) {
+	if p.Denom.movingBack(p.SourceChannel) {
+		n.move(p.Dest, Hold, EscrowAddress(p.DestChannel), p.received(), p.Amount)
+	} else {
+		n.debit(p.Dest, Hold, p.received(), p.Amount)
+	}
+}
+
+func (n *Network) writeAck(p Packet, success bool, err string) {
+	n.acks[p.ID] = Ack{Packet: p, Success: success, Error: err}
+}
+
+// Recv delivers a packet to its destination chain. If the route ends there,
+// ICS 20 receives the tokens, unless the receiver is invalid. Otherwise, the
+// middleware receives the tokens into the Hold address, and forwards them
+// over the next channel of the route, if the chain has it, and does not
+// acknowledge the packet yet.
+func (n *Network) Recv(id uint64) error {
+	p, found := n.packets[id]
+	if !found {
+		return ErrUnknownPacket
+	}
+	delete(n.packets, id)
+	if len(p.Forward) == 0 {
+		if p.Receiver == "" {
+			n.writeAck(p, false, AckInvalidReceiver)
+			return nil
+		}
+		n.receive(p, p.Receiver)
+		n.writeAck(p, true, "")
+		return nil
+	}
+	next := p.Forward[0]
+	if _, found := n.counterparty[End{Chain: p.Dest, Channel: next}]; !found {
+		n.writeAck(p, false, AckInvalidChannel)
+		return nil
+	}
+	n.receive(p, Hold)
+	outgoing := n.sendPacket(p.Dest, next, Hold, p.received(), p.Amount, p.Receiver, p.Forward[1:])
+	n.forwarded[outgoing] = p
+	return nil
+}
+
+// settle a packet on its source chain: refund it, if it failed, and
+// acknowledge the incoming packet, if the packet was forwarded
+func (n *Network) settle(p Packet, success bool, err string) {
+	if !success {
+		if p.Denom.movingBack(p.SourceChannel) {
+			n.credit(p.Source, p.Sender, p.Denom, p.Amount)
+		} else {
+			n.move(p.Source, EscrowAddress(p.SourceChannel), p.Sender, p.Denom, p.Amount)
+		}
+	}
+	incoming, found := n.forwarded[p.ID]
+	if !found {
+		return
+	}
+	delete(n.forwarded, p.ID)
+	if !success {
+		n.unreceive(incoming)
+	}
+	n.writeAck(incoming, success, err)
+}
+
+// Acknowledge delivers the acknowledgement of a packet to its source chain.
+func (n *Network) Acknowledge(id uint64) error {
+	ack, found := n.acks[id]
+	if !found {
+		return ErrUnknownAck
+	}
+	delete(n.acks, id)
+	n.settle(ack.Packet, ack.Success, ack.Error)
+	return nil
+}
+
+// Timeout proves to the source chain of a packet that it timed out.
+func (n *Network) Timeout(id uint64) error {
+	p, found := n.packets[id]
+	if !found {
+		return ErrUnknownPacket
+	}
+	delete(n.packets, id)
+	n.settle(p, false, AckTimeout)
+	return nil
+}
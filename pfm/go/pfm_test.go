@@ -0,0 +1,267 @@
+// A test harness that replays the traces of pfmTest.qnt against the
+// reference implementation of multi-hop transfers in the package forward,
+// which follows the packet-forward middleware, but does not run it.
+//
+// The chains A - B - C - D of the spec are connected by the channels of the
+// spec, and the users hold the native tokens of the spec. Every state of a
+// trace records the action that led to it in lastAction: a transfer along a
+// route, or the relayer delivering a packet or an acknowledgement, or timing
+// out a packet. We apply the action and check its error. Then we compare
+// all balances, including the escrow accounts and the hold address of the
+// middleware, as well as the packets, the acknowledgements, and the forwards
+// in flight, with the spec.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/pfm/forward"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the channels of pfm.qnt, see COUNTERPARTY
+var channels = [][2]forward.End{
+	{{Chain: "A", Channel: "toB"}, {Chain: "B", Channel: "toA"}},
+	{{Chain: "B", Channel: "toC"}, {Chain: "C", Channel: "toB"}},
+	{{Chain: "C", Channel: "toD"}, {Chain: "D", Channel: "toC"}},
+}
+
+// the errors of the transfers, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"insufficient funds": forward.ErrInsufficientFunds,
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind     string
+	chain    string
+	sender   string
+	denom    forward.Denom
+	amount   uint64
+	receiver string
+	route    []string
+	id       uint64
+	error    string
+}
+
+// the network of pfm.qnt
+type TestNetwork struct {
+	balances  map[forward.Account]uint64
+	packets   map[uint64]forward.Packet
+	acks      map[uint64]forward.Ack
+	forwarded map[uint64]forward.Packet
+}
+
+// a state of our testing state machine
+type TestState struct {
+	net        TestNetwork
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from pfmTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.net.balances = make(map[forward.Account]uint64)
+		entries, err := itf.Map(jsonState.Get("net.balances"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			key, err := itf.Tuple(e.Key)
+			require.NoError(t, err)
+			require.Len(t, key, 3)
+			account := forward.Account{Chain: key[0].String(), Owner: key[1].String(), Denom: parseDenom(key[2]).String()}
+			state.net.balances[account] = harness.Uint64(t, e.Value)
+		}
+		state.net.packets = make(map[uint64]forward.Packet)
+		entries, err = itf.Map(jsonState.Get("net.packets"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.net.packets[harness.Uint64(t, e.Key)] = parsePacket(t, e.Value)
+		}
+		state.net.acks = make(map[uint64]forward.Ack)
+		entries, err = itf.Map(jsonState.Get("net.acks"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.net.acks[harness.Uint64(t, e.Key)] = forward.Ack{
+				Packet:  parsePacket(t, e.Value.Get("packet")),
+				Success: e.Value.Get("success").Bool(),
+				Error:   e.Value.Get("error").String(),
+			}
+		}
+		state.net.forwarded = make(map[uint64]forward.Packet)
+		entries, err = itf.Map(jsonState.Get("net.forwarded"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.net.forwarded[harness.Uint64(t, e.Key)] = parsePacket(t, e.Value)
+		}
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.chain = jsonState.Get("lastAction.chain").String()
+		state.lastAction.sender = jsonState.Get("lastAction.sender").String()
+		state.lastAction.denom = parseDenom(jsonState.Get("lastAction.denom"))
+		state.lastAction.amount = harness.Uint64(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.receiver = jsonState.Get("lastAction.receiver").String()
+		state.lastAction.route = parseStrings(jsonState.Get("lastAction.route"))
+		state.lastAction.id = harness.Uint64(t, jsonState.Get("lastAction.id"))
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a list of strings
+func parseStrings(obj gjson.Result) []string {
+	var strs []string
+	for _, s := range obj.Array() {
+		strs = append(strs, s.String())
+	}
+	return strs
+}
+
+// parse a denomination of pfm.qnt
+func parseDenom(obj gjson.Result) forward.Denom {
+	return forward.Denom{Base: obj.Get("base").String(), Path: parseStrings(obj.Get("path"))}
+}
+
+// parse a packet of pfm.qnt
+func parsePacket(t *testing.T, obj gjson.Result) forward.Packet {
+	return forward.Packet{
+		ID:            harness.Uint64(t, obj.Get("id")),
+		Source:        obj.Get("source").String(),
+		SourceChannel: obj.Get("sourceChannel").String(),
+		Dest:          obj.Get("dest").String(),
+		DestChannel:   obj.Get("destChannel").String(),
+		Denom:         parseDenom(obj.Get("denom")),
+		Amount:        harness.Uint64(t, obj.Get("amount")),
+		Sender:        obj.Get("sender").String(),
+		Receiver:      obj.Get("receiver").String(),
+		Forward:       parseStrings(obj.Get("forward")),
+	}
+}
+
+// apply an action to the network and return its error
+func executeAction(n *forward.Network, a TestAction) error {
+	switch a.kind {
+	case "transfer":
+		_, err := n.Transfer(a.chain, a.sender, a.denom, a.amount, a.receiver, a.route)
+		return err
+
+	case "recv":
+		return n.Recv(a.id)
+
+	case "ack":
+		return n.Acknowledge(a.id)
+
+	case "timeout":
+		return n.Timeout(a.id)
+
+	default:
+		return fmt.Errorf("unknown action: %s", a.kind)
+	}
+}
+
+// compare a packet with the packet of the spec
+func checkPacket(t *testing.T, expected, p forward.Packet, what string) {
+	assert.Equal(t, expected.ID, p.ID, "ID of %s", what)
+	assert.Equal(t, expected.Source, p.Source, "source of %s", what)
+	assert.Equal(t, expected.SourceChannel, p.SourceChannel, "source channel of %s", what)
+	assert.Equal(t, expected.Dest, p.Dest, "destination of %s", what)
+	assert.Equal(t, expected.DestChannel, p.DestChannel, "destination channel of %s", what)
+	assert.Equal(t, expected.Denom.String(), p.Denom.String(), "denomination of %s", what)
+	assert.Equal(t, expected.Amount, p.Amount, "amount of %s", what)
+	assert.Equal(t, expected.Sender, p.Sender, "sender of %s", what)
+	assert.Equal(t, expected.Receiver, p.Receiver, "receiver of %s", what)
+	assert.Equal(t, strings.Join(expected.Forward, ","), strings.Join(p.Forward, ","), "forward of %s", what)
+}
+
+// compare the network with the state of the spec
+func checkState(t *testing.T, n *forward.Network, expected TestNetwork) {
+	balances := n.Balances()
+	for account, amount := range expected.balances {
+		assert.Equal(t, amount, balances[account], "balance of %s on %s in %s", account.Owner, account.Chain, account.Denom)
+		delete(balances, account)
+	}
+	assert.Empty(t, balances, "balances that the spec does not have")
+
+	packets := n.Packets()
+	require.Len(t, packets, len(expected.packets), "packets in flight")
+	for id, p := range expected.packets {
+		checkPacket(t, p, packets[id], fmt.Sprintf("the packet %d", id))
+	}
+	acks := n.Acks()
+	require.Len(t, acks, len(expected.acks), "acknowledgements in flight")
+	for id, ack := range expected.acks {
+		checkPacket(t, ack.Packet, acks[id].Packet, fmt.Sprintf("the packet of the acknowledgement %d", id))
+		assert.Equal(t, ack.Success, acks[id].Success, "success of the acknowledgement %d", id)
+		assert.Equal(t, ack.Error, acks[id].Error, "error of the acknowledgement %d", id)
+	}
+	forwarded := n.Forwarded()
+	require.Len(t, forwarded, len(expected.forwarded), "forwarded packets")
+	for id, p := range expected.forwarded {
+		checkPacket(t, p, forwarded[id], fmt.Sprintf("the incoming packet of %d", id))
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	n := forward.New(states[0].net.balances)
+	for _, c := range channels {
+		n.Connect(c[0], c[1])
+	}
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s%d", i, s.lastAction.kind, s.lastAction.chain, s.lastAction.id)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := executeAction(n, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err)
+				} else {
+					expected, found := errorsOfSpec[s.lastAction.error]
+					require.True(t, found, "unknown error: %s", s.lastAction.error)
+					require.True(t, errors.Is(err, expected), "expected %v, found %v", expected, err)
+				}
+			}
+			checkState(t, n, s.net)
+		})
+		if !ok {
+			// the states of the network and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// An intermediate chain refunds a forwarded packet that failed further down
+// the route, see noIntermediateRefund in pfmTest.qnt.
+func TestRefundPath(t *testing.T) {
+	ExecFromItf(t, tracePath("refundPath.itf.json"))
+}
@@ -0,0 +1,142 @@
+// Package pq is a priority queue on top of container/heap, in the style of
+// the example PriorityQueue in the documentation of container/heap.
+//
+// The item of the highest priority is popped first. Items of the same
+// priority are popped in the order in which they were pushed. Every item
+// has a unique value, by which its priority may be updated, or by which it
+// may be removed without popping it.
+package pq
+
+import (
+	"container/heap"
+	"errors"
+)
+
+// The errors of the operations, which change nothing when they fail.
+var (
+	ErrEmpty     = errors.New("empty queue")
+	ErrDuplicate = errors.New("duplicate value")
+	ErrNotFound  = errors.New("value not found")
+)
+
+// Item is an item of the queue.
+type Item struct {
+	Value    string
+	Priority int
+	// the number of pushes before the item
+	seq int
+	// the index of the item in the heap, which is maintained by the methods of heap.Interface
+	index int
+}
+
+// Index returns the index of an item in the heap.
+func (it *Item) Index() int {
+	return it.index
+}
+
+// items implements heap.Interface and holds the items.
+type items []*Item
+
+func (h items) Len() int { return len(h) }
+
+func (h items) Less(i, j int) bool {
+	// we want Pop to give us the highest, not the lowest, priority
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h items) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *items) Push(x any) {
+	item := x.(*Item)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *items) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil  // avoid memory leak
+	item.index = -1 // for safety
+	*h = old[0 : n-1]
+	return item
+}
+
+// PriorityQueue is a priority queue of values.
+type PriorityQueue struct {
+	heap   items
+	byVal  map[string]*Item
+	pushes int
+}
+
+// New returns an empty queue.
+func New() *PriorityQueue {
+	return &PriorityQueue{byVal: make(map[string]*Item)}
+}
+
+// Len returns the number of items in the queue.
+func (pq *PriorityQueue) Len() int {
+	return pq.heap.Len()
+}
+
+// Items returns the items in the order of the heap, which is only meant for
+// inspecting the queue. The items must not be modified.
+func (pq *PriorityQueue) Items() []*Item {
+	return pq.heap
+}
+
+// Push pushes a new value with a priority.
+func (pq *PriorityQueue) Push(value string, priority int) error {
+	if _, found := pq.byVal[value]; found {
+		return ErrDuplicate
+	}
+	item := &Item{Value: value, Priority: priority, seq: pq.pushes}
+	pq.pushes++
+	pq.byVal[value] = item
+	heap.Push(&pq.heap, item)
+	return nil
+}
+
+// Pop removes and returns the item of the highest priority.
+func (pq *PriorityQueue) Pop() (*Item, error) {
+	if pq.heap.Len() == 0 {
+		return nil, ErrEmpty
+	}
+	item := heap.Pop(&pq.heap).(*Item)
+	delete(pq.byVal, item.Value)
+	return item, nil
+}
+
+// Update changes the priority of a value.
+func (pq *PriorityQueue) Update(value string, priority int) error {
+	item, found := pq.byVal[value]
+	if !found {
+		return ErrNotFound
+	}
+	item.Priority = priority
+	heap.Fix(&pq.heap, item.index)
+	return nil
+}
+
+// Remove removes a value without popping it, and returns its item.
+func (pq *PriorityQueue) Remove(value string) (*Item, error) {
+	item, found := pq.byVal[value]
+	if !found {
+		return nil, ErrNotFound
+	}
+	heap.Remove(&pq.heap, item.index)
+	delete(pq.byVal, value)
+	return item, nil
+}
+
+// Less returns whether the item at index i of the heap is popped before the item at index j.
+func (pq *PriorityQueue) Less(i, j int) bool {
+	return pq.heap.Less(i, j)
+}
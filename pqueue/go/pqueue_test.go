@@ -0,0 +1,159 @@
+// A test harness that replays the traces of pqueueTest.qnt against the
+// priority queue of the package pq, which is built on top of container/heap.
+//
+// Every state of a trace records the operation that led to it in lastAction,
+// together with its value, its priority, and its error. We apply the
+// operation to the queue and check its error and the item that it popped or
+// removed. The spec only describes the contents of the queue, so we compare
+// the priorities of the values with the spec, and check the ordering
+// invariants of the heap: no item is popped before its parent, and every item
+// knows its index in the heap.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/pqueue/pq"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the errors of the operations, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"empty queue":     pq.ErrEmpty,
+	"duplicate value": pq.ErrDuplicate,
+	"value not found": pq.ErrNotFound,
+}
+
+// an operation, as recorded in lastAction
+type TestAction struct {
+	kind     string
+	value    string
+	priority int
+	error    string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	// the priorities of the values in the queue
+	priorities map[string]int
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from pqueueTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.priorities = make(map[string]int)
+		entries, err := itf.Map(jsonState.Get("queue.items"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.priorities[e.Key.String()] = int(harness.Int64(t, e.Value.Get("priority")))
+		}
+		state.lastAction = TestAction{
+			kind:     jsonState.Get("lastAction.kind").String(),
+			value:    jsonState.Get("lastAction.value").String(),
+			priority: int(harness.Int64(t, jsonState.Get("lastAction.priority"))),
+			error:    jsonState.Get("lastAction.error").String(),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// apply an operation to the queue, and check the popped or removed item
+func execute(t *testing.T, queue *pq.PriorityQueue, a TestAction) error {
+	switch a.kind {
+	case "push":
+		return queue.Push(a.value, a.priority)
+
+	case "pop":
+		item, err := queue.Pop()
+		if err == nil {
+			assert.Equal(t, a.value, item.Value, "popped value")
+			assert.Equal(t, a.priority, item.Priority, "popped priority")
+		}
+		return err
+
+	case "update":
+		return queue.Update(a.value, a.priority)
+
+	case "remove":
+		item, err := queue.Remove(a.value)
+		if err == nil {
+			assert.Equal(t, a.priority, item.Priority, "removed priority")
+		}
+		return err
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+		return nil
+	}
+}
+
+// compare the contents of the queue with the spec, and check the heap
+func checkState(t *testing.T, queue *pq.PriorityQueue, expected TestState) {
+	priorities := make(map[string]int)
+	for i, item := range queue.Items() {
+		priorities[item.Value] = item.Priority
+		assert.Equal(t, i, item.Index(), "index of %s", item.Value)
+		if i > 0 {
+			parent := (i - 1) / 2
+			assert.False(t, queue.Less(i, parent), "%s is popped before its parent %s",
+				item.Value, queue.Items()[parent].Value)
+		}
+	}
+	assert.Equal(t, expected.priorities, priorities, "priorities")
+	assert.Equal(t, len(expected.priorities), queue.Len(), "length")
+}
+
+// execute all operations of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	queue := pq.New()
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, s.lastAction.value)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := execute(t, queue, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err, "the operation should succeed")
+				} else {
+					require.True(t, errors.Is(err, errorsOfSpec[s.lastAction.error]),
+						"expected the error %q, found %v", s.lastAction.error, err)
+				}
+			}
+			checkState(t, queue, s)
+		})
+		if !ok {
+			// the states of the queue and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,328 @@
+// A test harness that replays the traces of raftTest.qnt against the RawNode
+// API of etcd/raft v3.5.9.
+//
+// Every node of the spec is a RawNode over its own memory storage, which is
+// bootstrapped with the configuration of all nodes. Every state of a trace
+// records the action that led to it in lastAction: a node timing out, which we
+// map to Campaign, or a message being delivered, which we map to Step, or
+// dropped. After every action, we persist the Ready of every node, and put
+// the vote requests and responses that the nodes send into the network of the
+// harness. All other messages, that is, the appends and heartbeats of the
+// leaders, are dropped, as in the spec.
+//
+// We compare the terms, votes, roles, leaders, and last log entries of the
+// nodes, and the messages in flight with the state of the spec. We also check
+// that every term has at most one leader, and that the terms never decrease.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"go.etcd.io/etcd/raft/v3"
+	pb "go.etcd.io/etcd/raft/v3/raftpb"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v3.5.9"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// a node of raft.qnt
+type TestNode struct {
+	term      uint64
+	vote      string
+	role      string
+	lead      string
+	lastIndex uint64
+	lastTerm  uint64
+}
+
+// a message of raft.qnt, which is comparable, so it serves as a key
+type TestMessage struct {
+	kind      string
+	src       string
+	dst       string
+	term      uint64
+	lastIndex uint64
+	lastTerm  uint64
+	reject    bool
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind string
+	node string
+	msg  TestMessage
+}
+
+// a state of our testing state machine
+type TestState struct {
+	nodes      map[string]TestNode
+	msgs       []TestMessage
+	elected    map[uint64]string
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from raftTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.nodes = make(map[string]TestNode)
+		entries, err := itf.Map(jsonState.Get("cluster.nodes"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.nodes[e.Key.String()] = TestNode{
+				term:      harness.Uint64(t, e.Value.Get("term")),
+				vote:      e.Value.Get("vote").String(),
+				role:      e.Value.Get("role").String(),
+				lead:      e.Value.Get("lead").String(),
+				lastIndex: harness.Uint64(t, e.Value.Get("lastIndex")),
+				lastTerm:  harness.Uint64(t, e.Value.Get("lastTerm")),
+			}
+		}
+		msgs, err := itf.Set(jsonState.Get("cluster.msgs"))
+		require.NoError(t, err)
+		for _, m := range msgs {
+			state.msgs = append(state.msgs, parseMessage(t, m))
+		}
+		state.elected = make(map[uint64]string)
+		elected, err := itf.Set(jsonState.Get("elected"))
+		require.NoError(t, err)
+		for _, e := range elected {
+			pair, err := itf.Tuple(e)
+			require.NoError(t, err)
+			state.elected[harness.Uint64(t, pair[0])] = pair[1].String()
+		}
+		state.lastAction = TestAction{
+			kind: jsonState.Get("lastAction.kind").String(),
+			node: jsonState.Get("lastAction.node").String(),
+			msg:  parseMessage(t, jsonState.Get("lastAction.msg")),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a message of raft.qnt
+func parseMessage(t *testing.T, obj gjson.Result) TestMessage {
+	return TestMessage{
+		kind:      obj.Get("kind").String(),
+		src:       obj.Get("src").String(),
+		dst:       obj.Get("dst").String(),
+		term:      harness.Uint64(t, obj.Get("term")),
+		lastIndex: harness.Uint64(t, obj.Get("lastIndex")),
+		lastTerm:  harness.Uint64(t, obj.Get("lastTerm")),
+		reject:    obj.Get("reject").Bool(),
+	}
+}
+
+// the nodes under test, the vote messages in flight, and the leaders of the terms
+type testCluster struct {
+	names    []string
+	ids      map[string]uint64
+	nodes    map[string]*raft.RawNode
+	storages map[string]*raft.MemoryStorage
+	msgs     map[TestMessage]pb.Message
+	leaders  map[uint64]string
+}
+
+// create and bootstrap a RawNode for every node of the spec
+func setupCluster(t *testing.T, init TestState) *testCluster {
+	c := &testCluster{
+		ids:      make(map[string]uint64),
+		nodes:    make(map[string]*raft.RawNode),
+		storages: make(map[string]*raft.MemoryStorage),
+		msgs:     make(map[TestMessage]pb.Message),
+		leaders:  make(map[uint64]string),
+	}
+	for name := range init.nodes {
+		c.names = append(c.names, name)
+	}
+	sort.Strings(c.names)
+	peers := make([]raft.Peer, 0, len(c.names))
+	for i, name := range c.names {
+		c.ids[name] = uint64(i + 1)
+		peers = append(peers, raft.Peer{ID: uint64(i + 1)})
+	}
+	logger := &raft.DefaultLogger{Logger: log.New(io.Discard, "", 0)}
+	for _, name := range c.names {
+		storage := raft.NewMemoryStorage()
+		node, err := raft.NewRawNode(&raft.Config{
+			ID:              c.ids[name],
+			ElectionTick:    10,
+			HeartbeatTick:   1,
+			Storage:         storage,
+			MaxSizePerMsg:   1024 * 1024,
+			MaxInflightMsgs: 256,
+			Logger:          logger,
+		})
+		require.NoError(t, err)
+		require.NoError(t, node.Bootstrap(peers))
+		c.nodes[name] = node
+		c.storages[name] = storage
+	}
+	c.processReady(t)
+	return c
+}
+
+// the name of a node in the spec, or "" for raft.None
+func (c *testCluster) name(id uint64) string {
+	for name, other := range c.ids {
+		if other == id {
+			return name
+		}
+	}
+	return ""
+}
+
+// Persist the Ready of every node and apply the committed configuration
+// changes. The vote messages go into the network, the others are dropped.
+func (c *testCluster) processReady(t *testing.T) {
+	for _, name := range c.names {
+		node, storage := c.nodes[name], c.storages[name]
+		for node.HasReady() {
+			rd := node.Ready()
+			require.NoError(t, storage.Append(rd.Entries))
+			if !raft.IsEmptyHardState(rd.HardState) {
+				require.NoError(t, storage.SetHardState(rd.HardState))
+			}
+			for _, m := range rd.Messages {
+				if m.Type == pb.MsgVote || m.Type == pb.MsgVoteResp {
+					c.msgs[c.specMessage(m)] = m
+				}
+			}
+			for _, entry := range rd.CommittedEntries {
+				if entry.Type == pb.EntryConfChange {
+					var cc pb.ConfChange
+					require.NoError(t, cc.Unmarshal(entry.Data))
+					node.ApplyConfChange(cc)
+				}
+			}
+			node.Advance(rd)
+		}
+	}
+}
+
+// the message of the spec that corresponds to a message of etcd/raft
+func (c *testCluster) specMessage(m pb.Message) TestMessage {
+	tm := TestMessage{
+		kind:   m.Type.String(),
+		src:    c.name(m.From),
+		dst:    c.name(m.To),
+		term:   m.Term,
+		reject: m.Reject,
+	}
+	if m.Type == pb.MsgVote {
+		tm.lastIndex, tm.lastTerm = m.Index, m.LogTerm
+	}
+	return tm
+}
+
+// execute an action of the spec
+func (c *testCluster) execute(t *testing.T, a TestAction) {
+	switch a.kind {
+	case "timeout":
+		require.NoError(t, c.nodes[a.node].Campaign())
+
+	case "deliver":
+		m, found := c.msgs[a.msg]
+		require.True(t, found, "message %+v should be in flight", a.msg)
+		delete(c.msgs, a.msg)
+		require.NoError(t, c.nodes[a.msg.dst].Step(m))
+
+	case "drop":
+		_, found := c.msgs[a.msg]
+		require.True(t, found, "message %+v should be in flight", a.msg)
+		delete(c.msgs, a.msg)
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+	}
+	c.processReady(t)
+}
+
+// compare the nodes and the messages in flight with the state of the spec,
+// and check the invariants of elections
+func (c *testCluster) checkState(t *testing.T, s TestState) {
+	for name, expected := range s.nodes {
+		status := c.nodes[name].BasicStatus()
+		assert.Equal(t, expected.term, status.Term, "term of %s", name)
+		assert.Equal(t, expected.vote, c.name(status.Vote), "vote of %s", name)
+		assert.Equal(t, expected.role, status.RaftState.String(), "role of %s", name)
+		assert.Equal(t, expected.lead, c.name(status.Lead), "leader of %s", name)
+		lastIndex, err := c.storages[name].LastIndex()
+		require.NoError(t, err)
+		lastTerm, err := c.storages[name].Term(lastIndex)
+		require.NoError(t, err)
+		assert.Equal(t, expected.lastIndex, lastIndex, "last index of %s", name)
+		assert.Equal(t, expected.lastTerm, lastTerm, "last term of %s", name)
+
+		if status.RaftState == raft.StateLeader {
+			leader, found := c.leaders[status.Term]
+			assert.True(t, !found || leader == name,
+				"%s and %s are leaders in term %d", leader, name, status.Term)
+			c.leaders[status.Term] = name
+		}
+	}
+	assert.Equal(t, s.elected, c.leaders, "elected leaders")
+
+	inFlight := make([]TestMessage, 0, len(c.msgs))
+	for m := range c.msgs {
+		inFlight = append(inFlight, m)
+	}
+	assert.ElementsMatch(t, s.msgs, inFlight, "messages in flight")
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	cluster := setupCluster(t, states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s_%s", i, s.lastAction.kind, s.lastAction.node, s.lastAction.msg.kind)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				before := make(map[string]uint64)
+				for name, node := range cluster.nodes {
+					before[name] = node.BasicStatus().Term
+				}
+				cluster.execute(t, s.lastAction)
+				for name, node := range cluster.nodes {
+					assert.GreaterOrEqual(t, node.BasicStatus().Term, before[name], "term of %s decreased", name)
+				}
+			}
+			cluster.checkState(t, s)
+		})
+		if !ok {
+			// the states of the nodes and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
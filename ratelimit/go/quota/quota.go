@@ -0,0 +1,198 @@
+// Package quota is a small implementation of an IBC rate-limiting middleware,
+// after the module ratelimit of Stride. A Limiter caps the net flow of a
+// token over every channel to a percentage of the channel value, which is
+// the supply of the token when the quota window of the channel starts.
+//
+// A send adds to the outflow, and a receive adds to the inflow, unless the
+// net flow in that direction would exceed its threshold. A packet that fails
+// is taken off the outflow again, if it was sent in the current window. At
+// the end of every hour, the windows whose duration divides the hour roll
+// over.
+package quota
+
+import "errors"
+
+// The errors of the operations, which change nothing when they fail.
+var (
+	ErrQuotaExceeded  = errors.New("quota exceeded")
+	ErrUnknownChannel = errors.New("unknown channel")
+	ErrUnknownPacket  = errors.New("unknown packet")
+)
+
+// Quota is the percentages of the channel value that may flow out and in
+// over a window, and the duration of the window in hours.
+type Quota struct {
+	MaxPercentSend int64
+	MaxPercentRecv int64
+	DurationHours  uint64
+}
+
+// Flow is the flow over a channel in the current window.
+type Flow struct {
+	Inflow       int64
+	Outflow      int64
+	ChannelValue int64
+}
+
+// RateLimit is the quota of a channel and its flow.
+type RateLimit struct {
+	Quota Quota
+	Flow  Flow
+}
+
+// Packet is a packet that was sent, and is neither acknowledged nor failed.
+type Packet struct {
+	Channel string
+	Amount  int64
+}
+
+// Limiter keeps the rate limits of the channels of a token.
+type Limiter struct {
+	limits   map[string]*RateLimit
+	supply   int64
+	hour     uint64
+	packets  map[uint64]Packet
+	pending  map[uint64]bool
+	sequence uint64
+}
+
+// New returns a limiter whose first windows start now, with the supply as
+// their channel value.
+func New(quotas map[string]Quota, supply int64) *Limiter {
+	l := &Limiter{
+		limits:   make(map[string]*RateLimit, len(quotas)),
+		supply:   supply,
+		packets:  make(map[uint64]Packet),
+		pending:  make(map[uint64]bool),
+		sequence: 1,
+	}
+	for channel, q := range quotas {
+		l.limits[channel] = &RateLimit{Quota: q, Flow: Flow{ChannelValue: supply}}
+	}
+	return l
+}
+
+// RateLimit returns the rate limit of a channel.
+func (l *Limiter) RateLimit(channel string) (RateLimit, bool) {
+	r, found := l.limits[channel]
+	if !found {
+		return RateLimit{}, false
+	}
+	return *r, true
+}
+
+// Supply returns the supply of the token.
+func (l *Limiter) Supply() int64 {
+	return l.supply
+}
+
+// Hour returns the hours that passed.
+func (l *Limiter) Hour() uint64 {
+	return l.hour
+}
+
+// Packets returns the packets in flight, by their sequence.
+func (l *Limiter) Packets() map[uint64]Packet {
+	packets := make(map[uint64]Packet, len(l.packets))
+	for s, p := range l.packets {
+		packets[s] = p
+	}
+	return packets
+}
+
+// Pending returns whether a packet in flight was sent in the current window
+// of its channel.
+func (l *Limiter) Pending(sequence uint64) bool {
+	return l.pending[sequence]
+}
+
+// Whether a net flow exceeds its threshold, which is the channel value times
+// the percentage, divided by 100 and truncated. Without a channel value,
+// there is nothing to protect, and nothing exceeds the threshold.
+func exceeds(netFlow, channelValue, maxPercent int64) bool {
+	if channelValue == 0 {
+		return false
+	}
+	return netFlow > channelValue*maxPercent/100
+}
+
+// Send adds a packet to the outflow of its channel and returns its sequence.
+func (l *Limiter) Send(channel string, amount int64) (uint64, error) {
+	r, found := l.limits[channel]
+	if !found {
+		return 0, ErrUnknownChannel
+	}
+	netOutflow := r.Flow.Outflow - r.Flow.Inflow + amount
+	if exceeds(netOutflow, r.Flow.ChannelValue, r.Quota.MaxPercentSend) {
+		return 0, ErrQuotaExceeded
+	}
+	r.Flow.Outflow += amount
+	sequence := l.sequence
+	l.packets[sequence] = Packet{Channel: channel, Amount: amount}
+	l.pending[sequence] = true
+	l.sequence++
+	return sequence, nil
+}
+
+// Recv adds a packet to the inflow of its channel.
+func (l *Limiter) Recv(channel string, amount int64) error {
+	r, found := l.limits[channel]
+	if !found {
+		return ErrUnknownChannel
+	}
+	netInflow := r.Flow.Inflow - r.Flow.Outflow + amount
+	if exceeds(netInflow, r.Flow.ChannelValue, r.Quota.MaxPercentRecv) {
+		return ErrQuotaExceeded
+	}
+	r.Flow.Inflow += amount
+	return nil
+}
+
+// AckSuccess settles a packet that was acknowledged with success.
+func (l *Limiter) AckSuccess(sequence uint64) error {
+	if _, found := l.packets[sequence]; !found {
+		return ErrUnknownPacket
+	}
+	delete(l.packets, sequence)
+	delete(l.pending, sequence)
+	return nil
+}
+
+// UndoSend settles a packet that failed, by an error acknowledgement or a
+// timeout, and takes it off the outflow, if it was sent in the current window.
+func (l *Limiter) UndoSend(sequence uint64) error {
+	p, found := l.packets[sequence]
+	if !found {
+		return ErrUnknownPacket
+	}
+	if l.pending[sequence] {
+		l.limits[p.Channel].Flow.Outflow -= p.Amount
+	}
+	delete(l.packets, sequence)
+	delete(l.pending, sequence)
+	return nil
+}
+
+// Mint changes the supply of the token. The channel values follow only when
+// their windows roll over.
+func (l *Limiter) Mint(amount int64) {
+	l.supply += amount
+}
+
+// EndHour ends the current hour, and rolls over the windows whose duration
+// divides the new hour: their flows start at zero, the current supply is
+// their channel value, and the packets in flight no longer count.
+func (l *Limiter) EndHour() {
+	l.hour++
+	for channel, r := range l.limits {
+		if l.hour%r.Quota.DurationHours != 0 {
+			continue
+		}
+		r.Flow = Flow{ChannelValue: l.supply}
+		for sequence := range l.pending {
+			if l.packets[sequence].Channel == channel {
+				delete(l.pending, sequence)
+			}
+		}
+	}
+}
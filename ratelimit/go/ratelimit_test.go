@@ -0,0 +1,208 @@
+// A test harness that replays the traces of ratelimitTest.qnt against the
+// implementation of the rate-limiting middleware in the package quota.
+//
+// The limiter starts with the quotas and the supply of the first state of a
+// trace. Every state records the action that led to it in lastAction: a send
+// or a receive over a channel, the acknowledgement or the failure of a
+// packet, a change of the supply, or the end of an hour. We apply the action
+// and check its error. Then we compare the flows and the channel values of
+// all channels, the supply, the hour, and the packets in flight with the
+// spec, including whether they count in the current window.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/ratelimit/quota"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the errors of the actions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"quota exceeded": quota.ErrQuotaExceeded,
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind     string
+	channel  string
+	amount   int64
+	sequence uint64
+	error    string
+}
+
+// the limiter of ratelimit.qnt
+type TestLimiter struct {
+	limits  map[string]quota.RateLimit
+	supply  int64
+	hour    uint64
+	packets map[uint64]quota.Packet
+	pending map[uint64]bool
+}
+
+// a state of our testing state machine
+type TestState struct {
+	limiter    TestLimiter
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from ratelimitTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.limiter.limits = make(map[string]quota.RateLimit)
+		entries, err := itf.Map(jsonState.Get("limiter.limits"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.limiter.limits[e.Key.String()] = quota.RateLimit{
+				Quota: quota.Quota{
+					MaxPercentSend: harness.Int64(t, e.Value.Get("quota.maxPercentSend")),
+					MaxPercentRecv: harness.Int64(t, e.Value.Get("quota.maxPercentRecv")),
+					DurationHours:  harness.Uint64(t, e.Value.Get("quota.durationHours")),
+				},
+				Flow: quota.Flow{
+					Inflow:       harness.Int64(t, e.Value.Get("flow.inflow")),
+					Outflow:      harness.Int64(t, e.Value.Get("flow.outflow")),
+					ChannelValue: harness.Int64(t, e.Value.Get("flow.channelValue")),
+				},
+			}
+		}
+		state.limiter.supply = harness.Int64(t, jsonState.Get("limiter.supply"))
+		state.limiter.hour = harness.Uint64(t, jsonState.Get("limiter.hour"))
+		state.limiter.packets = make(map[uint64]quota.Packet)
+		entries, err = itf.Map(jsonState.Get("limiter.packets"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.limiter.packets[harness.Uint64(t, e.Key)] = quota.Packet{
+				Channel: e.Value.Get("channel").String(),
+				Amount:  harness.Int64(t, e.Value.Get("amount")),
+			}
+		}
+		state.limiter.pending = make(map[uint64]bool)
+		elems, err := itf.Set(jsonState.Get("limiter.pending"))
+		require.NoError(t, err)
+		for _, s := range elems {
+			state.limiter.pending[harness.Uint64(t, s)] = true
+		}
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.channel = jsonState.Get("lastAction.channel").String()
+		state.lastAction.amount = harness.Int64(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.sequence = harness.Uint64(t, jsonState.Get("lastAction.sequence"))
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// apply an action to the limiter and return its error
+func executeAction(l *quota.Limiter, a TestAction) error {
+	switch a.kind {
+	case "send":
+		_, err := l.Send(a.channel, a.amount)
+		return err
+
+	case "recv":
+		return l.Recv(a.channel, a.amount)
+
+	case "ack":
+		return l.AckSuccess(a.sequence)
+
+	case "undo":
+		return l.UndoSend(a.sequence)
+
+	case "mint":
+		l.Mint(a.amount)
+		return nil
+
+	case "endHour":
+		l.EndHour()
+		return nil
+
+	default:
+		return fmt.Errorf("unknown action: %s", a.kind)
+	}
+}
+
+// compare the limiter with the state of the spec
+func checkState(t *testing.T, l *quota.Limiter, expected TestLimiter) {
+	for channel, e := range expected.limits {
+		r, found := l.RateLimit(channel)
+		require.True(t, found, "rate limit of %s", channel)
+		assert.Equal(t, e.Quota, r.Quota, "quota of %s", channel)
+		assert.Equal(t, e.Flow.Inflow, r.Flow.Inflow, "inflow of %s", channel)
+		assert.Equal(t, e.Flow.Outflow, r.Flow.Outflow, "outflow of %s", channel)
+		assert.Equal(t, e.Flow.ChannelValue, r.Flow.ChannelValue, "channel value of %s", channel)
+	}
+	assert.Equal(t, expected.supply, l.Supply(), "supply")
+	assert.Equal(t, expected.hour, l.Hour(), "hour")
+
+	packets := l.Packets()
+	require.Len(t, packets, len(expected.packets), "packets in flight")
+	for sequence, p := range expected.packets {
+		assert.Equal(t, p, packets[sequence], "the packet %d", sequence)
+		assert.Equal(t, expected.pending[sequence], l.Pending(sequence), "whether the packet %d is pending", sequence)
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	quotas := make(map[string]quota.Quota)
+	for channel, r := range states[0].limiter.limits {
+		quotas[channel] = r.Quota
+	}
+	l := quota.New(quotas, states[0].limiter.supply)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s%d", i, s.lastAction.kind, s.lastAction.channel, s.lastAction.amount)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := executeAction(l, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err)
+				} else {
+					expected, found := errorsOfSpec[s.lastAction.error]
+					require.True(t, found, "unknown error: %s", s.lastAction.error)
+					require.True(t, errors.Is(err, expected), "expected %v, found %v", expected, err)
+				}
+			}
+			checkState(t, l, s.limiter)
+		})
+		if !ok {
+			// the states of the limiter and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// A failed packet lets the net inflow of a channel exceed its threshold, see
+// netInflowWithinQuota in ratelimitTest.qnt.
+func TestUndoExceedsQuota(t *testing.T) {
+	ExecFromItf(t, tracePath("undoExceedsQuota.itf.json"))
+}
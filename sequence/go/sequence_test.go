@@ -0,0 +1,306 @@
+// A test harness that replays the traces of sequenceTest.qnt against the ante
+// handler of x/auth and the bank keeper of SimApp, as shipped with cosmos-sdk
+// v0.46.4.
+//
+// Every state of a trace records the action that led to it in lastAction:
+// funding an account, a client querying its account, or a client submitting a
+// transaction. For a transaction, we sign MsgSend with the account number and
+// the sequence of the client, and execute it as DeliverTx does: the ante
+// handler runs in a cached context, which is written when it succeeds, and the
+// message runs in another cached context. Then we compare the account numbers,
+// the sequences, and the balances with the state of the spec.
+//
+// The account numbers of the SDK count all accounts, including the module
+// accounts, so we translate the numbers of the spec to the numbers that the
+// accounts receive when they are created.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/cosmos/cosmos-sdk/simapp"
+	"github.com/cosmos/cosmos-sdk/simapp/helpers"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/x/auth/ante"
+	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+const (
+	denom   = sdk.DefaultBondDenom
+	chainID = "quint-sequence"
+	// the account that receives the tokens that the clients send
+	faucet = "faucet"
+	// the number that a transaction is signed with when the spec uses a number
+	// that no account has, e.g., 0
+	unusedNumber = math.MaxUint64
+)
+
+// the errors of a transaction, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"unknown address":            sdkerrors.ErrUnknownAddress,
+	"incorrect account sequence": sdkerrors.ErrWrongSequence,
+	"unauthorized":               sdkerrors.ErrUnauthorized,
+	"insufficient funds":         sdkerrors.ErrInsufficientFunds,
+}
+
+// an account of sequence.qnt
+type TestAccount struct {
+	number   int64
+	sequence int64
+	balance  *big.Int
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind     string
+	client   string
+	account  string
+	number   int64
+	sequence int64
+	amount   *big.Int
+	error    string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	accounts   map[string]TestAccount
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from sequenceTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.accounts = make(map[string]TestAccount)
+		entries, err := itf.Map(jsonState.Get("auth.accounts"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.accounts[e.Key.String()] = TestAccount{
+				number:   harness.Int64(t, e.Value.Get("number")),
+				sequence: harness.Int64(t, e.Value.Get("sequence")),
+				balance:  harness.BigInt(t, e.Value.Get("balance")),
+			}
+		}
+		state.lastAction = TestAction{
+			kind:     jsonState.Get("lastAction.kind").String(),
+			client:   jsonState.Get("lastAction.client").String(),
+			account:  jsonState.Get("lastAction.account").String(),
+			number:   harness.Int64(t, jsonState.Get("lastAction.number")),
+			sequence: harness.Int64(t, jsonState.Get("lastAction.sequence")),
+			amount:   harness.BigInt(t, jsonState.Get("lastAction.amount")),
+			error:    jsonState.Get("lastAction.error").String(),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the coins of the given amount in the single denomination of the spec
+func coins(amount *big.Int) sdk.Coins {
+	return sdk.NewCoins(sdk.NewCoin(denom, sdk.NewIntFromBigInt(amount)))
+}
+
+// the deterministic key of an account of the spec
+func privKey(name string) *secp256k1.PrivKey {
+	return secp256k1.GenPrivKeyFromSecret([]byte(name))
+}
+
+// the address of an account of the spec
+func addr(name string) sdk.AccAddress {
+	return sdk.AccAddress(privKey(name).PubKey().Address())
+}
+
+// SimApp, which is set up once for all traces, see simchain
+var sharedChain *simchain.Chain
+
+// the chain under test, together with the account numbers of the SDK that
+// correspond to the account numbers of the spec
+type testChain struct {
+	*simchain.Chain
+	ante    sdk.AnteHandler
+	txCfg   client.TxConfig
+	numbers map[int64]uint64
+}
+
+// reset the chain, and set up its ante handler and the accounts of the initial state
+func setupChain(t *testing.T, init TestState) *testChain {
+	if sharedChain == nil {
+		sharedChain = simchain.New(t, simchain.Config{
+			Header: tmproto.Header{Height: 1, ChainID: chainID},
+		})
+	}
+	sharedChain.Reset()
+	txCfg := simapp.MakeTestEncodingConfig().TxConfig
+	anteHandler, err := ante.NewAnteHandler(ante.HandlerOptions{
+		AccountKeeper:   sharedChain.Keepers.Account,
+		BankKeeper:      sharedChain.Keepers.Bank,
+		FeegrantKeeper:  sharedChain.App.FeeGrantKeeper,
+		SignModeHandler: txCfg.SignModeHandler(),
+		SigGasConsumer:  ante.DefaultSigVerificationGasConsumer,
+	})
+	require.NoError(t, err)
+	chain := &testChain{
+		Chain:   sharedChain,
+		ante:    anteHandler,
+		txCfg:   txCfg,
+		numbers: make(map[int64]uint64),
+	}
+	for name, a := range init.accounts {
+		chain.fund(t, name, a.balance)
+		chain.recordNumber(t, name, a.number)
+	}
+	return chain
+}
+
+// mint tokens to an account, which creates it, if it does not exist
+func (c *testChain) fund(t *testing.T, name string, amount *big.Int) {
+	require.NoError(t, banktestutil.FundAccount(c.Keepers.Bank, c.Ctx, addr(name), coins(amount)))
+}
+
+// record the number of the SDK for the number of an account of the spec
+func (c *testChain) recordNumber(t *testing.T, name string, number int64) {
+	if _, found := c.numbers[number]; found {
+		return
+	}
+	account := c.Keepers.Account.GetAccount(c.Ctx, addr(name))
+	require.NotNil(t, account, "account %s", name)
+	for _, other := range c.numbers {
+		require.Less(t, other, account.GetAccountNumber(), "the number of %s should be the latest", name)
+	}
+	c.numbers[number] = account.GetAccountNumber()
+}
+
+// the number of the SDK that a transaction is signed with
+func (c *testChain) sdkNumber(number int64) uint64 {
+	if n, found := c.numbers[number]; found {
+		return n
+	}
+	return unusedNumber
+}
+
+// Sign and deliver a transaction that sends tokens from the account of a
+// client to the faucet. As in BaseApp.runTx, the state changes of the ante
+// handler are kept when the message fails.
+func (c *testChain) deliver(t *testing.T, a TestAction) error {
+	msg := banktypes.NewMsgSend(addr(a.account), addr(faucet), coins(a.amount))
+	tx, err := helpers.GenSignedMockTx(
+		rand.New(rand.NewSource(0)),
+		c.txCfg,
+		[]sdk.Msg{msg},
+		sdk.NewCoins(),
+		helpers.DefaultGenTxGas,
+		chainID,
+		[]uint64{c.sdkNumber(a.number)},
+		[]uint64{uint64(a.sequence)},
+		privKey(a.account),
+	)
+	require.NoError(t, err)
+
+	anteCtx, writeAnte := c.Ctx.CacheContext()
+	anteCtx, err = c.ante(anteCtx, tx, false)
+	if err != nil {
+		return err
+	}
+	writeAnte()
+
+	msgCtx, writeMsg := c.Ctx.WithGasMeter(anteCtx.GasMeter()).CacheContext()
+	_, err = bankkeeper.NewMsgServerImpl(c.Keepers.Bank).Send(sdk.WrapSDKContext(msgCtx), msg)
+	if err != nil {
+		return err
+	}
+	writeMsg()
+	return nil
+}
+
+// compare the accounts with the state of the spec
+func (c *testChain) checkState(t *testing.T, s TestState) {
+	for name, expected := range s.accounts {
+		account := c.Keepers.Account.GetAccount(c.Ctx, addr(name))
+		require.NotNil(t, account, "account %s", name)
+		assert.Equal(t, c.numbers[expected.number], account.GetAccountNumber(), "account number of %s", name)
+		assert.Equal(t, uint64(expected.sequence), account.GetSequence(), "sequence of %s", name)
+		balance := c.Keepers.Bank.GetBalance(c.Ctx, addr(name), denom).Amount
+		assert.Equal(t, expected.balance.String(), balance.String(), "balance of %s", name)
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	chain := setupChain(t, states[0])
+	for i, s := range states {
+		a := s.lastAction
+		description := fmt.Sprintf("%d_%s_%s_%d", i, a.kind, a.client, a.sequence)
+		ok := t.Run(description, func(t *testing.T) {
+			switch a.kind {
+			case "fund":
+				chain.fund(t, a.account, a.amount)
+				chain.recordNumber(t, a.account, s.accounts[a.account].number)
+
+			case "query":
+				// the client reads the account number and the sequence of its account
+				account := chain.Keepers.Account.GetAccount(chain.Ctx, addr(a.account))
+				if account == nil {
+					require.NotContains(t, s.accounts, a.account, "account %s", a.account)
+				} else {
+					assert.Equal(t, chain.sdkNumber(a.number), account.GetAccountNumber(), "queried account number")
+					assert.Equal(t, uint64(a.sequence), account.GetSequence(), "queried sequence")
+				}
+
+			case "submit":
+				err := chain.deliver(t, a)
+				if a.error == "" {
+					require.NoError(t, err, "the transaction should be accepted")
+				} else {
+					require.True(t, errors.Is(err, errorsOfSpec[a.error]),
+						"expected the error %q, found %v", a.error, err)
+				}
+			}
+			chain.checkState(t, s)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,317 @@
+// Package abcisut drives an application over ABCI through the actions of a
+// trace, e.g., SimApp in the same process, or a chain binary over its socket,
+// so that a spec of the behavior of blocks, e.g., of the order of the begin
+// and end blockers, is checked end to end, and not only on the keepers.
+//
+// Every state of a trace records the action that led to it in lastAction,
+// whose kind is one of:
+//
+//   - initChain: {"chainId": string, "time": int, "initialHeight": int,
+//     "appState": string}, where the time is in seconds since the epoch, and
+//     the application state is the JSON of the genesis, if any,
+//   - finalizeBlock: {"height": int, "time": int, "txs": list, "results":
+//     list, "events": list}, which executes a block of transactions, and
+//     compares the codes of their results and, optionally, the types of the
+//     events of the block in order, with the trace,
+//   - commit: {"appHash": string}, which commits the block, and compares the
+//     application hash in hex, if the trace has one.
+//
+// The applications of cosmos-sdk v0.46.4 talk ABCI of Tendermint v0.34, which
+// has no FinalizeBlock yet: a block is executed by BeginBlock, DeliverTx for
+// every transaction, and EndBlock, whose events make up the events of the
+// block in this order. On CometBFT v0.38, the same action is a single call of
+// FinalizeBlock, so the traces of a spec do not change with the version.
+//
+// The transactions of a trace are encoded by Config.EncodeTx, e.g., to sign
+// the messages of a spec with the keys of its accounts, or sent as they are,
+// if they are strings.
+package abcisut
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	abcicli "github.com/tendermint/tendermint/abci/client"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+func init() {
+	adapter.Register("abci", New)
+}
+
+// Config tells how to connect to the application, and how to read a trace.
+type Config struct {
+	// connects to the application in the initial state of a trace, which is
+	// called on every reset, see Local and Remote
+	Connect func() (abcicli.Client, error)
+	// the path of the action in a state, by default lastAction
+	Action string
+	// encodes a transaction of a trace, by default a string is sent as is
+	EncodeTx func(tx gjson.Result) ([]byte, error)
+}
+
+// Local connects to a fresh application in the same process, e.g., to a new
+// SimApp, on every reset.
+func Local(newApp func() abci.Application) func() (abcicli.Client, error) {
+	return func() (abcicli.Client, error) {
+		return abcicli.NewLocalClient(nil, newApp()), nil
+	}
+}
+
+// Remote connects to an application at an address, with the transport socket
+// or grpc. As ABCI cannot reset an application, it has to start from its
+// genesis on every connection, e.g., by restarting it between the traces.
+func Remote(addr, transport string) func() (abcicli.Client, error) {
+	return func() (abcicli.Client, error) {
+		return abcicli.NewClient(addr, transport, true)
+	}
+}
+
+// Driver is an adapter that drives an application over ABCI.
+type Driver struct {
+	config Config
+	client abcicli.Client
+	// the chain id of InitChain, which is sent in the headers of the blocks
+	chainID string
+	// the height of the last block that was finalized, and not yet committed
+	pending int64
+}
+
+// New connects to a remote application. The options of the argument are:
+//
+//   - addr: the address of the application, e.g., tcp://localhost:26658,
+//   - transport: socket or grpc, by default socket,
+//   - action: the path of the action in a state, by default lastAction.
+func New(arg string) (adapter.Adapter, error) {
+	opts, err := adapter.ParseOptions(arg)
+	if err != nil {
+		return nil, err
+	}
+	addr := opts.Get("addr", "")
+	if addr == "" {
+		return nil, fmt.Errorf("missing the option addr")
+	}
+	return NewDriver(Config{
+		Connect: Remote(addr, opts.Get("transport", "socket")),
+		Action:  opts.Get("action", ""),
+	}), nil
+}
+
+// NewDriver returns an adapter of a configuration.
+func NewDriver(config Config) *Driver {
+	if config.Action == "" {
+		config.Action = "lastAction"
+	}
+	if config.EncodeTx == nil {
+		config.EncodeTx = rawTx
+	}
+	return &Driver{config: config}
+}
+
+// send a transaction of a trace as it is
+func rawTx(tx gjson.Result) ([]byte, error) {
+	if tx.Type != gjson.String {
+		return nil, fmt.Errorf("cannot encode the transaction %s without Config.EncodeTx", tx.Raw)
+	}
+	return []byte(tx.String()), nil
+}
+
+// Close disconnects from the application.
+func (d *Driver) Close() error {
+	if d.client == nil {
+		return nil
+	}
+	client := d.client
+	d.client = nil
+	return client.Stop()
+}
+
+func (d *Driver) Reset(init gjson.Result) error {
+	if err := d.Close(); err != nil {
+		return err
+	}
+	client, err := d.config.Connect()
+	if err != nil {
+		return err
+	}
+	if err := client.Start(); err != nil {
+		return err
+	}
+	d.client = client
+	d.chainID = ""
+	d.pending = 0
+	return nil
+}
+
+func (d *Driver) Step(state gjson.Result) error {
+	action := state.Get(d.config.Action)
+	kind := action.Get("kind").String()
+	var err error
+	switch kind {
+	case "initChain":
+		err = d.initChain(action)
+	case "finalizeBlock":
+		err = d.finalizeBlock(action)
+	case "commit":
+		err = d.commit(action)
+	default:
+		err = fmt.Errorf("unknown action %q", kind)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", kind, err)
+	}
+	return nil
+}
+
+// read an integer of an action
+func integer(action gjson.Result, path string) (int64, error) {
+	i, err := itf.Int64(action.Get(path))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return i, nil
+}
+
+// read a time of an action, in seconds since the epoch
+func seconds(action gjson.Result, path string) (time.Time, error) {
+	if !action.Get(path).Exists() {
+		return time.Time{}, nil
+	}
+	s, err := integer(action, path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(s, 0).UTC(), nil
+}
+
+func (d *Driver) initChain(action gjson.Result) error {
+	genesisTime, err := seconds(action, "time")
+	if err != nil {
+		return err
+	}
+	req := abci.RequestInitChain{
+		Time:          genesisTime,
+		ChainId:       action.Get("chainId").String(),
+		AppStateBytes: []byte(action.Get("appState").String()),
+	}
+	if action.Get("initialHeight").Exists() {
+		if req.InitialHeight, err = integer(action, "initialHeight"); err != nil {
+			return err
+		}
+	}
+	if _, err = d.client.InitChainSync(req); err != nil {
+		return err
+	}
+	d.chainID = req.ChainId
+	return nil
+}
+
+func (d *Driver) finalizeBlock(action gjson.Result) error {
+	if d.pending != 0 {
+		return fmt.Errorf("the block %d is not committed", d.pending)
+	}
+	height, err := integer(action, "height")
+	if err != nil {
+		return err
+	}
+	blockTime, err := seconds(action, "time")
+	if err != nil {
+		return err
+	}
+	var txs [][]byte
+	for i, tx := range action.Get("txs").Array() {
+		data, err := d.config.EncodeTx(tx)
+		if err != nil {
+			return fmt.Errorf("txs[%d]: %w", i, err)
+		}
+		txs = append(txs, data)
+	}
+
+	header := tmproto.Header{ChainID: d.chainID, Height: height, Time: blockTime}
+	begin, err := d.client.BeginBlockSync(abci.RequestBeginBlock{Header: header})
+	if err != nil {
+		return err
+	}
+	events := append([]abci.Event{}, begin.Events...)
+	var codes []uint32
+	for _, tx := range txs {
+		result, err := d.client.DeliverTxSync(abci.RequestDeliverTx{Tx: tx})
+		if err != nil {
+			return err
+		}
+		codes = append(codes, result.Code)
+		events = append(events, result.Events...)
+	}
+	end, err := d.client.EndBlockSync(abci.RequestEndBlock{Height: height})
+	if err != nil {
+		return err
+	}
+	events = append(events, end.Events...)
+	d.pending = height
+
+	if expected := action.Get("results"); expected.Exists() {
+		results := expected.Array()
+		if len(results) != len(codes) {
+			return adapter.Diverged(fmt.Errorf("expected %d results, found %d", len(results), len(codes)))
+		}
+		for i, r := range results {
+			code, err := integer(r, "code")
+			if err != nil {
+				return fmt.Errorf("results[%d]: %w", i, err)
+			}
+			if uint32(code) != codes[i] {
+				return adapter.Diverged(fmt.Errorf("results[%d]: expected the code %d, found %d", i, code, codes[i]))
+			}
+		}
+	}
+	if expected := action.Get("events"); expected.Exists() {
+		var want, found []string
+		for _, e := range expected.Array() {
+			want = append(want, e.String())
+		}
+		for _, e := range events {
+			found = append(found, e.Type)
+		}
+		if !equal(want, found) {
+			return adapter.Diverged(fmt.Errorf("expected the events %v, found %v", want, found))
+		}
+	}
+	return nil
+}
+
+func (d *Driver) commit(action gjson.Result) error {
+	if d.pending == 0 {
+		return fmt.Errorf("no block to commit")
+	}
+	result, err := d.client.CommitSync()
+	if err != nil {
+		return err
+	}
+	d.pending = 0
+	if expected := action.Get("appHash"); expected.Exists() {
+		if actual := hex.EncodeToString(result.Data); actual != expected.String() {
+			return adapter.Diverged(fmt.Errorf("expected the app hash %s, found %s", expected.String(), actual))
+		}
+	}
+	return nil
+}
+
+// whether two lists of strings are equal
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package abcisut
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// a trace of two blocks, whose second transaction fails
+const blocksTrace = `{
+  "vars": [ "lastAction" ],
+  "states": [
+    { "lastAction": { "kind": "init" } },
+    { "lastAction": { "kind": "initChain", "chainId": "test-1", "time": { "#bigint": "1700000000" } } },
+    { "lastAction": { "kind": "finalizeBlock", "height": { "#bigint": "1" }, "time": { "#bigint": "1700000005" },
+        "txs": [ "a", "fail" ],
+        "results": [ { "code": { "#bigint": "0" } }, { "code": { "#bigint": "1" } } ],
+        "events": [ "begin_block", "tx", "end_block" ] } },
+    { "lastAction": { "kind": "commit", "appHash": "0000000000000001" } },
+    { "lastAction": { "kind": "finalizeBlock", "height": { "#bigint": "2" }, "time": { "#bigint": "1700000010" },
+        "txs": [ "b", "c" ],
+        "results": [ { "code": { "#bigint": "0" } }, { "code": { "#bigint": "0" } } ],
+        "events": [ "begin_block", "tx", "tx", "end_block" ] } },
+    { "lastAction": { "kind": "commit", "appHash": "0000000000000003" } }
+  ]
+}`
+
+// an application that counts the transactions that succeed, and emits an
+// event in every hook, in which it is called
+type counter struct {
+	abci.BaseApplication
+	count uint64
+	// whether the end blocker runs before the transactions
+	endFirst bool
+}
+
+func (c *counter) BeginBlock(abci.RequestBeginBlock) abci.ResponseBeginBlock {
+	events := []abci.Event{{Type: "begin_block"}}
+	if c.endFirst {
+		events = append(events, abci.Event{Type: "end_block"})
+	}
+	return abci.ResponseBeginBlock{Events: events}
+}
+
+func (c *counter) DeliverTx(req abci.RequestDeliverTx) abci.ResponseDeliverTx {
+	if string(req.Tx) == "fail" {
+		return abci.ResponseDeliverTx{Code: 1, Log: "the transaction fails"}
+	}
+	c.count++
+	return abci.ResponseDeliverTx{Events: []abci.Event{{Type: "tx"}}}
+}
+
+func (c *counter) EndBlock(abci.RequestEndBlock) abci.ResponseEndBlock {
+	if c.endFirst {
+		return abci.ResponseEndBlock{}
+	}
+	return abci.ResponseEndBlock{Events: []abci.Event{{Type: "end_block"}}}
+}
+
+func (c *counter) Commit() abci.ResponseCommit {
+	hash := make([]byte, 8)
+	binary.BigEndian.PutUint64(hash, c.count)
+	return abci.ResponseCommit{Data: hash}
+}
+
+// a driver of a fresh counter on every reset
+func connect(t *testing.T, endFirst bool) *Driver {
+	d := NewDriver(Config{Connect: Local(func() abci.Application {
+		return &counter{endFirst: endFirst}
+	})})
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
+
+func TestReplayOverAbci(t *testing.T) {
+	trace, err := itf.Parse([]byte(blocksTrace))
+	require.NoError(t, err)
+	d := connect(t, false)
+	assert.NoError(t, adapter.Run(d, trace, nil))
+	// the next trace starts with a fresh application
+	assert.NoError(t, adapter.Run(d, trace, nil))
+}
+
+func TestOrderOfHooks(t *testing.T) {
+	trace, err := itf.Parse([]byte(blocksTrace))
+	require.NoError(t, err)
+	err = adapter.Run(connect(t, true), trace, nil)
+	var divergence *adapter.Divergence
+	require.True(t, errors.As(err, &divergence), "expected a divergence, found %v", err)
+	assert.Equal(t, 2, divergence.State)
+	assert.ErrorContains(t, err, "finalizeBlock: expected the events [begin_block tx end_block], found [begin_block end_block tx]")
+}
+
+func TestCommitBeforeFinalize(t *testing.T) {
+	d := connect(t, false)
+	trace, err := itf.Parse([]byte(blocksTrace))
+	require.NoError(t, err)
+	require.NoError(t, d.Reset(trace.States[0]))
+	require.NoError(t, d.Step(trace.States[1]))
+	assert.ErrorContains(t, d.Step(trace.States[3]), "commit: no block to commit")
+	require.NoError(t, d.Step(trace.States[2]))
+	assert.ErrorContains(t, d.Step(trace.States[4]), "finalizeBlock: the block 1 is not committed")
+}
@@ -0,0 +1,514 @@
+// Package msgmap populates the protobuf messages of the SDK, e.g., MsgSend
+// and MsgDelegate, from the records of a trace, so that a harness constructs
+// the messages of a spec without decoding them field by field:
+//
+//	msg, err := msgmap.New().Msg("/cosmos.bank.v1beta1.MsgSend", state.Get("lastAction.msg"))
+//
+// The fields of a record are matched with the fields of a message by their
+// names in protobuf, e.g., from_address, or by their JSON names, e.g.,
+// fromAddress, which we find with the reflection of gogoproto, as the SDK
+// generates its messages with it. A field of the message that the record
+// does not have keeps its value, and the other fields of the record are
+// ignored, e.g., the kind of an action. The values of the record are
+// converted by the types of the fields:
+//
+//	str                 strings, bytes, and enums by the names of their values,
+//	                    e.g., VOTE_OPTION_YES, or yes for short
+//	int                 integers, enums, sdk.Int, sdk.Dec, time.Time in seconds
+//	                    since the epoch, time.Duration in seconds, and
+//	                    sdk.Coin and sdk.Coins in the denomination of Mapper
+//	{ value: int }      sdk.Dec with 18 decimals, as in decimal.qnt
+//	records             messages, and *types.Any, whose type is in the field
+//	                    typeUrl of the record, e.g., /cosmos.bank.v1beta1.MsgSend
+//	lists and sets      repeated fields
+//	maps                maps, and repeated messages, e.g., sdk.Coins, whose
+//	                    first two fields are the keys and the values
+//
+// The accounts of a spec are called by their names. The string fields that
+// hold addresses, e.g., from_address and validator_address, turn the names
+// into addresses, see Mapper.Addresses.
+package msgmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/tidwall/gjson"
+
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// Mapper populates messages from records.
+type Mapper struct {
+	// Addresses turn the names of the accounts of a spec into addresses, by
+	// the names in protobuf of the string fields that hold them. A value that
+	// is an address already stays as it is.
+	Addresses map[string]func(name string) string
+	// the denomination of the coins that a record gives as integers
+	Denom string
+}
+
+// the address of an account, see simchain.Addr
+func accAddress(name string) string {
+	return simchain.Addr(name).String()
+}
+
+// the address of a validator, see simchain.ValAddr
+func valAddress(name string) string {
+	return simchain.ValAddr(name).String()
+}
+
+// New returns a mapper for the addresses of simchain, whose coins are in the
+// bond denomination.
+func New() *Mapper {
+	return &Mapper{
+		Addresses: map[string]func(string) string{
+			"from_address":          accAddress,
+			"to_address":            accAddress,
+			"delegator_address":     accAddress,
+			"withdraw_address":      accAddress,
+			"sender":                accAddress,
+			"receiver":              accAddress,
+			"proposer":              accAddress,
+			"depositor":             accAddress,
+			"voter":                 accAddress,
+			"granter":               accAddress,
+			"grantee":               accAddress,
+			"authority":             accAddress,
+			"validator_address":     valAddress,
+			"validator_src_address": valAddress,
+			"validator_dst_address": valAddress,
+		},
+		Denom: sdk.DefaultBondDenom,
+	}
+}
+
+// Msg returns the message of a type URL, e.g., /cosmos.bank.v1beta1.MsgSend,
+// as populated from a record.
+func (m *Mapper) Msg(typeURL string, v gjson.Result) (sdk.Msg, error) {
+	msg, err := newMessage(typeURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Unmarshal(v, msg); err != nil {
+		return nil, err
+	}
+	sdkMsg, ok := msg.(sdk.Msg)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an sdk.Msg", typeURL)
+	}
+	return sdkMsg, nil
+}
+
+// Unmarshal populates a message from a record.
+func (m *Mapper) Unmarshal(v gjson.Result, msg proto.Message) error {
+	ptr := reflect.ValueOf(msg)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a non-nil pointer to a message, found: %T", msg)
+	}
+	return m.message(v, ptr.Elem())
+}
+
+// a new message of a type URL, as registered with gogoproto
+func newMessage(typeURL string) (proto.Message, error) {
+	t := proto.MessageType(strings.TrimPrefix(typeURL, "/"))
+	if t == nil || t.Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("unknown message type: %s", typeURL)
+	}
+	msg, ok := reflect.New(t.Elem()).Interface().(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", typeURL)
+	}
+	return msg, nil
+}
+
+var (
+	anyType      = reflect.TypeOf(&codectypes.Any{})
+	coinType     = reflect.TypeOf(sdk.Coin{})
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// a field of a message: a field of its struct, or a member of a oneof, which
+// is set through a wrapper in the interface field of the oneof
+type field struct {
+	index int
+	prop  *proto.Properties
+	oneof *proto.OneofProperties
+}
+
+// the fields of a message, by their names in protobuf and in JSON
+func fieldsOf(t reflect.Type) map[string]field {
+	props := proto.GetProperties(t)
+	fields := make(map[string]field)
+	add := func(f field, p *proto.Properties) {
+		fields[p.OrigName] = f
+		if p.JSONName != "" {
+			fields[p.JSONName] = f
+		} else {
+			fields[lowerCamel(p.OrigName)] = f
+		}
+	}
+	for i, p := range props.Prop {
+		if p.Wire == "" || strings.HasPrefix(p.Name, "XXX_") {
+			// the interface field of a oneof, whose members follow
+			continue
+		}
+		add(field{index: i, prop: p}, p)
+	}
+	for _, oneof := range props.OneofTypes {
+		add(field{index: oneof.Field, prop: oneof.Prop, oneof: oneof}, oneof.Prop)
+	}
+	return fields
+}
+
+// the JSON name of a field in protobuf, e.g., fromAddress of from_address
+func lowerCamel(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// populate the struct of a message from a record
+func (m *Mapper) message(v gjson.Result, target reflect.Value) error {
+	if !v.IsObject() {
+		return fmt.Errorf("expected a record, found: %s", v.Raw)
+	}
+	fields := fieldsOf(target.Type())
+	var err error
+	v.ForEach(func(key, value gjson.Result) bool {
+		f, found := fields[key.Str]
+		if !found {
+			return true
+		}
+		if f.oneof != nil {
+			wrapper := reflect.New(f.oneof.Type.Elem())
+			if err = m.value(value, wrapper.Elem().Field(0), f.prop); err == nil {
+				target.Field(f.index).Set(wrapper)
+			}
+		} else {
+			err = m.value(value, target.Field(f.index), f.prop)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %v", key.Str, err)
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// populate a message from an entry of a map: its first field from the key,
+// and its second field from the value, e.g., the denomination and the amount
+// of a coin
+func (m *Mapper) entry(e itf.Entry, target reflect.Value) error {
+	props := proto.GetProperties(target.Type())
+	var fields []int
+	for i, p := range props.Prop {
+		if p.Wire != "" && !strings.HasPrefix(p.Name, "XXX_") {
+			fields = append(fields, i)
+		}
+	}
+	if len(fields) < 2 {
+		return fmt.Errorf("cannot populate %s from a map entry", target.Type())
+	}
+	if err := m.value(e.Key, target.Field(fields[0]), props.Prop[fields[0]]); err != nil {
+		return fmt.Errorf("key %s: %v", e.Key.Raw, err)
+	}
+	if err := m.value(e.Value, target.Field(fields[1]), props.Prop[fields[1]]); err != nil {
+		return fmt.Errorf("%s: %v", itf.Show(e.Key), err)
+	}
+	return nil
+}
+
+// populate a field of a message, of which prop is the field in protobuf,
+// and the elements of a repeated field share prop
+func (m *Mapper) value(v gjson.Result, target reflect.Value, prop *proto.Properties) error {
+	t := target.Type()
+	switch {
+	case t == anyType:
+		return m.any(v, target)
+	case t == timeType:
+		secs, err := itf.Int64(v)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(time.Unix(secs, 0).UTC()))
+		return nil
+	case t == durationType:
+		secs, err := itf.Int64(v)
+		if err != nil {
+			return err
+		}
+		target.SetInt(int64(time.Duration(secs) * time.Second))
+		return nil
+	case (t == coinType || t.Kind() == reflect.Slice && t.Elem() == coinType) && isInt(v):
+		i, err := itf.BigInt(v)
+		if err != nil {
+			return err
+		}
+		coin := sdk.NewCoin(m.Denom, sdk.NewIntFromBigInt(i))
+		if t == coinType {
+			target.Set(reflect.ValueOf(coin))
+		} else {
+			target.Set(reflect.ValueOf(sdk.Coins{coin}).Convert(t))
+		}
+		return nil
+	case prop != nil && prop.CustomType != "" && t.Kind() != reflect.Slice && t.Kind() != reflect.Pointer:
+		return customType(v, target)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if target.IsNil() {
+			target.Set(reflect.New(t.Elem()))
+		}
+		return m.value(v, target.Elem(), prop)
+	case reflect.Bool:
+		if !v.IsBool() {
+			return fmt.Errorf("expected a bool, found: %s", v.Raw)
+		}
+		target.SetBool(v.Bool())
+	case reflect.String:
+		if v.Type != gjson.String {
+			return fmt.Errorf("expected a string, found: %s", v.Raw)
+		}
+		s := v.Str
+		if address, found := m.Addresses[prop.OrigName]; found && !isAddress(s) {
+			s = address(s)
+		}
+		target.SetString(s)
+	case reflect.Int32:
+		if prop != nil && prop.Enum != "" && v.Type == gjson.String {
+			value, err := enumValue(prop.Enum, v.Str)
+			if err != nil {
+				return err
+			}
+			target.SetInt(int64(value))
+			return nil
+		}
+		fallthrough
+	case reflect.Int, reflect.Int64:
+		i, err := itf.BigInt(v)
+		if err != nil {
+			return err
+		}
+		if !i.IsInt64() || target.OverflowInt(i.Int64()) {
+			return fmt.Errorf("the integer %s does not fit into %s", i, t)
+		}
+		target.SetInt(i.Int64())
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		i, err := itf.BigInt(v)
+		if err != nil {
+			return err
+		}
+		if !i.IsUint64() || target.OverflowUint(i.Uint64()) {
+			return fmt.Errorf("the integer %s does not fit into %s", i, t)
+		}
+		target.SetUint(i.Uint64())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if v.Type != gjson.String {
+				return fmt.Errorf("expected a string of bytes, found: %s", v.Raw)
+			}
+			target.SetBytes([]byte(v.Str))
+			return nil
+		}
+		return m.slice(v, target, prop)
+	case reflect.Map:
+		return m.mapOf(v, target, prop)
+	case reflect.Struct:
+		return m.message(v, target)
+	default:
+		return fmt.Errorf("cannot populate %s", t)
+	}
+	return nil
+}
+
+// populate a repeated field from a list or a set, or a repeated message from
+// a map. The elements of sets and maps are sorted, if the type of the field
+// sorts itself, as sdk.Coins does.
+func (m *Mapper) slice(v gjson.Result, target reflect.Value, prop *proto.Properties) error {
+	t := target.Type()
+	elem := t.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	var slice reflect.Value
+	switch {
+	case v.IsArray():
+		elems := v.Array()
+		slice = reflect.MakeSlice(t, len(elems), len(elems))
+		for i, e := range elems {
+			if err := m.value(e, slice.Index(i), prop); err != nil {
+				return fmt.Errorf("%d: %v", i, err)
+			}
+		}
+		target.Set(slice)
+		return nil
+	case v.Get(`\#set`).Exists():
+		elems, err := itf.Set(v)
+		if err != nil {
+			return err
+		}
+		slice = reflect.MakeSlice(t, len(elems), len(elems))
+		for i, e := range elems {
+			if err := m.value(e, slice.Index(i), prop); err != nil {
+				return fmt.Errorf("%d: %v", i, err)
+			}
+		}
+	case v.Get(`\#map`).Exists() && elem.Kind() == reflect.Struct:
+		entries, err := itf.Map(v)
+		if err != nil {
+			return err
+		}
+		slice = reflect.MakeSlice(t, len(entries), len(entries))
+		for i, e := range entries {
+			target := slice.Index(i)
+			if target.Kind() == reflect.Pointer {
+				target.Set(reflect.New(elem))
+				target = target.Elem()
+			}
+			if err := m.entry(e, target); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("expected a list, a set, or a map, found: %s", v.Raw)
+	}
+	if sort := slice.MethodByName("Sort"); sort.IsValid() &&
+		sort.Type().NumIn() == 0 && sort.Type().NumOut() == 1 && sort.Type().Out(0) == t {
+		slice = sort.Call(nil)[0]
+	}
+	target.Set(slice)
+	return nil
+}
+
+// populate a map field of protobuf from a map
+func (m *Mapper) mapOf(v gjson.Result, target reflect.Value, prop *proto.Properties) error {
+	t := target.Type()
+	entries, err := itf.Map(v)
+	if err != nil {
+		return err
+	}
+	result := reflect.MakeMapWithSize(t, len(entries))
+	for _, e := range entries {
+		key := reflect.New(t.Key()).Elem()
+		if err := m.value(e.Key, key, prop.MapKeyProp); err != nil {
+			return fmt.Errorf("key %s: %v", e.Key.Raw, err)
+		}
+		value := reflect.New(t.Elem()).Elem()
+		if err := m.value(e.Value, value, prop.MapValProp); err != nil {
+			return fmt.Errorf("%s: %v", itf.Show(e.Key), err)
+		}
+		result.SetMapIndex(key, value)
+	}
+	target.Set(result)
+	return nil
+}
+
+// populate an Any with the message of the type URL in the field typeUrl of a record
+func (m *Mapper) any(v gjson.Result, target reflect.Value) error {
+	typeURL := v.Get("typeUrl")
+	if typeURL.Type != gjson.String {
+		return fmt.Errorf("expected a record with a typeUrl, found: %s", v.Raw)
+	}
+	msg, err := newMessage(typeURL.Str)
+	if err != nil {
+		return err
+	}
+	if err := m.Unmarshal(v, msg); err != nil {
+		return fmt.Errorf("%s: %v", typeURL.Str, err)
+	}
+	a, err := codectypes.NewAnyWithValue(msg)
+	if err != nil {
+		return err
+	}
+	target.Set(reflect.ValueOf(a))
+	return nil
+}
+
+// Populate a custom type of gogoproto, e.g., sdk.Int or sdk.Dec, by the
+// methods that it has for protobuf and JSON: a decimal of decimal.qnt is the
+// integer of its digits, as in protobuf, and an integer or a string is a
+// number, as in JSON.
+func customType(v gjson.Result, target reflect.Value) error {
+	ptr := target.Addr().Interface()
+	if v.IsObject() && v.Get("value").Exists() {
+		i, err := itf.Dec(v)
+		if err != nil {
+			return err
+		}
+		u, ok := ptr.(interface{ Unmarshal([]byte) error })
+		if !ok {
+			return fmt.Errorf("cannot populate %s from a decimal", target.Type())
+		}
+		return u.Unmarshal([]byte(i.String()))
+	}
+	var number string
+	if v.Type == gjson.String {
+		number = v.Str
+	} else {
+		i, err := itf.BigInt(v)
+		if err != nil {
+			return err
+		}
+		number = i.String()
+	}
+	u, ok := ptr.(json.Unmarshaler)
+	if !ok {
+		return fmt.Errorf("cannot populate %s from a number", target.Type())
+	}
+	bz, err := json.Marshal(number)
+	if err != nil {
+		return err
+	}
+	return u.UnmarshalJSON(bz)
+}
+
+// The value of an enum of protobuf by its name, or by the end of its name,
+// e.g., yes for VOTE_OPTION_YES, if the end matches a single value.
+func enumValue(enum string, name string) (int32, error) {
+	values := proto.EnumValueMap(enum)
+	if values == nil {
+		return 0, fmt.Errorf("unknown enum: %s", enum)
+	}
+	if value, found := values[name]; found {
+		return value, nil
+	}
+	suffix := "_" + strings.ToUpper(name)
+	var matches []string
+	for n := range values {
+		if strings.HasSuffix(n, suffix) {
+			matches = append(matches, n)
+		}
+	}
+	if len(matches) != 1 {
+		return 0, fmt.Errorf("expected a value of %s, found: %s", enum, name)
+	}
+	return values[matches[0]], nil
+}
+
+// whether a value is an integer, i.e., a number or a big integer
+func isInt(v gjson.Result) bool {
+	return v.Type == gjson.Number || v.Get(`\#bigint`).Exists()
+}
+
+// whether a string is an address in bech32 already, of any prefix
+func isAddress(s string) bool {
+	_, _, err := bech32.DecodeAndConvert(s)
+	return err == nil
+}
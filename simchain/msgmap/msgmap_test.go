@@ -0,0 +1,133 @@
+package msgmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	govv1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
+	govv1beta1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1beta1"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+func TestMsgSend(t *testing.T) {
+	v := gjson.Parse(`{
+	  "kind": "send", "from_address": "alice", "toAddress": "bob",
+	  "amount": { "#map": [ [ "uosmo", { "#bigint": "5" } ], [ "stake", { "#bigint": "30" } ] ] }
+	}`)
+	var msg banktypes.MsgSend
+	require.NoError(t, New().Unmarshal(v, &msg))
+	assert.Equal(t, simchain.Addr("alice").String(), msg.FromAddress)
+	assert.Equal(t, simchain.Addr("bob").String(), msg.ToAddress)
+	assert.Equal(t, sdk.NewCoins(sdk.NewInt64Coin("stake", 30), sdk.NewInt64Coin("uosmo", 5)), msg.Amount)
+	assert.NoError(t, msg.ValidateBasic())
+}
+
+func TestMsgDelegate(t *testing.T) {
+	// an address stays as it is
+	v := gjson.Parse(`{ "delegator": "alice", "delegator_address": "` + simchain.Addr("alice").String() + `",
+	  "validator_address": "val1", "amount": { "#bigint": "10" } }`)
+	msg, err := New().Msg("/cosmos.staking.v1beta1.MsgDelegate", v)
+	require.NoError(t, err)
+	assert.Equal(t, &stakingtypes.MsgDelegate{
+		DelegatorAddress: simchain.Addr("alice").String(),
+		ValidatorAddress: simchain.ValAddr("val1").String(),
+		Amount:           sdk.NewInt64Coin(sdk.DefaultBondDenom, 10),
+	}, msg)
+}
+
+func TestEnums(t *testing.T) {
+	for option, expected := range map[string]govv1beta1.VoteOption{
+		"VOTE_OPTION_YES": govv1beta1.OptionYes,
+		"no_with_veto":    govv1beta1.OptionNoWithVeto,
+		"abstain":         govv1beta1.OptionAbstain,
+	} {
+		var msg govv1beta1.MsgVote
+		v := gjson.Parse(`{ "proposal_id": 7, "voter": "bob", "option": "` + option + `" }`)
+		require.NoError(t, New().Unmarshal(v, &msg), option)
+		assert.Equal(t, expected, msg.Option, option)
+		assert.Equal(t, uint64(7), msg.ProposalId)
+	}
+	var msg govv1beta1.MsgVote
+	require.NoError(t, New().Unmarshal(gjson.Parse(`{ "option": { "#bigint": "3" } }`), &msg))
+	assert.Equal(t, govv1beta1.OptionNo, msg.Option)
+	assert.Error(t, New().Unmarshal(gjson.Parse(`{ "option": "maybe" }`), &msg))
+}
+
+func TestDecimals(t *testing.T) {
+	// 0.25 as a decimal of decimal.qnt, and 50 as an integer
+	v := gjson.Parse(`{
+	  "validator_address": "val1",
+	  "description": { "moniker": "val1", "website": "example.com" },
+	  "commission_rate": { "error": "", "value": { "#bigint": "250000000000000000" } },
+	  "min_self_delegation": { "#bigint": "50" }
+	}`)
+	var msg stakingtypes.MsgEditValidator
+	require.NoError(t, New().Unmarshal(v, &msg))
+	assert.Equal(t, "val1", msg.Description.Moniker)
+	assert.Equal(t, "example.com", msg.Description.Website)
+	require.NotNil(t, msg.CommissionRate)
+	assert.True(t, sdk.NewDecWithPrec(25, 2).Equal(*msg.CommissionRate), msg.CommissionRate)
+	require.NotNil(t, msg.MinSelfDelegation)
+	assert.True(t, sdk.NewInt(50).Equal(*msg.MinSelfDelegation), msg.MinSelfDelegation)
+
+	require.NoError(t, New().Unmarshal(gjson.Parse(`{ "commission_rate": "0.5" }`), &msg))
+	assert.True(t, sdk.NewDecWithPrec(5, 1).Equal(*msg.CommissionRate), msg.CommissionRate)
+	assert.Error(t, New().Unmarshal(gjson.Parse(`{ "commission_rate": { "error": "overflow", "value": 0 } }`), &msg))
+}
+
+func TestMessagesInAny(t *testing.T) {
+	v := gjson.Parse(`{
+	  "proposer": "alice", "metadata": "ipfs://",
+	  "initial_deposit": { "#bigint": "100" },
+	  "messages": [
+	    { "typeUrl": "/cosmos.bank.v1beta1.MsgSend", "from_address": "gov", "to_address": "bob",
+	      "amount": { "#map": [ [ "stake", 3 ] ] } }
+	  ]
+	}`)
+	var msg govv1.MsgSubmitProposal
+	require.NoError(t, New().Unmarshal(v, &msg))
+	assert.Equal(t, simchain.Addr("alice").String(), msg.Proposer)
+	assert.Equal(t, []sdk.Coin{sdk.NewInt64Coin(sdk.DefaultBondDenom, 100)}, msg.InitialDeposit)
+	require.Len(t, msg.Messages, 1)
+	assert.Equal(t, "/cosmos.bank.v1beta1.MsgSend", msg.Messages[0].TypeUrl)
+	assert.Equal(t, &banktypes.MsgSend{
+		FromAddress: simchain.Addr("gov").String(),
+		ToAddress:   simchain.Addr("bob").String(),
+		Amount:      sdk.NewCoins(sdk.NewInt64Coin("stake", 3)),
+	}, msg.Messages[0].GetCachedValue())
+
+	_, err := New().Msg("/cosmos.bank.v1beta1.MsgSent", v)
+	assert.Error(t, err)
+}
+
+func TestErrors(t *testing.T) {
+	var msg banktypes.MsgSend
+	for _, record := range []string{
+		`[ "alice" ]`,
+		`{ "from_address": 1 }`,
+		`{ "amount": "stake" }`,
+		`{ "amount": { "#map": [ [ 1, 2 ] ] } }`,
+	} {
+		assert.Error(t, New().Unmarshal(gjson.Parse(record), &msg), record)
+	}
+	var deposit govv1beta1.MsgDeposit
+	err := New().Unmarshal(gjson.Parse(`{ "proposal_id": -1 }`), &deposit)
+	assert.EqualError(t, err, "proposal_id: the integer -1 does not fit into uint64")
+}
+
+func TestTimes(t *testing.T) {
+	var proposal govv1.Proposal
+	v := gjson.Parse(`{ "id": 1, "status": "passed", "voting_end_time": { "#bigint": "1700000000" } }`)
+	require.NoError(t, New().Unmarshal(v, &proposal))
+	assert.Equal(t, govv1.StatusPassed, proposal.Status)
+	require.NotNil(t, proposal.VotingEndTime)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), *proposal.VotingEndTime)
+}
@@ -0,0 +1,264 @@
+// Package simchain is the common setup of the harnesses that replay traces
+// against the keepers of SimApp, as shipped with cosmos-sdk v0.46.4, e.g., of
+// the modules bank, gov, and staking.
+//
+// Setting up SimApp, that is, its genesis, InitChain, and the first block, is
+// the heavy part of a harness. A Chain sets up SimApp once, funds the accounts
+// that all traces share, and replays every trace in a branch of the store of
+// the setup, which the next Reset discards:
+//
+//	chain := simchain.New(t, simchain.Config{Modules: []string{simchain.Staking, simchain.Gov}})
+//	for _, filename := range traces {
+//		chain.Reset()
+//		proposal, err := chain.Keepers.Gov.SubmitProposal(chain.Ctx, []sdk.Msg{}, "")
+//		...
+//	}
+//
+// The accounts and the validators of a spec are called by their names, see
+// Addr and ValAddr.
+package simchain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/crypto"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/simapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authkeeper "github.com/cosmos/cosmos-sdk/x/auth/keeper"
+	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+	"github.com/cosmos/cosmos-sdk/x/distribution"
+	distrkeeper "github.com/cosmos/cosmos-sdk/x/distribution/keeper"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	"github.com/cosmos/cosmos-sdk/x/gov"
+	govkeeper "github.com/cosmos/cosmos-sdk/x/gov/keeper"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	"github.com/cosmos/cosmos-sdk/x/mint"
+	mintkeeper "github.com/cosmos/cosmos-sdk/x/mint/keeper"
+	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
+	"github.com/cosmos/cosmos-sdk/x/slashing"
+	slashingkeeper "github.com/cosmos/cosmos-sdk/x/slashing/keeper"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	"github.com/cosmos/cosmos-sdk/x/staking"
+	stakingkeeper "github.com/cosmos/cosmos-sdk/x/staking/keeper"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// The modules of SimApp that a harness may select, besides auth and bank,
+// which every harness uses.
+const (
+	Staking      = stakingtypes.ModuleName
+	Distribution = distrtypes.ModuleName
+	Slashing     = slashingtypes.ModuleName
+	Mint         = minttypes.ModuleName
+	Gov          = govtypes.ModuleName
+)
+
+// the begin blockers of the modules, in the order of SimApp
+var beginBlockers = []struct {
+	module string
+	run    func(c *Chain, req abci.RequestBeginBlock)
+}{
+	{Mint, func(c *Chain, _ abci.RequestBeginBlock) {
+		mint.BeginBlocker(c.Ctx, c.Keepers.Mint, minttypes.DefaultInflationCalculationFn)
+	}},
+	{Distribution, func(c *Chain, req abci.RequestBeginBlock) {
+		distribution.BeginBlocker(c.Ctx, req, c.Keepers.Distribution)
+	}},
+	{Slashing, func(c *Chain, req abci.RequestBeginBlock) {
+		slashing.BeginBlocker(c.Ctx, req, c.Keepers.Slashing)
+	}},
+	{Staking, func(c *Chain, _ abci.RequestBeginBlock) {
+		staking.BeginBlocker(c.Ctx, c.Keepers.Staking)
+	}},
+}
+
+// the end blockers of the modules, in the order of SimApp
+var endBlockers = []struct {
+	module string
+	run    func(c *Chain)
+}{
+	{Gov, func(c *Chain) {
+		gov.EndBlocker(c.Ctx, c.Keepers.Gov)
+	}},
+	{Staking, func(c *Chain) {
+		staking.EndBlocker(c.Ctx, c.Keepers.Staking)
+	}},
+}
+
+// Config selects the modules of a chain, and the state that all traces share.
+type Config struct {
+	// the modules whose keepers are set in Keepers, and whose blockers run in
+	// BeginBlock and EndBlock, e.g., Staking and Gov
+	Modules []string
+	// the coins of the accounts, by their names, which are funded on setup
+	Balances map[string]sdk.Coins
+	// the header of the block of the setup, by default the empty header
+	Header tmproto.Header
+	// called at the end of the setup, to set up further state that all traces
+	// share, e.g., the parameters of a module, if not nil
+	Setup func(c *Chain)
+}
+
+// Keepers are the keepers of SimApp that a harness uses. The keepers of
+// auth and bank are always set, and the others only if their modules are
+// selected, so a harness that uses a module without selecting it panics.
+type Keepers struct {
+	Account      authkeeper.AccountKeeper
+	Bank         bankkeeper.Keeper
+	Staking      stakingkeeper.Keeper
+	Distribution distrkeeper.Keeper
+	Slashing     slashingkeeper.Keeper
+	Mint         mintkeeper.Keeper
+	Gov          govkeeper.Keeper
+}
+
+// Chain is SimApp, together with the context of the current trace.
+type Chain struct {
+	App     *simapp.SimApp
+	Keepers Keepers
+	// the context of the current trace, whose store is a branch of the store
+	// of the setup
+	Ctx sdk.Context
+	// the selected modules
+	modules map[string]bool
+	// the context of the setup, which all traces branch from
+	setup sdk.Context
+}
+
+// New sets up SimApp with the modules and the accounts of a configuration,
+// and resets the chain for the first trace.
+func New(t *testing.T, config Config) *Chain {
+	app := simapp.Setup(t, false)
+	c := &Chain{
+		App:     app,
+		Keepers: Keepers{Account: app.AccountKeeper, Bank: app.BankKeeper},
+		modules: make(map[string]bool),
+		setup:   app.BaseApp.NewContext(false, config.Header),
+	}
+	for _, module := range config.Modules {
+		switch module {
+		case Staking:
+			c.Keepers.Staking = app.StakingKeeper
+		case Distribution:
+			c.Keepers.Distribution = app.DistrKeeper
+		case Slashing:
+			c.Keepers.Slashing = app.SlashingKeeper
+		case Mint:
+			c.Keepers.Mint = app.MintKeeper
+		case Gov:
+			c.Keepers.Gov = app.GovKeeper
+		default:
+			require.Fail(t, fmt.Sprintf("unknown module %q", module))
+		}
+		c.modules[module] = true
+	}
+	for name, coins := range config.Balances {
+		require.NoError(t, banktestutil.FundAccount(app.BankKeeper, c.setup, Addr(name), coins), "funds of %s", name)
+	}
+	if config.Setup != nil {
+		c.Ctx = c.setup
+		config.Setup(c)
+	}
+	c.Reset()
+	return c
+}
+
+// Reset discards the writes of the current trace, and starts the next trace
+// in a fresh branch of the store of the setup, at the block of the setup.
+func (c *Chain) Reset() {
+	c.Ctx, _ = c.setup.CacheContext()
+}
+
+// BeginBlock moves the context to a block at a height and a time, and runs
+// the begin blockers of the selected modules, in the order of SimApp. The
+// blockers see no votes of the last commit, so distribution moves the fees to
+// the community pool, and slashing misses no blocks.
+func (c *Chain) BeginBlock(height int64, blockTime time.Time) {
+	c.Ctx = c.Ctx.WithBlockHeight(height).WithBlockTime(blockTime)
+	req := abci.RequestBeginBlock{Header: c.Ctx.BlockHeader()}
+	for _, b := range beginBlockers {
+		if c.modules[b.module] {
+			b.run(c, req)
+		}
+	}
+}
+
+// EndBlock runs the end blockers of the selected modules, in the order of
+// SimApp, e.g., to bond the validators that were created in the block.
+func (c *Chain) EndBlock() {
+	for _, b := range endBlockers {
+		if c.modules[b.module] {
+			b.run(c)
+		}
+	}
+}
+
+// NextBlock ends the current block, and begins a block at a height and a time.
+func (c *Chain) NextBlock(height int64, blockTime time.Time) {
+	c.EndBlock()
+	c.BeginBlock(height, blockTime)
+}
+
+// Fund mints coins to the account of a name in the current trace, which
+// creates the account, if it does not exist, e.g., to fund the accounts of the
+// initial state of a trace.
+func (c *Chain) Fund(name string, coins sdk.Coins) error {
+	return banktestutil.FundAccount(c.Keepers.Bank, c.Ctx, Addr(name), coins)
+}
+
+// Addr returns the address of an account that is called by its name in a spec.
+func Addr(name string) sdk.AccAddress {
+	return sdk.AccAddress(tmcrypto.AddressHash([]byte(name)))
+}
+
+// ValAddr returns the address of a validator, which is operated by the
+// account of the same name.
+func ValAddr(name string) sdk.ValAddress {
+	return sdk.ValAddress(Addr(name))
+}
+
+// Driver is the part of an adapter that is specific to a harness: it brings
+// the chain into the initial state of a trace, and executes the actions of
+// the trace with the keepers.
+type Driver interface {
+	// Init brings a chain, which has just been reset, into the initial
+	// state of a trace, and compares it with the state.
+	Init(c *Chain, init gjson.Result) error
+	// Step executes the action that led to a state, and compares the chain
+	// with the state, as adapter.Adapter does, which also tells how to
+	// report a divergence.
+	Step(c *Chain, state gjson.Result) error
+}
+
+// Adapter returns an adapter of the package itf/adapter, which resets the
+// chain before every trace, and passes the states to a driver, so a harness
+// is replayed with adapter.Run.
+func (c *Chain) Adapter(d Driver) adapter.Adapter {
+	return &chainAdapter{chain: c, driver: d}
+}
+
+// the adapter of a chain and a driver
+type chainAdapter struct {
+	chain  *Chain
+	driver Driver
+}
+
+func (a *chainAdapter) Reset(init gjson.Result) error {
+	a.chain.Reset()
+	return a.driver.Init(a.chain, init)
+}
+
+func (a *chainAdapter) Step(state gjson.Result) error {
+	return a.driver.Step(a.chain, state)
+}
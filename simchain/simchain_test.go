@@ -0,0 +1,115 @@
+package simchain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/staking/teststaking"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+)
+
+// a trace, in which alice sends coins to bob
+const sendTrace = `{
+  "vars": [ "alice", "bob", "amount" ],
+  "states": [
+    { "alice": { "#bigint": "100" }, "bob": { "#bigint": "0" }, "amount": { "#bigint": "0" } },
+    { "alice": { "#bigint": "70" }, "bob": { "#bigint": "30" }, "amount": { "#bigint": "30" } },
+    { "alice": { "#bigint": "20" }, "bob": { "#bigint": "80" }, "amount": { "#bigint": "50" } }
+  ]
+}`
+
+const denom = sdk.DefaultBondDenom
+
+// the driver of sendTrace
+type sender struct{}
+
+func (sender) Init(c *Chain, init gjson.Result) error {
+	return checkBalances(c, init)
+}
+
+func (sender) Step(c *Chain, state gjson.Result) error {
+	amount, err := itf.BigInt(state.Get("amount"))
+	if err != nil {
+		return err
+	}
+	coins := sdk.NewCoins(sdk.NewCoin(denom, sdk.NewIntFromBigInt(amount)))
+	if err := c.Keepers.Bank.SendCoins(c.Ctx, Addr("alice"), Addr("bob"), coins); err != nil {
+		return err
+	}
+	return checkBalances(c, state)
+}
+
+// compare the balances of alice and bob with a state
+func checkBalances(c *Chain, state gjson.Result) error {
+	for _, name := range []string{"alice", "bob"} {
+		expected, err := itf.BigInt(state.Get(name))
+		if err != nil {
+			return err
+		}
+		actual := c.Keepers.Bank.GetBalance(c.Ctx, Addr(name), denom).Amount
+		if actual.BigInt().Cmp(expected) != 0 {
+			return adapter.Diverged(fmt.Errorf("%s: expected %s, found %s", name, expected, actual))
+		}
+	}
+	return nil
+}
+
+func TestResetDiscardsTheTrace(t *testing.T) {
+	funds := sdk.NewCoins(sdk.NewInt64Coin(denom, 100))
+	chain := New(t, Config{Balances: map[string]sdk.Coins{"alice": funds}})
+	require.NoError(t, chain.Keepers.Bank.SendCoins(chain.Ctx, Addr("alice"), Addr("bob"), funds))
+	assert.True(t, chain.Keepers.Bank.GetAllBalances(chain.Ctx, Addr("alice")).IsZero())
+
+	chain.Reset()
+	assert.Equal(t, funds.String(), chain.Keepers.Bank.GetAllBalances(chain.Ctx, Addr("alice")).String())
+	assert.True(t, chain.Keepers.Bank.GetAllBalances(chain.Ctx, Addr("bob")).IsZero())
+}
+
+func TestFundIsPerTrace(t *testing.T) {
+	funds := sdk.NewCoins(sdk.NewInt64Coin(denom, 100))
+	chain := New(t, Config{})
+	require.NoError(t, chain.Fund("alice", funds))
+	assert.Equal(t, funds.String(), chain.Keepers.Bank.GetAllBalances(chain.Ctx, Addr("alice")).String())
+
+	chain.Reset()
+	assert.True(t, chain.Keepers.Bank.GetAllBalances(chain.Ctx, Addr("alice")).IsZero())
+}
+
+func TestAdapter(t *testing.T) {
+	trace, err := itf.Parse([]byte(sendTrace))
+	require.NoError(t, err)
+	funds := sdk.NewCoins(sdk.NewInt64Coin(denom, 100))
+	chain := New(t, Config{Balances: map[string]sdk.Coins{"alice": funds}})
+	a := chain.Adapter(sender{})
+	// every trace starts from the setup, so the same trace replays twice
+	require.NoError(t, adapter.Run(a, trace, nil))
+	require.NoError(t, adapter.Run(a, trace, nil))
+}
+
+func TestEndBlockBondsValidators(t *testing.T) {
+	funds := sdk.NewCoins(sdk.NewInt64Coin(denom, 1_000_000_000))
+	chain := New(t, Config{Modules: []string{Staking}, Balances: map[string]sdk.Coins{"val": funds}})
+	tstaking := teststaking.NewHelper(t, chain.Ctx, chain.Keepers.Staking)
+	tstaking.CreateValidator(ValAddr("val"), ed25519.GenPrivKey().PubKey(), sdk.NewInt(10_000_000), true)
+	validator, found := chain.Keepers.Staking.GetValidator(chain.Ctx, ValAddr("val"))
+	require.True(t, found)
+	assert.False(t, validator.IsBonded())
+
+	chain.NextBlock(chain.Ctx.BlockHeight()+1, chain.Ctx.BlockTime().Add(5*time.Second))
+	validator, found = chain.Keepers.Staking.GetValidator(chain.Ctx, ValAddr("val"))
+	require.True(t, found)
+	assert.True(t, validator.IsBonded())
+
+	chain.Reset()
+	_, found = chain.Keepers.Staking.GetValidator(chain.Ctx, ValAddr("val"))
+	assert.False(t, found, "the validator of the previous trace")
+}
@@ -0,0 +1,288 @@
+// A test harness that replays the traces of slashingTest.qnt against
+// x/slashing of SimApp, as shipped with cosmos-sdk v0.46.4.
+//
+// The validators of the spec are created and bonded at height 1, which creates
+// their signing infos. Every state of a trace records the action that led to
+// it in lastAction: a new block with the validators that signed it, or an
+// attempt of a validator to unjail itself. For a block, we hand the signature
+// of every validator that is not jailed to the slashing keeper, as the begin
+// blocker does. After every action, we run the end blocker of staking, which
+// unbonds the jailed validators and bonds the unjailed ones. Then we compare
+// the tokens, the signing infos, and the windows of missed blocks with the
+// state of the spec.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	"github.com/cosmos/cosmos-sdk/x/staking/teststaking"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+const denom = sdk.DefaultBondDenom
+
+// the parameters of slashing.qnt
+var params = slashingtypes.NewParams(
+	10,                        // SIGNED_BLOCKS_WINDOW
+	sdk.NewDecWithPrec(55, 2), // MIN_SIGNED_PER_WINDOW
+	60*time.Second,            // DOWNTIME_JAIL_DURATION
+	sdk.NewDecWithPrec(5, 2),  // not modeled
+	sdk.NewDecWithPrec(1, 2),  // SLASH_FRACTION_DOWNTIME
+)
+
+// the block time that the times of the spec are relative to
+var genesisTime = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// the errors of Keeper.Unjail, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"validator not jailed; cannot be unjailed":   slashingtypes.ErrValidatorNotJailed,
+	"validator still jailed; cannot be unjailed": slashingtypes.ErrValidatorJailed,
+}
+
+// the signing info of a validator, with the times in seconds since genesis
+type TestSigningInfo struct {
+	startHeight         int64
+	indexOffset         int64
+	missedBlocksCounter int64
+	jailedUntil         int64
+}
+
+// a validator of slashing.qnt
+type TestValidator struct {
+	tokens *big.Int
+	jailed bool
+	info   TestSigningInfo
+	missed map[int64]bool
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind      string
+	signers   map[string]bool
+	validator string
+	error     string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	height     int64
+	time       int64
+	validators map[string]TestValidator
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from slashingTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.height = harness.Int64(t, jsonState.Get("height"))
+		state.time = harness.Int64(t, jsonState.Get("time"))
+		state.validators = make(map[string]TestValidator)
+		entries, err := itf.Map(jsonState.Get("validators"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.validators[e.Key.String()] = parseValidator(t, e.Value)
+		}
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		signers, err := itf.Set(jsonState.Get("lastAction.signers"))
+		require.NoError(t, err)
+		state.lastAction.signers = make(map[string]bool)
+		for _, s := range signers {
+			state.lastAction.signers[s.String()] = true
+		}
+		state.lastAction.validator = jsonState.Get("lastAction.validator").String()
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a validator of slashing.qnt
+func parseValidator(t *testing.T, obj gjson.Result) TestValidator {
+	tokens, err := itf.BigInt(obj.Get("tokens"))
+	require.NoError(t, err)
+	validator := TestValidator{
+		tokens: tokens,
+		jailed: obj.Get("jailed").Bool(),
+		info: TestSigningInfo{
+			startHeight:         harness.Int64(t, obj.Get("info.startHeight")),
+			indexOffset:         harness.Int64(t, obj.Get("info.indexOffset")),
+			missedBlocksCounter: harness.Int64(t, obj.Get("info.missedBlocksCounter")),
+			jailedUntil:         harness.Int64(t, obj.Get("info.jailedUntil")),
+		},
+		missed: make(map[int64]bool),
+	}
+	missed, err := itf.Set(obj.Get("missed"))
+	require.NoError(t, err)
+	for _, index := range missed {
+		validator.missed[harness.Int64(t, index)] = true
+	}
+	return validator
+}
+
+// the deterministic consensus key of a validator of the spec
+func consPubKey(name string) cryptotypes.PubKey {
+	return ed25519.GenPrivKeyFromSecret([]byte(name)).PubKey()
+}
+
+// the consensus address of a validator of the spec
+func consAddr(name string) sdk.ConsAddress {
+	return sdk.ConsAddress(consPubKey(name).Address())
+}
+
+// the block time that is given in seconds since genesis
+func specTime(seconds int64) time.Time {
+	return genesisTime.Add(time.Duration(seconds) * time.Second)
+}
+
+// the time of JailedUntil in the spec, where 0 stands for a validator that was never jailed
+func jailedUntil(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Unix(0, 0).UTC()
+	}
+	return specTime(seconds)
+}
+
+// SimApp with the parameters of the spec, which is set up once for all traces, see simchain
+var sharedChain *simchain.Chain
+
+// the chain under test at the current block
+type testChain struct {
+	*simchain.Chain
+}
+
+// Reset the chain, whose parameters are set on setup, and create and bond
+// the validators at the initial height.
+func setupChain(t *testing.T, init TestState) *testChain {
+	if sharedChain == nil {
+		sharedChain = simchain.New(t, simchain.Config{
+			Modules: []string{simchain.Staking, simchain.Slashing},
+			Setup: func(c *simchain.Chain) {
+				c.Keepers.Slashing.SetParams(c.Ctx, params)
+			},
+		})
+	}
+	c := &testChain{Chain: sharedChain}
+	c.Reset()
+	c.Ctx = c.Ctx.WithBlockHeight(init.height).WithBlockTime(specTime(init.time))
+	tstaking := teststaking.NewHelper(t, c.Ctx, c.Keepers.Staking)
+	for name, v := range init.validators {
+		require.NoError(t, c.Fund(name, sdk.NewCoins(sdk.NewCoin(denom, sdk.NewIntFromBigInt(v.tokens)))))
+		tstaking.CreateValidator(simchain.ValAddr(name), consPubKey(name), sdk.NewIntFromBigInt(v.tokens), true)
+	}
+	c.EndBlock()
+	return c
+}
+
+// Hand the signatures of a block to the slashing keeper. Only the validators
+// that were not jailed before the block are expected to sign it.
+func (c *testChain) block(t *testing.T, before TestState, signers map[string]bool) {
+	names := make([]string, 0, len(before.validators))
+	for name := range before.validators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if before.validators[name].jailed {
+			continue
+		}
+		validator, found := c.Keepers.Staking.GetValidator(c.Ctx, simchain.ValAddr(name))
+		require.True(t, found, "validator %s", name)
+		power := validator.GetConsensusPower(c.Keepers.Staking.PowerReduction(c.Ctx))
+		c.Keepers.Slashing.HandleValidatorSignature(c.Ctx, consPubKey(name).Address(), power, signers[name])
+	}
+}
+
+// compare the validators with the state of the spec
+func (c *testChain) checkState(t *testing.T, s TestState) {
+	for name, expected := range s.validators {
+		validator, found := c.Keepers.Staking.GetValidator(c.Ctx, simchain.ValAddr(name))
+		require.True(t, found, "validator %s", name)
+		assert.Equal(t, expected.tokens.String(), validator.Tokens.String(), "tokens of %s", name)
+		assert.Equal(t, expected.jailed, validator.Jailed, "jailed %s", name)
+		assert.Equal(t, !expected.jailed, validator.IsBonded(), "bonded %s", name)
+
+		info, found := c.Keepers.Slashing.GetValidatorSigningInfo(c.Ctx, consAddr(name))
+		require.True(t, found, "signing info of %s", name)
+		assert.Equal(t, expected.info.startHeight, info.StartHeight, "start height of %s", name)
+		assert.Equal(t, expected.info.indexOffset, info.IndexOffset, "index offset of %s", name)
+		assert.Equal(t, expected.info.missedBlocksCounter, info.MissedBlocksCounter, "missed blocks of %s", name)
+		assert.True(t, jailedUntil(expected.info.jailedUntil).Equal(info.JailedUntil),
+			"jailed until of %s: expected %s, found %s", name, jailedUntil(expected.info.jailedUntil), info.JailedUntil)
+		for index := int64(0); index < params.SignedBlocksWindow; index++ {
+			assert.Equal(t, expected.missed[index], c.Keepers.Slashing.GetValidatorMissedBlockBitArray(c.Ctx, consAddr(name), index),
+				"missed block %d of %s", index, name)
+		}
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	chain := setupChain(t, states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%d_%s_%s", i, s.height, s.lastAction.kind, s.lastAction.validator)
+		ok := t.Run(description, func(t *testing.T) {
+			chain.Ctx = chain.Ctx.WithBlockHeight(s.height).WithBlockTime(specTime(s.time))
+			switch s.lastAction.kind {
+			case "block":
+				chain.block(t, states[i-1], s.lastAction.signers)
+
+			case "unjail":
+				err := chain.Keepers.Slashing.Unjail(chain.Ctx, simchain.ValAddr(s.lastAction.validator))
+				if s.lastAction.error == "" {
+					require.NoError(t, err, "unjail should succeed")
+				} else {
+					require.True(t, errors.Is(err, errorsOfSpec[s.lastAction.error]),
+						"expected the error %q, found %v", s.lastAction.error, err)
+				}
+			}
+			if i > 0 {
+				chain.EndBlock()
+			}
+			chain.checkState(t, s)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
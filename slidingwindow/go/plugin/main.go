@@ -0,0 +1,137 @@
+// An adapter of the package window for itfrun, built as a Go plugin:
+//
+//	go build -buildmode=plugin -o /tmp/sw.so ./plugin
+//	cd ../../itf/cmd/itfrun && go run . -plugin /tmp/sw.so \
+//	  -adapter slidingwindow ../../../slidingwindow/test-inputs/done.itf.json
+//
+// It replays the same actions as slidingwindow_test.go, but reports the first
+// divergence as an error instead of failing a test.
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/adapter"
+	"github.com/informalsystems/quint-sandbox/slidingwindow/window"
+)
+
+// the errors of the actions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"window full":   window.ErrWindowFull,
+	"all sent":      window.ErrAllSent,
+	"not in window": window.ErrNotInWindow,
+	"not in flight": window.ErrNotInFlight,
+}
+
+func init() {
+	adapter.Register("slidingwindow", func(string) (adapter.Adapter, error) {
+		return &protocol{}, nil
+	})
+}
+
+// the sender, the receiver, and the channel under test
+type protocol struct {
+	channel  *window.Channel
+	sender   *window.Sender
+	receiver *window.Receiver
+}
+
+func (p *protocol) Reset(init gjson.Result) error {
+	total, err := parseInt(init.Get("p.total"))
+	if err != nil {
+		return err
+	}
+	size, err := parseInt(init.Get("p.window"))
+	if err != nil {
+		return err
+	}
+	p.channel = window.NewChannel()
+	p.sender = window.NewSender(total, size, p.channel)
+	p.receiver = window.NewReceiver(size, p.channel)
+	return p.check(init)
+}
+
+func (p *protocol) Step(state gjson.Result) error {
+	if err := p.Apply(state); err != nil {
+		return err
+	}
+	return p.check(state)
+}
+
+// Apply executes the action of a state and checks its error, but it does not
+// compare the protocol with the state, so itfrun fast-forwards through a trace.
+func (p *protocol) Apply(state gjson.Result) error {
+	kind := state.Get("lastAction.kind").String()
+	msg, err := parseMessage(state.Get("lastAction.msg"))
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "send":
+		err = p.sender.Send()
+	case "retransmit":
+		err = p.sender.Retransmit(msg.Seq)
+	case "deliver":
+		err = p.channel.Deliver(msg)
+	case "drop":
+		err = p.channel.Drop(msg)
+	default:
+		return fmt.Errorf("unknown action: %s", kind)
+	}
+	expected := state.Get("lastAction.error").String()
+	if expected == "" && err != nil {
+		return adapter.Diverged(fmt.Errorf("%s: unexpected error: %v", kind, err))
+	}
+	if expected != "" && !errors.Is(err, errorsOfSpec[expected]) {
+		return adapter.Diverged(fmt.Errorf("%s: expected the error %q, found %v", kind, expected, err))
+	}
+	return nil
+}
+
+// compare the sender and the receiver with a state of the spec
+func (p *protocol) check(state gjson.Result) error {
+	fields := []struct {
+		path   string
+		actual int
+	}{
+		{"p.sender.base", p.sender.Base},
+		{"p.sender.next", p.sender.Next},
+		{"p.receiver.expected", p.receiver.Expected},
+	}
+	for _, f := range fields {
+		expected, err := parseInt(state.Get(f.path))
+		if err != nil {
+			return err
+		}
+		if expected != f.actual {
+			return adapter.Diverged(fmt.Errorf("%s: expected %d, found %d", f.path, expected, f.actual))
+		}
+	}
+	msgs, err := itf.Set(state.Get("p.msgs"))
+	if err != nil {
+		return err
+	}
+	if len(msgs) != len(p.channel.InFlight()) {
+		return adapter.Diverged(fmt.Errorf("p.msgs: expected %d messages in flight, found %v", len(msgs), p.channel.InFlight()))
+	}
+	return nil
+}
+
+// parse a message of slidingwindow.qnt
+func parseMessage(obj gjson.Result) (window.Message, error) {
+	seq, err := parseInt(obj.Get("seq"))
+	return window.Message{Kind: window.Kind(obj.Get("kind").String()), Seq: seq}, err
+}
+
+// parse an integer that fits into int
+func parseInt(obj gjson.Result) (int, error) {
+	i, err := itf.Int64(obj)
+	return int(i), err
+}
+
+// a plugin is a main package, which is never run
+func main() {}
@@ -0,0 +1,225 @@
+// A test harness that replays the traces of slidingwindowTest.qnt against
+// the implementation of the sliding-window protocol in the package window.
+//
+// The sender and the receiver talk over the in-memory channel of the
+// package, which keeps the messages in flight until the harness delivers or
+// drops them. Every state of a trace records the action that led to it in
+// lastAction: the sender sending or retransmitting a message, or the channel
+// delivering or dropping a message. Thus, the trace schedules the channel.
+// We apply the action, check its error, and compare the sender, the
+// receiver, and the messages in flight with the state of the spec.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/slidingwindow/window"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// The range of the states to check, e.g., to debug a divergence deep in a long
+// trace: go test -run TestDone -args -from=412 -to=413. The protocol cannot be
+// set up in an arbitrary state, so the states before -from are fast-forwarded:
+// their actions are applied in a single subtest, without comparing the states.
+var (
+	fromState = flag.Int("from", 0, "check the states from this state on")
+	toState   = flag.Int("to", -1, "check the states up to this state, or up to the last state, if negative")
+)
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the errors of the actions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"window full":   window.ErrWindowFull,
+	"all sent":      window.ErrAllSent,
+	"not in window": window.ErrNotInWindow,
+	"not in flight": window.ErrNotInFlight,
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind  string
+	msg   window.Message
+	error string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	total      int
+	window     int
+	base       int
+	next       int
+	expected   int
+	buffer     []int
+	delivered  []int
+	msgs       []window.Message
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from slidingwindowTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.total = int(harness.Int64(t, jsonState.Get("p.total")))
+		state.window = int(harness.Int64(t, jsonState.Get("p.window")))
+		state.base = int(harness.Int64(t, jsonState.Get("p.sender.base")))
+		state.next = int(harness.Int64(t, jsonState.Get("p.sender.next")))
+		state.expected = int(harness.Int64(t, jsonState.Get("p.receiver.expected")))
+		buffer, err := itf.Set(jsonState.Get("p.receiver.buffer"))
+		require.NoError(t, err)
+		state.buffer = make([]int, 0, len(buffer))
+		for _, seq := range buffer {
+			state.buffer = append(state.buffer, int(harness.Int64(t, seq)))
+		}
+		sort.Ints(state.buffer)
+		// a list is a plain JSON array in ITF
+		state.delivered = []int{}
+		for _, seq := range jsonState.Get("p.receiver.delivered").Array() {
+			state.delivered = append(state.delivered, int(harness.Int64(t, seq)))
+		}
+		msgs, err := itf.Set(jsonState.Get("p.msgs"))
+		require.NoError(t, err)
+		for _, m := range msgs {
+			state.msgs = append(state.msgs, parseMessage(t, m))
+		}
+		state.lastAction = TestAction{
+			kind:  jsonState.Get("lastAction.kind").String(),
+			msg:   parseMessage(t, jsonState.Get("lastAction.msg")),
+			error: jsonState.Get("lastAction.error").String(),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a message of slidingwindow.qnt
+func parseMessage(t *testing.T, obj gjson.Result) window.Message {
+	return window.Message{Kind: window.Kind(obj.Get("kind").String()), Seq: int(harness.Int64(t, obj.Get("seq")))}
+}
+
+// the sender, the receiver, and the channel under test
+type testProtocol struct {
+	channel  *window.Channel
+	sender   *window.Sender
+	receiver *window.Receiver
+}
+
+// create the sender and the receiver of the initial state
+func setupProtocol(init TestState) *testProtocol {
+	channel := window.NewChannel()
+	return &testProtocol{
+		channel:  channel,
+		sender:   window.NewSender(init.total, init.window, channel),
+		receiver: window.NewReceiver(init.window, channel),
+	}
+}
+
+// apply an action to the protocol
+func (p *testProtocol) execute(t *testing.T, a TestAction) error {
+	switch a.kind {
+	case "send":
+		return p.sender.Send()
+	case "retransmit":
+		return p.sender.Retransmit(a.msg.Seq)
+	case "deliver":
+		return p.channel.Deliver(a.msg)
+	case "drop":
+		return p.channel.Drop(a.msg)
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+		return nil
+	}
+}
+
+// compare the sender, the receiver, and the channel with the state of the spec
+func (p *testProtocol) checkState(t *testing.T, expected TestState) {
+	assert.Equal(t, expected.base, p.sender.Base, "base of the sender")
+	assert.Equal(t, expected.next, p.sender.Next, "next message of the sender")
+	assert.Equal(t, expected.expected, p.receiver.Expected, "next message of the receiver")
+	assert.Equal(t, expected.buffer, p.receiver.Buffered(), "buffered messages")
+	assert.Equal(t, expected.delivered, p.receiver.Delivered(), "delivered messages")
+	assert.ElementsMatch(t, expected.msgs, p.channel.InFlight(), "messages in flight")
+}
+
+// check the error of an action against the spec
+func (p *testProtocol) checkAction(t *testing.T, a TestAction) {
+	err := p.execute(t, a)
+	if a.error == "" {
+		require.NoError(t, err, "the action should succeed")
+	} else {
+		require.True(t, errors.Is(err, errorsOfSpec[a.error]),
+			"expected the error %q, found %v", a.error, err)
+	}
+}
+
+// execute all actions of a trace, one by one, and return the protocol in the last state
+func ExecFromItf(t *testing.T, filename string) *testProtocol {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	protocol := setupProtocol(states[0])
+	from, to := *fromState, *toState
+	if to < 0 || to >= len(states) {
+		to = len(states) - 1
+	}
+	require.True(t, from >= 0 && from <= to, "expected a range of states in 0..%d, found %d..%d",
+		len(states)-1, *fromState, *toState)
+	if from > 0 {
+		ok := t.Run(fmt.Sprintf("fast-forward_to_%d", from), func(t *testing.T) {
+			for _, s := range states[1:from] {
+				protocol.checkAction(t, s.lastAction)
+			}
+		})
+		if !ok {
+			return protocol
+		}
+	}
+	for i := from; i <= to; i++ {
+		s := states[i]
+		description := fmt.Sprintf("%d_%s_%s_%d", i, s.lastAction.kind, s.lastAction.msg.Kind, s.lastAction.msg.Seq)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				protocol.checkAction(t, s.lastAction)
+			}
+			protocol.checkState(t, s)
+		})
+		if !ok {
+			// the states of the implementation and the spec diverged
+			break
+		}
+	}
+	return protocol
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// all messages are delivered in order and acknowledged, despite losses
+func TestDone(t *testing.T) {
+	protocol := ExecFromItf(t, tracePath("done.itf.json"))
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, protocol.receiver.Delivered())
+	assert.Equal(t, 6, protocol.sender.Base)
+}
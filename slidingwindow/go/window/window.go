@@ -0,0 +1,189 @@
+// Package window is a small implementation of a sliding-window protocol with
+// selective repeat and cumulative acknowledgements. A sender transmits a
+// fixed number of data messages to a receiver, which delivers them in order.
+//
+// The sender and the receiver talk over a Channel, which holds the messages
+// in flight until whoever drives the channel delivers or drops them. Thus,
+// the channel may lose and reorder messages, and it holds every message at
+// most once, so a message that is sent again while it is in flight is not
+// duplicated in the channel.
+package window
+
+import (
+	"errors"
+	"sort"
+)
+
+// The errors of the operations, which change nothing when they fail.
+var (
+	ErrWindowFull  = errors.New("window full")
+	ErrAllSent     = errors.New("all sent")
+	ErrNotInWindow = errors.New("not in window")
+	ErrNotInFlight = errors.New("not in flight")
+)
+
+// Kind is the kind of a message.
+type Kind string
+
+// The kinds of messages: data from the sender, and acknowledgements from the receiver.
+const (
+	Data Kind = "data"
+	Ack  Kind = "ack"
+)
+
+// Message is a data message with its number, or an acknowledgement with the
+// number of the next message that the receiver expects.
+type Message struct {
+	Kind Kind
+	Seq  int
+}
+
+// Channel holds the messages in flight between the sender and the receiver.
+type Channel struct {
+	msgs     map[Message]bool
+	sender   *Sender
+	receiver *Receiver
+}
+
+// NewChannel returns an empty channel. The sender and the receiver connect to it.
+func NewChannel() *Channel {
+	return &Channel{msgs: make(map[Message]bool)}
+}
+
+func (c *Channel) send(m Message) {
+	c.msgs[m] = true
+}
+
+// Deliver delivers a message in flight to its destination.
+func (c *Channel) Deliver(m Message) error {
+	if !c.msgs[m] {
+		return ErrNotInFlight
+	}
+	delete(c.msgs, m)
+	if m.Kind == Data {
+		c.receiver.onData(m.Seq)
+	} else {
+		c.sender.onAck(m.Seq)
+	}
+	return nil
+}
+
+// Drop loses a message in flight.
+func (c *Channel) Drop(m Message) error {
+	if !c.msgs[m] {
+		return ErrNotInFlight
+	}
+	delete(c.msgs, m)
+	return nil
+}
+
+// InFlight returns the messages in flight, ordered by kind and number.
+func (c *Channel) InFlight() []Message {
+	msgs := make([]Message, 0, len(c.msgs))
+	for m := range c.msgs {
+		msgs = append(msgs, m)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].Kind != msgs[j].Kind {
+			return msgs[i].Kind < msgs[j].Kind
+		}
+		return msgs[i].Seq < msgs[j].Seq
+	})
+	return msgs
+}
+
+// Sender sends the data messages 0, ..., total - 1.
+type Sender struct {
+	// the first message that was not acknowledged
+	Base int
+	// the first message that was never sent
+	Next int
+
+	total   int
+	window  int
+	channel *Channel
+}
+
+// NewSender returns a sender of total messages, of which at most window may be
+// in flight without being acknowledged.
+func NewSender(total, window int, channel *Channel) *Sender {
+	s := &Sender{total: total, window: window, channel: channel}
+	channel.sender = s
+	return s
+}
+
+// Send sends the next message, if the window is not full.
+func (s *Sender) Send() error {
+	if s.Next >= s.total {
+		return ErrAllSent
+	}
+	if s.Next >= s.Base+s.window {
+		return ErrWindowFull
+	}
+	s.channel.send(Message{Kind: Data, Seq: s.Next})
+	s.Next++
+	return nil
+}
+
+// Retransmit sends a message of the window again, e.g., on a timeout.
+func (s *Sender) Retransmit(seq int) error {
+	if seq < s.Base || seq >= s.Next {
+		return ErrNotInWindow
+	}
+	s.channel.send(Message{Kind: Data, Seq: seq})
+	return nil
+}
+
+// onAck slides the window over the acknowledged messages.
+func (s *Sender) onAck(seq int) {
+	if seq > s.Base {
+		s.Base = seq
+	}
+}
+
+// Receiver delivers the data messages in order.
+type Receiver struct {
+	// the next message that the receiver expects
+	Expected int
+
+	window    int
+	buffer    map[int]bool
+	delivered []int
+	channel   *Channel
+}
+
+// NewReceiver returns a receiver that buffers at most window messages.
+func NewReceiver(window int, channel *Channel) *Receiver {
+	r := &Receiver{window: window, buffer: make(map[int]bool), delivered: []int{}, channel: channel}
+	channel.receiver = r
+	return r
+}
+
+// Buffered returns the messages that arrived early, in order.
+func (r *Receiver) Buffered() []int {
+	seqs := make([]int, 0, len(r.buffer))
+	for seq := range r.buffer {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	return seqs
+}
+
+// Delivered returns the messages that were delivered, in order.
+func (r *Receiver) Delivered() []int {
+	return r.delivered
+}
+
+// onData buffers a message within the window, delivers the messages that are
+// next in order, and acknowledges the next message that it expects.
+func (r *Receiver) onData(seq int) {
+	if seq >= r.Expected && seq < r.Expected+r.window {
+		r.buffer[seq] = true
+	}
+	for r.buffer[r.Expected] {
+		delete(r.buffer, r.Expected)
+		r.delivered = append(r.delivered, r.Expected)
+		r.Expected++
+	}
+	r.channel.send(Message{Kind: Ack, Seq: r.Expected})
+}
@@ -0,0 +1,194 @@
+// A test harness that replays the traces of tokenbucketTest.qnt against
+// rate.Limiter of golang.org/x/time v0.3.0.
+//
+// The limiter never reads the wall clock, as we pass the time of every event
+// to AllowN and ReserveN. This time is the time of the spec in milliseconds
+// after a fixed epoch, so the clock of the limiter is driven by the trace.
+// Every state of a trace records the action that led to it in lastAction:
+// the time advancing, or an event of some tokens that is allowed right away
+// or reserves its tokens. We check whether the event was allowed and how long
+// it has to wait, and compare the tokens of the limiter with the bucket of
+// the spec, at the last use of the bucket and at the current time.
+//
+// The spec refills two tokens per second and advances the time in quarters
+// of a second, so the float tokens of the limiter are exact. TestHarness
+// replays the traces, as configured in ../harness.yaml, which also gives the
+// tolerance of the tokens for the rates that are not exact.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"golang.org/x/time/rate"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.3.0"), "the directory of ITF traces")
+
+// the configuration of TestHarness, e.g., go test -args -harness=/tmp/harness.yaml
+var harnessFile = flag.String("harness", "../harness.yaml", "the configuration of the harness")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the thousandths of a token, as in tokenbucket.qnt
+const milli = 1000
+
+// the time 0 of the spec
+var epoch = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// the time of the spec in milliseconds as a time.Time
+func at(ms int64) time.Time {
+	return epoch.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// a bucket of tokenbucket.qnt
+type TestBucket struct {
+	rate   int64
+	burst  int64
+	tokens int64
+	last   int64
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind   string
+	amount int64
+	ok     bool
+	delay  int64
+}
+
+// a state of our testing state machine
+type TestState struct {
+	bucket     TestBucket
+	now        int64
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from tokenbucketTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	return parseTrace(t, trace)
+}
+
+// parse the states of a trace
+func parseTrace(t *testing.T, trace itf.Trace) []TestState {
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.bucket = TestBucket{
+			rate:   harness.Int64(t, jsonState.Get("bucket.rate")),
+			burst:  harness.Int64(t, jsonState.Get("bucket.burst")),
+			tokens: harness.Int64(t, jsonState.Get("bucket.tokens")),
+			last:   harness.Int64(t, jsonState.Get("bucket.last")),
+		}
+		state.now = harness.Int64(t, jsonState.Get("now"))
+		state.lastAction = TestAction{
+			kind:   jsonState.Get("lastAction.kind").String(),
+			amount: harness.Int64(t, jsonState.Get("lastAction.amount")),
+			ok:     jsonState.Get("lastAction.ok").Bool(),
+			delay:  harness.Int64(t, jsonState.Get("lastAction.delay")),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the thousandths of tokens in a bucket at the time now, as available in tokenbucket.qnt
+func available(b TestBucket, now int64) int64 {
+	tokens := b.tokens + (now-b.last)*b.rate
+	if tokens > b.burst*milli {
+		return b.burst * milli
+	}
+	return tokens
+}
+
+// apply an action at the time now to the limiter, and check its outcome
+func execute(t *testing.T, limiter *rate.Limiter, now int64, a TestAction) {
+	switch a.kind {
+	case "tick":
+		// the time of the next event is taken from the trace
+
+	case "allow":
+		ok := limiter.AllowN(at(now), int(a.amount))
+		assert.Equal(t, a.ok, ok, "allowed %d tokens", a.amount)
+
+	case "reserve":
+		r := limiter.ReserveN(at(now), int(a.amount))
+		require.Equal(t, a.ok, r.OK(), "reserved %d tokens", a.amount)
+		if r.OK() {
+			delay := time.Duration(a.delay) * time.Millisecond
+			assert.Equal(t, delay, r.DelayFrom(at(now)), "delay of %d tokens", a.amount)
+		}
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+	}
+}
+
+// compare the limiter with the bucket of the spec, up to a tolerance of the tokens
+func checkState(t *testing.T, limiter *rate.Limiter, s TestState, tolerance float64) {
+	assert.Equal(t, rate.Limit(s.bucket.rate), limiter.Limit(), "rate")
+	assert.Equal(t, int(s.bucket.burst), limiter.Burst(), "burst")
+	// the limiter refills nothing at its last use
+	assert.InDelta(t, float64(s.bucket.tokens)/milli, limiter.TokensAt(at(s.bucket.last)), tolerance, "tokens at the last use")
+	assert.InDelta(t, float64(available(s.bucket, s.now))/milli, limiter.TokensAt(at(s.now)), tolerance, "tokens now")
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	execStates(t, parseItf(t, filename), 0)
+}
+
+// execute all actions of the states of a trace, and compare the tokens up to a tolerance
+func execStates(t *testing.T, states []TestState, tolerance float64) {
+	require.NotEmpty(t, states)
+	init := states[0].bucket
+	limiter := rate.NewLimiter(rate.Limit(init.rate), int(init.burst))
+	// the limiter starts empty, but it is full at the first event, as for the
+	// limiter, more than the time to fill the bucket passed since the zero time
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%d", i, s.lastAction.kind, s.lastAction.amount)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				execute(t, limiter, s.now, s.lastAction)
+			}
+			checkState(t, limiter, s, tolerance)
+		})
+		if !ok {
+			// the states of the limiter and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+func TestHarness(t *testing.T) {
+	c, err := harness.Load(*harnessFile)
+	require.NoError(t, err)
+	tolerance := c.Tolerance("tokens")
+	harness.Run(t, c, map[string]harness.Target{
+		"rate": func(t *testing.T, trace itf.Trace) {
+			execStates(t, parseTrace(t, trace), tolerance)
+		},
+	})
+}
@@ -0,0 +1,261 @@
+// Package twopc is a small implementation of two-phase commit with a
+// transaction manager and resource managers, which exchange messages over a
+// network that never loses them, but delivers them any number of times and in
+// any order. The processes may crash and recover:
+//
+//   - the transaction manager logs its decision, but keeps the set of
+//     prepared resource managers in memory only,
+//   - a resource manager logs its state once it prepared, so a resource
+//     manager that crashes before it prepared forgets its work and aborts.
+package twopc
+
+import (
+	"errors"
+	"sort"
+)
+
+// The errors of the actions, which change nothing when they fail.
+var (
+	ErrCrashed        = errors.New("crashed")
+	ErrNotCrashed     = errors.New("not crashed")
+	ErrNotWorking     = errors.New("not working")
+	ErrDecided        = errors.New("already decided")
+	ErrNotAllPrepared = errors.New("not all prepared")
+	ErrNoMessage      = errors.New("no message")
+)
+
+// State is the state of a resource manager or of the transaction manager.
+type State string
+
+// The states of the processes. The transaction manager starts in Init, and
+// the resource managers start in Working.
+const (
+	Init      State = "init"
+	Working   State = "working"
+	Prepared  State = "prepared"
+	Committed State = "committed"
+	Aborted   State = "aborted"
+)
+
+// Kind is the kind of a message.
+type Kind string
+
+// The kinds of messages: a resource manager sends MsgPrepared, and the
+// transaction manager sends its decision, MsgCommit or MsgAbort.
+const (
+	MsgPrepared Kind = "Prepared"
+	MsgCommit   Kind = "Commit"
+	MsgAbort    Kind = "Abort"
+)
+
+// Message is a message of the protocol. From is the resource manager that
+// sent MsgPrepared, and empty for the decisions of the transaction manager.
+type Message struct {
+	Kind Kind
+	From string
+}
+
+// Network holds all messages that were ever sent.
+type Network struct {
+	msgs map[Message]bool
+}
+
+// NewNetwork returns a network without messages.
+func NewNetwork() *Network {
+	return &Network{msgs: make(map[Message]bool)}
+}
+
+// Send puts a message on the network.
+func (n *Network) Send(m Message) {
+	n.msgs[m] = true
+}
+
+// Has returns whether the message was sent.
+func (n *Network) Has(m Message) bool {
+	return n.msgs[m]
+}
+
+// Messages returns the messages that were sent, ordered by kind and sender.
+func (n *Network) Messages() []Message {
+	msgs := make([]Message, 0, len(n.msgs))
+	for m := range n.msgs {
+		msgs = append(msgs, m)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].Kind != msgs[j].Kind {
+			return msgs[i].Kind < msgs[j].Kind
+		}
+		return msgs[i].From < msgs[j].From
+	})
+	return msgs
+}
+
+// ResourceManager takes part in a transaction.
+type ResourceManager struct {
+	Name  string
+	State State
+	Up    bool
+	net   *Network
+}
+
+// NewResourceManager returns a resource manager that is working.
+func NewResourceManager(name string, net *Network) *ResourceManager {
+	return &ResourceManager{Name: name, State: Working, Up: true, net: net}
+}
+
+// Prepare promises to commit and tells the transaction manager.
+func (rm *ResourceManager) Prepare() error {
+	if err := rm.checkWorking(); err != nil {
+		return err
+	}
+	rm.State = Prepared
+	rm.net.Send(Message{Kind: MsgPrepared, From: rm.Name})
+	return nil
+}
+
+// ChooseToAbort aborts on its own, which is only possible before preparing.
+func (rm *ResourceManager) ChooseToAbort() error {
+	if err := rm.checkWorking(); err != nil {
+		return err
+	}
+	rm.State = Aborted
+	return nil
+}
+
+func (rm *ResourceManager) checkWorking() error {
+	if !rm.Up {
+		return ErrCrashed
+	}
+	if rm.State != Working {
+		return ErrNotWorking
+	}
+	return nil
+}
+
+// Receive receives a decision of the transaction manager, MsgCommit or MsgAbort.
+func (rm *ResourceManager) Receive(decision Kind) error {
+	if !rm.Up {
+		return ErrCrashed
+	}
+	if !rm.net.Has(Message{Kind: decision}) {
+		return ErrNoMessage
+	}
+	if decision == MsgCommit {
+		rm.State = Committed
+	} else {
+		rm.State = Aborted
+	}
+	return nil
+}
+
+// Crash stops the resource manager. The work that was not prepared is lost.
+func (rm *ResourceManager) Crash() error {
+	if !rm.Up {
+		return ErrCrashed
+	}
+	rm.Up = false
+	if rm.State == Working {
+		rm.State = Aborted
+	}
+	return nil
+}
+
+// Recover restarts the resource manager from its log.
+func (rm *ResourceManager) Recover() error {
+	if rm.Up {
+		return ErrNotCrashed
+	}
+	rm.Up = true
+	return nil
+}
+
+// TransactionManager coordinates the resource managers.
+type TransactionManager struct {
+	State    State
+	Up       bool
+	rms      []string
+	prepared map[string]bool
+	net      *Network
+}
+
+// NewTransactionManager returns a transaction manager for the given resource managers.
+func NewTransactionManager(rms []string, net *Network) *TransactionManager {
+	return &TransactionManager{State: Init, Up: true, rms: rms, prepared: make(map[string]bool), net: net}
+}
+
+// Prepared returns the resource managers that the transaction manager knows
+// to be prepared, in order.
+func (tm *TransactionManager) Prepared() []string {
+	prepared := make([]string, 0, len(tm.prepared))
+	for rm := range tm.prepared {
+		prepared = append(prepared, rm)
+	}
+	sort.Strings(prepared)
+	return prepared
+}
+
+func (tm *TransactionManager) checkUndecided() error {
+	if !tm.Up {
+		return ErrCrashed
+	}
+	if tm.State != Init {
+		return ErrDecided
+	}
+	return nil
+}
+
+// ReceivePrepared receives MsgPrepared from a resource manager.
+func (tm *TransactionManager) ReceivePrepared(rm string) error {
+	if err := tm.checkUndecided(); err != nil {
+		return err
+	}
+	if !tm.net.Has(Message{Kind: MsgPrepared, From: rm}) {
+		return ErrNoMessage
+	}
+	tm.prepared[rm] = true
+	return nil
+}
+
+// Commit decides to commit, once all resource managers are prepared.
+func (tm *TransactionManager) Commit() error {
+	if err := tm.checkUndecided(); err != nil {
+		return err
+	}
+	for _, rm := range tm.rms {
+		if !tm.prepared[rm] {
+			return ErrNotAllPrepared
+		}
+	}
+	tm.State = Committed
+	tm.net.Send(Message{Kind: MsgCommit})
+	return nil
+}
+
+// Abort decides to abort, e.g., on a timeout.
+func (tm *TransactionManager) Abort() error {
+	if err := tm.checkUndecided(); err != nil {
+		return err
+	}
+	tm.State = Aborted
+	tm.net.Send(Message{Kind: MsgAbort})
+	return nil
+}
+
+// Crash stops the transaction manager, which forgets who is prepared.
+func (tm *TransactionManager) Crash() error {
+	if !tm.Up {
+		return ErrCrashed
+	}
+	tm.Up = false
+	tm.prepared = make(map[string]bool)
+	return nil
+}
+
+// Recover restarts the transaction manager, which reads its decision from its log.
+func (tm *TransactionManager) Recover() error {
+	if tm.Up {
+		return ErrNotCrashed
+	}
+	tm.Up = true
+	return nil
+}
@@ -0,0 +1,206 @@
+// A test harness that replays the traces of twophaseTest.qnt against the
+// implementation of two-phase commit in the package twopc.
+//
+// Every state of a trace records the action that led to it in lastAction,
+// together with the resource manager it was applied to and its error. We apply
+// the action to the corresponding process, check its error, and compare the
+// states of all processes and the messages on the network with the spec.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/twophase/twopc"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the errors of the actions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"crashed":          twopc.ErrCrashed,
+	"not crashed":      twopc.ErrNotCrashed,
+	"not working":      twopc.ErrNotWorking,
+	"already decided":  twopc.ErrDecided,
+	"not all prepared": twopc.ErrNotAllPrepared,
+	"no message":       twopc.ErrNoMessage,
+}
+
+// a process of twophase.qnt
+type TestProcess struct {
+	state string
+	up    bool
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind  string
+	rm    string
+	error string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	rms        map[string]TestProcess
+	tm         TestProcess
+	prepared   []string
+	msgs       []twopc.Message
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from twophaseTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.rms = make(map[string]TestProcess)
+		entries, err := itf.Map(jsonState.Get("sys.rms"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.rms[e.Key.String()] = TestProcess{state: e.Value.Get("state").String(), up: e.Value.Get("up").Bool()}
+		}
+		state.tm = TestProcess{
+			state: jsonState.Get("sys.tm.state").String(),
+			up:    jsonState.Get("sys.tm.up").Bool(),
+		}
+		prepared, err := itf.Set(jsonState.Get("sys.tm.prepared"))
+		require.NoError(t, err)
+		state.prepared = make([]string, 0, len(prepared))
+		for _, rm := range prepared {
+			state.prepared = append(state.prepared, rm.String())
+		}
+		sort.Strings(state.prepared)
+		msgs, err := itf.Set(jsonState.Get("sys.msgs"))
+		require.NoError(t, err)
+		state.msgs = make([]twopc.Message, 0, len(msgs))
+		for _, m := range msgs {
+			state.msgs = append(state.msgs, twopc.Message{
+				Kind: twopc.Kind(m.Get("kind").String()),
+				From: m.Get("rm").String(),
+			})
+		}
+		state.lastAction = TestAction{
+			kind:  jsonState.Get("lastAction.kind").String(),
+			rm:    jsonState.Get("lastAction.rm").String(),
+			error: jsonState.Get("lastAction.error").String(),
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// the processes under test
+type testSystem struct {
+	net *twopc.Network
+	tm  *twopc.TransactionManager
+	rms map[string]*twopc.ResourceManager
+}
+
+// create the processes of the initial state
+func setupSystem(init TestState) *testSystem {
+	net := twopc.NewNetwork()
+	names := make([]string, 0, len(init.rms))
+	rms := make(map[string]*twopc.ResourceManager)
+	for name := range init.rms {
+		names = append(names, name)
+		rms[name] = twopc.NewResourceManager(name, net)
+	}
+	sort.Strings(names)
+	return &testSystem{net: net, tm: twopc.NewTransactionManager(names, net), rms: rms}
+}
+
+// apply an action to the processes
+func (s *testSystem) execute(t *testing.T, a TestAction) error {
+	switch a.kind {
+	case "prepare":
+		return s.rms[a.rm].Prepare()
+	case "chooseToAbort":
+		return s.rms[a.rm].ChooseToAbort()
+	case "receiveCommit":
+		return s.rms[a.rm].Receive(twopc.MsgCommit)
+	case "receiveAbort":
+		return s.rms[a.rm].Receive(twopc.MsgAbort)
+	case "crash":
+		return s.rms[a.rm].Crash()
+	case "recover":
+		return s.rms[a.rm].Recover()
+	case "tmRcvPrepared":
+		return s.tm.ReceivePrepared(a.rm)
+	case "tmCommit":
+		return s.tm.Commit()
+	case "tmAbort":
+		return s.tm.Abort()
+	case "tmCrash":
+		return s.tm.Crash()
+	case "tmRecover":
+		return s.tm.Recover()
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+		return nil
+	}
+}
+
+// compare the processes and the network with the state of the spec
+func (s *testSystem) checkState(t *testing.T, expected TestState) {
+	for name, rm := range expected.rms {
+		assert.Equal(t, rm.state, string(s.rms[name].State), "state of %s", name)
+		assert.Equal(t, rm.up, s.rms[name].Up, "%s is up", name)
+	}
+	assert.Equal(t, expected.tm.state, string(s.tm.State), "state of the transaction manager")
+	assert.Equal(t, expected.tm.up, s.tm.Up, "the transaction manager is up")
+	assert.Equal(t, expected.prepared, s.tm.Prepared(), "prepared resource managers")
+	assert.ElementsMatch(t, expected.msgs, s.net.Messages(), "messages")
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	system := setupSystem(states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, s.lastAction.rm)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := system.execute(t, s.lastAction)
+				if s.lastAction.error == "" {
+					require.NoError(t, err, "the action should succeed")
+				} else {
+					require.True(t, errors.Is(err, errorsOfSpec[s.lastAction.error]),
+						"expected the error %q, found %v", s.lastAction.error, err)
+				}
+			}
+			system.checkState(t, s)
+		})
+		if !ok {
+			// the states of the implementation and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+func TestAllCommitted(t *testing.T) {
+	ExecFromItf(t, tracePath("allCommitted.itf.json"))
+}
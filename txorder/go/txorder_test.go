@@ -0,0 +1,275 @@
+// A test harness that replays the traces of txorderTest.qnt against the bank
+// and staking modules of SimApp, as shipped with cosmos-sdk v0.46.4.
+//
+// The validator, to which the accounts of the spec delegate, is created and
+// bonded on setup. Every state of a trace records the action that led to it
+// in lastAction: the submission of a transaction to the mempool, which does
+// not touch the chain, or a block, whose transactions are recorded in the
+// order that the spec chose for them. We execute the transactions of a block
+// through the message servers in this order, compare their failures with the
+// spec, and compare the balances, the delegations, and the unbonding
+// delegations of all accounts with the state of the spec.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingkeeper "github.com/cosmos/cosmos-sdk/x/staking/keeper"
+	"github.com/cosmos/cosmos-sdk/x/staking/teststaking"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+// the denomination of the tokens of the spec
+const denom = sdk.DefaultBondDenom
+
+// the accounts of txorderTest.qnt, and their initial balances
+var (
+	accounts       = []string{"alice", "bob", "carol"}
+	initialBalance = sdk.NewInt(100)
+)
+
+// the validator, to which the accounts delegate, and its self-delegation
+const validator = "val"
+
+var selfDelegation = sdk.NewInt(10_000_000)
+
+// the time between two blocks
+const blockTime = 5 * time.Second
+
+// a transaction of a block
+type TestTx struct {
+	kind     string
+	sender   string
+	receiver string
+	amount   *big.Int
+}
+
+// the tokens of the accounts, by their names
+type TestChain struct {
+	balances  map[string]*big.Int
+	delegated map[string]*big.Int
+	unbonding map[string]*big.Int
+	entries   map[string]*big.Int
+}
+
+// a state of our testing state machine
+type TestState struct {
+	chain  TestChain
+	height int64
+	// the action that led to the state, with the transactions of a block
+	// in the order of their execution, and whether they failed
+	lastAction struct {
+		kind   string
+		txs    []TestTx
+		errors []bool
+	}
+}
+
+// parse the states in the ITF JSON format, as produced from txorderTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.chain.balances = parseTokens(t, jsonState.Get("chain.balances"))
+		state.chain.delegated = parseTokens(t, jsonState.Get("chain.delegated"))
+		state.chain.unbonding = parseTokens(t, jsonState.Get("chain.unbonding"))
+		state.chain.entries = parseTokens(t, jsonState.Get("chain.entries"))
+		height, err := itf.BigInt(jsonState.Get("height"))
+		require.NoError(t, err)
+		state.height = height.Int64()
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		for _, tx := range jsonState.Get("lastAction.txs").Array() {
+			amount, err := itf.BigInt(tx.Get("amount"))
+			require.NoError(t, err)
+			state.lastAction.txs = append(state.lastAction.txs, TestTx{
+				kind:     tx.Get("kind").String(),
+				sender:   tx.Get("sender").String(),
+				receiver: tx.Get("receiver").String(),
+				amount:   amount,
+			})
+		}
+		for _, e := range jsonState.Get("lastAction.errors").Array() {
+			state.lastAction.errors = append(state.lastAction.errors, e.Bool())
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a map from the names of the accounts to amounts
+func parseTokens(t *testing.T, obj gjson.Result) map[string]*big.Int {
+	tokens, err := itf.StrBigIntMap(obj)
+	require.NoError(t, err)
+	return tokens
+}
+
+// SimApp, which is set up once for all traces, see simchain
+var chain *simchain.Chain
+
+// Reset the chain, whose accounts are funded, and whose validator is created
+// with its self-delegation and bonded on setup, as in the initial state of
+// the spec.
+func resetChain(t *testing.T) *simchain.Chain {
+	if chain == nil {
+		balances := map[string]sdk.Coins{
+			validator: sdk.NewCoins(sdk.NewCoin(denom, selfDelegation)),
+		}
+		for _, name := range accounts {
+			balances[name] = sdk.NewCoins(sdk.NewCoin(denom, initialBalance))
+		}
+		chain = simchain.New(t, simchain.Config{
+			Modules:  []string{simchain.Staking},
+			Balances: balances,
+			Setup: func(c *simchain.Chain) {
+				tstaking := teststaking.NewHelper(t, c.Ctx, c.Keepers.Staking)
+				tstaking.CreateValidator(simchain.ValAddr(validator), ed25519.GenPrivKey().PubKey(), selfDelegation, true)
+				c.EndBlock()
+			},
+		})
+	}
+	chain.Reset()
+	return chain
+}
+
+// Execute a transaction through the message server of its module, as the
+// router of SimApp does. As in the execution of a real transaction, the
+// writes are discarded when the transaction fails.
+func executeTx(c *simchain.Chain, tx TestTx) error {
+	cacheCtx, write := c.Ctx.CacheContext()
+	goCtx := sdk.WrapSDKContext(cacheCtx)
+	amount := sdk.NewCoin(denom, sdk.NewIntFromBigInt(tx.amount))
+	sender := simchain.Addr(tx.sender).String()
+	var err error
+	switch tx.kind {
+	case "send":
+		_, err = bankkeeper.NewMsgServerImpl(c.Keepers.Bank).Send(goCtx,
+			banktypes.NewMsgSend(simchain.Addr(tx.sender), simchain.Addr(tx.receiver), sdk.NewCoins(amount)))
+
+	case "delegate":
+		_, err = stakingkeeper.NewMsgServerImpl(c.Keepers.Staking).Delegate(goCtx, &stakingtypes.MsgDelegate{
+			DelegatorAddress: sender,
+			ValidatorAddress: simchain.ValAddr(validator).String(),
+			Amount:           amount,
+		})
+
+	case "undelegate":
+		_, err = stakingkeeper.NewMsgServerImpl(c.Keepers.Staking).Undelegate(goCtx, &stakingtypes.MsgUndelegate{
+			DelegatorAddress: sender,
+			ValidatorAddress: simchain.ValAddr(validator).String(),
+			Amount:           amount,
+		})
+
+	default:
+		return fmt.Errorf("unknown transaction: %s", tx.kind)
+	}
+	if err == nil {
+		write()
+	}
+	return err
+}
+
+// Execute the transactions of a block in the order of the trace, and move
+// to the next block.
+func executeBlock(t *testing.T, c *simchain.Chain, s TestState) {
+	require.Len(t, s.lastAction.errors, len(s.lastAction.txs), "errors of the block")
+	for i, tx := range s.lastAction.txs {
+		err := executeTx(c, tx)
+		if s.lastAction.errors[i] {
+			require.Error(t, err, "the transaction %d (%s of %s) should fail", i, tx.kind, tx.sender)
+		} else {
+			require.NoError(t, err, "the transaction %d (%s of %s) should succeed", i, tx.kind, tx.sender)
+		}
+	}
+	c.NextBlock(s.height, time.Unix(0, 0).Add(time.Duration(s.height)*blockTime).UTC())
+}
+
+// compare the tokens of the accounts with the state of the spec
+func checkState(t *testing.T, c *simchain.Chain, s TestState) {
+	val, found := c.Keepers.Staking.GetValidator(c.Ctx, simchain.ValAddr(validator))
+	require.True(t, found, "validator %s", validator)
+	for _, name := range accounts {
+		addr := simchain.Addr(name)
+		balance := c.Keepers.Bank.GetBalance(c.Ctx, addr, denom)
+		assert.Equal(t, s.chain.balances[name].String(), balance.Amount.String(), "balance of %s", name)
+
+		delegated := sdk.ZeroInt()
+		if delegation, found := c.Keepers.Staking.GetDelegation(c.Ctx, addr, val.GetOperator()); found {
+			delegated = val.TokensFromShares(delegation.Shares).TruncateInt()
+		}
+		assert.Equal(t, s.chain.delegated[name].String(), delegated.String(), "delegation of %s", name)
+
+		unbonding, entries := sdk.ZeroInt(), 0
+		if ubd, found := c.Keepers.Staking.GetUnbondingDelegation(c.Ctx, addr, val.GetOperator()); found {
+			for _, entry := range ubd.Entries {
+				unbonding = unbonding.Add(entry.Balance)
+			}
+			entries = len(ubd.Entries)
+		}
+		assert.Equal(t, s.chain.unbonding[name].String(), unbonding.String(), "unbonding of %s", name)
+		assert.Equal(t, s.chain.entries[name].Int64(), int64(entries), "unbonding entries of %s", name)
+	}
+}
+
+// a short description of the transactions of an action, e.g., send_alice_delegate_bob
+func describe(txs []TestTx) string {
+	var parts []string
+	for _, tx := range txs {
+		parts = append(parts, tx.kind+"_"+tx.sender)
+	}
+	return strings.Join(parts, "_")
+}
+
+// execute all blocks of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	c := resetChain(t)
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%s_%s", i, s.lastAction.kind, describe(s.lastAction.txs))
+		ok := t.Run(description, func(t *testing.T) {
+			// a submission only adds a transaction to the mempool of the spec
+			if s.lastAction.kind == "block" {
+				executeBlock(t, c, s)
+			}
+			checkState(t, c, s)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
@@ -0,0 +1,321 @@
+// A test harness that replays the traces of unbondingTest.qnt against the
+// unbonding queue of x/staking of SimApp, as shipped with cosmos-sdk v0.46.4.
+//
+// A validator is created and bonded once, when SimApp is set up for all
+// traces, see simchain, and the delegators of the spec delegate their initial
+// tokens to it at the start of every trace. Every state of a trace records
+// the action that led to it in lastAction: a delegation or an undelegation
+// in the current block, or the end of the block. We run the transactions in
+// a cached context, which we only write when they succeed, as the SDK does.
+// At the end of a block, we run the end blocker of staking at the block time
+// of the spec, which dequeues the mature time slices and completes the
+// unbonding delegations in them, and begin the next block at the height and
+// the time that the trace tells. Then we compare the balances, the
+// delegations, the entries of the unbonding delegations, and the time slices
+// of the unbonding queue with the state of the spec.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/x/staking/teststaking"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+const denom = sdk.DefaultBondDenom
+
+// the parameters of unbonding.qnt
+var params = stakingtypes.NewParams(
+	60*time.Second, // UNBONDING_TIME
+	100,            // not modeled
+	3,              // MAX_ENTRIES
+	0,              // not modeled
+	denom,
+	sdk.ZeroDec(), // not modeled
+)
+
+// the block time that the times of the spec are relative to
+var genesisTime = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// the name of the validator, which is not in the spec
+const validator = "validator"
+
+// the errors of the transactions, as they are written in the spec
+var errorsOfSpec = map[string]error{
+	"insufficient funds":                    sdkerrors.ErrInsufficientFunds,
+	"delegator does not contain delegation": stakingtypes.ErrNoDelegatorForAddress,
+	"not enough delegation shares":          stakingtypes.ErrNotEnoughDelegationShares,
+	"too many unbonding delegation entries for (delegator, validator) tuple": stakingtypes.ErrMaxUnbondingDelegationEntries,
+}
+
+// an entry of an unbonding delegation, with the completion time in seconds since genesis
+type TestEntry struct {
+	creationHeight int64
+	completionTime int64
+	balance        int64
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind      string
+	delegator string
+	amount    int64
+	error     string
+}
+
+// a state of our testing state machine
+type TestState struct {
+	height     int64
+	time       int64
+	balances   map[string]int64
+	delegated  map[string]int64
+	entries    map[string][]TestEntry
+	queue      map[int64][]string
+	lastAction TestAction
+}
+
+// parse the states in the ITF JSON format, as produced from unbondingTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.height = harness.Int64(t, jsonState.Get("chain.height"))
+		state.time = harness.Int64(t, jsonState.Get("chain.time"))
+		state.balances = parseIntMap(t, jsonState.Get("chain.balances"))
+		state.delegated = parseIntMap(t, jsonState.Get("chain.delegated"))
+		state.entries = make(map[string][]TestEntry)
+		entries, err := itf.Map(jsonState.Get("chain.entries"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			for _, entry := range e.Value.Array() {
+				state.entries[e.Key.String()] = append(state.entries[e.Key.String()], TestEntry{
+					creationHeight: harness.Int64(t, entry.Get("creationHeight")),
+					completionTime: harness.Int64(t, entry.Get("completionTime")),
+					balance:        harness.Int64(t, entry.Get("balance")),
+				})
+			}
+		}
+		state.queue = make(map[int64][]string)
+		entries, err = itf.Map(jsonState.Get("chain.queue"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			var delegators []string
+			for _, d := range e.Value.Array() {
+				delegators = append(delegators, d.String())
+			}
+			state.queue[harness.Int64(t, e.Key)] = delegators
+		}
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.delegator = jsonState.Get("lastAction.delegator").String()
+		state.lastAction.amount = harness.Int64(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.error = jsonState.Get("lastAction.error").String()
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a map from strings to integers that fit into int64
+func parseIntMap(t *testing.T, obj gjson.Result) map[string]int64 {
+	entries, err := itf.Map(obj)
+	require.NoError(t, err)
+	m := make(map[string]int64, len(entries))
+	for _, e := range entries {
+		m[e.Key.String()] = harness.Int64(t, e.Value)
+	}
+	return m
+}
+
+// the block time that is given in seconds since genesis
+func specTime(seconds int64) time.Time {
+	return genesisTime.Add(time.Duration(seconds) * time.Second)
+}
+
+// SimApp with the parameters of the spec and the bonded validator, which is
+// set up once for all traces, see simchain
+var sharedChain *simchain.Chain
+
+// the chain under test at the current block
+type testChain struct {
+	*simchain.Chain
+}
+
+// Reset the chain, whose parameters are set, and whose validator is created
+// and bonded on setup, and let the delegators delegate their initial tokens.
+func setupChain(t *testing.T, init TestState) *testChain {
+	if sharedChain == nil {
+		sharedChain = simchain.New(t, simchain.Config{
+			Modules: []string{simchain.Staking},
+			Setup: func(c *simchain.Chain) {
+				c.Keepers.Staking.SetParams(c.Ctx, params)
+				selfBond := sdk.TokensFromConsensusPower(10, c.Keepers.Staking.PowerReduction(c.Ctx))
+				require.NoError(t, c.Fund(validator, sdk.NewCoins(sdk.NewCoin(denom, selfBond))))
+				pk := ed25519.GenPrivKeyFromSecret([]byte(validator)).PubKey()
+				teststaking.NewHelper(t, c.Ctx, c.Keepers.Staking).CreateValidator(simchain.ValAddr(validator), pk, selfBond, true)
+				c.EndBlock()
+			},
+		})
+	}
+	c := &testChain{Chain: sharedChain}
+	c.Reset()
+	c.Ctx = c.Ctx.WithBlockHeight(init.height).WithBlockTime(specTime(init.time))
+	for d, balance := range init.balances {
+		require.NoError(t, c.Fund(d, sdk.NewCoins(sdk.NewInt64Coin(denom, balance+init.delegated[d]))))
+		require.NoError(t, c.delegate(d, init.delegated[d]))
+	}
+	return c
+}
+
+// delegate tokens of a delegator to the validator
+func (c *testChain) delegate(d string, amount int64) error {
+	v, found := c.Keepers.Staking.GetValidator(c.Ctx, simchain.ValAddr(validator))
+	if !found {
+		return stakingtypes.ErrNoValidatorFound
+	}
+	_, err := c.Keepers.Staking.Delegate(c.Ctx, simchain.Addr(d), sdk.NewInt(amount), stakingtypes.Unbonded, v, true)
+	return err
+}
+
+// run a transaction in a cached context, which is written only on success
+func (c *testChain) deliver(tx func(c *testChain) error) error {
+	ctx := c.Ctx
+	cacheCtx, write := ctx.CacheContext()
+	c.Ctx = cacheCtx
+	err := tx(c)
+	c.Ctx = ctx
+	if err == nil {
+		write()
+	}
+	return err
+}
+
+// apply an action of the spec, where next is the state after the action
+func (c *testChain) executeAction(a TestAction, next TestState) error {
+	switch a.kind {
+	case "delegate":
+		return c.deliver(func(c *testChain) error {
+			return c.delegate(a.delegator, a.amount)
+		})
+
+	case "undelegate":
+		return c.deliver(func(c *testChain) error {
+			_, err := c.Keepers.Staking.Undelegate(c.Ctx, simchain.Addr(a.delegator), simchain.ValAddr(validator), sdk.NewDec(a.amount))
+			return err
+		})
+
+	case "endBlock":
+		c.NextBlock(next.height, specTime(next.time))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown action: %s", a.kind)
+	}
+}
+
+// compare the staking and bank keepers with the state of the spec
+func (c *testChain) checkState(t *testing.T, s TestState) {
+	k := c.Keepers.Staking
+	assert.Equal(t, s.height, c.Ctx.BlockHeight(), "height")
+	assert.True(t, specTime(s.time).Equal(c.Ctx.BlockTime()), "time: expected %s, found %s", specTime(s.time), c.Ctx.BlockTime())
+	for d, balance := range s.balances {
+		assert.Equal(t, sdk.NewInt(balance).String(), c.Keepers.Bank.GetBalance(c.Ctx, simchain.Addr(d), denom).Amount.String(), "balance of %s", d)
+
+		delegation, found := k.GetDelegation(c.Ctx, simchain.Addr(d), simchain.ValAddr(validator))
+		assert.Equal(t, s.delegated[d] > 0, found, "delegation of %s", d)
+		if found {
+			assert.Equal(t, sdk.NewDec(s.delegated[d]).String(), delegation.Shares.String(), "shares of %s", d)
+		}
+
+		ubd, found := k.GetUnbondingDelegation(c.Ctx, simchain.Addr(d), simchain.ValAddr(validator))
+		assert.Equal(t, len(s.entries[d]) > 0, found, "unbonding delegation of %s", d)
+		require.Len(t, ubd.Entries, len(s.entries[d]), "entries of %s", d)
+		for i, expected := range s.entries[d] {
+			entry := ubd.Entries[i]
+			assert.Equal(t, expected.creationHeight, entry.CreationHeight, "creation height of the entry %d of %s", i, d)
+			assert.True(t, specTime(expected.completionTime).Equal(entry.CompletionTime),
+				"completion time of the entry %d of %s: expected %s, found %s", i, d, specTime(expected.completionTime), entry.CompletionTime)
+			assert.Equal(t, sdk.NewInt(expected.balance).String(), entry.Balance.String(), "balance of the entry %d of %s", i, d)
+			assert.Equal(t, sdk.NewInt(expected.balance).String(), entry.InitialBalance.String(), "initial balance of the entry %d of %s", i, d)
+		}
+	}
+	for completion, delegators := range s.queue {
+		pairs := k.GetUBDQueueTimeSlice(c.Ctx, specTime(completion))
+		require.Len(t, pairs, len(delegators), "time slice at %d", completion)
+		for i, d := range delegators {
+			assert.Equal(t, simchain.Addr(d).String(), pairs[i].DelegatorAddress, "delegator %d of the time slice at %d", i, completion)
+			assert.Equal(t, simchain.ValAddr(validator).String(), pairs[i].ValidatorAddress, "validator %d of the time slice at %d", i, completion)
+		}
+	}
+	// the end blocker dequeued every time slice up to the block time that the spec does not have
+	for seconds := int64(0); seconds <= s.time; seconds++ {
+		if _, found := s.queue[seconds]; !found {
+			assert.Empty(t, k.GetUBDQueueTimeSlice(c.Ctx, specTime(seconds)), "time slice at %d", seconds)
+		}
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	chain := setupChain(t, states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%d_%s_%s", i, s.height, s.lastAction.kind, s.lastAction.delegator)
+		ok := t.Run(description, func(t *testing.T) {
+			if i > 0 {
+				err := chain.executeAction(s.lastAction, s)
+				if s.lastAction.error == "" {
+					require.NoError(t, err)
+				} else {
+					expected, found := errorsOfSpec[s.lastAction.error]
+					require.True(t, found, "unknown error: %s", s.lastAction.error)
+					require.True(t, errors.Is(err, expected), "expected %v, found %v", expected, err)
+				}
+			}
+			chain.checkState(t, s)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}
+
+// One end blocker completes the unbonding of several entries, see
+// noMassCompletion in unbondingTest.qnt.
+func TestMassCompletion(t *testing.T) {
+	ExecFromItf(t, tracePath("massCompletion.itf.json"))
+}
@@ -0,0 +1,280 @@
+// A test harness that replays the traces of vestingTest.qnt against the
+// vesting accounts of x/auth/vesting and the bank keeper of SimApp, as shipped
+// with cosmos-sdk v0.46.4.
+//
+// The vesting accounts of the spec are created on setup and funded with their
+// original vesting. Every state of a trace records the action that led to it
+// in lastAction: a new block, or a transfer at the current block time. We
+// execute the action with the keepers at the block time of the spec, and
+// compare the balances, the delegated coins, and the vested, locked, and
+// spendable coins of every account with the state of the spec.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tidwall/gjson"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	vestexported "github.com/cosmos/cosmos-sdk/x/auth/vesting/exported"
+	vestingtypes "github.com/cosmos/cosmos-sdk/x/auth/vesting/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+
+	"github.com/informalsystems/quint-sandbox/itf"
+	"github.com/informalsystems/quint-sandbox/itf/harness"
+	"github.com/informalsystems/quint-sandbox/simchain"
+)
+
+// the directory to read the traces from, e.g., go test -args -itf-dir=/tmp/traces,
+// or ITF_TRACE_DIR=/tmp/traces go test -count=1, see itf.TraceDir
+var traceDir = flag.String("itf-dir", itf.TraceDir("../test-inputs-v0.46.4"), "the directory of ITF traces")
+
+// the path to a trace in the trace directory
+func tracePath(name string) string {
+	return filepath.Join(*traceDir, name)
+}
+
+const (
+	denom = sdk.DefaultBondDenom
+	// the account that receives the coins that are sent
+	sink = "sink"
+	// the module account that holds the delegated coins
+	delegationModule = stakingtypes.NotBondedPoolName
+)
+
+// the block time that the times of the spec are relative to
+var genesisTime = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// a period of a periodic vesting account, its length in seconds
+type TestPeriod struct {
+	length int64
+	amount *big.Int
+}
+
+// a vesting account of vesting.qnt
+type TestAccount struct {
+	kind             string
+	original         *big.Int
+	start            int64
+	end              int64
+	periods          []TestPeriod
+	balance          *big.Int
+	delegatedFree    *big.Int
+	delegatedVesting *big.Int
+}
+
+// the coins of an account at the current time
+type TestObserved struct {
+	vested    *big.Int
+	locked    *big.Int
+	spendable *big.Int
+}
+
+// an action, as recorded in lastAction
+type TestAction struct {
+	kind    string
+	account string
+	amount  *big.Int
+	error   bool
+}
+
+// a state of our testing state machine
+type TestState struct {
+	time       int64
+	accounts   map[string]TestAccount
+	lastAction TestAction
+	observed   map[string]TestObserved
+}
+
+// parse the states in the ITF JSON format, as produced from vestingTest.qnt
+func parseItf(t *testing.T, filename string) []TestState {
+	trace, err := itf.ReadFile(filename)
+	require.NoError(t, err)
+	var states = make([]TestState, 0, len(trace.States))
+	for _, jsonState := range trace.States {
+		var state TestState
+		state.time = harness.Int64(t, jsonState.Get("time"))
+		state.accounts = make(map[string]TestAccount)
+		entries, err := itf.Map(jsonState.Get("accounts"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.accounts[e.Key.String()] = parseAccount(t, e.Value)
+		}
+		state.lastAction.kind = jsonState.Get("lastAction.kind").String()
+		state.lastAction.account = jsonState.Get("lastAction.account").String()
+		state.lastAction.amount = harness.BigInt(t, jsonState.Get("lastAction.amount"))
+		state.lastAction.error = jsonState.Get("lastAction.error").Bool()
+		state.observed = make(map[string]TestObserved)
+		entries, err = itf.Map(jsonState.Get("observed"))
+		require.NoError(t, err)
+		for _, e := range entries {
+			state.observed[e.Key.String()] = TestObserved{
+				vested:    harness.BigInt(t, e.Value.Get("vested")),
+				locked:    harness.BigInt(t, e.Value.Get("locked")),
+				spendable: harness.BigInt(t, e.Value.Get("spendable")),
+			}
+		}
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// parse a vesting account of vesting.qnt
+func parseAccount(t *testing.T, obj gjson.Result) TestAccount {
+	account := TestAccount{
+		kind:             obj.Get("kind").String(),
+		original:         harness.BigInt(t, obj.Get("original")),
+		start:            harness.Int64(t, obj.Get("start")),
+		end:              harness.Int64(t, obj.Get("end")),
+		balance:          harness.BigInt(t, obj.Get("balance")),
+		delegatedFree:    harness.BigInt(t, obj.Get("delegatedFree")),
+		delegatedVesting: harness.BigInt(t, obj.Get("delegatedVesting")),
+	}
+	for _, p := range obj.Get("periods").Array() {
+		account.periods = append(account.periods, TestPeriod{
+			length: harness.Int64(t, p.Get("length")),
+			amount: harness.BigInt(t, p.Get("amount")),
+		})
+	}
+	return account
+}
+
+// the coins of the given amount in the single denomination of the spec
+func coins(amount *big.Int) sdk.Coins {
+	return sdk.NewCoins(sdk.NewCoin(denom, sdk.NewIntFromBigInt(amount)))
+}
+
+// the block time that is given in seconds since genesis
+func specTime(seconds int64) time.Time {
+	return genesisTime.Add(time.Duration(seconds) * time.Second)
+}
+
+// the vesting account of the SDK that corresponds to an account of the spec
+func vestingAccount(t *testing.T, name string, a TestAccount) vestexported.VestingAccount {
+	base := authtypes.NewBaseAccountWithAddress(simchain.Addr(name))
+	start, end := genesisTime.Unix()+a.start, genesisTime.Unix()+a.end
+	switch a.kind {
+	case "continuous":
+		return vestingtypes.NewContinuousVestingAccount(base, coins(a.original), start, end)
+
+	case "delayed":
+		return vestingtypes.NewDelayedVestingAccount(base, coins(a.original), end)
+
+	case "periodic":
+		periods := vestingtypes.Periods{}
+		for _, p := range a.periods {
+			periods = append(periods, vestingtypes.Period{Length: p.length, Amount: coins(p.amount)})
+		}
+		account := vestingtypes.NewPeriodicVestingAccount(base, coins(a.original), start, periods)
+		require.Equal(t, end, account.EndTime, "the end of the periods")
+		return account
+
+	default:
+		require.Fail(t, "unknown vesting account: "+a.kind)
+		return nil
+	}
+}
+
+// SimApp, which is set up once for all traces, see simchain
+var sharedChain *simchain.Chain
+
+// reset the chain, and create the vesting accounts of the initial state and fund them
+func setupChain(t *testing.T, init TestState) *simchain.Chain {
+	if sharedChain == nil {
+		sharedChain = simchain.New(t, simchain.Config{})
+	}
+	c := sharedChain
+	c.Reset()
+	c.Ctx = c.Ctx.WithBlockTime(specTime(init.time))
+	for name, a := range init.accounts {
+		account := c.Keepers.Account.NewAccount(c.Ctx, vestingAccount(t, name, a))
+		c.Keepers.Account.SetAccount(c.Ctx, account)
+		require.NoError(t, c.Fund(name, coins(a.balance)))
+	}
+	return c
+}
+
+// execute an action with the keepers at the block time of the context
+func executeAction(t *testing.T, c *simchain.Chain, a TestAction) error {
+	amount := coins(a.amount)
+	switch a.kind {
+	case "send":
+		return c.Keepers.Bank.SendCoins(c.Ctx, simchain.Addr(a.account), simchain.Addr(sink), amount)
+
+	case "receive":
+		return c.Fund(a.account, amount)
+
+	case "delegate":
+		return c.Keepers.Bank.DelegateCoinsFromAccountToModule(c.Ctx, simchain.Addr(a.account), delegationModule, amount)
+
+	case "undelegate":
+		return c.Keepers.Bank.UndelegateCoinsFromModuleToAccount(c.Ctx, delegationModule, simchain.Addr(a.account), amount)
+
+	default:
+		require.Fail(t, "unknown action: "+a.kind)
+		return nil
+	}
+}
+
+// compare the accounts at the block time of the context with the state of the spec
+func checkState(t *testing.T, c *simchain.Chain, s TestState) {
+	blockTime := c.Ctx.BlockTime()
+	for name, expected := range s.accounts {
+		account, ok := c.Keepers.Account.GetAccount(c.Ctx, simchain.Addr(name)).(vestexported.VestingAccount)
+		require.True(t, ok, "%s should be a vesting account", name)
+		observed := s.observed[name]
+		balance := c.Keepers.Bank.GetBalance(c.Ctx, simchain.Addr(name), denom).Amount
+		assert.Equal(t, expected.balance.String(), balance.String(), "balance of %s", name)
+		assert.Equal(t, expected.delegatedFree.String(), account.GetDelegatedFree().AmountOf(denom).String(),
+			"delegated free coins of %s", name)
+		assert.Equal(t, expected.delegatedVesting.String(), account.GetDelegatedVesting().AmountOf(denom).String(),
+			"delegated vesting coins of %s", name)
+		assert.Equal(t, observed.vested.String(), account.GetVestedCoins(blockTime).AmountOf(denom).String(),
+			"vested coins of %s", name)
+		assert.Equal(t, observed.locked.String(), account.LockedCoins(blockTime).AmountOf(denom).String(),
+			"locked coins of %s", name)
+		assert.Equal(t, observed.spendable.String(), c.Keepers.Bank.SpendableCoins(c.Ctx, simchain.Addr(name)).AmountOf(denom).String(),
+			"spendable coins of %s", name)
+	}
+}
+
+// execute all actions of a trace, one by one
+func ExecFromItf(t *testing.T, filename string) {
+	var states = parseItf(t, filename)
+	require.NotEmpty(t, states)
+	chain := setupChain(t, states[0])
+	for i, s := range states {
+		description := fmt.Sprintf("%d_%d_%s_%s", i, s.time, s.lastAction.kind, s.lastAction.account)
+		ok := t.Run(description, func(t *testing.T) {
+			chain.Ctx = chain.Ctx.WithBlockTime(specTime(s.time))
+			if i > 0 && s.lastAction.kind != "advance" {
+				err := executeAction(t, chain, s.lastAction)
+				if s.lastAction.error {
+					require.Error(t, err, "the action should fail")
+				} else {
+					require.NoError(t, err, "the action should succeed")
+				}
+			}
+			checkState(t, chain, s)
+		})
+		if !ok {
+			// the states of the keepers and the spec diverged
+			break
+		}
+	}
+}
+
+func TestOneRun(t *testing.T) {
+	ExecFromItf(t, tracePath("oneRandom.itf.json"))
+}